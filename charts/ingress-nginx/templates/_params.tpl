@@ -25,6 +25,9 @@
 {{- if .Values.udp }}
 - --udp-services-configmap={{ default "$(POD_NAMESPACE)" .Values.controller.udp.configMapNamespace }}/{{ include "ingress-nginx.fullname" . }}-udp
 {{- end }}
+{{- if .Values.controller.streamRoutes.enabled }}
+- --enable-stream-routes=true
+{{- end }}
 {{- if .Values.controller.scope.enabled }}
 - --watch-namespace={{ default "$(POD_NAMESPACE)" .Values.controller.scope.namespace }}
 {{- end }}
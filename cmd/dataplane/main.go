@@ -85,7 +85,7 @@ func main() {
 
 	mux := http.NewServeMux()
 	metrics.RegisterHealthz(nginx.HealthPath, mux)
-	metrics.RegisterMetrics(reg, mux)
+	metrics.RegisterMetrics(reg, mux, "")
 
 	go metrics.StartHTTPServer(conf.HealthCheckHost, conf.ListenPorts.Health, mux)
 	go ngx.Start()
@@ -20,16 +20,35 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/nginx/conf"
 )
 
 const (
 	backendsPath = "/configuration/backends"
 	generalPath  = "/configuration/general"
 	certsPath    = "/configuration/certs"
+
+	endpointDrainPath = "/api/v1/endpoints/drain"
+	ingressesPath     = "/api/v1/configuration/ingresses"
+	ingressUsagePath  = "/api/v1/usage/ingresses"
+)
+
+var (
+	healthzPort    int
+	drainTokenFile string
+	drainPort      string
+	drainMinutes   int
+	apiTokenFile   string
 )
 
 func main() {
@@ -108,6 +127,95 @@ func main() {
 	}
 	rootCmd.AddCommand(confCmd)
 
+	confQueryCmd := &cobra.Command{
+		Use:   "query [selector]",
+		Short: "Output the directives of /etc/nginx/nginx.conf matching the selector, e.g. 'server:has(> listen[~443]) > server_name'",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return queryNginxConf(args[0])
+		},
+	}
+	confCmd.AddCommand(confQueryCmd)
+
+	endpointsCmd := &cobra.Command{
+		Use:   "endpoints",
+		Short: "Drain endpoints from the upstreams of all the replicas of the controller",
+	}
+	endpointsCmd.PersistentFlags().IntVar(&healthzPort, "healthz-port", 10254, "Port of the healthz endpoint exposing the endpoint drain API.")
+	endpointsCmd.PersistentFlags().StringVar(&drainTokenFile, "token-file", "", "Path of the file containing the bearer token of the endpoint drain API.")
+	rootCmd.AddCommand(endpointsCmd)
+
+	endpointsDrainedCmd := &cobra.Command{
+		Use:   "drained",
+		Short: "Output the drained endpoints as a JSON array",
+		Run: func(_ *cobra.Command, _ []string) {
+			endpointDrainRequest(http.MethodGet, url.Values{})
+		},
+	}
+	endpointsCmd.AddCommand(endpointsDrainedCmd)
+
+	endpointsDrainCmd := &cobra.Command{
+		Use:   "drain [address]",
+		Short: "Remove the endpoint from the upstreams for the given number of minutes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			endpointDrainRequest(http.MethodPost, url.Values{
+				"address": {args[0]},
+				"port":    {drainPort},
+				"minutes": {strconv.Itoa(drainMinutes)},
+			})
+		},
+	}
+	endpointsDrainCmd.Flags().StringVar(&drainPort, "port", "", "Port of the endpoint, all the ports of the address when empty.")
+	endpointsDrainCmd.Flags().IntVar(&drainMinutes, "minutes", 10, "Number of minutes the endpoint is drained for.")
+	endpointsCmd.AddCommand(endpointsDrainCmd)
+
+	endpointsUndrainCmd := &cobra.Command{
+		Use:   "undrain [address]",
+		Short: "Add the drained endpoint back to the upstreams",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			endpointDrainRequest(http.MethodDelete, url.Values{
+				"address": {args[0]},
+				"port":    {drainPort},
+			})
+		},
+	}
+	endpointsUndrainCmd.Flags().StringVar(&drainPort, "port", "", "Port of the endpoint, all the ports of the address when empty.")
+	endpointsCmd.AddCommand(endpointsUndrainCmd)
+
+	ingressesCmd := &cobra.Command{
+		Use:   "ingresses",
+		Short: "Inspect the Ingresses of the running configuration",
+	}
+	ingressesCmd.PersistentFlags().IntVar(&healthzPort, "healthz-port", 10254, "Port of the healthz endpoint exposing the configuration API.")
+	ingressesCmd.PersistentFlags().StringVar(&apiTokenFile, "token-file", "", "Path of the file containing the bearer token of the configuration API.")
+	rootCmd.AddCommand(ingressesCmd)
+
+	ingressesExpansionCmd := &cobra.Command{
+		Use:   "expansion [namespace/name]",
+		Short: "Output the server and location blocks the Ingress expands to",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			namespace, name, found := strings.Cut(args[0], "/")
+			if !found || namespace == "" || name == "" {
+				return fmt.Errorf("invalid Ingress %q, expected namespace/name", args[0])
+			}
+			controllerAPIRequest(http.MethodGet, ingressesPath+"/"+url.PathEscape(namespace)+"/"+url.PathEscape(name), apiTokenFile, url.Values{})
+			return nil
+		},
+	}
+	ingressesCmd.AddCommand(ingressesExpansionCmd)
+
+	ingressesUsageCmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Output the bytes received and sent by every Ingress by hour, during the last 24 hours",
+		Run: func(_ *cobra.Command, _ []string) {
+			controllerAPIRequest(http.MethodGet, ingressUsagePath, apiTokenFile, url.Values{})
+		},
+	}
+	ingressesCmd.AddCommand(ingressesUsageCmd)
+
 	rootCmd.PersistentFlags().IntVar(&nginx.StatusPort, "status-port", 10246, `Port to use for the lua HTTP endpoint configuration.`)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -249,3 +357,80 @@ func readNginxConf() {
 
 	fmt.Println(conf)
 }
+
+func queryNginxConf(selector string) error {
+	content, err := nginx.ReadNginxConf()
+	if err != nil {
+		return err
+	}
+
+	root, err := conf.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	matches, err := root.Query(selector)
+	if err != nil {
+		return err
+	}
+	for _, d := range matches {
+		fmt.Println(d)
+	}
+	return nil
+}
+
+func endpointDrainRequest(method string, query url.Values) {
+	if query.Get("port") == "" {
+		query.Del("port")
+	}
+
+	controllerAPIRequest(method, endpointDrainPath, drainTokenFile, query)
+}
+
+// controllerAPIRequest sends a request to an API of the controller served
+// by the healthz endpoint and outputs the JSON response
+func controllerAPIRequest(method, path, tokenFile string, query url.Values) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		fmt.Printf("Error reading the API token: %v\n", err)
+		return
+	}
+
+	u := fmt.Sprintf("http://127.0.0.1:%v%v", healthzPort, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, http.NoBody)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("The controller returned code %v: %v", resp.StatusCode, string(body))
+		return
+	}
+
+	var prettyBuffer bytes.Buffer
+	if err := json.Indent(&prettyBuffer, body, "", "  "); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(prettyBuffer.String())
+}
@@ -23,6 +23,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,6 +33,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	discovery "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -69,7 +72,7 @@ func main() {
 		klog.Fatal(err)
 	}
 
-	kubeClient, err := createApiserverClient(conf.APIServerHost, conf.RootCAFile, conf.KubeConfigFile)
+	kubeClient, restConfig, err := createApiserverClient(conf.APIServerHost, conf.RootCAFile, conf.KubeConfigFile)
 	if err != nil {
 		handleFatalInitError(err)
 	}
@@ -115,6 +118,13 @@ func main() {
 	}
 	conf.Client = kubeClient
 
+	if conf.EnableStreamRoutes {
+		conf.DynamicClient, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			klog.Fatalf("Error creating dynamic client: %v", err)
+		}
+	}
+
 	err = k8s.GetIngressPod(kubeClient)
 	if err != nil {
 		klog.Fatalf("Unexpected error obtaining ingress-nginx pod: %v", err)
@@ -135,6 +145,9 @@ func main() {
 			klog.Fatalf("Error creating prometheus collector:  %v", err)
 		}
 	}
+	if conf.EnableRouteRegressionCheck {
+		mc.EnableRequestSampling(conf.RouteRegressionSamples)
+	}
 	// Pass the ValidationWebhook status to determine if we need to start the collector
 	// for the admissionWebhook
 	mc.Start(conf.ValidationWebhook)
@@ -147,7 +160,59 @@ func main() {
 
 	mux := http.NewServeMux()
 	metrics.RegisterHealthz(nginx.HealthPath, mux, ngx)
-	metrics.RegisterMetrics(reg, mux)
+
+	metricsMux := mux
+	if conf.MetricsTLSSecret != "" {
+		metricsMux = http.NewServeMux()
+	}
+
+	if conf.MetricsTokenFile != "" {
+		handleWithTokenFile(metricsMux, "metrics", conf.MetricsTokenFile, metrics.NewMetricsHandler(reg), "/metrics")
+	} else {
+		metrics.RegisterMetrics(reg, metricsMux, "")
+	}
+	if conf.EnableTenantMetrics {
+		metrics.RegisterTenantMetrics(reg, metricsMux, kubeClient, conf.TenantMetricsAudience)
+	}
+
+	if conf.MetricsTLSSecret != "" {
+		tlsConfig, err := metrics.NewSecretTLSConfig(kubeClient, conf.MetricsTLSSecret, conf.MetricsClientCert, time.Minute, wait.NeverStop)
+		if err != nil {
+			klog.Fatalf("Error reading the certificate of the metrics endpoint: %v", err)
+		}
+		go metrics.StartHTTPSServer(conf.HealthCheckHost, conf.ListenPorts.Metrics, metricsMux, tlsConfig)
+	}
+
+	if conf.EnableConfigurationAPI {
+		handleWithTokenFile(mux, "configuration API", conf.ConfigurationAPITokenFile, ngx.ConfigurationAPIHandler(),
+			controller.ConfigurationAPIPath, controller.ConfigurationAPIPath+"/", controller.IngressUsageAPIPath)
+	}
+
+	if conf.EnableCachePurgeAPI {
+		handleWithTokenFile(mux, "cache purge API", conf.CachePurgeAPITokenFile, ngx.CachePurgeAPIHandler(), controller.CachePurgeAPIPath)
+	}
+
+	if conf.EnableEndpointDrainAPI {
+		handleWithTokenFile(mux, "endpoint drain API", conf.EndpointDrainAPITokenFile, ngx.EndpointDrainAPIHandler(), controller.EndpointDrainAPIPath)
+	}
+
+	if conf.EnableReloadFreezeAPI {
+		handleWithTokenFile(mux, "reload freeze API", conf.ReloadFreezeAPITokenFile, ngx.ReloadFreezeAPIHandler(), controller.ReloadFreezeAPIPath)
+	}
+
+	if conf.EnableChangeApprovalAPI {
+		handleWithTokenFile(mux, "change approval API", conf.ChangeApprovalAPITokenFile, ngx.ChangeApprovalAPIHandler(), controller.ChangeApprovalAPIPath)
+	}
+
+	if conf.EnableErrorPages {
+		errorPages, err := controller.NewErrorPagesHandler(conf.ErrorPagesTemplates)
+		if err != nil {
+			klog.Fatalf("Error loading the error pages templates: %v", err)
+		}
+		errorPagesMux := http.NewServeMux()
+		errorPagesMux.Handle("/", errorPages)
+		go metrics.StartHTTPServer("127.0.0.1", conf.ListenPorts.ErrorPages, errorPagesMux)
+	}
 
 	_, errExists := os.Stat("/chroot")
 	if errExists == nil {
@@ -163,7 +228,50 @@ func main() {
 	})
 }
 
-// createApiserverClient creates a new Kubernetes REST client. apiserverHost is
+// handleWithTokenFile registers in the mux, for the paths, the handler
+// requiring the bearer token contained in tokenFile. The token is read again
+// when the file changes. An empty token is rejected, so a truncated file
+// never exposes the endpoint without authentication.
+func handleWithTokenFile(mux *http.ServeMux, name, tokenFile string, handler http.Handler, paths ...string) {
+	var authenticated atomic.Pointer[http.Handler]
+	load := func() error {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return err
+		}
+		token := strings.TrimSpace(string(content))
+		if token == "" {
+			return fmt.Errorf("the file %v is empty", tokenFile)
+		}
+		h := metrics.RequireBearerToken(token, handler)
+		authenticated.Store(&h)
+		return nil
+	}
+
+	if err := load(); err != nil {
+		klog.Fatalf("Error reading the %v token: %v", name, err)
+	}
+
+	_, err := file.NewFileWatcher(tokenFile, func() {
+		if err := load(); err != nil {
+			klog.ErrorS(err, "Error reading the rotated token, keeping the previous one", "endpoint", name, "file", tokenFile)
+			return
+		}
+		klog.InfoS("Token rotated", "endpoint", name, "file", tokenFile)
+	})
+	if err != nil {
+		klog.Fatalf("Error watching the %v token: %v", name, err)
+	}
+
+	for _, path := range paths {
+		mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*authenticated.Load()).ServeHTTP(w, r)
+		}))
+	}
+}
+
+// createApiserverClient creates a new Kubernetes REST client and returns it
+// with the configuration used to create it. apiserverHost is
 // the URL of the API server in the format protocol://address:port/pathPrefix,
 // kubeConfig is the location of a kubeconfig file. If defined, the kubeconfig
 // file is loaded first, the URL of the API server read from the file is then
@@ -171,10 +279,10 @@ func main() {
 // If neither apiserverHost nor kubeConfig is passed in, we assume the
 // controller runs inside Kubernetes and fallback to the in-cluster config. If
 // the in-cluster config is missing or fails, we fallback to the default config.
-func createApiserverClient(apiserverHost, rootCAFile, kubeConfig string) (*kubernetes.Clientset, error) {
+func createApiserverClient(apiserverHost, rootCAFile, kubeConfig string) (*kubernetes.Clientset, *rest.Config, error) {
 	cfg, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// TODO: remove after k8s v1.22
@@ -206,7 +314,7 @@ func createApiserverClient(apiserverHost, rootCAFile, kubeConfig string) (*kuber
 
 	client, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var v *discovery.Info
@@ -237,7 +345,7 @@ func createApiserverClient(apiserverHost, rootCAFile, kubeConfig string) (*kuber
 	})
 	// err is returned in case of timeout in the exponential backoff (ErrWaitTimeout)
 	if err != nil {
-		return nil, lastErr
+		return nil, nil, lastErr
 	}
 
 	// this should not happen, warn the user
@@ -254,7 +362,7 @@ func createApiserverClient(apiserverHost, rootCAFile, kubeConfig string) (*kuber
 		"platform", v.Platform,
 	)
 
-	return client, nil
+	return client, cfg, nil
 }
 
 // Handler for fatal init errors. Prints a verbose error message and exits.
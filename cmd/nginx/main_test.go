@@ -19,6 +19,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -38,7 +40,7 @@ import (
 )
 
 func TestCreateApiserverClient(t *testing.T) {
-	_, err := createApiserverClient("", "", "")
+	_, _, err := createApiserverClient("", "", "")
 	if err == nil {
 		t.Fatal("Expected an error creating REST client without an API server URL or kubeconfig file.")
 	}
@@ -139,3 +141,71 @@ func createConfigMap(clientSet kubernetes.Interface, ns string, t *testing.T) st
 
 	return cm.Name
 }
+
+func TestHandleWithTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("first\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error writing the token: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	handleWithTokenFile(mux, "test", tokenFile, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), "/a", "/b")
+
+	get := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := get("/a", "first"); status != http.StatusOK {
+		t.Fatalf("expected the token of the file to be accepted but got status %v", status)
+	}
+	if status := get("/b", "first"); status != http.StatusOK {
+		t.Fatalf("expected the handler to be registered for every path but got status %v", status)
+	}
+	if status := get("/a", "invalid"); status != http.StatusUnauthorized {
+		t.Fatalf("expected an invalid token to be rejected but got status %v", status)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("second"), 0o600); err != nil {
+		t.Fatalf("unexpected error writing the token: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for get("/a", "second") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the rotated token to be accepted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := get("/a", "first"); status != http.StatusUnauthorized {
+		t.Fatalf("expected the previous token to be rejected after the rotation but got status %v", status)
+	}
+
+	if err := os.WriteFile(tokenFile, nil, 0o600); err != nil {
+		t.Fatalf("unexpected error truncating the token: %v", err)
+	}
+	if status := get("/a", ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected an empty token to be rejected but got status %v", status)
+	}
+	if err := os.WriteFile(tokenFile, []byte("third"), 0o600); err != nil {
+		t.Fatalf("unexpected error writing the token: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		previous := get("/a", "second")
+		if get("/a", "third") == http.StatusOK {
+			break
+		}
+		if previous != http.StatusOK {
+			t.Fatalf("expected the previous token to be kept while the file is empty but got status %v", previous)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the rotated token to be accepted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+	klog "k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	ingresslint "k8s.io/ingress-nginx/pkg/lint"
+)
+
+type fileOptions struct {
+	ingresslint.Options
+	output string
+	strict bool
+}
+
+func addFileOptions(cmd *cobra.Command, opts *fileOptions) {
+	cmd.Flags().StringVar(&opts.ConfigMap, "configmap", "", "Namespace/name of the ConfigMap of the controller among the files")
+	cmd.Flags().StringVar(&opts.Controller, "controller-class", "k8s.io/ingress-nginx", "Controller of the IngressClasses handled by the controller")
+	cmd.Flags().StringVar(&opts.IngressClass, "ingress-class", "nginx", "Class of the Ingresses handled by the controller")
+	cmd.Flags().BoolVar(&opts.WatchWithoutClass, "watch-ingress-without-class", false, "Handle the Ingresses without class")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Output format of the interpretation of the files, text or json")
+	cmd.Flags().BoolVar(&opts.strict, "strict", false, "Fail on warnings too, e.g. unknown annotations or values replaced with their default")
+}
+
+// files prints how the controller interprets the Ingresses of the files
+// of paths and fails when an Ingress is rejected or some of its paths are
+// not served, or on warnings, e.g. an ignored Ingress, with --strict
+func files(paths []string, opts fileOptions) error {
+	// the errors logged by the controller are the ones of the report
+	silenceLogs()
+
+	interpretations, err := ingresslint.Files(paths, opts.Options)
+	if err != nil {
+		return err
+	}
+
+	switch opts.output {
+	case "json":
+		printed, err := json.MarshalIndent(interpretations, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(printed))
+	case "text":
+		for _, interpretation := range interpretations {
+			printInterpretation(interpretation)
+		}
+	default:
+		return fmt.Errorf("invalid output format %q, expected text or json", opts.output)
+	}
+
+	failed := 0
+	for _, interpretation := range interpretations {
+		if len(interpretation.Errors) > 0 || (opts.strict && len(interpretation.Warnings) > 0) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v Ingresses failed the lint", failed, len(interpretations))
+	}
+	return nil
+}
+
+func printInterpretation(interpretation *ingress.Interpretation) {
+	mark := "✓"
+	if len(interpretation.Errors) > 0 {
+		mark = "✗"
+	} else if interpretation.Class == "" {
+		mark = "-"
+	}
+	class := "ignored"
+	if interpretation.Class != "" {
+		class = "class " + interpretation.Class
+	}
+	fmt.Printf("%v %v (%v)\n", mark, interpretation.Ingress, class)
+
+	for _, e := range interpretation.Errors {
+		fmt.Printf("  - %v\n", e)
+	}
+	for _, w := range interpretation.Warnings {
+		fmt.Printf("  ! %v\n", w)
+	}
+
+	for _, server := range interpretation.Servers {
+		fmt.Printf("  server %v\n", server.Hostname)
+		for _, location := range server.Locations {
+			fmt.Printf("    location %v -> %v\n", location.Location, location.Backend)
+		}
+	}
+
+	names := make([]string, 0, len(interpretation.Annotations))
+	for name := range interpretation.Annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := json.Marshal(interpretation.Annotations[name])
+		if err != nil {
+			value = []byte(fmt.Sprintf("%+v", interpretation.Annotations[name]))
+		}
+		fmt.Printf("  %v: %s\n", name, value)
+	}
+	fmt.Println("")
+}
+
+func silenceLogs() {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	_ = fs.Set("logtostderr", "false")
+	_ = fs.Set("stderrthreshold", "FATAL")
+	klog.SetOutput(io.Discard)
+}
@@ -35,10 +35,23 @@ import (
 // CreateCommand creates and returns this cobra subcommand
 func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
 	var opts *lintOptions
+	var fileOpts fileOptions
 	cmd := &cobra.Command{
-		Use:   "lint",
+		Use:   "lint [path...]",
 		Short: "Inspect kubernetes resources for possible issues",
-		RunE: func(_ *cobra.Command, _ []string) error {
+		Long: `Inspect kubernetes resources for possible issues.
+
+With paths of files or directories, interpret the Ingresses of the YAML and JSON files like the controller does,
+without a cluster: their class, the values of their annotations, the server and location blocks they expand to
+and the reasons the controller ignores them or their paths.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				// the failures are the report, cmd/plugin prints the error
+				cmd.SilenceUsage = true
+				cmd.SilenceErrors = true
+				return files(args, fileOpts)
+			}
+
 			err := opts.Validate()
 			if err != nil {
 				return err
@@ -60,6 +73,7 @@ func CreateCommand(flags *genericclioptions.ConfigFlags) *cobra.Command {
 	}
 
 	opts = addCommonOptions(flags, cmd)
+	addFileOptions(cmd, &fileOpts)
 
 	cmd.AddCommand(createSubcommand(flags, []string{"ingresses", "ingress", "ing"}, "Check ingresses for possible issues", ingresses))
 	cmd.AddCommand(createSubcommand(flags, []string{"deployments", "deployment", "dep"}, "Check deployments for possible issues", deployments))
@@ -42,3 +42,9 @@ ${CODEGEN_PKG}/kube_codegen.sh "deepcopy" \
   .:ingress \
   --output-base "$(dirname ${BASH_SOURCE})/../../.." \
   --go-header-file ${SCRIPT_ROOT}/hack/boilerplate/boilerplate.generated.go.txt
+
+${CODEGEN_PKG}/kube_codegen.sh "deepcopy" \
+  k8s.io/ingress-nginx/internal k8s.io/ingress-nginx/pkg/apis \
+  nginxingress:v1alpha1 \
+  --output-base "$(dirname ${BASH_SOURCE})/../../.." \
+  --go-header-file ${SCRIPT_ROOT}/hack/boilerplate/boilerplate.generated.go.txt
@@ -17,23 +17,34 @@ limitations under the License.
 package controller
 
 import (
+	stdjson "encoding/json"
 	"fmt"
 	"net/http"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/klog/v2"
+
+	ingressapi "k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 )
 
+// ExpansionAuditAnnotation is the audit annotation of the webhook response
+// containing the server and location blocks an Ingress expands to
+const ExpansionAuditAnnotation = "ingress-expansion"
+
 // Checker must return an error if the ingress provided as argument
 // contains invalid instructions
 type Checker interface {
 	CheckIngress(ing *networking.Ingress) error
 	CheckWarning(ing *networking.Ingress) ([]string, error)
+	ExpandIngress(ing *networking.Ingress) (*ingressapi.Expansion, error)
+	CheckStreamRoute(proto corev1.Protocol, namespace, name string, spec *v1alpha1.RouteSpec) error
 }
 
 // IngressAdmission implements the AdmissionController interface
@@ -48,6 +59,11 @@ var ingressResource = metav1.GroupVersionKind{
 	Kind:    "Ingress",
 }
 
+var streamRouteResources = map[metav1.GroupVersionKind]corev1.Protocol{
+	{Group: v1alpha1.GroupName, Version: "v1alpha1", Kind: "TCPRoute"}: corev1.ProtocolTCP,
+	{Group: v1alpha1.GroupName, Version: "v1alpha1", Kind: "UDPRoute"}: corev1.ProtocolUDP,
+}
+
 // HandleAdmission populates the admission Response
 // with Allowed=false if the Object is an ingress that would prevent nginx to reload the configuration
 // with Allowed=true otherwise
@@ -57,6 +73,10 @@ func (ia *IngressAdmission) HandleAdmission(obj runtime.Object) (runtime.Object,
 		return nil, fmt.Errorf("request is not of type AdmissionReview v1 or v1beta1")
 	}
 
+	if proto, ok := streamRouteResources[review.Request.Kind]; ok {
+		return ia.handleStreamRoute(review, proto), nil
+	}
+
 	if !apiequality.Semantic.DeepEqual(review.Request.Kind, ingressResource) {
 		return nil, fmt.Errorf("rejecting admission review because the request does not contain an Ingress resource but %s with name %s in namespace %s",
 			review.Request.Kind.String(), review.Request.Name, review.Request.Namespace)
@@ -104,9 +124,57 @@ func (ia *IngressAdmission) HandleAdmission(obj runtime.Object) (runtime.Object,
 		return review, nil
 	}
 
+	expansion, err := ia.Checker.ExpandIngress(&ingress)
+	if err != nil {
+		klog.ErrorS(err, "failed to expand ingress")
+	} else if expansion != nil {
+		report, err := stdjson.Marshal(expansion)
+		if err != nil {
+			klog.ErrorS(err, "failed to encode ingress expansion")
+		} else {
+			status.AuditAnnotations = map[string]string{ExpansionAuditAnnotation: string(report)}
+		}
+	}
+
 	klog.InfoS("successfully validated configuration, accepting", "ingress", fmt.Sprintf("%v/%v", review.Request.Namespace, review.Request.Name))
 	status.Allowed = true
 	review.Response = status
 
 	return review, nil
 }
+
+// handleStreamRoute populates the admission Response with Allowed=false
+// if the TCPRoute or UDPRoute contains an invalid specification
+func (ia *IngressAdmission) handleStreamRoute(review *admissionv1.AdmissionReview, proto corev1.Protocol) *admissionv1.AdmissionReview {
+	status := &admissionv1.AdmissionResponse{}
+	status.UID = review.Request.UID
+	review.Response = status
+
+	// TCPRoute and UDPRoute share the same specification
+	route := v1alpha1.TCPRoute{}
+	if err := stdjson.Unmarshal(review.Request.Object.Raw, &route); err != nil {
+		klog.ErrorS(err, "failed to decode route", "kind", review.Request.Kind.Kind)
+		status.Allowed = false
+		status.Result = &metav1.Status{
+			Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
+			Message: err.Error(),
+		}
+		return review
+	}
+
+	if err := ia.Checker.CheckStreamRoute(proto, review.Request.Namespace, review.Request.Name, &route.Spec); err != nil {
+		klog.ErrorS(err, "invalid route configuration", "kind", review.Request.Kind.Kind,
+			"route", fmt.Sprintf("%v/%v", review.Request.Namespace, review.Request.Name))
+		status.Allowed = false
+		status.Result = &metav1.Status{
+			Status: metav1.StatusFailure, Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest,
+			Message: err.Error(),
+		}
+		return review
+	}
+
+	klog.InfoS("successfully validated route, accepting", "kind", review.Request.Kind.Kind,
+		"route", fmt.Sprintf("%v/%v", review.Request.Namespace, review.Request.Name))
+	status.Allowed = true
+	return review
+}
@@ -21,13 +21,20 @@ import (
 	"testing"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/json"
+
+	ingressapi "k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 )
 
-const testIngressName = "testIngressName"
+const (
+	testIngressName = "testIngressName"
+	testRouteName   = "testRouteName"
+)
 
 type failTestChecker struct {
 	t *testing.T
@@ -43,9 +50,20 @@ func (ftc failTestChecker) CheckWarning(_ *networking.Ingress) ([]string, error)
 	return nil, nil
 }
 
+func (ftc failTestChecker) ExpandIngress(_ *networking.Ingress) (*ingressapi.Expansion, error) {
+	ftc.t.Error("checker should not be called")
+	return nil, nil
+}
+
+func (ftc failTestChecker) CheckStreamRoute(_ corev1.Protocol, _, _ string, _ *v1alpha1.RouteSpec) error {
+	ftc.t.Error("checker should not be called")
+	return nil
+}
+
 type testChecker struct {
-	t   *testing.T
-	err error
+	t         *testing.T
+	err       error
+	expansion *ingressapi.Expansion
 }
 
 func (tc testChecker) CheckIngress(ing *networking.Ingress) error {
@@ -62,6 +80,26 @@ func (tc testChecker) CheckWarning(ing *networking.Ingress) ([]string, error) {
 	return nil, tc.err
 }
 
+func (tc testChecker) ExpandIngress(ing *networking.Ingress) (*ingressapi.Expansion, error) {
+	if ing.ObjectMeta.Name != testIngressName {
+		tc.t.Errorf("ExpandIngress should be called with %v ingress, but got %v", testIngressName, ing.ObjectMeta.Name)
+	}
+	return tc.expansion, nil
+}
+
+func (tc testChecker) CheckStreamRoute(proto corev1.Protocol, _, name string, spec *v1alpha1.RouteSpec) error {
+	if name != testRouteName {
+		tc.t.Errorf("CheckStreamRoute should be called with %v route, but got %v", testRouteName, name)
+	}
+	if proto != corev1.ProtocolUDP {
+		tc.t.Errorf("CheckStreamRoute should be called with UDP protocol, but got %v", proto)
+	}
+	if spec.Port != 5353 {
+		tc.t.Errorf("CheckStreamRoute should be called with port 5353, but got %v", spec.Port)
+	}
+	return tc.err
+}
+
 func TestHandleAdmission(t *testing.T) {
 	adm := &IngressAdmission{
 		Checker: failTestChecker{t: t},
@@ -132,4 +170,59 @@ func TestHandleAdmission(t *testing.T) {
 	if !review.Response.Allowed {
 		t.Fatalf("when the checker returns no error, the request should be allowed")
 	}
+	if len(review.Response.AuditAnnotations) != 0 {
+		t.Errorf("without expansion, no audit annotation should be added but got %v", review.Response.AuditAnnotations)
+	}
+
+	adm.Checker = testChecker{
+		t: t,
+		expansion: &ingressapi.Expansion{
+			Ingress: "default/" + testIngressName,
+			Servers: []ingressapi.ExpansionServer{},
+		},
+	}
+
+	if _, err := adm.HandleAdmission(review); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := `{"ingress":"default/testIngressName","servers":[]}`
+	if report := review.Response.AuditAnnotations[ExpansionAuditAnnotation]; report != expected {
+		t.Errorf("expected the expansion report %v but got %v", expected, report)
+	}
+}
+
+func TestHandleAdmissionStreamRoute(t *testing.T) {
+	raw, err := json.Marshal(v1alpha1.UDPRoute{
+		ObjectMeta: v1.ObjectMeta{Name: testRouteName},
+		Spec:       v1alpha1.RouteSpec{Port: 5353},
+	})
+	if err != nil {
+		t.Fatalf("failed to prepare test route data: %v", err.Error())
+	}
+
+	review := &admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			Kind:   v1.GroupVersionKind{Group: v1alpha1.GroupName, Version: "v1alpha1", Kind: "UDPRoute"},
+			Name:   testRouteName,
+			Object: runtime.RawExtension{Raw: raw},
+		},
+	}
+
+	adm := &IngressAdmission{
+		Checker: testChecker{t: t, err: fmt.Errorf("this is a test error")},
+	}
+	if _, err := adm.HandleAdmission(review); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if review.Response.Allowed {
+		t.Fatalf("when the checker returns an error, the request should not be allowed")
+	}
+
+	adm.Checker = testChecker{t: t}
+	if _, err := adm.HandleAdmission(review); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !review.Response.Allowed {
+		t.Fatalf("when the checker returns no error, the request should be allowed")
+	}
 }
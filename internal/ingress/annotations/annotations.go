@@ -17,6 +17,9 @@ limitations under the License.
 package annotations
 
 import (
+	"sort"
+	"strings"
+
 	"dario.cat/mergo"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -25,44 +28,74 @@ import (
 	"k8s.io/klog/v2"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/alias"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/attribution"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authcookiesession"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreqglobal"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/backendprotocol"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botmitigation"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/changeapproval"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/chaos"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/clientbodybuffersize"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/concurrencylimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/conflictresolution"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customheaders"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customhttperrors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultbackend"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/disableproxyintercepterrors"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/externalname"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failoverorigin"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/geoaccess"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/graphql"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostdefaultbackend"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/http2pushpreload"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipallowlist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipdenylist"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/linkrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancetuning"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/luaplugins"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/mirror"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/nextupstream"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentelemetry"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/portinredirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestid"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/responsebodyrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/satisfy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/schedule"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/serversnippet"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/servertiming"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/serviceupstream"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/slowstart"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/snippet"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslcertpreference"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslcipher"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthrough"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthroughrouting"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/streamsnippet"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/syntheticprobe"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamsigning"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamvhost"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/xforwardedprefix"
 	"k8s.io/ingress-nginx/internal/ingress/errors"
@@ -77,10 +110,20 @@ type Ingress struct {
 	metav1.ObjectMeta
 	BackendProtocol             string
 	Aliases                     []string
+	Attribution                 attribution.Config
+	ProxyCache                  proxycache.Config
+	UpstreamSigning             upstreamsigning.Config
+	NextUpstream                nextupstream.Config
+	AuthCookieSession           bool
 	BasicDigestAuth             auth.Config
 	Canary                      canary.Config
 	CertificateAuth             authtls.Config
+	BotMitigation               botmitigation.Config
+	ChangeApproval              string
+	Chaos                       chaos.Config
 	ClientBodyBufferSize        string
+	ConcurrencyLimit            concurrencylimit.Config
+	ConflictPriority            int
 	CustomHeaders               customheaders.Config
 	ConfigurationSnippet        string
 	Connection                  connection.Config
@@ -88,33 +131,53 @@ type Ingress struct {
 	CustomHTTPErrors            []int
 	DisableProxyInterceptErrors bool
 	DefaultBackend              *apiv1.Service
+	ExternalName                externalname.Config
+	FailoverOrigin              failoverorigin.Config
 	FastCGI                     fastcgi.Config
 	Denied                      *string
 	ExternalAuth                authreq.Config
 	EnableGlobalAuth            bool
+	GeoAccess                   geoaccess.Config
+	GraphQL                     graphql.Config
+	HostDefaultBackend          *networking.IngressBackend
 	HTTP2PushPreload            bool
+	LuaPlugins                  []string
 	Opentelemetry               opentelemetry.Config
+	PathTemplate                pathtemplate.Config
 	Proxy                       proxy.Config
 	ProxySSL                    proxyssl.Config
 	RateLimit                   ratelimit.Config
 	Redirect                    redirect.Config
+	RequestHeaders              requestheaders.Config
+	RequestID                   requestid.Config
+	ResponseBodyRewrite         responsebodyrewrite.Config
+	LinkRewrite                 linkrewrite.Config
+	RetryPolicy                 retrypolicy.Config
 	Rewrite                     rewrite.Config
 	Satisfy                     string
+	Schedule                    schedule.Config
 	ServerSnippet               string
+	ServerTiming                servertiming.Config
 	ServiceUpstream             bool
 	SessionAffinity             sessionaffinity.Config
+	SlowStart                   slowstart.Config
 	SSLPassthrough              bool
+	SSLPassthroughRouting       sslpassthroughrouting.Config
 	UsePortInRedirects          bool
 	UpstreamHashBy              upstreamhashby.Config
+	UpstreamKeepalive           upstreamkeepalive.Config
 	LoadBalancing               string
+	LoadBalanceTuning           loadbalancetuning.Config
 	UpstreamVhost               string
 	Denylist                    ipdenylist.SourceRange
 	XForwardedPrefix            string
 	SSLCipher                   sslcipher.Config
+	SSLCertificatePreference    sslcertpreference.Config
 	Logs                        log.Config
 	ModSecurity                 modsecurity.Config
 	Mirror                      mirror.Config
 	StreamSnippet               string
+	SyntheticProbe              syntheticprobe.Config
 	Allowlist                   ipallowlist.SourceRange
 }
 
@@ -126,10 +189,20 @@ type Extractor struct {
 func NewAnnotationFactory(cfg resolver.Resolver) map[string]parser.IngressAnnotation {
 	return map[string]parser.IngressAnnotation{
 		"Aliases":                     alias.NewParser(cfg),
+		"Attribution":                 attribution.NewParser(auth.AuthDirectory, cfg),
+		"ProxyCache":                  proxycache.NewParser(cfg),
+		"UpstreamSigning":             upstreamsigning.NewParser(auth.AuthDirectory, cfg),
+		"NextUpstream":                nextupstream.NewParser(cfg),
+		"AuthCookieSession":           authcookiesession.NewParser(cfg),
 		"BasicDigestAuth":             auth.NewParser(auth.AuthDirectory, cfg),
 		"Canary":                      canary.NewParser(cfg),
 		"CertificateAuth":             authtls.NewParser(cfg),
+		"BotMitigation":               botmitigation.NewParser(cfg),
+		"ChangeApproval":              changeapproval.NewParser(cfg),
+		"Chaos":                       chaos.NewParser(cfg),
 		"ClientBodyBufferSize":        clientbodybuffersize.NewParser(cfg),
+		"ConcurrencyLimit":            concurrencylimit.NewParser(cfg),
+		"ConflictPriority":            conflictresolution.NewParser(cfg),
 		"CustomHeaders":               customheaders.NewParser(cfg),
 		"ConfigurationSnippet":        snippet.NewParser(cfg),
 		"Connection":                  connection.NewParser(cfg),
@@ -137,34 +210,54 @@ func NewAnnotationFactory(cfg resolver.Resolver) map[string]parser.IngressAnnota
 		"CustomHTTPErrors":            customhttperrors.NewParser(cfg),
 		"DisableProxyInterceptErrors": disableproxyintercepterrors.NewParser(cfg),
 		"DefaultBackend":              defaultbackend.NewParser(cfg),
+		"ExternalName":                externalname.NewParser(cfg),
+		"FailoverOrigin":              failoverorigin.NewParser(cfg),
 		"FastCGI":                     fastcgi.NewParser(cfg),
 		"ExternalAuth":                authreq.NewParser(cfg),
 		"EnableGlobalAuth":            authreqglobal.NewParser(cfg),
+		"GeoAccess":                   geoaccess.NewParser(cfg),
+		"GraphQL":                     graphql.NewParser(cfg),
+		"HostDefaultBackend":          hostdefaultbackend.NewParser(cfg),
 		"HTTP2PushPreload":            http2pushpreload.NewParser(cfg),
+		"LuaPlugins":                  luaplugins.NewParser(cfg),
 		"Opentelemetry":               opentelemetry.NewParser(cfg),
+		"PathTemplate":                pathtemplate.NewParser(cfg),
 		"Proxy":                       proxy.NewParser(cfg),
 		"ProxySSL":                    proxyssl.NewParser(cfg),
 		"RateLimit":                   ratelimit.NewParser(cfg),
 		"Redirect":                    redirect.NewParser(cfg),
+		"RequestHeaders":              requestheaders.NewParser(cfg),
+		"RequestID":                   requestid.NewParser(cfg),
+		"ResponseBodyRewrite":         responsebodyrewrite.NewParser(cfg),
+		"LinkRewrite":                 linkrewrite.NewParser(cfg),
+		"RetryPolicy":                 retrypolicy.NewParser(cfg),
 		"Rewrite":                     rewrite.NewParser(cfg),
 		"Satisfy":                     satisfy.NewParser(cfg),
+		"Schedule":                    schedule.NewParser(cfg),
 		"ServerSnippet":               serversnippet.NewParser(cfg),
+		"ServerTiming":                servertiming.NewParser(cfg),
 		"ServiceUpstream":             serviceupstream.NewParser(cfg),
 		"SessionAffinity":             sessionaffinity.NewParser(cfg),
+		"SlowStart":                   slowstart.NewParser(cfg),
 		"SSLPassthrough":              sslpassthrough.NewParser(cfg),
+		"SSLPassthroughRouting":       sslpassthroughrouting.NewParser(cfg),
 		"UsePortInRedirects":          portinredirect.NewParser(cfg),
 		"UpstreamHashBy":              upstreamhashby.NewParser(cfg),
+		"UpstreamKeepalive":           upstreamkeepalive.NewParser(cfg),
 		"LoadBalancing":               loadbalancing.NewParser(cfg),
+		"LoadBalanceTuning":           loadbalancetuning.NewParser(cfg),
 		"UpstreamVhost":               upstreamvhost.NewParser(cfg),
 		"Allowlist":                   ipallowlist.NewParser(cfg),
 		"Denylist":                    ipdenylist.NewParser(cfg),
 		"XForwardedPrefix":            xforwardedprefix.NewParser(cfg),
 		"SSLCipher":                   sslcipher.NewParser(cfg),
+		"SSLCertificatePreference":    sslcertpreference.NewParser(cfg),
 		"Logs":                        log.NewParser(cfg),
 		"BackendProtocol":             backendprotocol.NewParser(cfg),
 		"ModSecurity":                 modsecurity.NewParser(cfg),
 		"Mirror":                      mirror.NewParser(cfg),
 		"StreamSnippet":               streamsnippet.NewParser(cfg),
+		"SyntheticProbe":              syntheticprobe.NewParser(cfg),
 	}
 }
 
@@ -181,8 +274,40 @@ func (e Extractor) Extract(ing *networking.Ingress) (*Ingress, error) {
 		ObjectMeta: ing.ObjectMeta,
 	}
 
-	data := make(map[string]interface{})
+	data, err := e.parse(ing, e.annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mergo.MapWithOverwrite(pia, data)
+	if err != nil {
+		klog.ErrorS(err, "unexpected error merging extracted annotations")
+	}
+
+	return pia, nil
+}
+
+// Interpret returns the values Extract merges into the Ingress of the
+// parsers of the annotations present in the Ingress, by name of parser,
+// and the reason its locations are denied under DeniedKeyName
+func (e Extractor) Interpret(ing *networking.Ingress) (map[string]interface{}, error) {
+	used := make(map[string]parser.IngressAnnotation)
 	for name, annotationParser := range e.annotations {
+		for annotation := range annotationParser.GetDocumentation() {
+			if _, ok := ing.GetAnnotations()[parser.GetAnnotationWithPrefix(annotation)]; ok {
+				used[name] = annotationParser
+				break
+			}
+		}
+	}
+
+	return e.parse(ing, used)
+}
+
+// parse returns the values of the annotation parsers by name of parser
+func (e Extractor) parse(ing *networking.Ingress, annotationParsers map[string]parser.IngressAnnotation) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	for name, annotationParser := range annotationParsers {
 		if err := annotationParser.Validate(ing.GetAnnotations()); err != nil {
 			return nil, errors.NewRiskyAnnotations(name)
 		}
@@ -225,10 +350,42 @@ func (e Extractor) Extract(ing *networking.Ingress) (*Ingress, error) {
 		}
 	}
 
-	err := mergo.MapWithOverwrite(pia, data)
-	if err != nil {
-		klog.ErrorS(err, "unexpected error merging extracted annotations")
+	return data, nil
+}
+
+// Schema returns the schema of the annotations of the extractor
+func (e Extractor) Schema() parser.AnnotationSchema {
+	features := make([]parser.AnnotationFields, 0, len(e.annotations))
+	for _, annotationParser := range e.annotations {
+		features = append(features, annotationParser.GetDocumentation())
 	}
+	return parser.NewAnnotationSchema(features...)
+}
 
-	return pia, nil
+// ValidateStrict returns an error when an annotation of the Ingress is
+// unknown, has a value not matching its schema or a value the parser of the
+// annotation ignores, instead of using the default of the annotation like
+// Extract does.
+func (e Extractor) ValidateStrict(ing *networking.Ingress) error {
+	var invalid []string
+	for _, err := range e.Schema().Validate(ing.GetAnnotations()) {
+		invalid = append(invalid, err.Error())
+	}
+
+	names := make([]string, 0, len(e.annotations))
+	for name := range e.annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := e.annotations[name].Parse(ing); errors.IsInvalidContent(err) {
+			invalid = append(invalid, err.Error())
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+	return errors.Errorf("invalid annotations: %s", strings.Join(invalid, "; "))
 }
@@ -366,3 +366,72 @@ func TestCustomResponseHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateStrict(t *testing.T) {
+	ec := NewAnnotationExtractor(mockCfg{})
+	ing := buildIngress()
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expectedErr bool
+	}{
+		{"no annotations", nil, false},
+		{"valid annotations", map[string]string{
+			annotationPassthrough:                                     "true",
+			parser.GetAnnotationWithPrefix("permanent-redirect"):      "https://example.com",
+			parser.GetAnnotationWithPrefix("permanent-redirect-code"): "308",
+			"kubernetes.io/ingress.class":                             "nginx",
+		}, false},
+		{"unknown annotation", map[string]string{parser.GetAnnotationWithPrefix("proxy-body-sise"): "8m"}, true},
+		{"invalid value", map[string]string{annotationPassthrough: "maybe"}, true},
+		{"redirect code replaced with the default", map[string]string{
+			parser.GetAnnotationWithPrefix("permanent-redirect"):      "https://example.com",
+			parser.GetAnnotationWithPrefix("permanent-redirect-code"): "200",
+		}, true},
+		{"redirect without host", map[string]string{parser.GetAnnotationWithPrefix("temporal-redirect"): "https:/path"}, true},
+		{"value ignored by the parser", map[string]string{parser.GetAnnotationWithPrefix("concurrency-limit"): "0"}, true},
+		{"negative timeout", map[string]string{parser.GetAnnotationWithPrefix("proxy-read-timeout"): "-1"}, true},
+		{"size with spaces", map[string]string{parser.GetAnnotationWithPrefix("proxy-body-size"): "8 m"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing.SetAnnotations(tc.annotations)
+			err := ec.ValidateStrict(ing)
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error %v but returned %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestInterpret(t *testing.T) {
+	ec := NewAnnotationExtractor(mockCfg{})
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		annotationPassthrough:    "true",
+		annotationUpstreamHashBy: "$request_uri",
+		"example.com/unrelated":  "true",
+	})
+
+	parsed, err := ec.Interpret(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(parsed) != 2 {
+		t.Errorf("expected the values of 2 parsers but returned %v", parsed)
+	}
+	if parsed["SSLPassthrough"] != true {
+		t.Errorf("expected SSLPassthrough true but returned %v", parsed["SSLPassthrough"])
+	}
+	if _, ok := parsed["UpstreamHashBy"]; !ok {
+		t.Errorf("expected a value of UpstreamHashBy but returned %v", parsed)
+	}
+
+	ing.SetAnnotations(map[string]string{annotationPassthrough: "maybe"})
+	if _, err := ec.Interpret(ing); err == nil {
+		t.Errorf("expected an error with an invalid annotation value")
+	}
+}
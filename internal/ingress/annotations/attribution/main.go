@@ -0,0 +1,193 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attribution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/pkg/util/file"
+)
+
+const (
+	attributionSecretAnnotation = "attribution-signing-secret" //#nosec G101
+	attributionHeaderAnnotation = "attribution-header"
+)
+
+const (
+	// DefaultHeader is the name of the signed header sent to the backend
+	DefaultHeader = "X-Ingress-Attribution"
+
+	secretKey   = "key"
+	secretKeyID = "key-id"
+)
+
+var (
+	headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	keyIDRegex      = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+var attributionAnnotations = parser.Annotation{
+	Group: "authentication",
+	Annotations: parser.AnnotationFields{
+		attributionSecretAnnotation: {
+			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines the Secret, in the format <namespace>/<name>, with the key used to sign the attribution header sent to the backend. ` +
+				`The Secret must contain the HMAC key in the key "key", and can contain its identifier in the key "key-id"`,
+		},
+		attributionHeaderAnnotation: {
+			Validator:     parser.ValidateRegex(headerNameRegex, false),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the name of the attribution header. Defaults to X-Ingress-Attribution`,
+		},
+	},
+}
+
+// Config contains the key used to sign the attribution header of a location
+type Config struct {
+	Enabled bool   `json:"enabled"`
+	Header  string `json:"header"`
+	Secret  string `json:"secret"`
+	KeyID   string `json:"keyId"`
+	KeyFile string `json:"keyFile"`
+	KeySHA  string `json:"keySha"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type attribution struct {
+	r                resolver.Resolver
+	keyDirectory     string
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new attribution header annotation parser
+func NewParser(keyDirectory string, r resolver.Resolver) parser.IngressAnnotation {
+	return attribution{
+		r:                r,
+		keyDirectory:     keyDirectory,
+		annotationConfig: attributionAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to sign
+// the attribution header and writes the signing key to a file read by NGINX
+func (a attribution) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation(attributionSecretAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+
+	sns, sname, err := cache.SplitMetaNamespaceKey(s)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading secret name from annotation: %w", err),
+		}
+	}
+	if sns == "" {
+		sns = ing.Namespace
+	}
+	if !a.r.GetSecurityConfiguration().AllowCrossNamespaceResources && sns != ing.Namespace {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("cross namespace usage of secrets is not allowed"),
+		}
+	}
+
+	name := fmt.Sprintf("%v/%v", sns, sname)
+	secret, err := a.r.GetSecret(name)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error reading secret %s: %w", name, err),
+		}
+	}
+
+	key := secret.Data[secretKey]
+	if len(key) == 0 {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("the secret %s does not contain a key with value %s", name, secretKey),
+		}
+	}
+
+	// the key identifier tells the backends which key verifies the
+	// signature while the keys are being rotated
+	keyID := string(secret.Data[secretKeyID])
+	if keyID == "" {
+		sum := sha256.Sum256(key)
+		keyID = hex.EncodeToString(sum[:4])
+	}
+	if !keyIDRegex.MatchString(keyID) {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(attributionSecretAnnotation, secretKeyID)
+	}
+
+	header, err := parser.GetStringAnnotation(attributionHeaderAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if !ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, err
+		}
+		header = DefaultHeader
+	}
+
+	keyFile := fmt.Sprintf("%v/%v-%v-%v.attribution", a.keyDirectory, ing.GetNamespace(), ing.UID, secret.UID)
+	if err := os.WriteFile(keyFile, key, file.ReadWriteByUser); err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error creating attribution key file: %w", err),
+		}
+	}
+
+	return &Config{
+		Enabled: true,
+		Header:  header,
+		Secret:  name,
+		KeyID:   keyID,
+		KeyFile: keyFile,
+		KeySHA:  file.SHA1(keyFile),
+	}, nil
+}
+
+func (a attribution) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a attribution) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, attributionAnnotations.Annotations)
+}
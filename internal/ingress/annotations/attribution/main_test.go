@@ -0,0 +1,128 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attribution
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	switch name {
+	case "default/signing":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "signing", UID: "uid"},
+			Data:       map[string][]byte{secretKey: []byte("secret")},
+		}, nil
+	case "default/signing-with-id":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "signing-with-id", UID: "uid"},
+			Data:       map[string][]byte{secretKey: []byte("secret"), secretKeyID: []byte("2024-01")},
+		}, nil
+	case "default/invalid-id":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "invalid-id", UID: "uid"},
+			Data:       map[string][]byte{secretKey: []byte("secret"), secretKeyID: []byte("a;b")},
+		}, nil
+	case "default/empty":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "empty", UID: "uid"},
+		}, nil
+	}
+	return nil, fmt.Errorf("there is no secret with name %v", name)
+}
+
+func TestParse(t *testing.T) {
+	secret := parser.GetAnnotationWithPrefix(attributionSecretAnnotation)
+	header := parser.GetAnnotationWithPrefix(attributionHeaderAnnotation)
+
+	dir := t.TempDir()
+	ap := NewParser(dir, mockSecret{})
+	keyFile := fmt.Sprintf("%v/default-ing-uid.attribution", dir)
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{
+			map[string]string{secret: "signing"},
+			Config{Enabled: true, Header: DefaultHeader, Secret: "default/signing", KeyID: "2bb80d53", KeyFile: keyFile},
+			false,
+		},
+		{
+			map[string]string{secret: "default/signing-with-id", header: "X-Edge-Attribution"},
+			Config{Enabled: true, Header: "X-Edge-Attribution", Secret: "default/signing-with-id", KeyID: "2024-01", KeyFile: keyFile},
+			false,
+		},
+		{map[string]string{secret: "other/signing"}, Config{}, true},
+		{map[string]string{secret: "default/missing"}, Config{}, true},
+		{map[string]string{secret: "default/empty"}, Config{}, true},
+		{map[string]string{secret: "default/invalid-id"}, Config{}, true},
+		{map[string]string{secret: "signing", header: "X Attribution"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+			UID:       "ing",
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		// the SHA depends on the content of the key file
+		config.KeySHA = ""
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+		if config.Enabled {
+			key, err := os.ReadFile(config.KeyFile)
+			if err != nil {
+				t.Fatalf("unexpected error reading the key file: %v", err)
+			}
+			if string(key) != "secret" {
+				t.Errorf("expected the key file to contain the key but got %q", key)
+			}
+		}
+	}
+}
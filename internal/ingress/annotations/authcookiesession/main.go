@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authcookiesession
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	authCookieSessionAnnotation = "auth-cookie-session"
+)
+
+var authCookieSessionAnnotations = parser.Annotation{
+	Group: "authentication",
+	Annotations: parser.AnnotationFields{
+		authCookieSessionAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation stores the cookies set by the external authentication or the OAuth/OIDC flow in a server-side session ` +
+				`when they are bigger than auth-cookie-session-threshold, replacing them with a short session cookie.`,
+		},
+	},
+}
+
+type authCookieSession struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new auth cookie session annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authCookieSession{
+		r:                r,
+		annotationConfig: authCookieSessionAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress to indicate if
+// the cookies set by the authentication flow must be stored server-side
+func (a authCookieSession) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parser.GetBoolAnnotation(authCookieSessionAnnotation, ing, a.annotationConfig.Annotations)
+}
+
+func (a authCookieSession) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a authCookieSession) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, authCookieSessionAnnotations.Annotations)
+}
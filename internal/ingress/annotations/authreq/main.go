@@ -18,6 +18,7 @@ package authreq
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
 
@@ -93,10 +94,11 @@ var authReqAnnotations = parser.Annotation{
 			Documentation: `This annotation enables caching for auth requests.`,
 		},
 		authReqKeepaliveAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation specifies the maximum number of keepalive connections to auth-url. Only takes effect when no variables are used in the host part of the URL`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(0, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation specifies the maximum number of keepalive connections to auth-url. Only takes effect when no variables are used in the host part of the URL`,
 		},
 		authReqKeepaliveShareVarsAnnotation: {
 			Validator:     parser.ValidateBool,
@@ -105,16 +107,18 @@ var authReqAnnotations = parser.Annotation{
 			Documentation: `This annotation specifies whether to share Nginx variables among the current request and the auth request`,
 		},
 		authReqKeepaliveRequestsAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation defines the maximum number of requests that can be served through one keepalive connection`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation defines the maximum number of requests that can be served through one keepalive connection`,
 		},
 		authReqKeepaliveTimeout: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation specifies a duration in seconds which an idle keepalive connection to an upstream server will stay open`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation specifies a duration in seconds which an idle keepalive connection to an upstream server will stay open`,
 		},
 		authReqCacheDuration: {
 			Validator:     parser.ValidateRegex(parser.ExtendedCharsRegex, false),
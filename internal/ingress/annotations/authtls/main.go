@@ -19,6 +19,9 @@ package authtls
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	networking "k8s.io/api/networking/v1"
 
@@ -32,17 +35,40 @@ const (
 	defaultAuthTLSDepth     = 1
 	defaultAuthVerifyClient = "on"
 
+	// DefaultCRLRefreshInterval is the interval used to download again the CRL
+	// configured with auth-tls-crl-url
+	DefaultCRLRefreshInterval = time.Hour
+
+	// RevocationFailClosed denies the client certificates that cannot be checked
+	RevocationFailClosed = "fail-closed"
+	// RevocationFailOpen accepts the client certificates that cannot be checked
+	RevocationFailOpen = "fail-open"
+
 	annotationAuthTLSSecret             = "auth-tls-secret" //#nosec G101
 	annotationAuthTLSVerifyClient       = "auth-tls-verify-client"
 	annotationAuthTLSVerifyDepth        = "auth-tls-verify-depth"
 	annotationAuthTLSErrorPage          = "auth-tls-error-page"
 	annotationAuthTLSPassCertToUpstream = "auth-tls-pass-certificate-to-upstream" //#nosec G101
 	annotationAuthTLSMatchCN            = "auth-tls-match-cn"
+	annotationAuthTLSCRLURL             = "auth-tls-crl-url"
+	annotationAuthTLSCRLRefreshInterval = "auth-tls-crl-refresh-interval"
+	annotationAuthTLSOCSP               = "auth-tls-ocsp"
+	annotationAuthTLSOCSPResponder      = "auth-tls-ocsp-responder"
+	annotationAuthTLSRevocationFailure  = "auth-tls-revocation-failure-mode"
+	annotationAuthTLSMatchOU            = "auth-tls-match-ou"
+	annotationAuthTLSMatchSAN           = "auth-tls-match-san"
+	annotationAuthTLSIdentityHeaders    = "auth-tls-identity-headers"
 )
 
+// IdentityAttributes are the attributes of the client certificate
+// that can be sent to the upstream with auth-tls-identity-headers
+var IdentityAttributes = []string{"cn", "ou", "o", "san", "subject", "serial", "fingerprint"}
+
 var (
 	authVerifyClientRegex = regexp.MustCompile(`^(on|off|optional|optional_no_ca)$`)
 	redirectRegex         = regexp.MustCompile(`^((https?://)?[A-Za-z0-9\-.]+(:\d+)?)?(/[A-Za-z0-9\-_.]+)*/?$`)
+	authOCSPRegex         = regexp.MustCompile(`^(on|off|leaf)$`)
+	identityHeaderRegex   = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
 )
 
 var authTLSAnnotations = parser.Annotation{
@@ -84,6 +110,54 @@ var authTLSAnnotations = parser.Annotation{
 			Risk:          parser.AnnotationRiskHigh,
 			Documentation: `This annotation adds a sanity check for the CN of the client certificate that is sent over using a string / regex starting with "CN="`,
 		},
+		annotationAuthTLSCRLURL: {
+			Validator:     parser.ValidateRegex(parser.URLIsValidRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskHigh, // the controller downloads the content of this URL
+			Documentation: `This annotation defines an HTTP(S) URL the controller downloads the certificate revocation list from. It takes precedence over the "ca.crl" key of the secret`,
+		},
+		annotationAuthTLSCRLRefreshInterval: {
+			Validator:     parser.ValidateDuration,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines how often the certificate revocation list is downloaded again from auth-tls-crl-url. Defaults to 1h`,
+		},
+		annotationAuthTLSOCSP: {
+			Validator:     parser.ValidateRegex(authOCSPRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation enables OCSP validation of the client certificate chain. Can be "on", "off" or "leaf" to only check the client certificate`,
+		},
+		annotationAuthTLSOCSPResponder: {
+			Validator:     parser.ValidateRegex(parser.URLIsValidRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation overrides the HTTP(S) URL of the OCSP responder specified in the "Authority Information Access" extension of the client certificate`,
+		},
+		annotationAuthTLSRevocationFailure: {
+			Validator:     parser.ValidateOptions([]string{RevocationFailClosed, RevocationFailOpen}, true, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium, // fail-open accepts certificates whose status is unknown
+			Documentation: `This annotation defines what happens when the CRL configured with auth-tls-crl-url cannot be downloaded, is not signed by the CA or has expired, and when the OCSP responder configured with auth-tls-ocsp-responder is not available. Can be "fail-closed" (default) to deny every client certificate or "fail-open" to stop checking the CRL or OCSP`,
+		},
+		annotationAuthTLSMatchOU: {
+			Validator:     validateMatchRegex,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation defines a regex at least one OU of the subject of the client certificate must match`,
+		},
+		annotationAuthTLSMatchSAN: {
+			Validator:     validateMatchRegex,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation defines a regex at least one subject alternative name of the client certificate must match. The names are prefixed with their type, like "DNS:", "email:", "URI:" or "IP:"`,
+		},
+		annotationAuthTLSIdentityHeaders: {
+			Validator:     validateIdentityHeaders,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines a comma separated list of attribute:header pairs sending the attributes of the client certificate to the upstream. The attributes are cn, ou, o, san, subject, serial and fingerprint`,
+		},
 	},
 }
 
@@ -96,7 +170,31 @@ type Config struct {
 	ErrorPage          string `json:"errorPage"`
 	PassCertToUpstream bool   `json:"passCertToUpstream"`
 	MatchCN            string `json:"matchCN"`
-	AuthTLSError       string
+	// CRLURL is the URL the certificate revocation list is downloaded from
+	CRLURL string `json:"crlURL,omitempty"`
+	// CRLRefreshInterval defines how often the CRL is downloaded again
+	CRLRefreshInterval time.Duration `json:"crlRefreshInterval,omitempty"`
+	// OCSP enables the OCSP validation of the client certificate (on, off or leaf)
+	OCSP string `json:"ocsp,omitempty"`
+	// OCSPResponder overrides the OCSP responder of the client certificate
+	OCSPResponder string `json:"ocspResponder,omitempty"`
+	// RevocationFailureMode is fail-closed or fail-open
+	RevocationFailureMode string `json:"revocationFailureMode,omitempty"`
+	// MatchOU is a regex one of the OUs of the client certificate must match
+	MatchOU string `json:"matchOU,omitempty"`
+	// MatchSAN is a regex one of the subject alternative names of the
+	// client certificate must match
+	MatchSAN string `json:"matchSAN,omitempty"`
+	// IdentityHeaders are the attributes of the client certificate sent
+	// to the upstream
+	IdentityHeaders []IdentityHeader `json:"identityHeaders,omitempty"`
+	AuthTLSError    string
+}
+
+// IdentityHeader sends an attribute of the client certificate to the upstream
+type IdentityHeader struct {
+	Attribute string `json:"attribute"`
+	Header    string `json:"header"`
 }
 
 // Equal tests for equality between two Config types
@@ -125,10 +223,45 @@ func (assl1 *Config) Equal(assl2 *Config) bool {
 	if assl1.MatchCN != assl2.MatchCN {
 		return false
 	}
+	if assl1.CRLURL != assl2.CRLURL {
+		return false
+	}
+	if assl1.CRLRefreshInterval != assl2.CRLRefreshInterval {
+		return false
+	}
+	if assl1.OCSP != assl2.OCSP {
+		return false
+	}
+	if assl1.OCSPResponder != assl2.OCSPResponder {
+		return false
+	}
+	if assl1.RevocationFailureMode != assl2.RevocationFailureMode {
+		return false
+	}
+	if assl1.MatchOU != assl2.MatchOU {
+		return false
+	}
+	if assl1.MatchSAN != assl2.MatchSAN {
+		return false
+	}
+	if len(assl1.IdentityHeaders) != len(assl2.IdentityHeaders) {
+		return false
+	}
+	for i := range assl1.IdentityHeaders {
+		if assl1.IdentityHeaders[i] != assl2.IdentityHeaders[i] {
+			return false
+		}
+	}
 
 	return true
 }
 
+// RevocationFailOpen returns true when the client certificates whose
+// revocation status cannot be obtained must be accepted
+func (assl1 *Config) RevocationFailOpen() bool {
+	return assl1.RevocationFailureMode == RevocationFailOpen
+}
+
 // NewParser creates a new TLS authentication annotation parser
 func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return authTLS{
@@ -209,9 +342,141 @@ func (a authTLS) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.MatchCN = ""
 	}
 
+	config.CRLURL, err = parser.GetStringAnnotation(annotationAuthTLSCRLURL, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		config.CRLURL = ""
+	}
+	if config.CRLURL != "" {
+		crlURL, err := parser.StringToURL(config.CRLURL)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid CRL URL: %v", err))
+		}
+		if crlURL.Scheme != "http" && crlURL.Scheme != "https" {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid CRL URL scheme %q", crlURL.Scheme))
+		}
+
+		config.CRLRefreshInterval = DefaultCRLRefreshInterval
+		interval, err := parser.GetStringAnnotation(annotationAuthTLSCRLRefreshInterval, ing, a.annotationConfig.Annotations)
+		if err != nil && ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			config.CRLRefreshInterval = d
+		}
+	}
+
+	config.OCSP, err = parser.GetStringAnnotation(annotationAuthTLSOCSP, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		config.OCSP = ""
+	}
+	if config.OCSP == "off" {
+		config.OCSP = ""
+	}
+
+	config.OCSPResponder, err = parser.GetStringAnnotation(annotationAuthTLSOCSPResponder, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		config.OCSPResponder = ""
+	}
+	if config.OCSPResponder != "" {
+		responder, err := parser.StringToURL(config.OCSPResponder)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid OCSP responder: %v", err))
+		}
+		if responder.Scheme != "http" && responder.Scheme != "https" {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid OCSP responder scheme %q", responder.Scheme))
+		}
+	}
+
+	config.RevocationFailureMode, err = parser.GetStringAnnotation(annotationAuthTLSRevocationFailure, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		config.RevocationFailureMode = RevocationFailClosed
+	}
+
+	for _, match := range []struct {
+		annotation string
+		value      *string
+	}{
+		{annotationAuthTLSMatchOU, &config.MatchOU},
+		{annotationAuthTLSMatchSAN, &config.MatchSAN},
+	} {
+		*match.value, err = parser.GetStringAnnotation(match.annotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			if ing_errors.IsValidationError(err) {
+				return &Config{}, err
+			}
+			*match.value = ""
+		}
+		if err := validateMatchRegex(*match.value); *match.value != "" && err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid %v: %v", match.annotation, err))
+		}
+	}
+
+	headers, err := parser.GetStringAnnotation(annotationAuthTLSIdentityHeaders, ing, a.annotationConfig.Annotations)
+	if err != nil && ing_errors.IsValidationError(err) {
+		return &Config{}, err
+	}
+	if headers != "" {
+		config.IdentityHeaders, err = parseIdentityHeaders(headers)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+	}
+
 	return config, nil
 }
 
+// validateMatchRegex checks the value of auth-tls-match-ou and auth-tls-match-san
+// is a regex that can be written in the configuration of NGINX
+func validateMatchRegex(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("value %s contains a line break", s)
+	}
+	if _, err := regexp.Compile(s); err != nil {
+		return fmt.Errorf("value %s is not a valid regex: %w", s, err)
+	}
+	return nil
+}
+
+func validateIdentityHeaders(s string) error {
+	_, err := parseIdentityHeaders(s)
+	return err
+}
+
+// parseIdentityHeaders parses a comma separated list of attribute:header pairs
+func parseIdentityHeaders(s string) ([]IdentityHeader, error) {
+	var headers []IdentityHeader
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(s, ",") {
+		attribute, header, found := strings.Cut(strings.TrimSpace(pair), ":")
+		attribute = strings.ToLower(strings.TrimSpace(attribute))
+		header = strings.TrimSpace(header)
+		if !found || !identityHeaderRegex.MatchString(header) {
+			return nil, fmt.Errorf("invalid identity header %q", pair)
+		}
+		if !slices.Contains(IdentityAttributes, attribute) {
+			return nil, fmt.Errorf("invalid client certificate attribute %q, valid attributes are %v", attribute, strings.Join(IdentityAttributes, ", "))
+		}
+		if seen[strings.ToLower(header)] {
+			return nil, fmt.Errorf("duplicated identity header %q", header)
+		}
+		seen[strings.ToLower(header)] = true
+		headers = append(headers, IdentityHeader{Attribute: attribute, Header: header})
+	}
+	return headers, nil
+}
+
 func (a authTLS) GetDocumentation() parser.AnnotationFields {
 	return a.annotationConfig.Annotations
 }
@@ -17,7 +17,9 @@ limitations under the License.
 package authtls
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
@@ -174,6 +176,147 @@ func TestAnnotations(t *testing.T) {
 	}
 }
 
+func TestRevocationAnnotations(t *testing.T) {
+	secret := parser.GetAnnotationWithPrefix(annotationAuthTLSSecret)
+	crlURL := parser.GetAnnotationWithPrefix(annotationAuthTLSCRLURL)
+	crlRefresh := parser.GetAnnotationWithPrefix(annotationAuthTLSCRLRefreshInterval)
+	ocsp := parser.GetAnnotationWithPrefix(annotationAuthTLSOCSP)
+	ocspResponder := parser.GetAnnotationWithPrefix(annotationAuthTLSOCSPResponder)
+	failureMode := parser.GetAnnotationWithPrefix(annotationAuthTLSRevocationFailure)
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{
+			name:        "defaults",
+			annotations: map[string]string{},
+			expected:    Config{RevocationFailureMode: RevocationFailClosed},
+		},
+		{
+			name: "CRL URL with default refresh interval",
+			annotations: map[string]string{
+				crlURL: "http://pki.example.com/ca.crl",
+			},
+			expected: Config{
+				CRLURL:                "http://pki.example.com/ca.crl",
+				CRLRefreshInterval:    DefaultCRLRefreshInterval,
+				RevocationFailureMode: RevocationFailClosed,
+			},
+		},
+		{
+			name: "CRL URL with custom refresh interval",
+			annotations: map[string]string{
+				crlURL:     "https://pki.example.com/ca.crl",
+				crlRefresh: "10m",
+			},
+			expected: Config{
+				CRLURL:                "https://pki.example.com/ca.crl",
+				CRLRefreshInterval:    10 * time.Minute,
+				RevocationFailureMode: RevocationFailClosed,
+			},
+		},
+		{
+			name: "CRL URL with an unsupported scheme",
+			annotations: map[string]string{
+				crlURL: "ftp://pki.example.com/ca.crl",
+			},
+			expectErr: true,
+		},
+		{
+			name: "OCSP fail-open",
+			annotations: map[string]string{
+				ocsp:          "leaf",
+				ocspResponder: "http://ocsp.example.com",
+				failureMode:   RevocationFailOpen,
+			},
+			expected: Config{
+				OCSP:                  "leaf",
+				OCSPResponder:         "http://ocsp.example.com",
+				RevocationFailureMode: RevocationFailOpen,
+			},
+		},
+		{
+			name: "OCSP responder with an unsupported scheme",
+			annotations: map[string]string{
+				ocsp:          "on",
+				ocspResponder: "ldap://ocsp.example.com",
+			},
+			expectErr: true,
+		},
+		{
+			name: "OCSP responder without host",
+			annotations: map[string]string{
+				ocsp:          "on",
+				ocspResponder: "http:/ocsp",
+			},
+			expectErr: true,
+		},
+		{
+			name: "OCSP off",
+			annotations: map[string]string{
+				ocsp: off,
+			},
+			expected: Config{RevocationFailureMode: RevocationFailClosed},
+		},
+		{
+			name: "invalid OCSP mode",
+			annotations: map[string]string{
+				ocsp: "always",
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid failure mode",
+			annotations: map[string]string{
+				failureMode: "fail-maybe",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			tc.annotations[secret] = defaultDemoSecret
+			ing.SetAnnotations(tc.annotations)
+
+			i, err := NewParser(&mockSecret{}).Parse(ing)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error but none returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			u, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("expected *Config but got %T", i)
+			}
+			if u.CRLURL != tc.expected.CRLURL {
+				t.Errorf("expected CRL URL %q but got %q", tc.expected.CRLURL, u.CRLURL)
+			}
+			if u.CRLRefreshInterval != tc.expected.CRLRefreshInterval {
+				t.Errorf("expected CRL refresh interval %v but got %v", tc.expected.CRLRefreshInterval, u.CRLRefreshInterval)
+			}
+			if u.OCSP != tc.expected.OCSP {
+				t.Errorf("expected OCSP %q but got %q", tc.expected.OCSP, u.OCSP)
+			}
+			if u.OCSPResponder != tc.expected.OCSPResponder {
+				t.Errorf("expected OCSP responder %q but got %q", tc.expected.OCSPResponder, u.OCSPResponder)
+			}
+			if u.RevocationFailureMode != tc.expected.RevocationFailureMode {
+				t.Errorf("expected revocation failure mode %q but got %q", tc.expected.RevocationFailureMode, u.RevocationFailureMode)
+			}
+		})
+	}
+}
+
 func TestInvalidAnnotations(t *testing.T) {
 	ing := buildIngress()
 	fakeSecret := &mockSecret{}
@@ -268,6 +411,111 @@ func TestInvalidAnnotations(t *testing.T) {
 	}
 }
 
+func TestAttributeAnnotations(t *testing.T) {
+	secret := parser.GetAnnotationWithPrefix(annotationAuthTLSSecret)
+	matchOU := parser.GetAnnotationWithPrefix(annotationAuthTLSMatchOU)
+	matchSAN := parser.GetAnnotationWithPrefix(annotationAuthTLSMatchSAN)
+	identityHeaders := parser.GetAnnotationWithPrefix(annotationAuthTLSIdentityHeaders)
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{
+			name:        "defaults",
+			annotations: map[string]string{},
+			expected:    Config{},
+		},
+		{
+			name: "OU and SAN",
+			annotations: map[string]string{
+				matchOU:  "^(payments|billing)$",
+				matchSAN: `^URI:spiffe://cluster\.local/ns/payments/`,
+			},
+			expected: Config{
+				MatchOU:  "^(payments|billing)$",
+				MatchSAN: `^URI:spiffe://cluster\.local/ns/payments/`,
+			},
+		},
+		{
+			name: "invalid OU regex",
+			annotations: map[string]string{
+				matchOU: "^(payments",
+			},
+			expectErr: true,
+		},
+		{
+			name: "identity headers",
+			annotations: map[string]string{
+				identityHeaders: "cn:X-Client-CN, SAN:X-Client-SAN,fingerprint:X-Client-Fingerprint",
+			},
+			expected: Config{
+				IdentityHeaders: []IdentityHeader{
+					{Attribute: "cn", Header: "X-Client-CN"},
+					{Attribute: "san", Header: "X-Client-SAN"},
+					{Attribute: "fingerprint", Header: "X-Client-Fingerprint"},
+				},
+			},
+		},
+		{
+			name: "unknown attribute",
+			annotations: map[string]string{
+				identityHeaders: "email:X-Client-Email",
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid header",
+			annotations: map[string]string{
+				identityHeaders: "cn:X Client CN",
+			},
+			expectErr: true,
+		},
+		{
+			name: "duplicated header",
+			annotations: map[string]string{
+				identityHeaders: "cn:X-Client,ou:x-client",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			tc.annotations[secret] = defaultDemoSecret
+			ing.SetAnnotations(tc.annotations)
+
+			i, err := NewParser(&mockSecret{}).Parse(ing)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error but none returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			u, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("expected *Config but got %T", i)
+			}
+			if u.MatchOU != tc.expected.MatchOU {
+				t.Errorf("expected OU match %q but got %q", tc.expected.MatchOU, u.MatchOU)
+			}
+			if u.MatchSAN != tc.expected.MatchSAN {
+				t.Errorf("expected SAN match %q but got %q", tc.expected.MatchSAN, u.MatchSAN)
+			}
+			if !reflect.DeepEqual(u.IdentityHeaders, tc.expected.IdentityHeaders) {
+				t.Errorf("expected identity headers %v but got %v", tc.expected.IdentityHeaders, u.IdentityHeaders)
+			}
+		})
+	}
+}
+
 func TestEquals(t *testing.T) {
 	cfg1 := &Config{}
 	cfg2 := &Config{}
@@ -342,6 +590,51 @@ func TestEquals(t *testing.T) {
 	}
 	cfg2.MatchCN = "CN=(hello-app|goodbye)"
 
+	// Different CRL URL
+	cfg1.CRLURL = "http://pki.example.com/ca.crl"
+	cfg2.CRLURL = "http://pki.example.com/other.crl"
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.CRLURL = "http://pki.example.com/ca.crl"
+
+	// Different OCSP
+	cfg1.OCSP = "on"
+	cfg2.OCSP = "leaf"
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.OCSP = "on"
+
+	// Different Revocation Failure Mode
+	cfg1.RevocationFailureMode = RevocationFailOpen
+	cfg2.RevocationFailureMode = RevocationFailClosed
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.RevocationFailureMode = RevocationFailOpen
+
+	// Different OU match
+	cfg1.MatchOU = "^payments$"
+	cfg2.MatchOU = "^billing$"
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.MatchOU = "^payments$"
+
+	// Different Identity Headers
+	cfg1.IdentityHeaders = []IdentityHeader{{Attribute: "cn", Header: "X-Client-CN"}}
+	cfg2.IdentityHeaders = []IdentityHeader{{Attribute: "ou", Header: "X-Client-CN"}}
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.IdentityHeaders = []IdentityHeader{{Attribute: "cn", Header: "X-Client-CN"}}
+
 	// Equal Configs
 	result = cfg1.Equal(cfg2)
 	if result != true {
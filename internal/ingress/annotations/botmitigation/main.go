@@ -0,0 +1,249 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package botmitigation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/pkg/util/sets"
+)
+
+const (
+	botChallengeAnnotation             = "bot-challenge"
+	botChallengeUserAgentsAnnotation   = "bot-challenge-user-agents"
+	botChallengeFingerprintsAnnotation = "bot-challenge-fingerprints"
+	botChallengeRateAnnotation         = "bot-challenge-rate"
+	botBlockUserAgentsAnnotation       = "bot-block-user-agents"
+	botAllowlistSourceRangeAnnotation  = "bot-allowlist-source-range"
+	botAllowlistUserAgentsAnnotation   = "bot-allowlist-user-agents"
+)
+
+const (
+	// ChallengeCookie redirects the clients to the same URL with a signed
+	// cookie, the clients not keeping the cookies are not let through
+	ChallengeCookie = "cookie"
+	// ChallengeJS returns a page setting the signed cookie with JavaScript
+	ChallengeJS = "js"
+)
+
+var (
+	challenges = []string{ChallengeCookie, ChallengeJS}
+
+	// JA4 fingerprints, like t13d1516h2_8daaf6152771_02713d6af862
+	fingerprintsRegexp = regexp.MustCompile(`^[a-z0-9_]+(,[a-z0-9_]+)*$`)
+)
+
+var botMitigationAnnotations = parser.Annotation{
+	Group: "bot-mitigation",
+	Annotations: parser.AnnotationFields{
+		botChallengeAnnotation: {
+			Validator: parser.ValidateOptions(challenges, true, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation challenges the suspicious clients before their requests reach the backend: cookie redirects them with a signed cookie, ` +
+				`js returns a page setting the cookie with JavaScript. Without bot-challenge-user-agents, bot-challenge-fingerprints or bot-challenge-rate every client is challenged`,
+		},
+		botChallengeUserAgentsAnnotation: {
+			Validator:     validateRegex,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation defines a case-insensitive regex of the User-Agent headers of the clients challenged`,
+		},
+		botChallengeFingerprintsAnnotation: {
+			Validator:     parser.ValidateRegex(fingerprintsRegexp, true),
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines a comma separated list of JA4 fingerprints of the TLS clients challenged. It requires the enable-tls-fingerprinting ConfigMap option`,
+		},
+		botChallengeRateAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the number of requests per minute from a client address above which the client is challenged`,
+		},
+		botBlockUserAgentsAnnotation: {
+			Validator:     validateRegex,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation defines a case-insensitive regex of the User-Agent headers of the clients denied with the status code 403`,
+		},
+		botAllowlistSourceRangeAnnotation: {
+			Validator:     parser.ValidateCIDRs,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation sets the list of IPs and networks never challenged nor blocked`,
+		},
+		botAllowlistUserAgentsAnnotation: {
+			Validator:     validateRegex,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation defines a case-insensitive regex of the User-Agent headers of the clients never challenged nor blocked`,
+		},
+	},
+}
+
+// Config contains the bot mitigation of an Ingress
+type Config struct {
+	Challenge             string   `json:"challenge,omitempty"`
+	ChallengeUserAgents   string   `json:"challengeUserAgents,omitempty"`
+	ChallengeFingerprints []string `json:"challengeFingerprints,omitempty"`
+	ChallengeRate         int      `json:"challengeRate,omitempty"`
+	BlockUserAgents       string   `json:"blockUserAgents,omitempty"`
+	AllowlistSourceRange  []string `json:"allowlistSourceRange,omitempty"`
+	AllowlistUserAgents   string   `json:"allowlistUserAgents,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Challenge != c2.Challenge {
+		return false
+	}
+	if c1.ChallengeUserAgents != c2.ChallengeUserAgents {
+		return false
+	}
+	if !slices.Equal(c1.ChallengeFingerprints, c2.ChallengeFingerprints) {
+		return false
+	}
+	if c1.ChallengeRate != c2.ChallengeRate {
+		return false
+	}
+	if c1.BlockUserAgents != c2.BlockUserAgents {
+		return false
+	}
+	if !sets.StringElementsMatch(c1.AllowlistSourceRange, c2.AllowlistSourceRange) {
+		return false
+	}
+
+	return c1.AllowlistUserAgents == c2.AllowlistUserAgents
+}
+
+// Enabled returns true when the clients of the Ingress are challenged or blocked
+func (c *Config) Enabled() bool {
+	return c.Challenge != "" || c.BlockUserAgents != ""
+}
+
+func validateRegex(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("value %s contains a line break", s)
+	}
+	if _, err := regexp.Compile(s); err != nil {
+		return fmt.Errorf("value %s is not a valid regex: %w", s, err)
+	}
+	return nil
+}
+
+type botMitigation struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new bot mitigation annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return botMitigation{
+		r:                r,
+		annotationConfig: botMitigationAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to challenge or block the suspicious clients
+func (a botMitigation) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	var err error
+	for _, annotation := range []struct {
+		name  string
+		value *string
+	}{
+		{botChallengeAnnotation, &config.Challenge},
+		{botChallengeUserAgentsAnnotation, &config.ChallengeUserAgents},
+		{botBlockUserAgentsAnnotation, &config.BlockUserAgents},
+		{botAllowlistUserAgentsAnnotation, &config.AllowlistUserAgents},
+	} {
+		*annotation.value, err = parser.GetStringAnnotation(annotation.name, ing, a.annotationConfig.Annotations)
+		if err != nil && !ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, err
+		}
+	}
+	config.Challenge = strings.TrimSpace(config.Challenge)
+
+	fingerprints, err := parser.GetStringAnnotation(botChallengeFingerprintsAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if fingerprints != "" {
+		config.ChallengeFingerprints = strings.Split(strings.ReplaceAll(fingerprints, " ", ""), ",")
+	}
+
+	config.ChallengeRate, err = parser.GetIntAnnotation(botChallengeRateAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.ChallengeRate < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(botChallengeRateAnnotation, config.ChallengeRate)
+	}
+
+	val, err := parser.GetStringAnnotation(botAllowlistSourceRangeAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+
+	ipnets, ips, err := net.ParseIPNets(strings.Split(val, ",")...)
+	if err != nil && len(ips) == 0 {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("the annotation does not contain a valid IP address or network: %w", err),
+		}
+	}
+
+	for k := range ipnets {
+		config.AllowlistSourceRange = append(config.AllowlistSourceRange, k)
+	}
+	for k := range ips {
+		config.AllowlistSourceRange = append(config.AllowlistSourceRange, k)
+	}
+	sort.Strings(config.AllowlistSourceRange)
+
+	return config, nil
+}
+
+func (a botMitigation) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a botMitigation) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, botMitigationAnnotations.Annotations)
+}
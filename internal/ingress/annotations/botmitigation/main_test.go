@@ -0,0 +1,114 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package botmitigation
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	challenge := parser.GetAnnotationWithPrefix(botChallengeAnnotation)
+	challengeUserAgents := parser.GetAnnotationWithPrefix(botChallengeUserAgentsAnnotation)
+	challengeFingerprints := parser.GetAnnotationWithPrefix(botChallengeFingerprintsAnnotation)
+	challengeRate := parser.GetAnnotationWithPrefix(botChallengeRateAnnotation)
+	blockUserAgents := parser.GetAnnotationWithPrefix(botBlockUserAgentsAnnotation)
+	allowlistSourceRange := parser.GetAnnotationWithPrefix(botAllowlistSourceRangeAnnotation)
+	allowlistUserAgents := parser.GetAnnotationWithPrefix(botAllowlistUserAgentsAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{challenge: "js"}, Config{Challenge: ChallengeJS}, false},
+		{
+			map[string]string{
+				challenge:             "cookie",
+				challengeUserAgents:   "(curl|python-requests)/",
+				challengeFingerprints: "t13d1516h2_8daaf6152771_02713d6af862, t13d191000_9dc949149365_e7c285222651",
+				challengeRate:         "120",
+				allowlistSourceRange:  "10.0.0.0/8,192.168.1.1",
+				allowlistUserAgents:   "Googlebot",
+			},
+			Config{
+				Challenge:             ChallengeCookie,
+				ChallengeUserAgents:   "(curl|python-requests)/",
+				ChallengeFingerprints: []string{"t13d1516h2_8daaf6152771_02713d6af862", "t13d191000_9dc949149365_e7c285222651"},
+				ChallengeRate:         120,
+				AllowlistSourceRange:  []string{"10.0.0.0/8", "192.168.1.1"},
+				AllowlistUserAgents:   "Googlebot",
+			},
+			false,
+		},
+		{map[string]string{blockUserAgents: "(sqlmap|nikto|masscan)"}, Config{BlockUserAgents: "(sqlmap|nikto|masscan)"}, false},
+		{map[string]string{challenge: "captcha"}, Config{}, true},
+		{map[string]string{challengeUserAgents: "(curl"}, Config{}, true},
+		{map[string]string{challengeFingerprints: "t13d;deny all"}, Config{}, true},
+		{map[string]string{challengeRate: "-1"}, Config{}, true},
+		{map[string]string{allowlistSourceRange: "not-a-network"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if (&Config{ChallengeRate: 100}).Enabled() {
+		t.Errorf("expected no mitigation without challenge")
+	}
+	if !(&Config{Challenge: ChallengeCookie}).Enabled() {
+		t.Errorf("expected the clients to be challenged")
+	}
+	if !(&Config{BlockUserAgents: "sqlmap"}).Enabled() {
+		t.Errorf("expected the clients to be blocked")
+	}
+}
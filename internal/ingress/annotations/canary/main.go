@@ -20,6 +20,7 @@ import (
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/klog/v2"
 
+	"k8s.io/ingress-nginx/internal/ingress/annotations/geoaccess"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
@@ -33,6 +34,7 @@ const (
 	canaryByHeaderValueAnnotation   = "canary-by-header-value"
 	canaryByHeaderPatternAnnotation = "canary-by-header-pattern"
 	canaryByCookieAnnotation        = "canary-by-cookie"
+	canaryByGeoAnnotation           = "canary-by-geo"
 )
 
 var CanaryAnnotations = parser.Annotation{
@@ -88,6 +90,13 @@ var CanaryAnnotations = parser.Annotation{
 			Documentation: `This annotation defines the cookie that should be used for notifying the Ingress to route the request to the service specified in the Canary Ingress.
 			When the cookie is set to 'always', it will be routed to the canary. When the cookie is set to 'never', it will never be routed to the canary`,
 		},
+		canaryByGeoAnnotation: {
+			Validator: parser.ValidateRegex(geoaccess.CountryCodesRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines a comma separated list of ISO country codes. Requests from clients located in these countries are routed to the service specified in the Canary Ingress.
+			Requests from other countries are compared against the other canary rules by precedence`,
+		},
 	},
 }
 
@@ -105,6 +114,7 @@ type Config struct {
 	HeaderValue   string
 	HeaderPattern string
 	Cookie        string
+	Geo           []string
 }
 
 // NewParser parses the ingress for canary related annotations
@@ -177,8 +187,19 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.Cookie = ""
 	}
 
+	geo, err := parser.GetStringAnnotation(canaryByGeoAnnotation, ing, c.annotationConfig.Annotations)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			klog.Warningf("%s is invalid, defaulting to ''", canaryByGeoAnnotation)
+		}
+		geo = ""
+	}
+	if geo != "" {
+		config.Geo = geoaccess.ParseCountries(geo)
+	}
+
 	if !config.Enabled && (config.Weight > 0 || config.Header != "" || config.HeaderValue != "" || config.Cookie != "" ||
-		config.HeaderPattern != "") {
+		config.HeaderPattern != "" || len(config.Geo) > 0) {
 		return nil, errors.NewInvalidAnnotationConfiguration(canaryAnnotation, "configured but not enabled")
 	}
 
@@ -17,6 +17,7 @@ limitations under the License.
 package canary
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -106,16 +107,21 @@ func TestAnnotations(t *testing.T) {
 		canaryWeight  int
 		canaryHeader  string
 		canaryCookie  string
+		canaryGeo     string
+		expGeo        []string
 		expErr        bool
 	}{
-		{"canary disabled and no weight", false, 0, "", "", false},
-		{"canary disabled and weight", false, 20, "", "", true},
-		{"canary disabled and header", false, 0, "X-Canary", "", true},
-		{"canary disabled and cookie", false, 0, "", "canary_enabled", true},
-		{"canary enabled and weight", true, 20, "", "", false},
-		{"canary enabled and no weight", true, 0, "", "", false},
-		{"canary enabled by header", true, 20, "X-Canary", "", false},
-		{"canary enabled by cookie", true, 20, "", "canary_enabled", false},
+		{"canary disabled and no weight", false, 0, "", "", "", nil, false},
+		{"canary disabled and weight", false, 20, "", "", "", nil, true},
+		{"canary disabled and header", false, 0, "X-Canary", "", "", nil, true},
+		{"canary disabled and cookie", false, 0, "", "canary_enabled", "", nil, true},
+		{"canary disabled and geo", false, 0, "", "", "US", nil, true},
+		{"canary enabled and weight", true, 20, "", "", "", nil, false},
+		{"canary enabled and no weight", true, 0, "", "", "", nil, false},
+		{"canary enabled by header", true, 20, "X-Canary", "", "", nil, false},
+		{"canary enabled by cookie", true, 20, "", "canary_enabled", "", nil, false},
+		{"canary enabled by geo", true, 0, "", "", "us,CA", []string{"CA", "US"}, false},
+		{"canary enabled with invalid geo", true, 0, "", "", "USA", nil, false},
 	}
 
 	for _, test := range tests {
@@ -123,6 +129,7 @@ func TestAnnotations(t *testing.T) {
 		data[parser.GetAnnotationWithPrefix("canary-weight")] = strconv.Itoa(test.canaryWeight)
 		data[parser.GetAnnotationWithPrefix("canary-by-header")] = test.canaryHeader
 		data[parser.GetAnnotationWithPrefix("canary-by-cookie")] = test.canaryCookie
+		data[parser.GetAnnotationWithPrefix("canary-by-geo")] = test.canaryGeo
 
 		i, err := NewParser(&resolver.Mock{}).Parse(ing)
 		if test.expErr {
@@ -152,5 +159,8 @@ func TestAnnotations(t *testing.T) {
 		if canaryConfig.Cookie != test.canaryCookie {
 			t.Errorf("%v: expected \"%v\", but \"%v\" was returned", test.title, test.canaryCookie, canaryConfig.Cookie)
 		}
+		if !reflect.DeepEqual(canaryConfig.Geo, test.expGeo) {
+			t.Errorf("%v: expected \"%v\", but \"%v\" was returned", test.title, test.expGeo, canaryConfig.Geo)
+		}
 	}
 }
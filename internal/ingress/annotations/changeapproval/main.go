@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package changeapproval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	approveChangeAnnotation = "approve-change"
+)
+
+// changeIDRegex matches the identifiers of the staged changes
+var changeIDRegex = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+var changeApprovalAnnotations = parser.Annotation{
+	Group: "changeapproval",
+	Annotations: parser.AnnotationFields{
+		approveChangeAnnotation: {
+			Validator: parser.ValidateRegex(changeIDRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation approves the high-impact change of the Ingress staged by the controller with this identifier, ` +
+				`reported in the ChangeStaged event of the Ingress. It is only used with --enable-change-approval.`,
+		},
+	},
+}
+
+// ChangeID returns the identifier of the version of an Ingress approved with
+// the approve-change annotation, computed from its spec and its annotations
+// other than approve-change, which are the only parts of the Ingress changing
+// its configuration
+func ChangeID(ing *networking.Ingress) string {
+	approve := parser.GetAnnotationWithPrefix(approveChangeAnnotation)
+	annotations := map[string]string{}
+	for name, value := range ing.Annotations {
+		if name != approve {
+			annotations[name] = value
+		}
+	}
+
+	// json sorts the keys of the maps
+	data, _ := json.Marshal(struct {
+		Spec        networking.IngressSpec
+		Annotations map[string]string
+	}{ing.Spec, annotations})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+type changeApproval struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new change approval annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return changeApproval{
+		r:                r,
+		annotationConfig: changeApprovalAnnotations,
+	}
+}
+
+// Parse parses the annotation contained in the ingress rule
+// used to approve a staged change of the Ingress
+func (a changeApproval) Parse(ing *networking.Ingress) (interface{}, error) {
+	id, err := parser.GetStringAnnotation(approveChangeAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		return "", nil
+	}
+	return id, nil
+}
+
+func (a changeApproval) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a changeApproval) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, changeApprovalAnnotations.Annotations)
+}
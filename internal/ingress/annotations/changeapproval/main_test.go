@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package changeapproval
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func buildIngress() *networking.Ingress {
+	return &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{Host: "foo.bar.com"}},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	approve := parser.GetAnnotationWithPrefix(approveChangeAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    string
+	}{
+		{nil, ""},
+		{map[string]string{approve: "0123456789abcdef"}, "0123456789abcdef"},
+		{map[string]string{approve: "yes"}, ""},
+	}
+
+	ing := buildIngress()
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if result != testCase.expected {
+			t.Errorf("expected %q but returned %q, annotations: %v", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
+
+func TestChangeID(t *testing.T) {
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{parser.GetAnnotationWithPrefix("rewrite-target"): "/"})
+
+	id := ChangeID(ing)
+	if !changeIDRegex.MatchString(id) {
+		t.Fatalf("expected a change identifier but returned %q", id)
+	}
+
+	ing.Annotations[parser.GetAnnotationWithPrefix(approveChangeAnnotation)] = id
+	if ChangeID(ing) != id {
+		t.Errorf("expected the approve-change annotation not to change the identifier")
+	}
+
+	ing.Spec.Rules[0].Host = "bar.foo.com"
+	if ChangeID(ing) == id {
+		t.Errorf("expected a different identifier for a different spec")
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	chaosAbortPercentAnnotation = "chaos-abort-percent"
+	chaosDelayMsAnnotation      = "chaos-delay-ms"
+	chaosDelayPercentAnnotation = "chaos-delay-percent"
+)
+
+// defaultDelayPercent delays every request when only chaos-delay-ms is set
+const defaultDelayPercent = 100
+
+var percentRegex = regexp.MustCompile(`^(100(\.0+)?|[0-9]{1,2}(\.[0-9]+)?)$`)
+
+var chaosAnnotations = parser.Annotation{
+	Group: "chaos",
+	Annotations: parser.AnnotationFields{
+		chaosAbortPercentAnnotation: {
+			Validator: parser.ValidateRegex(percentRegex, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the percentage of the requests aborted with the status code 503 before they reach the backend. ` +
+				`It requires the flag --enable-chaos-injection`,
+		},
+		chaosDelayMsAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the delay in milliseconds added to the requests before they reach the backend. ` +
+				`It requires the flag --enable-chaos-injection`,
+		},
+		chaosDelayPercentAnnotation: {
+			Validator:     parser.ValidateRegex(percentRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the percentage of the requests delayed by chaos-delay-ms. (default: 100)`,
+		},
+	},
+}
+
+// Config contains the faults injected in the requests of a location
+type Config struct {
+	AbortPercent float32 `json:"abortPercent"`
+	DelayMs      int     `json:"delayMs"`
+	DelayPercent float32 `json:"delayPercent"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+// Enabled returns true when faults are injected in the requests
+func (c *Config) Enabled() bool {
+	return c.AbortPercent > 0 || (c.DelayMs > 0 && c.DelayPercent > 0)
+}
+
+type chaos struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new chaos injection annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return chaos{
+		r:                r,
+		annotationConfig: chaosAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to inject faults in the requests
+func (a chaos) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	var err error
+	config.AbortPercent, err = a.parsePercent(chaosAbortPercentAnnotation, ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+
+	config.DelayMs, err = parser.GetIntAnnotation(chaosDelayMsAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.DelayMs < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(chaosDelayMsAnnotation, config.DelayMs)
+	}
+
+	config.DelayPercent, err = a.parsePercent(chaosDelayPercentAnnotation, ing)
+	switch {
+	case ing_errors.IsMissingAnnotations(err):
+		config.DelayPercent = defaultDelayPercent
+	case err != nil:
+		return &Config{}, err
+	}
+
+	if config.DelayMs == 0 {
+		config.DelayPercent = 0
+	}
+
+	return config, nil
+}
+
+// parsePercent returns the value of a percentage annotation, between 0 and 100
+func (a chaos) parsePercent(name string, ing *networking.Ingress) (float32, error) {
+	percent, err := parser.GetFloatAnnotation(name, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		return 0, err
+	}
+
+	if percent < 0 || percent > 100 {
+		return 0, ing_errors.NewInvalidAnnotationContent(name, percent)
+	}
+
+	return percent, nil
+}
+
+func (a chaos) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a chaos) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, chaosAnnotations.Annotations)
+}
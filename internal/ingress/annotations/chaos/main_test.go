@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	abortPercent := parser.GetAnnotationWithPrefix(chaosAbortPercentAnnotation)
+	delayMs := parser.GetAnnotationWithPrefix(chaosDelayMsAnnotation)
+	delayPercent := parser.GetAnnotationWithPrefix(chaosDelayPercentAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{abortPercent: "10"}, Config{AbortPercent: 10}, false},
+		{map[string]string{abortPercent: "0.5"}, Config{AbortPercent: 0.5}, false},
+		{map[string]string{delayMs: "200"}, Config{DelayMs: 200, DelayPercent: 100}, false},
+		{map[string]string{delayMs: "200", delayPercent: "25"}, Config{DelayMs: 200, DelayPercent: 25}, false},
+		{map[string]string{delayPercent: "25"}, Config{}, false},
+		{
+			map[string]string{abortPercent: "100", delayMs: "1500", delayPercent: "50.5"},
+			Config{AbortPercent: 100, DelayMs: 1500, DelayPercent: 50.5},
+			false,
+		},
+		{map[string]string{abortPercent: "101"}, Config{}, true},
+		{map[string]string{abortPercent: "-1"}, Config{}, true},
+		{map[string]string{abortPercent: "ten"}, Config{}, true},
+		{map[string]string{delayMs: "-200"}, Config{}, true},
+		{map[string]string{delayMs: "200", delayPercent: "150"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if (&Config{DelayPercent: 100}).Enabled() {
+		t.Errorf("expected no fault without delay")
+	}
+	if !(&Config{DelayMs: 100, DelayPercent: 100}).Enabled() {
+		t.Errorf("expected the delay to be injected")
+	}
+	if !(&Config{AbortPercent: 1}).Enabled() {
+		t.Errorf("expected the abort to be injected")
+	}
+}
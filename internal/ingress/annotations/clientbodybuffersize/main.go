@@ -31,9 +31,10 @@ var clientBodyBufferSizeConfig = parser.Annotation{
 	Group: "backend",
 	Annotations: parser.AnnotationFields{
 		clientBodyBufferSizeAnnotation: {
-			Validator: parser.ValidateRegex(parser.SizeRegex, true),
-			Scope:     parser.AnnotationScopeLocation,
-			Risk:      parser.AnnotationRiskLow, // Low, as it allows just a set of options
+			Validator:       parser.ValidateRegex(parser.SizeRegex, true),
+			StrictValidator: parser.ValidateSize,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow, // Low, as it allows just a set of options
 			Documentation: `Sets buffer size for reading client request body per location. 
 			In case the request body is larger than the buffer, the whole body or only its part is written to a temporary file. 
 			By default, buffer size is equal to two memory pages. This is 8K on x86, other 32-bit platforms, and x86-64. 
@@ -64,3 +64,14 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestStrictValidation(t *testing.T) {
+	schema := parser.NewAnnotationSchema(NewParser(&resolver.Mock{}).GetDocumentation())
+	annotation := parser.GetAnnotationWithPrefix("client-body-buffer-size")
+
+	for value, valid := range map[string]bool{"8k": true, "10000": true, "8 k": false, "16R": false} {
+		if errs := schema.Validate(map[string]string{annotation: value}); (len(errs) == 0) != valid {
+			t.Errorf("expected %q valid %v but returned %v", value, valid, errs)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package concurrencylimit
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	concurrencyLimitAnnotation             = "concurrency-limit"
+	concurrencyLimitQueueAnnotation        = "concurrency-limit-queue"
+	concurrencyLimitQueueTimeoutAnnotation = "concurrency-limit-queue-timeout"
+)
+
+const defaultQueueTimeout = time.Second
+
+var concurrencyLimitAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		concurrencyLimitAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of requests to the backends of the Ingress processed concurrently by each controller replica. ` +
+				`The requests above the limit are queued or rejected with the limit-conn-status-code`,
+		},
+		concurrencyLimitQueueAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of requests waiting for the requests above the concurrency-limit to complete. 0 disables the queue`,
+		},
+		concurrencyLimitQueueTimeoutAnnotation: {
+			Validator:     parser.ValidateDuration,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets how long a request waits in the queue before being rejected, like 500ms or 2s`,
+		},
+	},
+}
+
+// Config contains the maximum number of concurrent requests to the backends of an Ingress
+type Config struct {
+	Limit        int           `json:"limit"`
+	Queue        int           `json:"queue"`
+	QueueTimeout time.Duration `json:"queueTimeout"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type concurrencyLimit struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new concurrency limit annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return concurrencyLimit{
+		r:                r,
+		annotationConfig: concurrencyLimitAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to limit the concurrent requests to the backends
+func (a concurrencyLimit) Parse(ing *networking.Ingress) (interface{}, error) {
+	limit, err := parser.GetIntAnnotation(concurrencyLimitAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+	if limit <= 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(concurrencyLimitAnnotation, limit)
+	}
+
+	config := &Config{
+		Limit:        limit,
+		QueueTimeout: defaultQueueTimeout,
+	}
+
+	config.Queue, err = parser.GetIntAnnotation(concurrencyLimitQueueAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.Queue < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(concurrencyLimitQueueAnnotation, config.Queue)
+	}
+
+	queueTimeout, err := parser.GetStringAnnotation(concurrencyLimitQueueTimeoutAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.QueueTimeout, err = time.ParseDuration(queueTimeout)
+		if err != nil || config.QueueTimeout < time.Millisecond {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(concurrencyLimitQueueTimeoutAnnotation, queueTimeout)
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a concurrencyLimit) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a concurrencyLimit) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, concurrencyLimitAnnotations.Annotations)
+}
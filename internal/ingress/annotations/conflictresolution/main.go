@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package conflictresolution
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const conflictPriorityAnnotation = "conflict-priority"
+
+const (
+	// ResolveOldest serves a host and path defined by several Ingresses
+	// with the oldest Ingress
+	ResolveOldest = "oldest"
+	// ResolveNewest serves a host and path defined by several Ingresses
+	// with the newest Ingress
+	ResolveNewest = "newest"
+	// ResolvePriority serves a host and path defined by several Ingresses
+	// with the Ingress with the highest conflict-priority, the oldest one
+	// when several Ingresses have the same priority
+	ResolvePriority = "priority"
+	// ResolveReject does not serve a host and path defined by several Ingresses
+	ResolveReject = "reject"
+)
+
+// Strategies contains the valid conflict resolution strategies
+var Strategies = []string{ResolveOldest, ResolveNewest, ResolvePriority, ResolveReject}
+
+var conflictResolutionAnnotations = parser.Annotation{
+	Group: "conflicts",
+	Annotations: parser.AnnotationFields{
+		conflictPriorityAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the priority of the Ingress when a host and path is defined by several Ingresses ` +
+				`and the ingress-conflict-resolution ConfigMap option is "priority". The Ingress with the highest priority serves the host and path.`,
+		},
+	},
+}
+
+type conflictResolution struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new conflict priority annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return conflictResolution{
+		r:                r,
+		annotationConfig: conflictResolutionAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to choose the Ingress serving a conflicting host and path
+func (a conflictResolution) Parse(ing *networking.Ingress) (interface{}, error) {
+	priority, err := parser.GetIntAnnotation(conflictPriorityAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return priority, nil
+}
+
+func (a conflictResolution) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a conflictResolution) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, conflictResolutionAnnotations.Annotations)
+}
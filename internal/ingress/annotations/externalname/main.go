@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externalname
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	externalNameSRVAnnotation = "external-name-srv"
+	externalNameTTLAnnotation = "external-name-ttl"
+)
+
+// validSRV matches the service and protocol labels of a SRV record, like _http._tcp
+var validSRV = regexp.MustCompile(`^_[a-z0-9]([-a-z0-9]*[a-z0-9])?\._(tcp|udp)$`)
+
+var externalNameAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		externalNameSRVAnnotation: {
+			Validator: parser.ValidateRegex(validSRV, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation resolves the ExternalName Services of the Ingress with the SRV records of the service and protocol, like _http._tcp, ` +
+				`of the external name. The endpoints use the ports and weights of the records with the lowest priority`,
+		},
+		externalNameTTLAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the time in seconds the addresses of the ExternalName Services of the Ingress are cached ` +
+				`before being resolved again, instead of the TTL of the DNS records`,
+		},
+	},
+}
+
+// Config contains how the ExternalName Services of an Ingress are resolved
+type Config struct {
+	// SRV is the service and protocol of the SRV records, like _http._tcp.
+	// The A and AAAA records of the external name are used when empty.
+	SRV string `json:"srv,omitempty"`
+	// TTL is the time in seconds the resolved addresses are cached.
+	// The TTL of the DNS records is used when 0.
+	TTL int `json:"ttl,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type externalName struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new ExternalName resolution annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return externalName{
+		r:                r,
+		annotationConfig: externalNameAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to configure the resolution of the ExternalName Services
+func (a externalName) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	srv, err := parser.GetStringAnnotation(externalNameSRVAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	config.SRV = srv
+
+	ttl, err := parser.GetIntAnnotation(externalNameTTLAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if ttl < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(externalNameTTLAnnotation, ttl)
+	}
+	config.TTL = ttl
+
+	return config, nil
+}
+
+func (a externalName) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a externalName) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, externalNameAnnotations.Annotations)
+}
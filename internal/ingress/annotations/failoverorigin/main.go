@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failoverorigin
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+const (
+	failoverOriginAnnotation          = "failover-external-origin"
+	failoverOriginHostAnnotation      = "failover-external-origin-host"
+	failoverOriginSSLSecretAnnotation = "failover-external-origin-ssl-secret"
+	failoverOriginSSLVerifyAnnotation = "failover-external-origin-ssl-verify"
+
+	defaultSSLVerify = "off"
+)
+
+var failoverOriginAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		failoverOriginAnnotation: {
+			Validator: parser.ValidateRegex(parser.URLIsValidRegex, false),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskHigh, // High, as it proxies the requests to an arbitrary URL
+			Documentation: `This annotation sets the http or https URL of an external origin the requests are proxied to ` +
+				`when no endpoint of the backend could serve them, like a static copy of the site on a CDN`,
+		},
+		failoverOriginHostAnnotation: {
+			Validator:     parser.ValidateServerName,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskHigh,
+			Documentation: `This annotation sets the Host header and TLS server name of the requests to the external origin, defaulting to the host of its URL`,
+		},
+		failoverOriginSSLSecretAnnotation: {
+			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation specifies a Secret with the trusted CA certificates ca.crt used to verify the certificate of the external origin ` +
+				`and optionally the certificate tls.crt and key tls.key used for authentication to it, in the form "namespace/secretName"`,
+		},
+		failoverOriginSSLVerifyAnnotation: {
+			Validator:     parser.ValidateOptions([]string{"on", "off"}, true, true),
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation enables the verification of the certificate of an https external origin with the CA certificates of failover-external-origin-ssl-secret. Can be "on" or "off" (default)`,
+		},
+	},
+}
+
+// Config contains the external origin the requests of a location fail over
+// to when its backend is unavailable
+type Config struct {
+	// Location is the named location proxying to the external origin
+	Location string `json:"location"`
+	URL      string `json:"url"`
+	// Host is the Host header and TLS server name of the requests
+	Host string `json:"host"`
+	// SSL contains the trusted CA certificates and the client certificate
+	// used for the requests to an https origin
+	SSL resolver.AuthSSLCert `json:"ssl"`
+	// SSLVerify enables the verification of the certificate of the origin
+	SSLVerify string `json:"sslVerify"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type failoverOrigin struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new failover external origin annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return failoverOrigin{
+		r:                r,
+		annotationConfig: failoverOriginAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to fail
+// over to an external origin
+func (a failoverOrigin) Parse(ing *networking.Ingress) (interface{}, error) {
+	origin, err := parser.GetStringAnnotation(failoverOriginAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+	// the URI of the request is appended to the URL
+	u, err := parser.StringToURL(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.RawQuery != "" {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(failoverOriginAnnotation, origin)
+	}
+
+	config := &Config{
+		Location:  fmt.Sprintf("@failover-%v", ing.UID),
+		URL:       origin,
+		Host:      u.Hostname(),
+		SSLVerify: defaultSSLVerify,
+	}
+
+	host, err := parser.GetStringAnnotation(failoverOriginHostAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Host = host
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	secret, err := parser.GetStringAnnotation(failoverOriginSSLSecretAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		ns, _, err := k8s.ParseNameNS(secret)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+
+		// We don't accept different namespaces for secrets.
+		if !a.r.GetSecurityConfiguration().AllowCrossNamespaceResources && ns != ing.Namespace {
+			return &Config{}, ing_errors.NewLocationDenied("cross namespace secrets are not supported")
+		}
+
+		cert, err := a.r.GetAuthCertificate(secret)
+		if err != nil {
+			e := fmt.Errorf("error obtaining certificate: %w", err)
+			return &Config{}, ing_errors.LocationDeniedError{Reason: e}
+		}
+		config.SSL = *cert
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	verify, err := parser.GetStringAnnotation(failoverOriginSSLVerifyAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.SSLVerify = verify
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	if config.SSLVerify == "on" && config.SSL.CAFileName == "" {
+		return &Config{}, ing_errors.NewLocationDenied(
+			fmt.Sprintf("%v requires the CA certificates of %v", failoverOriginSSLVerifyAnnotation, failoverOriginSSLSecretAnnotation))
+	}
+
+	return config, nil
+}
+
+func (a failoverOrigin) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a failoverOrigin) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, failoverOriginAnnotations.Annotations)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failoverorigin
+
+import (
+	"fmt"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+	if name != "default/origin-tls" {
+		return nil, fmt.Errorf("there is no secret with name %v", name)
+	}
+
+	return &resolver.AuthSSLCert{
+		Secret:      name,
+		CAFileName:  "/ssl/ca.pem",
+		CASHA:       "abc",
+		PemFileName: "/ssl/origin.pem",
+	}, nil
+}
+
+func TestParse(t *testing.T) {
+	origin := parser.GetAnnotationWithPrefix(failoverOriginAnnotation)
+	host := parser.GetAnnotationWithPrefix(failoverOriginHostAnnotation)
+	secret := parser.GetAnnotationWithPrefix(failoverOriginSSLSecretAnnotation)
+	verify := parser.GetAnnotationWithPrefix(failoverOriginSSLVerifyAnnotation)
+
+	originSSL := resolver.AuthSSLCert{Secret: "default/origin-tls", CAFileName: "/ssl/ca.pem", CASHA: "abc", PemFileName: "/ssl/origin.pem"}
+
+	ap := NewParser(&mockSecret{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{host: "static.example.com"}, Config{}, false},
+		{
+			map[string]string{origin: "https://static.example.com"},
+			Config{Location: "@failover-uid", URL: "https://static.example.com", Host: "static.example.com", SSLVerify: "off"},
+			false,
+		},
+		{
+			map[string]string{origin: "http://cdn.example.net:8080/www", host: "www.example.com"},
+			Config{Location: "@failover-uid", URL: "http://cdn.example.net:8080/www", Host: "www.example.com", SSLVerify: "off"},
+			false,
+		},
+		{
+			map[string]string{origin: "https://static.example.com", secret: "default/origin-tls", verify: "on"},
+			Config{Location: "@failover-uid", URL: "https://static.example.com", Host: "static.example.com", SSL: originSSL, SSLVerify: "on"},
+			false,
+		},
+		{
+			map[string]string{origin: "https://static.example.com", secret: "default/origin-tls"},
+			Config{Location: "@failover-uid", URL: "https://static.example.com", Host: "static.example.com", SSL: originSSL, SSLVerify: "off"},
+			false,
+		},
+		{map[string]string{origin: "https://static.example.com", verify: "on"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com", verify: "yes"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com", secret: "other/origin-tls"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com", secret: "default/missing"}, Config{}, true},
+		{map[string]string{origin: "static.example.com"}, Config{}, true},
+		{map[string]string{origin: "ftp://static.example.com"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com/?site=www"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com/\";"}, Config{}, true},
+		{map[string]string{origin: "https://static.example.com", host: "www.example.com;"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+			UID:       "uid",
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/client-go/tools/cache"
@@ -31,14 +32,18 @@ import (
 )
 
 const (
-	fastCGIIndexAnnotation  = "fastcgi-index"
-	fastCGIParamsAnnotation = "fastcgi-params-configmap" //#nosec G101
+	fastCGIIndexAnnotation          = "fastcgi-index"
+	fastCGIParamsAnnotation         = "fastcgi-params-configmap" //#nosec G101
+	fastCGIScriptFilenameAnnotation = "fastcgi-script-filename"
+	fastCGISplitPathInfoAnnotation  = "fastcgi-split-path-info"
 )
 
 // fast-cgi valid parameters is just a single file name (like index.php)
 var (
 	regexValidIndexAnnotationAndKey = regexp.MustCompile(`^[A-Za-z0-9.\-\_]+$`)
 	validFCGIValue                  = regexp.MustCompile(`^[A-Za-z0-9\-\_\$\{\}/.]*$`)
+	validConfigMapList              = regexp.MustCompile(`^[A-Za-z0-9\-\_/.,]*$`)
+	validSplitPathInfo              = regexp.MustCompile(`^[A-Za-z0-9\-\_\^\$.*+?:()\[\]|\\/]*$`)
 )
 
 var fastCGIAnnotations = parser.Annotation{
@@ -51,12 +56,26 @@ var fastCGIAnnotations = parser.Annotation{
 			Documentation: `This annotation can be used to specify an index file`,
 		},
 		fastCGIParamsAnnotation: {
-			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Validator: parser.ValidateRegex(validConfigMapList, true),
 			Scope:     parser.AnnotationScopeLocation,
 			Risk:      parser.AnnotationRiskMedium,
 			Documentation: `This annotation can be used to specify a ConfigMap containing the fastcgi parameters as a key/value.
+			Several ConfigMaps can be separated by commas, the parameters of a ConfigMap override the ones of the previous ConfigMaps.
 			Only ConfigMaps on the same namespace of ingress can be used. They key and value from ConfigMap are validated for unauthorized characters.`,
 		},
+		fastCGIScriptFilenameAnnotation: {
+			Validator:     parser.ValidateRegex(validFCGIValue, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation sets the SCRIPT_FILENAME fastcgi parameter, with NGINX variables like /var/www/html$fastcgi_script_name. It overrides the SCRIPT_FILENAME of the fastcgi-params-configmap.`,
+		},
+		fastCGISplitPathInfoAnnotation: {
+			Validator: parser.ValidateRegex(validSplitPathInfo, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines a regular expression with two capture groups, the script name and the path info, like ^(.+\.php)(/.+)$.
+			The path info is sent in the PATH_INFO fastcgi parameter.`,
+		},
 	},
 }
 
@@ -69,6 +88,9 @@ type fastcgi struct {
 type Config struct {
 	Index  string            `json:"index"`
 	Params map[string]string `json:"params"`
+	// SplitPathInfo is the regular expression splitting the
+	// script name and the path info of the request URI
+	SplitPathInfo string `json:"splitPathInfo,omitempty"`
 }
 
 // Equal tests for equality between two Configuration types
@@ -85,6 +107,10 @@ func (l1 *Config) Equal(l2 *Config) bool {
 		return false
 	}
 
+	if l1.SplitPathInfo != l2.SplitPathInfo {
+		return false
+	}
+
 	return reflect.DeepEqual(l1.Params, l2.Params)
 }
 
@@ -115,46 +141,119 @@ func (a fastcgi) Parse(ing *networking.Ingress) (interface{}, error) {
 
 	fcgiConfig.Index = index
 
-	cm, err := parser.GetStringAnnotation(fastCGIParamsAnnotation, ing, a.annotationConfig.Annotations)
+	params, err := a.parseParams(ing)
 	if err != nil {
-		if ing_errors.IsValidationError(err) {
-			return fcgiConfig, err
-		}
-		return fcgiConfig, nil
+		return fcgiConfig, err
 	}
 
-	cmns, cmn, err := cache.SplitMetaNamespaceKey(cm)
-	if err != nil {
-		return fcgiConfig, ing_errors.LocationDeniedError{
-			Reason: fmt.Errorf("error reading configmap name from annotation: %w", err),
+	scriptFilename, err := parser.GetStringAnnotation(fastCGIScriptFilenameAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return fcgiConfig, err
+	}
+	if scriptFilename != "" {
+		if !validFCGIValue.MatchString(scriptFilename) {
+			return fcgiConfig, ing_errors.NewValidationError(fastCGIScriptFilenameAnnotation)
 		}
+		params = withParam(params, "SCRIPT_FILENAME", scriptFilename)
 	}
-	secCfg := a.r.GetSecurityConfiguration()
 
-	// We don't accept different namespaces for secrets.
-	if cmns != "" && !secCfg.AllowCrossNamespaceResources && cmns != ing.Namespace {
-		return fcgiConfig, fmt.Errorf("different namespace is not supported on fast_cgi param configmap")
+	splitPathInfo, err := parser.GetStringAnnotation(fastCGISplitPathInfoAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return fcgiConfig, err
+	}
+	if splitPathInfo != "" {
+		if err := validateSplitPathInfo(splitPathInfo); err != nil {
+			return fcgiConfig, ing_errors.ValidationError{
+				Reason: fmt.Errorf("invalid %v annotation: %w", fastCGISplitPathInfoAnnotation, err),
+			}
+		}
+		fcgiConfig.SplitPathInfo = splitPathInfo
+		params = withParam(params, "PATH_INFO", "$fastcgi_path_info")
 	}
 
-	cm = fmt.Sprintf("%v/%v", ing.Namespace, cmn)
-	cmap, err := a.r.GetConfigMap(cm)
+	fcgiConfig.Params = params
+
+	return fcgiConfig, nil
+}
+
+// parseParams returns the fastcgi parameters of the ConfigMaps of the
+// fastcgi-params-configmap annotation, merged in their order
+func (a fastcgi) parseParams(ing *networking.Ingress) (map[string]string, error) {
+	cms, err := parser.GetStringAnnotation(fastCGIParamsAnnotation, ing, a.annotationConfig.Annotations)
 	if err != nil {
-		return fcgiConfig, ing_errors.LocationDeniedError{
-			Reason: fmt.Errorf("unexpected error reading configmap %s: %w", cm, err),
+		if ing_errors.IsValidationError(err) {
+			return nil, err
 		}
+		return nil, nil
 	}
 
-	for k, v := range cmap.Data {
-		if !regexValidIndexAnnotationAndKey.MatchString(k) || !validFCGIValue.MatchString(v) {
-			klog.ErrorS(fmt.Errorf("fcgi contains invalid key or value"), "fcgi annotation error", "configmap", cmap.Name, "namespace", cmap.Namespace, "key", k, "value", v)
-			return fcgiConfig, ing_errors.NewValidationError(fastCGIParamsAnnotation)
+	var params map[string]string
+	for _, cm := range strings.Split(cms, ",") {
+		cm = strings.TrimSpace(cm)
+		if cm == "" {
+			continue
+		}
+
+		cmns, cmn, err := cache.SplitMetaNamespaceKey(cm)
+		if err != nil {
+			return nil, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("error reading configmap name from annotation: %w", err),
+			}
+		}
+		secCfg := a.r.GetSecurityConfiguration()
+
+		// We don't accept different namespaces for secrets.
+		if cmns != "" && !secCfg.AllowCrossNamespaceResources && cmns != ing.Namespace {
+			return nil, fmt.Errorf("different namespace is not supported on fast_cgi param configmap")
+		}
+
+		cm = fmt.Sprintf("%v/%v", ing.Namespace, cmn)
+		cmap, err := a.r.GetConfigMap(cm)
+		if err != nil {
+			return nil, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("unexpected error reading configmap %s: %w", cm, err),
+			}
+		}
+
+		for k, v := range cmap.Data {
+			if !regexValidIndexAnnotationAndKey.MatchString(k) || !validFCGIValue.MatchString(v) {
+				klog.ErrorS(fmt.Errorf("fcgi contains invalid key or value"), "fcgi annotation error", "configmap", cmap.Name, "namespace", cmap.Namespace, "key", k, "value", v)
+				return nil, ing_errors.NewValidationError(fastCGIParamsAnnotation)
+			}
+		}
+
+		for k, v := range cmap.Data {
+			params = withParam(params, k, v)
 		}
 	}
 
-	fcgiConfig.Index = index
-	fcgiConfig.Params = cmap.Data
+	return params, nil
+}
 
-	return fcgiConfig, nil
+// withParam sets a fastcgi parameter without changing the data of the ConfigMaps
+func withParam(params map[string]string, name, value string) map[string]string {
+	if params == nil {
+		params = map[string]string{}
+	}
+	params[name] = value
+	return params
+}
+
+// validateSplitPathInfo checks the regular expression of fastcgi_split_path_info
+// captures the script name and the path info
+func validateSplitPathInfo(splitPathInfo string) error {
+	if !validSplitPathInfo.MatchString(splitPathInfo) {
+		return fmt.Errorf("%q contains invalid characters", splitPathInfo)
+	}
+
+	re, err := regexp.Compile(splitPathInfo)
+	if err != nil {
+		return err
+	}
+	if re.NumSubexp() != 2 {
+		return fmt.Errorf("%q must contain two capture groups", splitPathInfo)
+	}
+	return nil
 }
 
 func (a fastcgi) GetDocumentation() parser.AnnotationFields {
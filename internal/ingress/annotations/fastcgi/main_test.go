@@ -410,3 +410,120 @@ func Test_fastcgi_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFastCGIScriptAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        Config
+		wantErr     bool
+	}{
+		{
+			name: "script filename",
+			annotations: map[string]string{
+				fastCGIScriptFilenameAnnotation: "/var/www/html$fastcgi_script_name",
+			},
+			want: Config{Params: map[string]string{"SCRIPT_FILENAME": "/var/www/html$fastcgi_script_name"}},
+		},
+		{
+			name: "script filename overriding the configmap",
+			annotations: map[string]string{
+				fastCGIParamsAnnotation:         "base",
+				fastCGIScriptFilenameAnnotation: "/var/www/html$fastcgi_script_name",
+			},
+			want: Config{Params: map[string]string{
+				"REDIRECT_STATUS": "200",
+				"SCRIPT_FILENAME": "/var/www/html$fastcgi_script_name",
+			}},
+		},
+		{
+			name: "configmaps merged in order",
+			annotations: map[string]string{
+				fastCGIParamsAnnotation: "base, app",
+			},
+			want: Config{Params: map[string]string{
+				"REDIRECT_STATUS": "200",
+				"SCRIPT_FILENAME": "/app/index.php",
+				"APP_ENV":         "production",
+			}},
+		},
+		{
+			name: "split path info",
+			annotations: map[string]string{
+				fastCGISplitPathInfoAnnotation: `^(.+\.php)(/.+)$`,
+			},
+			want: Config{
+				SplitPathInfo: `^(.+\.php)(/.+)$`,
+				Params:        map[string]string{"PATH_INFO": "$fastcgi_path_info"},
+			},
+		},
+		{
+			name: "split path info without path info group",
+			annotations: map[string]string{
+				fastCGISplitPathInfoAnnotation: `^(.+\.php)$`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "split path info with invalid characters",
+			annotations: map[string]string{
+				fastCGISplitPathInfoAnnotation: `^(.+\.php)(/.+)$";`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid script filename",
+			annotations: map[string]string{
+				fastCGIScriptFilenameAnnotation: "/var/www/html;index.php",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := buildIngress()
+
+			data := map[string]string{}
+			for k, v := range tt.annotations {
+				data[parser.GetAnnotationWithPrefix(k)] = v
+			}
+			ing.SetAnnotations(data)
+
+			m := &mockConfigMap{
+				extraConfigMap: map[string]map[string]string{
+					"default/base": {"REDIRECT_STATUS": "200", "SCRIPT_FILENAME": "/base/index.php"},
+					"default/app":  {"SCRIPT_FILENAME": "/app/index.php", "APP_ENV": "production"},
+				},
+			}
+
+			got, err := NewParser(m).Parse(ing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fastcgi.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config := got.(Config); !config.Equal(&tt.want) {
+				t.Errorf("fastcgi.Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestParseFastCGIParamsDoNotModifyConfigMap(t *testing.T) {
+	m := mockConfigMap{extraConfigMap: map[string]map[string]string{"default/base": {"REDIRECT_STATUS": "200"}}}
+
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix(fastCGIParamsAnnotation):         "base",
+		parser.GetAnnotationWithPrefix(fastCGIScriptFilenameAnnotation): "/index.php",
+	})
+	if _, err := NewParser(m).Parse(ing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := m.extraConfigMap["default/base"]["SCRIPT_FILENAME"]; ok {
+		t.Errorf("expected the data of the configmap not to be modified")
+	}
+}
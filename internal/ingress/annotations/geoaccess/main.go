@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package geoaccess
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/pkg/util/sets"
+)
+
+const (
+	geoAllowCountriesAnnotation = "geo-allow-countries"
+	geoDenyCountriesAnnotation  = "geo-deny-countries"
+	geoAllowASNsAnnotation      = "geo-allow-asns"
+	geoDenyASNsAnnotation       = "geo-deny-asns"
+)
+
+var (
+	// CountryCodesRegex matches a comma separated list of ISO 3166-1 alpha-2 country codes
+	CountryCodesRegex = regexp.MustCompile(`^[A-Za-z]{2}(\s*,\s*[A-Za-z]{2})*$`)
+	asnsRegex         = regexp.MustCompile(`^(?i)(AS)?\d+(\s*,\s*(AS)?\d+)*$`)
+)
+
+var geoAccessAnnotations = parser.Annotation{
+	Group: "acl",
+	Annotations: parser.AnnotationFields{
+		geoAllowCountriesAnnotation: {
+			Validator:     parser.ValidateRegex(CountryCodesRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium, // Failure on parsing this may cause undesired access
+			Documentation: `This annotation allows setting a comma separated list of ISO country codes whose clients are allowed to access this Location`,
+		},
+		geoDenyCountriesAnnotation: {
+			Validator:     parser.ValidateRegex(CountryCodesRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation allows setting a comma separated list of ISO country codes whose clients are blocked to access this Location`,
+		},
+		geoAllowASNsAnnotation: {
+			Validator:     parser.ValidateRegex(asnsRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation allows setting a comma separated list of autonomous system numbers whose clients are allowed to access this Location`,
+		},
+		geoDenyASNsAnnotation: {
+			Validator:     parser.ValidateRegex(asnsRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium,
+			Documentation: `This annotation allows setting a comma separated list of autonomous system numbers whose clients are blocked to access this Location`,
+		},
+	},
+}
+
+// Config contains the countries and autonomous systems allowed
+// or blocked to access a location
+type Config struct {
+	AllowCountries []string `json:"allowCountries,omitempty"`
+	DenyCountries  []string `json:"denyCountries,omitempty"`
+	AllowASNs      []string `json:"allowASNs,omitempty"`
+	DenyASNs       []string `json:"denyASNs,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return sets.StringElementsMatch(c1.AllowCountries, c2.AllowCountries) &&
+		sets.StringElementsMatch(c1.DenyCountries, c2.DenyCountries) &&
+		sets.StringElementsMatch(c1.AllowASNs, c2.AllowASNs) &&
+		sets.StringElementsMatch(c1.DenyASNs, c2.DenyASNs)
+}
+
+// Enabled returns true when the location restricts the access by country or ASN
+func (c *Config) Enabled() bool {
+	return len(c.AllowCountries) > 0 || len(c.DenyCountries) > 0 || len(c.AllowASNs) > 0 || len(c.DenyASNs) > 0
+}
+
+type geoAccess struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new geo access annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return geoAccess{
+		r:                r,
+		annotationConfig: geoAccessAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to limit access to certain countries or autonomous systems.
+func (a geoAccess) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for _, list := range []struct {
+		annotation string
+		values     *[]string
+		normalize  func(string) string
+	}{
+		{geoAllowCountriesAnnotation, &config.AllowCountries, strings.ToUpper},
+		{geoDenyCountriesAnnotation, &config.DenyCountries, strings.ToUpper},
+		{geoAllowASNsAnnotation, &config.AllowASNs, normalizeASN},
+		{geoDenyASNsAnnotation, &config.DenyASNs, normalizeASN},
+	} {
+		value, err := parser.GetStringAnnotation(list.annotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return &Config{}, err
+		}
+
+		*list.values = parseList(value, list.normalize)
+	}
+
+	return config, nil
+}
+
+// parseList returns the sorted unique values of a comma separated list
+func parseList(value string, normalize func(string) string) []string {
+	seen := map[string]bool{}
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		v = normalize(strings.TrimSpace(v))
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		list = append(list, v)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// ParseCountries returns the sorted unique country codes of a comma separated list
+func ParseCountries(value string) []string {
+	return parseList(value, strings.ToUpper)
+}
+
+func normalizeASN(asn string) string {
+	return strings.TrimPrefix(strings.ToUpper(asn), "AS")
+}
+
+func (a geoAccess) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a geoAccess) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, geoAccessAnnotations.Annotations)
+}
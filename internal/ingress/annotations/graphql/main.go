@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/pkg/util/sets"
+)
+
+const (
+	graphqlEnableAnnotation                 = "graphql-enable"
+	graphqlMaxDepthAnnotation               = "graphql-max-depth"
+	graphqlMaxComplexityAnnotation          = "graphql-max-complexity"
+	graphqlIntrospectionAllowlistAnnotation = "graphql-introspection-allowlist"
+)
+
+var graphqlAnnotations = parser.Annotation{
+	Group: "graphql",
+	Annotations: parser.AnnotationFields{
+		graphqlEnableAnnotation: {
+			Validator:     parser.ValidateBool,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation marks the location as a GraphQL endpoint and enables the inspection of its queries`,
+		},
+		graphqlMaxDepthAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum nesting depth of a GraphQL query. Deeper queries are rejected. 0 disables the check`,
+		},
+		graphqlMaxComplexityAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of fields a GraphQL query can select. Bigger queries are rejected. 0 disables the check`,
+		},
+		graphqlIntrospectionAllowlistAnnotation: {
+			Validator:     parser.ValidateCIDRs,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskMedium, // Failure on parsing this may expose the schema
+			Documentation: `This annotation sets the list of IPs and networks allowed to send introspection queries. Introspection is denied to any other client`,
+		},
+	},
+}
+
+// Config contains the GraphQL protections of a location
+type Config struct {
+	Enabled                bool     `json:"enabled"`
+	MaxDepth               int      `json:"maxDepth"`
+	MaxComplexity          int      `json:"maxComplexity"`
+	IntrospectionAllowlist []string `json:"introspectionAllowlist,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.MaxDepth != c2.MaxDepth {
+		return false
+	}
+	if c1.MaxComplexity != c2.MaxComplexity {
+		return false
+	}
+
+	return sets.StringElementsMatch(c1.IntrospectionAllowlist, c2.IntrospectionAllowlist)
+}
+
+type graphql struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new GraphQL annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return graphql{
+		r:                r,
+		annotationConfig: graphqlAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to protect a GraphQL endpoint
+func (g graphql) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation(graphqlEnableAnnotation, ing, g.annotationConfig.Annotations)
+	if err != nil || !enabled {
+		return &Config{}, err
+	}
+
+	config := &Config{Enabled: true}
+
+	config.MaxDepth, err = parser.GetIntAnnotation(graphqlMaxDepthAnnotation, ing, g.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.MaxDepth < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(graphqlMaxDepthAnnotation, config.MaxDepth)
+	}
+
+	config.MaxComplexity, err = parser.GetIntAnnotation(graphqlMaxComplexityAnnotation, ing, g.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.MaxComplexity < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(graphqlMaxComplexityAnnotation, config.MaxComplexity)
+	}
+
+	val, err := parser.GetStringAnnotation(graphqlIntrospectionAllowlistAnnotation, ing, g.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+
+	ipnets, ips, err := net.ParseIPNets(strings.Split(val, ",")...)
+	if err != nil && len(ips) == 0 {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("the annotation does not contain a valid IP address or network: %w", err),
+		}
+	}
+
+	for k := range ipnets {
+		config.IntrospectionAllowlist = append(config.IntrospectionAllowlist, k)
+	}
+	for k := range ips {
+		config.IntrospectionAllowlist = append(config.IntrospectionAllowlist, k)
+	}
+	sort.Strings(config.IntrospectionAllowlist)
+
+	return config, nil
+}
+
+func (g graphql) GetDocumentation() parser.AnnotationFields {
+	return g.annotationConfig.Annotations
+}
+
+func (g graphql) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(g.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, graphqlAnnotations.Annotations)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphql
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enable := parser.GetAnnotationWithPrefix(graphqlEnableAnnotation)
+	maxDepth := parser.GetAnnotationWithPrefix(graphqlMaxDepthAnnotation)
+	maxComplexity := parser.GetAnnotationWithPrefix(graphqlMaxComplexityAnnotation)
+	allowlist := parser.GetAnnotationWithPrefix(graphqlIntrospectionAllowlistAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, true},
+		{map[string]string{enable: "false", maxDepth: "5"}, Config{}, false},
+		{map[string]string{enable: "true"}, Config{Enabled: true}, false},
+		{map[string]string{enable: "true", maxDepth: "5", maxComplexity: "100"}, Config{Enabled: true, MaxDepth: 5, MaxComplexity: 100}, false},
+		{map[string]string{enable: "true", maxDepth: "-1"}, Config{}, true},
+		{map[string]string{enable: "true", maxDepth: "deep"}, Config{}, true},
+		{
+			map[string]string{enable: "true", allowlist: "10.0.0.0/8, 192.168.1.1"},
+			Config{Enabled: true, IntrospectionAllowlist: []string{"10.0.0.0/8", "192.168.1.1"}},
+			false,
+		},
+		{map[string]string{enable: "true", allowlist: "not-an-ip"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for i := range testCases {
+		ing.SetAnnotations(testCases[i].annotations)
+		result, err := ap.Parse(ing)
+		if testCases[i].expectErr != (err != nil) {
+			t.Errorf("%v: expected error %t but got %v", testCases[i].annotations, testCases[i].expectErr, err)
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("unexpected type: %T", result)
+		}
+		if !config.Equal(&testCases[i].expected) {
+			t.Errorf("%v: expected %+v but got %+v", testCases[i].annotations, testCases[i].expected, config)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostdefaultbackend
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	hostDefaultBackendAnnotation = "host-default-backend"
+)
+
+// backendRegex matches a Service name and its port number or name
+var backendRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?:([0-9]{1,5}|[a-z0-9]([-a-z0-9]*[a-z0-9])?)$`)
+
+var hostDefaultBackendAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		hostDefaultBackendAnnotation: {
+			Validator: parser.ValidateRegex(backendRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the Service and port, as <service>:<port>, serving the requests to the hosts of the Ingress ` +
+				`matching no path, instead of the global default backend`,
+		},
+	},
+}
+
+type hostDefaultBackend struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new host default backend annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return hostDefaultBackend{
+		r:                r,
+		annotationConfig: hostDefaultBackendAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress to use a
+// default backend for its hosts
+func (a hostDefaultBackend) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation(hostDefaultBackendAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	name, port, _ := strings.Cut(s, ":")
+	backend := &networking.IngressBackend{
+		Service: &networking.IngressServiceBackend{Name: name},
+	}
+	if number, err := strconv.Atoi(port); err == nil {
+		if number < 1 || number > 65535 {
+			return nil, ing_errors.NewInvalidAnnotationContent(hostDefaultBackendAnnotation, s)
+		}
+		backend.Service.Port.Number = int32(number)
+	} else {
+		backend.Service.Port.Name = port
+	}
+
+	return backend, nil
+}
+
+func (a hostDefaultBackend) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a hostDefaultBackend) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, hostDefaultBackendAnnotations.Annotations)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostdefaultbackend
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix(hostDefaultBackendAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *networking.IngressBackend
+		expectErr   bool
+	}{
+		{nil, nil, true},
+		{
+			map[string]string{annotation: "custom-404:80"},
+			&networking.IngressBackend{Service: &networking.IngressServiceBackend{
+				Name: "custom-404",
+				Port: networking.ServiceBackendPort{Number: 80},
+			}},
+			false,
+		},
+		{
+			map[string]string{annotation: "custom-404:http"},
+			&networking.IngressBackend{Service: &networking.IngressServiceBackend{
+				Name: "custom-404",
+				Port: networking.ServiceBackendPort{Name: "http"},
+			}},
+			false,
+		},
+		{map[string]string{annotation: "custom-404"}, nil, true},
+		{map[string]string{annotation: "custom-404:0"}, nil, true},
+		{map[string]string{annotation: "custom-404:70000"}, nil, true},
+		{map[string]string{annotation: "Custom-404:80"}, nil, true},
+		{map[string]string{annotation: "other/custom-404:80"}, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		backend, ok := result.(*networking.IngressBackend)
+		if !ok {
+			t.Fatalf("expected a networking.IngressBackend type")
+		}
+		if !reflect.DeepEqual(backend, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, backend, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linkrewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	rewriteLinksAnnotation             = "rewrite-links"
+	rewriteLinksPrefixAnnotation       = "rewrite-links-prefix"
+	rewriteLinksUpstreamURLsAnnotation = "rewrite-links-upstream-urls"
+)
+
+var (
+	prefixRegexp = regexp.MustCompile(`^/[A-Za-z0-9._~/-]*$`)
+	// upstreamURLRegexp matches the absolute URLs of the upstream, the
+	// origin and an optional path
+	upstreamURLRegexp = regexp.MustCompile(`^https?://[A-Za-z0-9.-]+(:[0-9]{1,5})?(/[A-Za-z0-9._~/-]*)?$`)
+)
+
+var linkRewriteAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		rewriteLinksAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation rewrites the links of the HTML and CSS responses, the Location and Content-Location headers ` +
+				`and the path and domain of the cookies of an application served under a path prefix`,
+		},
+		rewriteLinksPrefixAnnotation: {
+			Validator: parser.ValidateRegex(prefixRegexp, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the path prefix the application is served under, like /grafana. ` +
+				`It defaults to the path of the Ingress and is required for the regular expression paths`,
+		},
+		rewriteLinksUpstreamURLsAnnotation: {
+			Validator: validateUpstreamURLs,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the comma separated absolute URLs the application writes in its links, ` +
+				`like http://grafana.monitoring:3000, they are rewritten to the path prefix`,
+		},
+	},
+}
+
+// Config contains the rewriting of the links of an application served
+// under a path prefix
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Prefix is the path prefix the application is served under
+	Prefix string `json:"prefix,omitempty"`
+	// UpstreamURLs are the absolute URLs of the application, without
+	// trailing slash
+	UpstreamURLs []string `json:"upstreamURLs,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	if c1.Enabled != c2.Enabled || c1.Prefix != c2.Prefix || len(c1.UpstreamURLs) != len(c2.UpstreamURLs) {
+		return false
+	}
+	for i := range c1.UpstreamURLs {
+		if c1.UpstreamURLs[i] != c2.UpstreamURLs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseUpstreamURLs parses the comma separated URLs of the annotation
+func parseUpstreamURLs(value string) ([]string, error) {
+	var urls []string
+	for _, u := range strings.Split(value, ",") {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if !upstreamURLRegexp.MatchString(u) {
+			return nil, fmt.Errorf("invalid upstream URL %q, expected an absolute http or https URL without query string", u)
+		}
+		urls = append(urls, strings.TrimSuffix(u, "/"))
+	}
+	return urls, nil
+}
+
+func validateUpstreamURLs(value string) error {
+	_, err := parseUpstreamURLs(value)
+	return err
+}
+
+type linkRewrite struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new link rewrite annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return linkRewrite{
+		r:                r,
+		annotationConfig: linkRewriteAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// rewrite the links of an application served under a path prefix
+func (a linkRewrite) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation(rewriteLinksAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+	if !enabled {
+		return &Config{}, nil
+	}
+
+	config := &Config{Enabled: true}
+
+	prefix, err := parser.GetStringAnnotation(rewriteLinksPrefixAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Prefix = strings.TrimSuffix(prefix, "/")
+		if config.Prefix == "" {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(rewriteLinksPrefixAnnotation, prefix)
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	urls, err := parser.GetStringAnnotation(rewriteLinksUpstreamURLsAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.UpstreamURLs, err = parseUpstreamURLs(urls)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a linkRewrite) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a linkRewrite) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, linkRewriteAnnotations.Annotations)
+}
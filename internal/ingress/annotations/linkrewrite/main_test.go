@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linkrewrite
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enabled := parser.GetAnnotationWithPrefix(rewriteLinksAnnotation)
+	prefix := parser.GetAnnotationWithPrefix(rewriteLinksPrefixAnnotation)
+	upstreamURLs := parser.GetAnnotationWithPrefix(rewriteLinksUpstreamURLsAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := map[string]struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		"no annotations":  {nil, Config{}, false},
+		"disabled":        {map[string]string{enabled: "false", prefix: "/grafana"}, Config{}, false},
+		"without enabled": {map[string]string{prefix: "/grafana"}, Config{}, false},
+		"enabled":         {map[string]string{enabled: "true"}, Config{Enabled: true}, false},
+		"all annotations": {
+			map[string]string{
+				enabled:      "true",
+				prefix:       "/grafana/",
+				upstreamURLs: "http://grafana.monitoring:3000/, https://grafana.example.com/internal",
+			},
+			Config{
+				Enabled:      true,
+				Prefix:       "/grafana",
+				UpstreamURLs: []string{"http://grafana.monitoring:3000", "https://grafana.example.com/internal"},
+			},
+			false,
+		},
+		"invalid enabled":      {map[string]string{enabled: "yes please"}, Config{}, true},
+		"root prefix":          {map[string]string{enabled: "true", prefix: "/"}, Config{}, true},
+		"relative prefix":      {map[string]string{enabled: "true", prefix: "grafana"}, Config{}, true},
+		"prefix with regex":    {map[string]string{enabled: "true", prefix: "/grafana(/|$)(.*)"}, Config{}, true},
+		"relative upstream":    {map[string]string{enabled: "true", upstreamURLs: "grafana.monitoring:3000"}, Config{}, true},
+		"upstream with query":  {map[string]string{enabled: "true", upstreamURLs: "http://grafana?x=1"}, Config{}, true},
+		"upstream with quotes": {map[string]string{enabled: "true", upstreamURLs: `http://grafana";`}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing.SetAnnotations(testCase.annotations)
+			result, err := ap.Parse(ing)
+			if (err != nil) != testCase.expectErr {
+				t.Fatalf("expected error %t but got %v", testCase.expectErr, err)
+			}
+			if testCase.expectErr {
+				return
+			}
+			config, ok := result.(*Config)
+			if !ok {
+				t.Fatalf("expected a Config type but got %T", result)
+			}
+			if !reflect.DeepEqual(*config, testCase.expected) {
+				t.Errorf("expected %+v but got %+v", testCase.expected, *config)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancetuning
+
+import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	peakEWMADecayAnnotation       = "load-balance-peak-ewma-decay"
+	leastRequestChoicesAnnotation = "load-balance-least-request-choices"
+)
+
+const (
+	defaultPeakEWMADecay       = 10 * time.Second
+	defaultLeastRequestChoices = 2
+	maxLeastRequestChoices     = 10
+)
+
+var loadBalanceTuningAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		peakEWMADecayAnnotation: {
+			Validator: parser.ValidateDuration,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the time, like 10s, after which the latency observed for an endpoint by the peak_ewma load balancing ` +
+				`algorithm weighs about a third of its score. Shorter times react faster to a latency change, longer times are less noisy`,
+		},
+		leastRequestChoicesAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the number of endpoints, between 2 and 10, picked at random by the least_request load balancing ` +
+				`algorithm, the request is sent to the one with the fewest requests in flight`,
+		},
+	},
+}
+
+// Config contains the parameters of the load balancing algorithms of a backend
+type Config struct {
+	// PeakEWMADecay is the decay time in seconds of the latency of the
+	// endpoints measured by the peak_ewma algorithm
+	PeakEWMADecay float64 `json:"peakEwmaDecay,omitempty"`
+	// LeastRequestChoices is the number of endpoints compared by the
+	// least_request algorithm
+	LeastRequestChoices int `json:"leastRequestChoices,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type loadBalanceTuning struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new load balancing tuning annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return loadBalanceTuning{
+		r:                r,
+		annotationConfig: loadBalanceTuningAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to tune the load balancing algorithms of the backends
+func (a loadBalanceTuning) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{
+		PeakEWMADecay:       defaultPeakEWMADecay.Seconds(),
+		LeastRequestChoices: defaultLeastRequestChoices,
+	}
+
+	decay, err := parser.GetStringAnnotation(peakEWMADecayAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		d, err := time.ParseDuration(decay)
+		if err != nil || d < time.Second {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(peakEWMADecayAnnotation, decay)
+		}
+		config.PeakEWMADecay = d.Seconds()
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	choices, err := parser.GetIntAnnotation(leastRequestChoicesAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		if choices < 2 || choices > maxLeastRequestChoices {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(leastRequestChoicesAnnotation, choices)
+		}
+		config.LeastRequestChoices = choices
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a loadBalanceTuning) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a loadBalanceTuning) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, loadBalanceTuningAnnotations.Annotations)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadbalancetuning
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	decay := parser.GetAnnotationWithPrefix(peakEWMADecayAnnotation)
+	choices := parser.GetAnnotationWithPrefix(leastRequestChoicesAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{PeakEWMADecay: 10, LeastRequestChoices: 2}, false},
+		{map[string]string{decay: "30s"}, Config{PeakEWMADecay: 30, LeastRequestChoices: 2}, false},
+		{map[string]string{decay: "1m", choices: "3"}, Config{PeakEWMADecay: 60, LeastRequestChoices: 3}, false},
+		{map[string]string{choices: "10"}, Config{PeakEWMADecay: 10, LeastRequestChoices: 10}, false},
+		{map[string]string{decay: "500ms"}, Config{}, true},
+		{map[string]string{decay: "10"}, Config{}, true},
+		{map[string]string{choices: "1"}, Config{}, true},
+		{map[string]string{choices: "11"}, Config{}, true},
+		{map[string]string{choices: "two"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	ap := NewParser(&resolver.Mock{})
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix(peakEWMADecayAnnotation):       "30s",
+				parser.GetAnnotationWithPrefix(leastRequestChoicesAnnotation): "3",
+			},
+		},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ap.Parse(ing); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -17,17 +17,26 @@ limitations under the License.
 package log
 
 import (
+	"regexp"
+	"slices"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
 const (
-	enableAccessLogAnnotation  = "enable-access-log"
-	enableRewriteLogAnnotation = "enable-rewrite-log"
+	enableAccessLogAnnotation    = "enable-access-log"
+	enableRewriteLogAnnotation   = "enable-rewrite-log"
+	skipAccessLogPathsAnnotation = "skip-access-log-paths"
 )
 
+// validSkipAccessLogPaths matches a comma separated list of paths
+var validSkipAccessLogPaths = regexp.MustCompile(`^/[A-Za-z0-9\-._~/]*(,/[A-Za-z0-9\-._~/]*)*$`)
+
 var logAnnotations = parser.Annotation{
 	Group: "log",
 	Annotations: parser.AnnotationFields{
@@ -43,6 +52,13 @@ var logAnnotations = parser.Annotation{
 			Risk:          parser.AnnotationRiskLow,
 			Documentation: `This configuration setting allows you to control if this location should generate logs from the rewrite feature usage`,
 		},
+		skipAccessLogPathsAnnotation: {
+			Validator: parser.ValidateRegex(validSkipAccessLogPaths, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets a comma separated list of paths, like /healthz, whose requests are not written to the access log ` +
+				`and not reported by the request metrics. The paths must be equal to the path of the request`,
+		},
 	},
 }
 
@@ -55,6 +71,8 @@ type log struct {
 type Config struct {
 	Access  bool `json:"accessLog"`
 	Rewrite bool `json:"rewriteLog"`
+	// SkipPaths are the sorted paths of the requests that are not logged
+	SkipPaths []string `json:"skipPaths,omitempty"`
 }
 
 // Equal tests for equality between two Config types
@@ -67,6 +85,10 @@ func (bd1 *Config) Equal(bd2 *Config) bool {
 		return false
 	}
 
+	if !slices.Equal(bd1.SkipPaths, bd2.SkipPaths) {
+		return false
+	}
+
 	return true
 }
 
@@ -94,6 +116,18 @@ func (l log) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.Rewrite = false
 	}
 
+	skipPaths, err := parser.GetStringAnnotation(skipAccessLogPathsAnnotation, ing, l.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		for _, path := range strings.Split(skipPaths, ",") {
+			config.SkipPaths = append(config.SkipPaths, strings.TrimSpace(path))
+		}
+		slices.Sort(config.SkipPaths)
+		config.SkipPaths = slices.Compact(config.SkipPaths)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
 	return config, nil
 }
 
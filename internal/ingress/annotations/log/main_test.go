@@ -17,6 +17,7 @@ limitations under the License.
 package log
 
 import (
+	"slices"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -131,3 +132,39 @@ func TestInvalidBoolConfig(t *testing.T) {
 		t.Errorf("expected access log to be enabled due to invalid config, but it is disabled")
 	}
 }
+
+func TestSkipAccessLogPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		expectedErr bool
+	}{
+		{"single path", "/healthz", []string{"/healthz"}, false},
+		{"sorted and deduplicated", "/readyz,/healthz,/readyz", []string{"/healthz", "/readyz"}, false},
+		{"relative path", "healthz", nil, true},
+		{"invalid characters", "/a b$", nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			ing.SetAnnotations(map[string]string{
+				parser.GetAnnotationWithPrefix(skipAccessLogPathsAnnotation): tc.value,
+			})
+
+			log, err := NewParser(&resolver.Mock{}).Parse(ing)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %v but got %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr {
+				return
+			}
+
+			skipPaths := log.(*Config).SkipPaths
+			if !slices.Equal(skipPaths, tc.expected) {
+				t.Errorf("expected skip paths %v but got %v", tc.expected, skipPaths)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package luaplugins
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const luaPluginsAnnotation = "lua-plugins"
+
+// NameRegex matches the name of a Lua plugin, the name of its module
+var NameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var luaPluginsAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		luaPluginsAnnotation: {
+			Validator: validatePlugins,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium, // the plugins run in every request of the locations
+			Documentation: `This annotation enables the comma separated Lua plugins in the locations of the Ingress. ` +
+				`Only the plugins of the lua-plugins ConfigMap option whose checksum matches are loaded`,
+		},
+	},
+}
+
+// ParsePlugins parses the comma separated names of Lua plugins, in order
+// and without duplicates
+func ParsePlugins(value string) ([]string, error) {
+	var plugins []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !NameRegex.MatchString(name) {
+			return nil, ing_errors.NewInvalidAnnotationContent(luaPluginsAnnotation, name)
+		}
+		seen[name] = true
+		plugins = append(plugins, name)
+	}
+	return plugins, nil
+}
+
+func validatePlugins(value string) error {
+	_, err := ParsePlugins(value)
+	return err
+}
+
+type luaPlugins struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new Lua plugins annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return luaPlugins{
+		r:                r,
+		annotationConfig: luaPluginsAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to enable
+// Lua plugins in the locations
+func (a luaPlugins) Parse(ing *networking.Ingress) (interface{}, error) {
+	value, err := parser.GetStringAnnotation(luaPluginsAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return []string{}, nil
+		}
+		return []string{}, err
+	}
+
+	plugins, err := ParsePlugins(value)
+	if err != nil {
+		return []string{}, err
+	}
+	return plugins, nil
+}
+
+func (a luaPlugins) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a luaPlugins) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, luaPluginsAnnotations.Annotations)
+}
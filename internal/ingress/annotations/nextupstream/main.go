@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nextupstream
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	nextUpstreamStatusCodesAnnotation = "proxy-next-upstream-status-codes"
+	nextUpstreamHeaderAnnotation      = "proxy-next-upstream-header"
+)
+
+// DefaultHeaderStatusCodes are the status codes of the responses retried
+// when they carry the header, if no status codes are configured
+var DefaultHeaderStatusCodes = []int{429, 503}
+
+var (
+	// error_page only accepts status codes between 300 and 599
+	statusCodesRegex = regexp.MustCompile(`^[345]\d{2}(\s*,\s*[345]\d{2})*$`)
+	headerRegex      = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+var nextUpstreamAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		nextUpstreamStatusCodesAnnotation: {
+			Validator: parser.ValidateRegex(statusCodesRegex, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines a comma separated list of status codes, between 300 and 599, ` +
+				`of the responses retried with another endpoint, like 409,425`,
+		},
+		nextUpstreamHeaderAnnotation: {
+			Validator: parser.ValidateRegex(headerRegex, false),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines a response header, like X-Backend-Overloaded, the backend sends to have the request retried with another endpoint. ` +
+				`Only the responses with one of the proxy-next-upstream-status-codes, 429 and 503 by default, are retried`,
+		},
+	},
+}
+
+// Config contains the responses of the backend retried with another endpoint
+type Config struct {
+	StatusCodes []int  `json:"statusCodes,omitempty"`
+	Header      string `json:"header,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Header != c2.Header || len(c1.StatusCodes) != len(c2.StatusCodes) {
+		return false
+	}
+	for i := range c1.StatusCodes {
+		if c1.StatusCodes[i] != c2.StatusCodes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Enabled returns true when responses of the backend are retried
+func (c *Config) Enabled() bool {
+	return len(c.StatusCodes) > 0
+}
+
+type nextUpstream struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new next upstream response annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return nextUpstream{
+		r:                r,
+		annotationConfig: nextUpstreamAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to retry responses of the backend with another endpoint
+func (a nextUpstream) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	codes, err := parser.GetStringAnnotation(nextUpstreamStatusCodesAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if codes != "" {
+		config.StatusCodes, err = parseStatusCodes(codes)
+		if err != nil {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(nextUpstreamStatusCodesAnnotation, codes)
+		}
+	}
+
+	config.Header, err = parser.GetStringAnnotation(nextUpstreamHeaderAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	config.Header = strings.TrimSpace(config.Header)
+
+	if config.Header != "" && len(config.StatusCodes) == 0 {
+		config.StatusCodes = append([]int{}, DefaultHeaderStatusCodes...)
+	}
+
+	return config, nil
+}
+
+// parseStatusCodes returns the sorted unique status codes of a comma separated list
+func parseStatusCodes(value string) ([]int, error) {
+	seen := map[int]bool{}
+	codes := []int{}
+	for _, v := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	return codes, nil
+}
+
+func (a nextUpstream) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a nextUpstream) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, nextUpstreamAnnotations.Annotations)
+}
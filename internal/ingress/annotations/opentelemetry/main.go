@@ -17,7 +17,10 @@ limitations under the License.
 package opentelemetry
 
 import (
+	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
 
@@ -30,9 +33,18 @@ const (
 	enableOpenTelemetryAnnotation = "enable-opentelemetry"
 	otelTrustSpanAnnotation       = "opentelemetry-trust-incoming-span"
 	otelOperationNameAnnotation   = "opentelemetry-operation-name"
+	otelAttributesAnnotation      = "opentelemetry-attributes"
 )
 
-var regexOperationName = regexp.MustCompile(`^[A-Za-z0-9_\-]*$`)
+var (
+	regexOperationName = regexp.MustCompile(`^[A-Za-z0-9_\-]*$`)
+	// regexAttributes matches a comma separated list of key=value pairs
+	regexAttributes = regexp.MustCompile(`^\s*[A-Za-z0-9_.\-]+=[A-Za-z0-9_.\-/:$]*(\s*,\s*[A-Za-z0-9_.\-]+=[A-Za-z0-9_.\-/:$]*)*\s*$`)
+	regexVariable   = regexp.MustCompile(`\$[A-Za-z0-9_]*`)
+)
+
+// AttributeVariables contains the NGINX variables allowed in the values of the span attributes
+var AttributeVariables = []string{"$host", "$namespace", "$ingress_name", "$service_name", "$service_port", "$location_path"}
 
 var otelAnnotations = parser.Annotation{
 	Group: "opentelemetry",
@@ -56,6 +68,13 @@ var otelAnnotations = parser.Annotation{
 			Risk:          parser.AnnotationRiskMedium,
 			Documentation: `This annotation defines what operation name should be added to the span`,
 		},
+		otelAttributesAnnotation: {
+			Validator: parser.ValidateRegex(regexAttributes, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines a comma separated list of key=value attributes added to the server spans, like team=payments,tier=$service_name. ` +
+				`The values can only contain the variables $host, $namespace, $ingress_name, $service_name, $service_port and $location_path`,
+		},
 	},
 }
 
@@ -71,6 +90,14 @@ type Config struct {
 	TrustEnabled  bool   `json:"trust-enabled"`
 	TrustSet      bool   `json:"trust-set"`
 	OperationName string `json:"operation-name"`
+	// Attributes are added to the server spans
+	Attributes []Attribute `json:"attributes,omitempty"`
+}
+
+// Attribute is an attribute of the server spans
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
 // Equal tests for equality between two Config types
@@ -95,6 +122,15 @@ func (bd1 *Config) Equal(bd2 *Config) bool {
 		return false
 	}
 
+	if len(bd1.Attributes) != len(bd2.Attributes) {
+		return false
+	}
+	for i := range bd1.Attributes {
+		if bd1.Attributes[i] != bd2.Attributes[i] {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -109,6 +145,14 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // Parse parses the annotations to look for opentelemetry configurations
 func (c opentelemetry) Parse(ing *networking.Ingress) (interface{}, error) {
 	cfg := Config{}
+
+	// the attributes are also added when OpenTelemetry is enabled in the ConfigMap
+	attributes, err := c.parseAttributes(ing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Attributes = attributes
+
 	enabled, err := parser.GetBoolAnnotation(enableOpenTelemetryAnnotation, ing, c.annotationConfig.Annotations)
 	if err != nil {
 		return &cfg, nil
@@ -145,6 +189,44 @@ func (c opentelemetry) Parse(ing *networking.Ingress) (interface{}, error) {
 	return &cfg, nil
 }
 
+// parseAttributes returns the span attributes of the annotation in the
+// order they are defined
+func (c opentelemetry) parseAttributes(ing *networking.Ingress) ([]Attribute, error) {
+	value, err := parser.GetStringAnnotation(otelAttributesAnnotation, ing, c.annotationConfig.Annotations)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	// validators can be disabled
+	if !regexAttributes.MatchString(value) {
+		return nil, errors.ValidationError{Reason: fmt.Errorf("%v is not a list of key=value attributes", value)}
+	}
+
+	attributes := []Attribute{}
+	keys := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if keys[key] {
+			return nil, errors.ValidationError{Reason: fmt.Errorf("attribute %v is defined more than once", key)}
+		}
+		keys[key] = true
+
+		for _, variable := range regexVariable.FindAllString(val, -1) {
+			if !slices.Contains(AttributeVariables, variable) {
+				return nil, errors.ValidationError{Reason: fmt.Errorf("variable %v of attribute %v is not allowed, valid variables are %v",
+					variable, key, strings.Join(AttributeVariables, ", "))}
+			}
+		}
+
+		attributes = append(attributes, Attribute{Key: key, Value: val})
+	}
+
+	return attributes, nil
+}
+
 func (c opentelemetry) GetDocumentation() parser.AnnotationFields {
 	return c.annotationConfig.Annotations
 }
@@ -197,3 +197,50 @@ func TestIngressAnnotationOpentelemetryUnset(t *testing.T) {
 		t.Errorf("expected a Config type")
 	}
 }
+
+func TestIngressAnnotationOpentelemetryAttributes(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  []Attribute
+		expectErr bool
+	}{
+		{"team=payments", []Attribute{{Key: "team", Value: "payments"}}, false},
+		{
+			"team=payments, tier=$service_name,version=v1.2",
+			[]Attribute{{Key: "team", Value: "payments"}, {Key: "tier", Value: "$service_name"}, {Key: "version", Value: "v1.2"}},
+			false,
+		},
+		{"route=$namespace/$ingress_name", []Attribute{{Key: "route", Value: "$namespace/$ingress_name"}}, false},
+		{"empty=", []Attribute{{Key: "empty", Value: ""}}, false},
+		{"team", nil, true},
+		{"team=payments,team=orders", nil, true},
+		{"user=$remote_user", nil, true},
+		{"host=$hostname", nil, true},
+		{"team=pay ments", nil, true},
+		{`team=payments";`, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix(otelAttributesAnnotation): testCase.value,
+		})
+
+		val, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for %q", testCase.expectErr, err, testCase.value)
+		}
+		if testCase.expectErr {
+			continue
+		}
+
+		openTelemetry, ok := val.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		expected := &Config{Attributes: testCase.expected}
+		if !openTelemetry.Equal(expected) {
+			t.Errorf("expected %+v but got %+v for %q", testCase.expected, openTelemetry.Attributes, testCase.value)
+		}
+	}
+}
@@ -66,6 +66,10 @@ type AnnotationFields map[string]AnnotationConfig
 type AnnotationConfig struct {
 	// Validator defines a function to validate the annotation value
 	Validator AnnotationValidator
+	// StrictValidator defines a function validating the annotation value, in
+	// addition to Validator, when the strict annotation validation is enabled.
+	// It rejects the values the parser replaces with a default
+	StrictValidator AnnotationValidator
 	// Documentation defines a user facing documentation for this annotation. This
 	// field will be used to auto generate documentations
 	Documentation string
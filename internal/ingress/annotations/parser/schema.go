@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnnotationSchema contains the configuration of every annotation known by
+// the controller, indexed by the name without prefix. The aliases of an
+// annotation have the configuration of the annotation.
+type AnnotationSchema map[string]AnnotationConfig
+
+// NewAnnotationSchema returns the schema of the annotations of the features
+func NewAnnotationSchema(features ...AnnotationFields) AnnotationSchema {
+	schema := AnnotationSchema{}
+	for _, fields := range features {
+		for name, config := range fields {
+			schema[name] = config
+			for _, alias := range config.AnnotationAliases {
+				schema[alias] = config
+			}
+		}
+	}
+	return schema
+}
+
+// Validate checks the annotations with the prefix of the controller are
+// known and their values are valid for the validators of the annotation,
+// whether the annotation validation is enabled or not. It returns an error
+// per invalid annotation, sorted by annotation name.
+func (s AnnotationSchema) Validate(annotations map[string]string) []error {
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, fullName := range names {
+		name, found := strings.CutPrefix(fullName, AnnotationsPrefix+"/")
+		if !found {
+			continue
+		}
+
+		config, ok := s[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown annotation %s", fullName))
+			continue
+		}
+
+		value := annotations[fullName]
+		if value == "" {
+			continue
+		}
+
+		for _, validate := range []AnnotationValidator{config.Validator, config.StrictValidator} {
+			if validate == nil {
+				continue
+			}
+			if err := validate(value); err != nil {
+				errs = append(errs, fmt.Errorf("annotation %s contains invalid value %q: %w", fullName, value, err))
+				break
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"testing"
+)
+
+func TestAnnotationSchemaValidate(t *testing.T) {
+	schema := NewAnnotationSchema(AnnotationFields{
+		"enable-feature": {Validator: ValidateBool, AnnotationAliases: []string{"feature-enabled"}},
+		"feature-code":   {Validator: ValidateInt, StrictValidator: ValidateIntRange(300, 399)},
+	})
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+	}{
+		{"valid", map[string]string{
+			GetAnnotationWithPrefix("enable-feature"): "true",
+			GetAnnotationWithPrefix("feature-code"):   "302",
+			"example.com/other":                       "value",
+		}, 0},
+		{"alias", map[string]string{GetAnnotationWithPrefix("feature-enabled"): "false"}, 0},
+		{"empty value", map[string]string{GetAnnotationWithPrefix("feature-code"): ""}, 0},
+		{"unknown", map[string]string{GetAnnotationWithPrefix("enable-featur"): "true"}, 1},
+		{"invalid values", map[string]string{
+			GetAnnotationWithPrefix("enable-feature"): "yes please",
+			GetAnnotationWithPrefix("feature-code"):   "200",
+		}, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := schema.Validate(tc.annotations); len(errs) != tc.expected {
+				t.Errorf("expected %v errors but returned %v", tc.expected, errs)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -162,6 +163,45 @@ func ValidateInt(value string) error {
 	return err
 }
 
+// ValidateIntRange returns a validator checking the value is an integer
+// between minimum and maximum, inclusive
+func ValidateIntRange(minimum, maximum int) AnnotationValidator {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if i < minimum || i > maximum {
+			return fmt.Errorf("value %d is not between %d and %d", i, minimum, maximum)
+		}
+		return nil
+	}
+}
+
+// ValidateSize validates if the specified value is a size understood by NGINX
+func ValidateSize(value string) error {
+	if !SizeRegex.MatchString(value) {
+		return fmt.Errorf("value %s is not a valid size", value)
+	}
+	return nil
+}
+
+// ValidateHTTPURL validates if the specified value is an absolute http or
+// https URL
+func ValidateHTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("value %s is not an http or https URL", value)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("value %s does not contain a host", value)
+	}
+	return nil
+}
+
 // ValidateCIDRs validates if the specified value is an array of IPs and CIDRs
 func ValidateCIDRs(value string) error {
 	_, err := net.ParseCIDRs(value)
@@ -174,6 +214,12 @@ func ValidateDuration(value string) error {
 	return err
 }
 
+// ValidateTime validates if the specified value is a RFC3339 time
+func ValidateTime(value string) error {
+	_, err := time.Parse(time.RFC3339, value)
+	return err
+}
+
 // ValidateNull always return null values and should not be widely used.
 // It is used on the "snippet" annotations, as it is up to the admin to allow its
 // usage, knowing it can be critical!
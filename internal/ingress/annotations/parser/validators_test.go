@@ -368,3 +368,29 @@ func TestCommonNameAnnotationValidator(t *testing.T) {
 		})
 	}
 }
+
+func TestTypedValidators(t *testing.T) {
+	testCases := []struct {
+		name      string
+		validator AnnotationValidator
+		value     string
+		wantErr   bool
+	}{
+		{"int in range", ValidateIntRange(300, 308), "301", false},
+		{"int out of range", ValidateIntRange(300, 308), "200", true},
+		{"not an int", ValidateIntRange(300, 308), "3xx", true},
+		{"size", ValidateSize, "8m", false},
+		{"invalid size", ValidateSize, "8 megabytes", true},
+		{"http url", ValidateHTTPURL, "https://example.com/path?x=1", false},
+		{"url without host", ValidateHTTPURL, "https:/path", true},
+		{"url with other scheme", ValidateHTTPURL, "ftp://example.com", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.validator(tc.value); (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v but returned %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pathtemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	pathTemplateAnnotation             = "path-template"
+	pathTemplateHeaderPrefixAnnotation = "path-template-header-prefix"
+)
+
+// DefaultHeaderPrefix is the prefix of the headers sending the parameters
+// captured by a path template to the upstream
+const DefaultHeaderPrefix = "X-Path-Param-"
+
+// VariablePrefix is the prefix of the NGINX variables containing the
+// parameters captured by a path template
+const VariablePrefix = "path_param_"
+
+var (
+	validHeaderPrefix = regexp.MustCompile(`^[A-Za-z0-9-]*$`)
+	validName         = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// constraints are the named types a parameter can be restricted to
+var constraints = map[string]string{
+	"int":   `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"slug":  `[a-zA-Z0-9]+(?:-[a-zA-Z0-9]+)*`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// defaultConstraint matches a single path segment
+const defaultConstraint = `[^/]+`
+
+var pathTemplateAnnotations = parser.Annotation{
+	Group: "rewrite",
+	Annotations: parser.AnnotationFields{
+		pathTemplateAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines if the paths of the Ingress are templates like /users/{id:int}/orders, ` +
+				`compiled to regular expressions capturing the parameters sent to the upstream as headers. ` +
+				`The pathType of the paths should be 'ImplementationSpecific'.`,
+		},
+		pathTemplateHeaderPrefixAnnotation: {
+			Validator:     parser.ValidateRegex(validHeaderPrefix, true),
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the prefix of the headers containing the path template parameters. Defaults to X-Path-Param-`,
+		},
+	},
+}
+
+// Config describes if the paths of an Ingress are path templates
+type Config struct {
+	// Enabled indicates if the paths are path templates
+	Enabled bool `json:"enabled"`
+	// HeaderPrefix is the prefix of the headers sending the parameters to the upstream
+	HeaderPrefix string `json:"headerPrefix,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+// Compile returns the regular expression matching the path template and
+// the names of its parameters, captured in groups named after VariablePrefix
+// and the name of the parameter
+func Compile(template string, pathType *networking.PathType) (string, []string, error) {
+	if strings.ContainsAny(template, "\"; \t\r\n\\") {
+		return "", nil, fmt.Errorf("path templates cannot contain quotes, semicolons, backslashes or whitespace")
+	}
+
+	var (
+		regex  strings.Builder
+		params []string
+	)
+
+	for rest := template; rest != ""; {
+		start := strings.IndexAny(rest, "{}")
+		if start == -1 {
+			regex.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		if rest[start] == '}' {
+			return "", nil, fmt.Errorf("unexpected } at %q", rest[start:])
+		}
+		regex.WriteString(regexp.QuoteMeta(rest[:start]))
+
+		end := closingBrace(rest, start)
+		if end == -1 {
+			return "", nil, fmt.Errorf("parameter %q is not closed", rest[start:])
+		}
+
+		name, constraint, err := parseParameter(rest[start+1 : end])
+		if err != nil {
+			return "", nil, err
+		}
+		for _, param := range params {
+			if param == name {
+				return "", nil, fmt.Errorf("parameter %q is defined more than once", name)
+			}
+		}
+		params = append(params, name)
+
+		fmt.Fprintf(&regex, "(?<%s%s>%s)", VariablePrefix, name, constraint)
+		rest = rest[end+1:]
+	}
+
+	switch {
+	case pathType != nil && *pathType == networking.PathTypeExact:
+		regex.WriteString("$")
+	case !strings.HasSuffix(template, "/"):
+		// a parameter must not match only part of the last path segment
+		regex.WriteString("(?:/|$)")
+	}
+
+	return regex.String(), params, nil
+}
+
+// closingBrace returns the index of the brace closing the one at start,
+// skipping the braces of the quantifiers of the constraint
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseParameter returns the name and the regular expression of a
+// parameter defined as name or name:constraint
+func parseParameter(param string) (string, string, error) {
+	name, constraint, found := strings.Cut(param, ":")
+	if !validName.MatchString(name) {
+		return "", "", fmt.Errorf("parameter name %q must contain only letters, digits and underscores", name)
+	}
+	if !found {
+		return name, defaultConstraint, nil
+	}
+
+	if regex, ok := constraints[constraint]; ok {
+		return name, regex, nil
+	}
+
+	re, err := regexp.Compile(constraint)
+	if err != nil || constraint == "" {
+		return "", "", fmt.Errorf("constraint %q of parameter %q is not a valid regular expression", constraint, name)
+	}
+	if re.NumSubexp() > 0 {
+		return "", "", fmt.Errorf("constraint %q of parameter %q must use non-capturing groups (?:...)", constraint, name)
+	}
+
+	return name, constraint, nil
+}
+
+// HeaderName returns the name of the header sending a parameter to the upstream
+func HeaderName(prefix, param string) string {
+	return prefix + strings.ReplaceAll(param, "_", "-")
+}
+
+type pathTemplate struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new path template annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return pathTemplate{
+		r:                r,
+		annotationConfig: pathTemplateAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to compile the paths of the Ingress from path templates
+func (a pathTemplate) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation(pathTemplateAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+	if !enabled {
+		return &Config{}, nil
+	}
+
+	prefix, err := parser.GetStringAnnotation(pathTemplateHeaderPrefixAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if !ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, err
+		}
+		prefix = DefaultHeaderPrefix
+	}
+	if !validHeaderPrefix.MatchString(prefix) {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(pathTemplateHeaderPrefixAnnotation, prefix)
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if _, _, err := Compile(path.Path, path.PathType); err != nil {
+				return &Config{}, ing_errors.ValidationError{
+					Reason: fmt.Errorf("path %q is not a valid path template: %w", path.Path, err),
+				}
+			}
+		}
+	}
+
+	return &Config{
+		Enabled:      true,
+		HeaderPrefix: prefix,
+	}, nil
+}
+
+func (a pathTemplate) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a pathTemplate) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, pathTemplateAnnotations.Annotations)
+}
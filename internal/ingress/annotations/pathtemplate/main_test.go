@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pathtemplate
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestCompile(t *testing.T) {
+	exact := networking.PathTypeExact
+	implSpecific := networking.PathTypeImplementationSpecific
+
+	testCases := []struct {
+		template string
+		pathType *networking.PathType
+		regex    string
+		params   []string
+	}{
+		{"/", &implSpecific, `/`, nil},
+		{"/api/v1.0", &implSpecific, `/api/v1\.0(?:/|$)`, nil},
+		{"/users/{id}", &implSpecific, `/users/(?<path_param_id>[^/]+)(?:/|$)`, []string{"id"}},
+		{"/users/{id:int}/orders/", &implSpecific, `/users/(?<path_param_id>[0-9]+)/orders/`, []string{"id"}},
+		{"/users/{id:int}/orders", &exact, `/users/(?<path_param_id>[0-9]+)/orders$`, []string{"id"}},
+		{"/{org:slug}/{repo_id:uuid}", nil,
+			`/(?<path_param_org>[a-zA-Z0-9]+(?:-[a-zA-Z0-9]+)*)/(?<path_param_repo_id>[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})(?:/|$)`,
+			[]string{"org", "repo_id"}},
+		{"/v{version:[0-9]{1,2}}/{kind:(?:a|b)}", &implSpecific,
+			`/v(?<path_param_version>[0-9]{1,2})/(?<path_param_kind>(?:a|b))(?:/|$)`, []string{"version", "kind"}},
+	}
+
+	for _, testCase := range testCases {
+		regex, params, err := Compile(testCase.template, testCase.pathType)
+		if err != nil {
+			t.Errorf("unexpected error compiling %q: %v", testCase.template, err)
+			continue
+		}
+		if regex != testCase.regex {
+			t.Errorf("expected %q but returned %q for %q", testCase.regex, regex, testCase.template)
+		}
+		if !reflect.DeepEqual(params, testCase.params) {
+			t.Errorf("expected parameters %v but returned %v for %q", testCase.params, params, testCase.template)
+		}
+	}
+
+	for _, template := range []string{
+		"/users/{id",
+		"/users/id}",
+		"/users/{}",
+		"/users/{1d}",
+		"/users/{user-id}",
+		"/users/{id}/{id}",
+		"/users/{id:(a|b)}",
+		"/users/{id:[0-9}",
+		"/users/{id:}",
+		`/users/{id:\d+}`,
+		`/users/{id:"}`,
+		"/users/{id:a;b}",
+		"/users /{id}",
+	} {
+		if _, _, err := Compile(template, &implSpecific); err == nil {
+			t.Errorf("expected an error compiling %q", template)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	enabled := parser.GetAnnotationWithPrefix(pathTemplateAnnotation)
+	prefix := parser.GetAnnotationWithPrefix(pathTemplateHeaderPrefixAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	implSpecific := networking.PathTypeImplementationSpecific
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{
+				IngressRuleValue: networking.IngressRuleValue{
+					HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{Path: "/users/{id:int}", PathType: &implSpecific}},
+					},
+				},
+			}},
+		},
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{enabled: "false"}, Config{}, false},
+		{map[string]string{enabled: "true"}, Config{Enabled: true, HeaderPrefix: DefaultHeaderPrefix}, false},
+		{map[string]string{enabled: "true", prefix: "X-Route-"}, Config{Enabled: true, HeaderPrefix: "X-Route-"}, false},
+		{map[string]string{enabled: "true", prefix: "X Route"}, Config{}, true},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+
+	ing.SetAnnotations(map[string]string{enabled: "true"})
+	ing.Spec.Rules[0].HTTP.Paths[0].Path = "/users/{id:int"
+	if _, err := ap.Parse(ing); !ing_errors.IsValidationError(err) {
+		t.Errorf("expected a validation error for an invalid path template but returned %v", err)
+	}
+}
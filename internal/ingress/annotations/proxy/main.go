@@ -17,11 +17,17 @@ limitations under the License.
 package proxy
 
 import (
+	"fmt"
+	"math"
 	"regexp"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -43,62 +49,86 @@ const (
 	proxyBufferingAnnotation           = "proxy-buffering"
 	proxyHTTPVersionAnnotation         = "proxy-http-version"
 	proxyMaxTempFileSizeAnnotation     = "proxy-max-temp-file-size" //#nosec G101
+	chunkedTransferEncodingAnnotation  = "chunked-transfer-encoding"
 )
 
 var validUpstreamAnnotation = regexp.MustCompile(`^((error|timeout|invalid_header|http_500|http_502|http_503|http_504|http_403|http_404|http_429|non_idempotent|off)\s?)+$`)
 
+// maxCookieRewrites is the maximum number of mappings of the
+// proxy-cookie-path and proxy-cookie-domain annotations
+const maxCookieRewrites = 10
+
+var (
+	cookiePathRegexp   = regexp.MustCompile(`^/[A-Za-z0-9._~/%-]*$`)
+	cookieDomainRegexp = regexp.MustCompile(`^\.?[A-Za-z0-9.-]+$`)
+	// the replacements may refer to the captures of a regular expression
+	cookiePathCaptureRegexp   = regexp.MustCompile(`^/([A-Za-z0-9._~/%-]|\$[1-9])*$`)
+	cookieDomainCaptureRegexp = regexp.MustCompile(`^\.?([A-Za-z0-9.-]|\$[1-9])+$`)
+	// cookieRegexUnsafeRegexp matches what would break out of the directive
+	// or be interpolated as an NGINX variable
+	cookieRegexUnsafeRegexp = regexp.MustCompile(`[\s;{}"'#]|\$[A-Za-z_{]`)
+)
+
 var proxyAnnotations = parser.Annotation{
 	Group: "backend",
 	Annotations: parser.AnnotationFields{
 		proxyConnectTimeoutAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation allows setting the timeout in seconds of the connect operation to the backend.`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation allows setting the timeout in seconds of the connect operation to the backend.`,
 		},
 		proxySendTimeoutAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation allows setting the timeout in seconds of the send operation to the backend.`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation allows setting the timeout in seconds of the send operation to the backend.`,
 		},
 		proxyReadTimeoutAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation allows setting the timeout in seconds of the read operation to the backend.`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation allows setting the timeout in seconds of the read operation to the backend.`,
 		},
 		proxyBuffersNumberAnnotation: {
-			Validator: parser.ValidateInt,
-			Scope:     parser.AnnotationScopeLocation,
-			Risk:      parser.AnnotationRiskLow,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(1, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
 			Documentation: `This annotation sets the number of the buffers in proxy_buffers used for reading the first part of the response received from the proxied server. 
 			By default proxy buffers number is set as 4`,
 		},
 		proxyBufferSizeAnnotation: {
-			Validator: parser.ValidateRegex(parser.SizeRegex, true),
-			Scope:     parser.AnnotationScopeLocation,
-			Risk:      parser.AnnotationRiskLow,
+			Validator:       parser.ValidateRegex(parser.SizeRegex, true),
+			StrictValidator: parser.ValidateSize,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
 			Documentation: `This annotation sets the size of the buffer proxy_buffer_size used for reading the first part of the response received from the proxied server. 
 			By default proxy buffer size is set as "4k".`,
 		},
 		proxyCookiePathAnnotation: {
-			Validator:     parser.ValidateRegex(parser.URLIsValidRegex, true),
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskMedium,
-			Documentation: `This annotation sets a text that should be changed in the path attribute of the "Set-Cookie" header fields of a proxied server response.`,
+			Validator: validateCookiePaths,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation sets the texts that should be changed in the path attribute of the "Set-Cookie" header fields of a proxied server response. ` +
+				`It accepts up to 10 "from to" mappings separated by newlines or commas, a regular expression starting with ~ or ~* as "from", or "off"`,
 		},
 		proxyCookieDomainAnnotation: {
-			Validator:     parser.ValidateRegex(parser.BasicCharsRegex, true),
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskMedium,
-			Documentation: `This annotation ets a text that should be changed in the domain attribute of the "Set-Cookie" header fields of a proxied server response.`,
+			Validator: validateCookieDomains,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation sets the texts that should be changed in the domain attribute of the "Set-Cookie" header fields of a proxied server response. ` +
+				`It accepts up to 10 "from to" mappings separated by newlines or commas, a regular expression starting with ~ or ~* as "from", or "off"`,
 		},
 		proxyBodySizeAnnotation: {
-			Validator:     parser.ValidateRegex(parser.SizeRegex, true),
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskMedium,
-			Documentation: `This annotation allows setting the maximum allowed size of a client request body.`,
+			Validator:       parser.ValidateRegex(parser.SizeRegex, true),
+			StrictValidator: parser.ValidateSize,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskMedium,
+			Documentation:   `This annotation allows setting the maximum allowed size of a client request body.`,
 		},
 		proxyNextUpstreamAnnotation: {
 			Validator: parser.ValidateRegex(validUpstreamAnnotation, false),
@@ -109,16 +139,18 @@ var proxyAnnotations = parser.Annotation{
 			and only the allowed values on upstream are allowed here.`,
 		},
 		proxyNextUpstreamTimeoutAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation limits the time during which a request can be passed to the next server`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(0, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation limits the time during which a request can be passed to the next server`,
 		},
 		proxyNextUpstreamTriesAnnotation: {
-			Validator:     parser.ValidateInt,
-			Scope:         parser.AnnotationScopeLocation,
-			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation limits the number of possible tries for passing a request to the next server`,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(0, math.MaxInt32),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation limits the number of possible tries for passing a request to the next server`,
 		},
 		proxyRequestBufferingAnnotation: {
 			Validator:     parser.ValidateOptions([]string{"on", "off"}, true, true),
@@ -151,33 +183,41 @@ var proxyAnnotations = parser.Annotation{
 			Documentation: `This annotations sets the HTTP protocol version for proxying. Can be "1.0" or "1.1".`,
 		},
 		proxyMaxTempFileSizeAnnotation: {
-			Validator:     parser.ValidateRegex(parser.SizeRegex, true),
+			Validator:       parser.ValidateRegex(parser.SizeRegex, true),
+			StrictValidator: parser.ValidateSize,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow,
+			Documentation:   `This annotation defines the maximum size of a temporary file when buffering responses.`,
+		},
+		chunkedTransferEncodingAnnotation: {
+			Validator:     parser.ValidateOptions([]string{"on", "off"}, true, true),
 			Scope:         parser.AnnotationScopeLocation,
 			Risk:          parser.AnnotationRiskLow,
-			Documentation: `This annotation defines the maximum size of a temporary file when buffering responses.`,
+			Documentation: `This annotation enables or disables chunked transfer encoding in the requests sent to the upstream. When "off", the request bodies are buffered and sent with a Content-Length header. It can be "on" or "off"`,
 		},
 	},
 }
 
 // Config returns the proxy timeout to use in the upstream server/s
 type Config struct {
-	BodySize             string `json:"bodySize"`
-	ConnectTimeout       int    `json:"connectTimeout"`
-	SendTimeout          int    `json:"sendTimeout"`
-	ReadTimeout          int    `json:"readTimeout"`
-	BuffersNumber        int    `json:"buffersNumber"`
-	BufferSize           string `json:"bufferSize"`
-	CookieDomain         string `json:"cookieDomain"`
-	CookiePath           string `json:"cookiePath"`
-	NextUpstream         string `json:"nextUpstream"`
-	NextUpstreamTimeout  int    `json:"nextUpstreamTimeout"`
-	NextUpstreamTries    int    `json:"nextUpstreamTries"`
-	ProxyRedirectFrom    string `json:"proxyRedirectFrom"`
-	ProxyRedirectTo      string `json:"proxyRedirectTo"`
-	RequestBuffering     string `json:"requestBuffering"`
-	ProxyBuffering       string `json:"proxyBuffering"`
-	ProxyHTTPVersion     string `json:"proxyHTTPVersion"`
-	ProxyMaxTempFileSize string `json:"proxyMaxTempFileSize"`
+	BodySize                string          `json:"bodySize"`
+	ConnectTimeout          int             `json:"connectTimeout"`
+	SendTimeout             int             `json:"sendTimeout"`
+	ReadTimeout             int             `json:"readTimeout"`
+	BuffersNumber           int             `json:"buffersNumber"`
+	BufferSize              string          `json:"bufferSize"`
+	CookieDomain            []CookieRewrite `json:"cookieDomain,omitempty"`
+	CookiePath              []CookieRewrite `json:"cookiePath,omitempty"`
+	NextUpstream            string          `json:"nextUpstream"`
+	NextUpstreamTimeout     int             `json:"nextUpstreamTimeout"`
+	NextUpstreamTries       int             `json:"nextUpstreamTries"`
+	ProxyRedirectFrom       string          `json:"proxyRedirectFrom"`
+	ProxyRedirectTo         string          `json:"proxyRedirectTo"`
+	RequestBuffering        string          `json:"requestBuffering"`
+	ProxyBuffering          string          `json:"proxyBuffering"`
+	ProxyHTTPVersion        string          `json:"proxyHTTPVersion"`
+	ProxyMaxTempFileSize    string          `json:"proxyMaxTempFileSize"`
+	ChunkedTransferEncoding string          `json:"chunkedTransferEncoding"`
 }
 
 // Equal tests for equality between two Configuration types
@@ -206,10 +246,10 @@ func (l1 *Config) Equal(l2 *Config) bool {
 	if l1.BufferSize != l2.BufferSize {
 		return false
 	}
-	if l1.CookieDomain != l2.CookieDomain {
+	if !cookieRewritesEqual(l1.CookieDomain, l2.CookieDomain) {
 		return false
 	}
-	if l1.CookiePath != l2.CookiePath {
+	if !cookieRewritesEqual(l1.CookiePath, l2.CookiePath) {
 		return false
 	}
 	if l1.NextUpstream != l2.NextUpstream {
@@ -240,10 +280,116 @@ func (l1 *Config) Equal(l2 *Config) bool {
 	if l1.ProxyMaxTempFileSize != l2.ProxyMaxTempFileSize {
 		return false
 	}
+	if l1.ChunkedTransferEncoding != l2.ChunkedTransferEncoding {
+		return false
+	}
+
+	return true
+}
+
+// CookieRewrite is a mapping of the proxy_cookie_path or proxy_cookie_domain
+// directives
+type CookieRewrite struct {
+	// From is the text or, starting with ~ or ~*, the regular expression
+	// to look for in the attribute of the cookie
+	From string `json:"from"`
+	// To is the replacement, it can refer to the captures of From
+	To string `json:"to"`
+}
 
+func cookieRewritesEqual(c1, c2 []CookieRewrite) bool {
+	if len(c1) != len(c2) {
+		return false
+	}
+	for i := range c1 {
+		if c1[i] != c2[i] {
+			return false
+		}
+	}
 	return true
 }
 
+// ParseCookiePaths parses the mappings of the path attribute of the cookies,
+// "off" or an empty value returns no mappings
+func ParseCookiePaths(value string) ([]CookieRewrite, error) {
+	return parseCookieRewrites(value, cookiePathRegexp, cookiePathCaptureRegexp)
+}
+
+// ParseCookieDomains parses the mappings of the domain attribute of the
+// cookies, "off" or an empty value returns no mappings
+func ParseCookieDomains(value string) ([]CookieRewrite, error) {
+	return parseCookieRewrites(value, cookieDomainRegexp, cookieDomainCaptureRegexp)
+}
+
+func parseCookieRewrites(value string, literal, capture *regexp.Regexp) ([]CookieRewrite, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" {
+		return nil, nil
+	}
+
+	var rewrites []CookieRewrite
+	for _, mapping := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		fields := strings.Fields(mapping)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid mapping %q, expected \"from to\"", strings.TrimSpace(mapping))
+		}
+
+		from, to := fields[0], fields[1]
+		isRegex := strings.HasPrefix(from, "~")
+		if isRegex {
+			expr := strings.TrimPrefix(strings.TrimPrefix(from, "~"), "*")
+			if expr == "" || cookieRegexUnsafeRegexp.MatchString(expr) {
+				return nil, fmt.Errorf("invalid regular expression %q", from)
+			}
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", from, err)
+			}
+		} else if !literal.MatchString(from) {
+			return nil, fmt.Errorf("invalid value %q", from)
+		}
+
+		if !literal.MatchString(to) && (!isRegex || !capture.MatchString(to)) {
+			return nil, fmt.Errorf("invalid replacement %q", to)
+		}
+
+		rewrites = append(rewrites, CookieRewrite{From: from, To: to})
+	}
+
+	if len(rewrites) > maxCookieRewrites {
+		return nil, fmt.Errorf("too many mappings, the maximum is %d", maxCookieRewrites)
+	}
+
+	return rewrites, nil
+}
+
+func validateCookiePaths(value string) error {
+	_, err := ParseCookiePaths(value)
+	return err
+}
+
+func validateCookieDomains(value string) error {
+	_, err := ParseCookieDomains(value)
+	return err
+}
+
+// DefaultCookieRewrites returns the mappings of the path and domain
+// attributes of the cookies configured in the ConfigMap. An invalid value
+// is ignored.
+func DefaultCookieRewrites(defBackend defaults.Backend) (domains, paths []CookieRewrite) {
+	domains, err := ParseCookieDomains(defBackend.ProxyCookieDomain)
+	if err != nil {
+		klog.Warningf("Ignoring invalid proxy-cookie-domain %q: %v", defBackend.ProxyCookieDomain, err)
+	}
+	paths, err = ParseCookiePaths(defBackend.ProxyCookiePath)
+	if err != nil {
+		klog.Warningf("Ignoring invalid proxy-cookie-path %q: %v", defBackend.ProxyCookiePath, err)
+	}
+	return domains, paths
+}
+
 type proxy struct {
 	r                resolver.Resolver
 	annotationConfig parser.Annotation
@@ -290,14 +436,28 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.BufferSize = defBackend.ProxyBufferSize
 	}
 
-	config.CookiePath, err = parser.GetStringAnnotation(proxyCookiePathAnnotation, ing, a.annotationConfig.Annotations)
-	if err != nil {
-		config.CookiePath = defBackend.ProxyCookiePath
+	config.CookieDomain, config.CookiePath = DefaultCookieRewrites(defBackend)
+
+	cookiePath, err := parser.GetStringAnnotation(proxyCookiePathAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if err == nil {
+		config.CookiePath, err = ParseCookiePaths(cookiePath)
+		if err != nil {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(proxyCookiePathAnnotation, cookiePath)
+		}
 	}
 
-	config.CookieDomain, err = parser.GetStringAnnotation(proxyCookieDomainAnnotation, ing, a.annotationConfig.Annotations)
-	if err != nil {
-		config.CookieDomain = defBackend.ProxyCookieDomain
+	cookieDomain, err := parser.GetStringAnnotation(proxyCookieDomainAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if err == nil {
+		config.CookieDomain, err = ParseCookieDomains(cookieDomain)
+		if err != nil {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(proxyCookieDomainAnnotation, cookieDomain)
+		}
 	}
 
 	config.BodySize, err = parser.GetStringAnnotation(proxyBodySizeAnnotation, ing, a.annotationConfig.Annotations)
@@ -342,9 +502,25 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 
 	config.ProxyHTTPVersion, err = parser.GetStringAnnotation(proxyHTTPVersionAnnotation, ing, a.annotationConfig.Annotations)
 	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
 		config.ProxyHTTPVersion = defBackend.ProxyHTTPVersion
 	}
 
+	config.ChunkedTransferEncoding, err = parser.GetStringAnnotation(chunkedTransferEncodingAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsValidationError(err) {
+			return &Config{}, err
+		}
+		config.ChunkedTransferEncoding = defBackend.ChunkedTransferEncoding
+	}
+	// NGINX buffers the whole request body to send it to the upstream with
+	// a Content-Length header instead of chunked transfer encoding
+	if config.ChunkedTransferEncoding == "off" {
+		config.RequestBuffering = "on"
+	}
+
 	config.ProxyMaxTempFileSize, err = parser.GetStringAnnotation(proxyMaxTempFileSizeAnnotation, ing, a.annotationConfig.Annotations)
 	if err != nil {
 		config.ProxyMaxTempFileSize = defBackend.ProxyMaxTempFileSize
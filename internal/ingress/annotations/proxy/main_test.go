@@ -17,6 +17,7 @@ limitations under the License.
 package proxy
 
 import (
+	"strings"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -96,6 +97,7 @@ func (m mockBackend) GetDefaultBackend() defaults.Backend {
 		ProxyBuffering:           off,
 		ProxyHTTPVersion:         "1.1",
 		ProxyMaxTempFileSize:     "1024m",
+		ChunkedTransferEncoding:  "on",
 	}
 }
 
@@ -116,6 +118,7 @@ func TestProxy(t *testing.T) {
 	data[parser.GetAnnotationWithPrefix("proxy-buffering")] = "on"
 	data[parser.GetAnnotationWithPrefix("proxy-http-version")] = proxyHTTPVersion
 	data[parser.GetAnnotationWithPrefix("proxy-max-temp-file-size")] = proxyMaxTempFileSize
+	data[parser.GetAnnotationWithPrefix("chunked-transfer-encoding")] = "on"
 	ing.SetAnnotations(data)
 
 	i, err := NewParser(mockBackend{}).Parse(ing)
@@ -165,6 +168,9 @@ func TestProxy(t *testing.T) {
 	if p.ProxyMaxTempFileSize != proxyMaxTempFileSize {
 		t.Errorf("expected 128k as proxy-max-temp-file-size but returned %v", p.ProxyMaxTempFileSize)
 	}
+	if p.ChunkedTransferEncoding != "on" {
+		t.Errorf("expected on as chunked-transfer-encoding but returned %v", p.ChunkedTransferEncoding)
+	}
 }
 
 func TestProxyComplex(t *testing.T) {
@@ -285,4 +291,217 @@ func TestProxyWithNoAnnotation(t *testing.T) {
 	if p.ProxyMaxTempFileSize != "1024m" {
 		t.Errorf("expected 1024m as proxy-max-temp-file-size but returned %v", p.ProxyMaxTempFileSize)
 	}
+	if p.ChunkedTransferEncoding != "on" {
+		t.Errorf("expected on as chunked-transfer-encoding but returned %v", p.ChunkedTransferEncoding)
+	}
+}
+
+func TestProxyChunkedTransferEncodingOff(t *testing.T) {
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-request-buffering"):   off,
+		parser.GetAnnotationWithPrefix("chunked-transfer-encoding"): off,
+	})
+
+	i, err := NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing a valid annotation: %v", err)
+	}
+	p, ok := i.(*Config)
+	if !ok {
+		t.Fatalf("expected a Config type")
+	}
+	// the request bodies are buffered to be sent with a Content-Length header
+	if p.RequestBuffering != "on" {
+		t.Errorf("expected on as request-buffering but returned %v", p.RequestBuffering)
+	}
+}
+
+func TestProxyInvalidHTTPVersionAndChunked(t *testing.T) {
+	testCases := map[string]map[string]string{
+		"invalid proxy-http-version":        {parser.GetAnnotationWithPrefix("proxy-http-version"): "2.0"},
+		"invalid chunked-transfer-encoding": {parser.GetAnnotationWithPrefix("chunked-transfer-encoding"): "maybe"},
+	}
+
+	for name, annotations := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing := buildIngress()
+			ing.SetAnnotations(annotations)
+
+			if _, err := NewParser(mockBackend{}).Parse(ing); err == nil {
+				t.Errorf("expected an error parsing an invalid annotation")
+			}
+		})
+	}
+}
+
+func TestProxyCookieRewrites(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		domains     []CookieRewrite
+		paths       []CookieRewrite
+		expErr      bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name: "single mappings",
+			annotations: map[string]string{
+				proxyCookieDomainAnnotation: "localhost example.org",
+				proxyCookiePathAnnotation:   "/one/ /",
+			},
+			domains: []CookieRewrite{{From: "localhost", To: "example.org"}},
+			paths:   []CookieRewrite{{From: "/one/", To: "/"}},
+		},
+		{
+			name: "multiple mappings",
+			annotations: map[string]string{
+				proxyCookieDomainAnnotation: "internal.svc .example.org, ~\\.local$ example.org",
+				proxyCookiePathAnnotation:   "/ /app/\n~*^/api/(.*) /app/api/$1\n",
+			},
+			domains: []CookieRewrite{
+				{From: "internal.svc", To: ".example.org"},
+				{From: "~\\.local$", To: "example.org"},
+			},
+			paths: []CookieRewrite{
+				{From: "/", To: "/app/"},
+				{From: "~*^/api/(.*)", To: "/app/api/$1"},
+			},
+		},
+		{
+			name: "off",
+			annotations: map[string]string{
+				proxyCookieDomainAnnotation: off,
+				proxyCookiePathAnnotation:   off,
+			},
+		},
+		{
+			name:        "missing replacement",
+			annotations: map[string]string{proxyCookiePathAnnotation: "/one/"},
+			expErr:      true,
+		},
+		{
+			name:        "relative path",
+			annotations: map[string]string{proxyCookiePathAnnotation: "one /"},
+			expErr:      true,
+		},
+		{
+			name:        "capture of a literal path",
+			annotations: map[string]string{proxyCookiePathAnnotation: "/one/ /$1"},
+			expErr:      true,
+		},
+		{
+			name:        "nginx variable",
+			annotations: map[string]string{proxyCookiePathAnnotation: "~^/$host /"},
+			expErr:      true,
+		},
+		{
+			name:        "directive injection",
+			annotations: map[string]string{proxyCookieDomainAnnotation: "localhost example.org;return"},
+			expErr:      true,
+		},
+		{
+			name:        "invalid regular expression",
+			annotations: map[string]string{proxyCookieDomainAnnotation: "~(local example.org"},
+			expErr:      true,
+		},
+		{
+			name: "too many mappings",
+			annotations: map[string]string{
+				proxyCookiePathAnnotation: strings.Repeat("/a /b,", maxCookieRewrites+1),
+			},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			data := map[string]string{}
+			for name, value := range tc.annotations {
+				data[parser.GetAnnotationWithPrefix(name)] = value
+			}
+			ing.SetAnnotations(data)
+
+			i, err := NewParser(mockBackend{}).Parse(ing)
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("expected an error parsing an invalid annotation")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			p, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("expected a Config type")
+			}
+			if !cookieRewritesEqual(p.CookieDomain, tc.domains) {
+				t.Errorf("expected %v as cookie domains but returned %v", tc.domains, p.CookieDomain)
+			}
+			if !cookieRewritesEqual(p.CookiePath, tc.paths) {
+				t.Errorf("expected %v as cookie paths but returned %v", tc.paths, p.CookiePath)
+			}
+		})
+	}
+}
+
+func TestDefaultCookieRewrites(t *testing.T) {
+	domains, paths := DefaultCookieRewrites(defaults.Backend{
+		ProxyCookieDomain: off,
+		ProxyCookiePath:   "/ /app/",
+	})
+	if len(domains) != 0 {
+		t.Errorf("expected no cookie domains but returned %v", domains)
+	}
+	if !cookieRewritesEqual(paths, []CookieRewrite{{From: "/", To: "/app/"}}) {
+		t.Errorf("unexpected cookie paths %v", paths)
+	}
+
+	domains, _ = DefaultCookieRewrites(defaults.Backend{ProxyCookieDomain: "local host example.org"})
+	if len(domains) != 0 {
+		t.Errorf("expected an invalid default to be ignored but returned %v", domains)
+	}
+}
+
+func TestProxyStrictValidation(t *testing.T) {
+	schema := parser.NewAnnotationSchema(NewParser(mockBackend{}).GetDocumentation())
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+	}{
+		{"valid", map[string]string{
+			parser.GetAnnotationWithPrefix(proxyConnectTimeoutAnnotation):      "5",
+			parser.GetAnnotationWithPrefix(proxyReadTimeoutAnnotation):         "60",
+			parser.GetAnnotationWithPrefix(proxyNextUpstreamTriesAnnotation):   "0",
+			parser.GetAnnotationWithPrefix(proxyBufferSizeAnnotation):          "8k",
+			parser.GetAnnotationWithPrefix(proxyBodySizeAnnotation):            "1G",
+			parser.GetAnnotationWithPrefix(proxyMaxTempFileSizeAnnotation):     "1024m",
+			parser.GetAnnotationWithPrefix(proxyNextUpstreamTimeoutAnnotation): "0",
+		}, 0},
+		{"negative timeouts", map[string]string{
+			parser.GetAnnotationWithPrefix(proxyConnectTimeoutAnnotation): "-1",
+			parser.GetAnnotationWithPrefix(proxySendTimeoutAnnotation):    "0",
+			parser.GetAnnotationWithPrefix(proxyReadTimeoutAnnotation):    "-60",
+		}, 3},
+		{"no buffers", map[string]string{parser.GetAnnotationWithPrefix(proxyBuffersNumberAnnotation): "0"}, 1},
+		{"sizes with spaces", map[string]string{
+			parser.GetAnnotationWithPrefix(proxyBufferSizeAnnotation): "8 k",
+			parser.GetAnnotationWithPrefix(proxyBodySizeAnnotation):   "1 0m",
+		}, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := schema.Validate(tc.annotations); len(errs) != tc.expected {
+				t.Errorf("expected %d errors but returned %v", tc.expected, errs)
+			}
+		})
+	}
 }
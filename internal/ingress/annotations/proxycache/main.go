@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxycache
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	enableProxyCacheAnnotation  = "enable-proxy-cache"
+	proxyCacheKeyAnnotation     = "proxy-cache-key"
+	proxyCacheValidAnnotation   = "proxy-cache-valid"
+	proxyCacheBypassAnnotation  = "proxy-cache-bypass"
+	proxyCacheNoCacheAnnotation = "proxy-cache-no-cache"
+)
+
+// Directory is the directory containing the cache zones of the Ingresses
+const Directory = "/tmp/nginx/cache"
+
+// KeySeparator separates the components of the cache key, so the
+// value of a component cannot be confused with the next one
+const KeySeparator = "|"
+
+var (
+	// DefaultKey are the components of the cache key when none is configured
+	DefaultKey = []string{"scheme", "method", "host", "uri"}
+
+	defaultValid = []string{"200 301 302 1m"}
+)
+
+var (
+	keyComponentsRegex = regexp.MustCompile(`^((scheme|method|host|uri|path|query|header:[A-Za-z0-9-]+|cookie:\w+|arg:\w+),?)+$`)
+	conditionsRegex    = regexp.MustCompile(`^((header:[A-Za-z0-9-]+|cookie:\w+|arg:\w+),?)+$`)
+	validRegex         = regexp.MustCompile(`^((\d{3}|any)(\s+(\d{3}|any))*\s*=\s*\d+[smhd]?)(\s*,\s*((\d{3}|any)(\s+(\d{3}|any))*\s*=\s*\d+[smhd]?))*$`)
+)
+
+var proxyCacheAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		enableProxyCacheAnnotation: {
+			Validator:     parser.ValidateBool,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation enables the caching of the responses of the backend in a cache zone dedicated to the Ingress`,
+		},
+		proxyCacheKeyAnnotation: {
+			Validator: parser.ValidateRegex(keyComponentsRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the comma separated components of the cache key: scheme, method, host, uri, path, query, ` +
+				`header:<name>, cookie:<name> and arg:<name>. Defaults to scheme,method,host,uri`,
+		},
+		proxyCacheValidAnnotation: {
+			Validator: parser.ValidateRegex(validRegex, false),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the comma separated caching times by status code, like "200 302=10m,404=1m,any=10s". ` +
+				`Defaults to "200 301 302=1m"`,
+		},
+		proxyCacheBypassAnnotation: {
+			Validator: parser.ValidateRegex(conditionsRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the comma separated header:<name>, cookie:<name> and arg:<name> conditions ` +
+				`that do not take the response from the cache when they are not empty and not equal to "0"`,
+		},
+		proxyCacheNoCacheAnnotation: {
+			Validator: parser.ValidateRegex(conditionsRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the comma separated header:<name>, cookie:<name> and arg:<name> conditions ` +
+				`that do not save the response to the cache when they are not empty and not equal to "0"`,
+		},
+	},
+}
+
+// Config contains the caching of the responses of a location
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Zone is the name of the cache zone of the Ingress
+	Zone string `json:"zone,omitempty"`
+	// Key are the components of the cache key
+	Key []string `json:"key,omitempty"`
+	// Valid are the parameters of the proxy_cache_valid directives
+	Valid   []string `json:"valid,omitempty"`
+	Bypass  []string `json:"bypass,omitempty"`
+	NoCache []string `json:"noCache,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return reflect.DeepEqual(c1, c2)
+}
+
+// Variable returns the NGINX variable of a component of the cache key
+// or of a bypass condition
+func Variable(component string) string {
+	kind, name, _ := strings.Cut(component, ":")
+	switch kind {
+	case "scheme":
+		return "$scheme"
+	case "method":
+		return "$request_method"
+	case "host":
+		return "$host"
+	case "uri":
+		return "$request_uri"
+	case "path":
+		return "$uri"
+	case "query":
+		return "$args"
+	case "header":
+		return "$http_" + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	case "cookie":
+		return "$cookie_" + name
+	case "arg":
+		return "$arg_" + name
+	}
+	return ""
+}
+
+// ZoneName returns the name of the cache zone of an Ingress
+func ZoneName(namespace, name string) string {
+	return fmt.Sprintf("%v_%v", namespace, name)
+}
+
+type proxyCache struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new proxy cache annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return proxyCache{
+		r:                r,
+		annotationConfig: proxyCacheAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to cache the responses of the backend
+func (a proxyCache) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation(enableProxyCacheAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if !enabled {
+		return &Config{}, nil
+	}
+
+	config := &Config{
+		Enabled: true,
+		Zone:    ZoneName(ing.Namespace, ing.Name),
+		Key:     DefaultKey,
+		Valid:   defaultValid,
+	}
+
+	key, err := parser.GetStringAnnotation(proxyCacheKeyAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Key = splitList(key)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	valid, err := parser.GetStringAnnotation(proxyCacheValidAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Valid = parseValid(valid)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	bypass, err := parser.GetStringAnnotation(proxyCacheBypassAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Bypass = splitList(bypass)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	noCache, err := parser.GetStringAnnotation(proxyCacheNoCacheAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.NoCache = splitList(noCache)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseValid converts "200 302=10m,404=1m" to the parameters
+// of the proxy_cache_valid directives, "200 302 10m" and "404 1m"
+func parseValid(value string) []string {
+	valid := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		codes, duration, _ := strings.Cut(entry, "=")
+		valid = append(valid, strings.Join(append(strings.Fields(codes), strings.TrimSpace(duration)), " "))
+	}
+	return valid
+}
+
+func (a proxyCache) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a proxyCache) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, proxyCacheAnnotations.Annotations)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxycache
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enable := parser.GetAnnotationWithPrefix(enableProxyCacheAnnotation)
+	key := parser.GetAnnotationWithPrefix(proxyCacheKeyAnnotation)
+	valid := parser.GetAnnotationWithPrefix(proxyCacheValidAnnotation)
+	bypass := parser.GetAnnotationWithPrefix(proxyCacheBypassAnnotation)
+	noCache := parser.GetAnnotationWithPrefix(proxyCacheNoCacheAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	defaultConfig := Config{Enabled: true, Zone: "default_foo", Key: DefaultKey, Valid: defaultValid}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{key: "host,uri"}, Config{}, false},
+		{map[string]string{enable: "false"}, Config{}, false},
+		{map[string]string{enable: "true"}, defaultConfig, false},
+		{
+			map[string]string{
+				enable:  "true",
+				key:     "scheme, host, path, header:Accept-Language, cookie:lang",
+				valid:   "200 302=10m, 404=1m,any=10s",
+				bypass:  "header:Cache-Control,arg:nocache",
+				noCache: "cookie:session",
+			},
+			Config{
+				Enabled: true,
+				Zone:    "default_foo",
+				Key:     []string{"scheme", "host", "path", "header:Accept-Language", "cookie:lang"},
+				Valid:   []string{"200 302 10m", "404 1m", "any 10s"},
+				Bypass:  []string{"header:Cache-Control", "arg:nocache"},
+				NoCache: []string{"cookie:session"},
+			},
+			false,
+		},
+		{map[string]string{enable: "yes"}, Config{}, true},
+		{map[string]string{enable: "true", key: "$request_uri"}, Config{}, true},
+		{map[string]string{enable: "true", key: "cookie:a-b"}, Config{}, true},
+		{map[string]string{enable: "true", valid: "10m"}, Config{}, true},
+		{map[string]string{enable: "true", valid: "200=10m;"}, Config{}, true},
+		{map[string]string{enable: "true", bypass: "uri"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestVariable(t *testing.T) {
+	testCases := map[string]string{
+		"scheme":                 "$scheme",
+		"method":                 "$request_method",
+		"host":                   "$host",
+		"uri":                    "$request_uri",
+		"path":                   "$uri",
+		"query":                  "$args",
+		"header:Accept-Language": "$http_accept_language",
+		"cookie:session":         "$cookie_session",
+		"arg:page":               "$arg_page",
+		"unknown":                "",
+	}
+
+	for component, expected := range testCases {
+		if v := Variable(component); v != expected {
+			t.Errorf("expected %q for %q but got %q", expected, component, v)
+		}
+	}
+}
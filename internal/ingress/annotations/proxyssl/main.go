@@ -42,6 +42,8 @@ var (
 	proxySSLOnOffRegex    = regexp.MustCompile(`^(on|off)$`)
 	proxySSLProtocolRegex = regexp.MustCompile(`^(TLSv1\.2|TLSv1\.3| )*$`)
 	proxySSLCiphersRegex  = regexp.MustCompile(`^[A-Za-z0-9\+:\_\-!]*$`)
+	// spiffeIDRegex follows the format of the SPIFFE IDs defined by the SPIFFE specification
+	spiffeIDRegex = regexp.MustCompile(`^spiffe://[a-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
 )
 
 const (
@@ -52,6 +54,7 @@ const (
 	proxySSLVerifyAnnotation      = "proxy-ssl-verify"
 	proxySSLVerifyDepthAnnotation = "proxy-ssl-verify-depth"
 	proxySSLServerNameAnnotation  = "proxy-ssl-server-name"
+	proxySSLSpiffeIDAnnotation    = "proxy-ssl-spiffe-id"
 )
 
 var proxySSLAnnotation = parser.Annotation{
@@ -104,6 +107,14 @@ var proxySSLAnnotation = parser.Annotation{
 			Risk:          parser.AnnotationRiskLow,
 			Documentation: `This annotation enables passing of the server name through TLS Server Name Indication extension (SNI, RFC 6066) when establishing a connection with the proxied HTTPS server.`,
 		},
+		proxySSLSpiffeIDAnnotation: {
+			Validator: parser.ValidateRegex(spiffeIDRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation specifies the SPIFFE ID of the X.509 SVID, obtained from the SPIFFE Workload API, used for authentication to a proxied HTTPS server. 
+			The CA certificates of its trust domain are used to verify the certificate of the proxied HTTPS server. 
+			It requires the flag --spiffe-workload-api-socket and cannot be used with proxy-ssl-secret.`,
+		},
 	},
 }
 
@@ -117,6 +128,8 @@ type Config struct {
 	Verify             string `json:"verify"`
 	VerifyDepth        int    `json:"verifyDepth"`
 	ProxySSLServerName string `json:"proxySSLServerName"`
+	// SpiffeID is the SPIFFE ID of the SVID used instead of the certificate of a secret
+	SpiffeID string `json:"spiffeID,omitempty"`
 }
 
 // Equal tests for equality between two Config types
@@ -145,6 +158,9 @@ func (pssl1 *Config) Equal(pssl2 *Config) bool {
 	if pssl1.ProxySSLServerName != pssl2.ProxySSLServerName {
 		return false
 	}
+	if pssl1.SpiffeID != pssl2.SpiffeID {
+		return false
+	}
 	return true
 }
 
@@ -189,28 +205,39 @@ func (p proxySSL) Parse(ing *networking.Ingress) (interface{}, error) {
 	var err error
 	config := &Config{}
 
-	proxysslsecret, err := parser.GetStringAnnotation(proxySSLSecretAnnotation, ing, p.annotationConfig.Annotations)
-	if err != nil {
+	config.SpiffeID, err = parser.GetStringAnnotation(proxySSLSpiffeIDAnnotation, ing, p.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
 		return &Config{}, err
 	}
-
-	ns, _, err := k8s.ParseNameNS(proxysslsecret)
-	if err != nil {
-		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	if config.SpiffeID != "" && !spiffeIDRegex.MatchString(config.SpiffeID) {
+		return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid SPIFFE ID %q", config.SpiffeID))
 	}
 
-	secCfg := p.r.GetSecurityConfiguration()
-	// We don't accept different namespaces for secrets.
-	if !secCfg.AllowCrossNamespaceResources && ns != ing.Namespace {
-		return &Config{}, ing_errors.NewLocationDenied("cross namespace secrets are not supported")
-	}
+	proxysslsecret, err := parser.GetStringAnnotation(proxySSLSecretAnnotation, ing, p.annotationConfig.Annotations)
+	switch {
+	case err != nil && (config.SpiffeID == "" || !ing_errors.IsMissingAnnotations(err)):
+		return &Config{}, err
+	case err == nil && config.SpiffeID != "":
+		return &Config{}, ing_errors.NewLocationDenied("proxy-ssl-secret and proxy-ssl-spiffe-id cannot be used together")
+	case err == nil:
+		ns, _, err := k8s.ParseNameNS(proxysslsecret)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
 
-	proxyCert, err := p.r.GetAuthCertificate(proxysslsecret)
-	if err != nil {
-		e := fmt.Errorf("error obtaining certificate: %w", err)
-		return &Config{}, ing_errors.LocationDeniedError{Reason: e}
+		secCfg := p.r.GetSecurityConfiguration()
+		// We don't accept different namespaces for secrets.
+		if !secCfg.AllowCrossNamespaceResources && ns != ing.Namespace {
+			return &Config{}, ing_errors.NewLocationDenied("cross namespace secrets are not supported")
+		}
+
+		proxyCert, err := p.r.GetAuthCertificate(proxysslsecret)
+		if err != nil {
+			e := fmt.Errorf("error obtaining certificate: %w", err)
+			return &Config{}, ing_errors.LocationDeniedError{Reason: e}
+		}
+		config.AuthSSLCert = *proxyCert
 	}
-	config.AuthSSLCert = *proxyCert
 
 	config.Ciphers, err = parser.GetStringAnnotation(proxySSLCiphersAnnotation, ing, p.annotationConfig.Annotations)
 	if err != nil {
@@ -209,6 +209,47 @@ func TestInvalidAnnotations(t *testing.T) {
 	}
 }
 
+func TestSpiffeIDAnnotation(t *testing.T) {
+	ing := buildIngress()
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix(proxySSLSpiffeIDAnnotation)] = "spiffe://example.org/ns/default/sa/ingress"
+	data[parser.GetAnnotationWithPrefix(proxySSLVerifyAnnotation)] = "on"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(&mockSecret{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("Unexpected error with ingress: %v", err)
+	}
+	u, ok := i.(*Config)
+	if !ok {
+		t.Fatalf("expected *Config but got %v", u)
+	}
+	if u.SpiffeID != "spiffe://example.org/ns/default/sa/ingress" {
+		t.Errorf("expected %v but got %v", "spiffe://example.org/ns/default/sa/ingress", u.SpiffeID)
+	}
+	if u.Secret != "" {
+		t.Errorf("expected no secret but got %v", u.Secret)
+	}
+	if u.Verify != "on" {
+		t.Errorf("expected %v but got %v", "on", u.Verify)
+	}
+
+	for _, id := range []string{"https://example.org/ingress", "spiffe://Example.org/ingress", "spiffe://example.org/ingress/"} {
+		data[parser.GetAnnotationWithPrefix(proxySSLSpiffeIDAnnotation)] = id
+		ing.SetAnnotations(data)
+		if _, err := NewParser(&mockSecret{}).Parse(ing); err == nil {
+			t.Errorf("expected an error for the invalid SPIFFE ID %v", id)
+		}
+	}
+
+	data[parser.GetAnnotationWithPrefix(proxySSLSpiffeIDAnnotation)] = "spiffe://example.org/ingress"
+	data[parser.GetAnnotationWithPrefix(proxySSLSecretAnnotation)] = defaultDemoSecret
+	ing.SetAnnotations(data)
+	if _, err := NewParser(&mockSecret{}).Parse(ing); !errors.IsLocationDenied(err) {
+		t.Errorf("expected proxy-ssl-secret and proxy-ssl-spiffe-id to be denied together but got %v", err)
+	}
+}
+
 func TestEquals(t *testing.T) {
 	cfg1 := &Config{}
 	cfg2 := &Config{}
@@ -283,6 +324,15 @@ func TestEquals(t *testing.T) {
 	}
 	cfg2.ProxySSLServerName = off
 
+	// Different SpiffeID
+	cfg1.SpiffeID = "spiffe://example.org/ingress"
+	cfg2.SpiffeID = "spiffe://example.org/other"
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.SpiffeID = cfg1.SpiffeID
+
 	// Equal Configs
 	result = cfg1.Equal(cfg2)
 	if result != true {
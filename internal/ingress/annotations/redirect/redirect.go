@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/errors"
@@ -39,14 +40,22 @@ type Config struct {
 	Code      int    `json:"code"`
 	FromToWWW bool   `json:"fromToWWW"`
 	Relative  bool   `json:"relative"`
+	// KeepQuery appends the query string of the request to the URL
+	KeepQuery bool `json:"keepQuery"`
+	// AppendPath appends the path of the request to the URL
+	AppendPath bool `json:"appendPath"`
 }
 
 const (
 	fromToWWWRedirAnnotation        = "from-to-www-redirect"
 	temporalRedirectAnnotation      = "temporal-redirect"
 	temporalRedirectAnnotationCode  = "temporal-redirect-code"
+	temporalRedirectKeepQuery       = "temporal-redirect-keep-query"
+	temporalRedirectAppendPath      = "temporal-redirect-append-path"
 	permanentRedirectAnnotation     = "permanent-redirect"
 	permanentRedirectAnnotationCode = "permanent-redirect-code"
+	permanentRedirectKeepQuery      = "permanent-redirect-keep-query"
+	permanentRedirectAppendPath     = "permanent-redirect-append-path"
 	relativeRedirectsAnnotation     = "relative-redirects"
 )
 
@@ -60,30 +69,58 @@ var redirectAnnotations = parser.Annotation{
 			Documentation: `In some scenarios, it is required to redirect from www.domain.com to domain.com or vice versa, which way the redirect is performed depends on the configured host value in the Ingress object.`,
 		},
 		temporalRedirectAnnotation: {
-			Validator: parser.ValidateRegex(parser.URLIsValidRegex, false),
-			Scope:     parser.AnnotationScopeLocation,
-			Risk:      parser.AnnotationRiskMedium, // Medium, as it allows arbitrary URLs that needs to be validated
+			Validator:       parser.ValidateRegex(parser.URLIsValidRegex, false),
+			StrictValidator: parser.ValidateHTTPURL,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskMedium, // Medium, as it allows arbitrary URLs that needs to be validated
 			Documentation: `This annotation allows you to return a temporal redirect (Return Code 302) instead of sending data to the upstream. 
 			For example setting this annotation to https://www.google.com would redirect everything to Google with a Return Code of 302 (Moved Temporarily).`,
 		},
 		temporalRedirectAnnotationCode: {
-			Validator:     parser.ValidateInt,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(http.StatusMultipleChoices, http.StatusTemporaryRedirect),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow, // Low, as it allows just a set of options
+			Documentation:   `This annotation allows you to modify the status code used for temporal redirects.`,
+		},
+		temporalRedirectKeepQuery: {
+			Validator:     parser.ValidateBool,
 			Scope:         parser.AnnotationScopeLocation,
 			Risk:          parser.AnnotationRiskLow, // Low, as it allows just a set of options
-			Documentation: `This annotation allows you to modify the status code used for temporal redirects.`,
+			Documentation: `This annotation appends the query string of the request to the URL of the temporal redirect.`,
+		},
+		temporalRedirectAppendPath: {
+			Validator:     parser.ValidateBool,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow, // Low, as it allows just a set of options
+			Documentation: `This annotation appends the path of the request to the URL of the temporal redirect.`,
 		},
 		permanentRedirectAnnotation: {
-			Validator: parser.ValidateRegex(parser.URLIsValidRegex, false),
-			Scope:     parser.AnnotationScopeLocation,
-			Risk:      parser.AnnotationRiskMedium, // Medium, as it allows arbitrary URLs that needs to be validated
+			Validator:       parser.ValidateRegex(parser.URLIsValidRegex, false),
+			StrictValidator: parser.ValidateHTTPURL,
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskMedium, // Medium, as it allows arbitrary URLs that needs to be validated
 			Documentation: `This annotation allows to return a permanent redirect (Return Code 301) instead of sending data to the upstream. 
 			For example setting this annotation https://www.google.com would redirect everything to Google with a code 301`,
 		},
 		permanentRedirectAnnotationCode: {
-			Validator:     parser.ValidateInt,
+			Validator:       parser.ValidateInt,
+			StrictValidator: parser.ValidateIntRange(http.StatusMultipleChoices, http.StatusPermanentRedirect),
+			Scope:           parser.AnnotationScopeLocation,
+			Risk:            parser.AnnotationRiskLow, // Low, as it allows just a set of options
+			Documentation:   `This annotation allows you to modify the status code used for permanent redirects.`,
+		},
+		permanentRedirectKeepQuery: {
+			Validator:     parser.ValidateBool,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow, // Low, as it allows just a set of options
+			Documentation: `This annotation appends the query string of the request to the URL of the permanent redirect.`,
+		},
+		permanentRedirectAppendPath: {
+			Validator:     parser.ValidateBool,
 			Scope:         parser.AnnotationScopeLocation,
 			Risk:          parser.AnnotationRiskLow, // Low, as it allows just a set of options
-			Documentation: `This annotation allows you to modify the status code used for permanent redirects.`,
+			Documentation: `This annotation appends the path of the request to the URL of the permanent redirect.`,
 		},
 		relativeRedirectsAnnotation: {
 			Validator:     parser.ValidateBool,
@@ -141,11 +178,18 @@ func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 			return nil, err
 		}
 
+		keepQuery, appendPath, err := r.parseRequestParts(ing, tr, temporalRedirectKeepQuery, temporalRedirectAppendPath)
+		if err != nil {
+			return nil, err
+		}
+
 		return &Config{
-			URL:       tr,
-			Code:      trc,
-			FromToWWW: r3w,
-			Relative:  rr,
+			URL:        tr,
+			Code:       trc,
+			FromToWWW:  r3w,
+			Relative:   rr,
+			KeepQuery:  keepQuery,
+			AppendPath: appendPath,
 		}, nil
 	}
 
@@ -164,11 +208,21 @@ func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 	}
 
 	if pr != "" || r3w {
+		var keepQuery, appendPath bool
+		if pr != "" {
+			keepQuery, appendPath, err = r.parseRequestParts(ing, pr, permanentRedirectKeepQuery, permanentRedirectAppendPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		return &Config{
-			URL:       pr,
-			Code:      prc,
-			FromToWWW: r3w,
-			Relative:  rr,
+			URL:        pr,
+			Code:       prc,
+			FromToWWW:  r3w,
+			Relative:   rr,
+			KeepQuery:  keepQuery,
+			AppendPath: appendPath,
 		}, nil
 	}
 
@@ -181,6 +235,34 @@ func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 	return nil, errors.ErrMissingAnnotations
 }
 
+// parseRequestParts returns if the query string and the path of the
+// request are appended to the URL of a redirect
+func (r redirect) parseRequestParts(ing *networking.Ingress, redirectURL, keepQueryAnnotation, appendPathAnnotation string) (keepQuery, appendPath bool, err error) {
+	keepQuery, err = parser.GetBoolAnnotation(keepQueryAnnotation, ing, r.annotationConfig.Annotations)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return false, false, err
+	}
+
+	appendPath, err = parser.GetBoolAnnotation(appendPathAnnotation, ing, r.annotationConfig.Annotations)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return false, false, err
+	}
+
+	if appendPath && strings.ContainsAny(redirectURL, "?#") {
+		klog.Warningf("ignoring annotation %v in ingress %v/%v: the path cannot be appended to a URL with a query string or fragment", appendPathAnnotation, ing.Namespace, ing.Name)
+		appendPath = false
+	}
+
+	// a path like //example.com appended to a relative URL redirects to
+	// another host
+	if appendPath && !isAbsoluteURL(redirectURL) {
+		klog.Warningf("ignoring annotation %v in ingress %v/%v: the path can only be appended to an absolute http or https URL", appendPathAnnotation, ing.Namespace, ing.Name)
+		appendPath = false
+	}
+
+	return keepQuery, appendPath, nil
+}
+
 // Equal tests for equality between two Redirect types
 func (r1 *Config) Equal(r2 *Config) bool {
 	if r1 == r2 {
@@ -201,9 +283,21 @@ func (r1 *Config) Equal(r2 *Config) bool {
 	if r1.Relative != r2.Relative {
 		return false
 	}
+	if r1.KeepQuery != r2.KeepQuery {
+		return false
+	}
+	if r1.AppendPath != r2.AppendPath {
+		return false
+	}
 	return true
 }
 
+// isAbsoluteURL returns true when s is an http or https URL with a host
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func isValidURL(s string) error {
 	u, err := url.Parse(s)
 	if err != nil {
@@ -174,6 +174,84 @@ func TestTemporalRedirectWithCustomCode(t *testing.T) {
 	}
 }
 
+func TestRedirectRequestParts(t *testing.T) {
+	rp := NewParser(resolver.Mock{})
+
+	testCases := map[string]struct {
+		annotations map[string]string
+		expected    *Config
+	}{
+		"permanent redirect keeping the query and the path": {
+			map[string]string{
+				permanentRedirectAnnotation: defRedirectURL,
+				permanentRedirectKeepQuery:  "true",
+				permanentRedirectAppendPath: "true",
+			},
+			&Config{URL: defRedirectURL, Code: defaultPermanentRedirectCode, KeepQuery: true, AppendPath: true},
+		},
+		"temporal redirect keeping the query": {
+			map[string]string{
+				temporalRedirectAnnotation:  defRedirectURL,
+				temporalRedirectKeepQuery:   "true",
+				permanentRedirectAppendPath: "true",
+			},
+			&Config{URL: defRedirectURL, Code: defaultTemporalRedirectCode, KeepQuery: true},
+		},
+		"path not appended to a url with a query string": {
+			map[string]string{
+				permanentRedirectAnnotation: defRedirectURL + "/?from=old",
+				permanentRedirectAppendPath: "true",
+			},
+			&Config{URL: defRedirectURL + "/?from=old", Code: defaultPermanentRedirectCode},
+		},
+		"path not appended to a relative url": {
+			map[string]string{
+				permanentRedirectAnnotation: "/",
+				permanentRedirectAppendPath: "true",
+			},
+			&Config{URL: "/", Code: defaultPermanentRedirectCode},
+		},
+		"path not appended to a url without host": {
+			map[string]string{
+				permanentRedirectAnnotation: "https:/path",
+				permanentRedirectAppendPath: "true",
+			},
+			&Config{URL: "https:/path", Code: defaultPermanentRedirectCode},
+		},
+		"from-to-www redirect without url": {
+			map[string]string{
+				fromToWWWRedirAnnotation:   "true",
+				permanentRedirectKeepQuery: "true",
+			},
+			&Config{Code: defaultPermanentRedirectCode, FromToWWW: true},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			ing := new(networking.Ingress)
+
+			data := make(map[string]string, len(tc.annotations))
+			for k, v := range tc.annotations {
+				data[parser.GetAnnotationWithPrefix(k)] = v
+			}
+			ing.SetAnnotations(data)
+
+			i, err := rp.Parse(ing)
+			if err != nil {
+				t.Fatalf("Unexpected error with ingress: %v", err)
+			}
+			redirect, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("Expected a Redirect type")
+			}
+			if !redirect.Equal(tc.expected) {
+				t.Errorf("Expected %v but returned %v", tc.expected, redirect)
+			}
+		})
+	}
+}
+
 func TestIsValidURL(t *testing.T) {
 	invalid := "ok.com"
 	urlParse, err := url.Parse(invalid)
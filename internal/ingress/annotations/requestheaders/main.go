@@ -0,0 +1,286 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requestheaders
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	requestHeadersSetAnnotation    = "request-headers-set"
+	requestHeadersAddAnnotation    = "request-headers-add"
+	requestHeadersRemoveAnnotation = "request-headers-remove"
+)
+
+var (
+	headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	// values are printable ASCII characters and NGINX variables, without
+	// the characters ending or escaping a directive
+	headerValueRegexp = regexp.MustCompile(`^(?:[^"\\;{}$\x00-\x1f\x7f-\x{10ffff}]|\$[A-Za-z_][A-Za-z0-9_]*|\$\{[A-Za-z_][A-Za-z0-9_]*\})+$`)
+)
+
+// ReservedHeaders are set by the controller for every request sent to
+// the upstream and can not be changed by the annotations
+var ReservedHeaders = sets.New[string](
+	"connection",
+	"content-length",
+	"host",
+	"proxy",
+	"ssl-client-cert",
+	"ssl-client-issuer-dn",
+	"ssl-client-subject-dn",
+	"ssl-client-verify",
+	"transfer-encoding",
+	"upgrade",
+	"x-forwarded-for",
+	"x-forwarded-host",
+	"x-forwarded-port",
+	"x-forwarded-proto",
+	"x-forwarded-scheme",
+	"x-original-forwarded-for",
+	"x-original-uri",
+	"x-real-ip",
+	"x-request-id",
+	"x-scheme",
+)
+
+var requestHeadersAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		requestHeadersSetAnnotation: {
+			Validator: validateHeaders,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation sets headers of the requests sent to the upstream, replacing the values sent by the client. ` +
+				`It contains one "Name: value" header per line, the values can use NGINX variables like $remote_addr`,
+		},
+		requestHeadersAddAnnotation: {
+			Validator: validateHeaders,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation adds headers to the requests sent to the upstream when the client did not send them. ` +
+				`It contains one "Name: value" header per line, the values can use NGINX variables like $remote_addr`,
+		},
+		requestHeadersRemoveAnnotation: {
+			Validator:     validateHeaderNames,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation removes the comma separated headers from the requests sent to the upstream`,
+		},
+	},
+}
+
+// Header is a request header sent to the upstream
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Config contains the changes of the headers of the requests sent to the upstream
+type Config struct {
+	Set    []Header `json:"set,omitempty"`
+	Add    []Header `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	if len(c1.Set) != len(c2.Set) || len(c1.Add) != len(c2.Add) || len(c1.Remove) != len(c2.Remove) {
+		return false
+	}
+	for i := range c1.Set {
+		if c1.Set[i] != c2.Set[i] {
+			return false
+		}
+	}
+	for i := range c1.Add {
+		if c1.Add[i] != c2.Add[i] {
+			return false
+		}
+	}
+	for i := range c1.Remove {
+		if c1.Remove[i] != c2.Remove[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validateHeaderName(name string) error {
+	if !headerNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if ReservedHeaders.Has(strings.ToLower(name)) {
+		return fmt.Errorf("header %q is set by the controller and can not be changed", name)
+	}
+	return nil
+}
+
+// parseHeaders parses the "Name: value" headers of an annotation, one per line
+func parseHeaders(value string) ([]Header, error) {
+	var headers []Header
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name, headerValue, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid header %q, expected the format Name: value", line)
+		}
+
+		header := Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(headerValue)}
+		if err := validateHeaderName(header.Name); err != nil {
+			return nil, err
+		}
+		if !headerValueRegexp.MatchString(header.Value) {
+			return nil, fmt.Errorf("invalid value %q for header %q", header.Value, header.Name)
+		}
+
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// parseHeaderNames parses the comma separated header names of an annotation
+func parseHeaderNames(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := validateHeaderName(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func validateHeaders(value string) error {
+	_, err := parseHeaders(value)
+	return err
+}
+
+func validateHeaderNames(value string) error {
+	_, err := parseHeaderNames(value)
+	return err
+}
+
+type requestHeaders struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new request headers annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return requestHeaders{
+		r:                r,
+		annotationConfig: requestHeadersAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to change the headers of the requests sent to the upstream
+func (a requestHeaders) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+	changed := sets.New[string]()
+
+	for _, field := range []struct {
+		annotation string
+		headers    *[]Header
+	}{
+		{requestHeadersSetAnnotation, &config.Set},
+		{requestHeadersAddAnnotation, &config.Add},
+	} {
+		value, err := parser.GetStringAnnotation(field.annotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return &Config{}, err
+		}
+
+		headers, err := parseHeaders(value)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+		for _, header := range headers {
+			if err := checkDuplicate(changed, header.Name); err != nil {
+				return &Config{}, err
+			}
+		}
+		*field.headers = headers
+	}
+
+	value, err := parser.GetStringAnnotation(requestHeadersRemoveAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Remove, err = parseHeaderNames(value)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+		for _, name := range config.Remove {
+			if err := checkDuplicate(changed, name); err != nil {
+				return &Config{}, err
+			}
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+// checkDuplicate returns an error when a header is changed by more than one
+// line of the annotations, NGINX would send it twice
+func checkDuplicate(changed sets.Set[string], name string) error {
+	key := strings.ToLower(name)
+	if changed.Has(key) {
+		return ing_errors.NewLocationDenied(fmt.Sprintf("header %q is changed more than once", name))
+	}
+	changed.Insert(key)
+	return nil
+}
+
+func (a requestHeaders) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a requestHeaders) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, requestHeadersAnnotations.Annotations)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requestheaders
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	set := parser.GetAnnotationWithPrefix(requestHeadersSetAnnotation)
+	add := parser.GetAnnotationWithPrefix(requestHeadersAddAnnotation)
+	remove := parser.GetAnnotationWithPrefix(requestHeadersRemoveAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := map[string]struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		"no annotations": {nil, Config{}, false},
+		"set headers": {
+			map[string]string{set: "X-Tenant: blue\nX-Client-Address: $remote_addr:${remote_port}\n"},
+			Config{Set: []Header{{"X-Tenant", "blue"}, {"X-Client-Address", "$remote_addr:${remote_port}"}}},
+			false,
+		},
+		"all annotations": {
+			map[string]string{set: "X-Tenant: blue", add: "X-Api-Version: v1", remove: "Cookie, X-Debug"},
+			Config{Set: []Header{{"X-Tenant", "blue"}}, Add: []Header{{"X-Api-Version", "v1"}}, Remove: []string{"Cookie", "X-Debug"}},
+			false,
+		},
+		"missing value":            {map[string]string{set: "X-Tenant"}, Config{}, true},
+		"empty value":              {map[string]string{add: "X-Tenant: "}, Config{}, true},
+		"invalid name":             {map[string]string{set: "X_Tenant: blue"}, Config{}, true},
+		"quote in value":           {map[string]string{set: `X-Tenant: blue"; return 200;`}, Config{}, true},
+		"block in value":           {map[string]string{set: "X-Tenant: } location / {"}, Config{}, true},
+		"invalid variable":         {map[string]string{set: "X-Tenant: $"}, Config{}, true},
+		"reserved header":          {map[string]string{set: "host: foo.bar"}, Config{}, true},
+		"reserved header removed":  {map[string]string{remove: "X-Forwarded-For"}, Config{}, true},
+		"header set and added":     {map[string]string{set: "X-Tenant: blue", add: "x-tenant: green"}, Config{}, true},
+		"header set and removed":   {map[string]string{set: "X-Tenant: blue", remove: "X-Tenant"}, Config{}, true},
+		"header set twice":         {map[string]string{set: "X-Tenant: blue\nX-Tenant: green"}, Config{}, true},
+		"invalid removed header":   {map[string]string{remove: "X-Debug;"}, Config{}, true},
+		"header and empty entries": {map[string]string{remove: "X-Debug,,"}, Config{Remove: []string{"X-Debug"}}, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing.SetAnnotations(testCase.annotations)
+			result, err := ap.Parse(ing)
+			if (err != nil) != testCase.expectErr {
+				t.Fatalf("expected error %t but got %v", testCase.expectErr, err)
+			}
+			if testCase.expectErr {
+				return
+			}
+			config, ok := result.(*Config)
+			if !ok {
+				t.Fatalf("expected a Config type but got %T", result)
+			}
+			if !reflect.DeepEqual(*config, testCase.expected) {
+				t.Errorf("expected %+v but got %+v", testCase.expected, *config)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c1 := &Config{Set: []Header{{"X-Tenant", "blue"}}, Remove: []string{"Cookie"}}
+	c2 := &Config{Set: []Header{{"X-Tenant", "blue"}}, Remove: []string{"Cookie"}}
+	if !c1.Equal(c2) {
+		t.Errorf("expected the configurations to be equal")
+	}
+
+	c2.Set[0].Value = "green"
+	if c1.Equal(c2) {
+		t.Errorf("expected the configurations to be different")
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requestid
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestheaders"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	requestIDPolicyAnnotation = "request-id-policy"
+	requestIDHeaderAnnotation = "request-id-header"
+	requestIDFormatAnnotation = "request-id-format"
+)
+
+const (
+	// PolicyTrust keeps the request ID sent by the client and generates one
+	// when it is missing
+	PolicyTrust = "trust"
+	// PolicyGenerate ignores the request ID sent by the client
+	PolicyGenerate = "generate"
+	// PolicyRegenerate keeps the request ID sent by the client when it has
+	// the format of the location and generates one otherwise
+	PolicyRegenerate = "regenerate"
+)
+
+const (
+	// FormatHex is the 32 hexadecimal characters of the NGINX $request_id
+	FormatHex = "hex"
+	// FormatUUIDv7 is a time ordered UUID of RFC 9562
+	FormatUUIDv7 = "uuidv7"
+	// FormatULID is a time ordered ULID in the Crockford base 32
+	FormatULID = "ulid"
+)
+
+// DefaultHeader is the header containing the request ID
+const DefaultHeader = "X-Request-ID"
+
+var (
+	policies = []string{PolicyTrust, PolicyGenerate, PolicyRegenerate}
+	formats  = []string{FormatHex, FormatUUIDv7, FormatULID}
+
+	headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+var requestIDAnnotations = parser.Annotation{
+	Group: "request-id",
+	Annotations: parser.AnnotationFields{
+		requestIDPolicyAnnotation: {
+			Validator: parser.ValidateOptions(policies, true, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets how the request ID of the location is obtained: trust keeps the ID sent by the client, ` +
+				`generate always creates a new one and regenerate keeps the ID sent by the client only when it has the format of request-id-format. (default: trust)`,
+		},
+		requestIDHeaderAnnotation: {
+			Validator:     validateHeaderName,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the header containing the request ID, read from the client and sent to the upstream. (default: X-Request-ID)`,
+		},
+		requestIDFormatAnnotation: {
+			Validator:     parser.ValidateOptions(formats, true, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the format of the generated request IDs: hex, uuidv7 or ulid. (default: hex)`,
+		},
+	},
+}
+
+// Config contains the request ID policy of a location
+type Config struct {
+	Policy string `json:"policy,omitempty"`
+	Header string `json:"header,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+// Enabled returns true when the location does not use the request ID
+// policy of the ConfigMap
+func (c *Config) Enabled() bool {
+	return c.Policy != ""
+}
+
+func validateHeaderName(name string) error {
+	name = strings.TrimSpace(name)
+	if !headerNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	// the controller sets the default header with the request ID
+	if lower := strings.ToLower(name); lower != strings.ToLower(DefaultHeader) && requestheaders.ReservedHeaders.Has(lower) {
+		return fmt.Errorf("header %q is set by the controller and can not contain the request ID", name)
+	}
+	return nil
+}
+
+type requestID struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new request ID annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return requestID{
+		r:                r,
+		annotationConfig: requestIDAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to generate and propagate the request ID
+func (a requestID) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	policy, err := parser.GetStringAnnotation(requestIDPolicyAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+
+	header, err := parser.GetStringAnnotation(requestIDHeaderAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+
+	format, err := parser.GetStringAnnotation(requestIDFormatAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+
+	// the header and the format of the request ID apply to the trust policy
+	// when they are set without policy
+	if policy == "" && header == "" && format == "" {
+		return config, nil
+	}
+
+	config.Policy = strings.TrimSpace(policy)
+	if config.Policy == "" {
+		config.Policy = PolicyTrust
+	}
+	config.Header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+	if config.Header == "" {
+		config.Header = DefaultHeader
+	}
+	config.Format = strings.TrimSpace(format)
+	if config.Format == "" {
+		config.Format = FormatHex
+	}
+
+	return config, nil
+}
+
+func (a requestID) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a requestID) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, requestIDAnnotations.Annotations)
+}
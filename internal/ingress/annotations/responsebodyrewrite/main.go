@@ -0,0 +1,246 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responsebodyrewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	responseBodyRewriteAnnotation      = "response-body-rewrite"
+	responseBodyRewriteTypesAnnotation = "response-body-rewrite-types"
+	responseBodyRewriteOnceAnnotation  = "response-body-rewrite-once"
+)
+
+const (
+	// ruleSeparator separates the string to find from its replacement
+	ruleSeparator = "=>"
+
+	// limits of the rules of a location, the responses are searched for
+	// every string to find
+	maxRules         = 20
+	maxFindLength    = 255
+	maxReplaceLength = 1024
+)
+
+var (
+	// the strings are printable ASCII characters, without the characters
+	// ending or escaping a string and without NGINX variables
+	stringRegexp = regexp.MustCompile(`^[^"\\$\x00-\x1f\x7f-\x{10ffff}]*$`)
+	mimeRegexp   = regexp.MustCompile(`^(\*|[a-z0-9.+-]+/[a-z0-9.+*-]+)$`)
+)
+
+var responseBodyRewriteAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		responseBodyRewriteAnnotation: {
+			Validator: validateRules,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation replaces strings in the bodies of the responses of the upstream. ` +
+				`It contains one "find => replace" pair per line, up to 20, like "http://legacy.internal/ => https://app.example.com/"`,
+		},
+		responseBodyRewriteTypesAnnotation: {
+			Validator: validateTypes,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the comma separated MIME types of the responses rewritten by response-body-rewrite, ` +
+				`in addition to text/html. * rewrites the responses of any type`,
+		},
+		responseBodyRewriteOnceAnnotation: {
+			Validator:     parser.ValidateBool,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation replaces only the first occurrence of each string of response-body-rewrite instead of all of them`,
+		},
+	},
+}
+
+// Rule is a string replaced in the bodies of the responses
+type Rule struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+}
+
+// Config contains the rewriting of the bodies of the responses of a location
+type Config struct {
+	Rules []Rule   `json:"rules,omitempty"`
+	Types []string `json:"types,omitempty"`
+	// Once replaces only the first occurrence of each string
+	Once bool `json:"once,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	if c1.Once != c2.Once || len(c1.Rules) != len(c2.Rules) || len(c1.Types) != len(c2.Types) {
+		return false
+	}
+	for i := range c1.Rules {
+		if c1.Rules[i] != c2.Rules[i] {
+			return false
+		}
+	}
+	for i := range c1.Types {
+		if c1.Types[i] != c2.Types[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseRules parses the "find => replace" pairs of the annotation, one per line
+func parseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		find, replace, found := strings.Cut(line, ruleSeparator)
+		if !found {
+			return nil, fmt.Errorf("invalid rule %q, expected the format find => replace", line)
+		}
+
+		rule := Rule{Find: strings.TrimSpace(find), Replace: strings.TrimSpace(replace)}
+		switch {
+		case rule.Find == "":
+			return nil, fmt.Errorf("invalid rule %q, the string to find is empty", line)
+		case len(rule.Find) > maxFindLength:
+			return nil, fmt.Errorf("the string to find %q is longer than %d characters", rule.Find, maxFindLength)
+		case len(rule.Replace) > maxReplaceLength:
+			return nil, fmt.Errorf("the replacement of %q is longer than %d characters", rule.Find, maxReplaceLength)
+		case !stringRegexp.MatchString(rule.Find) || !stringRegexp.MatchString(rule.Replace):
+			return nil, fmt.Errorf("invalid rule %q, quotes, backslashes, $ and non ASCII characters are not allowed", line)
+		}
+
+		for _, r := range rules {
+			if r.Find == rule.Find {
+				return nil, fmt.Errorf("the string %q is replaced more than once", rule.Find)
+			}
+		}
+
+		rules = append(rules, rule)
+		if len(rules) > maxRules {
+			return nil, fmt.Errorf("more than %d rules", maxRules)
+		}
+	}
+	return rules, nil
+}
+
+// parseTypes parses the comma separated MIME types of the annotation
+func parseTypes(value string) ([]string, error) {
+	var types []string
+	for _, mime := range strings.Split(value, ",") {
+		mime = strings.ToLower(strings.TrimSpace(mime))
+		if mime == "" {
+			continue
+		}
+		if !mimeRegexp.MatchString(mime) {
+			return nil, fmt.Errorf("invalid MIME type %q", mime)
+		}
+		types = append(types, mime)
+	}
+	return types, nil
+}
+
+func validateRules(value string) error {
+	_, err := parseRules(value)
+	return err
+}
+
+func validateTypes(value string) error {
+	_, err := parseTypes(value)
+	return err
+}
+
+type responseBodyRewrite struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new response body rewrite annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return responseBodyRewrite{
+		r:                r,
+		annotationConfig: responseBodyRewriteAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to rewrite the bodies of the responses of the upstream
+func (a responseBodyRewrite) Parse(ing *networking.Ingress) (interface{}, error) {
+	value, err := parser.GetStringAnnotation(responseBodyRewriteAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+
+	config := &Config{}
+	config.Rules, err = parseRules(value)
+	if err != nil {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+	if len(config.Rules) == 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(responseBodyRewriteAnnotation, value)
+	}
+
+	types, err := parser.GetStringAnnotation(responseBodyRewriteTypesAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Types, err = parseTypes(types)
+		if err != nil {
+			return &Config{}, ing_errors.NewLocationDenied(err.Error())
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	config.Once, err = parser.GetBoolAnnotation(responseBodyRewriteOnceAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a responseBodyRewrite) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a responseBodyRewrite) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, responseBodyRewriteAnnotations.Annotations)
+}
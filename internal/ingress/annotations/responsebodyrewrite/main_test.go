@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package responsebodyrewrite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	rewrite := parser.GetAnnotationWithPrefix(responseBodyRewriteAnnotation)
+	types := parser.GetAnnotationWithPrefix(responseBodyRewriteTypesAnnotation)
+	once := parser.GetAnnotationWithPrefix(responseBodyRewriteOnceAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	tooManyRules := ""
+	for i := 0; i <= maxRules; i++ {
+		tooManyRules += fmt.Sprintf("/legacy-%d/ => /app-%d/\n", i, i)
+	}
+
+	testCases := map[string]struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		"no annotations": {nil, Config{}, false},
+		"rules": {
+			map[string]string{rewrite: "http://legacy.internal/ => https://app.example.com/\n\n<!-- debug --> =>\n"},
+			Config{Rules: []Rule{
+				{"http://legacy.internal/", "https://app.example.com/"},
+				{"<!-- debug -->", ""},
+			}},
+			false,
+		},
+		"all annotations": {
+			map[string]string{rewrite: "/legacy/ => /app/", types: "text/css, Application/JavaScript", once: "true"},
+			Config{Rules: []Rule{{"/legacy/", "/app/"}}, Types: []string{"text/css", "application/javascript"}, Once: true},
+			false,
+		},
+		"types without rules": {map[string]string{types: "text/css"}, Config{}, false},
+		"missing separator":   {map[string]string{rewrite: "/legacy/ /app/"}, Config{}, true},
+		"empty find":          {map[string]string{rewrite: " => /app/"}, Config{}, true},
+		"no rule":             {map[string]string{rewrite: "\n\n"}, Config{}, true},
+		"quote":               {map[string]string{rewrite: `/legacy/ => /app/"; return 200; "`}, Config{}, true},
+		"variable":            {map[string]string{rewrite: "/legacy/ => $host"}, Config{}, true},
+		"find too long":       {map[string]string{rewrite: strings.Repeat("a", maxFindLength+1) + " => b"}, Config{}, true},
+		"replace too long":    {map[string]string{rewrite: "a => " + strings.Repeat("b", maxReplaceLength+1)}, Config{}, true},
+		"too many rules":      {map[string]string{rewrite: tooManyRules}, Config{}, true},
+		"duplicated find":     {map[string]string{rewrite: "/legacy/ => /app/\n/legacy/ => /v2/"}, Config{}, true},
+		"invalid type":        {map[string]string{rewrite: "/legacy/ => /app/", types: "text/css; charset=utf-8"}, Config{}, true},
+		"invalid once":        {map[string]string{rewrite: "/legacy/ => /app/", once: "first"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ing.SetAnnotations(testCase.annotations)
+			result, err := ap.Parse(ing)
+			if (err != nil) != testCase.expectErr {
+				t.Fatalf("expected error %t but got %v", testCase.expectErr, err)
+			}
+			if testCase.expectErr {
+				return
+			}
+			config, ok := result.(*Config)
+			if !ok {
+				t.Fatalf("expected a Config type but got %T", result)
+			}
+			if !reflect.DeepEqual(*config, testCase.expected) {
+				t.Errorf("expected %+v but got %+v", testCase.expected, *config)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c1 := &Config{Rules: []Rule{{"/legacy/", "/app/"}}, Types: []string{"text/css"}}
+	c2 := &Config{Rules: []Rule{{"/legacy/", "/app/"}}, Types: []string{"text/css"}}
+	if !c1.Equal(c2) {
+		t.Errorf("expected the configurations to be equal")
+	}
+
+	c2.Rules[0].Replace = "/v2/"
+	if c1.Equal(c2) {
+		t.Errorf("expected the configurations to be different")
+	}
+}
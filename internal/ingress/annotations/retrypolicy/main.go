@@ -0,0 +1,173 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrypolicy
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	retryOnAnnotation            = "retry-on"
+	retryMaxRetriesAnnotation    = "retry-max-retries"
+	retryPerTryTimeoutAnnotation = "retry-per-try-timeout"
+	retryBudgetPercentAnnotation = "retry-budget-percent"
+)
+
+const (
+	defaultRetryOn    = "error timeout"
+	defaultMaxRetries = 2
+)
+
+var validRetryOnAnnotation = regexp.MustCompile(`^((error|timeout|invalid_header|http_500|http_502|http_503|http_504|http_403|http_404|http_429|non_idempotent)\s?)+$`)
+
+var retryPolicyAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		retryOnAnnotation: {
+			Validator: parser.ValidateRegex(validRetryOnAnnotation, false),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the space separated conditions that retry a request with another endpoint. ` +
+				`It accepts the values of proxy-next-upstream except off.`,
+		},
+		retryMaxRetriesAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of times a request is retried. 0 disables the retries`,
+		},
+		retryPerTryTimeoutAnnotation: {
+			Validator:     parser.ValidateDuration,
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the connect, send and read timeouts of each try, like 500ms or 2s`,
+		},
+		retryBudgetPercentAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum percentage of the requests to the backend that can be retries. ` +
+				`Requests are not retried once the budget is exhausted. 0 disables the budget`,
+		},
+	},
+}
+
+// Config contains the retry policy of a location
+type Config struct {
+	Enabled       bool          `json:"enabled"`
+	RetryOn       string        `json:"retryOn"`
+	MaxRetries    int           `json:"maxRetries"`
+	PerTryTimeout time.Duration `json:"perTryTimeout"`
+	BudgetPercent int           `json:"budgetPercent"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type retryPolicy struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new retry policy annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return retryPolicy{
+		r:                r,
+		annotationConfig: retryPolicyAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to configure the retries of the requests
+func (a retryPolicy) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{
+		RetryOn:    defaultRetryOn,
+		MaxRetries: defaultMaxRetries,
+	}
+
+	retryOn, err := parser.GetStringAnnotation(retryOnAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.Enabled = true
+		config.RetryOn = strings.Join(strings.Fields(retryOn), " ")
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	maxRetries, err := parser.GetIntAnnotation(retryMaxRetriesAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		if maxRetries < 0 {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(retryMaxRetriesAnnotation, maxRetries)
+		}
+		config.Enabled = true
+		config.MaxRetries = maxRetries
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	if !config.Enabled {
+		return &Config{}, nil
+	}
+
+	perTryTimeout, err := parser.GetStringAnnotation(retryPerTryTimeoutAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		config.PerTryTimeout, err = time.ParseDuration(perTryTimeout)
+		if err != nil || config.PerTryTimeout < time.Millisecond {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(retryPerTryTimeoutAnnotation, perTryTimeout)
+		}
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	config.BudgetPercent, err = parser.GetIntAnnotation(retryBudgetPercentAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if config.BudgetPercent < 0 || config.BudgetPercent > 100 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(retryBudgetPercentAnnotation, config.BudgetPercent)
+	}
+
+	return config, nil
+}
+
+func (a retryPolicy) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a retryPolicy) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, retryPolicyAnnotations.Annotations)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retrypolicy
+
+import (
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	retryOn := parser.GetAnnotationWithPrefix(retryOnAnnotation)
+	maxRetries := parser.GetAnnotationWithPrefix(retryMaxRetriesAnnotation)
+	perTryTimeout := parser.GetAnnotationWithPrefix(retryPerTryTimeoutAnnotation)
+	budget := parser.GetAnnotationWithPrefix(retryBudgetPercentAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{perTryTimeout: "1s", budget: "20"}, Config{}, false},
+		{map[string]string{maxRetries: "3"}, Config{Enabled: true, RetryOn: defaultRetryOn, MaxRetries: 3}, false},
+		{map[string]string{retryOn: "error http_503 "}, Config{Enabled: true, RetryOn: "error http_503", MaxRetries: defaultMaxRetries}, false},
+		{
+			map[string]string{retryOn: "http_502 http_503", maxRetries: "1", perTryTimeout: "500ms", budget: "20"},
+			Config{Enabled: true, RetryOn: "http_502 http_503", MaxRetries: 1, PerTryTimeout: 500 * time.Millisecond, BudgetPercent: 20},
+			false,
+		},
+		{map[string]string{maxRetries: "0"}, Config{Enabled: true, RetryOn: defaultRetryOn}, false},
+		{map[string]string{retryOn: "off"}, Config{}, true},
+		{map[string]string{maxRetries: "-1"}, Config{}, true},
+		{map[string]string{maxRetries: "1", perTryTimeout: "1"}, Config{}, true},
+		{map[string]string{maxRetries: "1", perTryTimeout: "0s"}, Config{}, true},
+		{map[string]string{maxRetries: "1", budget: "101"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
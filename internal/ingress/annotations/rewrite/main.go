@@ -18,6 +18,7 @@ package rewrite
 
 import (
 	"net/url"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/klog/v2"
@@ -34,6 +35,7 @@ const (
 	forceSSLRedirectAnnotation      = "force-ssl-redirect"
 	useRegexAnnotation              = "use-regex"
 	appRootAnnotation               = "app-root"
+	serveSubpathAnnotation          = "serve-subpath"
 )
 
 var rewriteAnnotations = parser.Annotation{
@@ -77,6 +79,13 @@ var rewriteAnnotations = parser.Annotation{
 			Risk:          parser.AnnotationRiskMedium,
 			Documentation: `This annotation defines the Application Root that the Controller must redirect if it's in / context`,
 		},
+		serveSubpathAnnotation: {
+			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines the path of the upstream the path prefix of the Ingress is mapped to, like a directory. 
+			The prefix without a trailing slash is redirected to the prefix with it`,
+		},
 	},
 }
 
@@ -94,6 +103,8 @@ type Config struct {
 	AppRoot string `json:"appRoot"`
 	// UseRegex indicates whether or not the locations use regex paths
 	UseRegex bool `json:"useRegex"`
+	// ServeSubpath is the path of the upstream the path prefix of the location is mapped to
+	ServeSubpath string `json:"serveSubpath"`
 }
 
 // Equal tests for equality between two Redirect types
@@ -119,6 +130,9 @@ func (r1 *Config) Equal(r2 *Config) bool {
 	if r1.UseRegex != r2.UseRegex {
 		return false
 	}
+	if r1.ServeSubpath != r2.ServeSubpath {
+		return false
+	}
 
 	return true
 }
@@ -180,6 +194,21 @@ func (a rewrite) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.UseRegex = false
 	}
 
+	config.ServeSubpath, err = parser.GetStringAnnotation(serveSubpathAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err != nil:
+		if errors.IsValidationError(err) {
+			klog.Warningf("%s is invalid, defaulting to empty", serveSubpathAnnotation)
+		}
+		config.ServeSubpath = ""
+	case !strings.HasPrefix(config.ServeSubpath, "/") || !parser.BasicCharsRegex.MatchString(config.ServeSubpath):
+		klog.Warningf("Annotation %s only allows absolute paths (%v)", serveSubpathAnnotation, config.ServeSubpath)
+		config.ServeSubpath = ""
+	case config.Target != "" || config.UseRegex:
+		klog.Warningf("Annotation %s cannot be used with %s or %s, ignoring it", serveSubpathAnnotation, rewriteTargetAnnotation, useRegexAnnotation)
+		config.ServeSubpath = ""
+	}
+
 	config.AppRoot, err = parser.GetStringAnnotation(appRootAnnotation, ing, a.annotationConfig.Annotations)
 	if err != nil {
 		if !errors.IsMissingAnnotations(err) && !errors.IsInvalidContent(err) {
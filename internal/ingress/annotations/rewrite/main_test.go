@@ -272,3 +272,43 @@ func TestUseRegex(t *testing.T) {
 		t.Errorf("Unexpected value got in UseRegex")
 	}
 }
+
+func TestServeSubpath(t *testing.T) {
+	ap := NewParser(mockBackend{redirect: true})
+
+	testCases := []struct {
+		title       string
+		annotations map[string]string
+		expected    string
+	}{
+		{"Absolute paths should pass", map[string]string{"serve-subpath": "/static/app/"}, "/static/app/"},
+		{"Root path should pass", map[string]string{"serve-subpath": "/"}, "/"},
+		{"Relative paths are ignored", map[string]string{"serve-subpath": "static"}, ""},
+		{"Variables are ignored", map[string]string{"serve-subpath": "/$host"}, ""},
+		{"Rewrite target takes precedence", map[string]string{"serve-subpath": "/static", "rewrite-target": "/$1"}, ""},
+		{"Regular expression paths take precedence", map[string]string{"serve-subpath": "/static", "use-regex": "true"}, ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.title, func(t *testing.T) {
+			ing := buildIngress()
+			for name, value := range testCase.annotations {
+				ing.Annotations[parser.GetAnnotationWithPrefix(name)] = value
+			}
+
+			i, err := ap.Parse(ing)
+			if err != nil {
+				t.Fatalf("%v: unexpected error: %v", testCase.title, err)
+			}
+
+			rewrite, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("expected a rewrite Config")
+			}
+
+			if testCase.expected != rewrite.ServeSubpath {
+				t.Fatalf("%v: expected ServeSubpath with value %v but was returned: %v", testCase.title, testCase.expected, rewrite.ServeSubpath)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	applyAtAnnotation  = "apply-at"
+	expireAtAnnotation = "expire-at"
+)
+
+var scheduleAnnotations = parser.Annotation{
+	Group: "schedule",
+	Annotations: parser.AnnotationFields{
+		applyAtAnnotation: {
+			Validator: parser.ValidateTime,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the time, in RFC3339 format like 2024-06-01T03:00:00Z, from which the Ingress is applied. ` +
+				`The Ingress is ignored by the controller until then.`,
+		},
+		expireAtAnnotation: {
+			Validator:     parser.ValidateTime,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the time, in RFC3339 format like 2024-06-01T03:00:00Z, from which the Ingress is ignored by the controller.`,
+		},
+	},
+}
+
+// Config contains the times the Ingress is applied from and until
+type Config struct {
+	ApplyAt  time.Time `json:"applyAt,omitempty"`
+	ExpireAt time.Time `json:"expireAt,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return c1.ApplyAt.Equal(c2.ApplyAt) && c1.ExpireAt.Equal(c2.ExpireAt)
+}
+
+// Active returns true when the Ingress is applied at a time
+func (c Config) Active(now time.Time) bool {
+	if !c.ApplyAt.IsZero() && now.Before(c.ApplyAt) {
+		return false
+	}
+	if !c.ExpireAt.IsZero() && !now.Before(c.ExpireAt) {
+		return false
+	}
+	return true
+}
+
+// NextChange returns the next time the Ingress is applied or expires
+// after a time, false when it does not change anymore
+func (c Config) NextChange(now time.Time) (time.Time, bool) {
+	if !c.ApplyAt.IsZero() && now.Before(c.ApplyAt) {
+		return c.ApplyAt, true
+	}
+	if !c.ExpireAt.IsZero() && now.Before(c.ExpireAt) {
+		return c.ExpireAt, true
+	}
+	return time.Time{}, false
+}
+
+// Overlaps returns true when the Ingress and another Ingress are both
+// applied at a time after now
+func (c Config) Overlaps(other Config, now time.Time) bool {
+	start := now
+	for _, applyAt := range []time.Time{c.ApplyAt, other.ApplyAt} {
+		if applyAt.After(start) {
+			start = applyAt
+		}
+	}
+
+	var end time.Time
+	for _, expireAt := range []time.Time{c.ExpireAt, other.ExpireAt} {
+		if !expireAt.IsZero() && (end.IsZero() || expireAt.Before(end)) {
+			end = expireAt
+		}
+	}
+	return end.IsZero() || start.Before(end)
+}
+
+type schedule struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new schedule annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return schedule{
+		r:                r,
+		annotationConfig: scheduleAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to apply the Ingress during a period of time
+func (a schedule) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	var err error
+	config.ApplyAt, err = a.parseTime(ing, applyAtAnnotation)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	config.ExpireAt, err = a.parseTime(ing, expireAtAnnotation)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	if !config.ApplyAt.IsZero() && !config.ExpireAt.IsZero() && !config.ApplyAt.Before(config.ExpireAt) {
+		return &Config{}, ing_errors.ValidationError{
+			Reason: fmt.Errorf("annotation %v must be before annotation %v", applyAtAnnotation, expireAtAnnotation),
+		}
+	}
+
+	return config, nil
+}
+
+func (a schedule) parseTime(ing *networking.Ingress, name string) (time.Time, error) {
+	value, err := parser.GetStringAnnotation(name, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ing_errors.ValidationError{
+			Reason: fmt.Errorf("annotation %v is not a RFC3339 time: %w", name, err),
+		}
+	}
+	return t, nil
+}
+
+func (a schedule) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a schedule) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, scheduleAnnotations.Annotations)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	applyAt := parser.GetAnnotationWithPrefix(applyAtAnnotation)
+	expireAt := parser.GetAnnotationWithPrefix(expireAtAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	start := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 1, 5, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{applyAt: "2024-06-01T03:00:00Z"}, Config{ApplyAt: start}, false},
+		{map[string]string{expireAt: "2024-06-01T07:00:00+02:00"}, Config{ExpireAt: end}, false},
+		{
+			map[string]string{applyAt: "2024-06-01T03:00:00Z", expireAt: "2024-06-01T05:00:00Z"},
+			Config{ApplyAt: start, ExpireAt: end},
+			false,
+		},
+		{map[string]string{applyAt: "2024-06-01 03:00"}, Config{}, true},
+		{map[string]string{applyAt: "2024-06-01T05:00:00Z", expireAt: "2024-06-01T03:00:00Z"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestActive(t *testing.T) {
+	start := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	config := Config{ApplyAt: start, ExpireAt: end}
+
+	testCases := []struct {
+		now        time.Time
+		active     bool
+		nextChange time.Time
+	}{
+		{start.Add(-time.Minute), false, start},
+		{start, true, end},
+		{end.Add(-time.Second), true, end},
+		{end, false, time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		if active := config.Active(tc.now); active != tc.active {
+			t.Errorf("expected active %t at %v but returned %t", tc.active, tc.now, active)
+		}
+		next, ok := config.NextChange(tc.now)
+		if ok != !tc.nextChange.IsZero() || !next.Equal(tc.nextChange) {
+			t.Errorf("expected the next change %v at %v but returned %v", tc.nextChange, tc.now, next)
+		}
+	}
+
+	if !(Config{}).Active(start) {
+		t.Errorf("expected an Ingress without schedule to be active")
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutover := now.Add(3 * time.Hour)
+
+	testCases := []struct {
+		name     string
+		config   Config
+		other    Config
+		overlaps bool
+	}{
+		{"without schedule", Config{}, Config{}, true},
+		{"cutover", Config{ExpireAt: cutover}, Config{ApplyAt: cutover}, false},
+		{"applied before the expiration", Config{ExpireAt: cutover}, Config{ApplyAt: cutover.Add(-time.Minute)}, true},
+		{"expired", Config{ExpireAt: now.Add(-time.Minute)}, Config{}, false},
+		{"successive periods", Config{ApplyAt: now.Add(time.Hour), ExpireAt: cutover}, Config{ApplyAt: cutover}, false},
+		{"nested periods", Config{ApplyAt: now.Add(time.Hour), ExpireAt: cutover}, Config{ExpireAt: cutover.Add(time.Hour)}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if overlaps := tc.config.Overlaps(tc.other, now); overlaps != tc.overlaps {
+				t.Errorf("expected overlaps %t but returned %t", tc.overlaps, overlaps)
+			}
+			if overlaps := tc.other.Overlaps(tc.config, now); overlaps != tc.overlaps {
+				t.Errorf("expected overlaps %t in the other order but returned %t", tc.overlaps, overlaps)
+			}
+		})
+	}
+}
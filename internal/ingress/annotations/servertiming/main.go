@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servertiming
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	serverTimingAnnotation            = "server-timing"
+	serverTimingAllowOriginAnnotation = "server-timing-allow-origin"
+)
+
+// originRegex matches an origin allowed to read the timings, or *
+var originRegex = regexp.MustCompile(`^(\*|https?://[A-Za-z0-9.-]+(:[0-9]{1,5})?)$`)
+
+var serverTimingAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		serverTimingAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation adds a Server-Timing header with the upstream connect, header and response times, ` +
+				`the time spent in NGINX, the cache status and the number of retries to the responses`,
+		},
+		serverTimingAllowOriginAnnotation: {
+			Validator: validateOrigins,
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the comma separated origins, or *, allowed to read the Server-Timing header ` +
+				`of cross-origin responses in the Timing-Allow-Origin header`,
+		},
+	},
+}
+
+// Config contains the Server-Timing header added to the responses of a location
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// AllowOrigins are the origins of the Timing-Allow-Origin header
+	AllowOrigins []string `json:"allowOrigins,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	if c1.Enabled != c2.Enabled || len(c1.AllowOrigins) != len(c2.AllowOrigins) {
+		return false
+	}
+	for i := range c1.AllowOrigins {
+		if c1.AllowOrigins[i] != c2.AllowOrigins[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseOrigins parses the comma separated origins of the annotation
+func parseOrigins(value string) ([]string, error) {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if !originRegex.MatchString(origin) {
+			return nil, ing_errors.NewInvalidAnnotationContent(serverTimingAllowOriginAnnotation, origin)
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+func validateOrigins(value string) error {
+	_, err := parseOrigins(value)
+	return err
+}
+
+type serverTiming struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new Server-Timing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return serverTiming{
+		r:                r,
+		annotationConfig: serverTimingAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to add
+// the Server-Timing header to the responses
+func (a serverTiming) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	var err error
+	config.Enabled, err = parser.GetBoolAnnotation(serverTimingAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+	if !config.Enabled {
+		return config, nil
+	}
+
+	origins, err := parser.GetStringAnnotation(serverTimingAllowOriginAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+	config.AllowOrigins, err = parseOrigins(origins)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a serverTiming) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a serverTiming) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, serverTimingAnnotations.Annotations)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package servertiming
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enabled := parser.GetAnnotationWithPrefix(serverTimingAnnotation)
+	allowOrigin := parser.GetAnnotationWithPrefix(serverTimingAllowOriginAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{enabled: "true"}, Config{Enabled: true}, false},
+		{map[string]string{enabled: "false", allowOrigin: "*"}, Config{}, false},
+		{map[string]string{allowOrigin: "*"}, Config{}, false},
+		{map[string]string{enabled: "true", allowOrigin: "*"}, Config{Enabled: true, AllowOrigins: []string{"*"}}, false},
+		{
+			map[string]string{enabled: "true", allowOrigin: "https://www.example.com, http://localhost:8080"},
+			Config{Enabled: true, AllowOrigins: []string{"https://www.example.com", "http://localhost:8080"}},
+			false,
+		},
+		{map[string]string{enabled: "yes please"}, Config{}, true},
+		{map[string]string{enabled: "true", allowOrigin: "www.example.com"}, Config{}, true},
+		{map[string]string{enabled: "true", allowOrigin: "https://www.example.com/app"}, Config{}, true},
+		{map[string]string{enabled: "true", allowOrigin: "https://example.com;x"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
@@ -65,6 +65,13 @@ func (s serviceUpstream) Parse(ing *networking.Ingress) (interface{}, error) {
 	return val, nil
 }
 
+// Explicit returns true when the Ingress defines the service-upstream
+// annotation instead of following the service-upstream option of the ConfigMap
+func Explicit(ing *networking.Ingress) bool {
+	_, err := parser.GetBoolAnnotation(serviceUpstreamAnnotation, ing, serviceUpstreamAnnotations.Annotations)
+	return err == nil
+}
+
 func (s serviceUpstream) GetDocumentation() parser.AnnotationFields {
 	return s.annotationConfig.Annotations
 }
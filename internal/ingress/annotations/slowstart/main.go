@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slowstart
+
+import (
+	"regexp"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	slowStartDurationAnnotation   = "slow-start-duration"
+	slowStartAggressionAnnotation = "slow-start-aggression"
+)
+
+const defaultAggression = 1.0
+
+// validAggression matches a positive decimal number, like 1 or 1.5
+var validAggression = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+var slowStartAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		slowStartDurationAnnotation: {
+			Validator: parser.ValidateDuration,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the window, like 30s or 2m, during which the weight of a new endpoint of the backends of the Ingress ` +
+				`is ramped up from a minimal share to its full share of the traffic`,
+		},
+		slowStartAggressionAnnotation: {
+			Validator: parser.ValidateRegex(validAggression, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the curve of the slow start ramp. 1 ramps the weight linearly, ` +
+				`values above 1 give a new endpoint more traffic early in the window and values below 1 less`,
+		},
+	},
+}
+
+// Config contains how the weight of the new endpoints of a backend is ramped up
+type Config struct {
+	// Duration is the length of the ramp in seconds, 0 disables the slow start
+	Duration float64 `json:"duration,omitempty"`
+	// Aggression is the curve of the ramp, the weight of an endpoint is
+	// proportional to (elapsed / duration) ^ (1 / aggression)
+	Aggression float64 `json:"aggression,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type slowStart struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new slow start annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return slowStart{
+		r:                r,
+		annotationConfig: slowStartAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to ramp up the weight of the new endpoints of the backends
+func (a slowStart) Parse(ing *networking.Ingress) (interface{}, error) {
+	duration, err := parser.GetStringAnnotation(slowStartDurationAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+
+	window, err := time.ParseDuration(duration)
+	if err != nil || window < time.Second {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(slowStartDurationAnnotation, duration)
+	}
+
+	config := &Config{
+		Duration:   window.Seconds(),
+		Aggression: defaultAggression,
+	}
+
+	aggression, err := parser.GetFloatAnnotation(slowStartAggressionAnnotation, ing, a.annotationConfig.Annotations)
+	switch {
+	case err == nil:
+		if aggression <= 0 {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(slowStartAggressionAnnotation, aggression)
+		}
+		config.Aggression = float64(aggression)
+	case !ing_errors.IsMissingAnnotations(err):
+		return &Config{}, err
+	}
+
+	return config, nil
+}
+
+func (a slowStart) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a slowStart) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, slowStartAnnotations.Annotations)
+}
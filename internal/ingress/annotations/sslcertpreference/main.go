@@ -0,0 +1,143 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sslcertpreference
+
+import (
+	"fmt"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	sslCertificatePreferenceAnnotation = "ssl-certificate-preference"
+	sslCertificateSecretAnnotation     = "ssl-certificate-secret" //#nosec G101
+)
+
+const (
+	// PreferExact selects the certificates containing the host name
+	// over the ones matching it with a wildcard
+	PreferExact = "exact"
+	// PreferWildcard selects the certificates matching the host name
+	// with a wildcard over the ones containing it
+	PreferWildcard = "wildcard"
+)
+
+// Preferences contains the valid certificate selection preferences
+var Preferences = []string{PreferExact, PreferWildcard}
+
+var sslCertPreferenceAnnotations = parser.Annotation{
+	Group: "tls",
+	Annotations: parser.AnnotationFields{
+		sslCertificatePreferenceAnnotation: {
+			Validator: parser.ValidateOptions(Preferences, false, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines which certificate of the TLS section is used when both an exact and a wildcard certificate match a host. ` +
+				`It can be "exact" or "wildcard" and overrides the ssl-certificate-preference ConfigMap option.`,
+		},
+		sslCertificateSecretAnnotation: {
+			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines the Secret, in the format <namespace>/<name>, whose certificate must be used for the hosts of the Ingress. ` +
+				`The default certificate is used when the Secret does not contain a valid certificate for a host.`,
+		},
+	},
+}
+
+// Config contains the certificate selection preference of an Ingress
+type Config struct {
+	Preference string `json:"preference,omitempty"`
+	// Secret is the namespace/name of the Secret that must be used
+	Secret string `json:"secret,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return c1.Preference == c2.Preference && c1.Secret == c2.Secret
+}
+
+type sslCertPreference struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new certificate selection preference annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslCertPreference{
+		r:                r,
+		annotationConfig: sslCertPreferenceAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to select the certificate of the hosts
+func (a sslCertPreference) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	preference, err := parser.GetStringAnnotation(sslCertificatePreferenceAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	config.Preference = strings.ToLower(strings.TrimSpace(preference))
+
+	secret, err := parser.GetStringAnnotation(sslCertificateSecretAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+
+	ns, name, err := cache.SplitMetaNamespaceKey(secret)
+	if err != nil || name == "" {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(sslCertificateSecretAnnotation, secret)
+	}
+	if ns == "" {
+		ns = ing.Namespace
+	}
+
+	if !a.r.GetSecurityConfiguration().AllowCrossNamespaceResources && ns != ing.Namespace {
+		return &Config{}, fmt.Errorf("cross namespace usage of secrets is not allowed")
+	}
+
+	config.Secret = fmt.Sprintf("%v/%v", ns, name)
+
+	return config, nil
+}
+
+func (a sslCertPreference) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a sslCertPreference) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, sslCertPreferenceAnnotations.Annotations)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sslpassthroughrouting
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	sslPassthroughTimeoutAnnotation  = "ssl-passthrough-timeout"
+	sslPassthroughFallbackAnnotation = "ssl-passthrough-fallback"
+)
+
+var sslPassthroughRoutingAnnotations = parser.Annotation{
+	Group: "tls",
+	Annotations: parser.AnnotationFields{
+		sslPassthroughTimeoutAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the timeout in seconds to connect to the backend and the time after which the idle ` +
+				`SSL passthrough connections of the host are closed. It requires the ssl-passthrough annotation.`,
+		},
+		sslPassthroughFallbackAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation passes the TLS connections whose SNI matches no host through to the backend of the Ingress ` +
+				`when the ssl-passthrough-unmatched-sni ConfigMap option is "fallback". It requires the ssl-passthrough annotation.`,
+		},
+	},
+}
+
+// Config contains the routing of the SSL passthrough connections of a host
+type Config struct {
+	// Timeout in seconds of the connections, 0 means no timeout
+	Timeout int `json:"timeout,omitempty"`
+	// Fallback indicates the connections with an unmatched SNI are passed through to the host
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return c1.Timeout == c2.Timeout && c1.Fallback == c2.Fallback
+}
+
+type sslPassthroughRouting struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new SSL passthrough routing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslPassthroughRouting{
+		r:                r,
+		annotationConfig: sslPassthroughRoutingAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to route the SSL passthrough connections
+func (a sslPassthroughRouting) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	timeout, err := parser.GetIntAnnotation(sslPassthroughTimeoutAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if timeout < 0 {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(sslPassthroughTimeoutAnnotation, timeout)
+	}
+	config.Timeout = timeout
+
+	fallback, err := parser.GetBoolAnnotation(sslPassthroughFallbackAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	config.Fallback = fallback
+
+	return config, nil
+}
+
+func (a sslPassthroughRouting) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a sslPassthroughRouting) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, sslPassthroughRoutingAnnotations.Annotations)
+}
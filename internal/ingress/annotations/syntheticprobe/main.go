@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntheticprobe
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	syntheticProbeAnnotation               = "synthetic-probe"
+	syntheticProbePathAnnotation           = "synthetic-probe-path"
+	syntheticProbeExpectedStatusAnnotation = "synthetic-probe-expected-status"
+)
+
+var (
+	regexProbePath      = regexp.MustCompile(`^/[A-Za-z0-9_.\-~/%?=&]*$`)
+	regexExpectedStatus = regexp.MustCompile(`^[1-5][0-9]{2}(,[1-5][0-9]{2})*$`)
+)
+
+var syntheticProbeAnnotations = parser.Annotation{
+	Group: "metrics",
+	Annotations: parser.AnnotationFields{
+		syntheticProbeAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation enables the synthetic probes of the hosts and paths of the Ingress, sent periodically by the controller ` +
+				`through NGINX when the controller is started with the synthetic-probe-interval flag.`,
+		},
+		syntheticProbePathAnnotation: {
+			Validator: parser.ValidateRegex(regexProbePath, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the path, with an optional query string, probed on every host of the Ingress ` +
+				`instead of the paths of the Ingress.`,
+		},
+		syntheticProbeExpectedStatusAnnotation: {
+			Validator: parser.ValidateRegex(regexExpectedStatus, true),
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation defines a comma separated list of the status codes of the successful probes. ` +
+				`By default the probes with a status lower than 400 are successful.`,
+		},
+	},
+}
+
+// Config contains the synthetic probes of an Ingress
+type Config struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Path probed instead of the paths of the Ingress
+	Path string `json:"path,omitempty"`
+	// ExpectedStatus contains the status codes of the successful probes
+	ExpectedStatus []int `json:"expectedStatus,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled || c1.Path != c2.Path {
+		return false
+	}
+	if len(c1.ExpectedStatus) != len(c2.ExpectedStatus) {
+		return false
+	}
+	for i := range c1.ExpectedStatus {
+		if c1.ExpectedStatus[i] != c2.ExpectedStatus[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Success returns true when status is the status of a successful probe
+func (c *Config) Success(status int) bool {
+	if len(c.ExpectedStatus) == 0 {
+		return status < 400
+	}
+
+	for _, s := range c.ExpectedStatus {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+type syntheticProbe struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new synthetic probe annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return syntheticProbe{
+		r:                r,
+		annotationConfig: syntheticProbeAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to probe the hosts and paths of the Ingress
+func (a syntheticProbe) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	enabled, err := parser.GetBoolAnnotation(syntheticProbeAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+		return &Config{}, err
+	}
+	config.Enabled = enabled
+
+	path, err := parser.GetStringAnnotation(syntheticProbePathAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if path != "" && !regexProbePath.MatchString(path) {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent(syntheticProbePathAnnotation, path)
+	}
+	config.Path = path
+
+	status, err := parser.GetStringAnnotation(syntheticProbeExpectedStatusAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if status != "" {
+		if !regexExpectedStatus.MatchString(status) {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(syntheticProbeExpectedStatusAnnotation, status)
+		}
+		for _, s := range strings.Split(status, ",") {
+			code, err := strconv.Atoi(s)
+			if err != nil {
+				return &Config{}, ing_errors.NewInvalidAnnotationContent(syntheticProbeExpectedStatusAnnotation, status)
+			}
+			config.ExpectedStatus = append(config.ExpectedStatus, code)
+		}
+	}
+
+	return config, nil
+}
+
+func (a syntheticProbe) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a syntheticProbe) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, syntheticProbeAnnotations.Annotations)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syntheticprobe
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	probe := parser.GetAnnotationWithPrefix(syntheticProbeAnnotation)
+	path := parser.GetAnnotationWithPrefix(syntheticProbePathAnnotation)
+	status := parser.GetAnnotationWithPrefix(syntheticProbeExpectedStatusAnnotation)
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{probe: "true"}, Config{Enabled: true}, false},
+		{map[string]string{probe: "false", path: "/healthz"}, Config{Path: "/healthz"}, false},
+		{map[string]string{probe: "true", path: "/healthz?deep=1"}, Config{Enabled: true, Path: "/healthz?deep=1"}, false},
+		{map[string]string{probe: "true", path: "healthz"}, Config{}, true},
+		{map[string]string{probe: "true", path: "/health z"}, Config{}, true},
+		{map[string]string{probe: "true", status: "200,204"}, Config{Enabled: true, ExpectedStatus: []int{200, 204}}, false},
+		{map[string]string{probe: "true", status: "200,2xx"}, Config{}, true},
+		{map[string]string{probe: "true", status: "600"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestSuccess(t *testing.T) {
+	testCases := []struct {
+		expectedStatus []int
+		status         int
+		expected       bool
+	}{
+		{nil, 200, true},
+		{nil, 302, true},
+		{nil, 404, false},
+		{nil, 502, false},
+		{[]int{401}, 401, true},
+		{[]int{401}, 200, false},
+	}
+
+	for _, testCase := range testCases {
+		c := &Config{Enabled: true, ExpectedStatus: testCase.expectedStatus}
+		if success := c.Success(testCase.status); success != testCase.expected {
+			t.Errorf("expected %v for status %v with expected status %v but got %v", testCase.expected, testCase.status, testCase.expectedStatus, success)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamkeepalive
+
+import (
+	"fmt"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/backendprotocol"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	upstreamKeepaliveConnectionsAnnotation = "upstream-keepalive-connections"
+	upstreamKeepaliveTimeoutAnnotation     = "upstream-keepalive-timeout"
+	upstreamKeepaliveRequestsAnnotation    = "upstream-keepalive-requests"
+	ntlmAnnotation                         = "ntlm"
+)
+
+var upstreamKeepaliveAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		upstreamKeepaliveConnectionsAnnotation: {
+			Validator: parser.ValidateInt,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of idle keepalive connections to the endpoints of the backends of the Ingress ` +
+				`kept by each worker process. 0 disables the keepalive connections`,
+		},
+		upstreamKeepaliveTimeoutAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the time in seconds an idle keepalive connection to the endpoints of the backends of the Ingress stays open`,
+		},
+		upstreamKeepaliveRequestsAnnotation: {
+			Validator:     parser.ValidateInt,
+			Scope:         parser.AnnotationScopeIngress,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation sets the maximum number of requests sent through a keepalive connection to the endpoints of the backends of the Ingress`,
+		},
+		ntlmAnnotation: {
+			Validator: parser.ValidateBool,
+			Scope:     parser.AnnotationScopeIngress,
+			Risk:      parser.AnnotationRiskLow,
+			Documentation: `This annotation pins each client connection to an endpoint and to its own keepalive connections to it, ` +
+				`as required by the NTLM and Negotiate authentications bound to the connection. Only the HTTP backend protocol is supported`,
+		},
+	},
+}
+
+// Config contains the keepalive connections to the endpoints of the backends
+// of an Ingress. The values not set by the annotations are the ones of the ConfigMap.
+type Config struct {
+	Enabled     bool `json:"enabled"`
+	Connections int  `json:"connections"`
+	Timeout     int  `json:"timeout"`
+	Requests    int  `json:"requests"`
+	NTLM        bool `json:"ntlm,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type upstreamKeepalive struct {
+	r                resolver.Resolver
+	annotationConfig parser.Annotation
+}
+
+// NewParser creates a new upstream keepalive annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamKeepalive{
+		r:                r,
+		annotationConfig: upstreamKeepaliveAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to configure the keepalive connections to the backends
+func (a upstreamKeepalive) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+	config := &Config{
+		Connections: defBackend.UpstreamKeepaliveConnections,
+		Timeout:     defBackend.UpstreamKeepaliveTimeout,
+		Requests:    defBackend.UpstreamKeepaliveRequests,
+	}
+
+	for _, value := range []struct {
+		annotation string
+		target     *int
+		min        int
+	}{
+		{upstreamKeepaliveConnectionsAnnotation, &config.Connections, 0},
+		{upstreamKeepaliveTimeoutAnnotation, &config.Timeout, 1},
+		{upstreamKeepaliveRequestsAnnotation, &config.Requests, 1},
+	} {
+		v, err := parser.GetIntAnnotation(value.annotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return &Config{}, err
+		}
+		if v < value.min {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(value.annotation, v)
+		}
+
+		*value.target = v
+		config.Enabled = true
+	}
+
+	ntlm, err := parser.GetBoolAnnotation(ntlmAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, err
+	}
+	if ntlm {
+		if config.Connections == 0 {
+			return &Config{}, ing_errors.NewInvalidAnnotationConfiguration(ntlmAnnotation, "requires keepalive connections to the endpoints")
+		}
+		// the pinned connections are told apart by the host of the peer,
+		// used as SNI by the encrypted protocols
+		proto, err := backendprotocol.NewParser(a.r).Parse(ing)
+		if err != nil {
+			return &Config{}, err
+		}
+		if !strings.EqualFold(proto.(string), "HTTP") {
+			return &Config{}, ing_errors.NewInvalidAnnotationConfiguration(ntlmAnnotation, fmt.Sprintf("does not support the backend protocol %v", proto))
+		}
+
+		config.NTLM = true
+		config.Enabled = true
+	}
+
+	if !config.Enabled {
+		return &Config{}, nil
+	}
+
+	return config, nil
+}
+
+func (a upstreamKeepalive) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a upstreamKeepalive) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, upstreamKeepaliveAnnotations.Annotations)
+}
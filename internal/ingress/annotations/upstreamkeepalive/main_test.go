@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamkeepalive
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockBackend struct {
+	resolver.Mock
+}
+
+func (m mockBackend) GetDefaultBackend() defaults.Backend {
+	return defaults.Backend{
+		UpstreamKeepaliveConnections: 320,
+		UpstreamKeepaliveTimeout:     60,
+		UpstreamKeepaliveRequests:    10000,
+	}
+}
+
+func TestParse(t *testing.T) {
+	connections := parser.GetAnnotationWithPrefix(upstreamKeepaliveConnectionsAnnotation)
+	timeout := parser.GetAnnotationWithPrefix(upstreamKeepaliveTimeoutAnnotation)
+	requests := parser.GetAnnotationWithPrefix(upstreamKeepaliveRequestsAnnotation)
+	ntlm := parser.GetAnnotationWithPrefix(ntlmAnnotation)
+	backendProtocol := parser.GetAnnotationWithPrefix("backend-protocol")
+
+	ap := NewParser(mockBackend{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expectErr   bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{connections: "32"}, Config{Enabled: true, Connections: 32, Timeout: 60, Requests: 10000}, false},
+		{map[string]string{connections: "0"}, Config{Enabled: true, Connections: 0, Timeout: 60, Requests: 10000}, false},
+		{map[string]string{timeout: "5", requests: "100"}, Config{Enabled: true, Connections: 320, Timeout: 5, Requests: 100}, false},
+		{map[string]string{connections: "-1"}, Config{}, true},
+		{map[string]string{timeout: "0"}, Config{}, true},
+		{map[string]string{requests: "many"}, Config{}, true},
+		{map[string]string{ntlm: "true"}, Config{Enabled: true, Connections: 320, Timeout: 60, Requests: 10000, NTLM: true}, false},
+		{map[string]string{ntlm: "false"}, Config{}, false},
+		{map[string]string{ntlm: "true", backendProtocol: "http"}, Config{Enabled: true, Connections: 320, Timeout: 60, Requests: 10000, NTLM: true}, false},
+		{map[string]string{ntlm: "true", backendProtocol: "HTTPS"}, Config{}, true},
+		{map[string]string{ntlm: "true", connections: "0"}, Config{}, true},
+		{map[string]string{ntlm: "maybe"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
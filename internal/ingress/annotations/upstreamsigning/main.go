@@ -0,0 +1,258 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamsigning
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/pkg/util/file"
+)
+
+const (
+	upstreamSigningMethodAnnotation  = "upstream-signing-method"
+	upstreamSigningSecretAnnotation  = "upstream-signing-secret" //#nosec G101
+	upstreamSigningRegionAnnotation  = "upstream-signing-aws-region"
+	upstreamSigningServiceAnnotation = "upstream-signing-aws-service"
+)
+
+const (
+	// MethodAWSSigV4 signs the requests with AWS Signature Version 4
+	MethodAWSSigV4 = "aws-sigv4"
+	// MethodHMACSHA256 signs the requests with the hmac-sha256
+	// algorithm of the HTTP Signatures draft
+	MethodHMACSHA256 = "hmac-sha256"
+
+	secretAccessKeyID     = "access-key-id"
+	secretSecretAccessKey = "secret-access-key" //#nosec G101
+	secretSessionToken    = "session-token"     //#nosec G101
+	secretKey             = "key"
+	secretKeyID           = "key-id"
+)
+
+var (
+	methodRegex = regexp.MustCompile(`^(aws-sigv4|hmac-sha256)$`)
+	awsRegex    = regexp.MustCompile(`^[a-z0-9-]+$`)
+	keyIDRegex  = regexp.MustCompile(`^[A-Za-z0-9._/+=@-]+$`)
+)
+
+var upstreamSigningAnnotations = parser.Annotation{
+	Group: "backend",
+	Annotations: parser.AnnotationFields{
+		upstreamSigningMethodAnnotation: {
+			Validator:     parser.ValidateRegex(methodRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation enables the signing of the requests sent to the upstream, with aws-sigv4 or hmac-sha256`,
+		},
+		upstreamSigningSecretAnnotation: {
+			Validator: parser.ValidateRegex(parser.BasicCharsRegex, true),
+			Scope:     parser.AnnotationScopeLocation,
+			Risk:      parser.AnnotationRiskMedium,
+			Documentation: `This annotation defines the Secret, in the format <namespace>/<name>, with the credentials signing the requests. ` +
+				`aws-sigv4 requires the keys "access-key-id" and "secret-access-key", and accepts "session-token". ` +
+				`hmac-sha256 requires the key "key" and accepts "key-id"`,
+		},
+		upstreamSigningRegionAnnotation: {
+			Validator:     parser.ValidateRegex(awsRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the AWS region of the upstream, like us-east-1`,
+		},
+		upstreamSigningServiceAnnotation: {
+			Validator:     parser.ValidateRegex(awsRegex, true),
+			Scope:         parser.AnnotationScopeLocation,
+			Risk:          parser.AnnotationRiskLow,
+			Documentation: `This annotation defines the AWS service of the upstream, like s3, es or execute-api`,
+		},
+	},
+}
+
+// Config contains the signing of the requests sent to the upstream of a location
+type Config struct {
+	Method  string `json:"method,omitempty"`
+	Secret  string `json:"secret,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Service string `json:"service,omitempty"`
+	// CredentialsFile contains the credentials read from the Secret, in JSON
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+	CredentialsSHA  string `json:"credentialsSha,omitempty"`
+}
+
+// credentials are written to the file read by NGINX
+type credentials struct {
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+	Key             string `json:"key,omitempty"`
+	KeyID           string `json:"key_id,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type upstreamSigning struct {
+	r                 resolver.Resolver
+	credentialsFolder string
+	annotationConfig  parser.Annotation
+}
+
+// NewParser creates a new upstream request signing annotation parser
+func NewParser(credentialsFolder string, r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamSigning{
+		r:                 r,
+		credentialsFolder: credentialsFolder,
+		annotationConfig:  upstreamSigningAnnotations,
+	}
+}
+
+// Parse parses the annotations contained in the ingress rule used to sign
+// the requests sent to the upstream and writes the credentials to a file
+// read by NGINX
+func (a upstreamSigning) Parse(ing *networking.Ingress) (interface{}, error) {
+	method, err := parser.GetStringAnnotation(upstreamSigningMethodAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return &Config{}, nil
+		}
+		return &Config{}, err
+	}
+
+	config := &Config{Method: method}
+
+	if method == MethodAWSSigV4 {
+		config.Region, err = parser.GetStringAnnotation(upstreamSigningRegionAnnotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			return &Config{}, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("%v requires the annotation %v: %w", MethodAWSSigV4, upstreamSigningRegionAnnotation, err),
+			}
+		}
+		config.Service, err = parser.GetStringAnnotation(upstreamSigningServiceAnnotation, ing, a.annotationConfig.Annotations)
+		if err != nil {
+			return &Config{}, ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("%v requires the annotation %v: %w", MethodAWSSigV4, upstreamSigningServiceAnnotation, err),
+			}
+		}
+	}
+
+	s, err := parser.GetStringAnnotation(upstreamSigningSecretAnnotation, ing, a.annotationConfig.Annotations)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading secret name from annotation: %w", err),
+		}
+	}
+
+	sns, sname, err := cache.SplitMetaNamespaceKey(s)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("error reading secret name from annotation: %w", err),
+		}
+	}
+	if sns == "" {
+		sns = ing.Namespace
+	}
+	if !a.r.GetSecurityConfiguration().AllowCrossNamespaceResources && sns != ing.Namespace {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("cross namespace usage of secrets is not allowed"),
+		}
+	}
+
+	config.Secret = fmt.Sprintf("%v/%v", sns, sname)
+	secret, err := a.r.GetSecret(config.Secret)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error reading secret %s: %w", config.Secret, err),
+		}
+	}
+
+	required := func(key string) (string, error) {
+		value := string(secret.Data[key])
+		if value == "" {
+			return "", ing_errors.LocationDeniedError{
+				Reason: fmt.Errorf("the secret %s does not contain a key with value %s", config.Secret, key),
+			}
+		}
+		return value, nil
+	}
+
+	creds := credentials{}
+	switch method {
+	case MethodAWSSigV4:
+		if creds.AccessKeyID, err = required(secretAccessKeyID); err != nil {
+			return &Config{}, err
+		}
+		if creds.SecretAccessKey, err = required(secretSecretAccessKey); err != nil {
+			return &Config{}, err
+		}
+		creds.SessionToken = string(secret.Data[secretSessionToken])
+	case MethodHMACSHA256:
+		if creds.Key, err = required(secretKey); err != nil {
+			return &Config{}, err
+		}
+		creds.KeyID = string(secret.Data[secretKeyID])
+		if creds.KeyID == "" {
+			sum := sha256.Sum256([]byte(creds.Key))
+			creds.KeyID = hex.EncodeToString(sum[:4])
+		}
+		if !keyIDRegex.MatchString(creds.KeyID) {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent(upstreamSigningSecretAnnotation, secretKeyID)
+		}
+	}
+
+	content, err := json.Marshal(creds)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	config.CredentialsFile = fmt.Sprintf("%v/%v-%v-%v.signing", a.credentialsFolder, ing.GetNamespace(), ing.UID, secret.UID)
+	if err := os.WriteFile(config.CredentialsFile, content, file.ReadWriteByUser); err != nil {
+		return &Config{}, ing_errors.LocationDeniedError{
+			Reason: fmt.Errorf("unexpected error creating upstream signing credentials file: %w", err),
+		}
+	}
+	config.CredentialsSHA = file.SHA1(config.CredentialsFile)
+
+	return config, nil
+}
+
+func (a upstreamSigning) GetDocumentation() parser.AnnotationFields {
+	return a.annotationConfig.Annotations
+}
+
+func (a upstreamSigning) Validate(anns map[string]string) error {
+	maxrisk := parser.StringRiskToRisk(a.r.GetSecurityConfiguration().AnnotationsRiskLevel)
+	return parser.CheckAnnotationRisk(anns, maxrisk, upstreamSigningAnnotations.Annotations)
+}
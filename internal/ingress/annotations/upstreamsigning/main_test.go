@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamsigning
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	var data map[string][]byte
+	switch name {
+	case "default/aws":
+		data = map[string][]byte{secretAccessKeyID: []byte("AKIDEXAMPLE"), secretSecretAccessKey: []byte("secret")}
+	case "default/aws-session":
+		data = map[string][]byte{secretAccessKeyID: []byte("AKIDEXAMPLE"), secretSecretAccessKey: []byte("secret"), secretSessionToken: []byte("token")}
+	case "default/hmac":
+		data = map[string][]byte{secretKey: []byte("secret")}
+	case "default/hmac-with-id":
+		data = map[string][]byte{secretKey: []byte("secret"), secretKeyID: []byte("partner-1")}
+	case "default/invalid-id":
+		data = map[string][]byte{secretKey: []byte("secret"), secretKeyID: []byte(`a"b`)}
+	case "default/empty":
+		data = map[string][]byte{}
+	default:
+		return nil, fmt.Errorf("there is no secret with name %v", name)
+	}
+
+	return &api.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: name, UID: "uid"},
+		Data:       data,
+	}, nil
+}
+
+func TestParse(t *testing.T) {
+	method := parser.GetAnnotationWithPrefix(upstreamSigningMethodAnnotation)
+	secret := parser.GetAnnotationWithPrefix(upstreamSigningSecretAnnotation)
+	region := parser.GetAnnotationWithPrefix(upstreamSigningRegionAnnotation)
+	service := parser.GetAnnotationWithPrefix(upstreamSigningServiceAnnotation)
+
+	dir := t.TempDir()
+	ap := NewParser(dir, mockSecret{})
+	credentialsFile := fmt.Sprintf("%v/default-ing-uid.signing", dir)
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		credentials credentials
+		expectErr   bool
+	}{
+		{nil, Config{}, credentials{}, false},
+		{
+			map[string]string{method: "aws-sigv4", secret: "aws", region: "us-east-1", service: "s3"},
+			Config{Method: MethodAWSSigV4, Secret: "default/aws", Region: "us-east-1", Service: "s3", CredentialsFile: credentialsFile},
+			credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+			false,
+		},
+		{
+			map[string]string{method: "aws-sigv4", secret: "default/aws-session", region: "eu-west-1", service: "es"},
+			Config{Method: MethodAWSSigV4, Secret: "default/aws-session", Region: "eu-west-1", Service: "es", CredentialsFile: credentialsFile},
+			credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"},
+			false,
+		},
+		{
+			map[string]string{method: "hmac-sha256", secret: "hmac"},
+			Config{Method: MethodHMACSHA256, Secret: "default/hmac", CredentialsFile: credentialsFile},
+			credentials{Key: "secret", KeyID: "2bb80d53"},
+			false,
+		},
+		{
+			map[string]string{method: "hmac-sha256", secret: "hmac-with-id"},
+			Config{Method: MethodHMACSHA256, Secret: "default/hmac-with-id", CredentialsFile: credentialsFile},
+			credentials{Key: "secret", KeyID: "partner-1"},
+			false,
+		},
+		{map[string]string{method: "aws-sigv2", secret: "aws"}, Config{}, credentials{}, true},
+		{map[string]string{method: "aws-sigv4", secret: "aws", region: "us-east-1"}, Config{}, credentials{}, true},
+		{map[string]string{method: "aws-sigv4", secret: "aws", service: "s3"}, Config{}, credentials{}, true},
+		{map[string]string{method: "aws-sigv4", secret: "hmac", region: "us-east-1", service: "s3"}, Config{}, credentials{}, true},
+		{map[string]string{method: "hmac-sha256"}, Config{}, credentials{}, true},
+		{map[string]string{method: "hmac-sha256", secret: "other/hmac"}, Config{}, credentials{}, true},
+		{map[string]string{method: "hmac-sha256", secret: "missing"}, Config{}, credentials{}, true},
+		{map[string]string{method: "hmac-sha256", secret: "empty"}, Config{}, credentials{}, true},
+		{map[string]string{method: "hmac-sha256", secret: "invalid-id"}, Config{}, credentials{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+			UID:       "ing",
+		},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("expected error %t but got %v for annotations %v", testCase.expectErr, err, testCase.annotations)
+		}
+		if testCase.expectErr {
+			continue
+		}
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type")
+		}
+		// the SHA depends on the content of the credentials file
+		config.CredentialsSHA = ""
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %v", testCase.expected, config, testCase.annotations)
+		}
+		if config.Method == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(config.CredentialsFile)
+		if err != nil {
+			t.Fatalf("unexpected error reading the credentials file: %v", err)
+		}
+		creds := credentials{}
+		if err := json.Unmarshal(content, &creds); err != nil {
+			t.Fatalf("unexpected error decoding the credentials file: %v", err)
+		}
+		if creds != testCase.credentials {
+			t.Errorf("expected the credentials %+v but got %+v", testCase.credentials, creds)
+		}
+	}
+}
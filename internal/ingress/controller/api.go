@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// ConfigurationAPIPath is the path of the read-only configuration API
+const ConfigurationAPIPath = "/api/v1/configuration"
+
+// IngressUsageAPIPath is the path of the usage of the Ingresses, served
+// with the configuration API
+const IngressUsageAPIPath = "/api/v1/usage/ingresses"
+
+// RunningConfiguration returns a copy of the configuration running in NGINX
+// without the private keys of the SSL certificates and the code of the Lua
+// plugins
+func (n *NGINXController) RunningConfiguration() *ingress.Configuration {
+	n.runningConfigLock.RLock()
+	defer n.runningConfigLock.RUnlock()
+
+	if n.runningConfig == nil {
+		return &ingress.Configuration{}
+	}
+
+	cfg := *n.runningConfig
+	cfg.DefaultSSLCertificate = nil
+	cfg.LuaPlugins = make([]ingress.LuaPlugin, 0, len(n.runningConfig.LuaPlugins))
+	for _, plugin := range n.runningConfig.LuaPlugins {
+		plugin.Code = ""
+		cfg.LuaPlugins = append(cfg.LuaPlugins, plugin)
+	}
+	cfg.Servers = make([]*ingress.Server, 0, len(n.runningConfig.Servers))
+	for _, server := range n.runningConfig.Servers {
+		s := *server
+		if s.SSLCert != nil {
+			cert := *s.SSLCert
+			cert.PemCertKey = ""
+			s.SSLCert = &cert
+		}
+		cfg.Servers = append(cfg.Servers, &s)
+	}
+
+	return &cfg
+}
+
+// ConfigurationAPIHandler returns the handler of the read-only configuration
+// API. The handler does not authenticate the requests.
+//
+//	GET /api/v1/configuration           the complete running configuration
+//	GET /api/v1/configuration/servers   the servers and their locations
+//	GET /api/v1/configuration/backends  the upstreams and their endpoints
+//	GET /api/v1/configuration/ingresses/{namespace}/{name}
+//	                                    the server and location blocks of an Ingress
+//	GET /api/v1/usage/ingresses         the bytes received and sent by every Ingress
+//	                                    by hour, during the last 24 hours
+func (n *NGINXController) ConfigurationAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(ConfigurationAPIPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, n.RunningConfiguration())
+	})
+	mux.HandleFunc(ConfigurationAPIPath+"/servers", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, n.RunningConfiguration().Servers)
+	})
+	mux.HandleFunc(ConfigurationAPIPath+"/backends", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, n.RunningConfiguration().Backends)
+	})
+	mux.HandleFunc(ConfigurationAPIPath+"/ingresses/{namespace}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, ingressExpansion(r.PathValue("namespace"), r.PathValue("name"), n.RunningConfiguration().Servers))
+	})
+	mux.HandleFunc(IngressUsageAPIPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, n.metricCollector.IngressUsage())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.ErrorS(err, "Error encoding configuration API response")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/metrics"
+)
+
+func TestConfigurationAPI(t *testing.T) {
+	n := &NGINXController{
+		metricCollector: metric.DummyCollector{},
+		runningConfig: &ingress.Configuration{
+			Backends: []*ingress.Backend{
+				{
+					Name:      "default-echo-80",
+					Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+				},
+			},
+			Servers: []*ingress.Server{
+				{
+					Hostname: "example.com",
+					SSLCert:  &ingress.SSLCert{Name: "tls", PemCertKey: "private key"},
+					Locations: []*ingress.Location{
+						{Path: "/", Backend: "default-echo-80"},
+					},
+				},
+			},
+		},
+	}
+
+	handler := metrics.RequireBearerToken("secret", n.ConfigurationAPIHandler())
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}{
+		{"without token", http.MethodGet, ConfigurationAPIPath, "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, ConfigurationAPIPath, "invalid", http.StatusUnauthorized},
+		{"configuration", http.MethodGet, ConfigurationAPIPath, "secret", http.StatusOK},
+		{"servers", http.MethodGet, ConfigurationAPIPath + "/servers", "secret", http.StatusOK},
+		{"backends", http.MethodGet, ConfigurationAPIPath + "/backends", "secret", http.StatusOK},
+		{"ingress expansion", http.MethodGet, ConfigurationAPIPath + "/ingresses/default/echo", "secret", http.StatusOK},
+		{"ingress usage", http.MethodGet, IngressUsageAPIPath, "secret", http.StatusOK},
+		{"unknown resource", http.MethodGet, ConfigurationAPIPath + "/unknown", "secret", http.StatusNotFound},
+		{"read-only", http.MethodPost, ConfigurationAPIPath, "secret", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %v but got %v", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, ConfigurationAPIPath, http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	cfg := &ingress.Configuration{}
+	if err := json.Unmarshal(w.Body.Bytes(), cfg); err != nil {
+		t.Fatalf("unexpected error decoding the configuration: %v", err)
+	}
+	if len(cfg.Servers) != 1 || cfg.Servers[0].Hostname != "example.com" {
+		t.Fatalf("expected the server example.com but got %+v", cfg.Servers)
+	}
+	if cfg.Servers[0].SSLCert.PemCertKey != "" {
+		t.Errorf("expected the private key to be removed")
+	}
+	if n.runningConfig.Servers[0].SSLCert.PemCertKey == "" {
+		t.Errorf("expected the running configuration to keep the private key")
+	}
+	if len(cfg.Backends) != 1 || len(cfg.Backends[0].Endpoints) != 1 {
+		t.Errorf("expected the backend endpoints but got %+v", cfg.Backends)
+	}
+}
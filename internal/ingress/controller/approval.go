@@ -0,0 +1,588 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/changeapproval"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// ChangeApprovalAPIPath is the path of the API approving the high-impact
+// changes of the Ingresses staged by the controller
+const ChangeApprovalAPIPath = "/api/v1/changes"
+
+const (
+	// changeApprovalStagedKey is the key of the change approval ConfigMap
+	// containing the JSON object of the staged changes, by Ingress
+	changeApprovalStagedKey = "staged"
+	// changeApprovalApprovedKey is the key of the change approval ConfigMap
+	// containing the JSON object of the approved changes, by Ingress
+	changeApprovalApprovedKey = "approved"
+)
+
+// approvalRetention is the time the approvals of the deleted Ingresses are
+// kept in the change approval ConfigMap, for every replica to apply them
+const approvalRetention = time.Hour
+
+// StagedChange is a high-impact change of an Ingress not applied until it is
+// approved, the previous version of the Ingress being served meanwhile
+type StagedChange struct {
+	// Ingress is the namespace and name of the Ingress
+	Ingress string `json:"ingress"`
+	// ID identifies the staged version of the Ingress
+	ID string `json:"id"`
+	// Reasons describes why the change is high-impact
+	Reasons []string `json:"reasons"`
+	// Since is the time the change was staged
+	Since time.Time `json:"since"`
+	// AutoApplyAt is the time the change is applied without approval, nil
+	// when the change waits for an approval
+	AutoApplyAt *time.Time `json:"autoApplyAt,omitempty"`
+}
+
+// sharedStagedChange is a staged change in the change approval ConfigMap,
+// with the applied version of the Ingress served by all the replicas until
+// the change is approved
+type sharedStagedChange struct {
+	StagedChange
+	Applied      networking.Ingress `json:"applied"`
+	IngressClass string             `json:"ingressClass,omitempty"`
+}
+
+// approvedChange is a staged change approved with the change approval API
+type approvedChange struct {
+	ID         string    `json:"id"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// parseChangeApprovalConfigMap returns the staged and the approved changes
+// of the change approval ConfigMap
+func parseChangeApprovalConfigMap(cm *apiv1.ConfigMap) (map[string]*sharedStagedChange, map[string]approvedChange, error) {
+	staged := map[string]*sharedStagedChange{}
+	approved := map[string]approvedChange{}
+	if cm == nil {
+		return staged, approved, nil
+	}
+
+	if data := cm.Data[changeApprovalStagedKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &staged); err != nil {
+			return nil, nil, err
+		}
+	}
+	if data := cm.Data[changeApprovalApprovedKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &approved); err != nil {
+			return nil, nil, err
+		}
+	}
+	return staged, approved, nil
+}
+
+// getSharedChangeApprovals returns the staged and the approved changes of
+// the change approval ConfigMap, nil when the replica does not share them
+func (n *NGINXController) getSharedChangeApprovals() (map[string]*sharedStagedChange, map[string]approvedChange) {
+	if n.cfg.ChangeApprovalConfigMapName == "" {
+		return nil, nil
+	}
+
+	cm, err := n.store.GetConfigMap(n.cfg.ChangeApprovalConfigMapName)
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		klog.Warningf("Error reading change approval ConfigMap %q: %v", n.cfg.ChangeApprovalConfigMapName, err)
+	}
+
+	staged, approved, err := parseChangeApprovalConfigMap(cm)
+	if err != nil {
+		klog.Warningf("Error parsing change approval ConfigMap %q: %v", n.cfg.ChangeApprovalConfigMapName, err)
+		return map[string]*sharedStagedChange{}, map[string]approvedChange{}
+	}
+	return staged, approved
+}
+
+// updateChangeApprovalConfigMap applies update to the staged and the
+// approved changes of the change approval ConfigMap, creating it when it
+// does not exist
+func (n *NGINXController) updateChangeApprovalConfigMap(update func(map[string]*sharedStagedChange, map[string]approvedChange) error) error {
+	return n.updateConfigMap(n.cfg.ChangeApprovalConfigMapName, func(cm *apiv1.ConfigMap) error {
+		staged, approved, err := parseChangeApprovalConfigMap(cm)
+		if err != nil {
+			return err
+		}
+		if err := update(staged, approved); err != nil {
+			return err
+		}
+
+		stagedData, err := json.Marshal(staged)
+		if err != nil {
+			return err
+		}
+		approvedData, err := json.Marshal(approved)
+		if err != nil {
+			return err
+		}
+		cm.Data[changeApprovalStagedKey] = string(stagedData)
+		cm.Data[changeApprovalApprovedKey] = string(approvedData)
+		return nil
+	})
+}
+
+// sharedAppliedIngress returns the applied version of an Ingress kept in
+// the change approval ConfigMap, with its annotations parsed again
+func (n *NGINXController) sharedAppliedIngress(change *sharedStagedChange) (*ingress.Ingress, error) {
+	parsed, overridden, err := n.extractAnnotations(&change.Applied)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ingress.Ingress{
+		Ingress:                      change.Applied,
+		ParsedAnnotations:            parsed,
+		OverriddenDefaultAnnotations: overridden,
+		IngressClass:                 change.IngressClass,
+	}, nil
+}
+
+// newSharedStagedChange returns the staged change kept in the change
+// approval ConfigMap, without the fields managed by the API server
+func newSharedStagedChange(change *StagedChange, applied *ingress.Ingress) *sharedStagedChange {
+	shared := &sharedStagedChange{StagedChange: *change, IngressClass: applied.IngressClass}
+	applied.Ingress.DeepCopyInto(&shared.Applied)
+	shared.Applied.ManagedFields = nil
+	shared.Applied.Status = networking.IngressStatus{}
+	return shared
+}
+
+// highImpactChanges returns the reasons the update of an Ingress from its
+// applied version is high-impact: a host removed, an authentication removed
+// or a snippet changed. A nil current version is a deleted Ingress.
+func highImpactChanges(applied, current *ingress.Ingress) []string {
+	if current == nil {
+		return []string{"deletes the Ingress"}
+	}
+
+	reasons := []string{}
+
+	hosts := sets.New[string]()
+	for _, rule := range current.Spec.Rules {
+		hosts.Insert(rule.Host)
+	}
+	removed := sets.New[string]()
+	for _, rule := range applied.Spec.Rules {
+		if rule.Host != "" && !hosts.Has(rule.Host) {
+			removed.Insert(rule.Host)
+		}
+	}
+	for _, host := range sets.List(removed) {
+		reasons = append(reasons, fmt.Sprintf("removes host %v", host))
+	}
+
+	if applied.ParsedAnnotations != nil && current.ParsedAnnotations != nil {
+		before, after := applied.ParsedAnnotations, current.ParsedAnnotations
+		if before.BasicDigestAuth.Secured && !after.BasicDigestAuth.Secured {
+			reasons = append(reasons, fmt.Sprintf("removes the %v authentication", before.BasicDigestAuth.Type))
+		}
+		if before.ExternalAuth.URL != "" && after.ExternalAuth.URL == "" {
+			reasons = append(reasons, "removes the external authentication")
+		}
+		if before.CertificateAuth.Secret != "" && after.CertificateAuth.Secret == "" {
+			reasons = append(reasons, "removes the client certificate authentication")
+		}
+	}
+
+	snippets := sets.New[string]()
+	for _, annotations := range []map[string]string{applied.Annotations, current.Annotations} {
+		for name := range annotations {
+			if strings.HasPrefix(name, parser.AnnotationsPrefix+"/") && strings.HasSuffix(name, "-snippet") {
+				snippets.Insert(name)
+			}
+		}
+	}
+	for _, name := range sets.List(snippets) {
+		if applied.Annotations[name] != current.Annotations[name] {
+			reasons = append(reasons, fmt.Sprintf("changes annotation %v", name))
+		}
+	}
+
+	return reasons
+}
+
+// deletionID returns the identifier of the deletion of an Ingress
+func deletionID(applied *ingress.Ingress) string {
+	sum := sha256.Sum256([]byte("delete/" + string(applied.UID) + "/" + applied.ResourceVersion))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stageHighImpactChanges replaces the Ingresses updated or deleted with a
+// high-impact change by their applied version, until the change is approved
+// with the approve-change annotation or the change approval API, or the
+// change approval timeout expires. A ChangeStaged Event is recorded on the
+// Ingresses when a change is staged. Nothing is staged before the first
+// configuration is applied, except the changes staged by the other replicas
+// in the change approval ConfigMap, whose applied versions are served.
+func (n *NGINXController) stageHighImpactChanges(ings []*ingress.Ingress) []*ingress.Ingress {
+	if !n.cfg.EnableChangeApproval {
+		return ings
+	}
+
+	n.changeApprovalLock.Lock()
+	defer n.changeApprovalLock.Unlock()
+
+	sharedStaged, sharedApproved := n.getSharedChangeApprovals()
+	if sharedApproved != nil {
+		n.approvedChanges = sharedApproved
+	}
+
+	appliedIngresses := n.appliedIngresses
+	if len(sharedStaged) > 0 {
+		appliedIngresses = make(map[string]*ingress.Ingress, len(n.appliedIngresses)+len(sharedStaged))
+		for key, applied := range n.appliedIngresses {
+			appliedIngresses[key] = applied
+		}
+		for key, change := range sharedStaged {
+			if applied, ok := appliedIngresses[key]; ok && applied.ResourceVersion == change.Applied.ResourceVersion {
+				continue
+			}
+			applied, err := n.sharedAppliedIngress(change)
+			if err != nil {
+				klog.Warningf("Error parsing the applied version of Ingress %q in the change approval ConfigMap: %v", key, err)
+				continue
+			}
+			appliedIngresses[key] = applied
+		}
+	}
+
+	if appliedIngresses == nil {
+		return ings
+	}
+
+	now := time.Now()
+	staged := map[string]*StagedChange{}
+
+	// the change staged first by a replica is kept by the others
+	previousChange := func(key, id string) (*StagedChange, bool) {
+		previous, ok := n.stagedChanges[key]
+		if shared, found := sharedStaged[key]; found && shared.ID == id && (!ok || previous.ID != id || shared.Since.Before(previous.Since)) {
+			return &shared.StagedChange, true
+		}
+		return previous, ok
+	}
+
+	stage := func(key string, applied, current *ingress.Ingress) bool {
+		reasons := highImpactChanges(applied, current)
+		if len(reasons) == 0 {
+			return false
+		}
+
+		id := deletionID(applied)
+		if current != nil {
+			id = changeapproval.ChangeID(&current.Ingress)
+			if current.ParsedAnnotations != nil && current.ParsedAnnotations.ChangeApproval == id {
+				return false
+			}
+		}
+		if n.approvedChanges[key].ID == id {
+			return false
+		}
+
+		change := &StagedChange{Ingress: key, ID: id, Reasons: reasons, Since: now}
+		previous, ok := previousChange(key, id)
+		if ok && previous.ID == id {
+			change.Since = previous.Since
+		}
+		if n.cfg.ChangeApprovalTimeout > 0 {
+			autoApplyAt := change.Since.Add(n.cfg.ChangeApprovalTimeout)
+			if !now.Before(autoApplyAt) {
+				klog.InfoS("Applying high-impact change without approval", "ingress", key, "id", id, "since", change.Since)
+				return false
+			}
+			change.AutoApplyAt = &autoApplyAt
+		}
+
+		staged[key] = change
+		if !ok || previous.ID != id {
+			klog.InfoS("High-impact change staged until approved", "ingress", key, "id", id, "reasons", reasons)
+			approval := fmt.Sprintf("the annotation %v=%v", parser.GetAnnotationWithPrefix("approve-change"), id)
+			if current == nil {
+				approval = fmt.Sprintf("the change approval API with the id %v", id)
+			}
+			n.recorder.Eventf(&applied.Ingress, apiv1.EventTypeWarning, "ChangeStaged",
+				"Change not applied until approved with %v: %v", approval, strings.Join(reasons, ", "))
+		}
+		return true
+	}
+
+	result := make([]*ingress.Ingress, 0, len(ings))
+	current := sets.New[string]()
+	for _, ing := range ings {
+		key := k8s.MetaNamespaceKey(&ing.Ingress)
+		current.Insert(key)
+
+		applied, ok := appliedIngresses[key]
+		if ok && applied.ResourceVersion != ing.ResourceVersion && stage(key, applied, ing) {
+			result = append(result, applied)
+			continue
+		}
+		result = append(result, ing)
+	}
+
+	for key, applied := range appliedIngresses {
+		if !current.Has(key) && stage(key, applied, nil) {
+			result = append(result, applied)
+		}
+	}
+
+	n.stagedChanges = staged
+	n.scheduleChangeAutoApply()
+	n.shareChangeApprovals(sharedStaged, appliedIngresses, ings, now)
+
+	return result
+}
+
+// shareChangeApprovals updates the change approval ConfigMap when the
+// changes staged by the replica differ from the ones it contains, and
+// removes the approvals not needed anymore: the approvals of a previous
+// version of an Ingress, and the approvals of a deleted Ingress after the
+// approval retention.
+func (n *NGINXController) shareChangeApprovals(sharedStaged map[string]*sharedStagedChange, appliedIngresses map[string]*ingress.Ingress, ings []*ingress.Ingress, now time.Time) {
+	if n.cfg.ChangeApprovalConfigMapName == "" || n.cfg.ShadowMode {
+		return
+	}
+
+	changeIDs := make(map[string]string, len(ings))
+	for _, ing := range ings {
+		changeIDs[k8s.MetaNamespaceKey(&ing.Ingress)] = changeapproval.ChangeID(&ing.Ingress)
+	}
+	expired := func(key string, approval approvedChange) bool {
+		if id, ok := changeIDs[key]; ok {
+			return id != approval.ID
+		}
+		return now.Sub(approval.ApprovedAt) > approvalRetention
+	}
+
+	stagedIDs := func(changes map[string]*sharedStagedChange) map[string]string {
+		ids := make(map[string]string, len(changes))
+		for key, change := range changes {
+			ids[key] = change.ID
+		}
+		return ids
+	}
+	staged := make(map[string]*sharedStagedChange, len(n.stagedChanges))
+	for key, change := range n.stagedChanges {
+		staged[key] = newSharedStagedChange(change, appliedIngresses[key])
+	}
+
+	pruned := false
+	for key, approval := range n.approvedChanges {
+		if expired(key, approval) {
+			pruned = true
+		}
+	}
+	if !pruned && reflect.DeepEqual(stagedIDs(sharedStaged), stagedIDs(staged)) {
+		return
+	}
+
+	err := n.updateChangeApprovalConfigMap(func(sharedStaged map[string]*sharedStagedChange, sharedApproved map[string]approvedChange) error {
+		for key := range sharedStaged {
+			delete(sharedStaged, key)
+		}
+		for key, change := range staged {
+			sharedStaged[key] = change
+		}
+		for key, approval := range sharedApproved {
+			if expired(key, approval) {
+				delete(sharedApproved, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		klog.ErrorS(err, "Error updating the staged changes", "configmap", n.cfg.ChangeApprovalConfigMapName)
+	}
+}
+
+// scheduleChangeAutoApply syncs the configuration again when the change
+// approval timeout of the first staged change expires
+func (n *NGINXController) scheduleChangeAutoApply() {
+	if n.changeAutoApply != nil {
+		n.changeAutoApply.Stop()
+		n.changeAutoApply = nil
+	}
+
+	var next time.Time
+	for _, change := range n.stagedChanges {
+		if change.AutoApplyAt != nil && (next.IsZero() || change.AutoApplyAt.Before(next)) {
+			next = *change.AutoApplyAt
+		}
+	}
+
+	if !next.IsZero() {
+		n.changeAutoApply = time.AfterFunc(time.Until(next), func() {
+			n.syncQueue.EnqueueTask(task.GetDummyObject("change-auto-apply"))
+		})
+	}
+}
+
+// recordAppliedIngresses keeps the Ingresses of the configuration applied,
+// the high-impact changes being detected against them
+func (n *NGINXController) recordAppliedIngresses(ings []*ingress.Ingress) {
+	if !n.cfg.EnableChangeApproval {
+		return
+	}
+
+	n.changeApprovalLock.Lock()
+	defer n.changeApprovalLock.Unlock()
+
+	applied := make(map[string]*ingress.Ingress, len(ings))
+	for _, ing := range ings {
+		applied[k8s.MetaNamespaceKey(&ing.Ingress)] = ing
+	}
+
+	// the approvals of the changes applied are not needed anymore, the
+	// approvals shared with the other replicas are removed when staging
+	if n.cfg.ChangeApprovalConfigMapName == "" {
+		for key, approval := range n.approvedChanges {
+			ing, ok := applied[key]
+			if !ok || changeapproval.ChangeID(&ing.Ingress) == approval.ID {
+				delete(n.approvedChanges, key)
+			}
+		}
+	}
+
+	n.appliedIngresses = applied
+}
+
+// stagedChangeList returns the staged changes sorted by Ingress
+func (n *NGINXController) stagedChangeList() []*StagedChange {
+	n.changeApprovalLock.Lock()
+	defer n.changeApprovalLock.Unlock()
+
+	changes := make([]*StagedChange, 0, len(n.stagedChanges))
+	for _, change := range n.stagedChanges {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Ingress < changes[j].Ingress
+	})
+	return changes
+}
+
+// approveChange approves the staged change of an Ingress with an identifier,
+// in the change approval ConfigMap when the replica shares the approvals
+func (n *NGINXController) approveChange(key, id string) (*StagedChange, int, error) {
+	approval := approvedChange{ID: id, ApprovedAt: time.Now().UTC().Truncate(time.Second)}
+
+	if n.cfg.ChangeApprovalConfigMapName != "" {
+		var change *StagedChange
+		status := http.StatusOK
+		err := n.updateChangeApprovalConfigMap(func(staged map[string]*sharedStagedChange, approved map[string]approvedChange) error {
+			shared, ok := staged[key]
+			switch {
+			case !ok:
+				status = http.StatusNotFound
+			case shared.ID != id:
+				status = http.StatusConflict
+			default:
+				change = &shared.StagedChange
+				approved[key] = approval
+			}
+			return nil
+		})
+		return change, status, err
+	}
+
+	n.changeApprovalLock.Lock()
+	defer n.changeApprovalLock.Unlock()
+
+	change, ok := n.stagedChanges[key]
+	if !ok {
+		return nil, http.StatusNotFound, nil
+	}
+	if change.ID != id {
+		return nil, http.StatusConflict, nil
+	}
+
+	if n.approvedChanges == nil {
+		n.approvedChanges = map[string]approvedChange{}
+	}
+	n.approvedChanges[key] = approval
+
+	return change, http.StatusOK, nil
+}
+
+// ChangeApprovalAPIHandler returns the handler of the API approving the
+// high-impact changes staged by the controller, for all the replicas when
+// they share the change approval ConfigMap. The handler does not
+// authenticate the requests.
+//
+//	GET  /api/v1/changes                                           the staged changes
+//	POST /api/v1/changes?ingress=<namespace>/<name>&id=<id>       approves the staged change of an Ingress
+//
+// The identifier of the change must be the one of the staged change, to
+// approve the version of the Ingress reviewed only.
+func (n *NGINXController) ChangeApprovalAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, n.stagedChangeList())
+		case http.MethodPost:
+			key, id := r.URL.Query().Get("ingress"), r.URL.Query().Get("id")
+			if _, _, err := k8s.ParseNameNS(key); err != nil || id == "" {
+				http.Error(w, "the ingress parameter, in the form namespace/name, and the id parameter are required", http.StatusBadRequest)
+				return
+			}
+
+			change, status, err := n.approveChange(key, id)
+			if err != nil {
+				klog.ErrorS(err, "Error approving change", "ingress", key, "configmap", n.cfg.ChangeApprovalConfigMapName)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			switch status {
+			case http.StatusNotFound:
+				http.Error(w, fmt.Sprintf("no change of Ingress %v is staged", key), status)
+				return
+			case http.StatusConflict:
+				http.Error(w, fmt.Sprintf("the staged change of Ingress %v is not %v", key, id), status)
+				return
+			}
+
+			klog.InfoS("High-impact change approved", "ingress", key, "id", id)
+			n.syncQueue.EnqueueTask(task.GetDummyObject("change-approved"))
+			writeJSON(w, change)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
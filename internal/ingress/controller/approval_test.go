@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/changeapproval"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/metrics"
+)
+
+func approvalIngress(resourceVersion string, hosts ...string) *ingress.Ingress {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            "web",
+				ResourceVersion: resourceVersion,
+				Annotations:     map[string]string{},
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{},
+	}
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networking.IngressRule{Host: host})
+	}
+	return ing
+}
+
+func TestHighImpactChanges(t *testing.T) {
+	snippet := parser.GetAnnotationWithPrefix("configuration-snippet")
+
+	applied := approvalIngress("1", "a.example.com", "b.example.com")
+	applied.Annotations[snippet] = "return 403;"
+	applied.ParsedAnnotations.BasicDigestAuth = auth.Config{Type: "basic", Secured: true}
+	applied.ParsedAnnotations.ExternalAuth.URL = "http://auth.example.com"
+
+	current := approvalIngress("2", "a.example.com", "c.example.com")
+	current.Annotations[snippet] = "return 200;"
+	current.ParsedAnnotations.ExternalAuth.URL = "http://auth.example.com"
+
+	expected := []string{
+		"removes host b.example.com",
+		"removes the basic authentication",
+		"changes annotation " + snippet,
+	}
+	if reasons := highImpactChanges(applied, current); !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("expected %v but returned %v", expected, reasons)
+	}
+
+	if reasons := highImpactChanges(applied, applied); len(reasons) != 0 {
+		t.Errorf("expected no high-impact change but returned %v", reasons)
+	}
+
+	expected = []string{"deletes the Ingress"}
+	if reasons := highImpactChanges(applied, nil); !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("expected %v but returned %v", expected, reasons)
+	}
+}
+
+func TestStageHighImpactChanges(t *testing.T) {
+	n := &NGINXController{
+		cfg:       &Configuration{EnableChangeApproval: true},
+		recorder:  record.NewFakeRecorder(10),
+		syncQueue: task.NewTaskQueue(func(interface{}) error { return nil }),
+	}
+
+	applied := approvalIngress("1", "a.example.com", "b.example.com")
+	removed := approvalIngress("2", "a.example.com")
+	added := approvalIngress("3", "a.example.com", "b.example.com", "c.example.com")
+
+	// nothing is staged before the first configuration is applied
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{removed}); ings[0] != removed {
+		t.Fatalf("expected the Ingress to be applied before the first configuration")
+	}
+
+	n.recordAppliedIngresses([]*ingress.Ingress{applied})
+
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{added}); ings[0] != added {
+		t.Errorf("expected a host added to be applied")
+	}
+
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{removed}); ings[0] != applied {
+		t.Errorf("expected the applied version of the Ingress to be served until the change is approved")
+	}
+	changes := n.stagedChangeList()
+	if len(changes) != 1 || changes[0].Ingress != "default/web" || changes[0].AutoApplyAt != nil {
+		t.Fatalf("expected a staged change of default/web but returned %v", changes)
+	}
+
+	id := changeapproval.ChangeID(&removed.Ingress)
+	removed.ParsedAnnotations.ChangeApproval = id
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{removed}); ings[0] != removed {
+		t.Errorf("expected the change approved with the annotation to be applied")
+	}
+	n.recordAppliedIngresses([]*ingress.Ingress{removed})
+
+	// the deletion of the Ingress is applied after the timeout
+	n.cfg.ChangeApprovalTimeout = time.Hour
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{}); len(ings) != 1 || ings[0] != removed {
+		t.Fatalf("expected the deleted Ingress to be served until the change is approved")
+	}
+	n.stagedChanges["default/web"].Since = time.Now().Add(-2 * time.Hour)
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{}); len(ings) != 0 {
+		t.Errorf("expected the deletion to be applied after the change approval timeout")
+	}
+}
+
+func TestChangeApprovalAPI(t *testing.T) {
+	n := &NGINXController{
+		cfg:       &Configuration{EnableChangeApproval: true},
+		recorder:  record.NewFakeRecorder(10),
+		syncQueue: task.NewTaskQueue(func(interface{}) error { return nil }),
+	}
+
+	applied := approvalIngress("1", "a.example.com")
+	n.recordAppliedIngresses([]*ingress.Ingress{applied})
+	n.stageHighImpactChanges([]*ingress.Ingress{})
+	id := deletionID(applied)
+
+	handler := metrics.RequireBearerToken("secret", n.ChangeApprovalAPIHandler())
+
+	testCases := []struct {
+		name           string
+		method         string
+		query          string
+		token          string
+		expectedStatus int
+	}{
+		{"without token", http.MethodGet, "", "", http.StatusUnauthorized},
+		{"staged changes", http.MethodGet, "", "secret", http.StatusOK},
+		{"without id", http.MethodPost, "?ingress=default/web", "secret", http.StatusBadRequest},
+		{"not staged", http.MethodPost, "?ingress=default/api&id=" + id, "secret", http.StatusNotFound},
+		{"other change", http.MethodPost, "?ingress=default/web&id=0123456789abcdef", "secret", http.StatusConflict},
+		{"approve", http.MethodPost, "?ingress=default/web&id=" + id, "secret", http.StatusOK},
+		{"unsupported method", http.MethodDelete, "", "secret", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, ChangeApprovalAPIPath+tc.query, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v but got %v: %v", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.name == "staged changes" {
+				var changes []StagedChange
+				if err := json.Unmarshal(w.Body.Bytes(), &changes); err != nil || len(changes) != 1 || changes[0].ID != id {
+					t.Errorf("expected the staged deletion of default/web but got %v (%v)", w.Body.String(), err)
+				}
+			}
+		})
+	}
+
+	if ings := n.stageHighImpactChanges([]*ingress.Ingress{}); len(ings) != 0 {
+		t.Errorf("expected the approved deletion to be applied")
+	}
+	n.recordAppliedIngresses([]*ingress.Ingress{})
+	if len(n.approvedChanges) != 0 {
+		t.Errorf("expected the approval to be removed once applied but got %v", n.approvedChanges)
+	}
+}
+
+func TestSharedChangeApprovals(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	newReplica := func() *NGINXController {
+		return &NGINXController{
+			cfg: &Configuration{
+				Client:                      client,
+				EnableChangeApproval:        true,
+				ChangeApprovalConfigMapName: "ingress-nginx/change-approval",
+			},
+			store:     &drainStore{client: client},
+			recorder:  record.NewFakeRecorder(10),
+			syncQueue: task.NewTaskQueue(func(interface{}) error { return nil }),
+		}
+	}
+
+	applied := approvalIngress("1", "a.example.com", "b.example.com")
+	removed := approvalIngress("2", "a.example.com")
+
+	first := newReplica()
+	first.recordAppliedIngresses([]*ingress.Ingress{applied})
+	if ings := first.stageHighImpactChanges([]*ingress.Ingress{removed}); ings[0] != applied {
+		t.Fatalf("expected the applied version of the Ingress to be served until the change is approved")
+	}
+
+	// a new replica serves the applied version staged by the other replicas
+	second := newReplica()
+	ings := second.stageHighImpactChanges([]*ingress.Ingress{removed})
+	if len(ings) != 1 || ings[0].ResourceVersion != "1" || len(ings[0].Spec.Rules) != 2 {
+		t.Fatalf("expected the applied version of the Ingress shared by the other replica but got %v", ings)
+	}
+
+	// an approval received by a replica applies to all of them
+	id := changeapproval.ChangeID(&removed.Ingress)
+	if _, status, err := second.approveChange("default/web", id); status != http.StatusOK || err != nil {
+		t.Fatalf("expected the change to be approved but got %v (%v)", status, err)
+	}
+	if ings := first.stageHighImpactChanges([]*ingress.Ingress{removed}); ings[0] != removed {
+		t.Errorf("expected the change approved with the other replica to be applied")
+	}
+	first.recordAppliedIngresses([]*ingress.Ingress{removed})
+
+	staged, approved := first.getSharedChangeApprovals()
+	if len(staged) != 0 || approved["default/web"].ID != id {
+		t.Errorf("expected the approved change to be applied but got %v staged and %v approved", staged, approved)
+	}
+
+	// the approval of a previous version of the Ingress is removed
+	updated := approvalIngress("3", "a.example.com", "c.example.com")
+	first.stageHighImpactChanges([]*ingress.Ingress{updated})
+	if _, approved := first.getSharedChangeApprovals(); len(approved) != 0 {
+		t.Errorf("expected the approval of the previous version to be removed but got %v", approved)
+	}
+}
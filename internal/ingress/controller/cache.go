@@ -0,0 +1,276 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// CachePurgeAPIPath is the path of the API removing responses from the
+// cache zones of the Ingresses
+const CachePurgeAPIPath = "/api/v1/cache/purge"
+
+// cacheDirectory contains the cache zones, one directory per zone
+var cacheDirectory = proxycache.Directory
+
+// maxCacheHeaderSize is the number of bytes of a cached response read to find its key
+const maxCacheHeaderSize = 16384
+
+var (
+	cacheFileRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	cacheKeyMarker = []byte("\nKEY: ")
+)
+
+// CachePurgeAPIHandler returns the handler of the API removing cached
+// responses. The handler does not authenticate the requests.
+//
+//	POST /api/v1/cache/purge?namespace=<namespace>&ingress=<name>
+//
+// removes the responses cached by the Ingress, or only the responses of
+// the url parameter, like https://example.com/index.html. A trailing *
+// removes the responses of the URLs starting with the url parameter, and
+// the method parameter only removes the responses of a request method.
+func (n *NGINXController) CachePurgeAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		namespace, name := query.Get("namespace"), query.Get("ingress")
+		if namespace == "" || name == "" {
+			http.Error(w, "the namespace and ingress parameters are required", http.StatusBadRequest)
+			return
+		}
+
+		cache := n.proxyCache(proxycache.ZoneName(namespace, name))
+		if cache == nil {
+			http.Error(w, fmt.Sprintf("the ingress %v/%v does not cache responses", namespace, name), http.StatusNotFound)
+			return
+		}
+
+		var match *regexp.Regexp
+		if rawURL := query.Get("url"); rawURL != "" {
+			var err error
+			match, err = cacheKeyRegex(cache.Key, query.Get("method"), rawURL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		purged, err := purgeCache(filepath.Join(cacheDirectory, cache.Zone), match)
+		if err != nil {
+			klog.ErrorS(err, "Error purging cache", "zone", cache.Zone)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		klog.InfoS("Cache purged", "ingress", namespace+"/"+name, "url", query.Get("url"), "responses", purged)
+		writeJSON(w, map[string]int{"purged": purged})
+	})
+}
+
+// proxyCache returns the cache configuration of the zone in the running configuration
+func (n *NGINXController) proxyCache(zone string) *proxycache.Config {
+	n.runningConfigLock.RLock()
+	defer n.runningConfigLock.RUnlock()
+
+	if n.runningConfig == nil {
+		return nil
+	}
+
+	for _, server := range n.runningConfig.Servers {
+		for _, location := range server.Locations {
+			if location.ProxyCache.Enabled && location.ProxyCache.Zone == zone {
+				cache := location.ProxyCache
+				return &cache
+			}
+		}
+	}
+
+	return nil
+}
+
+// cacheKeyRegex returns the regular expression matching the cache keys of
+// the responses of a URL. The headers, cookies and arguments in the key
+// match any value.
+func cacheKeyRegex(components []string, method, rawURL string) (*regexp.Regexp, error) {
+	prefix := strings.HasSuffix(rawURL, "*")
+	u, err := url.Parse(strings.TrimSuffix(rawURL, "*"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", rawURL)
+	}
+
+	wildcard := func(value string) string {
+		if prefix {
+			return regexp.QuoteMeta(value) + ".*"
+		}
+		return regexp.QuoteMeta(value)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, component := range components {
+		if i > 0 {
+			expr.WriteString(regexp.QuoteMeta(proxycache.KeySeparator))
+		}
+
+		kind, _, _ := strings.Cut(component, ":")
+		switch kind {
+		case "scheme":
+			expr.WriteString(regexp.QuoteMeta(u.Scheme))
+		case "method":
+			if method == "" {
+				expr.WriteString("[A-Z]+")
+			} else {
+				expr.WriteString(regexp.QuoteMeta(strings.ToUpper(method)))
+			}
+		case "host":
+			expr.WriteString(regexp.QuoteMeta(strings.ToLower(u.Hostname())))
+		case "uri":
+			expr.WriteString(wildcard(u.RequestURI()))
+		case "path":
+			expr.WriteString(wildcard(u.Path))
+		case "query":
+			if prefix {
+				expr.WriteString(".*")
+			} else {
+				expr.WriteString(regexp.QuoteMeta(u.RawQuery))
+			}
+		default:
+			expr.WriteString(".*")
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// purgeCache removes the cached responses of a zone whose key matches,
+// or all of them when match is nil, and returns their number
+func purgeCache(dir string, match *regexp.Regexp) (int, error) {
+	purged := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// temporary files are still being written by NGINX
+		if !d.Type().IsRegular() || !cacheFileRegex.MatchString(d.Name()) {
+			return nil
+		}
+
+		if match != nil {
+			key, err := readCacheKey(path)
+			if err != nil {
+				klog.V(3).InfoS("Ignoring cache file", "path", path, "err", err)
+				return nil
+			}
+			if !match.MatchString(key) {
+				return nil
+			}
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		purged++
+		return nil
+	})
+
+	return purged, err
+}
+
+// readCacheKey returns the key stored after the header of a cached response
+func readCacheKey(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, maxCacheHeaderSize)
+	size, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	header = header[:size]
+
+	start := bytes.Index(header, cacheKeyMarker)
+	if start == -1 {
+		return "", fmt.Errorf("cache key not found")
+	}
+	header = header[start+len(cacheKeyMarker):]
+
+	end := bytes.IndexByte(header, '\n')
+	if end == -1 {
+		return "", fmt.Errorf("cache key not found")
+	}
+	return string(header[:end]), nil
+}
+
+// removeUnusedCacheZones removes the directories of the cache
+// zones that are no longer used by the configuration
+func removeUnusedCacheZones(pcfg *ingress.Configuration) {
+	zones := sets.Set[string]{}
+	for _, server := range pcfg.Servers {
+		for _, location := range server.Locations {
+			if location.ProxyCache.Enabled {
+				zones.Insert(location.ProxyCache.Zone)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(cacheDirectory)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			klog.ErrorS(err, "Error reading cache directory")
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || zones.Has(entry.Name()) {
+			continue
+		}
+		klog.InfoS("Removing unused cache zone", "zone", entry.Name())
+		if err := os.RemoveAll(filepath.Join(cacheDirectory, entry.Name())); err != nil {
+			klog.ErrorS(err, "Error removing cache zone", "zone", entry.Name())
+		}
+	}
+}
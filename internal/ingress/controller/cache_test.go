@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/metrics"
+)
+
+// writeCacheFile writes a cached response with the layout of NGINX,
+// a binary header followed by the key and the response
+func writeCacheFile(t *testing.T, zone, key string) string {
+	sum := md5.Sum([]byte(key)) //nolint:gosec // the cache files of NGINX are named by the MD5 of their key
+	name := hex.EncodeToString(sum[:])
+	dir := filepath.Join(zone, name[31:], name[29:31])
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("unexpected error creating cache directory: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	content := append([]byte{0x05, 0x00, 0x00, 0x00, 0xff, 0x01}, []byte("\nKEY: "+key+"\nHTTP/1.1 200 OK\r\n\r\nbody")...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("unexpected error writing cache file: %v", err)
+	}
+	return path
+}
+
+func TestCacheKeyRegex(t *testing.T) {
+	testCases := []struct {
+		name       string
+		components []string
+		method     string
+		url        string
+		matches    []string
+		mismatches []string
+	}{
+		{
+			name:       "default key",
+			components: proxycache.DefaultKey,
+			url:        "https://Example.com/index.html?lang=en",
+			matches:    []string{"https|GET|example.com|/index.html?lang=en", "https|HEAD|example.com|/index.html?lang=en"},
+			mismatches: []string{"http|GET|example.com|/index.html?lang=en", "https|GET|example.com|/index.html", "https|GET|example.com|/index.html?lang=en&a=b"},
+		},
+		{
+			name:       "method",
+			components: proxycache.DefaultKey,
+			method:     "get",
+			url:        "https://example.com/",
+			matches:    []string{"https|GET|example.com|/"},
+			mismatches: []string{"https|HEAD|example.com|/"},
+		},
+		{
+			name:       "prefix",
+			components: proxycache.DefaultKey,
+			url:        "https://example.com/static/*",
+			matches:    []string{"https|GET|example.com|/static/", "https|GET|example.com|/static/app.js?v=1"},
+			mismatches: []string{"https|GET|example.com|/index.html", "https|GET|example.com.evil|/static/"},
+		},
+		{
+			name:       "path, query and headers",
+			components: []string{"host", "path", "header:Accept-Language", "query"},
+			url:        "http://example.com/a.b?x=1",
+			matches:    []string{"example.com|/a.b|en-US|x=1", "example.com|/a.b||x=1"},
+			mismatches: []string{"example.com|/aXb|en-US|x=1", "example.com|/a.b||x=2", "example.com/a.b|x=1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			re, err := cacheKeyRegex(tc.components, tc.method, tc.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, key := range tc.matches {
+				if !re.MatchString(key) {
+					t.Errorf("expected %v to match %q", re, key)
+				}
+			}
+			for _, key := range tc.mismatches {
+				if re.MatchString(key) {
+					t.Errorf("expected %v not to match %q", re, key)
+				}
+			}
+		})
+	}
+
+	if _, err := cacheKeyRegex(proxycache.DefaultKey, "", "/index.html"); err == nil {
+		t.Errorf("expected an error for a URL without scheme and host")
+	}
+}
+
+func TestCachePurgeAPI(t *testing.T) {
+	cacheDirectory = t.TempDir()
+	defer func() { cacheDirectory = proxycache.Directory }()
+
+	zone := filepath.Join(cacheDirectory, "default_web")
+	index := writeCacheFile(t, zone, "https|GET|example.com|/index.html")
+	script := writeCacheFile(t, zone, "https|GET|example.com|/static/app.js")
+	style := writeCacheFile(t, zone, "https|GET|example.com|/static/app.css")
+	temp := filepath.Join(zone, "0000000001")
+	if err := os.WriteFile(temp, []byte("partial"), 0o600); err != nil {
+		t.Fatalf("unexpected error writing temporary file: %v", err)
+	}
+
+	n := &NGINXController{
+		runningConfig: &ingress.Configuration{
+			Servers: []*ingress.Server{
+				{
+					Hostname: "example.com",
+					Locations: []*ingress.Location{
+						{Path: "/", ProxyCache: proxycache.Config{Enabled: true, Zone: "default_web", Key: proxycache.DefaultKey}},
+					},
+				},
+			},
+		},
+	}
+	handler := metrics.RequireBearerToken("secret", n.CachePurgeAPIHandler())
+
+	testCases := []struct {
+		name           string
+		method         string
+		query          string
+		token          string
+		expectedStatus int
+		removed        []string
+		kept           []string
+	}{
+		{"without token", http.MethodPost, "?namespace=default&ingress=web", "", http.StatusUnauthorized, nil, []string{index}},
+		{"not a POST", http.MethodGet, "?namespace=default&ingress=web", "secret", http.StatusMethodNotAllowed, nil, []string{index}},
+		{"without ingress", http.MethodPost, "?namespace=default", "secret", http.StatusBadRequest, nil, []string{index}},
+		{"without cache", http.MethodPost, "?namespace=default&ingress=other", "secret", http.StatusNotFound, nil, []string{index}},
+		{"invalid url", http.MethodPost, "?namespace=default&ingress=web&url=/index.html", "secret", http.StatusBadRequest, nil, []string{index}},
+		{"url", http.MethodPost, "?namespace=default&ingress=web&url=https://example.com/index.html", "secret", http.StatusOK, []string{index}, []string{script, style}},
+		{"prefix", http.MethodPost, "?namespace=default&ingress=web&url=https://example.com/static/app.j*", "secret", http.StatusOK, []string{script}, []string{style}},
+		{"ingress", http.MethodPost, "?namespace=default&ingress=web", "secret", http.StatusOK, []string{style}, []string{temp}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, CachePurgeAPIPath+tc.query, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %v but got %v: %v", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			for _, path := range tc.removed {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("expected %v to be removed", path)
+				}
+			}
+			for _, path := range tc.kept {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("expected %v to be kept: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveUnusedCacheZones(t *testing.T) {
+	cacheDirectory = t.TempDir()
+	defer func() { cacheDirectory = proxycache.Directory }()
+
+	used := writeCacheFile(t, filepath.Join(cacheDirectory, "default_web"), "https|GET|example.com|/")
+	unused := writeCacheFile(t, filepath.Join(cacheDirectory, "default_old"), "https|GET|example.com|/")
+
+	removeUnusedCacheZones(&ingress.Configuration{
+		Servers: []*ingress.Server{
+			{
+				Locations: []*ingress.Location{
+					{Path: "/", ProxyCache: proxycache.Config{Enabled: true, Zone: "default_web"}},
+				},
+			},
+		},
+	})
+
+	if _, err := os.Stat(used); err != nil {
+		t.Errorf("expected the used cache zone to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDirectory, "default_old")); !os.IsNotExist(err) {
+		t.Errorf("expected the unused cache zone %v to be removed", unused)
+	}
+}
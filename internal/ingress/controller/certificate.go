@@ -18,9 +18,14 @@ package controller
 
 import (
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"net"
 	"strings"
 	"unicode/utf8"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslcertpreference"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
 )
 
 // Please check https://github.com/golang/go/issues/22922
@@ -122,3 +127,84 @@ func matchHostnames(pattern, host string) bool {
 
 	return true
 }
+
+// certificateHostMatch returns sslcertpreference.PreferExact when one of the names
+// of the certificate is the named host, sslcertpreference.PreferWildcard when the
+// certificate only matches the host with a wildcard name and an empty string when
+// the certificate is not valid for the host.
+func certificateHostMatch(h string, c *x509.Certificate) string {
+	if c == nil || c.VerifyHostname(h) != nil {
+		return ""
+	}
+
+	if net.ParseIP(strings.Trim(h, "[]")) != nil {
+		return sslcertpreference.PreferExact
+	}
+
+	lowered := toLowerCaseASCII(strings.TrimSuffix(h, "."))
+	for _, name := range c.DNSNames {
+		if toLowerCaseASCII(strings.TrimSuffix(name, ".")) == lowered {
+			return sslcertpreference.PreferExact
+		}
+	}
+
+	return sslcertpreference.PreferWildcard
+}
+
+// discoveredSSLCertificate returns the certificate valid for a host among the
+// certificates of the domain closest to the host, like example.com for the
+// host www.example.com, preferring the one expiring last. Only the
+// certificates of the namespaces allowed and whose chain is verified against
+// roots, the system CA certificates when nil, are valid.
+func discoveredSSLCertificate(host string, allowed func(namespace string) bool, roots *x509.CertPool, domainCerts map[string][]*ingress.SSLCert) *ingress.SSLCert {
+	for domain := toLowerCaseASCII(host); domain != ""; {
+		var found *ingress.SSLCert
+		for _, cert := range domainCerts[domain] {
+			if !allowed(cert.Namespace) || verifyDiscoveredCertificate(host, cert, roots) != nil {
+				continue
+			}
+			if found == nil || cert.ExpireTime.After(found.ExpireTime) ||
+				(cert.ExpireTime.Equal(found.ExpireTime) && cert.Namespace+"/"+cert.Name < found.Namespace+"/"+found.Name) {
+				found = cert
+			}
+		}
+		if found != nil {
+			return found
+		}
+
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return nil
+}
+
+// verifyDiscoveredCertificate returns nil if the certificate is valid for the
+// host and signed by roots, through the intermediate certificates following
+// it in the PEM file
+func verifyDiscoveredCertificate(host string, cert *ingress.SSLCert, roots *x509.CertPool) error {
+	if cert.Certificate == nil {
+		return errors.New("no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	rest := []byte(cert.PemCertKey)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil && !c.Equal(cert.Certificate) {
+			intermediates.AddCert(c)
+		}
+	}
+
+	_, err := cert.Certificate.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -128,6 +128,12 @@ type Configuration struct {
 	// By default this is disabled
 	EnableAccessLogForDefaultBackend bool `json:"enable-access-log-for-default-backend"`
 
+	// EnableDynamicServers routes the requests to the servers using the
+	// global settings from the catch-all server with Lua, adding or
+	// removing these servers does not reload NGINX.
+	// By default this is disabled
+	EnableDynamicServers bool `json:"enable-dynamic-servers"`
+
 	// EnableAuthAccessLog enable auth access log
 	// By default this is disabled
 	EnableAuthAccessLog bool `json:"enable-auth-access-log"`
@@ -179,6 +185,16 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_core_module.html#client_header_timeout
 	ClientHeaderTimeout int `json:"client-header-timeout,omitempty"`
 
+	// RejectNonHTTPProtocols closes without lingering the connections of clients
+	// sending other protocols (like TLS or SSH) to the HTTP ports and counts them
+	// in the nginx_ingress_controller_rejected_protocols_total metric
+	RejectNonHTTPProtocols bool `json:"reject-non-http-protocols"`
+
+	// RejectNonHTTPProtocolsTimeout defines, in seconds, how long a new connection
+	// can stay idle before sending the first request when reject-non-http-protocols
+	// is enabled
+	RejectNonHTTPProtocolsTimeout int `json:"reject-non-http-protocols-timeout,omitempty"`
+
 	// Sets buffer size for reading client request body
 	// http://nginx.org/en/docs/http/ngx_http_core_module.html#client_body_buffer_size
 	ClientBodyBufferSize string `json:"client-body-buffer-size,omitempty"`
@@ -322,6 +338,10 @@ type Configuration struct {
 	// of your external load balancer
 	ProxyRealIPCIDR []string `json:"proxy-real-ip-cidr,omitempty"`
 
+	// UpstreamAttemptsHeaderCIDRs defines the client IP/network addresses trusted to
+	// receive the X-Upstream-Attempts response header. The header is disabled when empty
+	UpstreamAttemptsHeaderCIDRs []string `json:"upstream-attempts-header-cidrs,omitempty"`
+
 	// Sets the name of the configmap that contains the headers to pass to the backend
 	ProxySetHeaders string `json:"proxy-set-headers,omitempty"`
 
@@ -407,6 +427,24 @@ type Configuration struct {
 	// Default: false
 	SSLRejectHandshake bool `json:"ssl-reject-handshake"`
 
+	// SSLCertificatePreference defines the certificate used when both a certificate
+	// containing a host and a wildcard certificate matching it are available.
+	// It can be "exact" or "wildcard". By default the first certificate listing
+	// the host in the TLS section of the Ingress is used.
+	SSLCertificatePreference string `json:"ssl-certificate-preference,omitempty"`
+
+	// IngressConflictResolution defines the Ingress serving a host and path defined
+	// by several Ingresses. It can be "oldest", "newest", "priority" (the highest
+	// conflict-priority annotation) or "reject" to serve it with none of them.
+	// By default the oldest Ingress is used.
+	IngressConflictResolution string `json:"ingress-conflict-resolution,omitempty"`
+
+	// SSLPassthroughUnmatchedSNI defines the action applied to the TLS connections
+	// whose SNI matches no host when SSL passthrough is enabled. It can be "terminate",
+	// "reject" or "fallback" to pass them through to the host with the
+	// ssl-passthrough-fallback annotation. By default NGINX terminates them.
+	SSLPassthroughUnmatchedSNI string `json:"ssl-passthrough-unmatched-sni,omitempty"`
+
 	// Enables or disables the use of the PROXY protocol to receive client connection
 	// (real IP address) information passed through proxy servers and load balancers
 	// such as HAproxy and Amazon Elastic Load Balancer (ELB).
@@ -483,6 +521,23 @@ type Configuration struct {
 	// http://nginx.org/en/docs/ngx_core_module.html#worker_shutdown_timeout
 	WorkerShutdownTimeout string `json:"worker-shutdown-timeout,omitempty"`
 
+	// Enables just-in-time compilation of the regular expressions used in
+	// locations and by the Lua modules
+	// http://nginx.org/en/docs/ngx_core_module.html#pcre_jit
+	PCREJIT bool `json:"pcre-jit"`
+
+	// Limits the number of internal match calls PCRE may perform while
+	// matching a regular expression path, and the regular expressions of the
+	// Lua modules. Requests exceeding the limit fail instead of exhausting the
+	// CPU of the worker. 0 keeps the library default.
+	// https://github.com/openresty/lua-nginx-module#lua_regex_match_limit
+	PCREMatchLimit int `json:"pcre-match-limit"`
+
+	// Limits the recursion depth PCRE may reach while matching a regular
+	// expression path. It is ignored by patterns compiled with JIT.
+	// 0 keeps the library default.
+	PCRERecursionLimit int `json:"pcre-recursion-limit"`
+
 	// Sets the bucket size for the variables hash table.
 	// http://nginx.org/en/docs/http/ngx_http_map_module.html#variables_hash_bucket_size
 	VariablesHashBucketSize int `json:"variables-hash-bucket-size,omitempty"`
@@ -491,26 +546,10 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_map_module.html#variables_hash_max_size
 	VariablesHashMaxSize int `json:"variables-hash-max-size,omitempty"`
 
-	// Activates the cache for connections to upstream servers.
-	// The connections parameter sets the maximum number of idle keepalive connections to
-	// upstream servers that are preserved in the cache of each worker process. When this
-	// number is exceeded, the least recently used connections are closed.
-	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive
-	UpstreamKeepaliveConnections int `json:"upstream-keepalive-connections,omitempty"`
-
 	// Sets the maximum time during which requests can be processed through one keepalive connection
 	// https://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive_time
 	UpstreamKeepaliveTime string `json:"upstream-keepalive-time,omitempty"`
 
-	// Sets a timeout during which an idle keepalive connection to an upstream server will stay open.
-	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive_timeout
-	UpstreamKeepaliveTimeout int `json:"upstream-keepalive-timeout,omitempty"`
-
-	// Sets the maximum number of requests that can be served through one keepalive connection.
-	// After the maximum number of requests is made, the connection is closed.
-	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive_requests
-	UpstreamKeepaliveRequests int `json:"upstream-keepalive-requests,omitempty"`
-
 	// Sets the maximum size of the variables hash table.
 	// http://nginx.org/en/docs/http/ngx_http_map_module.html#variables_hash_max_size
 	LimitConnZoneVariable string `json:"limit-conn-zone-variable,omitempty"`
@@ -693,6 +732,102 @@ type Configuration struct {
 	// +optional
 	GlobalExternalAuth GlobalExternalAuth `json:"global-external-auth"`
 
+	// AuthCookieSessionStore defines where the cookies of locations using the
+	// auth-cookie-session annotation are stored. Valid values are shared-dict and redis
+	// Default: shared-dict
+	AuthCookieSessionStore string `json:"auth-cookie-session-store,omitempty"`
+
+	// AuthCookieSessionThreshold is the size in bytes of the Set-Cookie headers of a
+	// response from which the cookies are stored server-side
+	// Default: 4096
+	AuthCookieSessionThreshold int `json:"auth-cookie-session-threshold,omitempty"`
+
+	// AuthCookieSessionName is the name of the cookie that replaces the stored cookies
+	// Default: ingress_auth_session
+	AuthCookieSessionName string `json:"auth-cookie-session-name,omitempty"`
+
+	// AuthCookieSessionTTL is the time in seconds the stored cookies are kept
+	// after the last update
+	// Default: 86400
+	AuthCookieSessionTTL int `json:"auth-cookie-session-ttl,omitempty"`
+
+	// AuthCookieSessionRedisHost is the Redis server used when
+	// AuthCookieSessionStore is redis
+	AuthCookieSessionRedisHost string `json:"auth-cookie-session-redis-host,omitempty"`
+
+	// AuthCookieSessionRedisPort is the port of AuthCookieSessionRedisHost
+	// Default: 6379
+	AuthCookieSessionRedisPort int `json:"auth-cookie-session-redis-port,omitempty"`
+
+	// EnableTLSFingerprinting computes the JA3 and JA4 fingerprints of the TLS
+	// ClientHello of each connection and exposes them in the $tls_ja3 and
+	// $tls_ja4 variables
+	// Default: false
+	EnableTLSFingerprinting bool `json:"enable-tls-fingerprinting"`
+
+	// TLSFingerprintHeaders sends the fingerprints to the upstream in the
+	// X-JA3-Fingerprint and X-JA4-Fingerprint headers. Requires EnableTLSFingerprinting
+	// Default: false
+	TLSFingerprintHeaders bool `json:"tls-fingerprint-headers"`
+
+	// EnableTLSHandshakeMetrics counts the TLS handshakes started by the
+	// clients, running Lua code in the handshake of every connection, and
+	// the handshakes that failed and the connections reset by the clients,
+	// reading the error log of NGINX at the info level.
+	// Requires the metrics to be enabled
+	// Default: false
+	EnableTLSHandshakeMetrics bool `json:"enable-tls-handshake-metrics"`
+
+	// BotChallengeKey signs the cookies of the clients that passed the
+	// challenge of the bot-challenge annotation. The clients are not
+	// challenged when it is empty
+	BotChallengeKey string `json:"bot-challenge-key,omitempty"`
+
+	// BotChallengeTTL is the time in seconds a client that passed the
+	// challenge of the bot-challenge annotation is not challenged again
+	// Default: 3600
+	BotChallengeTTL int `json:"bot-challenge-ttl,omitempty"`
+
+	// EnableOTLPAccessLog sends the access log records of the requests to
+	// the collector as OTLP log records, in addition to the access log
+	// Default: false
+	EnableOTLPAccessLog bool `json:"enable-otlp-access-log"`
+
+	// OtlpAccessLogEndpoint is the OTLP/HTTP logs endpoint of the collector
+	// Default: http://<otlp-collector-host>:4318/v1/logs
+	OtlpAccessLogEndpoint string `json:"otlp-access-log-endpoint,omitempty"`
+
+	// OtlpAccessLogBatchSize is the maximum number of records sent by an
+	// NGINX worker in a request to the collector
+	// Default: 512
+	OtlpAccessLogBatchSize int `json:"otlp-access-log-batch-size,omitempty"`
+
+	// UpstreamConnectBackoffBase is the time in milliseconds an endpoint is
+	// not picked by the balancer after a failure to connect to it, doubled
+	// with each consecutive failure. 0 disables the backoff
+	// Default: 0
+	UpstreamConnectBackoffBase int `json:"upstream-connect-backoff-base"`
+
+	// UpstreamConnectBackoffMax is the maximum time in milliseconds an
+	// endpoint is not picked by the balancer after connect failures
+	// Default: 10000
+	UpstreamConnectBackoffMax int `json:"upstream-connect-backoff-max,omitempty"`
+
+	// ProxyCacheZoneSize is the size of the shared memory zone with the keys
+	// of the cache zone of each Ingress caching the responses of its backends
+	// Default: 10m
+	ProxyCacheZoneSize string `json:"proxy-cache-zone-size,omitempty"`
+
+	// ProxyCacheMaxSize is the maximum size of the responses stored on disk
+	// by the cache zone of each Ingress
+	// Default: 1g
+	ProxyCacheMaxSize string `json:"proxy-cache-max-size,omitempty"`
+
+	// ProxyCacheInactive is the time after which the responses that were not
+	// accessed are removed from the cache zones, even when they are still valid
+	// Default: 10m
+	ProxyCacheInactive string `json:"proxy-cache-inactive,omitempty"`
+
 	// Checksum contains a checksum of the configmap configuration
 	Checksum string `json:"-"`
 
@@ -733,10 +868,20 @@ type Configuration struct {
 	// like used on Rewrite configurations the user should use pathType as ImplementationSpecific
 	StrictValidatePathType bool `json:"strict-validate-path-type"`
 
+	// StrictAnnotationValidation rejects in the validating webhook the Ingresses
+	// with unknown annotations or annotation values that would be ignored or
+	// replaced with a default
+	StrictAnnotationValidation bool `json:"strict-annotation-validation"`
+
 	// GRPCBufferSizeKb Sets the size of the buffer used for reading the response received
 	// from the gRPC server. The response is passed to the client synchronously,
 	// as soon as it is received.
 	GRPCBufferSizeKb int `json:"grpc-buffer-size-kb"`
+
+	// LuaPlugins are the comma separated Lua plugins of the Lua plugins
+	// ConfigMap loaded by NGINX, as "<name>:<sha256 checksum of the code>"
+	// Default: ""
+	LuaPlugins string `json:"lua-plugins,omitempty"`
 }
 
 // NewDefault returns the default nginx configuration
@@ -773,6 +918,8 @@ func NewDefault() Configuration {
 		BrotliTypes:                      brotliTypes,
 		ClientHeaderBufferSize:           "1k",
 		ClientHeaderTimeout:              60,
+		RejectNonHTTPProtocols:           false,
+		RejectNonHTTPProtocolsTimeout:    5,
 		ClientBodyBufferSize:             "8k",
 		ClientBodyTimeout:                60,
 		EnableUnderscoresInHeaders:       false,
@@ -810,6 +957,7 @@ func NewDefault() Configuration {
 		NginxStatusIpv6Whitelist:         defNginxStatusIpv6Whitelist,
 		ProxyRealIPCIDR:                  defIPCIDR,
 		ProxyProtocolHeaderTimeout:       defProxyDeadlineDuration,
+		SSLPassthroughUnmatchedSNI:       "terminate",
 		ServerNameHashMaxSize:            1024,
 		ProxyHeadersHashMaxSize:          512,
 		ProxyHeadersHashBucketSize:       64,
@@ -834,6 +982,9 @@ func NewDefault() Configuration {
 		WorkerProcesses:                  strconv.Itoa(runtime.NumCPU()),
 		WorkerSerialReloads:              false,
 		WorkerShutdownTimeout:            "240s",
+		PCREJIT:                          false,
+		PCREMatchLimit:                   0,
+		PCRERecursionLimit:               0,
 		VariablesHashBucketSize:          256,
 		VariablesHashMaxSize:             2048,
 		UseHTTP2:                         true,
@@ -844,40 +995,41 @@ func NewDefault() Configuration {
 		ProxyStreamNextUpstreamTimeout:   "600s",
 		ProxyStreamNextUpstreamTries:     3,
 		Backend: defaults.Backend{
-			ProxyBodySize:               bodySize,
-			ProxyConnectTimeout:         5,
-			ProxyReadTimeout:            60,
-			ProxySendTimeout:            60,
-			ProxyBuffersNumber:          4,
-			ProxyBufferSize:             "4k",
-			ProxyCookieDomain:           "off",
-			ProxyCookiePath:             "off",
-			ProxyNextUpstream:           "error timeout",
-			ProxyNextUpstreamTimeout:    0,
-			ProxyNextUpstreamTries:      3,
-			ProxyRequestBuffering:       "on",
-			ProxyRedirectFrom:           "off",
-			ProxyRedirectTo:             "off",
-			PreserveTrailingSlash:       false,
-			SSLRedirect:                 true,
-			CustomHTTPErrors:            []int{},
-			DisableProxyInterceptErrors: false,
-			RelativeRedirects:           false,
-			DenylistSourceRange:         []string{},
-			WhitelistSourceRange:        []string{},
-			SkipAccessLogURLs:           []string{},
-			LimitRate:                   0,
-			LimitRateAfter:              0,
-			ProxyBuffering:              "off",
-			ProxyHTTPVersion:            "1.1",
-			ProxyMaxTempFileSize:        "1024m",
-			ServiceUpstream:             false,
-			AllowedResponseHeaders:      []string{},
+			ProxyBodySize:                bodySize,
+			ProxyConnectTimeout:          5,
+			ProxyReadTimeout:             60,
+			ProxySendTimeout:             60,
+			ProxyBuffersNumber:           4,
+			ProxyBufferSize:              "4k",
+			ProxyCookieDomain:            "off",
+			ProxyCookiePath:              "off",
+			ProxyNextUpstream:            "error timeout",
+			ProxyNextUpstreamTimeout:     0,
+			ProxyNextUpstreamTries:       3,
+			ProxyRequestBuffering:        "on",
+			ProxyRedirectFrom:            "off",
+			ProxyRedirectTo:              "off",
+			PreserveTrailingSlash:        false,
+			SSLRedirect:                  true,
+			CustomHTTPErrors:             []int{},
+			DisableProxyInterceptErrors:  false,
+			RelativeRedirects:            false,
+			DenylistSourceRange:          []string{},
+			WhitelistSourceRange:         []string{},
+			SkipAccessLogURLs:            []string{},
+			LimitRate:                    0,
+			LimitRateAfter:               0,
+			ProxyBuffering:               "off",
+			ProxyHTTPVersion:             "1.1",
+			ChunkedTransferEncoding:      "on",
+			ProxyMaxTempFileSize:         "1024m",
+			ServiceUpstream:              false,
+			AllowedResponseHeaders:       []string{},
+			UpstreamKeepaliveConnections: 320,
+			UpstreamKeepaliveTimeout:     60,
+			UpstreamKeepaliveRequests:    10000,
 		},
-		UpstreamKeepaliveConnections:   320,
 		UpstreamKeepaliveTime:          "1h",
-		UpstreamKeepaliveTimeout:       60,
-		UpstreamKeepaliveRequests:      10000,
 		LimitConnZoneVariable:          defaultLimitConnZoneVariable,
 		BindAddressIpv4:                defBindAddress,
 		BindAddressIpv6:                defBindAddress,
@@ -897,10 +1049,22 @@ func NewDefault() Configuration {
 		NoTLSRedirectLocations:         "/.well-known/acme-challenge",
 		NoAuthLocations:                "/.well-known/acme-challenge",
 		GlobalExternalAuth:             defGlobalExternalAuth,
+		AuthCookieSessionStore:         "shared-dict",
+		AuthCookieSessionThreshold:     4096,
+		AuthCookieSessionName:          "ingress_auth_session",
+		AuthCookieSessionTTL:           86400,
+		AuthCookieSessionRedisPort:     6379,
+		BotChallengeTTL:                3600,
+		OtlpAccessLogBatchSize:         512,
+		UpstreamConnectBackoffMax:      10000,
+		ProxyCacheZoneSize:             "10m",
+		ProxyCacheMaxSize:              "1g",
+		ProxyCacheInactive:             "10m",
 		ProxySSLLocationOnly:           false,
 		DefaultType:                    "text/html",
 		DebugConnections:               []string{},
 		StrictValidatePathType:         true,
+		StrictAnnotationValidation:     false,
 		GRPCBufferSizeKb:               0,
 	}
 
@@ -929,15 +1093,19 @@ type TemplateConfig struct {
 	NginxStatusIpv6Whitelist []string                         `json:"NginxStatusIpv6Whitelist"`
 	RedirectServers          interface{}                      `json:"RedirectServers"`
 	ListenPorts              *ListenPorts                     `json:"ListenPorts"`
-	PublishService           *apiv1.Service                   `json:"PublishService"`
-	EnableMetrics            bool                             `json:"EnableMetrics"`
-	MaxmindEditionFiles      *[]string                        `json:"MaxmindEditionFiles"`
-	MonitorMaxBatchSize      int                              `json:"MonitorMaxBatchSize"`
-	PID                      string                           `json:"PID"`
-	StatusPath               string                           `json:"StatusPath"`
-	StatusPort               int                              `json:"StatusPort"`
-	StreamPort               int                              `json:"StreamPort"`
-	StreamSnippets           []string                         `json:"StreamSnippets"`
+	// ClassListenPorts contains the ports of the servers listening on the
+	// ports of their IngressClass by hostname
+	ClassListenPorts     map[string]*ingress.ClassListenPorts `json:"ClassListenPorts"`
+	PublishService       *apiv1.Service                       `json:"PublishService"`
+	EnableMetrics        bool                                 `json:"EnableMetrics"`
+	EnableChaosInjection bool                                 `json:"EnableChaosInjection"`
+	MaxmindEditionFiles  *[]string                            `json:"MaxmindEditionFiles"`
+	MonitorMaxBatchSize  int                                  `json:"MonitorMaxBatchSize"`
+	PID                  string                               `json:"PID"`
+	StatusPath           string                               `json:"StatusPath"`
+	StatusPort           int                                  `json:"StatusPort"`
+	StreamPort           int                                  `json:"StreamPort"`
+	StreamSnippets       []string                             `json:"StreamSnippets"`
 }
 
 // ListenPorts describe the ports required to run the
@@ -948,6 +1116,15 @@ type ListenPorts struct {
 	Health   int `json:"Health"`
 	Default  int `json:"Default"`
 	SSLProxy int `json:"SSLProxy"`
+	// ErrorPages is the port of the error pages served by the controller
+	ErrorPages int `json:"ErrorPages"`
+	// Metrics is the port of the metrics endpoint served over TLS
+	Metrics int `json:"Metrics"`
+	// ClientIPAgentHTTP and ClientIPAgentHTTPS are the ports receiving the
+	// connections of a node-local agent, prefixed with a PROXY protocol
+	// header carrying the address of the client
+	ClientIPAgentHTTP  int `json:"ClientIPAgentHTTP"`
+	ClientIPAgentHTTPS int `json:"ClientIPAgentHTTPS"`
 }
 
 // GlobalExternalAuth describe external authentication configuration for the
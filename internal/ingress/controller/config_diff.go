@@ -0,0 +1,258 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/util/file"
+)
+
+const (
+	ingressAdded   = "added"
+	ingressRemoved = "removed"
+	ingressUpdated = "updated"
+
+	// configurationDiffWebhookTimeout is the timeout of the requests sending
+	// a configuration diff to the webhook
+	configurationDiffWebhookTimeout = 10 * time.Second
+)
+
+// diffConfiguration returns the changes of the hosts, backends and Ingresses
+// between the running configuration and the new one
+func diffConfiguration(running, pcfg *ingress.Configuration) *ingress.ConfigurationDiff {
+	diff := &ingress.ConfigurationDiff{
+		Timestamp: time.Now().UTC(),
+		Checksum:  pcfg.ConfigurationChecksum,
+	}
+
+	runningServers := make(map[string]*ingress.Server, len(running.Servers))
+	for _, server := range running.Servers {
+		runningServers[server.Hostname] = server
+	}
+	for _, server := range pcfg.Servers {
+		old, ok := runningServers[server.Hostname]
+		switch {
+		case !ok:
+			diff.HostsAdded = append(diff.HostsAdded, server.Hostname)
+		case !old.Equal(server):
+			diff.HostsChanged = append(diff.HostsChanged, server.Hostname)
+		}
+		delete(runningServers, server.Hostname)
+	}
+	for hostname := range runningServers {
+		diff.HostsRemoved = append(diff.HostsRemoved, hostname)
+	}
+
+	runningBackends := make(map[string]*ingress.Backend, len(running.Backends))
+	for _, backend := range running.Backends {
+		runningBackends[backend.Name] = backend
+	}
+	for _, backend := range pcfg.Backends {
+		old, ok := runningBackends[backend.Name]
+		switch {
+		case !ok:
+			diff.BackendsAdded = append(diff.BackendsAdded, backend.Name)
+		case !old.Equal(backend):
+			diff.BackendsChanged = append(diff.BackendsChanged, backend.Name)
+		}
+		delete(runningBackends, backend.Name)
+	}
+	for name := range runningBackends {
+		diff.BackendsRemoved = append(diff.BackendsRemoved, name)
+	}
+
+	runningIngresses := configurationIngresses(running)
+	for key, ing := range configurationIngresses(pcfg) {
+		old, ok := runningIngresses[key]
+		delete(runningIngresses, key)
+		switch {
+		case !ok:
+			diff.Ingresses = append(diff.Ingresses, ingress.IngressConfigurationDiff{
+				Ingress:     key,
+				Change:      ingressAdded,
+				Annotations: diffAnnotations(nil, ing.Annotations),
+			})
+		case old.ResourceVersion != ing.ResourceVersion:
+			diff.Ingresses = append(diff.Ingresses, ingress.IngressConfigurationDiff{
+				Ingress:     key,
+				Change:      ingressUpdated,
+				Annotations: diffAnnotations(old.Annotations, ing.Annotations),
+			})
+		}
+	}
+	for key, ing := range runningIngresses {
+		diff.Ingresses = append(diff.Ingresses, ingress.IngressConfigurationDiff{
+			Ingress:     key,
+			Change:      ingressRemoved,
+			Annotations: diffAnnotations(ing.Annotations, nil),
+		})
+	}
+
+	sort.Strings(diff.HostsAdded)
+	sort.Strings(diff.HostsRemoved)
+	sort.Strings(diff.HostsChanged)
+	sort.Strings(diff.BackendsAdded)
+	sort.Strings(diff.BackendsRemoved)
+	sort.Strings(diff.BackendsChanged)
+	sort.Slice(diff.Ingresses, func(i, j int) bool {
+		return diff.Ingresses[i].Ingress < diff.Ingresses[j].Ingress
+	})
+
+	return diff
+}
+
+// configurationIngresses returns the Ingresses of the locations of a
+// configuration by namespace and name
+func configurationIngresses(pcfg *ingress.Configuration) map[string]*ingress.Ingress {
+	ings := map[string]*ingress.Ingress{}
+	for _, server := range pcfg.Servers {
+		for _, location := range server.Locations {
+			if location.Ingress == nil || location.IsDefBackend {
+				continue
+			}
+			ings[k8s.MetaNamespaceKey(location.Ingress)] = location.Ingress
+		}
+	}
+	return ings
+}
+
+// diffAnnotations returns the annotations added, removed or changed, sorted
+// by name
+func diffAnnotations(old, annotations map[string]string) []ingress.AnnotationDiff {
+	var diff []ingress.AnnotationDiff
+	for name, value := range annotations {
+		if oldValue, ok := old[name]; !ok || oldValue != value {
+			diff = append(diff, ingress.AnnotationDiff{Name: name, Old: oldValue, New: value})
+		}
+	}
+	for name, value := range old {
+		if _, ok := annotations[name]; !ok {
+			diff = append(diff, ingress.AnnotationDiff{Name: name, Old: value})
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Name < diff[j].Name
+	})
+	return diff
+}
+
+// isEmptyConfigurationDiff returns true when the configuration changed
+// outside of the hosts, backends and Ingresses, like the TCP services
+func isEmptyConfigurationDiff(diff *ingress.ConfigurationDiff) bool {
+	return len(diff.HostsAdded)+len(diff.HostsRemoved)+len(diff.HostsChanged)+
+		len(diff.BackendsAdded)+len(diff.BackendsRemoved)+len(diff.BackendsChanged)+
+		len(diff.Ingresses) == 0
+}
+
+// configurationDiffSummary returns the number of changes of a diff for
+// the message of an event
+func configurationDiffSummary(diff *ingress.ConfigurationDiff) string {
+	changes := map[string]int{}
+	for _, ing := range diff.Ingresses {
+		changes[ing.Change]++
+	}
+
+	return fmt.Sprintf("Configuration changed (reload: %v): hosts %d added, %d removed, %d changed; backends %d added, %d removed, %d changed; Ingresses %d added, %d removed, %d updated",
+		diff.Reload,
+		len(diff.HostsAdded), len(diff.HostsRemoved), len(diff.HostsChanged),
+		len(diff.BackendsAdded), len(diff.BackendsRemoved), len(diff.BackendsChanged),
+		changes[ingressAdded], changes[ingressRemoved], changes[ingressUpdated])
+}
+
+// appendConfigurationDiff appends a diff as a line of JSON to a file
+func appendConfigurationDiff(path string, diff *ingress.ConfigurationDiff) error {
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, file.ReadWriteByUser)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// postConfigurationDiff sends a diff as JSON to a webhook
+func postConfigurationDiff(url string, diff *ingress.ConfigurationDiff) error {
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: configurationDiffWebhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// exportConfigurationDiff writes the diff between the running configuration
+// and the configuration applied by a sync to the file, webhook and events
+// enabled by the flags
+func (n *NGINXController) exportConfigurationDiff(pcfg *ingress.Configuration, reload bool) {
+	if n.cfg.ConfigurationDiffFile == "" && n.cfg.ConfigurationDiffWebhook == "" && !n.cfg.ConfigurationDiffEvents {
+		return
+	}
+
+	diff := diffConfiguration(n.runningConfig, pcfg)
+	diff.Reload = reload
+	if isEmptyConfigurationDiff(diff) {
+		return
+	}
+
+	if n.cfg.ConfigurationDiffFile != "" {
+		if err := appendConfigurationDiff(n.cfg.ConfigurationDiffFile, diff); err != nil {
+			klog.ErrorS(err, "Error writing the configuration diff", "file", n.cfg.ConfigurationDiffFile)
+		}
+	}
+
+	if n.cfg.ConfigurationDiffWebhook != "" {
+		// the sync does not wait for the webhook
+		go func() {
+			if err := postConfigurationDiff(n.cfg.ConfigurationDiffWebhook, diff); err != nil {
+				klog.ErrorS(err, "Error sending the configuration diff", "url", n.cfg.ConfigurationDiffWebhook)
+			}
+		}()
+	}
+
+	if n.cfg.ConfigurationDiffEvents {
+		n.recorder.Event(k8s.IngressPodDetails, apiv1.EventTypeNormal, "ConfigurationDiff", configurationDiffSummary(diff))
+	}
+}
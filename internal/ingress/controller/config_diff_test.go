@@ -0,0 +1,201 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func diffIngress(name, resourceVersion string, annotations map[string]string) *ingress.Ingress {
+	return &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            name,
+				ResourceVersion: resourceVersion,
+				Annotations:     annotations,
+			},
+		},
+	}
+}
+
+func diffServer(hostname, backend string, ing *ingress.Ingress) *ingress.Server {
+	return &ingress.Server{
+		Hostname: hostname,
+		Locations: []*ingress.Location{
+			{Path: "/", Backend: backend, Ingress: ing},
+		},
+	}
+}
+
+func TestDiffConfiguration(t *testing.T) {
+	app := diffIngress("app", "1", map[string]string{
+		"nginx.ingress.kubernetes.io/rewrite-target": "/",
+		"nginx.ingress.kubernetes.io/ssl-redirect":   "false",
+	})
+	updatedApp := diffIngress("app", "2", map[string]string{
+		"nginx.ingress.kubernetes.io/rewrite-target":  "/$1",
+		"nginx.ingress.kubernetes.io/proxy-body-size": "8m",
+	})
+	old := diffIngress("old", "1", nil)
+	api := diffIngress("api", "1", map[string]string{"nginx.ingress.kubernetes.io/enable-cors": "true"})
+
+	running := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			diffServer("app.example.com", "default-app-80", app),
+			diffServer("old.example.com", "default-old-80", old),
+		},
+		Backends: []*ingress.Backend{
+			{Name: "default-app-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "80"}}},
+			{Name: "default-old-80"},
+		},
+	}
+	pcfg := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			diffServer("app.example.com", "default-app-80", updatedApp),
+			diffServer("api.example.com", "default-api-80", api),
+		},
+		Backends: []*ingress.Backend{
+			{Name: "default-app-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.2", Port: "80"}}},
+			{Name: "default-api-80"},
+		},
+		ConfigurationChecksum: "42",
+	}
+	pcfg.Servers[0].Locations = append(pcfg.Servers[0].Locations, &ingress.Location{Path: "/v2", Backend: "default-app-80", Ingress: updatedApp})
+
+	diff := diffConfiguration(running, pcfg)
+	diff.Timestamp = time.Time{}
+
+	expected := &ingress.ConfigurationDiff{
+		Checksum:        "42",
+		HostsAdded:      []string{"api.example.com"},
+		HostsRemoved:    []string{"old.example.com"},
+		HostsChanged:    []string{"app.example.com"},
+		BackendsAdded:   []string{"default-api-80"},
+		BackendsRemoved: []string{"default-old-80"},
+		BackendsChanged: []string{"default-app-80"},
+		Ingresses: []ingress.IngressConfigurationDiff{
+			{
+				Ingress: "default/api",
+				Change:  ingressAdded,
+				Annotations: []ingress.AnnotationDiff{
+					{Name: "nginx.ingress.kubernetes.io/enable-cors", New: "true"},
+				},
+			},
+			{
+				Ingress: "default/app",
+				Change:  ingressUpdated,
+				Annotations: []ingress.AnnotationDiff{
+					{Name: "nginx.ingress.kubernetes.io/proxy-body-size", New: "8m"},
+					{Name: "nginx.ingress.kubernetes.io/rewrite-target", Old: "/", New: "/$1"},
+					{Name: "nginx.ingress.kubernetes.io/ssl-redirect", Old: "false"},
+				},
+			},
+			{
+				Ingress: "default/old",
+				Change:  ingressRemoved,
+			},
+		},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff\n%+v\nbut returned\n%+v", expected, diff)
+	}
+
+	if !isEmptyConfigurationDiff(diffConfiguration(pcfg, pcfg)) {
+		t.Errorf("expected no changes between a configuration and itself")
+	}
+
+	summary := configurationDiffSummary(diff)
+	if !strings.Contains(summary, "hosts 1 added, 1 removed, 1 changed") || !strings.Contains(summary, "Ingresses 1 added, 1 removed, 1 updated") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func TestAppendConfigurationDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.json")
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		if err := appendConfigurationDiff(path, &ingress.ConfigurationDiff{HostsAdded: []string{host}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but the file contains %q", data)
+	}
+
+	diff := &ingress.ConfigurationDiff{}
+	if err := json.Unmarshal([]byte(lines[1]), diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(diff.HostsAdded, []string{"b.example.com"}) {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+}
+
+func TestPostConfigurationDiff(t *testing.T) {
+	received := make(chan *ingress.ConfigurationDiff, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		diff := &ingress.ConfigurationDiff{}
+		if err := json.NewDecoder(r.Body).Decode(diff); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- diff
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if err := postConfigurationDiff(server.URL, &ingress.ConfigurationDiff{Reload: true, BackendsAdded: []string{"default-app-80"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	diff := <-received
+	if !diff.Reload || !reflect.DeepEqual(diff.BackendsAdded, []string{"default-app-80"}) {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := postConfigurationDiff(failing.URL, &ingress.ConfigurationDiff{}); err == nil {
+		t.Errorf("expected an error sending a diff to a failing webhook")
+	}
+}
@@ -0,0 +1,458 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/conflictresolution"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/schedule"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+const (
+	// ConflictsReject rejects the Ingresses conflicting with other Ingresses
+	ConflictsReject = "reject"
+	// ConflictsWarn admits the Ingresses conflicting with other Ingresses with a warning
+	ConflictsWarn = "warn"
+)
+
+type hostPathType struct {
+	host     string
+	path     string
+	pathType string
+}
+
+func newHostPathType(host string, path networking.HTTPIngressPath) hostPathType {
+	key := hostPathType{host: host, path: path.Path}
+	if key.host == "" {
+		key.host = defServerName
+	}
+	if key.path == "" {
+		key.path = rootLocation
+	}
+	if path.PathType != nil {
+		key.pathType = string(*path.PathType)
+	}
+	return key
+}
+
+// ingressHostPaths returns the hosts, paths and path types of the rules of
+// an Ingress using a Service backend
+func ingressHostPaths(ing *networking.Ingress) []hostPathType {
+	var hostPaths []hostPathType
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				continue
+			}
+			hostPaths = append(hostPaths, newHostPathType(rule.Host, path))
+		}
+	}
+	return hostPaths
+}
+
+func isCanaryIngress(ing *ingress.Ingress) bool {
+	return ing.ParsedAnnotations != nil && ing.ParsedAnnotations.Canary.Enabled
+}
+
+func ingressAliases(ing *ingress.Ingress) []string {
+	if ing.ParsedAnnotations == nil {
+		return nil
+	}
+	return ing.ParsedAnnotations.Aliases
+}
+
+func ingressSchedule(ing *ingress.Ingress) schedule.Config {
+	if ing.ParsedAnnotations == nil {
+		return schedule.Config{}
+	}
+	return ing.ParsedAnnotations.Schedule
+}
+
+// ingressConflicts returns the conflicts of an Ingress with the other
+// Ingresses: a host and path already defined by another Ingress, the host
+// and path of a canary Ingress without a primary Ingress, and a server alias
+// already used as host or server alias by another Ingress. The Ingresses
+// not applied at the same time after now, with the apply-at and expire-at
+// annotations, are not conflicting.
+func ingressConflicts(ing *ingress.Ingress, others []*ingress.Ingress, now time.Time) []string {
+	key := k8s.MetaNamespaceKey(&ing.Ingress)
+	period := ingressSchedule(ing)
+
+	hostPaths := make(map[hostPathType][]*ingress.Ingress)
+	hosts := make(map[string]sets.Set[string])
+	aliases := make(map[string]sets.Set[string])
+	insert := func(m map[string]sets.Set[string], name, ingKey string) {
+		if m[name] == nil {
+			m[name] = sets.New[string]()
+		}
+		m[name].Insert(ingKey)
+	}
+
+	for _, other := range others {
+		otherKey := k8s.MetaNamespaceKey(&other.Ingress)
+		if otherKey == key || !period.Overlaps(ingressSchedule(other), now) {
+			continue
+		}
+
+		for _, hp := range ingressHostPaths(&other.Ingress) {
+			hostPaths[hp] = append(hostPaths[hp], other)
+		}
+		for _, rule := range other.Spec.Rules {
+			if rule.Host != "" {
+				insert(hosts, rule.Host, otherKey)
+			}
+		}
+		for _, alias := range ingressAliases(other) {
+			insert(aliases, alias, otherKey)
+		}
+	}
+
+	var conflicts []string
+	canary := isCanaryIngress(ing)
+	checked := sets.New[hostPathType]()
+	for _, hp := range ingressHostPaths(&ing.Ingress) {
+		if checked.Has(hp) {
+			continue
+		}
+		checked.Insert(hp)
+
+		duplicates := sets.New[string]()
+		hasPrimary := false
+		for _, other := range hostPaths[hp] {
+			otherCanary := isCanaryIngress(other)
+			if !otherCanary {
+				hasPrimary = true
+			}
+			// a canary Ingress shares the host and path of its primary Ingress
+			if otherCanary == canary {
+				duplicates.Insert(k8s.MetaNamespaceKey(&other.Ingress))
+			}
+		}
+
+		if duplicates.Len() > 0 {
+			conflicts = append(conflicts, fmt.Sprintf(`host "%s" and path "%s" is already defined in ingress %s`,
+				hp.host, hp.path, strings.Join(sets.List(duplicates), ", ")))
+		}
+		if canary && !hasPrimary {
+			conflicts = append(conflicts, fmt.Sprintf(`canary host "%s" and path "%s" is not defined in any primary ingress`,
+				hp.host, hp.path))
+		}
+	}
+
+	for _, alias := range ingressAliases(ing) {
+		if users, ok := hosts[alias]; ok {
+			conflicts = append(conflicts, fmt.Sprintf(`server alias "%s" is already defined as host in ingress %s`,
+				alias, strings.Join(sets.List(users), ", ")))
+		}
+		if users, ok := aliases[alias]; ok {
+			conflicts = append(conflicts, fmt.Sprintf(`server alias "%s" is already defined in ingress %s`,
+				alias, strings.Join(sets.List(users), ", ")))
+		}
+	}
+
+	checkedHosts := sets.New[string]()
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host == "" || checkedHosts.Has(rule.Host) {
+			continue
+		}
+		checkedHosts.Insert(rule.Host)
+
+		if users, ok := aliases[rule.Host]; ok {
+			conflicts = append(conflicts, fmt.Sprintf(`host "%s" is already defined as server alias in ingress %s`,
+				rule.Host, strings.Join(sets.List(users), ", ")))
+		}
+	}
+
+	return conflicts
+}
+
+func ingressPriority(ing *ingress.Ingress) int {
+	if ing.ParsedAnnotations == nil {
+		return 0
+	}
+	return ing.ParsedAnnotations.ConflictPriority
+}
+
+// resolveIngressConflicts chooses with a strategy of the
+// ingress-conflict-resolution option the Ingress serving a host and path
+// defined by several Ingresses, ordered from the oldest to the newest. The
+// host and path are removed from the copies of the other Ingresses, and
+// returned as conflicts. Canary Ingresses are not conflicting.
+func resolveIngressConflicts(ings []*ingress.Ingress, strategy string) ([]*ingress.Ingress, []ingress.IngressConflict) {
+	var keys []hostPathType
+	definitions := make(map[hostPathType][]int)
+	for i, ing := range ings {
+		if isCanaryIngress(ing) {
+			continue
+		}
+
+		for _, key := range ingressHostPaths(&ing.Ingress) {
+			defined := definitions[key]
+			if len(defined) == 0 {
+				keys = append(keys, key)
+			}
+			if len(defined) > 0 && defined[len(defined)-1] == i {
+				continue
+			}
+			definitions[key] = append(defined, i)
+		}
+	}
+
+	var conflicts []ingress.IngressConflict
+	dropped := make(map[int]sets.Set[hostPathType])
+	for _, key := range keys {
+		defined := definitions[key]
+		if len(defined) < 2 {
+			continue
+		}
+
+		winner := -1
+		switch strategy {
+		case conflictresolution.ResolveNewest:
+			winner = defined[len(defined)-1]
+		case conflictresolution.ResolvePriority:
+			winner = defined[0]
+			for _, i := range defined[1:] {
+				if ingressPriority(ings[i]) > ingressPriority(ings[winner]) {
+					winner = i
+				}
+			}
+		case conflictresolution.ResolveReject:
+		default:
+			winner = defined[0]
+		}
+
+		winnerKey := ""
+		if winner >= 0 {
+			winnerKey = k8s.MetaNamespaceKey(ings[winner])
+		}
+
+		for _, i := range defined {
+			if i == winner {
+				continue
+			}
+			if dropped[i] == nil {
+				dropped[i] = sets.New[hostPathType]()
+			}
+			dropped[i].Insert(key)
+			conflicts = append(conflicts, ingress.IngressConflict{
+				Ingress: k8s.MetaNamespaceKey(ings[i]),
+				Host:    key.host,
+				Path:    key.path,
+				Winner:  winnerKey,
+			})
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return ings, nil
+	}
+
+	resolved := make([]*ingress.Ingress, 0, len(ings))
+	for i, ing := range ings {
+		if dropped[i] == nil {
+			resolved = append(resolved, ing)
+			continue
+		}
+		resolved = append(resolved, withoutHostPaths(ing, dropped[i]))
+	}
+	return resolved, conflicts
+}
+
+// withoutHostPaths returns a copy of an Ingress without some hosts and paths
+func withoutHostPaths(ing *ingress.Ingress, hostPaths sets.Set[hostPathType]) *ingress.Ingress {
+	copied := *ing
+	copied.Ingress = *ing.Ingress.DeepCopy()
+
+	for _, rule := range copied.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		paths := rule.HTTP.Paths[:0]
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && hostPaths.Has(newHostPathType(rule.Host, path)) {
+				continue
+			}
+			paths = append(paths, path)
+		}
+		rule.HTTP.Paths = paths
+	}
+	return &copied
+}
+
+// resolveIngressConflicts resolves the conflicts between Ingresses with the
+// strategy of the ingress-conflict-resolution option
+func (n *NGINXController) resolveIngressConflicts(ings []*ingress.Ingress) ([]*ingress.Ingress, []ingress.IngressConflict) {
+	return resolveIngressConflicts(ings, n.store.GetBackendConfiguration().IngressConflictResolution)
+}
+
+// recordIngressConflicts logs and records an Event for the Ingresses with a
+// host and path not served since the last sync
+func (n *NGINXController) recordIngressConflicts(ings []*ingress.Ingress, conflicts []ingress.IngressConflict) {
+	ingresses := make(map[string]*ingress.Ingress, len(ings))
+	for _, ing := range ings {
+		ingresses[k8s.MetaNamespaceKey(ing)] = ing
+	}
+
+	current := sets.New[ingress.IngressConflict]()
+	for _, conflict := range conflicts {
+		current.Insert(conflict)
+		if n.ingressConflicts.Has(conflict) {
+			continue
+		}
+
+		message := fmt.Sprintf("Host %q and path %q are defined by several ingresses and are not served by any of them", conflict.Host, conflict.Path)
+		if conflict.Winner != "" {
+			message = fmt.Sprintf("Host %q and path %q are served by ingress %v", conflict.Host, conflict.Path, conflict.Winner)
+		}
+		klog.Warningf("Ingress %q: %v", conflict.Ingress, message)
+
+		ing, ok := ingresses[conflict.Ingress]
+		if !ok || n.recorder == nil {
+			continue
+		}
+		n.recorder.Event(&ing.Ingress, apiv1.EventTypeWarning, "IngressConflict", message)
+	}
+
+	n.ingressConflicts = current
+}
+
+// servedHosts returns the hosts of the rules of an Ingress, or the default
+// server when it only defines a default backend
+func servedHosts(ing *ingress.Ingress) []string {
+	if len(ing.Spec.Rules) == 0 && ing.Spec.DefaultBackend != nil {
+		return []string{defServerName}
+	}
+
+	hosts := make([]string, 0, len(ing.Spec.Rules))
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// isolateIngressClasses keeps the servers of the IngressClasses listening on
+// their own ports isolated from the servers of the other classes. A host is
+// served by the class of the oldest Ingress defining it, and is removed from
+// the copies of the Ingresses of other classes when one of the classes is
+// isolated. The default server is shared by all the classes, so the rules
+// without host of the isolated classes are removed too. The Ingresses left
+// without hosts are removed. The hosts removed are returned as conflicts.
+func isolateIngressClasses(ings []*ingress.Ingress, isolated func(class string) bool) ([]*ingress.Ingress, []ingress.IngressConflict) {
+	owners := make(map[string]*ingress.Ingress)
+	for _, ing := range ings {
+		for _, host := range servedHosts(ing) {
+			if _, ok := owners[host]; !ok && (host != defServerName || !isolated(ing.IngressClass)) {
+				owners[host] = ing
+			}
+		}
+	}
+
+	var conflicts []ingress.IngressConflict
+	resolved := make([]*ingress.Ingress, 0, len(ings))
+	for _, ing := range ings {
+		hosts := servedHosts(ing)
+		dropped := sets.New[string]()
+		for _, host := range hosts {
+			owner, ok := owners[host]
+			if ok && (owner.IngressClass == ing.IngressClass || (!isolated(owner.IngressClass) && !isolated(ing.IngressClass))) {
+				continue
+			}
+			dropped.Insert(host)
+		}
+
+		paths := make(map[string][]string)
+		for _, hp := range ingressHostPaths(&ing.Ingress) {
+			paths[hp.host] = append(paths[hp.host], hp.path)
+		}
+		for _, host := range sets.List(dropped) {
+			hostPaths := paths[host]
+			if len(hostPaths) == 0 {
+				hostPaths = []string{rootLocation}
+			}
+			winner := ""
+			if owner, ok := owners[host]; ok {
+				winner = k8s.MetaNamespaceKey(owner)
+			}
+			for _, path := range hostPaths {
+				conflicts = append(conflicts, ingress.IngressConflict{
+					Ingress: k8s.MetaNamespaceKey(ing),
+					Host:    host,
+					Path:    path,
+					Winner:  winner,
+				})
+			}
+		}
+
+		switch {
+		case dropped.Len() == 0:
+			resolved = append(resolved, ing)
+		case dropped.Len() < sets.New(hosts...).Len():
+			resolved = append(resolved, withoutHosts(ing, dropped))
+		default:
+			klog.Warningf("Ignoring Ingress %v: none of its hosts can be served by its IngressClass %q", k8s.MetaNamespaceKey(ing), ing.IngressClass)
+		}
+	}
+	return resolved, conflicts
+}
+
+// withoutHosts returns a copy of an Ingress without the rules of some hosts
+func withoutHosts(ing *ingress.Ingress, hosts sets.Set[string]) *ingress.Ingress {
+	copied := *ing
+	copied.Ingress = *ing.Ingress.DeepCopy()
+
+	rules := copied.Spec.Rules[:0]
+	for _, rule := range copied.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
+		if !hosts.Has(host) {
+			rules = append(rules, rule)
+		}
+	}
+	copied.Spec.Rules = rules
+	return &copied
+}
+
+// isolateIngressClasses isolates the servers of the IngressClasses listening
+// on their own ports
+func (n *NGINXController) isolateIngressClasses(ings []*ingress.Ingress) ([]*ingress.Ingress, []ingress.IngressConflict) {
+	classPorts := n.ingressClassListenPorts()
+	return isolateIngressClasses(ings, func(class string) bool {
+		return classPorts[class] != nil
+	})
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/conflictresolution"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/schedule"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func conflictIngress(namespace, name, host string, isCanary bool, aliases ...string) *ingress.Ingress {
+	pathType := networking.PathTypePrefix
+	return &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{
+					Host: host,
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{{
+								Path:     "/",
+								PathType: &pathType,
+								Backend: networking.IngressBackend{
+									Service: &networking.IngressServiceBackend{Name: "svc"},
+								},
+							}},
+						},
+					},
+				}},
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{
+			Canary:  canary.Config{Enabled: isCanary},
+			Aliases: aliases,
+		},
+	}
+}
+
+func withPathType(ing *ingress.Ingress, pathType networking.PathType) *ingress.Ingress {
+	ing.Spec.Rules[0].HTTP.Paths[0].PathType = &pathType
+	return ing
+}
+
+func withSchedule(ing *ingress.Ingress, applyAt, expireAt time.Time) *ingress.Ingress {
+	ing.ParsedAnnotations.Schedule = schedule.Config{ApplyAt: applyAt, ExpireAt: expireAt}
+	return ing
+}
+
+func TestIngressConflicts(t *testing.T) {
+	cutover := time.Now().Add(time.Hour)
+
+	testCases := []struct {
+		name     string
+		ing      *ingress.Ingress
+		others   []*ingress.Ingress
+		expected []string
+	}{
+		{
+			name:   "no other ingress",
+			ing:    conflictIngress("a", "web", "example.com", false),
+			others: nil,
+		},
+		{
+			name:   "same ingress",
+			ing:    conflictIngress("a", "web", "example.com", false),
+			others: []*ingress.Ingress{conflictIngress("a", "web", "example.com", false)},
+		},
+		{
+			name: "host and path defined in other namespaces",
+			ing:  conflictIngress("a", "web", "example.com", false),
+			others: []*ingress.Ingress{
+				conflictIngress("c", "web", "example.com", false),
+				conflictIngress("b", "web", "example.com", false),
+				conflictIngress("b", "other", "other.example.com", false),
+			},
+			expected: []string{`host "example.com" and path "/" is already defined in ingress b/web, c/web`},
+		},
+		{
+			name:   "same host and path with another path type",
+			ing:    withPathType(conflictIngress("a", "web", "example.com", false), networking.PathTypeExact),
+			others: []*ingress.Ingress{conflictIngress("b", "web", "example.com", false)},
+		},
+		{
+			name:   "ingress applied when the other ingress expires",
+			ing:    withSchedule(conflictIngress("a", "new", "example.com", false), cutover, time.Time{}),
+			others: []*ingress.Ingress{withSchedule(conflictIngress("a", "current", "example.com", false), time.Time{}, cutover)},
+		},
+		{
+			name:     "ingress applied before the other ingress expires",
+			ing:      withSchedule(conflictIngress("a", "new", "example.com", false), cutover.Add(-time.Minute), time.Time{}),
+			others:   []*ingress.Ingress{withSchedule(conflictIngress("a", "current", "example.com", false), time.Time{}, cutover)},
+			expected: []string{`host "example.com" and path "/" is already defined in ingress a/current`},
+		},
+		{
+			name:   "canary with a primary ingress",
+			ing:    conflictIngress("a", "canary", "example.com", true),
+			others: []*ingress.Ingress{conflictIngress("a", "web", "example.com", false)},
+		},
+		{
+			name:     "canary without a primary ingress",
+			ing:      conflictIngress("a", "canary", "example.com", true),
+			others:   []*ingress.Ingress{conflictIngress("a", "web", "other.example.com", false)},
+			expected: []string{`canary host "example.com" and path "/" is not defined in any primary ingress`},
+		},
+		{
+			name: "two canaries",
+			ing:  conflictIngress("a", "canary", "example.com", true),
+			others: []*ingress.Ingress{
+				conflictIngress("a", "web", "example.com", false),
+				conflictIngress("b", "canary", "example.com", true),
+			},
+			expected: []string{`host "example.com" and path "/" is already defined in ingress b/canary`},
+		},
+		{
+			name: "server alias collisions",
+			ing:  conflictIngress("a", "web", "example.com", false, "www.example.com", "old.example.com"),
+			others: []*ingress.Ingress{
+				conflictIngress("b", "www", "www.example.com", false),
+				conflictIngress("c", "old", "legacy.example.com", false, "old.example.com", "example.com"),
+			},
+			expected: []string{
+				`server alias "www.example.com" is already defined as host in ingress b/www`,
+				`server alias "old.example.com" is already defined in ingress c/old`,
+				`host "example.com" is already defined as server alias in ingress c/old`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conflicts := ingressConflicts(tc.ing, tc.others, time.Now())
+			if !reflect.DeepEqual(conflicts, tc.expected) {
+				t.Errorf("expected conflicts %q but got %q", tc.expected, conflicts)
+			}
+		})
+	}
+}
+
+func TestResolveIngressConflicts(t *testing.T) {
+	withPriority := func(ing *ingress.Ingress, priority int) *ingress.Ingress {
+		ing.ParsedAnnotations.ConflictPriority = priority
+		return ing
+	}
+
+	newIngresses := func() []*ingress.Ingress {
+		return []*ingress.Ingress{
+			conflictIngress("a", "oldest", "example.com", false),
+			withPriority(conflictIngress("b", "middle", "example.com", false), 10),
+			conflictIngress("c", "newest", "example.com", false),
+			conflictIngress("c", "canary", "example.com", true),
+			conflictIngress("c", "other", "other.example.com", false),
+		}
+	}
+
+	testCases := []struct {
+		strategy string
+		served   []string
+		winner   string
+	}{
+		{"", []string{"a/oldest", "c/canary", "c/other"}, "a/oldest"},
+		{conflictresolution.ResolveOldest, []string{"a/oldest", "c/canary", "c/other"}, "a/oldest"},
+		{conflictresolution.ResolveNewest, []string{"c/newest", "c/canary", "c/other"}, "c/newest"},
+		{conflictresolution.ResolvePriority, []string{"b/middle", "c/canary", "c/other"}, "b/middle"},
+		{conflictresolution.ResolveReject, []string{"c/canary", "c/other"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			ings := newIngresses()
+			resolved, conflicts := resolveIngressConflicts(ings, tc.strategy)
+
+			var served []string
+			for _, ing := range resolved {
+				if len(ing.Spec.Rules[0].HTTP.Paths) > 0 {
+					served = append(served, k8s.MetaNamespaceKey(ing))
+				}
+			}
+			if !reflect.DeepEqual(served, tc.served) {
+				t.Errorf("expected the ingresses %v to serve their paths but returned %v", tc.served, served)
+			}
+
+			for _, conflict := range conflicts {
+				if conflict.Winner != tc.winner || conflict.Host != "example.com" || conflict.Path != "/" {
+					t.Errorf("unexpected conflict %+v", conflict)
+				}
+			}
+			if len(conflicts) != len(ings)-len(tc.served) {
+				t.Errorf("expected %v conflicts but returned %v", len(ings)-len(tc.served), len(conflicts))
+			}
+
+			for _, ing := range ings {
+				if len(ing.Spec.Rules[0].HTTP.Paths) == 0 {
+					t.Errorf("expected the ingress %v not to be modified", k8s.MetaNamespaceKey(ing))
+				}
+			}
+		})
+	}
+}
+
+func TestResolveIngressConflictsWithoutConflicts(t *testing.T) {
+	ings := []*ingress.Ingress{
+		conflictIngress("a", "web", "example.com", false),
+		conflictIngress("a", "api", "api.example.com", false),
+	}
+
+	resolved, conflicts := resolveIngressConflicts(ings, conflictresolution.ResolveReject)
+	if len(conflicts) != 0 {
+		t.Errorf("expected no conflict but returned %v", conflicts)
+	}
+	if !reflect.DeepEqual(resolved, ings) {
+		t.Errorf("expected the ingresses to be unchanged")
+	}
+}
+
+func TestIsolateIngressClasses(t *testing.T) {
+	withClass := func(ing *ingress.Ingress, class string) *ingress.Ingress {
+		ing.IngressClass = class
+		return ing
+	}
+	isolated := func(class string) bool {
+		return class == "internal"
+	}
+
+	shared := withClass(conflictIngress("a", "shared", "example.com", false), "nginx")
+	sharedInternal := withClass(conflictIngress("b", "shared", "example.com", false), "internal")
+	internalRule := conflictIngress("b", "shared", "internal.example.com", false).Spec.Rules[0]
+	sharedInternal.Spec.Rules = append(sharedInternal.Spec.Rules, internalRule)
+	catchAll := withClass(conflictIngress("b", "catch-all", "", false), "internal")
+	public := withClass(conflictIngress("c", "public", "example.com", false), "public")
+
+	ings := []*ingress.Ingress{shared, sharedInternal, catchAll, public}
+	resolved, conflicts := isolateIngressClasses(ings, isolated)
+
+	var served []string
+	for _, ing := range resolved {
+		served = append(served, k8s.MetaNamespaceKey(ing))
+	}
+	if expected := []string{"a/shared", "b/shared", "c/public"}; !reflect.DeepEqual(served, expected) {
+		t.Fatalf("expected the ingresses %v but returned %v", expected, served)
+	}
+	if hosts := resolved[1].Spec.Rules; len(hosts) != 1 || hosts[0].Host != "internal.example.com" {
+		t.Errorf("expected only the host internal.example.com to be served but returned %v", hosts)
+	}
+	if len(sharedInternal.Spec.Rules) != 2 {
+		t.Errorf("expected the ingress not to be modified")
+	}
+
+	expected := []ingress.IngressConflict{
+		{Ingress: "b/shared", Host: "example.com", Path: "/", Winner: "a/shared"},
+		{Ingress: "b/catch-all", Host: "_", Path: "/"},
+	}
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("expected conflicts %v but returned %v", expected, conflicts)
+	}
+
+	resolved, conflicts = isolateIngressClasses([]*ingress.Ingress{shared, public}, isolated)
+	if len(conflicts) != 0 || !reflect.DeepEqual(resolved, []*ingress.Ingress{shared, public}) {
+		t.Errorf("expected the classes without own ports to share hosts but returned %v", conflicts)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"crypto/tls"
 	"fmt"
 	"sort"
 	"strconv"
@@ -31,16 +32,18 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/serviceupstream"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
-	"k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/inspector"
 	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
 	"k8s.io/ingress-nginx/internal/k8s"
@@ -67,6 +70,9 @@ type Configuration struct {
 	KubeConfigFile string
 
 	Client clientset.Interface
+	// DynamicClient is used to watch TCPRoute and UDPRoute resources.
+	// Stream routes are disabled when it is nil.
+	DynamicClient dynamic.Interface
 
 	ResyncPeriod time.Duration
 
@@ -81,6 +87,14 @@ type Configuration struct {
 	TCPConfigMapName string
 	// +optional
 	UDPConfigMapName string
+	// +optional
+	DefaultAnnotationsConfigMapName string
+	// +optional
+	DrainedEndpointsConfigMapName string
+	// +optional
+	MetricsLabelsConfigMapName string
+	// +optional
+	ReloadFreezeConfigMapName string
 
 	DefaultSSLCertificate string
 
@@ -114,6 +128,21 @@ type Configuration struct {
 	ReportStatusClasses     bool
 	ExcludeSocketMetrics    []string
 
+	// MetricsTLSSecret is the Secret with the certificate of the metrics
+	// endpoint served over TLS on the Metrics port, in the form "namespace/name"
+	MetricsTLSSecret string
+	// MetricsClientCert requires the scrapers to present a certificate signed
+	// by the ca.crt of MetricsTLSSecret
+	MetricsClientCert bool
+	// MetricsTokenFile contains the bearer token required to scrape the metrics
+	MetricsTokenFile string
+	// EnableTenantMetrics exposes the metrics of the namespace of the
+	// ServiceAccount authenticated by the token of the scraper
+	EnableTenantMetrics bool
+	// TenantMetricsAudience is the audience the tokens of the scrapers of
+	// the tenant metrics must be issued for
+	TenantMetricsAudience string
+
 	FakeCertificate *ingress.SSLCert
 
 	SyncRateLimit float32
@@ -126,6 +155,12 @@ type Configuration struct {
 	ValidationWebhookCertPath string
 	ValidationWebhookKeyPath  string
 	DisableFullValidationTest bool
+	// ValidationWebhookConflicts is the action, ConflictsReject or ConflictsWarn,
+	// on the conflicts of an Ingress with the other Ingresses
+	ValidationWebhookConflicts string
+	// ValidationWebhookExpansion adds the server and location blocks
+	// of an Ingress to the audit annotations of the webhook response
+	ValidationWebhookExpansion bool
 
 	GlobalExternalAuth  *ngx_config.GlobalExternalAuth
 	MaxmindEditionFiles *[]string
@@ -144,6 +179,86 @@ type Configuration struct {
 	DisableSyncEvents bool
 
 	EnableTopologyAwareRouting bool
+
+	EnableConfigurationAPI    bool
+	ConfigurationAPITokenFile string
+
+	// ConfigurationHandoffSocket is the unix socket the running
+	// configuration is fetched from at start, and handed off on once synced
+	ConfigurationHandoffSocket  string
+	ConfigurationHandoffTimeout time.Duration
+
+	EnableCachePurgeAPI    bool
+	CachePurgeAPITokenFile string
+
+	EnableStreamRoutes bool
+
+	// EnableChaosInjection allows the chaos annotations to inject faults
+	EnableChaosInjection bool
+
+	EnableErrorPages    bool
+	ErrorPagesTemplates string
+
+	// SpiffeWorkloadAPISocket is the socket of the SPIFFE Workload API
+	// providing the SVIDs used with proxy-ssl-spiffe-id
+	SpiffeWorkloadAPISocket string
+
+	EnableEndpointDrainAPI    bool
+	EndpointDrainAPITokenFile string
+
+	// ReloadFreezeWindows are the time ranges during which only the
+	// endpoints and the certificate renewals are applied
+	ReloadFreezeWindows      []FreezeWindow
+	EnableReloadFreezeAPI    bool
+	ReloadFreezeAPITokenFile string
+
+	// EnableCertificateDiscovery uses the certificates of the Secrets
+	// labeled with the domain of a host for the hosts of the Ingresses
+	// without TLS section
+	EnableCertificateDiscovery bool
+	// CertificateDiscoveryCAFile contains the CA certificates the
+	// discovered certificates are verified against, instead of the system ones
+	CertificateDiscoveryCAFile string
+
+	// EnableChangeApproval stages the high-impact changes of the Ingresses
+	// until they are approved or ChangeApprovalTimeout expires, 0 waiting
+	// for an approval
+	EnableChangeApproval       bool
+	ChangeApprovalTimeout      time.Duration
+	EnableChangeApprovalAPI    bool
+	ChangeApprovalAPITokenFile string
+	// ChangeApprovalConfigMapName is the ConfigMap sharing the staged and
+	// the approved changes between the replicas, in the form namespace/name
+	ChangeApprovalConfigMapName string
+
+	// LuaPluginsConfigMapName is the ConfigMap containing the code of the
+	// Lua plugins, in the form namespace/name
+	LuaPluginsConfigMapName string
+
+	EnableRouteRegressionCheck bool
+	RouteRegressionSamples     int
+	// RouteRegressionTimeout is the maximum time the route regressions
+	// hold the reload of a new configuration, 0 holds it indefinitely
+	RouteRegressionTimeout time.Duration
+
+	// SyntheticProbeInterval is the interval of the synthetic probes of the
+	// Ingresses, 0 disables them
+	SyntheticProbeInterval time.Duration
+	SyntheticProbeTimeout  time.Duration
+
+	// ConfigSnapshots is the number of configurations applied successfully
+	// kept to roll back the Ingresses NGINX rejects, 0 disables the rollback
+	ConfigSnapshots int
+
+	// ShadowMode builds and validates the configuration without
+	// writing to the Ingresses, routes or leader election lease
+	ShadowMode bool
+
+	// ConfigurationDiffFile, ConfigurationDiffWebhook and ConfigurationDiffEvents
+	// export the changes of the configuration applied by every sync
+	ConfigurationDiffFile    string
+	ConfigurationDiffWebhook string
+	ConfigurationDiffEvents  bool
 }
 
 func getIngressPodZone(svc *apiv1.Service) string {
@@ -179,20 +294,70 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		return nil
 	}
 
-	ings := n.store.ListIngresses()
+	ings, classConflicts := n.isolateIngressClasses(n.rollBackIngresses(n.activeIngresses(n.stageHighImpactChanges(n.store.ListIngresses()))))
+	ings, conflicts := n.resolveIngressConflicts(ings)
+	conflicts = append(classConflicts, conflicts...)
 	hosts, servers, pcfg := n.getConfiguration(ings)
+	n.syncRevocationChecks(servers)
 
 	n.metricCollector.SetSSLExpireTime(servers)
 	n.metricCollector.SetSSLInfo(servers)
+	n.metricCollector.SetDefaultAnnotationOverrides(ings)
+	n.metricCollector.SetSSLCertificateFallbacks(servers)
+	n.metricCollector.SetSlowStartEndpoints(pcfg.Backends)
+	n.metricCollector.SetMetricLabels(n.getMetricLabels(ings))
+	n.metricCollector.SetIngressConflicts(conflicts)
+	n.recordSSLCertificateFallbacks(ings, servers)
+	n.recordIngressConflicts(ings, conflicts)
 
-	if n.runningConfig.Equal(pcfg) {
+	n.scheduleDrainExpiry(n.getDrainedEndpoints())
+
+	freezeWindows := n.getReloadFreezeWindows()
+	n.scheduleReloadFreezeChange(freezeWindows)
+
+	if !n.configurationHandedOff && n.runningConfig.Equal(pcfg) {
 		klog.V(3).Infof("No configuration change detected, skipping backend reload")
+		n.reloadFreezePending.Store(false)
+		n.recordAppliedIngresses(ings)
 		return nil
 	}
 
+	// the first sync is never frozen, NGINX has no configuration yet or the
+	// one handed off
+	freeze, frozen := activeFreezeWindow(freezeWindows, time.Now())
+	frozen = frozen && !n.runningConfig.Equal(&ingress.Configuration{}) && !n.configurationHandedOff
+	if frozen {
+		// only the endpoints and the certificate renewals are applied, the
+		// other changes are applied when the freeze window ends
+		pcfg = frozenConfiguration(n.runningConfig, pcfg)
+		n.reloadFreezePending.Store(true)
+		klog.InfoS("Configuration changes kept until the end of the reload freeze", "until", freeze.End, "reason", freeze.Reason)
+
+		if n.runningConfig.Equal(pcfg) {
+			return nil
+		}
+	} else {
+		n.reloadFreezePending.Store(false)
+	}
+
 	n.metricCollector.SetHosts(hosts)
 
-	if !utilingress.IsDynamicConfigurationEnough(pcfg, n.runningConfig) {
+	reload := n.reloadRequired(pcfg)
+	var regressionErr error
+	if reload && n.cfg.EnableRouteRegressionCheck {
+		regressionErr = n.checkRouteRegressions(ings, pcfg)
+		if regressionErr != nil {
+			// only the endpoints and the certificate renewals are applied
+			// until the route regressions are solved or the check expires
+			pcfg = frozenConfiguration(n.runningConfig, pcfg)
+			if n.runningConfig.Equal(pcfg) {
+				return regressionErr
+			}
+			reload = n.reloadRequired(pcfg)
+		}
+	}
+
+	if reload {
 		klog.InfoS("Configuration changes detected, backend reload required")
 
 		hash, err := hashstructure.Hash(pcfg, hashstructure.FormatV1, &hashstructure.HashOptions{
@@ -206,6 +371,8 @@ func (n *NGINXController) syncIngress(interface{}) error {
 
 		err = n.OnUpdate(*pcfg)
 		if err != nil {
+			n.reportRegexCompileFailures(err, pcfg.Servers)
+			n.rollBackInvalidIngresses(ings, err)
 			n.metricCollector.IncReloadErrorCount()
 			n.metricCollector.ConfigSuccess(hash, false)
 			klog.Errorf("Unexpected failure reloading the backend:\n%v", err)
@@ -214,6 +381,10 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		}
 
 		klog.InfoS("Backend successfully reloaded")
+		if n.cfg.ShadowMode {
+			klog.InfoS("Shadow mode configuration validated", "ingresses", len(ings), "servers", len(pcfg.Servers), "backends", len(pcfg.Backends))
+		}
+		removeUnusedCacheZones(pcfg)
 		n.metricCollector.ConfigSuccess(hash, true)
 		n.metricCollector.IncReloadCount()
 
@@ -259,7 +430,21 @@ func (n *NGINXController) syncIngress(interface{}) error {
 	rc := utilingress.GetRemovedCertificateSerialNumbers(n.runningConfig, pcfg)
 	n.metricCollector.RemoveMetrics(ri, rc)
 
+	n.exportConfigurationDiff(pcfg, reload)
+
+	n.runningConfigLock.Lock()
 	n.runningConfig = pcfg
+	n.configurationHandedOff = false
+	n.runningConfigLock.Unlock()
+
+	if regressionErr != nil {
+		return regressionErr
+	}
+
+	if !frozen {
+		n.recordConfigSnapshot(ings)
+		n.recordAppliedIngresses(ings)
+	}
 
 	return nil
 }
@@ -307,9 +492,38 @@ func (n *NGINXController) CheckWarning(ing *networking.Ingress) ([]string, error
 		}
 	}
 
+	if n.cfg != nil && n.cfg.ValidationWebhookConflicts == ConflictsWarn {
+		warnings = append(warnings, n.ingressConflictWarnings(ing)...)
+	}
+
 	return warnings, nil
 }
 
+// ingressConflictWarnings returns the conflicts of an Ingress handled by the
+// controller with the other Ingresses
+func (n *NGINXController) ingressConflictWarnings(ing *networking.Ingress) []string {
+	if ingressClass, _ := n.store.GetIngressClass(ing, n.cfg.IngressClassConfiguration); ingressClass == "" {
+		return nil
+	}
+
+	parsed, _, err := n.extractAnnotations(ing)
+	if err != nil {
+		// the Ingress is rejected by CheckIngress
+		return nil
+	}
+
+	return ingressConflicts(&ingress.Ingress{Ingress: *ing, ParsedAnnotations: parsed}, n.otherIngresses(ing), time.Now())
+}
+
+// extractAnnotations parses the annotations of an Ingress merged with the
+// default annotations of its namespace, like the store does for the
+// Ingresses it watches
+func (n *NGINXController) extractAnnotations(ing *networking.Ingress) (*annotations.Ingress, []string, error) {
+	withDefaults, overridden := n.store.WithNamespaceDefaults(ing)
+	parsed, err := annotations.NewAnnotationExtractor(n.store).Extract(withDefaults)
+	return parsed, overridden, err
+}
+
 // CheckIngress returns an error in case the provided ingress, when added
 // to the current configuration, generates an invalid configuration
 func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
@@ -380,6 +594,17 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 		if !cfg.AllowSnippetAnnotations && strings.HasSuffix(key, "-snippet") {
 			return fmt.Errorf("%s annotation cannot be used. Snippet directives are disabled by the Ingress administrator", key)
 		}
+
+		if !n.cfg.EnableChaosInjection && strings.HasPrefix(key, parser.GetAnnotationWithPrefix("chaos-")) {
+			return fmt.Errorf("%s annotation cannot be used. Chaos injection is disabled, it requires the flag --enable-chaos-injection", key)
+		}
+	}
+
+	if cfg.StrictAnnotationValidation {
+		if err := annotations.NewAnnotationExtractor(n.store).ValidateStrict(ing); err != nil {
+			n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+			return err
+		}
 	}
 
 	k8s.SetDefaultNGINXPathType(ing)
@@ -391,23 +616,34 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
 	}
 	ings := store.FilterIngresses(allIngresses, filter)
-	parsed, err := annotations.NewAnnotationExtractor(n.store).Extract(ing)
+	parsed, overridden, err := n.extractAnnotations(ing)
 	if err != nil {
 		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
 		return err
 	}
+	if parsed.ServiceUpstream && serviceupstream.Explicit(ing) {
+		if incompatible := serviceUpstreamIncompatibilities(parsed); len(incompatible) > 0 {
+			n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+			return fmt.Errorf("service-upstream cannot be used with %s: kube-proxy balances the connections to the Service ClusterIP",
+				strings.Join(incompatible, ", "))
+		}
+	}
 	ings = append(ings, &ingress.Ingress{
-		Ingress:           *ing,
-		ParsedAnnotations: parsed,
+		Ingress:                      *ing,
+		ParsedAnnotations:            parsed,
+		OverriddenDefaultAnnotations: overridden,
 	})
-	startTest := time.Now().UnixNano() / 1000000
-	_, servers, pcfg := n.getConfiguration(ings)
-
-	err = checkOverlap(ing, servers)
-	if err != nil {
-		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
-		return err
+	if n.cfg.ValidationWebhookConflicts != ConflictsWarn {
+		if conflicts := ingressConflicts(ings[len(ings)-1], ings[:len(ings)-1], time.Now()); len(conflicts) > 0 {
+			n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+			return fmt.Errorf("ingress conflicts with other ingresses: %s", strings.Join(conflicts, "; "))
+		}
 	}
+
+	ings, _ = resolveIngressConflicts(ings, cfg.IngressConflictResolution)
+
+	startTest := time.Now().UnixNano() / 1000000
+	_, _, pcfg := n.getConfiguration(ings)
 	testedSize := len(ings)
 	if n.cfg.DisableFullValidationTest {
 		_, _, pcfg = n.getConfiguration(ings[len(ings)-1:])
@@ -423,6 +659,11 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 	err = n.testTemplate(content)
 	if err != nil {
 		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		for _, failure := range regexCompileFailures(err.Error(), pcfg.Servers) {
+			if failure.Ingress.Namespace == ing.Namespace && failure.Ingress.Name == ing.Name {
+				return fmt.Errorf("path %q is not a valid regular expression: %v", failure.Path, failure.Reason)
+			}
+		}
 		return err
 	}
 	n.metricCollector.IncCheckCount(ing.ObjectMeta.Namespace, ing.Name)
@@ -439,6 +680,109 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 }
 
 func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Protocol) []ingress.L4Service {
+	svcs := n.getConfigMapStreamServices(configmapName, proto)
+
+	// ports of the ConfigMap take precedence over TCPRoute and UDPRoute resources
+	routeSvcs, _ := n.getStreamRouteServices(proto, n.configMapStreamPorts(configmapName))
+	svcs = append(svcs, routeSvcs...)
+
+	// Keep upstream order sorted to reduce unnecessary nginx config reloads.
+	sort.SliceStable(svcs, func(i, j int) bool {
+		return svcs[i].Port < svcs[j].Port
+	})
+	return svcs
+}
+
+// controllerPorts returns the ports used by the Ingress controller
+func (n *NGINXController) controllerPorts() sets.Int {
+	return sets.NewInt(
+		n.cfg.ListenPorts.HTTP,
+		n.cfg.ListenPorts.HTTPS,
+		n.cfg.ListenPorts.SSLProxy,
+		n.cfg.ListenPorts.Health,
+		n.cfg.ListenPorts.Default,
+		n.cfg.ListenPorts.ClientIPAgentHTTP,
+		n.cfg.ListenPorts.ClientIPAgentHTTPS,
+		nginx.ProfilerPort,
+		nginx.StatusPort,
+		nginx.StreamPort,
+	)
+}
+
+// ingressClassListenPorts returns the ports the servers of the IngressClasses
+// listen on, by class. The ports used by the Ingress controller are ignored.
+func (n *NGINXController) ingressClassListenPorts() map[string]*ingress.ClassListenPorts {
+	reserved := n.controllerPorts()
+
+	ports := map[string]*ingress.ClassListenPorts{}
+	for class, cfg := range n.store.ListIngressClassConfigs() {
+		if cfg.ListenPorts == nil {
+			continue
+		}
+		if reserved.Has(cfg.ListenPorts.HTTP) || reserved.Has(cfg.ListenPorts.HTTPS) {
+			klog.Warningf("Ignoring the ports of IngressClass %v: ports %d and %d cannot be used, they are reserved for the Ingress controller",
+				class, cfg.ListenPorts.HTTP, cfg.ListenPorts.HTTPS)
+			continue
+		}
+		ports[class] = cfg.ListenPorts
+	}
+	return ports
+}
+
+// reservedStreamPorts returns the ports used by the Ingress controller and
+// the IngressClasses that cannot be used by stream services
+func (n *NGINXController) reservedStreamPorts() sets.Int {
+	reserved := n.controllerPorts()
+	for _, ports := range n.ingressClassListenPorts() {
+		reserved.Insert(ports.HTTP, ports.HTTPS)
+	}
+	return reserved
+}
+
+// getStreamEndpoints returns the endpoints of the port of a Service matching
+// svcPort, either a port number or a port name
+func (n *NGINXController) getStreamEndpoints(svc *apiv1.Service, svcPort string, proto apiv1.Protocol) []ingress.Endpoint {
+	nsName := k8s.MetaNamespaceKey(svc)
+
+	var zone string
+	if n.cfg.EnableTopologyAwareRouting {
+		zone = getIngressPodZone(svc)
+	} else {
+		zone = emptyZone
+	}
+
+	/* #nosec */
+	targetPort, err := strconv.Atoi(svcPort) // #nosec
+	if err != nil {
+		// not a port number, fall back to using port name
+		klog.V(3).Infof("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
+		for i := range svc.Spec.Ports {
+			sp := svc.Spec.Ports[i]
+			if sp.Name == svcPort {
+				if sp.Protocol == proto {
+					return getEndpointsFromSlices(svc, &sp, proto, zone, n.store.GetServiceEndpointsSlices)
+				}
+			}
+		}
+		return nil
+	}
+
+	klog.V(3).Infof("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
+	for i := range svc.Spec.Ports {
+		sp := svc.Spec.Ports[i]
+		//nolint:gosec // Ignore G109 error
+		if sp.Port == int32(targetPort) {
+			if sp.Protocol == proto {
+				return getEndpointsFromSlices(svc, &sp, proto, zone, n.store.GetServiceEndpointsSlices)
+			}
+		}
+	}
+	return nil
+}
+
+// getConfigMapStreamServices returns the stream services declared in the
+// tcp-services-configmap or udp-services-configmap ConfigMap
+func (n *NGINXController) getConfigMapStreamServices(configmapName string, proto apiv1.Protocol) []ingress.L4Service {
 	if configmapName == "" {
 		return []ingress.L4Service{}
 	}
@@ -457,18 +801,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 	svcs := make([]ingress.L4Service, 0, len(configmap.Data))
 	var svcProxyProtocol ingress.ProxyProtocol
 
-	rp := []int{
-		n.cfg.ListenPorts.HTTP,
-		n.cfg.ListenPorts.HTTPS,
-		n.cfg.ListenPorts.SSLProxy,
-		n.cfg.ListenPorts.Health,
-		n.cfg.ListenPorts.Default,
-		nginx.ProfilerPort,
-		nginx.StatusPort,
-		nginx.StreamPort,
-	}
-
-	reservedPorts := sets.NewInt(rp...)
+	reservedPorts := n.reservedStreamPorts()
 	// svcRef format: <(str)namespace>/<(str)service>:<(intstr)port>[:<("PROXY")decode>:<("PROXY")encode>]
 	for port, svcRef := range configmap.Data {
 		externalPort, err := strconv.Atoi(port) // #nosec
@@ -508,41 +841,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 			klog.Warningf("Error getting Service %q: %v", nsName, err)
 			continue
 		}
-		var endps []ingress.Endpoint
-		/* #nosec */
-		targetPort, err := strconv.Atoi(svcPort) // #nosec
-		var zone string
-		if n.cfg.EnableTopologyAwareRouting {
-			zone = getIngressPodZone(svc)
-		} else {
-			zone = emptyZone
-		}
-
-		if err != nil {
-			// not a port number, fall back to using port name
-			klog.V(3).Infof("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
-			for i := range svc.Spec.Ports {
-				sp := svc.Spec.Ports[i]
-				if sp.Name == svcPort {
-					if sp.Protocol == proto {
-						endps = getEndpointsFromSlices(svc, &sp, proto, zone, n.store.GetServiceEndpointsSlices)
-						break
-					}
-				}
-			}
-		} else {
-			klog.V(3).Infof("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
-			for i := range svc.Spec.Ports {
-				sp := svc.Spec.Ports[i]
-				//nolint:gosec // Ignore G109 error
-				if sp.Port == int32(targetPort) {
-					if sp.Protocol == proto {
-						endps = getEndpointsFromSlices(svc, &sp, proto, zone, n.store.GetServiceEndpointsSlices)
-						break
-					}
-				}
-			}
-		}
+		endps := n.getStreamEndpoints(svc, svcPort, proto)
 		// stream services cannot contain empty upstreams and there is
 		// no default backend equivalent
 		if len(endps) == 0 {
@@ -562,10 +861,6 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 			Service:   svc,
 		})
 	}
-	// Keep upstream order sorted to reduce unnecessary nginx config reloads.
-	sort.SliceStable(svcs, func(i, j int) bool {
-		return svcs[i].Port < svcs[j].Port
-	})
 	return svcs
 }
 
@@ -610,6 +905,8 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 	upstreams, servers := n.getBackendServers(ingresses)
 	var passUpstreams []*ingress.SSLPassthroughBackend
 
+	drainEndpoints(upstreams, n.getDrainedEndpoints())
+
 	hosts := sets.New[string]()
 
 	for _, server := range servers {
@@ -651,6 +948,7 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 				Hostname: server.Hostname,
 				Service:  loc.Service,
 				Port:     loc.Port,
+				Routing:  server.SSLPassthroughRouting,
 			})
 			break
 		}
@@ -665,6 +963,7 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 		BackendConfigChecksum: n.store.GetBackendConfiguration().Checksum,
 		DefaultSSLCertificate: n.getDefaultSSLCertificate(),
 		StreamSnippets:        n.getStreamSnippets(ingresses),
+		LuaPlugins:            n.getLuaPlugins(),
 	}
 }
 
@@ -743,13 +1042,19 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					klog.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled for Ingress %q",
 						server.CertificateAuth.Secret, ingKey)
 				}
+				if server.CertificateAuth.CAFileName != "" && server.CertificateAuth.CRLURL != "" {
+					n.applyRemoteCRL(server)
+				}
+				if server.CertificateAuth.CAFileName != "" && server.CertificateAuth.OCSP != "" {
+					n.applyOCSPFailureMode(server)
+				}
 			} else {
 				klog.V(3).Infof("Server %q is already configured for mutual authentication (Ingress %q)",
 					server.Hostname, ingKey)
 			}
 
 			if !n.store.GetBackendConfiguration().ProxySSLLocationOnly {
-				if server.ProxySSL.CAFileName == "" {
+				if server.ProxySSL.CAFileName == "" && server.ProxySSL.SpiffeID == "" {
 					server.ProxySSL = anns.ProxySSL
 					if server.ProxySSL.Secret != "" && server.ProxySSL.CAFileName == "" {
 						klog.V(3).Infof("Secret %q has no 'ca.crt' key, client cert authentication disabled for Ingress %q",
@@ -782,9 +1087,10 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					continue
 				}
 
-				nginxPath := rootLocation
-				if path.Path != "" {
-					nginxPath = path.Path
+				nginxPath, params, err := locationPath(path, anns)
+				if err != nil {
+					klog.Warningf("Path %q of Ingress %q is not a valid path template: %v", path.Path, ingKey, err)
+					continue
 				}
 
 				addLoc := true
@@ -815,6 +1121,7 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					loc.Port = ups.Port
 					loc.Service = ups.Service
 					loc.Ingress = ing
+					loc.PathParameters = params
 
 					locationApplyAnnotations(loc, anns)
 
@@ -830,13 +1137,14 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					klog.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
 						nginxPath, server.Hostname, ups.Name, ingKey)
 					loc := &ingress.Location{
-						Path:         nginxPath,
-						PathType:     path.PathType,
-						Backend:      ups.Name,
-						IsDefBackend: false,
-						Service:      ups.Service,
-						Port:         ups.Port,
-						Ingress:      ing,
+						Path:           nginxPath,
+						PathType:       path.PathType,
+						Backend:        ups.Name,
+						IsDefBackend:   false,
+						Service:        ups.Service,
+						Port:           ups.Port,
+						Ingress:        ing,
+						PathParameters: params,
 					}
 					locationApplyAnnotations(loc, anns)
 
@@ -986,6 +1294,12 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 		return aServers[i].Hostname < aServers[j].Hostname
 	})
 
+	n.applySVIDs(aServers)
+
+	if cfg := n.store.GetBackendConfiguration(); cfg.EnableDynamicServers {
+		markDynamicServers(aServers, cfg.SSLRejectHandshake, n.store.WithNamespaceDefaults)
+	}
+
 	return aUpstreams, aServers
 }
 
@@ -1003,9 +1317,15 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 			dropSnippetDirectives(anns, ingKey)
 		}
 
-		var defBackend string
-		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
-			defBackend = upstreamName(ing.Namespace, ing.Spec.DefaultBackend.Service)
+		serviceUpstream := useServiceUpstream(ing, data)
+
+		// the default backend of the Ingress and the one of its hosts
+		for _, backend := range []*networking.IngressBackend{ing.Spec.DefaultBackend, anns.HostDefaultBackend} {
+			if backend == nil || backend.Service == nil {
+				continue
+			}
+
+			defBackend := upstreamName(ing.Namespace, backend.Service)
 
 			klog.V(3).Infof("Creating upstream %q", defBackend)
 			upstreams[defBackend] = newUpstream(defBackend)
@@ -1019,11 +1339,16 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 				upstreams[defBackend].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 			}
 
-			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, ing.Spec.DefaultBackend.Service.Name)
+			upstreams[defBackend].LoadBalanceTuning = anns.LoadBalanceTuning
+			upstreams[defBackend].UpstreamKeepalive = anns.UpstreamKeepalive
+			upstreams[defBackend].ExternalName = anns.ExternalName
+			upstreams[defBackend].SlowStart = anns.SlowStart
+
+			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, backend.Service.Name)
 
 			// add the service ClusterIP as a single Endpoint instead of individual Endpoints
-			if anns.ServiceUpstream {
-				endpoint, err := n.getServiceClusterEndpoint(svcKey, ing.Spec.DefaultBackend)
+			if serviceUpstream {
+				endpoint, err := n.getServiceClusterEndpoint(svcKey, backend)
 				if err != nil {
 					klog.Errorf("Failed to determine a suitable ClusterIP Endpoint for Service %q: %v", svcKey, err)
 				} else {
@@ -1038,7 +1363,7 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 			}
 
 			if len(upstreams[defBackend].Endpoints) == 0 {
-				_, port := upstreamServiceNameAndPort(ing.Spec.DefaultBackend.Service)
+				_, port := upstreamServiceNameAndPort(backend.Service)
 				endps, err := n.serviceEndpoints(svcKey, port.String())
 				upstreams[defBackend].Endpoints = append(upstreams[defBackend].Endpoints, endps...)
 				if err != nil {
@@ -1084,10 +1409,15 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 				}
 
+				upstreams[name].LoadBalanceTuning = anns.LoadBalanceTuning
+				upstreams[name].UpstreamKeepalive = anns.UpstreamKeepalive
+				upstreams[name].ExternalName = anns.ExternalName
+				upstreams[name].SlowStart = anns.SlowStart
+
 				svcKey := fmt.Sprintf("%v/%v", ing.Namespace, svcName)
 
 				// add the service ClusterIP as a single Endpoint instead of individual Endpoints
-				if anns.ServiceUpstream {
+				if serviceUpstream {
 					endpoint, err := n.getServiceClusterEndpoint(svcKey, &rule.HTTP.Paths[i].Backend)
 					if err != nil {
 						klog.Errorf("Failed to determine a suitable ClusterIP Endpoint for Service %q: %v", svcKey, err)
@@ -1246,6 +1576,9 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 ) map[string]*ingress.Server {
 	servers := make(map[string]*ingress.Server, len(data))
 	allAliases := make(map[string][]string, len(data))
+	// hosts whose certificate comes from a Secret required by an Ingress
+	requiredSSLCerts := sets.NewString()
+	classPorts := n.ingressClassListenPorts()
 
 	bdef := n.store.GetDefaultBackend()
 	ngxProxy := proxy.Config{
@@ -1255,8 +1588,6 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 		ReadTimeout:          bdef.ProxyReadTimeout,
 		BuffersNumber:        bdef.ProxyBuffersNumber,
 		BufferSize:           bdef.ProxyBufferSize,
-		CookieDomain:         bdef.ProxyCookieDomain,
-		CookiePath:           bdef.ProxyCookiePath,
 		NextUpstream:         bdef.ProxyNextUpstream,
 		NextUpstreamTimeout:  bdef.ProxyNextUpstreamTimeout,
 		NextUpstreamTries:    bdef.ProxyNextUpstreamTries,
@@ -1266,6 +1597,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 		ProxyHTTPVersion:     bdef.ProxyHTTPVersion,
 		ProxyMaxTempFileSize: bdef.ProxyMaxTempFileSize,
 	}
+	ngxProxy.CookieDomain, ngxProxy.CookiePath = proxy.DefaultCookieRewrites(bdef)
 
 	// initialize default server and root location
 	pathTypePrefix := networking.PathTypePrefix
@@ -1281,7 +1613,8 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				Proxy:        ngxProxy,
 				Service:      du.Service,
 				Logs: log.Config{
-					Access:  n.store.GetBackendConfiguration().EnableAccessLogForDefaultBackend,
+					// the requests to the dynamic servers are served by this location
+					Access:  n.store.GetBackendConfiguration().EnableAccessLogForDefaultBackend || n.store.GetBackendConfiguration().EnableDynamicServers,
 					Rewrite: false,
 				},
 			},
@@ -1360,12 +1693,18 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 					loc,
 				},
 				SSLPassthrough:         anns.SSLPassthrough,
+				SSLPassthroughRouting:  anns.SSLPassthroughRouting,
 				SSLCiphers:             anns.SSLCipher.SSLCiphers,
 				SSLPreferServerCiphers: anns.SSLCipher.SSLPreferServerCiphers,
+				IngressClass:           ing.IngressClass,
 			}
+			servers[host].ClassListenPorts = classPorts[ing.IngressClass]
 		}
 	}
 
+	// the Ingresses configuring the default backend of each server
+	hostDefaultBackends := make(map[string]string)
+
 	// configure default location, alias, and SSL
 	for _, ing := range data {
 		ingKey := k8s.MetaNamespaceKey(ing)
@@ -1395,6 +1734,24 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				klog.Warningf("Aliases already configured for server %q, skipping (Ingress %q)", host, ingKey)
 			}
 
+			// the requests to the server matching no path are sent to the
+			// default backend of the host instead of the global one
+			if anns.HostDefaultBackend != nil && host != defServerName {
+				if owner, ok := hostDefaultBackends[host]; ok {
+					if owner != ingKey {
+						klog.Warningf("Default backend already configured for server %q by Ingress %q, skipping (Ingress %q)", host, owner, ingKey)
+					}
+				} else if backendUpstream, ok := upstreams[upstreamName(ing.Namespace, anns.HostDefaultBackend.Service)]; ok {
+					hostDefaultBackends[host] = ingKey
+
+					defLoc := servers[host].Locations[0]
+					defLoc.Backend = backendUpstream.Name
+					defLoc.Service = backendUpstream.Service
+					defLoc.Ingress = ing
+					locationApplyAnnotations(defLoc, anns)
+				}
+			}
+
 			if anns.ServerSnippet != "" {
 				if servers[host].ServerSnippet == "" {
 					servers[host].ServerSnippet = anns.ServerSnippet
@@ -1414,20 +1771,59 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				servers[host].SSLPreferServerCiphers = anns.SSLCipher.SSLPreferServerCiphers
 			}
 
-			// only add a certificate if the server does not have one previously configured
-			if servers[host].SSLCert != nil {
+			if len(ing.Spec.TLS) == 0 {
+				if servers[host].SSLCert == nil {
+					klog.V(3).Infof("Ingress %q does not contains a TLS section.", ingKey)
+				}
 				continue
 			}
 
-			if len(ing.Spec.TLS) == 0 {
-				klog.V(3).Infof("Ingress %q does not contains a TLS section.", ingKey)
+			// a Secret required by an Ingress replaces the certificates of other Ingresses
+			if anns.SSLCertificatePreference.Secret != "" {
+				if !requiredSSLCerts.Has(host) {
+					n.setRequiredSSLCertificate(servers[host], ing, anns.SSLCertificatePreference.Secret)
+					requiredSSLCerts.Insert(host)
+				}
 				continue
 			}
 
-			tlsSecretName := extractTLSSecretName(host, ing, n.store.GetLocalSSLCert)
+			preference := anns.SSLCertificatePreference.Preference
+			if preference == "" {
+				preference = n.store.GetBackendConfiguration().SSLCertificatePreference
+			}
+
+			// only add a certificate if the server does not have one previously configured,
+			// unless it does not match the host as preferred and this Ingress has one that does
+			if servers[host].SSLCert != nil {
+				if preference == "" || requiredSSLCerts.Has(host) ||
+					certificateHostMatch(host, servers[host].SSLCert.Certificate) == preference {
+					continue
+				}
+
+				tlsSecretName, fallback := selectTLSSecretName(host, ing, preference, n.store.GetLocalSSLCert)
+				if tlsSecretName == "" || fallback {
+					continue
+				}
+
+				secrKey := fmt.Sprintf("%v/%v", ing.Namespace, tlsSecretName)
+				if cert, err := n.store.GetLocalSSLCert(secrKey); err == nil {
+					klog.V(2).Infof("Using SSL certificate %q of Ingress %q for server %q (%v preference)", secrKey, ingKey, host, preference)
+					servers[host].SSLCert = cert
+					servers[host].SSLCertFallback = nil
+				}
+				continue
+			}
+
+			tlsSecretName, fallback := selectTLSSecretName(host, ing, preference, n.store.GetLocalSSLCert)
+			if fallback {
+				n.recordSSLCertificateFallback(ing, apiv1.EventTypeNormal,
+					"No SSL certificate matches host %q with the %v preference, using Secret %v/%v",
+					host, preference, ing.Namespace, tlsSecretName)
+			}
+
 			if tlsSecretName == "" {
 				klog.V(3).Infof("Host %q is listed in the TLS section but secretName is empty. Using default certificate", host)
-				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				n.useDefaultSSLCertificate(servers[host], ing, "", ingress.SSLCertFallbackNoSecretName)
 				continue
 			}
 
@@ -1435,14 +1831,14 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 			cert, err := n.store.GetLocalSSLCert(secrKey)
 			if err != nil {
 				klog.Warningf("Error getting SSL certificate %q: %v. Using default certificate", secrKey, err)
-				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				n.useDefaultSSLCertificate(servers[host], ing, secrKey, n.sslCertFallbackReason(secrKey))
 				continue
 			}
 
 			if cert.Certificate == nil {
 				klog.Warningf("SSL certificate %q does not contain a valid SSL certificate for server %q", secrKey, host)
 				klog.Warningf("Using default certificate")
-				servers[host].SSLCert = n.getDefaultSSLCertificate()
+				n.useDefaultSSLCertificate(servers[host], ing, secrKey, ingress.SSLCertFallbackSecretInvalid)
 				continue
 			}
 
@@ -1456,7 +1852,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				if err != nil {
 					klog.Warningf("SSL certificate %q does not contain a Common Name or Subject Alternative Name for server %q: %v", secrKey, host, err)
 					klog.Warningf("Using default certificate")
-					servers[host].SSLCert = n.getDefaultSSLCertificate()
+					n.useDefaultSSLCertificate(servers[host], ing, secrKey, ingress.SSLCertFallbackHostMismatch)
 					continue
 				}
 			}
@@ -1472,6 +1868,37 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 		}
 	}
 
+	if n.cfg.EnableCertificateDiscovery {
+		domainCerts := n.store.ListDomainSSLCerts()
+		crossNamespace := n.store.GetBackendConfiguration().AllowCrossNamespaceResources
+		for host, server := range servers {
+			if host == defServerName || server.SSLCert != nil {
+				continue
+			}
+
+			// the certificates of the namespaces of the Ingresses of the host
+			// and of the controller, unless cross namespace resources are allowed
+			namespaces := sets.NewString()
+			if k8s.IngressPodDetails != nil {
+				namespaces.Insert(k8s.IngressPodDetails.Namespace)
+			}
+			for _, loc := range server.Locations {
+				if loc.Ingress != nil {
+					namespaces.Insert(loc.Ingress.Namespace)
+				}
+			}
+			allowed := func(namespace string) bool {
+				return crossNamespace || namespaces.Has(namespace)
+			}
+
+			// only the hosts of Ingresses without TLS section have no certificate
+			if cert := discoveredSSLCertificate(host, allowed, n.certificateDiscoveryRoots, domainCerts); cert != nil {
+				klog.V(3).Infof("Using SSL certificate %v/%v discovered for server %q", cert.Namespace, cert.Name, host)
+				server.SSLCert = cert
+			}
+		}
+	}
+
 	for host, hostAliases := range allAliases {
 		if _, ok := servers[host]; !ok {
 			continue
@@ -1531,10 +1958,53 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.ModSecurity = anns.ModSecurity
 	loc.Satisfy = anns.Satisfy
 	loc.Mirror = anns.Mirror
+	loc.GraphQL = anns.GraphQL
+	loc.AuthCookieSession = anns.AuthCookieSession
+	loc.RetryPolicy = anns.RetryPolicy
+	loc.GeoAccess = anns.GeoAccess
+	loc.Attribution = anns.Attribution
+	loc.ProxyCache = anns.ProxyCache
+	loc.UpstreamSigning = anns.UpstreamSigning
+	loc.NextUpstream = anns.NextUpstream
+	loc.UpstreamKeepalive = anns.UpstreamKeepalive
+	loc.RequestHeaders = anns.RequestHeaders
+	loc.RequestID = anns.RequestID
+	loc.ResponseBodyRewrite = anns.ResponseBodyRewrite
+	loc.LinkRewrite = anns.LinkRewrite
+	loc.ConcurrencyLimit = anns.ConcurrencyLimit
+	loc.PathTemplate = anns.PathTemplate
+	loc.Chaos = anns.Chaos
+	loc.BotMitigation = anns.BotMitigation
+	loc.ServerTiming = anns.ServerTiming
+	loc.FailoverOrigin = anns.FailoverOrigin
+	loc.LuaPlugins = anns.LuaPlugins
+
+	// the retry policy replaces the proxy-next-upstream annotations
+	if loc.RetryPolicy.Enabled {
+		loc.Proxy.NextUpstream = loc.RetryPolicy.RetryOn
+		loc.Proxy.NextUpstreamTries = loc.RetryPolicy.MaxRetries + 1
+		if loc.RetryPolicy.MaxRetries == 0 {
+			loc.Proxy.NextUpstream = "off"
+		}
+	}
 
 	loc.DefaultBackendUpstreamName = defUpstreamName
 }
 
+// locationPath returns the path of the location created for an Ingress path
+// and the parameters it captures, compiled from a path template if enabled
+func locationPath(path networking.HTTPIngressPath, anns *annotations.Ingress) (string, []string, error) {
+	nginxPath := rootLocation
+	if path.Path != "" {
+		nginxPath = path.Path
+	}
+	if anns == nil || !anns.PathTemplate.Enabled {
+		return nginxPath, nil, nil
+	}
+
+	return pathtemplate.Compile(nginxPath, path.PathType)
+}
+
 // OK to merge canary ingresses iff there exists one or more ingresses to potentially merge into
 func nonCanaryIngressExists(ingresses, canaryIngresses []*ingress.Ingress) bool {
 	return len(ingresses)-len(canaryIngresses) > 0
@@ -1641,6 +2111,12 @@ func mergeAlternativeBackends(ing *ingress.Ingress, upstreams map[string]*ingres
 				continue
 			}
 
+			altPath, _, err := locationPath(path, ing.ParsedAnnotations)
+			if err != nil {
+				klog.Warningf("Path %q of Ingress %q is not a valid path template: %v", path.Path, k8s.MetaNamespaceKey(ing), err)
+				continue
+			}
+
 			merged := false
 			altEqualsPri := false
 
@@ -1667,7 +2143,7 @@ func mergeAlternativeBackends(ing *ingress.Ingress, upstreams map[string]*ingres
 					break
 				}
 
-				if canMergeBackend(priUps, altUps) && loc.Path == path.Path && *loc.PathType == *path.PathType {
+				if canMergeBackend(priUps, altUps) && loc.Path == altPath && *loc.PathType == *path.PathType {
 					klog.V(2).Infof("matching backend %v found for alternative backend %v",
 						priUps.Name, altUps.Name)
 
@@ -1732,6 +2208,173 @@ func extractTLSSecretName(host string, ing *ingress.Ingress,
 	return ""
 }
 
+// selectTLSSecretName returns the name of the Secret in the TLS section of the
+// Ingress used for a host, preferring the certificates matching the host as
+// defined by preference. The returned bool is true when no certificate matches
+// the host as preferred and the Secret of another one is returned instead.
+func selectTLSSecretName(host string, ing *ingress.Ingress, preference string,
+	getLocalSSLCert func(string) (*ingress.SSLCert, error),
+) (name string, fallback bool) {
+	if ing == nil || preference == "" {
+		return extractTLSSecretName(host, ing, getLocalSSLCert), false
+	}
+
+	// the TLS hosts matching the host name are checked first
+	lowercaseHost := toLowerCaseASCII(host)
+	listed := make([]networking.IngressTLS, 0, len(ing.Spec.TLS))
+	others := make([]networking.IngressTLS, 0, len(ing.Spec.TLS))
+	for _, tls := range ing.Spec.TLS {
+		isListed := false
+		for _, tlsHost := range tls.Hosts {
+			if toLowerCaseASCII(tlsHost) == lowercaseHost {
+				isListed = true
+				break
+			}
+		}
+
+		if isListed {
+			listed = append(listed, tls)
+		} else {
+			others = append(others, tls)
+		}
+	}
+
+	for _, tls := range append(listed, others...) {
+		if tls.SecretName == "" {
+			continue
+		}
+
+		secrKey := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+		cert, err := getLocalSSLCert(secrKey)
+		if err != nil || cert == nil {
+			continue
+		}
+
+		match := certificateHostMatch(host, cert.Certificate)
+		if match == "" {
+			continue
+		}
+		if match == preference {
+			klog.V(3).Infof("Found SSL certificate matching host %q (%v preference): %q", host, preference, secrKey)
+			return tls.SecretName, false
+		}
+		if name == "" {
+			name = tls.SecretName
+		}
+	}
+
+	if name != "" {
+		return name, true
+	}
+
+	return extractTLSSecretName(host, ing, getLocalSSLCert), false
+}
+
+// setRequiredSSLCertificate configures a server to use the certificate of the Secret
+// required by an Ingress, or the default certificate when the Secret does not contain
+// a valid certificate for the host.
+func (n *NGINXController) setRequiredSSLCertificate(server *ingress.Server, ing *ingress.Ingress, secrKey string) {
+	var reason string
+	cert, err := n.store.GetLocalSSLCert(secrKey)
+	switch {
+	case err != nil:
+		reason = n.sslCertFallbackReason(secrKey)
+	case cert.Certificate == nil:
+		reason = ingress.SSLCertFallbackSecretInvalid
+		err = fmt.Errorf("the Secret does not contain a valid SSL certificate")
+	case cert.Certificate.VerifyHostname(server.Hostname) != nil:
+		err = verifyHostname(server.Hostname, cert.Certificate)
+		reason = ingress.SSLCertFallbackHostMismatch
+	}
+
+	if err != nil {
+		n.recordSSLCertificateFallback(ing, apiv1.EventTypeWarning,
+			"Using the default certificate for host %q, the required Secret %v cannot be used: %v", server.Hostname, secrKey, err)
+		n.useDefaultSSLCertificate(server, ing, secrKey, reason)
+		return
+	}
+
+	server.SSLCert = cert
+	server.SSLCertFallback = nil
+}
+
+// useDefaultSSLCertificate configures a server to use the default certificate
+// instead of the certificate of the Secret secrKey listed by an Ingress
+func (n *NGINXController) useDefaultSSLCertificate(server *ingress.Server, ing *ingress.Ingress, secrKey, reason string) {
+	server.SSLCert = n.getDefaultSSLCertificate()
+	server.SSLCertFallback = &ingress.SSLCertFallback{
+		Reason:          reason,
+		Ingress:         k8s.MetaNamespaceKey(ing),
+		Secret:          secrKey,
+		FakeCertificate: server.SSLCert == n.cfg.FakeCertificate,
+	}
+}
+
+// sslCertFallbackReason returns why the certificate of the Secret secrKey
+// is not in the local store
+func (n *NGINXController) sslCertFallbackReason(secrKey string) string {
+	secret, err := n.store.GetSecret(secrKey)
+	if err != nil {
+		return ingress.SSLCertFallbackSecretMissing
+	}
+
+	if _, err := tls.X509KeyPair(secret.Data[apiv1.TLSCertKey], secret.Data[apiv1.TLSPrivateKeyKey]); err != nil {
+		return ingress.SSLCertFallbackSecretInvalid
+	}
+
+	return ingress.SSLCertFallbackSecretNotSynced
+}
+
+// recordSSLCertificateFallback logs and records an Event for an Ingress whose
+// preferred or required certificate is not used
+func (n *NGINXController) recordSSLCertificateFallback(ing *ingress.Ingress, eventType, messageFmt string, args ...interface{}) {
+	klog.Warningf("Ingress %q: %v", k8s.MetaNamespaceKey(ing), fmt.Sprintf(messageFmt, args...))
+	if n.recorder != nil {
+		n.recorder.Eventf(&ing.Ingress, eventType, "SSLCertificateFallback", messageFmt, args...)
+	}
+}
+
+// recordSSLCertificateFallbacks records an Event for the Ingresses of the hosts
+// that started using the default certificate, or that use it for a new reason
+func (n *NGINXController) recordSSLCertificateFallbacks(ings []*ingress.Ingress, servers []*ingress.Server) {
+	ingresses := make(map[string]*ingress.Ingress, len(ings))
+	for _, ing := range ings {
+		ingresses[k8s.MetaNamespaceKey(ing)] = ing
+	}
+
+	fallbacks := make(map[string]ingress.SSLCertFallback)
+	for _, server := range servers {
+		if server.SSLCertFallback == nil {
+			continue
+		}
+
+		fallback := *server.SSLCertFallback
+		fallbacks[server.Hostname] = fallback
+		if previous, ok := n.sslCertFallbacks[server.Hostname]; ok && previous == fallback {
+			continue
+		}
+
+		ing, ok := ingresses[fallback.Ingress]
+		if !ok || n.recorder == nil {
+			continue
+		}
+
+		certificate := "the default certificate"
+		if fallback.FakeCertificate {
+			certificate = "the fake certificate generated by the controller"
+		}
+		if fallback.Secret == "" {
+			n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "SSLCertificateFallback",
+				"Host %q is served with %v (%v)", server.Hostname, certificate, fallback.Reason)
+			continue
+		}
+		n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "SSLCertificateFallback",
+			"Host %q is served with %v, Secret %v cannot be used (%v)", server.Hostname, certificate, fallback.Secret, fallback.Reason)
+	}
+
+	n.sslCertFallbacks = fallbacks
+}
+
 // checks conditions for whether or not an upstream should be created for a custom default backend
 func shouldCreateUpstreamForLocationDefaultBackend(upstream *ingress.Backend, location *ingress.Location) bool {
 	return (upstream.Name == location.Backend) &&
@@ -1788,87 +2431,6 @@ func externalNamePorts(name string, svc *apiv1.Service) *apiv1.ServicePort {
 	}
 }
 
-func checkOverlap(ing *networking.Ingress, servers []*ingress.Server) error {
-	for _, rule := range ing.Spec.Rules {
-		if rule.HTTP == nil {
-			continue
-		}
-
-		if rule.Host == "" {
-			rule.Host = defServerName
-		}
-
-		for _, path := range rule.HTTP.Paths {
-			if path.Backend.Service == nil {
-				// skip non-service backends
-				klog.V(3).Infof("Ingress %q and path %q does not contain a service backend, using default backend", k8s.MetaNamespaceKey(ing), path.Path)
-				continue
-			}
-
-			if path.Path == "" {
-				path.Path = rootLocation
-			}
-
-			existingIngresses := ingressForHostPath(rule.Host, path.Path, servers)
-
-			// no previous ingress
-			if len(existingIngresses) == 0 {
-				continue
-			}
-
-			// same ingress
-			for _, existing := range existingIngresses {
-				if existing.ObjectMeta.Namespace == ing.ObjectMeta.Namespace && existing.ObjectMeta.Name == ing.ObjectMeta.Name {
-					return nil
-				}
-			}
-
-			// path overlap. Check if one of the ingresses has a canary annotation
-			isCanaryEnabled, annotationErr := parser.GetBoolAnnotation("canary", ing, canary.CanaryAnnotations.Annotations)
-			for _, existing := range existingIngresses {
-				isExistingCanaryEnabled, existingAnnotationErr := parser.GetBoolAnnotation("canary", existing, canary.CanaryAnnotations.Annotations)
-
-				if isCanaryEnabled && isExistingCanaryEnabled {
-					return fmt.Errorf(`host "%s" and path "%s" is already defined in ingress %s/%s`, rule.Host, path.Path, existing.Namespace, existing.Name)
-				}
-
-				if annotationErr == errors.ErrMissingAnnotations && existingAnnotationErr == errors.ErrMissingAnnotations {
-					return fmt.Errorf(`host "%s" and path "%s" is already defined in ingress %s/%s`, rule.Host, path.Path, existing.Namespace, existing.Name)
-				}
-			}
-
-			// no overlap
-			return nil
-		}
-	}
-
-	return nil
-}
-
-func ingressForHostPath(hostname, path string, servers []*ingress.Server) []*networking.Ingress {
-	ingresses := make([]*networking.Ingress, 0)
-
-	for _, server := range servers {
-		if hostname != server.Hostname {
-			continue
-		}
-
-		for i, location := range server.Locations {
-			if location.Path != path {
-				continue
-			}
-
-			if location.IsDefBackend {
-				continue
-			}
-
-			ingresses = append(ingresses, &server.Locations[i].Ingress.Ingress)
-		}
-	}
-
-	return ingresses
-}
-
 func (n *NGINXController) getStreamSnippets(ingresses []*ingress.Ingress) []string {
 	snippets := make([]string, 0, len(ingresses))
 	for _, i := range ingresses {
@@ -1889,5 +2451,6 @@ func newTrafficShapingPolicy(cfg *canary.Config) ingress.TrafficShapingPolicy {
 		HeaderValue:   cfg.HeaderValue,
 		HeaderPattern: cfg.HeaderPattern,
 		Cookie:        cfg.Cookie,
+		Geo:           cfg.Geo,
 	}
 }
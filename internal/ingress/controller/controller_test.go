@@ -18,12 +18,17 @@ package controller
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -39,13 +44,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipallowlist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
@@ -68,12 +76,30 @@ const (
 type fakeIngressStore struct {
 	ingresses     []*ingress.Ingress
 	configuration ngx_config.Configuration
+	tcpRoutes     []*v1alpha1.TCPRoute
+	udpRoutes     []*v1alpha1.UDPRoute
+	classConfigs  map[string]*store.IngressClassConfig
 }
 
 func (fakeIngressStore) GetIngressClass(_ *networking.Ingress, _ *ingressclass.Configuration) (string, error) {
 	return "nginx", nil
 }
 
+func (fakeIngressStore) GetStreamRouteIngressClass(spec *v1alpha1.RouteSpec, _ *ingressclass.Configuration) (string, error) {
+	if spec.IngressClassName != nil && *spec.IngressClassName != "nginx" {
+		return "", fmt.Errorf("route does not contain a valid IngressClass")
+	}
+	return "nginx", nil
+}
+
+func (fis *fakeIngressStore) GetIngressClassConfig(class string) *store.IngressClassConfig {
+	return fis.classConfigs[class]
+}
+
+func (fis *fakeIngressStore) ListIngressClassConfigs() map[string]*store.IngressClassConfig {
+	return fis.classConfigs
+}
+
 func (fis *fakeIngressStore) GetBackendConfiguration() ngx_config.Configuration {
 	return fis.configuration
 }
@@ -105,6 +131,14 @@ func (fis *fakeIngressStore) ListIngresses() []*ingress.Ingress {
 	return fis.ingresses
 }
 
+func (fis *fakeIngressStore) ListTCPRoutes() []*v1alpha1.TCPRoute {
+	return fis.tcpRoutes
+}
+
+func (fis *fakeIngressStore) ListUDPRoutes() []*v1alpha1.UDPRoute {
+	return fis.udpRoutes
+}
+
 func (fis *fakeIngressStore) FilterIngresses(ingresses []*ingress.Ingress, _ store.IngressFilterFunc) []*ingress.Ingress {
 	return ingresses
 }
@@ -117,6 +151,14 @@ func (fakeIngressStore) ListLocalSSLCerts() []*ingress.SSLCert {
 	return nil
 }
 
+func (fakeIngressStore) ListDomainSSLCerts() map[string][]*ingress.SSLCert {
+	return nil
+}
+
+func (fakeIngressStore) WithNamespaceDefaults(ing *networking.Ingress) (*networking.Ingress, []string) {
+	return ing, nil
+}
+
 func (fakeIngressStore) GetAuthCertificate(string) (*resolver.AuthSSLCert, error) {
 	return nil, fmt.Errorf("test error")
 }
@@ -294,6 +336,25 @@ func TestCheckIngress(t *testing.T) {
 			}
 		})
 
+		t.Run("When chaos injection is disabled and user tries to use chaos annotation", func(t *testing.T) {
+			nginx.store = &fakeIngressStore{
+				ingresses: []*ingress.Ingress{},
+				configuration: ngx_config.Configuration{
+					AllowSnippetAnnotations: true,
+				},
+			}
+			nginx.command = testNginxTestCommand{
+				t:   t,
+				err: nil,
+			}
+			ing.ObjectMeta.Annotations["nginx.ingress.kubernetes.io/chaos-abort-percent"] = "10"
+			defer delete(ing.ObjectMeta.Annotations, "nginx.ingress.kubernetes.io/chaos-abort-percent")
+			err := nginx.CheckIngress(ing)
+			if err == nil || !strings.Contains(err.Error(), "--enable-chaos-injection") {
+				t.Errorf("with a chaos annotation, ingresses should be rejected when chaos injection is disabled, got %v", err)
+			}
+		})
+
 		t.Run("When invalid directives are used in annotation values", func(t *testing.T) {
 			nginx.store = &fakeIngressStore{
 				ingresses: []*ingress.Ingress{},
@@ -315,6 +376,28 @@ func TestCheckIngress(t *testing.T) {
 			}
 		})
 
+		t.Run("When service-upstream is used with affinity", func(t *testing.T) {
+			annotationsBefore := ing.ObjectMeta.Annotations
+			defer func() { ing.ObjectMeta.Annotations = annotationsBefore }()
+
+			nginx.store = &fakeIngressStore{
+				ingresses: []*ingress.Ingress{},
+			}
+			nginx.command = testNginxTestCommand{
+				t:   t,
+				err: nil,
+			}
+			ing.ObjectMeta.Annotations = map[string]string{
+				"kubernetes.io/ingress.class":                  "nginx",
+				"nginx.ingress.kubernetes.io/service-upstream": "true",
+				"nginx.ingress.kubernetes.io/affinity":         "cookie",
+			}
+			err := nginx.CheckIngress(ing)
+			if err == nil || !strings.Contains(err.Error(), "service-upstream cannot be used with affinity") {
+				t.Errorf("expected the ingress to be rejected but got %v", err)
+			}
+		})
+
 		t.Run("When a new catch-all ingress is being created despite catch-alls being disabled ", func(t *testing.T) {
 			backendBefore := ing.Spec.DefaultBackend
 			disableCatchAllBefore := nginx.cfg.DisableCatchAll
@@ -343,6 +426,63 @@ func TestCheckIngress(t *testing.T) {
 			nginx.cfg.DisableCatchAll = disableCatchAllBefore
 		})
 
+		t.Run("When the host and path are already defined by another ingress", func(t *testing.T) {
+			defer func() {
+				nginx.cfg.ValidationWebhookConflicts = ""
+			}()
+			nginx.store = &fakeIngressStore{
+				ingresses: []*ingress.Ingress{conflictIngress("other-namespace", "web", "example.com", false)},
+			}
+			nginx.command = testNginxTestCommand{
+				t:        t,
+				err:      nil,
+				expected: "_,example.com",
+			}
+			conflicting := conflictIngress("user-namespace", "web", "example.com", false).Ingress
+			conflicting.ObjectMeta.Annotations = map[string]string{"kubernetes.io/ingress.class": "nginx"}
+
+			if nginx.CheckIngress(&conflicting) == nil {
+				t.Errorf("with a host and path defined by another ingress, an error should be returned")
+			}
+
+			nginx.cfg.ValidationWebhookConflicts = ConflictsWarn
+			if err := nginx.CheckIngress(&conflicting); err != nil {
+				t.Errorf("when the conflicts are warnings, no error should be returned but got %v", err)
+			}
+			warnings, err := nginx.CheckWarning(&conflicting)
+			if err != nil {
+				t.Errorf("no error should be returned, but %s was returned", err)
+			}
+			expected := `host "example.com" and path "/" is already defined in ingress other-namespace/web`
+			if len(warnings) != 1 || warnings[0] != expected {
+				t.Errorf("expected the warning %q but got %q", expected, warnings)
+			}
+		})
+
+		t.Run("When the ingress is applied when the ingress defining the host and path expires", func(t *testing.T) {
+			defer func() {
+				nginx.cfg.ValidationWebhookConflicts = ""
+			}()
+			cutover := time.Now().Add(time.Hour).Truncate(time.Second)
+			nginx.store = &fakeIngressStore{
+				ingresses: []*ingress.Ingress{withSchedule(conflictIngress("user-namespace", "current", "example.com", false), time.Time{}, cutover)},
+			}
+			nginx.command = testNginxTestCommand{
+				t:        t,
+				err:      nil,
+				expected: "_,example.com",
+			}
+			next := conflictIngress("user-namespace", "next", "example.com", false).Ingress
+			next.ObjectMeta.Annotations = map[string]string{
+				"kubernetes.io/ingress.class":              "nginx",
+				parser.GetAnnotationWithPrefix("apply-at"): cutover.Format(time.RFC3339),
+			}
+
+			if err := nginx.CheckIngress(&next); err != nil {
+				t.Errorf("with an ingress applied when the other ingress expires, no error should be returned but got %v", err)
+			}
+		})
+
 		t.Run("When the ingress is in a different namespace than the watched one", func(t *testing.T) {
 			defer func() {
 				nginx.cfg.Namespace = "test-namespace"
@@ -370,6 +510,94 @@ func TestCheckIngress(t *testing.T) {
 	})
 }
 
+// namespaceDefaultsIngressStore merges the default annotations of the
+// namespaces into the Ingresses, the ones of an Ingress taking precedence
+type namespaceDefaultsIngressStore struct {
+	fakeIngressStore
+	defaults map[string]map[string]string
+}
+
+func (fis *namespaceDefaultsIngressStore) WithNamespaceDefaults(ing *networking.Ingress) (*networking.Ingress, []string) {
+	merged := make(map[string]string)
+	for name, value := range fis.defaults[ing.Namespace] {
+		merged[name] = value
+	}
+	for name, value := range ing.Annotations {
+		merged[name] = value
+	}
+
+	withDefaults := ing.DeepCopy()
+	withDefaults.Annotations = merged
+	return withDefaults, nil
+}
+
+// allowlistTemplate writes the allowlists of the locations of the servers
+type allowlistTemplate struct{}
+
+func (allowlistTemplate) Write(conf *ngx_config.TemplateConfig) ([]byte, error) {
+	r := []string{}
+	for _, s := range conf.Servers {
+		for _, loc := range s.Locations {
+			if loc.Ingress != nil {
+				r = append(r, s.Hostname+loc.Path+"="+strings.Join(loc.Allowlist.CIDR, " "))
+			}
+		}
+	}
+	return []byte(strings.Join(r, ",")), nil
+}
+
+func TestCheckIngressNamespaceDefaults(t *testing.T) {
+	if err := file.CreateRequiredDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	nginx := newNGINXController(t)
+	nginx.metricCollector = metric.DummyCollector{}
+	nginx.t = allowlistTemplate{}
+	nginx.store = &namespaceDefaultsIngressStore{
+		fakeIngressStore: fakeIngressStore{
+			configuration: ngx_config.Configuration{AnnotationsRiskLevel: "Critical"},
+		},
+		defaults: map[string]map[string]string{
+			"user-namespace": {"nginx.ingress.kubernetes.io/allowlist-source-range": "10.0.0.0/8"},
+		},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ingress",
+			Namespace: "user-namespace",
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": "nginx",
+			},
+		},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{
+				{
+					Host: "example.com",
+				},
+			},
+		},
+	}
+
+	nginx.command = testNginxTestCommand{
+		t:        t,
+		expected: "example.com/=10.0.0.0/8",
+	}
+	if err := nginx.CheckIngress(ing); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ing.Annotations["nginx.ingress.kubernetes.io/allowlist-source-range"] = "192.168.0.0/16"
+	nginx.command = testNginxTestCommand{
+		t:        t,
+		expected: "example.com/=192.168.0.0/16",
+	}
+	if err := nginx.CheckIngress(ing); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestCheckWarning(t *testing.T) {
 	// Ensure no panic with wrong arguments
 	nginx := &NGINXController{}
@@ -1609,8 +1837,209 @@ func TestExtractTLSSecretName(t *testing.T) {
 	}
 }
 
+func TestSelectTLSSecretName(t *testing.T) {
+	certs := map[string]*ingress.SSLCert{
+		"default/wildcard": {Certificate: fakeX509Cert([]string{"*.example.com"})},
+		"default/exact":    {Certificate: fakeX509Cert([]string{"app.example.com"})},
+		"default/other":    {Certificate: fakeX509Cert([]string{"other.com"})},
+	}
+	getLocalSSLCert := func(key string) (*ingress.SSLCert, error) {
+		if cert, ok := certs[key]; ok {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("local SSL certificate %v was not found", key)
+	}
+
+	newIngress := func(tls ...networking.IngressTLS) *ingress.Ingress {
+		return &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       networking.IngressSpec{TLS: tls},
+			},
+		}
+	}
+
+	wildcardFirst := newIngress(
+		networking.IngressTLS{SecretName: "wildcard"},
+		networking.IngressTLS{SecretName: "exact"},
+	)
+
+	testCases := map[string]struct {
+		ingress     *ingress.Ingress
+		preference  string
+		expName     string
+		expFallback bool
+	}{
+		"no preference uses the first matching certificate": {
+			wildcardFirst, "", "wildcard", false,
+		},
+		"exact preference": {
+			wildcardFirst, "exact", "exact", false,
+		},
+		"wildcard preference": {
+			newIngress(networking.IngressTLS{SecretName: "exact"}, networking.IngressTLS{SecretName: "wildcard"}),
+			"wildcard", "wildcard", false,
+		},
+		"exact preference falls back to a wildcard certificate": {
+			newIngress(networking.IngressTLS{SecretName: "other"}, networking.IngressTLS{SecretName: "wildcard"}),
+			"exact", "wildcard", true,
+		},
+		"wildcard preference falls back to an exact certificate": {
+			newIngress(networking.IngressTLS{SecretName: "missing"}, networking.IngressTLS{SecretName: "exact"}),
+			"wildcard", "exact", true,
+		},
+		"listed hosts are checked first": {
+			newIngress(
+				networking.IngressTLS{SecretName: "exact"},
+				networking.IngressTLS{Hosts: []string{"App.example.com"}, SecretName: "wildcard"},
+			),
+			"wildcard", "wildcard", false,
+		},
+		"listed host without secret": {
+			newIngress(networking.IngressTLS{Hosts: []string{"app.example.com"}}),
+			"exact", "", false,
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			name, fallback := selectTLSSecretName("app.example.com", tc.ingress, tc.preference, getLocalSSLCert)
+			if name != tc.expName {
+				t.Errorf("Expected Secret name %q (got %q)", tc.expName, name)
+			}
+			if fallback != tc.expFallback {
+				t.Errorf("Expected fallback %v (got %v)", tc.expFallback, fallback)
+			}
+		})
+	}
+}
+
+func TestCertificateHostMatch(t *testing.T) {
+	testCases := []struct {
+		host     string
+		names    []string
+		expected string
+	}{
+		{"app.example.com", []string{"app.example.com"}, "exact"},
+		{"App.Example.com", []string{"*.example.com", "app.example.com"}, "exact"},
+		{"app.example.com", []string{"*.example.com"}, "wildcard"},
+		{"app.example.com", []string{"example.com"}, ""},
+		{"a.app.example.com", []string{"*.example.com"}, ""},
+	}
+
+	for _, tc := range testCases {
+		match := certificateHostMatch(tc.host, fakeX509Cert(tc.names))
+		if match != tc.expected {
+			t.Errorf("Expected %q for host %v and names %v (got %q)", tc.expected, tc.host, tc.names, match)
+		}
+	}
+
+	if match := certificateHostMatch("app.example.com", nil); match != "" {
+		t.Errorf("Expected no match without certificate (got %q)", match)
+	}
+}
+
+// newDiscoveredSSLCert returns a certificate of the Secret namespace/name
+// for the DNS names, signed by the parent or self-signed when nil
+func newDiscoveredSSLCert(t *testing.T, namespace, name string, dnsNames []string, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*ingress.SSLCert, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating a key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  dnsNames == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating a certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error parsing a certificate: %v", err)
+	}
+
+	return &ingress.SSLCert{
+		Namespace:   namespace,
+		Name:        name,
+		Certificate: cert,
+		ExpireTime:  cert.NotAfter,
+		PemCertKey:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}, key
+}
+
+func TestDiscoveredSSLCertificate(t *testing.T) {
+	now := time.Now()
+	ca, caKey := newDiscoveredSSLCert(t, "", "ca", nil, now.Add(24*time.Hour), nil, nil)
+	intermediate, intermediateKey := newDiscoveredSSLCert(t, "", "intermediate", nil, now.Add(24*time.Hour), ca.Certificate, caKey)
+
+	wildcard, _ := newDiscoveredSSLCert(t, "certs", "wildcard", []string{"*.example.com"}, now.Add(time.Hour), ca.Certificate, caKey)
+	renewed, _ := newDiscoveredSSLCert(t, "certs", "renewed", []string{"*.example.com"}, now.Add(2*time.Hour), ca.Certificate, caKey)
+	eu, _ := newDiscoveredSSLCert(t, "certs", "eu", []string{"*.eu.example.com"}, now.Add(time.Hour), intermediate.Certificate, intermediateKey)
+	eu.PemCertKey += intermediate.PemCertKey
+	apex, _ := newDiscoveredSSLCert(t, "certs", "apex", []string{"example.com"}, now.Add(time.Hour), ca.Certificate, caKey)
+	selfSigned, _ := newDiscoveredSSLCert(t, "certs", "self-signed", []string{"*.example.org"}, now.Add(3*time.Hour), nil, nil)
+	other, _ := newDiscoveredSSLCert(t, "other", "other", []string{"*.example.net"}, now.Add(time.Hour), ca.Certificate, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate)
+
+	domainCerts := map[string][]*ingress.SSLCert{
+		"example.com":    {wildcard, renewed, apex},
+		"eu.example.com": {eu},
+		"example.org":    {selfSigned},
+		"example.net":    {other},
+	}
+
+	certsNamespace := func(namespace string) bool { return namespace == "certs" }
+	anyNamespace := func(string) bool { return true }
+
+	testCases := []struct {
+		host     string
+		allowed  func(string) bool
+		expected *ingress.SSLCert
+	}{
+		{"shop.example.com", certsNamespace, renewed},
+		{"Shop.Example.com", certsNamespace, renewed},
+		{"example.com", certsNamespace, apex},
+		{"shop.eu.example.com", certsNamespace, eu},
+		{"a.shop.example.com", certsNamespace, nil},
+		{"shop.example.org", anyNamespace, nil},
+		{"shop.example.net", certsNamespace, nil},
+		{"shop.example.net", anyNamespace, other},
+	}
+
+	for _, tc := range testCases {
+		if cert := discoveredSSLCertificate(tc.host, tc.allowed, roots, domainCerts); cert != tc.expected {
+			t.Errorf("Expected certificate %v for host %v (got %v)", tc.expected, tc.host, cert)
+		}
+	}
+
+	// the eu certificate without its intermediate certificate
+	eu.PemCertKey = ""
+	if cert := discoveredSSLCertificate("shop.eu.example.com", certsNamespace, roots, domainCerts); cert != nil {
+		t.Errorf("Expected no certificate without the intermediate certificate (got %v)", cert)
+	}
+}
+
 //nolint:gocyclo // Ignore function complexity error
 func TestGetBackendServers(t *testing.T) {
+	pathTypeImplementationSpecific := networking.PathTypeImplementationSpecific
+
 	testCases := []struct {
 		Ingresses    []*ingress.Ingress
 		Validate     func(ingresses []*ingress.Ingress, upstreams []*ingress.Backend, servers []*ingress.Server)
@@ -2536,6 +2965,226 @@ func TestGetBackendServers(t *testing.T) {
 				}
 			},
 		},
+		{
+			Ingresses: []*ingress.Ingress{
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "users",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path:     "/users/{id:int}/orders",
+													PathType: &pathTypeImplementationSpecific,
+													Backend: networking.IngressBackend{
+														Service: &networking.IngressServiceBackend{
+															Name: "http-svc",
+															Port: networking.ServiceBackendPort{
+																Number: 80,
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						PathTemplate: pathtemplate.Config{
+							Enabled:      true,
+							HeaderPrefix: pathtemplate.DefaultHeaderPrefix,
+						},
+					},
+				},
+			},
+			Validate: func(_ []*ingress.Ingress, _ []*ingress.Backend, servers []*ingress.Server) {
+				if len(servers) != 2 {
+					t.Errorf("servers count should be 2, got %d", len(servers))
+					return
+				}
+
+				s := servers[1]
+				if len(s.Locations) != 2 {
+					t.Errorf("locations count should be 2, got %d", len(s.Locations))
+					return
+				}
+
+				loc := s.Locations[0]
+				if expected := `/users/(?<path_param_id>[0-9]+)/orders(?:/|$)`; loc.Path != expected {
+					t.Errorf("location path should be '%s', got '%s'", expected, loc.Path)
+				}
+				if len(loc.PathParameters) != 1 || loc.PathParameters[0] != "id" {
+					t.Errorf("location path parameters should be [id], got %v", loc.PathParameters)
+				}
+			},
+			SetConfigMap: testConfigMap,
+		},
+		{
+			Ingresses: []*ingress.Ingress{
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "shop",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path:     "/shop",
+													PathType: &pathTypePrefix,
+													Backend: networking.IngressBackend{
+														Service: &networking.IngressServiceBackend{
+															Name: "shop-svc",
+															Port: networking.ServiceBackendPort{
+																Number: 80,
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{},
+				},
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "tenant",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path:     "/blog",
+													PathType: &pathTypePrefix,
+													Backend: networking.IngressBackend{
+														Service: &networking.IngressServiceBackend{
+															Name: "blog-svc",
+															Port: networking.ServiceBackendPort{
+																Number: 80,
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						HostDefaultBackend: &networking.IngressBackend{
+							Service: &networking.IngressServiceBackend{
+								Name: "tenant-404",
+								Port: networking.ServiceBackendPort{
+									Number: 80,
+								},
+							},
+						},
+					},
+				},
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "other",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path:     "/docs",
+													PathType: &pathTypePrefix,
+													Backend: networking.IngressBackend{
+														Service: &networking.IngressServiceBackend{
+															Name: "docs-svc",
+															Port: networking.ServiceBackendPort{
+																Number: 80,
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						HostDefaultBackend: &networking.IngressBackend{
+							Service: &networking.IngressServiceBackend{
+								Name: "other-404",
+								Port: networking.ServiceBackendPort{
+									Number: 80,
+								},
+							},
+						},
+					},
+				},
+			},
+			Validate: func(_ []*ingress.Ingress, upstreams []*ingress.Backend, servers []*ingress.Server) {
+				if len(servers) != 2 {
+					t.Errorf("servers count should be 2, got %d", len(servers))
+					return
+				}
+
+				found := false
+				for _, upstream := range upstreams {
+					if upstream.Name == "example-tenant-404-80" {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("upstream 'example-tenant-404-80' should be created")
+				}
+
+				// the first Ingress setting the default backend of the host wins
+				for _, loc := range servers[1].Locations {
+					if loc.Path != rootLocation {
+						continue
+					}
+					if !loc.IsDefBackend || loc.Backend != "example-tenant-404-80" {
+						t.Errorf("root location backend should be 'example-tenant-404-80', got '%s'", loc.Backend)
+					}
+					if loc.Ingress == nil || loc.Ingress.Name != "tenant" {
+						t.Errorf("root location should belong to the Ingress 'tenant', got %v", loc.Ingress)
+					}
+					return
+				}
+				t.Errorf("server should have a root location")
+			},
+			SetConfigMap: testConfigMap,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -2588,8 +3237,15 @@ func newNGINXController(t *testing.T) *NGINXController {
 		fmt.Sprintf("%v/tcp", ns),
 		fmt.Sprintf("%v/udp", ns),
 		"",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"",
 		10*time.Minute,
 		clientSet,
+		nil,
 		channels.NewRingChannel(10),
 		false,
 		true,
@@ -2598,6 +3254,7 @@ func newNGINXController(t *testing.T) *NGINXController {
 			AnnotationValue: "nginx",
 		},
 		false,
+		false,
 	)
 
 	sslCert := ssl.GetFakeSSLCert()
@@ -2654,8 +3311,15 @@ func newDynamicNginxController(t *testing.T, setConfigMap func(string) *corev1.C
 		fmt.Sprintf("%v/tcp", ns),
 		fmt.Sprintf("%v/udp", ns),
 		"",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"",
 		10*time.Minute,
 		clientSet,
+		nil,
 		channels.NewRingChannel(10),
 		false,
 		true,
@@ -2663,6 +3327,7 @@ func newDynamicNginxController(t *testing.T, setConfigMap func(string) *corev1.C
 			Controller:      "k8s.io/ingress-nginx",
 			AnnotationValue: "nginx",
 		},
+		false,
 		false)
 
 	sslCert := ssl.GetFakeSSLCert()
@@ -2680,3 +3345,144 @@ func newDynamicNginxController(t *testing.T, setConfigMap func(string) *corev1.C
 		metricCollector: metric.DummyCollector{},
 	}
 }
+
+type fallbackIngressStore struct {
+	fakeIngressStore
+	secrets map[string]*corev1.Secret
+	certs   map[string]*ingress.SSLCert
+}
+
+func (fis *fallbackIngressStore) GetSecret(key string) (*corev1.Secret, error) {
+	if secret, ok := fis.secrets[key]; ok {
+		return secret, nil
+	}
+	return nil, fmt.Errorf("secret %v not found", key)
+}
+
+func (fis *fallbackIngressStore) GetLocalSSLCert(key string) (*ingress.SSLCert, error) {
+	if cert, ok := fis.certs[key]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("local SSL certificate %v was not found", key)
+}
+
+func TestSetRequiredSSLCertificateFallbacks(t *testing.T) {
+	fakeCert := ssl.GetFakeSSLCert()
+	pem := []byte(fakeCert.PemCertKey)
+
+	n := &NGINXController{
+		cfg: &Configuration{FakeCertificate: fakeCert},
+		store: &fallbackIngressStore{
+			secrets: map[string]*corev1.Secret{
+				"default/not-synced": {Data: map[string][]byte{corev1.TLSCertKey: pem, corev1.TLSPrivateKeyKey: pem}},
+				"default/invalid":    {Data: map[string][]byte{corev1.TLSCertKey: []byte("invalid")}},
+			},
+			certs: map[string]*ingress.SSLCert{
+				"default/valid":   fakeCert,
+				"default/no-x509": {},
+			},
+		},
+	}
+	ing := &ingress.Ingress{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"}}}
+
+	testCases := []struct {
+		host   string
+		secret string
+		reason string
+	}{
+		{"ingress.local", "default/valid", ""},
+		{"example.com", "default/valid", ingress.SSLCertFallbackHostMismatch},
+		{"ingress.local", "default/no-x509", ingress.SSLCertFallbackSecretInvalid},
+		{"ingress.local", "default/missing", ingress.SSLCertFallbackSecretMissing},
+		{"ingress.local", "default/not-synced", ingress.SSLCertFallbackSecretNotSynced},
+		{"ingress.local", "default/invalid", ingress.SSLCertFallbackSecretInvalid},
+	}
+
+	for _, tc := range testCases {
+		server := &ingress.Server{Hostname: tc.host}
+		n.setRequiredSSLCertificate(server, ing, tc.secret)
+
+		if tc.reason == "" {
+			if server.SSLCertFallback != nil {
+				t.Errorf("expected no fallback for %v with %v but got %v", tc.host, tc.secret, server.SSLCertFallback)
+			}
+			continue
+		}
+
+		expected := ingress.SSLCertFallback{Reason: tc.reason, Ingress: "default/demo", Secret: tc.secret, FakeCertificate: true}
+		if server.SSLCertFallback == nil || *server.SSLCertFallback != expected {
+			t.Errorf("expected fallback %v for %v with %v but got %v", expected, tc.host, tc.secret, server.SSLCertFallback)
+		}
+		if server.SSLCert != fakeCert {
+			t.Errorf("expected the fake certificate for %v with %v", tc.host, tc.secret)
+		}
+	}
+}
+
+func TestRecordSSLCertificateFallbacks(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	n := &NGINXController{recorder: recorder}
+
+	ings := []*ingress.Ingress{
+		{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"}}},
+	}
+	fallback := &ingress.SSLCertFallback{Reason: ingress.SSLCertFallbackSecretMissing, Ingress: "default/demo", Secret: "default/tls", FakeCertificate: true}
+	servers := []*ingress.Server{
+		{Hostname: "_"},
+		{Hostname: "example.com", SSLCertFallback: fallback},
+	}
+
+	n.recordSSLCertificateFallbacks(ings, servers)
+	expected := `Warning SSLCertificateFallback Host "example.com" is served with the fake certificate generated by the controller, Secret default/tls cannot be used (secret-missing)`
+	if event := <-recorder.Events; event != expected {
+		t.Errorf("expected event %q but got %q", expected, event)
+	}
+
+	// the same fallback is not recorded again
+	n.recordSSLCertificateFallbacks(ings, servers)
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected event %q", <-recorder.Events)
+	}
+
+	servers[1].SSLCertFallback = &ingress.SSLCertFallback{Reason: ingress.SSLCertFallbackSecretNotSynced, Ingress: "default/demo", Secret: "default/tls", FakeCertificate: true}
+	n.recordSSLCertificateFallbacks(ings, servers)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected an event for the new reason")
+	}
+	<-recorder.Events
+
+	// a host using the default certificate again is recorded
+	n.recordSSLCertificateFallbacks(ings, servers[:1])
+	n.recordSSLCertificateFallbacks(ings, servers)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected an event for the host using the default certificate again")
+	}
+}
+
+func TestIngressClassListenPorts(t *testing.T) {
+	n := &NGINXController{
+		cfg: &Configuration{
+			ListenPorts: &ngx_config.ListenPorts{HTTP: 80, HTTPS: 443, Health: 10254, Default: 8181, SSLProxy: 442},
+		},
+		store: &fakeIngressStore{
+			classConfigs: map[string]*store.IngressClassConfig{
+				"nginx":    {},
+				"internal": {ListenPorts: &ingress.ClassListenPorts{HTTP: 8080, HTTPS: 8443}},
+				"health":   {ListenPorts: &ingress.ClassListenPorts{HTTP: 10254, HTTPS: 10443}},
+			},
+		},
+	}
+
+	ports := n.ingressClassListenPorts()
+	if len(ports) != 1 || ports["internal"] == nil {
+		t.Errorf("expected the ports of the class internal only but got %v", ports)
+	}
+
+	reserved := n.reservedStreamPorts()
+	if !reserved.HasAll(8080, 8443, 80, 443) {
+		t.Errorf("expected the ports of the class internal to be reserved but got %v", reserved.List())
+	}
+	if reserved.Has(10443) {
+		t.Errorf("expected the ports of the class health to be ignored")
+	}
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha1" // #nosec
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+const (
+	// crlDownloadTimeout is the maximum time allowed to download a CRL
+	crlDownloadTimeout = 30 * time.Second
+	// crlMaxSize is the maximum size of a downloaded CRL
+	crlMaxSize = 10 << 20
+	// crlRetryInterval is the time to wait before retrying a failed download
+	crlRetryInterval = time.Minute
+)
+
+// remoteCRL contains the state of a certificate revocation list
+// downloaded from the URL configured with auth-tls-crl-url
+type remoteCRL struct {
+	url      string
+	interval time.Duration
+
+	// FileName contains the path to the last valid version of the CRL
+	FileName string
+	// SHA contains the SHA1 hash of FileName
+	SHA string
+	// NextUpdate is the time the issuer publishes a new version of the CRL
+	NextUpdate time.Time
+
+	list        *x509.RevocationList
+	lastAttempt time.Time
+	lastErr     error
+	fetching    bool
+	expired     bool
+}
+
+// Expired returns true when the downloaded CRL is past its next update
+func (c remoteCRL) Expired() bool {
+	return !c.NextUpdate.IsZero() && time.Now().After(c.NextUpdate)
+}
+
+// crlRefresher downloads the certificate revocation lists used for client
+// certificate authentication and keeps them up to date
+type crlRefresher struct {
+	directory       string
+	client          *http.Client
+	metricCollector metric.Collector
+	// onChange is called when a CRL is updated or expires
+	onChange func()
+
+	mu   sync.Mutex
+	crls map[string]*remoteCRL
+}
+
+func newCRLRefresher(directory string, mc metric.Collector, onChange func()) *crlRefresher {
+	return &crlRefresher{
+		directory:       directory,
+		client:          &http.Client{Timeout: crlDownloadTimeout},
+		metricCollector: mc,
+		onChange:        onChange,
+		crls:            make(map[string]*remoteCRL),
+	}
+}
+
+// Get returns the last downloaded version of the CRL located at url.
+// Get never schedules a download, the URLs are registered with Sync.
+func (r *crlRefresher) Get(url string) remoteCRL {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	crl, ok := r.crls[url]
+	if !ok {
+		return remoteCRL{url: url}
+	}
+
+	return *crl
+}
+
+// Sync registers the CRLs referenced by the configuration, indexed by URL
+// with their refresh interval. New URLs are downloaded in background and
+// the CRLs that are no longer referenced are removed with their files.
+func (r *crlRefresher) Sync(refs map[string]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for url, crl := range r.crls {
+		if _, ok := refs[url]; ok {
+			continue
+		}
+
+		delete(r.crls, url)
+		if crl.FileName != "" {
+			if err := os.Remove(crl.FileName); err != nil && !os.IsNotExist(err) {
+				klog.ErrorS(err, "Error removing certificate revocation list", "url", url)
+			}
+		}
+		klog.V(3).InfoS("Removed certificate revocation list no longer referenced", "url", url)
+	}
+
+	for url, interval := range refs {
+		crl, ok := r.crls[url]
+		if !ok {
+			r.crls[url] = &remoteCRL{url: url, interval: interval, fetching: true}
+			go r.refresh(url)
+			continue
+		}
+
+		crl.interval = interval
+	}
+}
+
+// Run refreshes the CRLs until stopCh is closed
+func (r *crlRefresher) Run(stopCh chan struct{}) {
+	wait.Until(r.refreshAll, 10*time.Second, stopCh)
+}
+
+func (r *crlRefresher) refreshAll() {
+	var due []string
+	changed := false
+
+	r.mu.Lock()
+	for url, crl := range r.crls {
+		if !crl.expired && crl.FileName != "" && crl.Expired() {
+			klog.Warningf("Certificate revocation list %q expired on %v", url, crl.NextUpdate)
+			crl.expired = true
+			changed = true
+		}
+
+		if crl.fetching {
+			continue
+		}
+
+		interval := crl.interval
+		if crl.lastErr != nil && crlRetryInterval < interval {
+			interval = crlRetryInterval
+		}
+		if time.Since(crl.lastAttempt) >= interval {
+			crl.fetching = true
+			due = append(due, url)
+		}
+	}
+	r.mu.Unlock()
+
+	if changed {
+		r.onChange()
+	}
+
+	for _, url := range due {
+		r.refresh(url)
+	}
+}
+
+// refresh downloads the CRL located at url and writes it to disk
+// when it differs from the previous version
+func (r *crlRefresher) refresh(url string) {
+	start := time.Now()
+	crl, data, err := r.download(url)
+	r.metricCollector.ObserveCRLRefresh(url, time.Since(start), err == nil)
+
+	r.mu.Lock()
+	entry, ok := r.crls[url]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+
+	entry.fetching = false
+	entry.lastAttempt = time.Now()
+	entry.lastErr = err
+	if err != nil {
+		r.mu.Unlock()
+		klog.ErrorS(err, "Error refreshing certificate revocation list", "url", url)
+		return
+	}
+
+	hasher := sha1.New() // #nosec
+	hasher.Write(data)
+	sha := hex.EncodeToString(hasher.Sum(nil))
+	entry.NextUpdate = crl.NextUpdate
+	entry.list = crl
+	if sha == entry.SHA {
+		entry.expired = entry.Expired()
+		r.mu.Unlock()
+		return
+	}
+
+	fileName, err := r.write(url, data)
+	if err != nil {
+		entry.lastErr = err
+		r.mu.Unlock()
+		klog.ErrorS(err, "Error writing certificate revocation list", "url", url)
+		return
+	}
+
+	entry.FileName = fileName
+	entry.SHA = sha
+	entry.expired = entry.Expired()
+	r.mu.Unlock()
+
+	klog.InfoS("Updated certificate revocation list", "url", url, "nextUpdate", crl.NextUpdate)
+	r.onChange()
+}
+
+// download returns the parsed CRL located at url and its PEM encoding.
+// Both PEM and DER encoded lists are accepted.
+func (r *crlRefresher) download(url string) (*x509.RevocationList, []byte, error) {
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, crlMaxSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > crlMaxSize {
+		return nil, nil, fmt.Errorf("CRL is bigger than %d bytes", crlMaxSize)
+	}
+
+	der := body
+	if block, _ := pem.Decode(body); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		der = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CRL: %w", err)
+	}
+
+	return crl, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// write atomically replaces the file used by NGINX for the CRL located at url
+func (r *crlRefresher) write(url string, data []byte) (string, error) {
+	hasher := sha1.New() // #nosec
+	hasher.Write([]byte(url))
+	fileName := filepath.Join(r.directory, fmt.Sprintf("crl-url-%v.pem", hex.EncodeToString(hasher.Sum(nil))))
+
+	tmp, err := os.CreateTemp(r.directory, "crl-url-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // nginx workers must be able to read the file
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+
+	return fileName, os.Rename(tmp.Name(), fileName)
+}
+
+// syncRevocationChecks registers the CRLs and the OCSP responders used by
+// the servers, the ones no longer used are not checked anymore
+func (n *NGINXController) syncRevocationChecks(servers []*ingress.Server) {
+	crls := make(map[string]time.Duration)
+	responders := make(map[string]string)
+	for _, server := range servers {
+		auth := server.CertificateAuth
+		if auth.CAFileName == "" {
+			continue
+		}
+
+		if auth.CRLURL != "" {
+			// the same CRL can be used by several servers
+			if interval, ok := crls[auth.CRLURL]; !ok || auth.CRLRefreshInterval < interval {
+				crls[auth.CRLURL] = auth.CRLRefreshInterval
+			}
+		}
+
+		// OCSP is disabled when the responder is not available in fail-open mode
+		if auth.OCSPResponder != "" {
+			if _, ok := responders[auth.OCSPResponder]; !ok {
+				responders[auth.OCSPResponder] = auth.CAFileName
+			}
+		}
+	}
+
+	if n.crlRefresher != nil {
+		n.crlRefresher.Sync(crls)
+	}
+	if n.ocspProber != nil {
+		n.ocspProber.Sync(responders)
+	}
+}
+
+// applyRemoteCRL configures the server to use the CRL downloaded from the
+// URL configured with auth-tls-crl-url, according to the failure mode
+func (n *NGINXController) applyRemoteCRL(server *ingress.Server) {
+	if n.crlRefresher == nil {
+		return
+	}
+
+	auth := &server.CertificateAuth
+	crl := n.crlRefresher.Get(auth.CRLURL)
+	if crl.FileName != "" {
+		if err := verifyCRLIssuer(crl.list, auth.CAFileName); err != nil {
+			klog.Warningf("Certificate revocation list %q cannot be used for server %q: %v", auth.CRLURL, server.Hostname, err)
+			crl = remoteCRL{url: crl.url}
+		}
+	}
+
+	switch {
+	case crl.FileName != "" && (!crl.Expired() || !auth.RevocationFailOpen()):
+		// an expired CRL makes NGINX deny every client certificate
+		auth.CRLFileName = crl.FileName
+		auth.CRLSHA = crl.SHA
+	case auth.RevocationFailOpen():
+		klog.Warningf("Certificate revocation list %q is not available, client certificates of server %q are not checked against it",
+			auth.CRLURL, server.Hostname)
+	default:
+		server.AuthTLSError = fmt.Sprintf("certificate revocation list %v is not available", auth.CRLURL)
+	}
+}
+
+// verifyCRLIssuer returns an error when the CRL is not signed
+// by one of the certificates of the file caFileName
+func verifyCRLIssuer(crl *x509.RevocationList, caFileName string) error {
+	if crl == nil {
+		return fmt.Errorf("the CRL was not parsed")
+	}
+
+	certs, err := readCertificates(caFileName)
+	if err != nil {
+		return err
+	}
+
+	for _, cert := range certs {
+		if crl.CheckSignatureFrom(cert) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the CRL is not signed by the certificate authority %v", caFileName)
+}
+
+// readCertificates returns the certificates of the PEM file fileName
+func readCertificates(fileName string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %v", fileName)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,355 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func fakeCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate: %v", err)
+	}
+
+	return ca, key
+}
+
+func writeFakeCA(t *testing.T, ca *x509.Certificate) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatalf("unexpected error writing CA: %v", err)
+	}
+
+	return fileName
+}
+
+func fakeCRL(t *testing.T, nextUpdate time.Time) []byte {
+	t.Helper()
+
+	ca, key := fakeCA(t)
+	return fakeCRLFrom(t, ca, key, nextUpdate)
+}
+
+func fakeCRLFrom(t *testing.T, ca *x509.Certificate, key *ecdsa.PrivateKey, nextUpdate time.Time) []byte {
+	t.Helper()
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}, ca, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating CRL: %v", err)
+	}
+
+	return crl
+}
+
+func TestCRLRefresherDownload(t *testing.T) {
+	der := fakeCRL(t, time.Now().Add(time.Hour))
+
+	testCases := map[string]struct {
+		body      []byte
+		status    int
+		expectErr bool
+	}{
+		"DER encoded CRL":      {der, http.StatusOK, false},
+		"PEM encoded CRL":      {pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), http.StatusOK, false},
+		"unexpected PEM block": {pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), http.StatusOK, true},
+		"invalid content":      {[]byte("not a CRL"), http.StatusOK, true},
+		"not found":            {der, http.StatusNotFound, true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+				//nolint:errcheck // test server
+				w.Write(tc.body)
+			}))
+			defer srv.Close()
+
+			r := newCRLRefresher(t.TempDir(), metric.DummyCollector{}, func() {})
+			crl, data, err := r.download(srv.URL)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error but none returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if crl.Number.Cmp(big.NewInt(1)) != 0 {
+				t.Errorf("expected CRL number 1 but got %v", crl.Number)
+			}
+			if block, _ := pem.Decode(data); block == nil || block.Type != "X509 CRL" {
+				t.Errorf("expected a PEM encoded CRL but got %q", data)
+			}
+		})
+	}
+}
+
+func TestCRLRefresherRefresh(t *testing.T) {
+	body := fakeCRL(t, time.Now().Add(time.Hour))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		//nolint:errcheck // test server
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	changes := make(chan struct{}, 10)
+	r := newCRLRefresher(t.TempDir(), metric.DummyCollector{}, func() {
+		changes <- struct{}{}
+	})
+
+	if crl := r.Get(srv.URL); crl.FileName != "" || len(r.crls) != 0 {
+		t.Fatalf("expected no download before the CRL is registered")
+	}
+
+	r.Sync(map[string]time.Duration{srv.URL: time.Hour})
+
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected a notification after the download of the CRL")
+	}
+
+	crl := r.Get(srv.URL)
+	if crl.FileName == "" || crl.SHA == "" {
+		t.Fatalf("expected a downloaded CRL but got %+v", crl)
+	}
+	if _, err := os.Stat(crl.FileName); err != nil {
+		t.Errorf("expected the CRL file to exist: %v", err)
+	}
+
+	// the same content must not trigger a new sync
+	r.refresh(srv.URL)
+	if len(changes) != 0 {
+		t.Errorf("expected no notification when the CRL did not change")
+	}
+
+	body = fakeCRL(t, time.Now().Add(2*time.Hour))
+	r.refresh(srv.URL)
+	if len(changes) != 1 {
+		t.Errorf("expected a notification when the CRL changed")
+	}
+	if updated := r.Get(srv.URL); updated.SHA == crl.SHA {
+		t.Errorf("expected a new SHA after the CRL changed")
+	}
+
+	// CRLs no longer referenced are removed
+	r.Sync(map[string]time.Duration{})
+	if len(r.crls) != 0 {
+		t.Errorf("expected the CRL to be removed but got %v", r.crls)
+	}
+	if _, err := os.Stat(crl.FileName); !os.IsNotExist(err) {
+		t.Errorf("expected the CRL file to be removed: %v", err)
+	}
+}
+
+func TestApplyRemoteCRL(t *testing.T) {
+	const crlURL = "http://pki.example.com/ca.crl"
+
+	ca, key := fakeCA(t)
+	caFileName := writeFakeCA(t, ca)
+
+	parse := func(der []byte) *x509.RevocationList {
+		list, err := x509.ParseRevocationList(der)
+		if err != nil {
+			t.Fatalf("unexpected error parsing CRL: %v", err)
+		}
+		return list
+	}
+	valid := parse(fakeCRLFrom(t, ca, key, time.Now().Add(time.Hour)))
+	expired := parse(fakeCRLFrom(t, ca, key, time.Now().Add(-time.Minute)))
+	otherIssuer := parse(fakeCRL(t, time.Now().Add(time.Hour)))
+
+	testCases := map[string]struct {
+		crl           remoteCRL
+		failureMode   string
+		expectedCRL   string
+		expectedError bool
+	}{
+		"valid CRL": {
+			crl:         remoteCRL{FileName: "/ssl/crl.pem", SHA: "abc", NextUpdate: valid.NextUpdate, list: valid},
+			failureMode: authtls.RevocationFailClosed,
+			expectedCRL: "/ssl/crl.pem",
+		},
+		"expired CRL fail-closed": {
+			crl:         remoteCRL{FileName: "/ssl/crl.pem", SHA: "abc", NextUpdate: expired.NextUpdate, list: expired},
+			failureMode: authtls.RevocationFailClosed,
+			expectedCRL: "/ssl/crl.pem",
+		},
+		"expired CRL fail-open": {
+			crl:         remoteCRL{FileName: "/ssl/crl.pem", SHA: "abc", NextUpdate: expired.NextUpdate, list: expired},
+			failureMode: authtls.RevocationFailOpen,
+		},
+		"CRL of another issuer fail-closed": {
+			crl:           remoteCRL{FileName: "/ssl/crl.pem", SHA: "abc", NextUpdate: otherIssuer.NextUpdate, list: otherIssuer},
+			failureMode:   authtls.RevocationFailClosed,
+			expectedError: true,
+		},
+		"CRL of another issuer fail-open": {
+			crl:         remoteCRL{FileName: "/ssl/crl.pem", SHA: "abc", NextUpdate: otherIssuer.NextUpdate, list: otherIssuer},
+			failureMode: authtls.RevocationFailOpen,
+		},
+		"unavailable CRL fail-closed": {
+			failureMode:   authtls.RevocationFailClosed,
+			expectedError: true,
+		},
+		"unavailable CRL fail-open": {
+			failureMode: authtls.RevocationFailOpen,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			crl := tc.crl
+			crl.url = crlURL
+			crl.interval = time.Hour
+			crl.fetching = true
+
+			n := &NGINXController{
+				crlRefresher: &crlRefresher{
+					crls: map[string]*remoteCRL{crlURL: &crl},
+				},
+			}
+
+			server := &ingress.Server{
+				Hostname: "example.com",
+				CertificateAuth: authtls.Config{
+					AuthSSLCert: resolver.AuthSSLCert{
+						CAFileName: caFileName,
+					},
+					CRLURL:                crlURL,
+					CRLRefreshInterval:    time.Hour,
+					RevocationFailureMode: tc.failureMode,
+				},
+			}
+
+			n.applyRemoteCRL(server)
+
+			if server.CertificateAuth.CRLFileName != tc.expectedCRL {
+				t.Errorf("expected CRL file %q but got %q", tc.expectedCRL, server.CertificateAuth.CRLFileName)
+			}
+			if tc.expectedError != (server.AuthTLSError != "") {
+				t.Errorf("expected error %t but got %q", tc.expectedError, server.AuthTLSError)
+			}
+		})
+	}
+}
+
+func TestSyncRevocationChecks(t *testing.T) {
+	n := &NGINXController{
+		crlRefresher: newCRLRefresher(t.TempDir(), metric.DummyCollector{}, func() {}),
+		ocspProber:   newOCSPProber(metric.DummyCollector{}, func() {}),
+	}
+
+	// the checks are scheduled in background and fail against these URLs
+	n.crlRefresher.client.Timeout = time.Millisecond
+	n.ocspProber.client.Timeout = time.Millisecond
+
+	auth := func(crlURL string, interval time.Duration, responder string) *ingress.Server {
+		return &ingress.Server{CertificateAuth: authtls.Config{
+			AuthSSLCert:        resolver.AuthSSLCert{CAFileName: "/ssl/ca.pem"},
+			CRLURL:             crlURL,
+			CRLRefreshInterval: interval,
+			OCSP:               "on",
+			OCSPResponder:      responder,
+		}}
+	}
+
+	n.syncRevocationChecks([]*ingress.Server{
+		auth("http://127.0.0.1:1/a.crl", time.Hour, "http://127.0.0.1:1/ocsp"),
+		auth("http://127.0.0.1:1/a.crl", time.Minute, ""),
+		auth("http://127.0.0.1:1/b.crl", time.Hour, ""),
+		{CertificateAuth: authtls.Config{CRLURL: "http://127.0.0.1:1/no-ca.crl"}},
+	})
+
+	n.crlRefresher.mu.Lock()
+	if len(n.crlRefresher.crls) != 2 {
+		t.Errorf("expected 2 CRLs but got %v", len(n.crlRefresher.crls))
+	}
+	if crl := n.crlRefresher.crls["http://127.0.0.1:1/a.crl"]; crl == nil || crl.interval != time.Minute {
+		t.Errorf("expected the shortest refresh interval of the CRL but got %+v", crl)
+	}
+	n.crlRefresher.mu.Unlock()
+
+	n.ocspProber.mu.Lock()
+	if len(n.ocspProber.responders) != 1 {
+		t.Errorf("expected 1 OCSP responder but got %v", len(n.ocspProber.responders))
+	}
+	n.ocspProber.mu.Unlock()
+
+	n.syncRevocationChecks([]*ingress.Server{auth("http://127.0.0.1:1/b.crl", time.Hour, "")})
+
+	n.crlRefresher.mu.Lock()
+	if _, ok := n.crlRefresher.crls["http://127.0.0.1:1/a.crl"]; ok || len(n.crlRefresher.crls) != 1 {
+		t.Errorf("expected the CRL no longer referenced to be removed but got %v", n.crlRefresher.crls)
+	}
+	n.crlRefresher.mu.Unlock()
+
+	n.ocspProber.mu.Lock()
+	if len(n.ocspProber.responders) != 0 {
+		t.Errorf("expected the OCSP responder no longer referenced to be removed but got %v", n.ocspProber.responders)
+	}
+	n.ocspProber.mu.Unlock()
+}
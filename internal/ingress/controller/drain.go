@@ -0,0 +1,287 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// EndpointDrainAPIPath is the path of the API removing endpoints from the
+// upstreams of all the replicas of the controller for a while
+const EndpointDrainAPIPath = "/api/v1/endpoints/drain"
+
+// drainedEndpointsKey is the key of the drained endpoints ConfigMap
+// containing the JSON list of the drained endpoints
+const drainedEndpointsKey = "endpoints"
+
+// maxDrainDuration is the longest time an endpoint can be drained for
+const maxDrainDuration = 24 * time.Hour
+
+// DrainedEndpoint is an endpoint removed from the upstreams until a time.
+// An empty port drains all the ports of the address.
+type DrainedEndpoint struct {
+	Address string    `json:"address"`
+	Port    string    `json:"port,omitempty"`
+	Until   time.Time `json:"until"`
+}
+
+func (d DrainedEndpoint) matches(address, port string) bool {
+	return d.Address == address && (d.Port == "" || d.Port == port)
+}
+
+// parseDrainedEndpoints returns the endpoints of the drained endpoints
+// ConfigMap still drained at now
+func parseDrainedEndpoints(cm *corev1.ConfigMap, now time.Time) ([]DrainedEndpoint, error) {
+	if cm == nil || cm.Data[drainedEndpointsKey] == "" {
+		return []DrainedEndpoint{}, nil
+	}
+
+	var drained []DrainedEndpoint
+	if err := json.Unmarshal([]byte(cm.Data[drainedEndpointsKey]), &drained); err != nil {
+		return nil, err
+	}
+
+	active := make([]DrainedEndpoint, 0, len(drained))
+	for _, d := range drained {
+		if d.Until.After(now) {
+			active = append(active, d)
+		}
+	}
+
+	return active, nil
+}
+
+// getDrainedEndpoints returns the endpoints currently drained
+func (n *NGINXController) getDrainedEndpoints() []DrainedEndpoint {
+	if n.cfg.DrainedEndpointsConfigMapName == "" {
+		return []DrainedEndpoint{}
+	}
+
+	cm, err := n.store.GetConfigMap(n.cfg.DrainedEndpointsConfigMapName)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			klog.Warningf("Error reading drained endpoints ConfigMap %q: %v", n.cfg.DrainedEndpointsConfigMapName, err)
+		}
+		return []DrainedEndpoint{}
+	}
+
+	drained, err := parseDrainedEndpoints(cm, time.Now())
+	if err != nil {
+		klog.Warningf("Error parsing drained endpoints ConfigMap %q: %v", n.cfg.DrainedEndpointsConfigMapName, err)
+		return []DrainedEndpoint{}
+	}
+
+	return drained
+}
+
+// drainEndpoints removes the drained endpoints from the upstreams. The
+// endpoints of an upstream are not removed when all of them are drained.
+func drainEndpoints(upstreams []*ingress.Backend, drained []DrainedEndpoint) {
+	if len(drained) == 0 {
+		return
+	}
+
+	for _, upstream := range upstreams {
+		endpoints := make([]ingress.Endpoint, 0, len(upstream.Endpoints))
+		for _, ep := range upstream.Endpoints {
+			isDrained := false
+			for _, d := range drained {
+				if d.matches(ep.Address, ep.Port) {
+					isDrained = true
+					break
+				}
+			}
+			if !isDrained {
+				endpoints = append(endpoints, ep)
+			}
+		}
+
+		if len(endpoints) == len(upstream.Endpoints) {
+			continue
+		}
+		if len(endpoints) == 0 {
+			klog.Warningf("Not draining the endpoints of upstream %q: all of them are drained", upstream.Name)
+			continue
+		}
+
+		klog.V(2).InfoS("Draining endpoints", "upstream", upstream.Name, "drained", len(upstream.Endpoints)-len(endpoints))
+		upstream.Endpoints = endpoints
+	}
+}
+
+// scheduleDrainExpiry syncs the configuration again when the first of the
+// drained endpoints expires, to add it back to its upstreams
+func (n *NGINXController) scheduleDrainExpiry(drained []DrainedEndpoint) {
+	n.drainExpiryLock.Lock()
+	defer n.drainExpiryLock.Unlock()
+
+	if n.drainExpiry != nil {
+		n.drainExpiry.Stop()
+		n.drainExpiry = nil
+	}
+
+	if len(drained) == 0 {
+		return
+	}
+
+	next := drained[0].Until
+	for _, d := range drained[1:] {
+		if d.Until.Before(next) {
+			next = d.Until
+		}
+	}
+
+	n.drainExpiry = time.AfterFunc(time.Until(next), func() {
+		n.syncQueue.EnqueueTask(task.GetDummyObject("drained-endpoint-expired"))
+	})
+}
+
+// updateDrainedEndpoints applies update to the endpoints of the drained
+// endpoints ConfigMap, creating it when it does not exist
+func (n *NGINXController) updateDrainedEndpoints(update func([]DrainedEndpoint) ([]DrainedEndpoint, error)) ([]DrainedEndpoint, error) {
+	var result []DrainedEndpoint
+	err := n.updateConfigMap(n.cfg.DrainedEndpointsConfigMapName, func(cm *corev1.ConfigMap) error {
+		drained, err := parseDrainedEndpoints(cm, time.Now())
+		if err != nil {
+			return err
+		}
+
+		result, err = update(drained)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Address != result[j].Address {
+				return result[i].Address < result[j].Address
+			}
+			return result[i].Port < result[j].Port
+		})
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		cm.Data[drainedEndpointsKey] = string(data)
+		return nil
+	})
+
+	return result, err
+}
+
+// errEndpointNotDrained is returned when undraining an endpoint not drained
+var errEndpointNotDrained = errors.New("the endpoint is not drained")
+
+// EndpointDrainAPIHandler returns the handler of the API draining endpoints
+// from the upstreams of all the replicas of the controller, through the
+// drained endpoints ConfigMap. The handler does not authenticate the
+// requests.
+//
+//	GET    /api/v1/endpoints/drain                                        the drained endpoints
+//	POST   /api/v1/endpoints/drain?address=<ip>&port=<port>&minutes=<n>  drains the endpoint for n minutes
+//	DELETE /api/v1/endpoints/drain?address=<ip>&port=<port>               adds the endpoint back
+//
+// The port parameter is optional, all the ports of the address being
+// drained without it.
+func (n *NGINXController) EndpointDrainAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, n.getDrainedEndpoints())
+			return
+		}
+
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		address, port := query.Get("address"), query.Get("port")
+		if net.ParseIP(address) == nil {
+			http.Error(w, "the address parameter must be an IP address", http.StatusBadRequest)
+			return
+		}
+		if port != "" {
+			if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+				http.Error(w, "the port parameter must be a port number", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var update func([]DrainedEndpoint) ([]DrainedEndpoint, error)
+		if r.Method == http.MethodPost {
+			minutes, err := strconv.Atoi(query.Get("minutes"))
+			duration := time.Duration(minutes) * time.Minute
+			if err != nil || minutes < 1 || duration > maxDrainDuration {
+				http.Error(w, fmt.Sprintf("the minutes parameter must be a number of minutes between 1 and %v", int(maxDrainDuration.Minutes())), http.StatusBadRequest)
+				return
+			}
+
+			update = func(drained []DrainedEndpoint) ([]DrainedEndpoint, error) {
+				result := []DrainedEndpoint{{Address: address, Port: port, Until: time.Now().Add(duration).UTC().Truncate(time.Second)}}
+				for _, d := range drained {
+					if d.Address != address || d.Port != port {
+						result = append(result, d)
+					}
+				}
+				return result, nil
+			}
+		} else {
+			update = func(drained []DrainedEndpoint) ([]DrainedEndpoint, error) {
+				result := make([]DrainedEndpoint, 0, len(drained))
+				for _, d := range drained {
+					if d.Address != address || d.Port != port {
+						result = append(result, d)
+					}
+				}
+				if len(result) == len(drained) {
+					return nil, errEndpointNotDrained
+				}
+				return result, nil
+			}
+		}
+
+		drained, err := n.updateDrainedEndpoints(update)
+		if errors.Is(err, errEndpointNotDrained) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			klog.ErrorS(err, "Error updating drained endpoints", "configmap", n.cfg.DrainedEndpointsConfigMapName)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		klog.InfoS("Drained endpoints updated", "method", r.Method, "address", address, "port", port)
+		writeJSON(w, drained)
+	})
+}
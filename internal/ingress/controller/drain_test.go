@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/metrics"
+)
+
+// drainStore reads the ConfigMaps from the fake client
+type drainStore struct {
+	fakeIngressStore
+	client kubernetes.Interface
+}
+
+func (s *drainStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
+	ns, name, err := k8s.ParseNameNS(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func TestParseDrainedEndpoints(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	drained, err := parseDrainedEndpoints(nil, now)
+	if err != nil || len(drained) != 0 {
+		t.Errorf("expected no drained endpoints but returned %v, %v", drained, err)
+	}
+
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		drainedEndpointsKey: `[{"address":"10.0.0.1","port":"8080","until":"2024-01-01T12:10:00Z"},{"address":"10.0.0.2","until":"2024-01-01T11:50:00Z"}]`,
+	}}
+	drained, err = parseDrainedEndpoints(cm, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []DrainedEndpoint{{Address: "10.0.0.1", Port: "8080", Until: now.Add(10 * time.Minute)}}
+	if !reflect.DeepEqual(drained, expected) {
+		t.Errorf("expected %v but returned %v", expected, drained)
+	}
+
+	cm.Data[drainedEndpointsKey] = "invalid"
+	if _, err := parseDrainedEndpoints(cm, now); err == nil {
+		t.Errorf("expected an error parsing invalid drained endpoints")
+	}
+}
+
+func TestDrainEndpoints(t *testing.T) {
+	upstreams := []*ingress.Backend{
+		{
+			Name: "default-echo-80",
+			Endpoints: []ingress.Endpoint{
+				{Address: "10.0.0.1", Port: "8080"},
+				{Address: "10.0.0.2", Port: "8080"},
+				{Address: "10.0.0.2", Port: "9090"},
+			},
+		},
+		{
+			Name:      "default-single-80",
+			Endpoints: []ingress.Endpoint{{Address: "10.0.0.3", Port: "8080"}},
+		},
+	}
+
+	drainEndpoints(upstreams, []DrainedEndpoint{
+		{Address: "10.0.0.2"},
+		{Address: "10.0.0.1", Port: "9090"},
+		{Address: "10.0.0.3", Port: "8080"},
+	})
+
+	expected := []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}}
+	if !reflect.DeepEqual(upstreams[0].Endpoints, expected) {
+		t.Errorf("expected endpoints %v but returned %v", expected, upstreams[0].Endpoints)
+	}
+
+	// the last endpoint of an upstream is never drained
+	expected = []ingress.Endpoint{{Address: "10.0.0.3", Port: "8080"}}
+	if !reflect.DeepEqual(upstreams[1].Endpoints, expected) {
+		t.Errorf("expected endpoints %v but returned %v", expected, upstreams[1].Endpoints)
+	}
+}
+
+func TestEndpointDrainAPI(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	n := &NGINXController{
+		cfg: &Configuration{
+			Client:                        client,
+			DrainedEndpointsConfigMapName: "ingress-nginx/drained-endpoints",
+		},
+		store: &drainStore{client: client},
+	}
+
+	handler := metrics.RequireBearerToken("secret", n.EndpointDrainAPIHandler())
+
+	testCases := []struct {
+		name           string
+		method         string
+		query          string
+		token          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"without token", http.MethodPost, "?address=10.0.0.1&minutes=5", "", http.StatusUnauthorized, -1},
+		{"invalid address", http.MethodPost, "?address=pod&minutes=5", "secret", http.StatusBadRequest, -1},
+		{"invalid port", http.MethodPost, "?address=10.0.0.1&port=0&minutes=5", "secret", http.StatusBadRequest, -1},
+		{"invalid minutes", http.MethodPost, "?address=10.0.0.1&minutes=10000", "secret", http.StatusBadRequest, -1},
+		{"drain address", http.MethodPost, "?address=10.0.0.1&minutes=5", "secret", http.StatusOK, 1},
+		{"drain endpoint", http.MethodPost, "?address=10.0.0.2&port=8080&minutes=5", "secret", http.StatusOK, 2},
+		{"drain endpoint again", http.MethodPost, "?address=10.0.0.2&port=8080&minutes=10", "secret", http.StatusOK, 2},
+		{"drained endpoints", http.MethodGet, "", "secret", http.StatusOK, 2},
+		{"undrain endpoint", http.MethodDelete, "?address=10.0.0.2&port=8080", "secret", http.StatusOK, 1},
+		{"undrain endpoint not drained", http.MethodDelete, "?address=10.0.0.2&port=8080", "secret", http.StatusNotFound, -1},
+		{"unsupported method", http.MethodPut, "?address=10.0.0.1", "secret", http.StatusMethodNotAllowed, -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, EndpointDrainAPIPath+tc.query, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v but got %v: %v", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.expectedCount < 0 {
+				return
+			}
+
+			var drained []DrainedEndpoint
+			if err := json.Unmarshal(w.Body.Bytes(), &drained); err != nil {
+				t.Fatalf("unexpected error decoding the response: %v", err)
+			}
+			if len(drained) != tc.expectedCount {
+				t.Errorf("expected %v drained endpoints but got %v", tc.expectedCount, drained)
+			}
+		})
+	}
+
+	drained := n.getDrainedEndpoints()
+	if len(drained) != 1 || drained[0].Address != "10.0.0.1" || drained[0].Port != "" {
+		t.Errorf("expected the address 10.0.0.1 to be drained but got %v", drained)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// dynamicLocation is the Lua representation of a location of a dynamic server
+type dynamicLocation struct {
+	Path                  string `json:"path"`
+	PathType              string `json:"pathType"`
+	Backend               string `json:"backend"`
+	Namespace             string `json:"namespace"`
+	Ingress               string `json:"ingress"`
+	Service               string `json:"service"`
+	ServicePort           string `json:"servicePort"`
+	SSLRedirect           bool   `json:"sslRedirect"`
+	ForceSSLRedirect      bool   `json:"forceSSLRedirect"`
+	ForceNoSSLRedirect    bool   `json:"forceNoSSLRedirect"`
+	PreserveTrailingSlash bool   `json:"preserveTrailingSlash"`
+	UsePortInRedirects    bool   `json:"usePortInRedirects"`
+}
+
+// namespaceDefaultsFunc returns a copy of an Ingress that also contains the
+// default annotations of its namespace
+type namespaceDefaultsFunc func(*networking.Ingress) (*networking.Ingress, []string)
+
+// hasIngressAnnotations returns true when an Ingress changes the global
+// settings of the controller with annotations, including the default
+// annotations of its namespace
+func hasIngressAnnotations(ing *ingress.Ingress, withDefaults namespaceDefaultsFunc) bool {
+	withAnnotations := &ing.Ingress
+	if withDefaults != nil {
+		withAnnotations, _ = withDefaults(withAnnotations)
+	}
+
+	for key := range withAnnotations.GetAnnotations() {
+		if strings.HasPrefix(key, parser.AnnotationsPrefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// canRouteDynamicServers returns true when the catch-all server only has
+// its root location without annotations, so the requests routed to the
+// dynamic servers do not get the settings of another Ingress
+func canRouteDynamicServers(servers []*ingress.Server, withDefaults namespaceDefaultsFunc) bool {
+	for _, server := range servers {
+		if server.Hostname != defServerName {
+			continue
+		}
+		if len(server.Locations) != 1 || server.ServerSnippet != "" {
+			return false
+		}
+		loc := server.Locations[0]
+		return loc.Path == rootLocation && (loc.Ingress == nil || !hasIngressAnnotations(loc.Ingress, withDefaults))
+	}
+	return false
+}
+
+// isDynamicServer returns true when the locations of a server only come
+// from Ingresses without annotations. The catch-all server rejects the TLS
+// handshakes with ssl-reject-handshake, the servers with TLS need a server
+// block of their own, as well as the servers listening on the ports of their
+// IngressClass.
+func isDynamicServer(server *ingress.Server, rejectHandshake bool, withDefaults namespaceDefaultsFunc) bool {
+	if server.Hostname == defServerName || strings.HasPrefix(server.Hostname, "*") {
+		return false
+	}
+	if server.ClassListenPorts != nil {
+		return false
+	}
+	if rejectHandshake && server.SSLCert != nil {
+		return false
+	}
+
+	for _, loc := range server.Locations {
+		if loc.Ingress != nil && hasIngressAnnotations(loc.Ingress, withDefaults) {
+			return false
+		}
+	}
+	return true
+}
+
+// markDynamicServers marks the servers whose requests can be routed by
+// the catch-all server
+func markDynamicServers(servers []*ingress.Server, rejectHandshake bool, withDefaults namespaceDefaultsFunc) {
+	if !canRouteDynamicServers(servers, withDefaults) {
+		return
+	}
+
+	for _, server := range servers {
+		server.Dynamic = isDynamicServer(server, rejectHandshake, withDefaults)
+	}
+}
+
+// hasDynamicServers returns true when a server is routed by the catch-all server
+func hasDynamicServers(servers []*ingress.Server) bool {
+	for _, server := range servers {
+		if server.Dynamic {
+			return true
+		}
+	}
+	return false
+}
+
+// renderedServers returns the servers with a server block of their own
+func renderedServers(servers []*ingress.Server) []*ingress.Server {
+	rendered := make([]*ingress.Server, 0, len(servers))
+	for _, server := range servers {
+		if !server.Dynamic {
+			rendered = append(rendered, server)
+		}
+	}
+	return rendered
+}
+
+// buildDynamicServers returns the locations of the dynamic servers by hostname
+func buildDynamicServers(servers []*ingress.Server, noTLSRedirectLocations string) map[string][]dynamicLocation {
+	noTLSRedirect := []string{}
+	for _, path := range strings.Split(noTLSRedirectLocations, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			noTLSRedirect = append(noTLSRedirect, path)
+		}
+	}
+
+	dynamicServers := map[string][]dynamicLocation{}
+	for _, server := range servers {
+		if !server.Dynamic {
+			continue
+		}
+
+		locations := make([]dynamicLocation, 0, len(server.Locations))
+		for _, loc := range server.Locations {
+			dl := dynamicLocation{
+				Path:                  loc.Path,
+				PathType:              "Prefix",
+				Backend:               loc.Backend,
+				SSLRedirect:           loc.Rewrite.SSLRedirect,
+				ForceSSLRedirect:      loc.Rewrite.ForceSSLRedirect,
+				PreserveTrailingSlash: loc.Rewrite.PreserveTrailingSlash,
+				UsePortInRedirects:    loc.UsePortInRedirects,
+				ServicePort:           loc.Port.String(),
+			}
+			if loc.PathType != nil {
+				dl.PathType = string(*loc.PathType)
+			}
+			if loc.Ingress != nil {
+				dl.Namespace = loc.Ingress.Namespace
+				dl.Ingress = loc.Ingress.Name
+			}
+			if loc.Service != nil {
+				dl.Service = loc.Service.Name
+			}
+			for _, path := range noTLSRedirect {
+				if strings.HasPrefix(loc.Path, path) {
+					dl.ForceNoSSLRedirect = true
+				}
+			}
+			locations = append(locations, dl)
+		}
+		dynamicServers[server.Hostname] = locations
+	}
+	return dynamicServers
+}
+
+// configureDynamicServers sends the locations of the dynamic servers to Lua
+func configureDynamicServers(servers []*ingress.Server, noTLSRedirectLocations string) error {
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/dynamic-servers", "application/json",
+		buildDynamicServers(servers, noTLSRedirectLocations))
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func dynamicServersFixture() []*ingress.Server {
+	plain := &ingress.Ingress{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}}
+	annotated := &ingress.Ingress{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "auth",
+		Annotations: map[string]string{"nginx.ingress.kubernetes.io/auth-url": "http://auth.default.svc"},
+	}}}
+	prefix := networking.PathTypePrefix
+
+	return []*ingress.Server{
+		{Hostname: "_", Locations: []*ingress.Location{{Path: "/", Backend: defUpstreamName, IsDefBackend: true}}},
+		{Hostname: "*.example.com", Locations: []*ingress.Location{{Path: "/", Ingress: plain}}},
+		{Hostname: "auth.example.com", Locations: []*ingress.Location{{Path: "/", Ingress: annotated}}},
+		{Hostname: "tls.example.com", SSLCert: &ingress.SSLCert{}, Locations: []*ingress.Location{{Path: "/", Ingress: plain}}},
+		{Hostname: "web.example.com", Locations: []*ingress.Location{
+			{
+				Path:     "/.well-known/acme-challenge",
+				PathType: &prefix,
+				Backend:  "default-acme-80",
+				Ingress:  plain,
+				Service:  &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "acme"}},
+				Port:     intstr.FromInt(80),
+				Rewrite:  rewrite.Config{SSLRedirect: true},
+			},
+			{Path: "/", Backend: defUpstreamName, IsDefBackend: true},
+		}},
+	}
+}
+
+func TestMarkDynamicServers(t *testing.T) {
+	servers := dynamicServersFixture()
+	markDynamicServers(servers, false, nil)
+
+	dynamic := []string{}
+	for _, server := range servers {
+		if server.Dynamic {
+			dynamic = append(dynamic, server.Hostname)
+		}
+	}
+	if expected := []string{"tls.example.com", "web.example.com"}; !reflect.DeepEqual(dynamic, expected) {
+		t.Errorf("expected dynamic servers %v but got %v", expected, dynamic)
+	}
+	if rendered := renderedServers(servers); len(rendered) != 3 {
+		t.Errorf("expected 3 rendered servers but got %v", len(rendered))
+	}
+
+	servers = dynamicServersFixture()
+	markDynamicServers(servers, true, nil)
+	if servers[3].Dynamic {
+		t.Errorf("expected the server with TLS to be rendered when the catch-all server rejects the handshakes")
+	}
+
+	servers = dynamicServersFixture()
+	servers[0].Locations = append(servers[0].Locations, &ingress.Location{Path: "/api"})
+	markDynamicServers(servers, false, nil)
+	for _, server := range servers {
+		if server.Dynamic {
+			t.Errorf("expected no dynamic server when the catch-all server has other locations but got %v", server.Hostname)
+		}
+	}
+
+	withAuth := func(ing *networking.Ingress) (*networking.Ingress, []string) {
+		withDefaults := *ing
+		withDefaults.Annotations = map[string]string{"nginx.ingress.kubernetes.io/auth-url": "http://auth.default.svc"}
+		return &withDefaults, nil
+	}
+	servers = dynamicServersFixture()
+	servers[0].Locations[0].Ingress = nil
+	markDynamicServers(servers, false, withAuth)
+	for _, server := range servers {
+		if server.Dynamic {
+			t.Errorf("expected no dynamic server when the namespace defaults set annotations but got %v", server.Hostname)
+		}
+	}
+}
+
+func TestBuildDynamicServers(t *testing.T) {
+	servers := dynamicServersFixture()
+	markDynamicServers(servers, false, nil)
+
+	dynamicServers := buildDynamicServers(servers, "/.well-known/acme-challenge")
+	expected := []dynamicLocation{
+		{
+			Path:               "/.well-known/acme-challenge",
+			PathType:           "Prefix",
+			Backend:            "default-acme-80",
+			Namespace:          "default",
+			Ingress:            "web",
+			Service:            "acme",
+			ServicePort:        "80",
+			SSLRedirect:        true,
+			ForceNoSSLRedirect: true,
+		},
+		{Path: "/", PathType: "Prefix", Backend: defUpstreamName, ServicePort: "0"},
+	}
+	if !reflect.DeepEqual(dynamicServers["web.example.com"], expected) {
+		t.Errorf("expected %+v but got %+v", expected, dynamicServers["web.example.com"])
+	}
+	if len(dynamicServers) != 2 {
+		t.Errorf("expected 2 dynamic servers but got %v", len(dynamicServers))
+	}
+}
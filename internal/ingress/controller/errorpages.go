@@ -0,0 +1,271 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	text_template "text/template"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// formatHTML and formatJSON are the formats of the error pages
+	formatHTML = "html"
+	formatJSON = "json"
+
+	// defaultErrorPage is the name of the templates used for the
+	// status codes without a template of their own
+	defaultErrorPage = "default"
+)
+
+// defaultErrorPageTemplates are used when the templates directory does
+// not contain a template for the format
+var defaultErrorPageTemplates = map[string]string{
+	formatHTML: `<!DOCTYPE html>
+<html>
+<head><title>{{ .Code }} {{ .Message }}</title></head>
+<body>
+<h1>{{ .Code }} {{ .Message }}</h1>
+{{- if .RequestID }}
+<p>Request ID: {{ .RequestID }}</p>
+{{- end }}
+</body>
+</html>
+`,
+	formatJSON: `{"code":{{ .Code }},"message":{{ json .Message }},"requestId":{{ json .RequestID }}}
+`,
+}
+
+// errorPageContentTypes contains the content type of each format
+var errorPageContentTypes = map[string]string{
+	formatHTML: "text/html; charset=utf-8",
+	formatJSON: "application/json",
+}
+
+// errorPageData is the data available to the error page templates
+type errorPageData struct {
+	Code        int
+	Message     string
+	RequestID   string
+	Namespace   string
+	IngressName string
+	ServiceName string
+	ServicePort string
+	OriginalURI string
+}
+
+// executor is implemented by both the HTML and the text templates
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// errorPages renders the error pages of the requests sent to the default
+// backend when no default backend Service is configured
+type errorPages struct {
+	// templates contains the templates by format and name, the name
+	// being a status code or defaultErrorPage
+	templates map[string]map[string]executor
+}
+
+// NewErrorPagesHandler returns the handler serving the error pages of the
+// custom errors, whose status code, request ID and Ingress are sent by
+// NGINX in the X-Code, X-Request-ID, X-Namespace and X-Ingress-Name headers.
+// The pages are rendered in JSON or HTML depending on the X-Format header,
+// the Accept header of the client.
+//
+// The templates are read from dir, named <status code>.html or
+// <status code>.json, default.html and default.json being used for the other
+// status codes. Built-in templates are used when dir is empty or when it
+// does not contain the default template of a format.
+func NewErrorPagesHandler(dir string) (http.Handler, error) {
+	e := &errorPages{
+		templates: map[string]map[string]executor{
+			formatHTML: {},
+			formatJSON: {},
+		},
+	}
+
+	for format, text := range defaultErrorPageTemplates {
+		t, err := parseErrorPageTemplate(format, defaultErrorPage, text)
+		if err != nil {
+			return nil, err
+		}
+		e.templates[format][defaultErrorPage] = t
+	}
+
+	if dir == "" {
+		return e, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading the error pages templates: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		format := strings.TrimPrefix(filepath.Ext(entry.Name()), ".")
+		if _, ok := e.templates[format]; !ok {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if name != defaultErrorPage {
+			if code, err := strconv.Atoi(name); err != nil || code < 400 || code > 599 {
+				klog.Warningf("Ignoring error page template %q: the name must be a status code between 400 and 599 or %q", entry.Name(), defaultErrorPage)
+				continue
+			}
+		}
+
+		text, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading the error page template %q: %w", entry.Name(), err)
+		}
+
+		t, err := parseErrorPageTemplate(format, name, string(text))
+		if err != nil {
+			return nil, err
+		}
+		e.templates[format][name] = t
+	}
+
+	return e, nil
+}
+
+func parseErrorPageTemplate(format, name, text string) (executor, error) {
+	funcs := map[string]interface{}{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+
+	var (
+		t   executor
+		err error
+	)
+	if format == formatHTML {
+		t, err = html_template.New(name).Funcs(funcs).Parse(text)
+	} else {
+		t, err = text_template.New(name).Funcs(funcs).Parse(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing the error page template %v.%v: %w", name, format, err)
+	}
+
+	return t, nil
+}
+
+// ServeHTTP renders the error page of the status code of the X-Code
+// header, 404 when the request is not a custom error
+func (e *errorPages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code := http.StatusNotFound
+	if c, err := strconv.Atoi(r.Header.Get("X-Code")); err == nil && c >= 400 && c <= 599 {
+		code = c
+	}
+
+	// the requests not matching any server name are proxied without X-Format
+	accept := r.Header.Get("X-Format")
+	if accept == "" {
+		accept = r.Header.Get("Accept")
+	}
+	format := negotiateErrorPageFormat(accept)
+
+	t, ok := e.templates[format][strconv.Itoa(code)]
+	if !ok {
+		t = e.templates[format][defaultErrorPage]
+	}
+
+	data := errorPageData{
+		Code:        code,
+		Message:     http.StatusText(code),
+		RequestID:   r.Header.Get("X-Request-ID"),
+		Namespace:   r.Header.Get("X-Namespace"),
+		IngressName: r.Header.Get("X-Ingress-Name"),
+		ServiceName: r.Header.Get("X-Service-Name"),
+		ServicePort: r.Header.Get("X-Service-Port"),
+		OriginalURI: r.Header.Get("X-Original-URI"),
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		klog.ErrorS(err, "Error rendering error page", "code", code, "format", format)
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", errorPageContentTypes[format])
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	//nolint:errcheck // the client may be gone
+	w.Write(buf.Bytes())
+}
+
+// negotiateErrorPageFormat returns the format of the error page preferred
+// by the Accept header of the client, HTML when it has no preference
+func negotiateErrorPageFormat(accept string) string {
+	format, quality := formatHTML, -1.0
+
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var f string
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			f = formatJSON
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			f = formatHTML
+		default:
+			continue
+		}
+
+		// HTML is preferred when both formats have the same quality
+		if q > quality || q == quality && f == formatHTML {
+			format, quality = f, q
+		}
+	}
+
+	if quality == 0 {
+		return formatHTML
+	}
+
+	return format
+}
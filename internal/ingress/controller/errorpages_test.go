@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNegotiateErrorPageFormat(t *testing.T) {
+	testCases := map[string]string{
+		"":                                   formatHTML,
+		"*/*":                                formatHTML,
+		"text/html":                          formatHTML,
+		"application/json":                   formatJSON,
+		"application/problem+json":           formatJSON,
+		"text/html, application/json":        formatHTML,
+		"text/html;q=0.8, application/json":  formatJSON,
+		"application/json;q=0, */*":          formatHTML,
+		"text/plain, application/json;q=0.5": formatJSON,
+		"invalid;;":                          formatHTML,
+	}
+
+	for accept, expected := range testCases {
+		if format := negotiateErrorPageFormat(accept); format != expected {
+			t.Errorf("expected format %v for Accept %q but returned %v", expected, accept, format)
+		}
+	}
+}
+
+func TestErrorPagesHandler(t *testing.T) {
+	dir := t.TempDir()
+	templates := map[string]string{
+		"503.html":     `<p>{{ .IngressName }} is down ({{ .RequestID }})</p>`,
+		"default.json": `{"error":{{ .Code }},"ingress":{{ json .IngressName }}}`,
+		"200.html":     `ignored`,
+		"README.md":    `ignored`,
+	}
+	for name, text := range templates {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatalf("unexpected error writing template: %v", err)
+		}
+	}
+
+	handler, err := NewErrorPagesHandler(dir)
+	if err != nil {
+		t.Fatalf("unexpected error creating the handler: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		headers     map[string]string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "request without custom error",
+			code:        http.StatusNotFound,
+			contentType: "text/html; charset=utf-8",
+			body:        "<!DOCTYPE html>\n<html>\n<head><title>404 Not Found</title></head>\n<body>\n<h1>404 Not Found</h1>\n</body>\n</html>\n",
+		},
+		{
+			name:        "status code template",
+			headers:     map[string]string{"X-Code": "503", "X-Ingress-Name": "<app>", "X-Request-ID": "abc"},
+			code:        http.StatusServiceUnavailable,
+			contentType: "text/html; charset=utf-8",
+			body:        "<p>&lt;app&gt; is down (abc)</p>",
+		},
+		{
+			name:        "default JSON template",
+			headers:     map[string]string{"X-Code": "503", "X-Format": "application/json", "X-Ingress-Name": `"app"`},
+			code:        http.StatusServiceUnavailable,
+			contentType: "application/json",
+			body:        `{"error":503,"ingress":"\"app\""}`,
+		},
+		{
+			name:        "invalid status code",
+			headers:     map[string]string{"X-Code": "200", "X-Format": "application/json"},
+			code:        http.StatusNotFound,
+			contentType: "application/json",
+			body:        `{"error":404,"ingress":""}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.code {
+				t.Errorf("expected status code %v but returned %v", tc.code, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("expected content type %v but returned %v", tc.contentType, ct)
+			}
+			if body := rec.Body.String(); body != tc.body {
+				t.Errorf("expected body %q but returned %q", tc.body, body)
+			}
+		})
+	}
+}
+
+func TestErrorPagesHandlerInvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "default.html"), []byte(`{{ .Code `), 0o644); err != nil {
+		t.Fatalf("unexpected error writing template: %v", err)
+	}
+
+	if _, err := NewErrorPagesHandler(dir); err == nil {
+		t.Errorf("expected an error parsing the template")
+	}
+}
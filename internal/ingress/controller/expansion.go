@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+const (
+	matchExact  = "exact"
+	matchPrefix = "prefix"
+	matchRegex  = "regex"
+)
+
+// ingressPathType returns the type of the path of an Ingress rule for the
+// host, the type of the location when the path is not found
+func ingressPathType(location *ingress.Location, hostname, path string) string {
+	if location.Ingress != nil {
+		for _, rule := range location.Ingress.Spec.Rules {
+			if rule.HTTP == nil || (rule.Host != hostname && (rule.Host != "" || hostname != defServerName)) {
+				continue
+			}
+			for _, p := range rule.HTTP.Paths {
+				if p.Path == path && p.PathType != nil {
+					return string(*p.PathType)
+				}
+			}
+		}
+	}
+
+	if location.PathType == nil {
+		return string(pathTypePrefix)
+	}
+	return string(*location.PathType)
+}
+
+// expansionLocation returns the location block of a location, following
+// buildLocation of the template: when a location of the server uses a
+// regular expression all the locations are case insensitive regular
+// expressions, otherwise exact locations use the = modifier.
+func expansionLocation(hostname string, location *ingress.Location, enforceRegex bool) ingress.ExpansionLocation {
+	path := location.IngressPath
+	if path == "" {
+		path = location.Path
+	}
+
+	expanded := ingress.ExpansionLocation{
+		Path:     path,
+		PathType: ingressPathType(location, hostname, path),
+		Location: location.Path,
+		Match:    matchPrefix,
+		Backend:  location.Backend,
+	}
+
+	switch {
+	case enforceRegex:
+		expanded.Location = fmt.Sprintf(`~* "^%s"`, location.Path)
+		expanded.Match = matchRegex
+		expanded.CaseInsensitive = true
+	case location.PathType != nil && *location.PathType == pathTypeExact:
+		expanded.Location = fmt.Sprintf(`= %s`, location.Path)
+		expanded.Match = matchExact
+	}
+
+	return expanded
+}
+
+// ingressExpansion returns the server and location blocks generated for
+// the paths of an Ingress
+func ingressExpansion(namespace, name string, servers []*ingress.Server) *ingress.Expansion {
+	expansion := &ingress.Expansion{
+		Ingress: fmt.Sprintf("%v/%v", namespace, name),
+		Servers: []ingress.ExpansionServer{},
+	}
+
+	for _, server := range servers {
+		enforceRegex := false
+		for _, location := range server.Locations {
+			if needsRewrite(location) || location.Rewrite.UseRegex {
+				enforceRegex = true
+				break
+			}
+		}
+
+		var locations []ingress.ExpansionLocation
+		for _, location := range server.Locations {
+			if location.Ingress == nil || location.Ingress.Namespace != namespace || location.Ingress.Name != name {
+				continue
+			}
+			locations = append(locations, expansionLocation(server.Hostname, location, enforceRegex))
+		}
+
+		if len(locations) > 0 {
+			expansion.Servers = append(expansion.Servers, ingress.ExpansionServer{
+				Hostname:  server.Hostname,
+				Aliases:   server.Aliases,
+				Locations: locations,
+			})
+		}
+	}
+
+	return expansion
+}
+
+// otherIngresses returns the Ingresses of the store but the one with the
+// namespace and name of ing
+func (n *NGINXController) otherIngresses(ing *networking.Ingress) []*ingress.Ingress {
+	return store.FilterIngresses(n.store.ListIngresses(), func(toCheck *ingress.Ingress) bool {
+		return toCheck.ObjectMeta.Namespace == ing.ObjectMeta.Namespace &&
+			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
+	})
+}
+
+// ExpandIngress returns the server and location blocks an Ingress expands
+// to when added to the current configuration, nil when the expansion report
+// of the validating webhook is disabled or the Ingress is not handled by
+// the controller
+func (n *NGINXController) ExpandIngress(ing *networking.Ingress) (*ingress.Expansion, error) {
+	if n.cfg == nil || !n.cfg.ValidationWebhookExpansion || !ing.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	if ingressClass, _ := n.store.GetIngressClass(ing, n.cfg.IngressClassConfiguration); ingressClass == "" {
+		return nil, nil
+	}
+
+	parsed, overridden, err := n.extractAnnotations(ing)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the annotations of ingress %v: %w", k8s.MetaNamespaceKey(ing), err)
+	}
+
+	k8s.SetDefaultNGINXPathType(ing)
+	ings := append(n.otherIngresses(ing), &ingress.Ingress{
+		Ingress:                      *ing,
+		ParsedAnnotations:            parsed,
+		OverriddenDefaultAnnotations: overridden,
+	})
+	ings, _ = n.resolveIngressConflicts(ings)
+	_, servers, _ := n.getConfiguration(ings)
+
+	return ingressExpansion(ing.Namespace, ing.Name, servers), nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestIngressExpansion(t *testing.T) {
+	prefix := networking.PathTypePrefix
+	exact := networking.PathTypeExact
+	web := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{
+					Host: "example.com",
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{{Path: "/app", PathType: &prefix}},
+						},
+					},
+				}},
+			},
+		},
+	}
+	other := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		},
+	}
+
+	servers := []*ingress.Server{
+		{
+			Hostname: "example.com",
+			Aliases:  []string{"www.example.com"},
+			Locations: []*ingress.Location{
+				{Path: "/app/", IngressPath: "/app", PathType: &prefix, Backend: "default-web-80", Ingress: web},
+				{Path: "/app", IngressPath: "/app", PathType: &exact, Backend: "default-web-80", Ingress: web},
+				{Path: "/", PathType: &prefix, Backend: "default-other-80", Ingress: other},
+			},
+		},
+		{
+			Hostname: "api.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/v1", PathType: &exact, Backend: "default-web-80", Ingress: web},
+				{Path: "/v[0-9]+", PathType: &prefix, Backend: "default-other-80", Ingress: other, Rewrite: rewrite.Config{UseRegex: true}},
+			},
+		},
+		{
+			Hostname: "other.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", PathType: &prefix, Backend: "default-other-80", Ingress: other},
+			},
+		},
+	}
+
+	expected := &ingress.Expansion{
+		Ingress: "default/web",
+		Servers: []ingress.ExpansionServer{
+			{
+				Hostname: "example.com",
+				Aliases:  []string{"www.example.com"},
+				Locations: []ingress.ExpansionLocation{
+					{Path: "/app", PathType: "Prefix", Location: "/app/", Match: matchPrefix, Backend: "default-web-80"},
+					{Path: "/app", PathType: "Prefix", Location: "= /app", Match: matchExact, Backend: "default-web-80"},
+				},
+			},
+			{
+				Hostname: "api.example.com",
+				Locations: []ingress.ExpansionLocation{
+					{Path: "/v1", PathType: "Exact", Location: `~* "^/v1"`, Match: matchRegex, CaseInsensitive: true, Backend: "default-web-80"},
+				},
+			},
+		},
+	}
+
+	if expansion := ingressExpansion("default", "web", servers); !reflect.DeepEqual(expansion, expected) {
+		t.Errorf("expected expansion %+v but got %+v", expected, expansion)
+	}
+
+	expansion := ingressExpansion("default", "missing", servers)
+	if expansion.Ingress != "default/missing" || len(expansion.Servers) != 0 {
+		t.Errorf("expected an empty expansion but got %+v", expansion)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// ReloadFreezeAPIPath is the path of the API declaring the reload freeze
+// windows of all the replicas of the controller
+const ReloadFreezeAPIPath = "/api/v1/reload/freeze"
+
+// reloadFreezeWindowsKey is the key of the reload freeze ConfigMap
+// containing the JSON list of the freeze windows
+const reloadFreezeWindowsKey = "windows"
+
+// maxFreezeDuration is the longest freeze window declared with the API
+const maxFreezeDuration = 7 * 24 * time.Hour
+
+// FreezeWindow is a time range during which the configuration changes are
+// not applied, except the endpoints of the upstreams and the certificates
+// of the servers
+type FreezeWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (w FreezeWindow) active(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// ParseFreezeWindows parses a comma separated list of freeze windows in the
+// form start/end, with times in RFC3339 format
+func ParseFreezeWindows(value string) ([]FreezeWindow, error) {
+	windows := []FreezeWindow{}
+	for _, window := range strings.Split(value, ",") {
+		window = strings.TrimSpace(window)
+		if window == "" {
+			continue
+		}
+
+		start, end, found := strings.Cut(window, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid freeze window %q: expected start/end", window)
+		}
+
+		w := FreezeWindow{}
+		var err error
+		if w.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return nil, fmt.Errorf("invalid start of freeze window %q: %w", window, err)
+		}
+		if w.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return nil, fmt.Errorf("invalid end of freeze window %q: %w", window, err)
+		}
+		if !w.End.After(w.Start) {
+			return nil, fmt.Errorf("invalid freeze window %q: the end must be after the start", window)
+		}
+
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseReloadFreezeConfigMap returns the freeze windows of the reload freeze
+// ConfigMap not ended at now
+func parseReloadFreezeConfigMap(cm *corev1.ConfigMap, now time.Time) ([]FreezeWindow, error) {
+	if cm == nil || cm.Data[reloadFreezeWindowsKey] == "" {
+		return []FreezeWindow{}, nil
+	}
+
+	var windows []FreezeWindow
+	if err := json.Unmarshal([]byte(cm.Data[reloadFreezeWindowsKey]), &windows); err != nil {
+		return nil, err
+	}
+
+	return pendingFreezeWindows(windows, now), nil
+}
+
+// pendingFreezeWindows returns the freeze windows not ended at now
+func pendingFreezeWindows(windows []FreezeWindow, now time.Time) []FreezeWindow {
+	pending := make([]FreezeWindow, 0, len(windows))
+	for _, w := range windows {
+		if now.Before(w.End) {
+			pending = append(pending, w)
+		}
+	}
+	return pending
+}
+
+// getReloadFreezeWindows returns the freeze windows of the flags and of the
+// reload freeze ConfigMap not ended yet
+func (n *NGINXController) getReloadFreezeWindows() []FreezeWindow {
+	now := time.Now()
+	windows := pendingFreezeWindows(n.cfg.ReloadFreezeWindows, now)
+
+	if n.cfg.ReloadFreezeConfigMapName == "" {
+		return windows
+	}
+
+	cm, err := n.store.GetConfigMap(n.cfg.ReloadFreezeConfigMapName)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			klog.Warningf("Error reading reload freeze ConfigMap %q: %v", n.cfg.ReloadFreezeConfigMapName, err)
+		}
+		return windows
+	}
+
+	declared, err := parseReloadFreezeConfigMap(cm, now)
+	if err != nil {
+		klog.Warningf("Error parsing reload freeze ConfigMap %q: %v", n.cfg.ReloadFreezeConfigMapName, err)
+		return windows
+	}
+
+	return append(windows, declared...)
+}
+
+// activeFreezeWindow returns the active freeze window ending last
+func activeFreezeWindow(windows []FreezeWindow, now time.Time) (FreezeWindow, bool) {
+	var active FreezeWindow
+	found := false
+	for _, w := range windows {
+		if w.active(now) && (!found || w.End.After(active.End)) {
+			active = w
+			found = true
+		}
+	}
+	return active, found
+}
+
+// scheduleReloadFreezeChange syncs the configuration again when the next
+// freeze window starts or ends, to apply the changes kept during the freeze
+func (n *NGINXController) scheduleReloadFreezeChange(windows []FreezeWindow) {
+	n.reloadFreezeChangeLock.Lock()
+	defer n.reloadFreezeChangeLock.Unlock()
+
+	if n.reloadFreezeChange != nil {
+		n.reloadFreezeChange.Stop()
+		n.reloadFreezeChange = nil
+	}
+
+	now := time.Now()
+	var next time.Time
+	for _, w := range windows {
+		change := w.End
+		if now.Before(w.Start) {
+			change = w.Start
+		}
+		if next.IsZero() || change.Before(next) {
+			next = change
+		}
+	}
+
+	if next.IsZero() {
+		return
+	}
+
+	n.reloadFreezeChange = time.AfterFunc(time.Until(next), func() {
+		n.syncQueue.EnqueueTask(task.GetDummyObject("reload-freeze-change"))
+	})
+}
+
+// frozenConfiguration returns the running configuration with the endpoints
+// of its upstreams and the certificates of its servers taken from the new
+// configuration. The other changes are kept until the freeze ends.
+func frozenConfiguration(running, pcfg *ingress.Configuration) *ingress.Configuration {
+	frozen := *running
+
+	backends := make(map[string]*ingress.Backend, len(pcfg.Backends))
+	for _, backend := range pcfg.Backends {
+		backends[backend.Name] = backend
+	}
+	frozen.Backends = make([]*ingress.Backend, 0, len(running.Backends))
+	for _, backend := range running.Backends {
+		b := *backend
+		if updated, ok := backends[b.Name]; ok {
+			b.Endpoints = updated.Endpoints
+		}
+		frozen.Backends = append(frozen.Backends, &b)
+	}
+
+	servers := make(map[string]*ingress.Server, len(pcfg.Servers))
+	for _, server := range pcfg.Servers {
+		servers[server.Hostname] = server
+	}
+	frozen.Servers = make([]*ingress.Server, 0, len(running.Servers))
+	for _, server := range running.Servers {
+		s := *server
+		// only the renewals are applied, adding or removing the
+		// certificate of a server changes its listeners
+		if updated, ok := servers[s.Hostname]; ok && s.SSLCert != nil && updated.SSLCert != nil {
+			s.SSLCert = updated.SSLCert
+		}
+		frozen.Servers = append(frozen.Servers, &s)
+	}
+
+	frozen.TCPEndpoints = frozenL4Endpoints(running.TCPEndpoints, pcfg.TCPEndpoints)
+	frozen.UDPEndpoints = frozenL4Endpoints(running.UDPEndpoints, pcfg.UDPEndpoints)
+
+	return &frozen
+}
+
+func frozenL4Endpoints(running, updated []ingress.L4Service) []ingress.L4Service {
+	endpoints := make(map[int][]ingress.Endpoint, len(updated))
+	for i := range updated {
+		endpoints[updated[i].Port] = updated[i].Endpoints
+	}
+
+	frozen := make([]ingress.L4Service, 0, len(running))
+	for i := range running {
+		service := running[i]
+		if eps, ok := endpoints[service.Port]; ok {
+			service.Endpoints = eps
+		}
+		frozen = append(frozen, service)
+	}
+	return frozen
+}
+
+// updateReloadFreezeWindows applies update to the freeze windows of the
+// reload freeze ConfigMap, creating it when it does not exist
+func (n *NGINXController) updateReloadFreezeWindows(update func([]FreezeWindow) []FreezeWindow) ([]FreezeWindow, error) {
+	var result []FreezeWindow
+	err := n.updateConfigMap(n.cfg.ReloadFreezeConfigMapName, func(cm *corev1.ConfigMap) error {
+		windows, err := parseReloadFreezeConfigMap(cm, time.Now())
+		if err != nil {
+			return err
+		}
+
+		result = update(windows)
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Start.Before(result[j].Start)
+		})
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		cm.Data[reloadFreezeWindowsKey] = string(data)
+		return nil
+	})
+
+	return result, err
+}
+
+// reloadFreezeStatus is the response of the reload freeze API
+type reloadFreezeStatus struct {
+	// Frozen is true when a freeze window is active
+	Frozen bool `json:"frozen"`
+	// Pending is true when configuration changes are kept until the end
+	// of the freeze
+	Pending bool           `json:"pending"`
+	Windows []FreezeWindow `json:"windows"`
+}
+
+func (n *NGINXController) reloadFreezeStatus() reloadFreezeStatus {
+	windows := n.getReloadFreezeWindows()
+	_, frozen := activeFreezeWindow(windows, time.Now())
+	return reloadFreezeStatus{
+		Frozen:  frozen,
+		Pending: frozen && n.reloadFreezePending.Load(),
+		Windows: windows,
+	}
+}
+
+// ReloadFreezeAPIHandler returns the handler of the API declaring the
+// reload freeze windows of all the replicas of the controller, through the
+// reload freeze ConfigMap. The handler does not authenticate the requests.
+//
+//	GET    /api/v1/reload/freeze                                          the freeze windows and if changes are pending
+//	POST   /api/v1/reload/freeze?minutes=<n>&start=<time>&reason=<text>  freezes the configuration for n minutes
+//	DELETE /api/v1/reload/freeze                                          removes the freeze windows declared with the API
+//
+// The start parameter, in RFC3339 format, is optional, the freeze starting
+// immediately without it. The freeze windows of the flags can not be removed.
+func (n *NGINXController) ReloadFreezeAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var update func([]FreezeWindow) []FreezeWindow
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, n.reloadFreezeStatus())
+			return
+		case http.MethodPost:
+			query := r.URL.Query()
+			minutes, err := strconv.Atoi(query.Get("minutes"))
+			duration := time.Duration(minutes) * time.Minute
+			if err != nil || minutes < 1 || duration > maxFreezeDuration {
+				http.Error(w, fmt.Sprintf("the minutes parameter must be a number of minutes between 1 and %v", int(maxFreezeDuration.Minutes())), http.StatusBadRequest)
+				return
+			}
+
+			start := time.Now().UTC().Truncate(time.Second)
+			if value := query.Get("start"); value != "" {
+				if start, err = time.Parse(time.RFC3339, value); err != nil {
+					http.Error(w, "the start parameter must be a time in RFC3339 format", http.StatusBadRequest)
+					return
+				}
+			}
+
+			window := FreezeWindow{Start: start, End: start.Add(duration), Reason: query.Get("reason")}
+			update = func(windows []FreezeWindow) []FreezeWindow {
+				return append(windows, window)
+			}
+		case http.MethodDelete:
+			update = func([]FreezeWindow) []FreezeWindow {
+				return []FreezeWindow{}
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		windows, err := n.updateReloadFreezeWindows(update)
+		if err != nil {
+			klog.ErrorS(err, "Error updating reload freeze windows", "configmap", n.cfg.ReloadFreezeConfigMapName)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		klog.InfoS("Reload freeze windows updated", "method", r.Method, "windows", len(windows))
+		writeJSON(w, windows)
+	})
+}
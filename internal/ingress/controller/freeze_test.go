@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/metrics"
+)
+
+func TestParseFreezeWindows(t *testing.T) {
+	start := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+
+	windows, err := ParseFreezeWindows("2024-12-20T00:00:00Z/2025-01-02T00:00:00Z, 2025-02-01T00:00:00Z/2025-02-01T06:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []FreezeWindow{
+		{Start: start, End: start.Add(13 * 24 * time.Hour)},
+		{Start: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 2, 1, 6, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(windows, expected) {
+		t.Errorf("expected %v but returned %v", expected, windows)
+	}
+
+	for _, invalid := range []string{
+		"2024-12-20T00:00:00Z",
+		"2024-12-20/2025-01-02",
+		"2025-01-02T00:00:00Z/2024-12-20T00:00:00Z",
+	} {
+		if _, err := ParseFreezeWindows(invalid); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+}
+
+func TestParseReloadFreezeConfigMap(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		reloadFreezeWindowsKey: `[{"start":"2024-01-01T11:00:00Z","end":"2024-01-01T13:00:00Z","reason":"release"},{"start":"2024-01-01T10:00:00Z","end":"2024-01-01T11:00:00Z"}]`,
+	}}
+	windows, err := parseReloadFreezeConfigMap(cm, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []FreezeWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "release"}}
+	if !reflect.DeepEqual(windows, expected) {
+		t.Errorf("expected %v but returned %v", expected, windows)
+	}
+
+	if _, found := activeFreezeWindow(windows, now.Add(2*time.Hour)); found {
+		t.Errorf("expected no active freeze window after its end")
+	}
+	if active, found := activeFreezeWindow(windows, now); !found || active.Reason != "release" {
+		t.Errorf("expected the freeze window to be active but returned %v", active)
+	}
+
+	cm.Data[reloadFreezeWindowsKey] = "invalid"
+	if _, err := parseReloadFreezeConfigMap(cm, now); err == nil {
+		t.Errorf("expected an error parsing invalid freeze windows")
+	}
+}
+
+func TestFrozenConfiguration(t *testing.T) {
+	oldCert := &ingress.SSLCert{PemSHA: "old"}
+	newCert := &ingress.SSLCert{PemSHA: "new"}
+
+	running := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{Name: "default-echo-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}}},
+			{Name: "default-removed-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.2", Port: "8080"}}},
+		},
+		Servers: []*ingress.Server{
+			{Hostname: "echo.example.com", SSLCert: oldCert, Locations: []*ingress.Location{{Path: "/"}}},
+			{Hostname: "plain.example.com"},
+		},
+		TCPEndpoints: []ingress.L4Service{
+			{Port: 5432, Endpoints: []ingress.Endpoint{{Address: "10.0.0.3", Port: "5432"}}},
+		},
+	}
+	pcfg := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{Name: "default-echo-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.4", Port: "8080"}}},
+			{Name: "default-added-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.5", Port: "8080"}}},
+		},
+		Servers: []*ingress.Server{
+			{Hostname: "echo.example.com", SSLCert: newCert, Locations: []*ingress.Location{{Path: "/"}, {Path: "/api"}}},
+			{Hostname: "plain.example.com", SSLCert: newCert},
+		},
+		TCPEndpoints: []ingress.L4Service{
+			{Port: 5432, Endpoints: []ingress.Endpoint{{Address: "10.0.0.6", Port: "5432"}}},
+		},
+	}
+
+	frozen := frozenConfiguration(running, pcfg)
+
+	if len(frozen.Backends) != 2 || frozen.Backends[1].Name != "default-removed-80" {
+		t.Errorf("expected the upstreams of the running configuration but returned %v", frozen.Backends)
+	}
+	expected := []ingress.Endpoint{{Address: "10.0.0.4", Port: "8080"}}
+	if !reflect.DeepEqual(frozen.Backends[0].Endpoints, expected) {
+		t.Errorf("expected endpoints %v but returned %v", expected, frozen.Backends[0].Endpoints)
+	}
+	if running.Backends[0].Endpoints[0].Address != "10.0.0.1" {
+		t.Errorf("expected the running configuration to be unchanged")
+	}
+
+	if frozen.Servers[0].SSLCert != newCert || len(frozen.Servers[0].Locations) != 1 {
+		t.Errorf("expected only the certificate of the server to be renewed but returned %v", frozen.Servers[0])
+	}
+	if frozen.Servers[1].SSLCert != nil {
+		t.Errorf("expected no certificate to be added to a server during the freeze")
+	}
+
+	expected = []ingress.Endpoint{{Address: "10.0.0.6", Port: "5432"}}
+	if !reflect.DeepEqual(frozen.TCPEndpoints[0].Endpoints, expected) {
+		t.Errorf("expected TCP endpoints %v but returned %v", expected, frozen.TCPEndpoints[0].Endpoints)
+	}
+}
+
+func TestReloadFreezeAPI(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	n := &NGINXController{
+		cfg: &Configuration{
+			Client:                    client,
+			ReloadFreezeConfigMapName: "ingress-nginx/reload-freeze",
+		},
+		store: &drainStore{client: client},
+	}
+
+	handler := metrics.RequireBearerToken("secret", n.ReloadFreezeAPIHandler())
+
+	testCases := []struct {
+		name           string
+		method         string
+		query          string
+		token          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"without token", http.MethodPost, "?minutes=60", "", http.StatusUnauthorized, -1},
+		{"invalid minutes", http.MethodPost, "?minutes=0", "secret", http.StatusBadRequest, -1},
+		{"too many minutes", http.MethodPost, "?minutes=20000", "secret", http.StatusBadRequest, -1},
+		{"invalid start", http.MethodPost, "?minutes=60&start=tomorrow", "secret", http.StatusBadRequest, -1},
+		{"freeze", http.MethodPost, "?minutes=60&reason=release", "secret", http.StatusOK, 1},
+		{"freeze later", http.MethodPost, "?minutes=60&start=2999-01-01T00:00:00Z", "secret", http.StatusOK, 2},
+		{"unsupported method", http.MethodPut, "", "secret", http.StatusMethodNotAllowed, -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, ReloadFreezeAPIPath+tc.query, http.NoBody)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v but got %v: %v", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.expectedCount < 0 {
+				return
+			}
+
+			var windows []FreezeWindow
+			if err := json.Unmarshal(w.Body.Bytes(), &windows); err != nil {
+				t.Fatalf("unexpected error decoding the response: %v", err)
+			}
+			if len(windows) != tc.expectedCount {
+				t.Errorf("expected %v freeze windows but got %v", tc.expectedCount, windows)
+			}
+		})
+	}
+
+	status := n.reloadFreezeStatus()
+	if !status.Frozen || status.Pending || len(status.Windows) != 2 {
+		t.Errorf("expected an active freeze without pending changes but got %+v", status)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, ReloadFreezeAPIPath, http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %v but got %v: %v", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if windows := n.getReloadFreezeWindows(); len(windows) != 0 {
+		t.Errorf("expected no freeze windows but got %v", windows)
+	}
+}
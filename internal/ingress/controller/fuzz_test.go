@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// fuzzAnnotationValues seed the fuzzing of every annotation with values
+// its parser is likely to mishandle
+var fuzzAnnotationValues = []string{
+	"",
+	"true",
+	"0",
+	"-1",
+	"99999999999999999999",
+	"1.5",
+	"10m",
+	"a,b,,c",
+	"http://",
+	"https://example.com:99999/auth?rd=$escaped_request_uri",
+	"//example.com",
+	"/$1/${2}",
+	"(",
+	"[a-z",
+	"^.*+$",
+	"default/",
+	"/name",
+	"10.0.0.0/33",
+	"\";\n}\nlocation / {",
+	"${}",
+	"\x00",
+	"☃",
+	strings.Repeat("a", 1024),
+}
+
+// fuzzResolver resolves no Secret, Service or certificate, like the store
+// of the controller when they do not exist
+type fuzzResolver struct {
+	resolver.Mock
+}
+
+func (fuzzResolver) GetSecret(name string) (*apiv1.Secret, error) {
+	return nil, fmt.Errorf("secret %v not found", name)
+}
+
+func (fuzzResolver) GetService(name string) (*apiv1.Service, error) {
+	return nil, fmt.Errorf("service %v not found", name)
+}
+
+func (fuzzResolver) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+	return nil, fmt.Errorf("secret %v not found", name)
+}
+
+// fuzzAnnotationNames returns the names of the annotations of every parser
+func fuzzAnnotationNames(ec annotations.Extractor) []string {
+	names := make([]string, 0)
+	for name := range ec.Schema() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FuzzAnnotations feeds the values of two annotations through every
+// annotation parser and the NGINX template, checking that they do not panic
+// and that the template is rendered. When the nginx binary of the
+// controller image is available, the configuration is tested with nginx -t.
+//
+// The seeds run with go test, fuzzing with:
+//
+//	go test ./internal/ingress/controller -run '^$' -fuzz FuzzAnnotations
+func FuzzAnnotations(f *testing.F) {
+	ec := annotations.NewAnnotationExtractor(fuzzResolver{resolver.Mock{AnnotationsRiskLevel: "Critical"}})
+
+	for _, name := range fuzzAnnotationNames(ec) {
+		for _, value := range fuzzAnnotationValues {
+			f.Add(name, value, "", "")
+		}
+	}
+	// annotations whose values are parsed together
+	f.Add("auth-url", "http://auth.default.svc/verify", "auth-signin", "https://$host/oauth2/start?rd=$escaped_request_uri")
+	f.Add("rewrite-target", "/$2", "use-regex", "true")
+	f.Add("canary", "true", "canary-weight", "101")
+
+	tpl, err := ngx_template.NewTemplate(fuzzTemplatePath(f))
+	if err != nil {
+		f.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	ngxCommand := NewNginxCommand()
+	_, err = os.Stat(ngxCommand.Binary)
+	testConfig := err == nil
+
+	f.Fuzz(func(t *testing.T, name, value, otherName, otherValue string) {
+		ing := &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "fuzz",
+				Namespace:   "default",
+				Annotations: map[string]string{parser.GetAnnotationWithPrefix(name): value},
+			},
+		}
+		if otherName != "" {
+			ing.Annotations[parser.GetAnnotationWithPrefix(otherName)] = otherValue
+		}
+
+		anns, err := ec.Extract(ing)
+		if err != nil {
+			// the Ingress is rejected
+			return
+		}
+
+		loc := &ingress.Location{
+			Path:     rootLocation,
+			PathType: &pathTypePrefix,
+			Backend:  "default-fuzz-80",
+			Ingress:  &ingress.Ingress{Ingress: *ing, ParsedAnnotations: anns},
+		}
+		locationApplyAnnotations(loc, anns)
+
+		cfg := ngx_config.NewDefault()
+		cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+		content, err := tpl.Write(&ngx_config.TemplateConfig{
+			Cfg:         cfg,
+			ListenPorts: &ngx_config.ListenPorts{HTTP: 80, HTTPS: 443, Health: 10254, Default: 8181, SSLProxy: 442},
+			Backends:    []*ingress.Backend{{Name: loc.Backend}},
+			Servers: []*ingress.Server{
+				{
+					Hostname:  "example.com",
+					Locations: []*ingress.Location{loc},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error rendering the NGINX template with %q=%q and %q=%q: %v", name, value, otherName, otherValue, err)
+		}
+
+		if !testConfig {
+			return
+		}
+
+		tmpfile := filepath.Join(t.TempDir(), "nginx.conf")
+		if err := os.WriteFile(tmpfile, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := ngxCommand.Test(tmpfile); err != nil {
+			t.Errorf("invalid NGINX configuration with %q=%q and %q=%q: %v\n%s", name, value, otherName, otherValue, err, out)
+		}
+	})
+}
+
+// fuzzTemplatePath returns the path of the NGINX template of the controller
+// image, or else the one of the repository
+func fuzzTemplatePath(f *testing.F) string {
+	if _, err := os.Stat(nginx.TemplatePath); err == nil {
+		return nginx.TemplatePath
+	}
+
+	path, err := filepath.Abs(filepath.Join("..", "..", "..", "rootfs", nginx.TemplatePath))
+	if err != nil {
+		f.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/util/file"
+	utilingress "k8s.io/ingress-nginx/pkg/util/ingress"
+)
+
+// ConfigurationHandoffAPIPath is the path of the API handing the running
+// configuration off to the controller replacing this one, served on the
+// unix socket ConfigurationHandoffSocket
+const ConfigurationHandoffAPIPath = "/api/v1/configuration/handoff"
+
+// configurationHandoffSocketMode are the permissions of the configuration
+// handoff socket, only the user of the controller can connect to it as the
+// configuration contains the private keys of the SSL certificates
+const configurationHandoffSocketMode os.FileMode = 0o600
+
+// configurationHandoffHandler returns the handler of the configuration
+// handoff API. Unlike the configuration API the response contains the private
+// keys of the SSL certificates, the API is only served on a unix socket
+// readable by the user of the controller.
+//
+// Only the running configuration is handed off. The state of the Lua
+// balancer of the NGINX workers, like the scores of the ewma and
+// least_request load balancing, the slow start and the connect backoff of the
+// endpoints, is not, and starts empty as with a cold start. The cookie
+// affinity is kept as the endpoint is picked from the cookie with the
+// endpoints of the backend, which are handed off.
+//
+//	GET /api/v1/configuration/handoff  the complete running configuration
+func (n *NGINXController) configurationHandoffHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		n.runningConfigLock.RLock()
+		defer n.runningConfigLock.RUnlock()
+
+		// a controller without configuration has nothing to hand off, and a
+		// configuration handed off lacks the Kubernetes objects until synced
+		if n.runningConfig == nil || n.runningConfig.Equal(&ingress.Configuration{}) || n.configurationHandedOff {
+			http.Error(w, "configuration not synced yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, n.runningConfig)
+	})
+}
+
+// listenConfigurationHandoff listens on the unix socket, with the
+// configurationHandoffSocketMode permissions. The socket replaces the one
+// of the controller being replaced atomically, once its configuration was
+// fetched.
+func listenConfigurationHandoff(socket string) (net.Listener, error) {
+	// the socket is created in a directory only accessible by the user of
+	// the controller until its permissions are set
+	dir, err := os.MkdirTemp(filepath.Dir(socket), ".handoff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "handoff.sock")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(tmp, configurationHandoffSocketMode); err == nil {
+		err = os.Rename(tmp, socket)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// serveConfigurationHandoff serves the configuration handoff API on the
+// socket until the controller stops
+func (n *NGINXController) serveConfigurationHandoff() {
+	if n.cfg.ConfigurationHandoffSocket == "" {
+		return
+	}
+
+	listener, err := listenConfigurationHandoff(n.cfg.ConfigurationHandoffSocket)
+	if err != nil {
+		klog.ErrorS(err, "Error listening on the configuration handoff socket", "socket", n.cfg.ConfigurationHandoffSocket)
+		return
+	}
+
+	server := &http.Server{
+		Handler:           n.configurationHandoffHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-n.stopCh
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		klog.ErrorS(err, "Error serving the configuration handoff API")
+	}
+}
+
+// fetchConfigurationHandoff returns the running configuration of the
+// controller serving the configuration handoff API on the unix socket
+func fetchConfigurationHandoff(socket string, timeout time.Duration) (*ingress.Configuration, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://handoff" + ConfigurationHandoffAPIPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	cfg := &ingress.Configuration{}
+	if err := json.NewDecoder(resp.Body).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("decoding the configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// prepareConfigurationHandoff fetches the configuration of the controller
+// being replaced and writes the NGINX configuration file generated from it,
+// so NGINX starts with the routes already served instead of an empty
+// configuration. It returns nil when the handoff is disabled or fails, in
+// which case the controller starts as usual.
+func (n *NGINXController) prepareConfigurationHandoff() *ingress.Configuration {
+	if n.cfg.ConfigurationHandoffSocket == "" {
+		return nil
+	}
+
+	pcfg, err := fetchConfigurationHandoff(n.cfg.ConfigurationHandoffSocket, n.cfg.ConfigurationHandoffTimeout)
+	if err != nil {
+		klog.Warningf("Starting without configuration handoff from %v: %v", n.cfg.ConfigurationHandoffSocket, err)
+		return nil
+	}
+
+	// the default certificate is a file local to every controller
+	pcfg.DefaultSSLCertificate = n.getDefaultSSLCertificate()
+
+	cfg := n.store.GetBackendConfiguration()
+	cfg.Resolver = n.resolver
+
+	content, err := n.generateTemplate(cfg, *pcfg)
+	if err == nil {
+		err = writeLuaPlugins(luaPluginsDir, pcfg.LuaPlugins)
+	}
+	if err == nil {
+		err = n.createLuaConfig(&cfg, luaPluginNames(pcfg.LuaPlugins))
+	}
+	if err == nil {
+		err = createOpentelemetryCfg(&cfg)
+	}
+	if err == nil {
+		err = n.testTemplate(content)
+	}
+	if err == nil {
+		err = os.WriteFile(cfgPath, content, file.ReadWriteByUser)
+	}
+	if err != nil {
+		klog.Warningf("Starting without configuration handoff from %v: %v", n.cfg.ConfigurationHandoffSocket, err)
+		return nil
+	}
+
+	klog.InfoS("Configuration handed off", "socket", n.cfg.ConfigurationHandoffSocket, "servers", len(pcfg.Servers), "backends", len(pcfg.Backends))
+	return pcfg
+}
+
+// applyConfigurationHandoff configures the backends and certificates of the
+// handed off configuration in the NGINX process started with it. Once applied
+// the configuration is the running one until the initial sync, which always
+// reloads NGINX: the locations handed off lack their Ingress and Service,
+// rendered in the variables of the access log and the metrics.
+func (n *NGINXController) applyConfigurationHandoff(pcfg *ingress.Configuration) {
+	if pcfg == nil {
+		return
+	}
+
+	retry := wait.Backoff{
+		Steps:    1 + n.cfg.DynamicConfigurationRetries,
+		Duration: time.Second,
+		Factor:   1.3,
+		Jitter:   0.1,
+	}
+
+	err := wait.ExponentialBackoff(retry, func() (bool, error) {
+		return n.configureDynamically(pcfg) == nil, nil
+	})
+	if err != nil {
+		klog.Warningf("Error configuring the handed off backends, NGINX is reloaded on the initial sync: %v", err)
+		return
+	}
+
+	n.runningConfigLock.Lock()
+	n.runningConfig = pcfg
+	n.configurationHandedOff = true
+	n.runningConfigLock.Unlock()
+}
+
+// reloadRequired returns true when pcfg can not be applied dynamically, or
+// when the running configuration was handed off, its locations lacking the
+// Kubernetes objects that Equal ignores
+func (n *NGINXController) reloadRequired(pcfg *ingress.Configuration) bool {
+	return n.configurationHandedOff || !utilingress.IsDynamicConfigurationEnough(pcfg, n.runningConfig)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func handedOffConfiguration() *ingress.Configuration {
+	return &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{
+				Name:      "default-echo-80",
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+			},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname: "example.com",
+				SSLCert:  &ingress.SSLCert{Name: "tls", PemSHA: "sha", PemCertKey: "private key"},
+				Locations: []*ingress.Location{
+					{
+						Path:    "/",
+						Backend: "default-echo-80",
+						Ingress: &ingress.Ingress{
+							Ingress: networking.Ingress{
+								ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+							},
+						},
+						Service: &apiv1.Service{
+							ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func serveTestConfigurationHandoff(t *testing.T, n *NGINXController) string {
+	t.Helper()
+
+	n.cfg = &Configuration{ConfigurationHandoffSocket: filepath.Join(t.TempDir(), "handoff.sock")}
+	n.stopCh = make(chan struct{})
+	t.Cleanup(func() { close(n.stopCh) })
+
+	go n.serveConfigurationHandoff()
+
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		_, err := os.Stat(n.cfg.ConfigurationHandoffSocket)
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected the configuration handoff socket to be created: %v", err)
+	}
+
+	return n.cfg.ConfigurationHandoffSocket
+}
+
+func TestConfigurationHandoffAPI(t *testing.T) {
+	n := &NGINXController{runningConfig: &ingress.Configuration{}}
+	socket := serveTestConfigurationHandoff(t, n)
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != configurationHandoffSocketMode {
+		t.Errorf("expected the socket permissions %v but got %v", configurationHandoffSocketMode, perm)
+	}
+
+	if _, err := fetchConfigurationHandoff(socket, time.Second); err == nil {
+		t.Errorf("expected an error fetching a configuration not synced yet")
+	}
+
+	n.runningConfigLock.Lock()
+	n.runningConfig = handedOffConfiguration()
+	n.runningConfig.Servers[0].Locations[0].Service = nil
+	n.runningConfigLock.Unlock()
+
+	cfg, err := fetchConfigurationHandoff(socket, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error fetching the configuration: %v", err)
+	}
+	if !cfg.Equal(n.runningConfig) {
+		t.Errorf("expected the running configuration but got %+v", cfg)
+	}
+	if cfg.Servers[0].SSLCert.PemCertKey != "private key" {
+		t.Errorf("expected the private key to be handed off")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, ConfigurationHandoffAPIPath, http.NoBody)
+	w := httptest.NewRecorder()
+	n.configurationHandoffHandler().ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %v but got %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestConfigurationHandoffLabels(t *testing.T) {
+	n := &NGINXController{runningConfig: handedOffConfiguration()}
+	socket := serveTestConfigurationHandoff(t, n)
+
+	cfg, err := fetchConfigurationHandoff(socket, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error fetching the configuration: %v", err)
+	}
+
+	// the labels of the metrics and the access log are not handed off
+	loc := cfg.Servers[0].Locations[0]
+	if loc.Service != nil || (loc.Ingress != nil && loc.Ingress.Name != "") {
+		t.Fatalf("expected the Ingress and Service of the location not to be handed off")
+	}
+
+	n.runningConfig = cfg
+	n.configurationHandedOff = true
+
+	synced := handedOffConfiguration()
+	if !n.reloadRequired(synced) {
+		t.Errorf("expected the initial sync after a handoff to reload NGINX")
+	}
+
+	w := httptest.NewRecorder()
+	n.configurationHandoffHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConfigurationHandoffAPIPath, http.NoBody))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a handed off configuration not to be handed off again, got status %v", w.Code)
+	}
+
+	n.runningConfig = synced
+	n.configurationHandedOff = false
+
+	if n.reloadRequired(handedOffConfiguration()) {
+		t.Errorf("expected no reload once the handed off configuration was synced")
+	}
+
+	cfg, err = fetchConfigurationHandoff(socket, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error fetching the synced configuration: %v", err)
+	}
+	if n.runningConfig.Servers[0].Locations[0].Ingress.Name != "echo" || cfg.Servers[0].Hostname != "example.com" {
+		t.Errorf("expected the synced configuration to keep the Ingress of its locations")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// InterpretIngresses returns how the controller interprets the Ingresses,
+// whose Services, Secrets and configuration are the ones of the store:
+// their class, the values of their annotations, the reasons the controller
+// ignores them or their paths and the server and location blocks they
+// expand to. It needs neither NGINX nor the API server, the store being
+// the one of store.NewOffline to interpret Ingresses read from files.
+func InterpretIngresses(s store.Storer, ings []*networking.Ingress, icConfig *ingressclass.Configuration) []*ingress.Interpretation {
+	n := &NGINXController{
+		store: s,
+		cfg: &Configuration{
+			IngressClassConfiguration: icConfig,
+			FakeCertificate:           &ingress.SSLCert{},
+			ListenPorts: &ngx_config.ListenPorts{
+				HTTP:  80,
+				HTTPS: 443,
+			},
+		},
+		metricCollector: metric.DummyCollector{},
+	}
+
+	handled, classConflicts := n.isolateIngressClasses(s.ListIngresses())
+	handled, conflicts := n.resolveIngressConflicts(handled)
+	conflicts = append(classConflicts, conflicts...)
+	_, servers, _ := n.getConfiguration(handled)
+
+	interpretations := make([]*ingress.Interpretation, 0, len(ings))
+	for _, ing := range ings {
+		interpretation, handled := interpretIngress(s, ing, icConfig)
+		if handled {
+			interpretServers(interpretation, ing, servers, conflicts)
+		}
+		interpretations = append(interpretations, interpretation)
+	}
+
+	return interpretations
+}
+
+// interpretIngress returns the class and the values of the annotations of
+// the Ingress, and whether the controller handles it
+func interpretIngress(s store.Storer, ing *networking.Ingress, icConfig *ingressclass.Configuration) (*ingress.Interpretation, bool) {
+	interpretation := &ingress.Interpretation{
+		Ingress: k8s.MetaNamespaceKey(ing),
+	}
+
+	class, err := s.GetIngressClass(ing, icConfig)
+	if class == "" {
+		interpretation.Warnings = append(interpretation.Warnings, fmt.Sprintf("the Ingress is ignored: %v", err))
+		return interpretation, false
+	}
+	interpretation.Class = class
+
+	extractor := annotations.NewAnnotationExtractor(s)
+	if err := extractor.ValidateStrict(ing); err != nil {
+		interpretation.Warnings = append(interpretation.Warnings, err.Error())
+	}
+
+	values, err := extractor.Interpret(ing)
+	if err != nil {
+		interpretation.Errors = append(interpretation.Errors, fmt.Sprintf("the Ingress is rejected: %v", err))
+		return interpretation, false
+	}
+	if denied, ok := values[annotations.DeniedKeyName].(*string); ok {
+		interpretation.Errors = append(interpretation.Errors, fmt.Sprintf("the locations of the Ingress are denied: %v", *denied))
+		delete(values, annotations.DeniedKeyName)
+	}
+	if len(values) > 0 {
+		interpretation.Annotations = values
+	}
+
+	for _, svc := range ingressServices(ing) {
+		if _, err := s.GetService(svc); err != nil {
+			interpretation.Warnings = append(interpretation.Warnings,
+				fmt.Sprintf("Service %v not found, its paths are answered with 503", svc))
+		}
+	}
+
+	return interpretation, true
+}
+
+// interpretServers sets the server and location blocks the Ingress expands
+// to, and the paths it does not serve
+func interpretServers(interpretation *ingress.Interpretation, ing *networking.Ingress, servers []*ingress.Server, conflicts []ingress.IngressConflict) {
+	key := interpretation.Ingress
+	interpretation.Servers = ingressExpansion(ing.Namespace, ing.Name, servers).Servers
+
+	for _, conflict := range conflicts {
+		if conflict.Ingress != key {
+			continue
+		}
+		if conflict.Winner == "" {
+			interpretation.Errors = append(interpretation.Errors,
+				fmt.Sprintf("host %q and path %q are not served, other Ingresses define them", conflict.Host, conflict.Path))
+			continue
+		}
+		interpretation.Errors = append(interpretation.Errors,
+			fmt.Sprintf("host %q and path %q are served by Ingress %v", conflict.Host, conflict.Path, conflict.Winner))
+	}
+
+	for _, server := range servers {
+		if server.SSLCertFallback != nil && server.SSLCertFallback.Ingress == key {
+			interpretation.Warnings = append(interpretation.Warnings,
+				fmt.Sprintf("host %q uses the default certificate: %v", server.Hostname, server.SSLCertFallback.Reason))
+		}
+
+		for _, location := range server.Locations {
+			if location.Denied == nil || location.Ingress == nil || k8s.MetaNamespaceKey(location.Ingress) != key ||
+				slices.ContainsFunc(interpretation.Errors, func(e string) bool { return strings.HasSuffix(e, *location.Denied) }) {
+				continue
+			}
+			interpretation.Errors = append(interpretation.Errors,
+				fmt.Sprintf("location %v of host %q is denied: %v", location.Path, server.Hostname, *location.Denied))
+		}
+	}
+}
+
+// ingressServices returns the namespace/name of the Services of the backends
+// of the Ingress
+func ingressServices(ing *networking.Ingress) []string {
+	var svcs []string
+	seen := make(map[string]bool)
+	add := func(backend *networking.IngressBackend) {
+		if backend == nil || backend.Service == nil {
+			return
+		}
+		svc := fmt.Sprintf("%v/%v", ing.Namespace, backend.Service.Name)
+		if !seen[svc] {
+			seen[svc] = true
+			svcs = append(svcs, svc)
+		}
+	}
+
+	add(ing.Spec.DefaultBackend)
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			add(&rule.HTTP.Paths[i].Backend)
+		}
+	}
+	return svcs
+}
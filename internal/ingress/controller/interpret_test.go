@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func interpretedIngress(name string, created int64, class, host, path, svc string, annotations map[string]string) *networking.Ingress {
+	prefix := networking.PathTypePrefix
+	return &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.Unix(created, 0),
+			Annotations:       annotations,
+		},
+		Spec: networking.IngressSpec{
+			IngressClassName: &class,
+			Rules: []networking.IngressRule{{
+				Host: host,
+				IngressRuleValue: networking.IngressRuleValue{
+					HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{
+							Path:     path,
+							PathType: &prefix,
+							Backend: networking.IngressBackend{
+								Service: &networking.IngressServiceBackend{
+									Name: svc,
+									Port: networking.ServiceBackendPort{Number: 80},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func TestInterpretIngresses(t *testing.T) {
+	ings := []*networking.Ingress{
+		interpretedIngress("web", 1, "nginx", "example.com", "/", "web", map[string]string{
+			parser.GetAnnotationWithPrefix("ssl-redirect"):    "false",
+			parser.GetAnnotationWithPrefix("proxy-body-sise"): "8m",
+		}),
+		interpretedIngress("copy", 2, "nginx", "example.com", "/", "web", nil),
+		interpretedIngress("api", 3, "nginx", "api.example.com", "/v[0-9]+", "api", map[string]string{
+			parser.GetAnnotationWithPrefix("use-regex"): "true",
+		}),
+		interpretedIngress("other", 4, "other", "other.example.com", "/", "web", nil),
+		interpretedIngress("invalid", 5, "nginx", "invalid.example.com", "/", "web", map[string]string{
+			parser.GetAnnotationWithPrefix("ssl-redirect"): "maybe",
+		}),
+	}
+	objects := []k8sruntime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+	}
+	for _, ing := range ings {
+		objects = append(objects, ing)
+	}
+
+	icConfig := &ingressclass.Configuration{
+		Controller:      ingressclass.DefaultControllerName,
+		AnnotationValue: ingressclass.DefaultAnnotationValue,
+	}
+	interpretations := InterpretIngresses(store.NewOffline(objects, "", icConfig), ings, icConfig)
+
+	if len(interpretations) != len(ings) {
+		t.Fatalf("expected %v interpretations but returned %v", len(ings), len(interpretations))
+	}
+	byName := make(map[string]*ingress.Interpretation)
+	for _, interpretation := range interpretations {
+		byName[interpretation.Ingress] = interpretation
+	}
+
+	web := byName["default/web"]
+	if web.Class != "nginx" || len(web.Errors) != 0 {
+		t.Errorf("expected default/web handled without error but returned %+v", web)
+	}
+	if _, ok := web.Annotations["Rewrite"]; !ok || len(web.Annotations) != 1 {
+		t.Errorf("expected the value of the Rewrite parser but returned %v", web.Annotations)
+	}
+	if len(web.Warnings) != 1 || !strings.Contains(web.Warnings[0], "proxy-body-sise") {
+		t.Errorf("expected a warning about the unknown annotation but returned %v", web.Warnings)
+	}
+	if len(web.Servers) != 1 || web.Servers[0].Hostname != "example.com" || web.Servers[0].Locations[0].Backend != "default-web-80" {
+		t.Errorf("unexpected servers of default/web: %+v", web.Servers)
+	}
+
+	copied := byName["default/copy"]
+	if len(copied.Errors) != 1 || !strings.Contains(copied.Errors[0], "served by Ingress default/web") || len(copied.Servers) != 0 {
+		t.Errorf("expected default/copy not served but returned %+v", copied)
+	}
+
+	api := byName["default/api"]
+	if len(api.Warnings) != 1 || !strings.Contains(api.Warnings[0], "default/api not found") {
+		t.Errorf("expected a warning about the missing Service but returned %v", api.Warnings)
+	}
+	if len(api.Servers) != 1 || api.Servers[0].Locations[0].Match != matchRegex {
+		t.Errorf("expected a regular expression location but returned %+v", api.Servers)
+	}
+
+	other := byName["default/other"]
+	if other.Class != "" || len(other.Errors) != 0 || len(other.Warnings) != 1 || len(other.Servers) != 0 {
+		t.Errorf("expected default/other ignored but returned %+v", other)
+	}
+
+	invalid := byName["default/invalid"]
+	if len(invalid.Warnings) != 1 || !strings.Contains(invalid.Warnings[0], "ssl-redirect") || len(invalid.Servers) != 1 {
+		t.Errorf("expected default/invalid served with a warning but returned %+v", invalid)
+	}
+}
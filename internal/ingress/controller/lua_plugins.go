@@ -0,0 +1,165 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/luaplugins"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/util/file"
+)
+
+// luaPluginsDir is the directory of the modules of the Lua plugins, required
+// by NGINX as "plugins.<name>"
+const luaPluginsDir = "/etc/nginx/lua/plugins"
+
+var luaPluginChecksumRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// parseLuaPluginChecksums parses the comma separated "<name>:<checksum>" of
+// the lua-plugins option, the checksum being the SHA-256 of the code in
+// hexadecimal. The plugins are returned in order.
+func parseLuaPluginChecksums(value string) ([]ingress.LuaPlugin, error) {
+	var plugins []ingress.LuaPlugin
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, checksum, found := strings.Cut(entry, ":")
+		checksum = strings.ToLower(strings.TrimSpace(checksum))
+		name = strings.TrimSpace(name)
+		if !found || !luaplugins.NameRegex.MatchString(name) || !luaPluginChecksumRegex.MatchString(checksum) {
+			return nil, fmt.Errorf("invalid Lua plugin %q, expected <name>:<sha256 checksum>", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicated Lua plugin %q", name)
+		}
+		seen[name] = true
+
+		plugins = append(plugins, ingress.LuaPlugin{Name: name, Checksum: checksum})
+	}
+	return plugins, nil
+}
+
+// verifyLuaPlugins returns the plugins whose code, in the "<name>.lua" key of
+// the ConfigMap, matches their checksum. The other plugins are not loaded.
+func verifyLuaPlugins(plugins []ingress.LuaPlugin, cm *corev1.ConfigMap) []ingress.LuaPlugin {
+	var verified []ingress.LuaPlugin
+	for _, plugin := range plugins {
+		code, ok := cm.Data[plugin.Name+".lua"]
+		if !ok {
+			klog.Warningf("Lua plugin %q not loaded: the ConfigMap %v/%v has no key %v.lua", plugin.Name, cm.Namespace, cm.Name, plugin.Name)
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(code))
+		if checksum := hex.EncodeToString(sum[:]); checksum != plugin.Checksum {
+			klog.Warningf("Lua plugin %q not loaded: the checksum of its code is %v, expected %v", plugin.Name, checksum, plugin.Checksum)
+			continue
+		}
+
+		plugin.Code = code
+		verified = append(verified, plugin)
+	}
+	return verified
+}
+
+// getLuaPlugins returns the Lua plugins of the lua-plugins option whose code
+// in the Lua plugins ConfigMap matches their checksum
+func (n *NGINXController) getLuaPlugins() []ingress.LuaPlugin {
+	value := n.store.GetBackendConfiguration().LuaPlugins
+	if value == "" {
+		return nil
+	}
+
+	plugins, err := parseLuaPluginChecksums(value)
+	if err != nil {
+		klog.Warningf("Ignoring the Lua plugins: %v", err)
+		return nil
+	}
+
+	if n.cfg.LuaPluginsConfigMapName == "" {
+		klog.Warning("Ignoring the Lua plugins, the lua-plugins option requires the --lua-plugins-configmap flag")
+		return nil
+	}
+
+	cm, err := n.store.GetConfigMap(n.cfg.LuaPluginsConfigMapName)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			klog.Warningf("Error reading Lua plugins ConfigMap %q: %v", n.cfg.LuaPluginsConfigMapName, err)
+		} else {
+			klog.Warningf("Ignoring the Lua plugins, the ConfigMap %q does not exist", n.cfg.LuaPluginsConfigMapName)
+		}
+		return nil
+	}
+
+	return verifyLuaPlugins(plugins, cm)
+}
+
+// writeLuaPlugins writes the modules of the plugins to dir, removing the
+// modules of the plugins no longer loaded
+func writeLuaPlugins(dir string, plugins []ingress.LuaPlugin) error {
+	if err := os.MkdirAll(dir, file.ReadWriteByUser); err != nil {
+		return err
+	}
+
+	modules := map[string]bool{}
+	for _, plugin := range plugins {
+		module := plugin.Name + ".lua"
+		modules[module] = true
+		if err := os.WriteFile(filepath.Join(dir, module), []byte(plugin.Code), file.ReadWriteByUser); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lua") || modules[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// luaPluginNames returns the names of the plugins, in order
+func luaPluginNames(plugins []ingress.LuaPlugin) []string {
+	names := make([]string, 0, len(plugins))
+	for _, plugin := range plugins {
+		names = append(names, plugin.Name)
+	}
+	return names
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func luaPluginChecksum(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseLuaPluginChecksums(t *testing.T) {
+	checksum := luaPluginChecksum("return {}")
+
+	plugins, err := parseLuaPluginChecksums(" audit:" + checksum + ", geo_headers:" + checksum + ",")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ingress.LuaPlugin{
+		{Name: "audit", Checksum: checksum},
+		{Name: "geo_headers", Checksum: checksum},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v but got %+v", expected, plugins)
+	}
+
+	for _, value := range []string{
+		"audit",
+		"audit:1234",
+		"../audit:" + checksum,
+		"Audit:" + checksum,
+		"audit:" + checksum + ",audit:" + checksum,
+	} {
+		if _, err := parseLuaPluginChecksums(value); err == nil {
+			t.Errorf("expected an error parsing %q", value)
+		}
+	}
+}
+
+func TestVerifyLuaPlugins(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			"audit.lua":       "return { log = function() end }",
+			"geo_headers.lua": "return { rewrite = function() end }",
+		},
+	}
+
+	plugins := verifyLuaPlugins([]ingress.LuaPlugin{
+		{Name: "audit", Checksum: luaPluginChecksum("return { log = function() end }")},
+		{Name: "geo_headers", Checksum: luaPluginChecksum("return {}")},
+		{Name: "missing", Checksum: luaPluginChecksum("return {}")},
+	}, cm)
+
+	expected := []ingress.LuaPlugin{
+		{Name: "audit", Checksum: luaPluginChecksum("return { log = function() end }"), Code: "return { log = function() end }"},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected only the plugins matching their checksum %+v but got %+v", expected, plugins)
+	}
+}
+
+func TestWriteLuaPlugins(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plugins")
+
+	err := writeLuaPlugins(dir, []ingress.LuaPlugin{
+		{Name: "audit", Code: "return {}"},
+		{Name: "geo_headers", Code: "return {}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = writeLuaPlugins(dir, []ingress.LuaPlugin{{Name: "audit", Code: "return { log = function() end }"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code, err := os.ReadFile(filepath.Join(dir, "audit.lua"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(code) != "return { log = function() end }" {
+		t.Errorf("expected the updated code of the plugin but got %q", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "geo_headers.lua")); !os.IsNotExist(err) {
+		t.Errorf("expected the module of the plugin no longer loaded to be removed")
+	}
+}
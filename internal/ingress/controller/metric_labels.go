@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// metricLabelsClassPrefix is the prefix of the keys of the metric labels
+// ConfigMap applying to the Ingresses of an IngressClass. Namespace names
+// can not contain dots, so it can not clash with a namespace.
+const metricLabelsClassPrefix = "ingressclass."
+
+// metricLabelsRules contains the labels of the request metrics selected for
+// the Ingresses of namespaces and IngressClasses
+type metricLabelsRules struct {
+	namespaces map[string]collectors.MetricLabels
+	classes    map[string]collectors.MetricLabels
+}
+
+// parseMetricLabelsRules reads the labels of the request metrics from a
+// ConfigMap. Each key is either a namespace or "ingressclass.<name>" and its
+// value a comma separated list of labels, like "status-class,method".
+func parseMetricLabelsRules(cm *corev1.ConfigMap) metricLabelsRules {
+	rules := metricLabelsRules{
+		namespaces: map[string]collectors.MetricLabels{},
+		classes:    map[string]collectors.MetricLabels{},
+	}
+	if cm == nil {
+		return rules
+	}
+
+	for key, value := range cm.Data {
+		labels, err := collectors.ParseMetricLabels(value)
+		if err != nil {
+			klog.Warningf("ignoring the metric labels of %q: %v", key, err)
+			continue
+		}
+
+		if class, ok := strings.CutPrefix(key, metricLabelsClassPrefix); ok {
+			rules.classes[class] = labels
+			continue
+		}
+		rules.namespaces[key] = labels
+	}
+
+	return rules
+}
+
+// ingressMetricLabels returns the labels of the request metrics of the
+// Ingresses by namespace/name. The labels of the namespace of an Ingress take
+// precedence over the labels of its IngressClass.
+func ingressMetricLabels(rules metricLabelsRules, ings []*ingress.Ingress, classOf func(*ingress.Ingress) string) map[string]collectors.MetricLabels {
+	labels := map[string]collectors.MetricLabels{}
+	if len(rules.namespaces) == 0 && len(rules.classes) == 0 {
+		return labels
+	}
+
+	for _, ing := range ings {
+		if l, ok := rules.namespaces[ing.Namespace]; ok {
+			labels[k8s.MetaNamespaceKey(&ing.Ingress)] = l
+			continue
+		}
+
+		if len(rules.classes) == 0 {
+			continue
+		}
+		if l, ok := rules.classes[classOf(ing)]; ok {
+			labels[k8s.MetaNamespaceKey(&ing.Ingress)] = l
+		}
+	}
+
+	return labels
+}
+
+// getMetricLabels returns the labels of the request metrics of the Ingresses
+// defined by the metric labels ConfigMap
+func (n *NGINXController) getMetricLabels(ings []*ingress.Ingress) map[string]collectors.MetricLabels {
+	if n.cfg.MetricsLabelsConfigMapName == "" {
+		return map[string]collectors.MetricLabels{}
+	}
+
+	cm, err := n.store.GetConfigMap(n.cfg.MetricsLabelsConfigMapName)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			klog.Warningf("Error reading metric labels ConfigMap %q: %v", n.cfg.MetricsLabelsConfigMapName, err)
+		}
+		return map[string]collectors.MetricLabels{}
+	}
+
+	return ingressMetricLabels(parseMetricLabelsRules(cm), ings, func(ing *ingress.Ingress) string {
+		class, err := n.store.GetIngressClass(&ing.Ingress, n.cfg.IngressClassConfiguration)
+		if err != nil {
+			return ""
+		}
+		return class
+	})
+}
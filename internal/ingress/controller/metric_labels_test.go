@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestParseMetricLabelsRules(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			"team-a":                "status-class",
+			"team-b":                "host,path,method,status",
+			"ingressclass.internal": "method,status",
+			"team-c":                "uri",
+		},
+	}
+
+	expected := metricLabelsRules{
+		namespaces: map[string]collectors.MetricLabels{
+			"team-a": {Status: collectors.StatusClass},
+			"team-b": {Host: true, Path: true, Method: true, Status: collectors.StatusCode},
+		},
+		classes: map[string]collectors.MetricLabels{
+			"internal": {Method: true, Status: collectors.StatusCode},
+		},
+	}
+
+	rules := parseMetricLabelsRules(cm)
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %+v but got %+v", expected, rules)
+	}
+
+	rules = parseMetricLabelsRules(nil)
+	if len(rules.namespaces) != 0 || len(rules.classes) != 0 {
+		t.Errorf("expected no rules without a ConfigMap but got %+v", rules)
+	}
+}
+
+func TestIngressMetricLabels(t *testing.T) {
+	newIngress := func(namespace, name string) *ingress.Ingress {
+		return &ingress.Ingress{
+			Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}},
+		}
+	}
+	ings := []*ingress.Ingress{
+		newIngress("team-a", "web"),
+		newIngress("team-b", "web"),
+		newIngress("team-b", "admin"),
+		newIngress("team-c", "web"),
+	}
+	classes := map[string]string{
+		"team-a/web":   "internal",
+		"team-b/web":   "internal",
+		"team-b/admin": "external",
+		"team-c/web":   "external",
+	}
+	classOf := func(ing *ingress.Ingress) string {
+		return classes[ing.Namespace+"/"+ing.Name]
+	}
+
+	rules := metricLabelsRules{
+		namespaces: map[string]collectors.MetricLabels{
+			"team-a": {Status: collectors.StatusClass},
+		},
+		classes: map[string]collectors.MetricLabels{
+			"internal": {Method: true, Status: collectors.StatusCode},
+		},
+	}
+
+	expected := map[string]collectors.MetricLabels{
+		"team-a/web": {Status: collectors.StatusClass},
+		"team-b/web": {Method: true, Status: collectors.StatusCode},
+	}
+	if labels := ingressMetricLabels(rules, ings, classOf); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected %+v but got %+v", expected, labels)
+	}
+
+	if labels := ingressMetricLabels(metricLabelsRules{}, ings, classOf); len(labels) != 0 {
+		t.Errorf("expected no labels without rules but got %+v", labels)
+	}
+}
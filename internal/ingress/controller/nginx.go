@@ -19,6 +19,7 @@ package controller
 import (
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,6 +33,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
@@ -41,6 +43,7 @@ import (
 	"github.com/eapache/channels"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/record"
@@ -76,9 +79,13 @@ const (
 func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXController {
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
-	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{
-		Interface: config.Client.CoreV1().Events(config.Namespace),
-	})
+	// in shadow mode the events are only logged, the controller serving
+	// the traffic creates them
+	if !config.ShadowMode {
+		eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{
+			Interface: config.Client.CoreV1().Events(config.Namespace),
+		})
+	}
 
 	h, err := dns.GetSystemNameServers()
 	if err != nil {
@@ -133,17 +140,39 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 		config.ConfigMapName,
 		config.TCPConfigMapName,
 		config.UDPConfigMapName,
+		config.DefaultAnnotationsConfigMapName,
+		config.DrainedEndpointsConfigMapName,
+		config.MetricsLabelsConfigMapName,
+		config.ReloadFreezeConfigMapName,
+		config.ChangeApprovalConfigMapName,
+		config.LuaPluginsConfigMapName,
 		config.DefaultSSLCertificate,
 		config.ResyncPeriod,
 		config.Client,
+		config.DynamicClient,
 		n.updateCh,
 		config.DisableCatchAll,
 		config.DeepInspector,
 		config.IngressClassConfiguration,
-		config.DisableSyncEvents)
+		config.DisableSyncEvents,
+		config.EnableCertificateDiscovery)
 
 	n.syncQueue = task.NewTaskQueue(n.syncIngress)
 
+	n.crlRefresher = newCRLRefresher(file.DefaultSSLDirectory, mc, func() {
+		n.syncQueue.EnqueueTask(task.GetDummyObject("crl-change"))
+	})
+
+	n.ocspProber = newOCSPProber(mc, func() {
+		n.syncQueue.EnqueueTask(task.GetDummyObject("ocsp-change"))
+	})
+
+	if config.SpiffeWorkloadAPISocket != "" {
+		n.svidSource = newSVIDSource(config.SpiffeWorkloadAPISocket, file.DefaultSSLDirectory, func() {
+			n.syncQueue.EnqueueTask(task.GetDummyObject("svid-change"))
+		})
+	}
+
 	if config.UpdateStatus {
 		n.syncStatus = status.NewStatusSyncer(status.Config{
 			Client:                 config.Client,
@@ -152,6 +181,13 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 			IngressLister:          n.store,
 			UpdateStatusOnShutdown: config.UpdateStatusOnShutdown,
 			UseNodeInternalIP:      config.UseNodeInternalIP,
+			IngressClassPublish: func(class string) (publishService, publishStatusAddress string) {
+				if cfg := n.store.GetIngressClassConfig(class); cfg != nil {
+					return cfg.PublishService, cfg.PublishStatusAddress
+				}
+				return "", ""
+			},
+			UpdateRouteStatus: n.syncStreamRouteStatus,
 		})
 	} else {
 		klog.Warning("Update of Ingress status is disabled (flag --update-status)")
@@ -177,6 +213,17 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 
 	n.t = ngxTpl
 
+	if config.CertificateDiscoveryCAFile != "" {
+		ca, err := os.ReadFile(config.CertificateDiscoveryCAFile)
+		if err != nil {
+			klog.Fatalf("Error reading the certificate discovery CA file: %v", err)
+		}
+		n.certificateDiscoveryRoots = x509.NewCertPool()
+		if !n.certificateDiscoveryRoots.AppendCertsFromPEM(ca) {
+			klog.Fatalf("No CA certificate found in %v", config.CertificateDiscoveryCAFile)
+		}
+	}
+
 	_, err = file.NewFileWatcher(nginx.TemplatePath, onTemplateChange)
 	if err != nil {
 		klog.Fatalf("Error creating file watcher for %v: %v", nginx.TemplatePath, err)
@@ -246,6 +293,15 @@ type NGINXController struct {
 
 	// runningConfig contains the running configuration in the Backend
 	runningConfig *ingress.Configuration
+	// runningConfigLock protects runningConfig from the configuration API
+	runningConfigLock sync.RWMutex
+	// configurationHandedOff indicates runningConfig was handed off by
+	// another controller, without the Kubernetes objects it references
+	configurationHandedOff bool
+
+	// certificateDiscoveryRoots verify the chain of the discovered
+	// certificates, the system CA certificates when nil
+	certificateDiscoveryRoots *x509.CertPool
 
 	t ngx_template.Writer
 
@@ -261,6 +317,65 @@ type NGINXController struct {
 
 	metricCollector metric.Collector
 
+	// crlRefresher keeps up to date the CRLs configured with auth-tls-crl-url
+	crlRefresher *crlRefresher
+
+	// ocspProber checks the responders configured with auth-tls-ocsp-responder
+	ocspProber *ocspProber
+
+	// svidSource keeps up to date the SVIDs used with proxy-ssl-spiffe-id
+	svidSource *svidSource
+
+	// drainExpiry syncs the configuration when the first drained endpoint expires
+	drainExpiry     *time.Timer
+	drainExpiryLock sync.Mutex
+
+	// scheduleChange syncs the configuration when the first scheduled
+	// Ingress is applied or expires
+	scheduleChange     *time.Timer
+	scheduleChangeLock sync.Mutex
+
+	// reloadFreezeChange syncs the configuration when the next reload
+	// freeze window starts or ends
+	reloadFreezeChange     *time.Timer
+	reloadFreezeChangeLock sync.Mutex
+
+	// routeRegressionSince is the time the route regression check started
+	// holding the reload of the new configuration, zero when it does not
+	routeRegressionSince time.Time
+
+	// reloadFreezePending is true when configuration changes are kept until
+	// the end of the active reload freeze window
+	reloadFreezePending atomic.Bool
+
+	// appliedIngresses contains the Ingresses of the configuration applied,
+	// by namespace and name, nil until the first configuration is applied
+	appliedIngresses map[string]*ingress.Ingress
+	// stagedChanges contains the high-impact changes not applied until
+	// they are approved, by Ingress
+	stagedChanges map[string]*StagedChange
+	// approvedChanges contains the staged changes approved with the change
+	// approval API, by Ingress
+	approvedChanges map[string]approvedChange
+	// changeAutoApply syncs the configuration when the change approval
+	// timeout of the first staged change expires
+	changeAutoApply    *time.Timer
+	changeApprovalLock sync.Mutex
+
+	// sslCertFallbacks contains the hosts using the default certificate after
+	// the last sync, to record an Event only when a host starts using it
+	sslCertFallbacks map[string]ingress.SSLCertFallback
+
+	// ingressConflicts contains the hosts and paths not served by an Ingress
+	// after the last sync, to record an Event only for the new conflicts
+	ingressConflicts sets.Set[ingress.IngressConflict]
+
+	// configSnapshots contains the Ingresses of the last configurations
+	// applied successfully, to roll back the Ingresses NGINX rejects
+	configSnapshots []configSnapshot
+	// ingressRollbacks contains the Ingresses rejected by NGINX by namespace and name
+	ingressRollbacks map[string]ingressRollback
+
 	validationWebhookServer *http.Server
 
 	command NginxExecTester
@@ -314,10 +429,26 @@ func (n *NGINXController) Start() {
 		n.setupSSLProxy()
 	}
 
+	handoff := n.prepareConfigurationHandoff()
+
 	klog.InfoS("Starting NGINX process")
 	n.start(cmd)
 
+	n.applyConfigurationHandoff(handoff)
+	go n.serveConfigurationHandoff()
+
 	go n.syncQueue.Run(time.Second, n.stopCh)
+	go n.crlRefresher.Run(n.stopCh)
+	go n.ocspProber.Run(n.stopCh)
+	if n.svidSource != nil {
+		go n.svidSource.Run(n.stopCh)
+	}
+	if n.cfg.SyntheticProbeInterval > 0 {
+		go n.runSyntheticProbes()
+	}
+	if nginx.MaxmindRefreshInterval > 0 && n.cfg.MaxmindEditionFiles != nil {
+		go nginx.RefreshGeoLite2DB(n.stopCh)
+	}
 	// force initial sync
 	n.syncQueue.EnqueueTask(task.GetDummyObject("initial-sync"))
 
@@ -444,9 +575,14 @@ func (n *NGINXController) start(cmd *exec.Cmd) {
 
 // DefaultEndpoint returns the default endpoint to be use as default server that returns 404.
 func (n *NGINXController) DefaultEndpoint() ingress.Endpoint {
+	port := n.cfg.ListenPorts.Default
+	if n.cfg.EnableErrorPages {
+		port = n.cfg.ListenPorts.ErrorPages
+	}
+
 	return ingress.Endpoint{
 		Address: "127.0.0.1",
-		Port:    fmt.Sprintf("%v", n.cfg.ListenPorts.Default),
+		Port:    fmt.Sprintf("%v", port),
 		Target:  &apiv1.ObjectReference{},
 	}
 }
@@ -487,10 +623,31 @@ func (n *NGINXController) generateTemplate(cfg ngx_config.Configuration, ingress
 				IP:            svc.Spec.ClusterIP,
 				Port:          port,
 				ProxyProtocol: false,
+				Timeout:       time.Duration(pb.Routing.Timeout) * time.Second,
+				Fallback:      pb.Routing.Fallback,
 			})
 		}
 
+		// the connections to the hosts terminated by NGINX do not have an unmatched SNI
+		hosts := []string{}
+		for _, srv := range ingressCfg.Servers {
+			if srv.SSLPassthrough || srv.Hostname == defServerName {
+				continue
+			}
+			hosts = append(hosts, srv.Hostname)
+			hosts = append(hosts, srv.Aliases...)
+			if srv.RedirectFromToWWW {
+				if host, ok := strings.CutPrefix(srv.Hostname, "www."); ok {
+					hosts = append(hosts, host)
+				} else {
+					hosts = append(hosts, "www."+srv.Hostname)
+				}
+			}
+		}
+
 		n.Proxy.ServerList = servers
+		n.Proxy.Hosts = hosts
+		n.Proxy.UnmatchedSNI = cfg.SSLPassthroughUnmatchedSNI
 	}
 
 	// NGINX cannot resize the hash tables used to store server names. For
@@ -594,6 +751,11 @@ func (n *NGINXController) generateTemplate(cfg ngx_config.Configuration, ingress
 
 	cfg.SSLDHParam = sslDHParam
 
+	// the databases refreshed by the controller must be loaded without a reload
+	if nginx.MaxmindRefreshInterval > 0 && cfg.GeoIP2AutoReloadMinutes == 0 {
+		cfg.GeoIP2AutoReloadMinutes = 1
+	}
+
 	cfg.DefaultSSLCertificate = n.getDefaultSSLCertificate()
 
 	if n.cfg.IsChroot {
@@ -605,23 +767,27 @@ func (n *NGINXController) generateTemplate(cfg ngx_config.Configuration, ingress
 		}
 	}
 
+	redirects := utilingress.BuildRedirects(ingressCfg.Servers)
+
 	tc := &ngx_config.TemplateConfig{
 		ProxySetHeaders:          setHeaders,
 		AddHeaders:               addHeaders,
 		BacklogSize:              sysctlSomaxconn(),
 		Backends:                 ingressCfg.Backends,
 		PassthroughBackends:      ingressCfg.PassthroughBackends,
-		Servers:                  ingressCfg.Servers,
+		Servers:                  renderedServers(ingressCfg.Servers),
 		TCPBackends:              ingressCfg.TCPEndpoints,
 		UDPBackends:              ingressCfg.UDPEndpoints,
 		Cfg:                      cfg,
 		IsIPV6Enabled:            n.isIPV6Enabled && !cfg.DisableIpv6,
 		NginxStatusIpv4Whitelist: cfg.NginxStatusIpv4Whitelist,
 		NginxStatusIpv6Whitelist: cfg.NginxStatusIpv6Whitelist,
-		RedirectServers:          utilingress.BuildRedirects(ingressCfg.Servers),
+		RedirectServers:          redirects,
 		IsSSLPassthroughEnabled:  n.cfg.EnableSSLPassthrough,
 		ListenPorts:              n.cfg.ListenPorts,
+		ClassListenPorts:         classListenPorts(ingressCfg.Servers, redirects),
 		EnableMetrics:            n.cfg.EnableMetrics,
+		EnableChaosInjection:     n.cfg.EnableChaosInjection,
 		MaxmindEditionFiles:      n.cfg.MaxmindEditionFiles,
 		HealthzURI:               nginx.HealthPath,
 		MonitorMaxBatchSize:      n.cfg.MonitorMaxBatchSize,
@@ -637,6 +803,23 @@ func (n *NGINXController) generateTemplate(cfg ngx_config.Configuration, ingress
 	return n.t.Write(tc)
 }
 
+// classListenPorts returns the ports of the IngressClass of the servers and
+// redirects listening on the ports of their class, by hostname
+func classListenPorts(servers []*ingress.Server, redirects []*utilingress.Redirect) map[string]*ingress.ClassListenPorts {
+	ports := make(map[string]*ingress.ClassListenPorts)
+	for _, server := range servers {
+		if server.ClassListenPorts != nil {
+			ports[server.Hostname] = server.ClassListenPorts
+		}
+	}
+	for _, redirect := range redirects {
+		if redirect.ClassListenPorts != nil {
+			ports[redirect.From] = redirect.ClassListenPorts
+		}
+	}
+	return ports
+}
+
 // testTemplate checks if the NGINX configuration inside the byte array is valid
 // running the command "nginx -t" using a temporal file.
 func (n *NGINXController) testTemplate(cfg []byte) error {
@@ -689,7 +872,11 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		return err
 	}
 
-	err = n.createLuaConfig(&cfg)
+	err = writeLuaPlugins(luaPluginsDir, ingressCfg.LuaPlugins)
+	if err != nil {
+		return err
+	}
+	err = n.createLuaConfig(&cfg, luaPluginNames(ingressCfg.LuaPlugins))
 	if err != nil {
 		return err
 	}
@@ -700,7 +887,7 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 
 	err = n.testTemplate(content)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", errConfigTest, err)
 	}
 
 	if klog.V(2).Enabled() {
@@ -740,6 +927,12 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		}
 	}
 
+	// the running configuration is restored when NGINX fails to reload
+	var running []byte
+	if n.cfg.ConfigSnapshots > 0 {
+		running, _ = os.ReadFile(cfgPath)
+	}
+
 	err = os.WriteFile(cfgPath, content, file.ReadWriteByUser)
 	if err != nil {
 		return err
@@ -747,6 +940,11 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 
 	o, err := n.command.ExecCommand("-s", "reload").CombinedOutput()
 	if err != nil {
+		if len(running) > 0 {
+			if werr := os.WriteFile(cfgPath, running, file.ReadWriteByUser); werr != nil {
+				klog.Errorf("Unexpected error restoring the running NGINX configuration: %v", werr)
+			}
+		}
 		return fmt.Errorf("%v\n%v", err, string(o))
 	}
 
@@ -823,6 +1021,7 @@ func (n *NGINXController) setupSSLProxy() {
 			Port:          proxyPort,
 			ProxyProtocol: true,
 		},
+		OnConnection: n.metricCollector.IncSSLPassthroughConnection,
 	}
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", sslPort))
@@ -882,6 +1081,13 @@ func (n *NGINXController) configureDynamically(pcfg *ingress.Configuration) erro
 		if err != nil {
 			return err
 		}
+
+		if hasDynamicServers(n.runningConfig.Servers) || hasDynamicServers(pcfg.Servers) {
+			err = configureDynamicServers(pcfg.Servers, n.store.GetBackendConfiguration().NoTLSRedirectLocations)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -959,6 +1165,7 @@ func configureBackends(rawBackends []*ingress.Backend) error {
 			SessionAffinity:      backend.SessionAffinity,
 			UpstreamHashBy:       backend.UpstreamHashBy,
 			LoadBalancing:        backend.LoadBalancing,
+			UpstreamKeepalive:    backend.UpstreamKeepalive,
 			Service:              service,
 			NoServer:             backend.NoServer,
 			TrafficShapingPolicy: backend.TrafficShapingPolicy,
@@ -1082,7 +1289,25 @@ func createOpentelemetryCfg(cfg *ngx_config.Configuration) error {
 	return os.WriteFile(cfg.OpentelemetryConfig, tmplBuf.Bytes(), file.ReadWriteByUser)
 }
 
-func (n *NGINXController) createLuaConfig(cfg *ngx_config.Configuration) error {
+// otlpAccessLogEndpoint returns the endpoint the access log records are sent
+// to, the OTLP/HTTP port of the collector of the traces by default. Empty
+// when the records are not sent.
+func otlpAccessLogEndpoint(cfg *ngx_config.Configuration) string {
+	if !cfg.EnableOTLPAccessLog {
+		return ""
+	}
+	if cfg.OtlpAccessLogEndpoint != "" {
+		return cfg.OtlpAccessLogEndpoint
+	}
+	if cfg.OtlpCollectorHost == "" {
+		klog.Warning("The access log records are not sent, enable-otlp-access-log requires otlp-access-log-endpoint or otlp-collector-host")
+		return ""
+	}
+
+	return fmt.Sprintf("http://%v/v1/logs", net.JoinHostPort(cfg.OtlpCollectorHost, "4318"))
+}
+
+func (n *NGINXController) createLuaConfig(cfg *ngx_config.Configuration, plugins []string) error {
 	luaconfigs := &ngx_template.LuaConfig{
 		EnableMetrics: n.cfg.EnableMetrics,
 		ListenPorts: ngx_template.LuaListenPorts{
@@ -1100,6 +1325,32 @@ func (n *NGINXController) createLuaConfig(cfg *ngx_config.Configuration) error {
 		HSTSMaxAge:              cfg.HSTSMaxAge,
 		HSTSIncludeSubdomains:   cfg.HSTSIncludeSubdomains,
 		HSTSPreload:             cfg.HSTSPreload,
+		AuthCookieSession: ngx_template.LuaAuthCookieSession{
+			Store:     cfg.AuthCookieSessionStore,
+			Threshold: cfg.AuthCookieSessionThreshold,
+			Name:      cfg.AuthCookieSessionName,
+			TTL:       cfg.AuthCookieSessionTTL,
+			RedisHost: cfg.AuthCookieSessionRedisHost,
+			RedisPort: cfg.AuthCookieSessionRedisPort,
+		},
+		EnableTLSFingerprinting:   cfg.EnableTLSFingerprinting,
+		TLSFingerprintHeaders:     cfg.TLSFingerprintHeaders,
+		UpstreamAttemptsHeader:    len(cfg.UpstreamAttemptsHeaderCIDRs) > 0,
+		EnableTLSHandshakeMetrics: n.cfg.EnableMetrics && cfg.EnableTLSHandshakeMetrics,
+		BotMitigation: ngx_template.LuaBotMitigation{
+			Key: cfg.BotChallengeKey,
+			TTL: cfg.BotChallengeTTL,
+		},
+		OTLPAccessLog: ngx_template.LuaOTLPAccessLog{
+			Endpoint:    otlpAccessLogEndpoint(cfg),
+			BatchSize:   cfg.OtlpAccessLogBatchSize,
+			ServiceName: cfg.OtelServiceName,
+		},
+		ConnectBackoff: ngx_template.LuaConnectBackoff{
+			Base: cfg.UpstreamConnectBackoffBase,
+			Max:  cfg.UpstreamConnectBackoffMax,
+		},
+		Plugins: plugins,
 	}
 	jsonCfg, err := json.Marshal(luaconfigs)
 	if err != nil {
@@ -32,6 +32,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/nginx"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
 )
@@ -355,6 +356,28 @@ func TestNextPowerOf2(t *testing.T) {
 	}
 }
 
+func TestOtlpAccessLogEndpoint(t *testing.T) {
+	testCases := []struct {
+		cfg      ngx_config.Configuration
+		expected string
+	}{
+		{ngx_config.Configuration{OtlpAccessLogEndpoint: "http://collector:4318/v1/logs"}, ""},
+		{ngx_config.Configuration{EnableOTLPAccessLog: true}, ""},
+		{ngx_config.Configuration{EnableOTLPAccessLog: true, OtlpCollectorHost: "collector.observability"}, "http://collector.observability:4318/v1/logs"},
+		{ngx_config.Configuration{EnableOTLPAccessLog: true, OtlpCollectorHost: "fd00::1"}, "http://[fd00::1]:4318/v1/logs"},
+		{
+			ngx_config.Configuration{EnableOTLPAccessLog: true, OtlpCollectorHost: "collector", OtlpAccessLogEndpoint: "https://logs.example.com/v1/logs"},
+			"https://logs.example.com/v1/logs",
+		},
+	}
+
+	for _, tc := range testCases {
+		if endpoint := otlpAccessLogEndpoint(&tc.cfg); endpoint != tc.expected {
+			t.Errorf("expected %q but got %q for %+v", tc.expected, endpoint, tc.cfg)
+		}
+	}
+}
+
 func TestCleanTempNginxCfg(t *testing.T) {
 	err := cleanTempNginxCfg()
 	if err != nil {
@@ -0,0 +1,260 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+const (
+	// ocspCheckTimeout is the maximum time allowed to obtain an OCSP response
+	ocspCheckTimeout = 10 * time.Second
+	// ocspCheckInterval is the time between two checks of an OCSP responder
+	ocspCheckInterval = time.Minute
+	// ocspMaxSize is the maximum size of an OCSP response
+	ocspMaxSize = 1 << 20
+)
+
+// ocspResponder contains the state of an OCSP responder
+// configured with auth-tls-ocsp-responder
+type ocspResponder struct {
+	url        string
+	caFileName string
+
+	lastCheck time.Time
+	lastErr   error
+	checking  bool
+}
+
+// Available returns false when the last check of the responder failed
+func (s ocspResponder) Available() bool {
+	return s.lastErr == nil
+}
+
+// ocspProber checks that the OCSP responders used for client certificate
+// authentication return valid responses signed for the certificate authority
+type ocspProber struct {
+	client          *http.Client
+	metricCollector metric.Collector
+	// onChange is called when the availability of a responder changes
+	onChange func()
+
+	mu         sync.Mutex
+	responders map[string]*ocspResponder
+}
+
+func newOCSPProber(mc metric.Collector, onChange func()) *ocspProber {
+	return &ocspProber{
+		client:          &http.Client{Timeout: ocspCheckTimeout},
+		metricCollector: mc,
+		onChange:        onChange,
+		responders:      make(map[string]*ocspResponder),
+	}
+}
+
+// Get returns the state of the OCSP responder located at url.
+// Responders are registered with Sync and are available until checked.
+func (p *ocspProber) Get(url string) ocspResponder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	responder, ok := p.responders[url]
+	if !ok {
+		return ocspResponder{url: url}
+	}
+
+	return *responder
+}
+
+// Sync registers the OCSP responders referenced by the configuration,
+// indexed by URL with the file of the certificate authority they answer for.
+// The responders that are no longer referenced are not checked anymore.
+func (p *ocspProber) Sync(refs map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for url := range p.responders {
+		if _, ok := refs[url]; !ok {
+			delete(p.responders, url)
+		}
+	}
+
+	for url, caFileName := range refs {
+		responder, ok := p.responders[url]
+		if !ok {
+			p.responders[url] = &ocspResponder{url: url, caFileName: caFileName, checking: true}
+			go p.check(url)
+			continue
+		}
+
+		responder.caFileName = caFileName
+	}
+}
+
+// Run checks the OCSP responders until stopCh is closed
+func (p *ocspProber) Run(stopCh chan struct{}) {
+	wait.Until(p.checkAll, 10*time.Second, stopCh)
+}
+
+func (p *ocspProber) checkAll() {
+	var due []string
+
+	p.mu.Lock()
+	for url, responder := range p.responders {
+		if !responder.checking && time.Since(responder.lastCheck) >= ocspCheckInterval {
+			responder.checking = true
+			due = append(due, url)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, url := range due {
+		p.check(url)
+	}
+}
+
+// check sends an OCSP request to the responder located at url
+func (p *ocspProber) check(url string) {
+	p.mu.Lock()
+	responder, ok := p.responders[url]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	caFileName := responder.caFileName
+	p.mu.Unlock()
+
+	start := time.Now()
+	err := p.probe(url, caFileName)
+	p.metricCollector.ObserveOCSPCheck(url, time.Since(start), err == nil)
+
+	p.mu.Lock()
+	responder, ok = p.responders[url]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+
+	wasAvailable := responder.Available()
+	responder.checking = false
+	responder.lastCheck = time.Now()
+	responder.lastErr = err
+	changed := wasAvailable != responder.Available()
+	p.mu.Unlock()
+
+	if err != nil {
+		klog.ErrorS(err, "Error checking OCSP responder", "url", url)
+	}
+
+	if changed {
+		if err == nil {
+			klog.InfoS("OCSP responder is available again", "url", url)
+		}
+		p.onChange()
+	}
+}
+
+// probe requests the status of a certificate issued by the certificate
+// authority of the file caFileName and verifies the signature of the response
+func (p *ocspProber) probe(url, caFileName string) error {
+	issuer, err := readIssuer(caFileName)
+	if err != nil {
+		return err
+	}
+
+	// the responder answers with the status of any serial number
+	// the certificate authority is responsible for
+	req, err := ocsp.CreateRequest(&x509.Certificate{SerialNumber: big.NewInt(1)}, issuer, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Post(url, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ocspMaxSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > ocspMaxSize {
+		return fmt.Errorf("OCSP response is bigger than %d bytes", ocspMaxSize)
+	}
+
+	if _, err := ocsp.ParseResponse(body, issuer); err != nil {
+		return fmt.Errorf("invalid OCSP response: %w", err)
+	}
+
+	return nil
+}
+
+// readIssuer returns the first certificate authority of the file caFileName
+func readIssuer(caFileName string) (*x509.Certificate, error) {
+	certs, err := readCertificates(caFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cert := range certs {
+		if cert.IsCA {
+			return cert, nil
+		}
+	}
+
+	return certs[0], nil
+}
+
+// applyOCSPFailureMode disables the OCSP validation of the client certificates
+// of the server when its responder is not available and the failure mode is fail-open
+func (n *NGINXController) applyOCSPFailureMode(server *ingress.Server) {
+	if n.ocspProber == nil {
+		return
+	}
+
+	auth := &server.CertificateAuth
+	if auth.OCSPResponder == "" || !auth.RevocationFailOpen() {
+		return
+	}
+
+	if n.ocspProber.Get(auth.OCSPResponder).Available() {
+		return
+	}
+
+	klog.Warningf("OCSP responder %q is not available, client certificates of server %q are not checked with OCSP",
+		auth.OCSPResponder, server.Hostname)
+	auth.OCSP = ""
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestOCSPProberProbe(t *testing.T) {
+	ca, key := fakeCA(t)
+	caFileName := writeFakeCA(t, ca)
+	other, otherKey := fakeCA(t)
+
+	response := func(t *testing.T, signer *x509.Certificate, signerKey *ecdsa.PrivateKey) []byte {
+		t.Helper()
+
+		resp, err := ocsp.CreateResponse(ca, signer, ocsp.Response{
+			Status:       ocsp.Unknown,
+			SerialNumber: big.NewInt(1),
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, signerKey)
+		if err != nil {
+			t.Fatalf("unexpected error creating OCSP response: %v", err)
+		}
+		return resp
+	}
+
+	testCases := map[string]struct {
+		body      []byte
+		status    int
+		expectErr bool
+	}{
+		"response signed by the CA":      {response(t, ca, key), http.StatusOK, false},
+		"response signed by another key": {response(t, other, otherKey), http.StatusOK, true},
+		"invalid content":                {[]byte("not an OCSP response"), http.StatusOK, true},
+		"server error":                   {nil, http.StatusInternalServerError, true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("unexpected error reading request: %v", err)
+				}
+				if _, err := ocsp.ParseRequest(body); err != nil {
+					t.Errorf("expected an OCSP request: %v", err)
+				}
+
+				w.WriteHeader(tc.status)
+				//nolint:errcheck // test server
+				w.Write(tc.body)
+			}))
+			defer srv.Close()
+
+			p := newOCSPProber(metric.DummyCollector{}, func() {})
+			err := p.probe(srv.URL, caFileName)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error %t but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestOCSPProberCheck(t *testing.T) {
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	ca, _ := fakeCA(t)
+	changes := 0
+	p := newOCSPProber(metric.DummyCollector{}, func() {
+		changes++
+	})
+	p.responders[srv.URL] = &ocspResponder{url: srv.URL, caFileName: writeFakeCA(t, ca)}
+
+	if !p.Get(srv.URL).Available() {
+		t.Fatalf("expected the responder to be available before it is checked")
+	}
+
+	p.check(srv.URL)
+	if p.Get(srv.URL).Available() {
+		t.Errorf("expected the responder to be unavailable after a failed check")
+	}
+	if changes != 1 {
+		t.Errorf("expected a notification when the responder became unavailable")
+	}
+
+	p.check(srv.URL)
+	if changes != 1 {
+		t.Errorf("expected no notification when the availability did not change")
+	}
+}
+
+func TestApplyOCSPFailureMode(t *testing.T) {
+	const responder = "http://ocsp.example.com"
+
+	testCases := map[string]struct {
+		responder    string
+		failureMode  string
+		available    bool
+		expectedOCSP string
+	}{
+		"available responder fail-open": {
+			responder:    responder,
+			failureMode:  authtls.RevocationFailOpen,
+			available:    true,
+			expectedOCSP: "on",
+		},
+		"unavailable responder fail-open": {
+			responder:   responder,
+			failureMode: authtls.RevocationFailOpen,
+		},
+		"unavailable responder fail-closed": {
+			responder:    responder,
+			failureMode:  authtls.RevocationFailClosed,
+			expectedOCSP: "on",
+		},
+		"responder of the client certificate": {
+			failureMode:  authtls.RevocationFailOpen,
+			expectedOCSP: "on",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			state := &ocspResponder{url: responder, lastCheck: time.Now()}
+			if !tc.available {
+				state.lastErr = io.EOF
+			}
+
+			n := &NGINXController{
+				ocspProber: &ocspProber{
+					responders: map[string]*ocspResponder{responder: state},
+				},
+			}
+
+			server := &ingress.Server{
+				Hostname: "example.com",
+				CertificateAuth: authtls.Config{
+					AuthSSLCert:           resolver.AuthSSLCert{CAFileName: "/ssl/ca.pem"},
+					OCSP:                  "on",
+					OCSPResponder:         tc.responder,
+					RevocationFailureMode: tc.failureMode,
+				},
+			}
+
+			n.applyOCSPFailureMode(server)
+
+			if server.CertificateAuth.OCSP != tc.expectedOCSP {
+				t.Errorf("expected OCSP %q but got %q", tc.expectedOCSP, server.CertificateAuth.OCSP)
+			}
+		})
+	}
+}
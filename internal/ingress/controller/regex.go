@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"regexp"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+var (
+	// pcreCompileFailure matches the error reported by NGINX for a regular
+	// expression it cannot compile
+	pcreCompileFailure = regexp.MustCompile(`pcre2?_compile\(\) failed: (.+?) in "(.*)" at "`)
+	// pcreSettings matches the settings and the anchor prepended to the path
+	// of regular expression locations
+	pcreSettings = regexp.MustCompile(`^(\(\*[A-Z_]+(=[0-9]+)?\))*\^`)
+)
+
+// regexCompileFailure describes a regular expression path NGINX cannot compile
+type regexCompileFailure struct {
+	Ingress *ingress.Ingress
+	Path    string
+	Reason  string
+}
+
+// regexCompileFailures returns the regular expression paths of the locations
+// NGINX reported as invalid in the output of a configuration test, along with
+// the Ingress defining each of them.
+func regexCompileFailures(output string, servers []*ingress.Server) []regexCompileFailure {
+	reasons := map[string]string{}
+	for _, match := range pcreCompileFailure.FindAllStringSubmatch(output, -1) {
+		path := pcreSettings.ReplaceAllString(match[2], "")
+		if _, ok := reasons[path]; !ok {
+			reasons[path] = match[1]
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	failures := []regexCompileFailure{}
+	seen := map[string]bool{}
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			reason, ok := reasons[location.Path]
+			if !ok || location.Ingress == nil {
+				continue
+			}
+
+			key := k8s.MetaNamespaceKey(&location.Ingress.Ingress) + ":" + location.Path
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			failures = append(failures, regexCompileFailure{
+				Ingress: location.Ingress,
+				Path:    location.Path,
+				Reason:  reason,
+			})
+		}
+	}
+	return failures
+}
+
+// reportRegexCompileFailures records an event on every Ingress defining a
+// regular expression path NGINX failed to compile.
+func (n *NGINXController) reportRegexCompileFailures(err error, servers []*ingress.Server) {
+	for _, failure := range regexCompileFailures(err.Error(), servers) {
+		klog.Warningf("Ingress %q defines the path %q, which is not a valid regular expression: %v",
+			k8s.MetaNamespaceKey(&failure.Ingress.Ingress), failure.Path, failure.Reason)
+		n.recorder.Eventf(&failure.Ingress.Ingress, apiv1.EventTypeWarning, "RegexCompileFailed",
+			"Path %q is not a valid regular expression: %v", failure.Path, failure.Reason)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestRegexCompileFailures(t *testing.T) {
+	foo := regressionIngress("foo", "")
+	bar := regressionIngress("bar", "")
+	servers := []*ingress.Server{
+		{
+			Hostname: "example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Ingress: bar},
+				{Path: "/api/(v1", Ingress: foo},
+				{Path: "/ok/(.*)", Ingress: foo},
+			},
+		},
+		{
+			Hostname: "www.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/api/(v1", Ingress: foo},
+				{Path: "/api/(v1", Ingress: bar},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		output   string
+		expected []regexCompileFailure
+	}{
+		{
+			"no regex failure",
+			`nginx: [emerg] unknown directive "foo" in /tmp/nginx/nginx-cfg123:10`,
+			nil,
+		},
+		{
+			"pcre",
+			`nginx: [emerg] pcre_compile() failed: missing ) in "^/api/(v1" at "" in /tmp/nginx/nginx-cfg123:10`,
+			[]regexCompileFailure{
+				{Ingress: foo, Path: "/api/(v1", Reason: "missing )"},
+				{Ingress: bar, Path: "/api/(v1", Reason: "missing )"},
+			},
+		},
+		{
+			"pcre2 with limits",
+			`nginx: [emerg] pcre2_compile() failed: missing closing parenthesis in "(*LIMIT_MATCH=1000)(*LIMIT_RECURSION=100)^/api/(v1" at "" in /tmp/nginx/nginx-cfg123:10`,
+			[]regexCompileFailure{
+				{Ingress: foo, Path: "/api/(v1", Reason: "missing closing parenthesis"},
+				{Ingress: bar, Path: "/api/(v1", Reason: "missing closing parenthesis"},
+			},
+		},
+		{
+			"unknown path",
+			`nginx: [emerg] pcre_compile() failed: missing ) in "^/other/(" at "" in /tmp/nginx/nginx-cfg123:10`,
+			[]regexCompileFailure{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failures := regexCompileFailures(tc.output, servers)
+			if !reflect.DeepEqual(failures, tc.expected) {
+				t.Errorf("expected %v but got %v", tc.expected, failures)
+			}
+		})
+	}
+}
+
+func TestReportRegexCompileFailures(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	n := &NGINXController{recorder: recorder}
+
+	servers := []*ingress.Server{
+		{
+			Hostname:  "example.com",
+			Locations: []*ingress.Location{{Path: "/api/(v1", Ingress: regressionIngress("foo", "")}},
+		},
+	}
+	err := errors.New(`nginx: [emerg] pcre_compile() failed: missing ) in "^/api/(v1" at "" in /tmp/nginx/nginx-cfg123:10`)
+
+	n.reportRegexCompileFailures(err, servers)
+	expected := `Warning RegexCompileFailed Path "/api/(v1" is not a valid regular expression: missing )`
+	if event := <-recorder.Events; event != expected {
+		t.Errorf("expected event %q but got %q", expected, event)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected event %q", <-recorder.Events)
+	}
+}
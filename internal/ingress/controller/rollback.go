@@ -0,0 +1,197 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// maxInvalidIngresses bounds the number of Ingresses looked for by bisection
+// after NGINX rejects a configuration, each of them costing a few tests
+const maxInvalidIngresses = 5
+
+// errConfigTest is wrapped by the errors of the NGINX configuration tests
+var errConfigTest = errors.New("NGINX rejected the configuration")
+
+// configSnapshot contains the Ingresses of a configuration applied
+// successfully, by namespace and name
+type configSnapshot map[string]*ingress.Ingress
+
+// ingressRollback replaces a version of an Ingress rejected by NGINX
+type ingressRollback struct {
+	// resourceVersion is the version of the Ingress rejected by NGINX
+	resourceVersion string
+	// lastValid is the version of the Ingress in the last snapshot
+	// containing it, nil to ignore the Ingress
+	lastValid *ingress.Ingress
+}
+
+// recordConfigSnapshot keeps the Ingresses of a configuration applied
+// successfully, up to the number of snapshots configured
+func (n *NGINXController) recordConfigSnapshot(ings []*ingress.Ingress) {
+	if n.cfg.ConfigSnapshots == 0 {
+		return
+	}
+
+	snapshot := configSnapshot{}
+	for _, ing := range ings {
+		snapshot[k8s.MetaNamespaceKey(&ing.Ingress)] = ing
+	}
+
+	n.configSnapshots = append(n.configSnapshots, snapshot)
+	if len(n.configSnapshots) > n.cfg.ConfigSnapshots {
+		n.configSnapshots = n.configSnapshots[len(n.configSnapshots)-n.cfg.ConfigSnapshots:]
+	}
+}
+
+// rollBackIngresses replaces the Ingresses rejected by NGINX by their last
+// valid version, until they are updated
+func (n *NGINXController) rollBackIngresses(ings []*ingress.Ingress) []*ingress.Ingress {
+	if len(n.ingressRollbacks) == 0 {
+		return ings
+	}
+
+	result := make([]*ingress.Ingress, 0, len(ings))
+	rolledBack := map[string]bool{}
+	for _, ing := range ings {
+		key := k8s.MetaNamespaceKey(&ing.Ingress)
+		rollback, ok := n.ingressRollbacks[key]
+		if !ok || rollback.resourceVersion != ing.ResourceVersion {
+			result = append(result, ing)
+			continue
+		}
+
+		rolledBack[key] = true
+		if rollback.lastValid != nil {
+			result = append(result, rollback.lastValid)
+		}
+	}
+
+	// the updated and deleted Ingresses are not rolled back anymore
+	for key := range n.ingressRollbacks {
+		if !rolledBack[key] {
+			delete(n.ingressRollbacks, key)
+		}
+	}
+
+	return result
+}
+
+// rollBackInvalidIngresses looks for the Ingresses breaking a configuration
+// rejected by NGINX, to replace them in the next syncs by their version in
+// the last snapshot containing them or to ignore them, and records an Event
+// on each of them.
+func (n *NGINXController) rollBackInvalidIngresses(ings []*ingress.Ingress, err error) {
+	if len(n.configSnapshots) == 0 || !errors.Is(err, errConfigTest) {
+		return
+	}
+
+	invalid := bisectInvalidIngresses(ings, n.testIngresses)
+	if len(invalid) == 0 {
+		klog.Warningf("The rejected NGINX configuration is not caused by an Ingress, keeping the running configuration")
+		return
+	}
+
+	if n.ingressRollbacks == nil {
+		n.ingressRollbacks = map[string]ingressRollback{}
+	}
+
+	for _, ing := range invalid {
+		key := k8s.MetaNamespaceKey(&ing.Ingress)
+
+		rollback := ingressRollback{
+			resourceVersion: ing.ResourceVersion,
+			lastValid:       n.lastValidIngress(key, ing.ResourceVersion),
+		}
+		if previous, ok := n.ingressRollbacks[key]; ok {
+			// the last valid version of the Ingress is rejected as well
+			rollback = ingressRollback{resourceVersion: previous.resourceVersion}
+		}
+		n.ingressRollbacks[key] = rollback
+
+		if rollback.lastValid != nil {
+			klog.Warningf("NGINX rejected the configuration of Ingress %q, rolling back to its version %v", key, rollback.lastValid.ResourceVersion)
+			n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "ConfigurationRolledBack",
+				"NGINX rejected the configuration of the Ingress, serving its last valid version until it is updated")
+			continue
+		}
+
+		klog.Warningf("NGINX rejected the configuration of Ingress %q, ignoring it", key)
+		n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "ConfigurationRolledBack",
+			"NGINX rejected the configuration of the Ingress, ignoring it until it is updated")
+	}
+}
+
+// lastValidIngress returns the version of an Ingress, other than the
+// rejected one, in the last snapshot containing it
+func (n *NGINXController) lastValidIngress(key, rejectedVersion string) *ingress.Ingress {
+	for i := len(n.configSnapshots) - 1; i >= 0; i-- {
+		if ing, ok := n.configSnapshots[i][key]; ok && ing.ResourceVersion != rejectedVersion {
+			return ing
+		}
+	}
+	return nil
+}
+
+// testIngresses tests the NGINX configuration of the Ingresses
+func (n *NGINXController) testIngresses(ings []*ingress.Ingress) error {
+	cfg := n.store.GetBackendConfiguration()
+	cfg.Resolver = n.resolver
+
+	_, _, pcfg := n.getConfiguration(ings)
+	content, err := n.generateTemplate(cfg, *pcfg)
+	if err != nil {
+		return err
+	}
+	return n.testTemplate(content)
+}
+
+// bisectInvalidIngresses returns the Ingresses breaking the configuration of
+// ings according to test. The shortest prefix of the Ingresses failing the
+// test ends with an invalid Ingress, which is set aside before looking for
+// the next one. No Ingress is returned when the configuration without any
+// Ingress fails.
+func bisectInvalidIngresses(ings []*ingress.Ingress, test func([]*ingress.Ingress) error) []*ingress.Ingress {
+	if test([]*ingress.Ingress{}) != nil {
+		return nil
+	}
+
+	invalid := []*ingress.Ingress{}
+	remaining := ings
+	for len(invalid) < maxInvalidIngresses && test(remaining) != nil {
+		// remaining[:valid] passes the test and remaining[:failing] does not
+		valid, failing := 0, len(remaining)
+		for failing-valid > 1 {
+			middle := (valid + failing) / 2
+			if test(remaining[:middle]) != nil {
+				failing = middle
+			} else {
+				valid = middle
+			}
+		}
+
+		invalid = append(invalid, remaining[failing-1])
+		remaining = append(remaining[:failing-1:failing-1], remaining[failing:]...)
+	}
+	return invalid
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func ingressNames(ings []*ingress.Ingress) []string {
+	names := []string{}
+	for _, ing := range ings {
+		names = append(names, ing.Name+"@"+ing.ResourceVersion)
+	}
+	return names
+}
+
+func TestBisectInvalidIngresses(t *testing.T) {
+	ings := []*ingress.Ingress{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		ings = append(ings, regressionIngress(name, "1"))
+	}
+
+	testCases := []struct {
+		name     string
+		invalid  map[string]bool
+		conflict [2]string
+		broken   bool
+		expected []string
+	}{
+		{"valid configuration", nil, [2]string{}, false, []string{}},
+		{"single invalid Ingress", map[string]bool{"e": true}, [2]string{}, false, []string{"e@1"}},
+		{"several invalid Ingresses", map[string]bool{"a": true, "c": true, "g": true}, [2]string{}, false, []string{"a@1", "c@1", "g@1"}},
+		{"conflicting Ingresses", nil, [2]string{"b", "f"}, false, []string{"f@1"}},
+		{"configuration broken without Ingresses", nil, [2]string{}, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tests := 0
+			test := func(subset []*ingress.Ingress) error {
+				tests++
+				if tc.broken {
+					return errors.New("broken")
+				}
+				names := map[string]bool{}
+				for _, ing := range subset {
+					if tc.invalid[ing.Name] {
+						return errors.New("invalid")
+					}
+					names[ing.Name] = true
+				}
+				if names[tc.conflict[0]] && names[tc.conflict[1]] {
+					return errors.New("conflict")
+				}
+				return nil
+			}
+
+			invalid := bisectInvalidIngresses(ings, test)
+			if tc.expected == nil {
+				if invalid != nil {
+					t.Errorf("expected no Ingress but got %v", ingressNames(invalid))
+				}
+				return
+			}
+			if actual := ingressNames(invalid); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v but got %v", tc.expected, actual)
+			}
+			if len(ings) != 7 || ings[6].Name != "g" {
+				t.Errorf("expected the Ingresses to be left untouched")
+			}
+			if maxTests := 2 + len(tc.expected)*4; tests > maxTests {
+				t.Errorf("expected at most %d tests but ran %d", maxTests, tests)
+			}
+		})
+	}
+}
+
+func TestRollBackIngresses(t *testing.T) {
+	n := &NGINXController{cfg: &Configuration{ConfigSnapshots: 2}}
+
+	a1, b1, c1 := regressionIngress("a", "1"), regressionIngress("b", "1"), regressionIngress("c", "1")
+	a2, b2 := regressionIngress("a", "2"), regressionIngress("b", "2")
+
+	n.recordConfigSnapshot([]*ingress.Ingress{a1, b1})
+	n.recordConfigSnapshot([]*ingress.Ingress{a2, b1})
+	n.recordConfigSnapshot([]*ingress.Ingress{a2, b1, c1})
+	if len(n.configSnapshots) != 2 {
+		t.Fatalf("expected 2 snapshots but got %d", len(n.configSnapshots))
+	}
+
+	if ing := n.lastValidIngress("default/a", "3"); ing != a2 {
+		t.Errorf("expected the version 2 of Ingress a but got %v", ing)
+	}
+	if ing := n.lastValidIngress("default/a", "2"); ing != nil {
+		t.Errorf("expected no version of Ingress a older than the snapshots but got %v", ing)
+	}
+	if ing := n.lastValidIngress("default/d", "1"); ing != nil {
+		t.Errorf("expected no version of Ingress d but got %v", ing)
+	}
+
+	n.ingressRollbacks = map[string]ingressRollback{
+		"default/a": {resourceVersion: "3", lastValid: a2},
+		"default/b": {resourceVersion: "2"},
+		"default/c": {resourceVersion: "1"},
+	}
+
+	a3 := regressionIngress("a", "3")
+	c2 := regressionIngress("c", "2")
+
+	ings := n.rollBackIngresses([]*ingress.Ingress{a3, b2, c2})
+	expected := []string{"a@2", "c@2"}
+	if actual := ingressNames(ings); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	if _, ok := n.ingressRollbacks["default/c"]; ok {
+		t.Errorf("expected the rollback of the updated Ingress c to be forgotten")
+	}
+	if len(n.ingressRollbacks) != 2 {
+		t.Errorf("expected 2 rollbacks but got %d", len(n.ingressRollbacks))
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// routeRegression is a sampled request served by an Ingress which
+// would be sent to the default backend by a new configuration
+type routeRegression struct {
+	sample  collectors.RequestSample
+	ingress string
+}
+
+func (r routeRegression) String() string {
+	return fmt.Sprintf("%s %s%s (Ingress %s)", r.sample.Method, r.sample.Host, r.sample.Path, r.ingress)
+}
+
+// matchServer returns the server NGINX selects for a host: the server with
+// the same name or alias, then the longest matching wildcard name and the
+// catch-all server when none match.
+func matchServer(servers []*ingress.Server, host string) *ingress.Server {
+	var wildcard, catchAll *ingress.Server
+	wildcardName := ""
+	for _, server := range servers {
+		if server.Hostname == defServerName {
+			catchAll = server
+			continue
+		}
+
+		names := append([]string{server.Hostname}, server.Aliases...)
+		for _, name := range names {
+			if name == host {
+				return server
+			}
+			if strings.HasPrefix(name, "*.") && strings.HasSuffix(host, name[1:]) && len(name) > len(wildcardName) {
+				wildcard = server
+				wildcardName = name
+			}
+		}
+	}
+
+	if wildcard != nil {
+		return wildcard
+	}
+	return catchAll
+}
+
+// matchLocation returns the location NGINX selects for a path, following
+// the location blocks generated by the template: when a location of the
+// server uses a regular expression all the locations are case insensitive
+// regular expressions evaluated in order, otherwise an exact location is
+// preferred to the longest prefix.
+func matchLocation(server *ingress.Server, path string) *ingress.Location {
+	enforceRegex := false
+	for _, location := range server.Locations {
+		if needsRewrite(location) || location.Rewrite.UseRegex {
+			enforceRegex = true
+			break
+		}
+	}
+
+	if enforceRegex {
+		for _, location := range server.Locations {
+			re, err := regexp.Compile("(?i)^" + location.Path)
+			if err == nil && re.MatchString(path) {
+				return location
+			}
+		}
+		return nil
+	}
+
+	var prefix *ingress.Location
+	for _, location := range server.Locations {
+		if location.PathType != nil && *location.PathType == pathTypeExact {
+			if location.Path == path {
+				return location
+			}
+			continue
+		}
+
+		if strings.HasPrefix(path, location.Path) && (prefix == nil || len(location.Path) > len(prefix.Path)) {
+			prefix = location
+		}
+	}
+	return prefix
+}
+
+// routeSample returns the location serving a sampled request, nil when
+// the request is sent to the default backend
+func routeSample(servers []*ingress.Server, sample *collectors.RequestSample) *ingress.Location {
+	server := matchServer(servers, sample.Host)
+	if server == nil {
+		return nil
+	}
+
+	location := matchLocation(server, sample.Path)
+	if location == nil || location.Ingress == nil || location.Backend == defUpstreamName {
+		return nil
+	}
+	return location
+}
+
+// routeRegressions returns the sampled requests served by an Ingress in the
+// running configuration which would be sent to the default backend by the
+// new one. The routes of the Ingresses updated or deleted since the running
+// configuration was built are expected to change and are not checked.
+func routeRegressions(running, updated []*ingress.Server, ings []*ingress.Ingress, samples []collectors.RequestSample) []routeRegression {
+	resourceVersions := make(map[string]string, len(ings))
+	for _, ing := range ings {
+		resourceVersions[k8s.MetaNamespaceKey(ing)] = ing.ResourceVersion
+	}
+
+	regressions := []routeRegression{}
+	for i := range samples {
+		before := routeSample(running, &samples[i])
+		if before == nil {
+			continue
+		}
+
+		if routeSample(updated, &samples[i]) != nil {
+			continue
+		}
+
+		ingKey := k8s.MetaNamespaceKey(before.Ingress)
+		if resourceVersion, ok := resourceVersions[ingKey]; !ok || resourceVersion != before.Ingress.ResourceVersion {
+			continue
+		}
+
+		regressions = append(regressions, routeRegression{sample: samples[i], ingress: ingKey})
+	}
+	return regressions
+}
+
+// checkRouteRegressions returns an error when the new configuration sends
+// sampled requests to the default backend and its reload must be held. The
+// reload is no longer held once the route regressions are found for longer
+// than the RouteRegressionTimeout.
+func (n *NGINXController) checkRouteRegressions(ings []*ingress.Ingress, pcfg *ingress.Configuration) error {
+	regressions := routeRegressions(n.runningConfig.Servers, pcfg.Servers, ings, n.metricCollector.RequestSamples())
+	if len(regressions) == 0 {
+		n.routeRegressionSince = time.Time{}
+		return nil
+	}
+
+	for _, regression := range regressions {
+		klog.Warningf("Request %v would be sent to the default backend by the new configuration", regression)
+	}
+
+	if n.routeRegressionSince.IsZero() {
+		n.routeRegressionSince = time.Now()
+	}
+	if n.cfg.RouteRegressionTimeout > 0 && time.Since(n.routeRegressionSince) >= n.cfg.RouteRegressionTimeout {
+		n.recorder.Eventf(k8s.IngressPodDetails, apiv1.EventTypeWarning, "RouteRegression",
+			"Configuration applied after %v, %d sampled requests are sent to the default backend, like %v", n.cfg.RouteRegressionTimeout, len(regressions), regressions[0])
+		n.routeRegressionSince = time.Time{}
+		return nil
+	}
+
+	n.recorder.Eventf(k8s.IngressPodDetails, apiv1.EventTypeWarning, "RouteRegression",
+		"Reload held, %d sampled requests would be sent to the default backend, like %v", len(regressions), regressions[0])
+	return fmt.Errorf("new configuration sends %d sampled requests to the default backend", len(regressions))
+}
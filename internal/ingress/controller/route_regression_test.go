@@ -0,0 +1,227 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func regressionIngress(name, resourceVersion string) *ingress.Ingress {
+	return &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: resourceVersion},
+		},
+	}
+}
+
+func regressionLocation(path string, pathType networking.PathType, ing *ingress.Ingress) *ingress.Location {
+	return &ingress.Location{Path: path, PathType: &pathType, Backend: "default-" + ing.Name + "-80", Ingress: ing}
+}
+
+func defaultBackendLocation() *ingress.Location {
+	return &ingress.Location{Path: rootLocation, PathType: &pathTypePrefix, Backend: defUpstreamName, IsDefBackend: true}
+}
+
+func TestMatchServer(t *testing.T) {
+	servers := []*ingress.Server{
+		{Hostname: defServerName},
+		{Hostname: "foo.bar", Aliases: []string{"www.foo.bar"}},
+		{Hostname: "*.foo.bar"},
+		{Hostname: "*.api.foo.bar"},
+	}
+
+	testCases := map[string]string{
+		"foo.bar":        "foo.bar",
+		"www.foo.bar":    "foo.bar",
+		"app.foo.bar":    "*.foo.bar",
+		"v1.api.foo.bar": "*.api.foo.bar",
+		"bar.baz":        defServerName,
+	}
+
+	for host, expected := range testCases {
+		if server := matchServer(servers, host); server == nil || server.Hostname != expected {
+			t.Errorf("expected server %q for host %q but got %+v", expected, host, server)
+		}
+	}
+}
+
+func TestMatchLocation(t *testing.T) {
+	ing := regressionIngress("app", "1")
+	server := &ingress.Server{
+		Hostname: "foo.bar",
+		Locations: []*ingress.Location{
+			regressionLocation("/api/", networking.PathTypePrefix, ing),
+			regressionLocation("/api", networking.PathTypeExact, ing),
+			regressionLocation("/", networking.PathTypePrefix, ing),
+			regressionLocation("/a", networking.PathTypeImplementationSpecific, ing),
+		},
+	}
+
+	testCases := map[string]string{
+		"/api":      "/api",
+		"/api/":     "/api/",
+		"/api/v1":   "/api/",
+		"/apiv1":    "/a",
+		"/products": "/",
+	}
+
+	for path, expected := range testCases {
+		if location := matchLocation(server, path); location == nil || location.Path != expected {
+			t.Errorf("expected location %q for path %q but got %+v", expected, path, location)
+		}
+	}
+
+	server.Locations[0].Rewrite = rewrite.Config{UseRegex: true}
+	server.Locations[0].Path = "/API/v[0-9]+"
+	if location := matchLocation(server, "/api/v2/users"); location == nil || location.Path != "/API/v[0-9]+" {
+		t.Errorf("expected the case insensitive regular expression to match but got %+v", location)
+	}
+	if location := matchLocation(server, "/api/v"); location == nil || location.Path != "/api" {
+		t.Errorf("expected locations to be evaluated as regular expressions in order but got %+v", location)
+	}
+}
+
+func TestRouteRegressions(t *testing.T) {
+	app := regressionIngress("app", "1")
+	api := regressionIngress("api", "1")
+
+	running := []*ingress.Server{
+		{Hostname: defServerName, Locations: []*ingress.Location{defaultBackendLocation()}},
+		{Hostname: "foo.bar", Locations: []*ingress.Location{
+			regressionLocation("/app/", networking.PathTypePrefix, app),
+			regressionLocation("/api/", networking.PathTypePrefix, api),
+			defaultBackendLocation(),
+		}},
+	}
+
+	samples := []collectors.RequestSample{
+		{Method: "GET", Host: "foo.bar", Path: "/app/index.html"},
+		{Method: "GET", Host: "foo.bar", Path: "/api/users"},
+		{Method: "GET", Host: "foo.bar", Path: "/unknown"},
+	}
+
+	testCases := map[string]struct {
+		updated  []*ingress.Server
+		ings     []*ingress.Ingress
+		expected []string
+	}{
+		"unchanged routes": {
+			running,
+			[]*ingress.Ingress{app, api},
+			[]string{},
+		},
+		"server removed": {
+			running[:1],
+			[]*ingress.Ingress{app, api},
+			[]string{"GET foo.bar/app/index.html (Ingress default/app)", "GET foo.bar/api/users (Ingress default/api)"},
+		},
+		"path moved to another Ingress": {
+			[]*ingress.Server{
+				running[0],
+				{Hostname: "foo.bar", Locations: []*ingress.Location{
+					regressionLocation("/app/", networking.PathTypePrefix, api),
+					defaultBackendLocation(),
+				}},
+			},
+			[]*ingress.Ingress{app, api},
+			[]string{"GET foo.bar/api/users (Ingress default/api)"},
+		},
+		"Ingress updated": {
+			running[:1],
+			[]*ingress.Ingress{app, regressionIngress("api", "2")},
+			[]string{"GET foo.bar/app/index.html (Ingress default/app)"},
+		},
+		"Ingress deleted": {
+			running[:1],
+			[]*ingress.Ingress{api},
+			[]string{"GET foo.bar/api/users (Ingress default/api)"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			regressions := routeRegressions(running, tc.updated, tc.ings, samples)
+
+			if len(regressions) != len(tc.expected) {
+				t.Fatalf("expected %d regressions but got %v", len(tc.expected), regressions)
+			}
+			for i, regression := range regressions {
+				if regression.String() != tc.expected[i] {
+					t.Errorf("expected regression %q but got %q", tc.expected[i], regression.String())
+				}
+			}
+		})
+	}
+}
+
+type sampledCollector struct {
+	metric.DummyCollector
+	samples []collectors.RequestSample
+}
+
+func (sc sampledCollector) RequestSamples() []collectors.RequestSample {
+	return sc.samples
+}
+
+func TestCheckRouteRegressions(t *testing.T) {
+	app := regressionIngress("app", "1")
+	running := []*ingress.Server{
+		{Hostname: defServerName, Locations: []*ingress.Location{defaultBackendLocation()}},
+		{Hostname: "foo.bar", Locations: []*ingress.Location{regressionLocation("/", networking.PathTypePrefix, app)}},
+	}
+
+	n := &NGINXController{
+		cfg:             &Configuration{EnableRouteRegressionCheck: true, RouteRegressionTimeout: time.Minute},
+		recorder:        record.NewFakeRecorder(10),
+		runningConfig:   &ingress.Configuration{Servers: running},
+		metricCollector: sampledCollector{samples: []collectors.RequestSample{{Method: "GET", Host: "foo.bar", Path: "/"}}},
+	}
+	ings := []*ingress.Ingress{app}
+	pcfg := &ingress.Configuration{Servers: running[:1]}
+
+	if err := n.checkRouteRegressions(ings, pcfg); err == nil {
+		t.Fatalf("expected the reload to be held")
+	}
+	if n.routeRegressionSince.IsZero() {
+		t.Fatalf("expected the start of the route regressions to be recorded")
+	}
+
+	n.routeRegressionSince = time.Now().Add(-2 * time.Minute)
+	if err := n.checkRouteRegressions(ings, pcfg); err != nil {
+		t.Errorf("expected the reload to be applied once the check expires but got %v", err)
+	}
+
+	n.cfg.RouteRegressionTimeout = 0
+	n.routeRegressionSince = time.Now().Add(-time.Hour)
+	if err := n.checkRouteRegressions(ings, pcfg); err == nil {
+		t.Errorf("expected the reload to be held without timeout")
+	}
+
+	if err := n.checkRouteRegressions(ings, &ingress.Configuration{Servers: running}); err != nil || !n.routeRegressionSince.IsZero() {
+		t.Errorf("expected no route regression but got %v", err)
+	}
+}
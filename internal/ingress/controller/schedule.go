@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// scheduledIngresses returns the Ingresses applied at a time, without the
+// ones applied later or expired with the apply-at and expire-at annotations,
+// and the next time one of the Ingresses is applied or expires
+func scheduledIngresses(ings []*ingress.Ingress, now time.Time) (active []*ingress.Ingress, next time.Time) {
+	active = make([]*ingress.Ingress, 0, len(ings))
+	for _, ing := range ings {
+		if ing.ParsedAnnotations == nil {
+			active = append(active, ing)
+			continue
+		}
+
+		schedule := ing.ParsedAnnotations.Schedule
+		if change, ok := schedule.NextChange(now); ok && (next.IsZero() || change.Before(next)) {
+			next = change
+		}
+
+		if !schedule.Active(now) {
+			klog.V(3).Infof("Ignoring Ingress %q: it is applied from %v until %v", k8s.MetaNamespaceKey(ing), schedule.ApplyAt, schedule.ExpireAt)
+			continue
+		}
+		active = append(active, ing)
+	}
+	return active, next
+}
+
+// activeIngresses returns the Ingresses applied now and syncs the
+// configuration again when the next scheduled Ingress is applied or expires
+func (n *NGINXController) activeIngresses(ings []*ingress.Ingress) []*ingress.Ingress {
+	active, next := scheduledIngresses(ings, time.Now())
+
+	n.scheduleChangeLock.Lock()
+	defer n.scheduleChangeLock.Unlock()
+
+	if n.scheduleChange != nil {
+		n.scheduleChange.Stop()
+		n.scheduleChange = nil
+	}
+
+	if !next.IsZero() {
+		n.scheduleChange = time.AfterFunc(time.Until(next), func() {
+			n.syncQueue.EnqueueTask(task.GetDummyObject("scheduled-ingress-change"))
+		})
+	}
+
+	return active
+}
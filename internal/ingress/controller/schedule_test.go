@@ -0,0 +1,61 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/schedule"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestScheduledIngresses(t *testing.T) {
+	now := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	scheduled := func(name string, config schedule.Config) *ingress.Ingress {
+		return &ingress.Ingress{
+			Ingress:           networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}},
+			ParsedAnnotations: &annotations.Ingress{Schedule: config},
+		}
+	}
+
+	always := scheduled("always", schedule.Config{})
+	applied := scheduled("applied", schedule.Config{ApplyAt: now.Add(-time.Hour), ExpireAt: now.Add(2 * time.Hour)})
+	later := scheduled("later", schedule.Config{ApplyAt: now.Add(time.Hour)})
+	expired := scheduled("expired", schedule.Config{ExpireAt: now})
+	withoutAnnotations := &ingress.Ingress{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "parsed"}}}
+
+	active, next := scheduledIngresses([]*ingress.Ingress{always, applied, later, expired, withoutAnnotations}, now)
+
+	expected := []*ingress.Ingress{always, applied, withoutAnnotations}
+	if !reflect.DeepEqual(active, expected) {
+		t.Errorf("expected the active ingresses %v but returned %v", expected, active)
+	}
+	if !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the next change at %v but returned %v", now.Add(time.Hour), next)
+	}
+
+	_, next = scheduledIngresses([]*ingress.Ingress{always, expired}, now)
+	if !next.IsZero() {
+		t.Errorf("expected no next change but returned %v", next)
+	}
+}
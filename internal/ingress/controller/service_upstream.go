@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancetuning"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/serviceupstream"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/slowstart"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// serviceUpstreamIncompatibilities returns the annotations of an Ingress that
+// balance the requests between the Endpoints of its Services. They have no
+// effect when kube-proxy balances the connections to the ClusterIP.
+func serviceUpstreamIncompatibilities(anns *annotations.Ingress) []string {
+	if anns == nil {
+		return nil
+	}
+
+	var incompatible []string
+	if anns.SessionAffinity.Type != "" {
+		incompatible = append(incompatible, "affinity")
+	}
+	if anns.UpstreamHashBy.UpstreamHashBy != "" {
+		incompatible = append(incompatible, "upstream-hash-by")
+	}
+	if anns.LoadBalancing != "" && anns.LoadBalancing != "round_robin" {
+		incompatible = append(incompatible, "load-balance")
+	}
+	if anns.SlowStart.Duration > 0 {
+		incompatible = append(incompatible, "slow-start-duration")
+	}
+	return incompatible
+}
+
+// useServiceUpstream returns true when the upstreams of an Ingress use the
+// ClusterIP of their Service instead of its Endpoints.
+//
+// An Ingress without the service-upstream annotation follows the ConfigMap,
+// unless it uses annotations balancing between the Endpoints. A canary
+// Ingress without the annotation follows its primary Ingress, so the traffic
+// shaping does not change how the backends are reached.
+func useServiceUpstream(ing *ingress.Ingress, ings []*ingress.Ingress) bool {
+	anns := ing.ParsedAnnotations
+	if anns == nil {
+		return false
+	}
+
+	ingKey := k8s.MetaNamespaceKey(ing)
+	if serviceupstream.Explicit(&ing.Ingress) {
+		if anns.ServiceUpstream {
+			dropServiceUpstreamIncompatibilities(anns, ingKey)
+		}
+		return anns.ServiceUpstream
+	}
+
+	enabled := anns.ServiceUpstream
+	if isCanaryIngress(ing) {
+		if primary := canaryPrimaryIngress(ing, ings); primary != nil {
+			enabled = useServiceUpstream(primary, ings)
+		}
+	}
+
+	if enabled {
+		if incompatible := serviceUpstreamIncompatibilities(anns); len(incompatible) > 0 {
+			klog.V(2).Infof("Ingress %q uses %v, the Endpoints of its Services are used instead of their ClusterIP",
+				ingKey, strings.Join(incompatible, ", "))
+			return false
+		}
+	}
+
+	return enabled
+}
+
+// canaryPrimaryIngress returns the first primary Ingress sharing a host and
+// path with a canary Ingress
+func canaryPrimaryIngress(canary *ingress.Ingress, ings []*ingress.Ingress) *ingress.Ingress {
+	hostPaths := ingressHostPaths(&canary.Ingress)
+	for _, other := range ings {
+		if isCanaryIngress(other) {
+			continue
+		}
+		for _, hp := range ingressHostPaths(&other.Ingress) {
+			for _, canaryHP := range hostPaths {
+				if hp == canaryHP {
+					return other
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dropServiceUpstreamIncompatibilities removes the annotations that cannot be
+// used by an Ingress with the service-upstream annotation
+func dropServiceUpstreamIncompatibilities(anns *annotations.Ingress, ingKey string) {
+	incompatible := serviceUpstreamIncompatibilities(anns)
+	if len(incompatible) == 0 {
+		return
+	}
+
+	klog.Warningf("Ingress %q uses service-upstream, ignoring the annotations %v", ingKey, strings.Join(incompatible, ", "))
+	anns.SessionAffinity = sessionaffinity.Config{}
+	anns.UpstreamHashBy = upstreamhashby.Config{}
+	anns.LoadBalancing = ""
+	anns.LoadBalanceTuning = loadbalancetuning.Config{}
+	anns.SlowStart = slowstart.Config{}
+}
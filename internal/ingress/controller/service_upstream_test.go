@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func serviceUpstreamIngress(name string, isCanary, enabled bool, explicit string) *ingress.Ingress {
+	ing := conflictIngress("default", name, "example.com", isCanary)
+	ing.ParsedAnnotations.ServiceUpstream = enabled
+	if explicit != "" {
+		ing.SetAnnotations(map[string]string{parser.GetAnnotationWithPrefix("service-upstream"): explicit})
+	}
+	return ing
+}
+
+func TestUseServiceUpstream(t *testing.T) {
+	withAffinity := func(ing *ingress.Ingress) *ingress.Ingress {
+		ing.ParsedAnnotations.SessionAffinity = sessionaffinity.Config{Type: "cookie"}
+		return ing
+	}
+
+	testCases := []struct {
+		name     string
+		ing      *ingress.Ingress
+		others   []*ingress.Ingress
+		expected bool
+	}{
+		{
+			name: "endpoints by default",
+			ing:  serviceUpstreamIngress("web", false, false, ""),
+		},
+		{
+			name:     "ClusterIP from the ConfigMap",
+			ing:      serviceUpstreamIngress("web", false, true, ""),
+			expected: true,
+		},
+		{
+			name: "endpoints from the annotation",
+			ing:  serviceUpstreamIngress("web", false, false, "false"),
+		},
+		{
+			name: "ConfigMap with affinity",
+			ing:  withAffinity(serviceUpstreamIngress("web", false, true, "")),
+		},
+		{
+			name:     "annotation with affinity",
+			ing:      withAffinity(serviceUpstreamIngress("web", false, true, "true")),
+			expected: true,
+		},
+		{
+			name:     "canary following its primary ingress",
+			ing:      serviceUpstreamIngress("canary", true, false, ""),
+			others:   []*ingress.Ingress{serviceUpstreamIngress("web", false, true, "true")},
+			expected: true,
+		},
+		{
+			name:   "canary with the annotation",
+			ing:    serviceUpstreamIngress("canary", true, false, "false"),
+			others: []*ingress.Ingress{serviceUpstreamIngress("web", false, true, "true")},
+		},
+		{
+			name:   "canary following a primary ingress with affinity",
+			ing:    serviceUpstreamIngress("canary", true, true, ""),
+			others: []*ingress.Ingress{withAffinity(serviceUpstreamIngress("web", false, true, ""))},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ings := append([]*ingress.Ingress{tc.ing}, tc.others...)
+			if got := useServiceUpstream(tc.ing, ings); got != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDropServiceUpstreamIncompatibilities(t *testing.T) {
+	ing := serviceUpstreamIngress("web", false, true, "true")
+	ing.ParsedAnnotations.SessionAffinity = sessionaffinity.Config{Type: "cookie"}
+	ing.ParsedAnnotations.UpstreamHashBy = upstreamhashby.Config{UpstreamHashBy: "$remote_addr"}
+	ing.ParsedAnnotations.LoadBalancing = "ewma"
+
+	incompatible := serviceUpstreamIncompatibilities(ing.ParsedAnnotations)
+	if len(incompatible) != 3 {
+		t.Fatalf("expected 3 incompatible annotations but got %v", incompatible)
+	}
+
+	if !useServiceUpstream(ing, []*ingress.Ingress{ing}) {
+		t.Fatalf("expected the ClusterIP to be used")
+	}
+	if incompatible := serviceUpstreamIncompatibilities(ing.ParsedAnnotations); len(incompatible) != 0 {
+		t.Errorf("expected the incompatible annotations to be dropped but got %v", incompatible)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha1" // #nosec
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
+	"k8s.io/ingress-nginx/internal/net/spiffe"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/util/file"
+)
+
+// svidRetryInterval is the time to wait before reconnecting to the Workload API
+const svidRetryInterval = 5 * time.Second
+
+// svidFiles contains the files of an X.509 SVID obtained from the SPIFFE
+// Workload API
+type svidFiles struct {
+	// PemFileName contains the path to the certificate chain and the key of the SVID
+	PemFileName string
+	// CAFileName contains the path to the CA certificates of its trust domain
+	CAFileName string
+	// SHA contains the SHA1 hash of both files
+	SHA string
+}
+
+// svidSource keeps up to date on disk the SVIDs of the controller, sent by
+// the SPIFFE Workload API each time they are rotated
+type svidSource struct {
+	socket    string
+	directory string
+	// onChange is called when an SVID is added, rotated or removed
+	onChange func()
+
+	mu    sync.RWMutex
+	svids map[string]svidFiles
+}
+
+func newSVIDSource(socket, directory string, onChange func()) *svidSource {
+	return &svidSource{
+		socket:    socket,
+		directory: directory,
+		onChange:  onChange,
+		svids:     make(map[string]svidFiles),
+	}
+}
+
+// Get returns the files of the SVID with the SPIFFE ID id
+func (s *svidSource) Get(id string) (svidFiles, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, ok := s.svids[id]
+	return files, ok
+}
+
+// Run watches the SVIDs until stopCh is closed, reconnecting to the
+// Workload API when the stream fails
+func (s *svidSource) Run(stopCh chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	wait.Until(func() { s.watch(ctx) }, svidRetryInterval, stopCh)
+}
+
+func (s *svidSource) watch(ctx context.Context) {
+	client, err := spiffe.NewClient(s.socket)
+	if err != nil {
+		klog.ErrorS(err, "Error connecting to the SPIFFE Workload API", "socket", s.socket)
+		return
+	}
+	defer client.Close()
+
+	err = client.WatchX509SVIDs(ctx, s.update)
+	if err != nil && ctx.Err() == nil {
+		klog.ErrorS(err, "Error watching the SVIDs of the SPIFFE Workload API", "socket", s.socket)
+	}
+}
+
+// update writes the SVIDs sent by the Workload API to disk, the SVIDs
+// missing from the update are no longer available
+func (s *svidSource) update(svids []*spiffe.X509SVID) {
+	s.mu.RLock()
+	previous := s.svids
+	s.mu.RUnlock()
+
+	current := make(map[string]svidFiles, len(svids))
+	changed := len(svids) != len(previous)
+	for _, svid := range svids {
+		files, err := s.write(svid, previous[svid.ID])
+		if err != nil {
+			klog.ErrorS(err, "Error writing SVID", "spiffeID", svid.ID)
+			if files, ok := previous[svid.ID]; ok {
+				current[svid.ID] = files
+			}
+			continue
+		}
+
+		if files != previous[svid.ID] {
+			changed = true
+		}
+		current[svid.ID] = files
+	}
+
+	s.mu.Lock()
+	s.svids = current
+	s.mu.Unlock()
+
+	if changed {
+		ids := make([]string, 0, len(current))
+		for id := range current {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		klog.InfoS("Updated SPIFFE SVIDs", "spiffeIDs", ids)
+		s.onChange()
+	}
+}
+
+// write replaces the files of the SVID when it differs from the previous version
+func (s *svidSource) write(svid *spiffe.X509SVID, previous svidFiles) (svidFiles, error) {
+	pem := svid.CertificatePEM()
+	bundle := svid.BundlePEM()
+
+	hasher := sha1.New() // #nosec
+	hasher.Write(pem)
+	hasher.Write(bundle)
+	sha := hex.EncodeToString(hasher.Sum(nil))
+	if sha == previous.SHA {
+		return previous, nil
+	}
+
+	hasher = sha1.New() // #nosec
+	hasher.Write([]byte(svid.ID))
+	name := hex.EncodeToString(hasher.Sum(nil))
+
+	files := svidFiles{
+		PemFileName: filepath.Join(s.directory, fmt.Sprintf("spiffe-svid-%v.pem", name)),
+		CAFileName:  filepath.Join(s.directory, fmt.Sprintf("spiffe-bundle-%v.pem", name)),
+		SHA:         sha,
+	}
+
+	if err := writeFileAtomically(s.directory, files.PemFileName, pem, file.ReadWriteByUser); err != nil {
+		return svidFiles{}, err
+	}
+	//nolint:gosec // the bundle only contains public certificates
+	if err := writeFileAtomically(s.directory, files.CAFileName, bundle, 0o644); err != nil {
+		return svidFiles{}, err
+	}
+
+	return files, nil
+}
+
+// writeFileAtomically replaces fileName with data, NGINX never reads a
+// partially written file
+func writeFileAtomically(directory, fileName string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(directory, "spiffe-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// applySVIDs configures the servers and locations using proxy-ssl-spiffe-id
+// with the files of their SVID. The locations are denied while their SVID
+// is not available, their upstream would reject the requests.
+func (n *NGINXController) applySVIDs(servers []*ingress.Server) {
+	for _, server := range servers {
+		if server.ProxySSL.SpiffeID != "" {
+			if err := n.applySVID(&server.ProxySSL); err != nil {
+				klog.Warningf("Server %q: %v", server.Hostname, err)
+			}
+		}
+
+		for _, loc := range server.Locations {
+			if loc.ProxySSL.SpiffeID == "" {
+				continue
+			}
+
+			if err := n.applySVID(&loc.ProxySSL); err != nil && loc.Denied == nil {
+				reason := err.Error()
+				loc.Denied = &reason
+			}
+		}
+	}
+}
+
+func (n *NGINXController) applySVID(cfg *proxyssl.Config) error {
+	if n.svidSource == nil {
+		return fmt.Errorf("SVID %v is not available, the flag --spiffe-workload-api-socket is not set", cfg.SpiffeID)
+	}
+
+	files, ok := n.svidSource.Get(cfg.SpiffeID)
+	if !ok {
+		return fmt.Errorf("SVID %v is not available", cfg.SpiffeID)
+	}
+
+	cfg.PemFileName = files.PemFileName
+	cfg.CAFileName = files.CAFileName
+	cfg.CASHA = files.SHA
+	return nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
+	"k8s.io/ingress-nginx/internal/net/spiffe"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func fakeSVID(t *testing.T, id string) *spiffe.X509SVID {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &spiffe.X509SVID{
+		ID:           id,
+		Certificates: []*x509.Certificate{cert},
+		PrivateKey:   keyDER,
+		Bundle:       []*x509.Certificate{cert},
+	}
+}
+
+func TestSVIDSourceUpdate(t *testing.T) {
+	const id = "spiffe://example.org/ns/ingress-nginx/sa/ingress-nginx"
+
+	changes := 0
+	source := newSVIDSource("", t.TempDir(), func() { changes++ })
+
+	svid := fakeSVID(t, id)
+	source.update([]*spiffe.X509SVID{svid})
+	files, ok := source.Get(id)
+	if !ok {
+		t.Fatalf("expected SVID %v to be available", id)
+	}
+	if changes != 1 {
+		t.Errorf("expected 1 change but got %v", changes)
+	}
+
+	pem, err := os.ReadFile(files.PemFileName)
+	if err != nil {
+		t.Fatalf("unexpected error reading the SVID: %v", err)
+	}
+	if string(pem) != string(svid.CertificatePEM()) {
+		t.Errorf("expected the certificate and key of the SVID in %v", files.PemFileName)
+	}
+	if _, err := os.Stat(files.CAFileName); err != nil {
+		t.Errorf("expected the bundle of the SVID in %v: %v", files.CAFileName, err)
+	}
+
+	source.update([]*spiffe.X509SVID{svid})
+	if changes != 1 {
+		t.Errorf("expected no change for the same SVID but got %v changes", changes)
+	}
+
+	source.update([]*spiffe.X509SVID{fakeSVID(t, id)})
+	rotated, _ := source.Get(id)
+	if changes != 2 || rotated.SHA == files.SHA {
+		t.Errorf("expected the rotation of the SVID to be a change")
+	}
+	if rotated.PemFileName != files.PemFileName {
+		t.Errorf("expected the rotated SVID to replace %v but got %v", files.PemFileName, rotated.PemFileName)
+	}
+
+	source.update([]*spiffe.X509SVID{fakeSVID(t, "spiffe://example.org/other")})
+	if _, ok := source.Get(id); ok || changes != 3 {
+		t.Errorf("expected SVID %v to be removed", id)
+	}
+}
+
+func TestApplySVIDs(t *testing.T) {
+	const id = "spiffe://example.org/ns/ingress-nginx/sa/ingress-nginx"
+
+	newServers := func() []*ingress.Server {
+		return []*ingress.Server{{
+			Hostname: "example.com",
+			ProxySSL: proxyssl.Config{SpiffeID: id},
+			Locations: []*ingress.Location{
+				{Path: "/", ProxySSL: proxyssl.Config{SpiffeID: id}},
+				{Path: "/public"},
+			},
+		}}
+	}
+
+	n := &NGINXController{}
+	servers := newServers()
+	n.applySVIDs(servers)
+	if servers[0].Locations[0].Denied == nil {
+		t.Errorf("expected the location to be denied without SPIFFE Workload API")
+	}
+
+	n.svidSource = newSVIDSource("", t.TempDir(), func() {})
+	servers = newServers()
+	n.applySVIDs(servers)
+	if servers[0].Locations[0].Denied == nil {
+		t.Errorf("expected the location to be denied while the SVID is not available")
+	}
+	if servers[0].Locations[1].Denied != nil {
+		t.Errorf("expected the location without SVID to be allowed")
+	}
+
+	n.svidSource.update([]*spiffe.X509SVID{fakeSVID(t, id)})
+	files, _ := n.svidSource.Get(id)
+	servers = newServers()
+	n.applySVIDs(servers)
+
+	loc := servers[0].Locations[0]
+	if loc.Denied != nil {
+		t.Errorf("expected the location to be allowed but got %v", *loc.Denied)
+	}
+	if loc.ProxySSL.PemFileName != files.PemFileName || loc.ProxySSL.CAFileName != files.CAFileName || loc.ProxySSL.CASHA != files.SHA {
+		t.Errorf("expected the location to use the files of the SVID but got %+v", loc.ProxySSL.AuthSSLCert)
+	}
+	if servers[0].ProxySSL.PemFileName != files.PemFileName {
+		t.Errorf("expected the server to use the files of the SVID but got %+v", servers[0].ProxySSL.AuthSSLCert)
+	}
+}
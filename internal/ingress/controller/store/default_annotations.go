@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+// enforcedDefaultsKey is the suffix of the ConfigMap key listing the default
+// annotations of a namespace that Ingresses are not allowed to override.
+// Annotation names never start with an underscore, so it can not clash.
+const enforcedDefaultsKey = "_enforced"
+
+// NamespaceDefaults contains the annotations merged into every Ingress of a
+// namespace before its annotations are parsed.
+type NamespaceDefaults struct {
+	// Annotations maps the full annotation name to its default value
+	Annotations map[string]string
+	// Enforced contains the annotations an Ingress can not override
+	Enforced sets.Set[string]
+}
+
+// parseNamespaceDefaults reads the default annotations from a ConfigMap.
+// Each key has the form "<namespace>.<annotation>", where the annotation is
+// written without the annotations prefix, e.g. "team-a.ssl-redirect". Since
+// namespace names can not contain dots the first dot is always the separator.
+// The key "<namespace>._enforced" contains a comma separated list of
+// annotations that Ingresses of the namespace can not override.
+func parseNamespaceDefaults(cm *corev1.ConfigMap) map[string]*NamespaceDefaults {
+	defaults := make(map[string]*NamespaceDefaults)
+	if cm == nil {
+		return defaults
+	}
+
+	get := func(ns string) *NamespaceDefaults {
+		if _, ok := defaults[ns]; !ok {
+			defaults[ns] = &NamespaceDefaults{
+				Annotations: make(map[string]string),
+				Enforced:    sets.New[string](),
+			}
+		}
+		return defaults[ns]
+	}
+
+	for key, value := range cm.Data {
+		ns, name, found := strings.Cut(key, ".")
+		if !found || ns == "" || name == "" {
+			klog.Warningf("ignoring default annotation %q: expected the format <namespace>.<annotation>", key)
+			continue
+		}
+
+		if name == enforcedDefaultsKey {
+			for _, ann := range strings.Split(value, ",") {
+				ann = strings.TrimSpace(ann)
+				if ann != "" {
+					get(ns).Enforced.Insert(parser.GetAnnotationWithPrefix(ann))
+				}
+			}
+			continue
+		}
+
+		get(ns).Annotations[parser.GetAnnotationWithPrefix(name)] = value
+	}
+
+	return defaults
+}
+
+// mergeDefaultAnnotations returns the annotations of the Ingress merged with
+// the defaults of its namespace, and the list of default annotations the
+// Ingress overrides.
+func mergeDefaultAnnotations(ing *networkingv1.Ingress, defaults *NamespaceDefaults) (merged map[string]string, overridden []string) {
+	if defaults == nil || len(defaults.Annotations) == 0 {
+		return ing.GetAnnotations(), nil
+	}
+
+	merged = make(map[string]string, len(ing.GetAnnotations())+len(defaults.Annotations))
+	for name, value := range defaults.Annotations {
+		merged[name] = value
+	}
+
+	for name, value := range ing.GetAnnotations() {
+		defValue, isDefault := defaults.Annotations[name]
+		if !isDefault {
+			merged[name] = value
+			continue
+		}
+
+		if value == defValue {
+			continue
+		}
+
+		if defaults.Enforced.Has(name) {
+			klog.Warningf("ignoring annotation %v in ingress %v: the namespace default %q is enforced", name, klog.KObj(ing), defValue)
+			continue
+		}
+
+		merged[name] = value
+		overridden = append(overridden, parser.TrimAnnotationPrefix(name))
+	}
+
+	sort.Strings(overridden)
+	return merged, overridden
+}
+
+// setNamespaceDefaults replaces the default annotations using the content of
+// the given ConfigMap.
+func (s *k8sStore) setNamespaceDefaults(cm *corev1.ConfigMap) {
+	s.namespaceDefaultsMu.Lock()
+	defer s.namespaceDefaultsMu.Unlock()
+
+	s.namespaceDefaults = parseNamespaceDefaults(cm)
+}
+
+// WithNamespaceDefaults returns a copy of the Ingress that also contains the
+// default annotations of its namespace, and the defaults it overrides.
+func (s *k8sStore) WithNamespaceDefaults(ing *networkingv1.Ingress) (*networkingv1.Ingress, []string) {
+	s.namespaceDefaultsMu.RLock()
+	defaults := s.namespaceDefaults[ing.Namespace]
+	s.namespaceDefaultsMu.RUnlock()
+
+	if defaults == nil {
+		return ing, nil
+	}
+
+	merged, overridden := mergeDefaultAnnotations(ing, defaults)
+
+	withDefaults := *ing
+	withDefaults.ObjectMeta.Annotations = merged
+	return &withDefaults, overridden
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+func TestParseNamespaceDefaults(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			"team-a.ssl-redirect":       "true",
+			"team-a.proxy-read-timeout": "120",
+			"team-a._enforced":          "ssl-redirect, ",
+			"team-b.proxy-body-size":    "8m",
+			"invalid":                   "value",
+			".ssl-redirect":             "false",
+		},
+	}
+
+	defaults := parseNamespaceDefaults(cm)
+	if len(defaults) != 2 {
+		t.Fatalf("expected defaults for 2 namespaces but %v returned", len(defaults))
+	}
+
+	teamA := defaults["team-a"]
+	expected := map[string]string{
+		parser.GetAnnotationWithPrefix("ssl-redirect"):       "true",
+		parser.GetAnnotationWithPrefix("proxy-read-timeout"): "120",
+	}
+	if !reflect.DeepEqual(teamA.Annotations, expected) {
+		t.Errorf("expected %v but %v returned", expected, teamA.Annotations)
+	}
+	if teamA.Enforced.Len() != 1 || !teamA.Enforced.Has(parser.GetAnnotationWithPrefix("ssl-redirect")) {
+		t.Errorf("expected only ssl-redirect to be enforced but %v returned", teamA.Enforced.UnsortedList())
+	}
+
+	if len(parseNamespaceDefaults(nil)) != 0 {
+		t.Errorf("expected no defaults from a nil configmap")
+	}
+}
+
+func TestMergeDefaultAnnotations(t *testing.T) {
+	sslRedirect := parser.GetAnnotationWithPrefix("ssl-redirect")
+	readTimeout := parser.GetAnnotationWithPrefix("proxy-read-timeout")
+	bodySize := parser.GetAnnotationWithPrefix("proxy-body-size")
+
+	defaults := parseNamespaceDefaults(&corev1.ConfigMap{
+		Data: map[string]string{
+			"team-a.ssl-redirect":       "true",
+			"team-a.proxy-read-timeout": "120",
+			"team-a._enforced":          "ssl-redirect",
+		},
+	})["team-a"]
+
+	tests := map[string]struct {
+		annotations        map[string]string
+		expected           map[string]string
+		expectedOverridden []string
+	}{
+		"defaults are added": {
+			annotations: map[string]string{bodySize: "1m"},
+			expected:    map[string]string{sslRedirect: "true", readTimeout: "120", bodySize: "1m"},
+		},
+		"same value is not an override": {
+			annotations: map[string]string{readTimeout: "120"},
+			expected:    map[string]string{sslRedirect: "true", readTimeout: "120"},
+		},
+		"ingress overrides a default": {
+			annotations:        map[string]string{readTimeout: "30"},
+			expected:           map[string]string{sslRedirect: "true", readTimeout: "30"},
+			expectedOverridden: []string{"proxy-read-timeout"},
+		},
+		"enforced default is kept": {
+			annotations: map[string]string{sslRedirect: "false"},
+			expected:    map[string]string{sslRedirect: "true", readTimeout: "120"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ing := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "demo",
+					Namespace:   "team-a",
+					Annotations: tc.annotations,
+				},
+			}
+
+			merged, overridden := mergeDefaultAnnotations(ing, defaults)
+			if !reflect.DeepEqual(merged, tc.expected) {
+				t.Errorf("expected %v but %v returned", tc.expected, merged)
+			}
+			if !reflect.DeepEqual(overridden, tc.expectedOverridden) {
+				t.Errorf("expected overridden %v but %v returned", tc.expectedOverridden, overridden)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestDomainCertificates(t *testing.T) {
+	s := &k8sStore{
+		listers:              &Lister{Secret: SecretLister{cache.NewStore(cache.MetaNamespaceKeyFunc)}},
+		sslStore:             NewSSLCertTracker(),
+		syncSecretMu:         &sync.Mutex{},
+		certificateDiscovery: true,
+		domainCertificates:   make(map[string]string),
+		domainCertificatesMu: &sync.RWMutex{},
+	}
+
+	sec := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "certs",
+		Name:      "wildcard",
+		Labels:    map[string]string{DomainCertificateLabel: "Example.com"},
+	}}
+	cert := &ingress.SSLCert{Namespace: "certs", Name: "wildcard"}
+	s.sslStore.Add("certs/wildcard", cert)
+
+	if !s.syncDomainCertificate(sec) {
+		t.Errorf("expected the domain of the Secret to change")
+	}
+	if s.syncDomainCertificate(sec) {
+		t.Errorf("expected the domain of the Secret not to change")
+	}
+
+	certs := s.ListDomainSSLCerts()
+	if len(certs) != 1 || len(certs["example.com"]) != 1 || certs["example.com"][0] != cert {
+		t.Errorf("expected the certificate of example.com but returned %v", certs)
+	}
+
+	sec.Labels = nil
+	if !s.syncDomainCertificate(sec) {
+		t.Errorf("expected the Secret not to be labeled anymore")
+	}
+	if certs := s.ListDomainSSLCerts(); len(certs) != 0 {
+		t.Errorf("expected no certificate but returned %v", certs)
+	}
+	if s.removeDomainCertificate("certs/wildcard") {
+		t.Errorf("expected the Secret not to be tracked anymore")
+	}
+
+	s.certificateDiscovery = false
+	sec.Labels = map[string]string{DomainCertificateLabel: "example.com"}
+	if s.syncDomainCertificate(sec) || len(s.ListDomainSSLCerts()) != 0 {
+		t.Errorf("expected the labeled Secrets to be ignored without certificate discovery")
+	}
+}
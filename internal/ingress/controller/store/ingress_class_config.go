@@ -0,0 +1,291 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// Keys of the ConfigMap of an IngressClass that are not global configuration
+// overrides.
+const (
+	classHTTPPortKey             = "http-port"
+	classHTTPSPortKey            = "https-port"
+	classPublishServiceKey       = "publish-service"
+	classPublishStatusAddressKey = "publish-status-address"
+)
+
+// IngressClassConfig contains the configuration of an IngressClass whose
+// parameters reference a ConfigMap.
+type IngressClassConfig struct {
+	// ConfigMap is the namespace and name of the ConfigMap of the class
+	ConfigMap string
+	// Backend contains the global configuration with the overrides of the
+	// class, used as the defaults of the annotations of its Ingresses
+	Backend defaults.Backend
+	// ListenPorts contains the ports the servers of the class listen on,
+	// nil when they use the ports of the controller
+	ListenPorts *ingress.ClassListenPorts
+	// PublishService is the namespace and name of the Service whose
+	// addresses are reported in the status of the Ingresses of the class
+	PublishService string
+	// PublishStatusAddress contains the comma separated addresses reported
+	// in the status of the Ingresses of the class
+	PublishStatusAddress string
+
+	// extractor parses the annotations using the defaults of the class
+	extractor annotations.Extractor
+}
+
+// classParametersConfigMap returns the namespace and name of the ConfigMap
+// referenced by the parameters of an IngressClass, or an empty string when
+// it has no parameters.
+func classParametersConfigMap(ic *networkingv1.IngressClass) (string, error) {
+	params := ic.Spec.Parameters
+	if params == nil {
+		return "", nil
+	}
+
+	if params.APIGroup != nil && *params.APIGroup != "" {
+		return "", fmt.Errorf("unsupported parameters API group %q", *params.APIGroup)
+	}
+	if params.Kind != "ConfigMap" {
+		return "", fmt.Errorf("unsupported parameters kind %q", params.Kind)
+	}
+	if params.Namespace == nil || *params.Namespace == "" {
+		return "", fmt.Errorf("the parameters of kind ConfigMap require a namespace")
+	}
+
+	return fmt.Sprintf("%v/%v", *params.Namespace, params.Name), nil
+}
+
+// parseIngressClassConfig returns the configuration of an IngressClass from
+// its ConfigMap. The other keys of the ConfigMap override the keys of the
+// global configuration.
+func parseIngressClassConfig(global map[string]string, cm *corev1.ConfigMap) (*IngressClassConfig, error) {
+	cfg := &IngressClassConfig{
+		ConfigMap: k8s.MetaNamespaceKey(cm),
+	}
+
+	data := make(map[string]string, len(global)+len(cm.Data))
+	for key, value := range global {
+		data[key] = value
+	}
+
+	ports := &ingress.ClassListenPorts{}
+	for key, value := range cm.Data {
+		var err error
+		switch key {
+		case classHTTPPortKey:
+			ports.HTTP, err = parseClassPort(key, value)
+		case classHTTPSPortKey:
+			ports.HTTPS, err = parseClassPort(key, value)
+		case classPublishServiceKey:
+			if _, _, err = k8s.ParseNameNS(value); err != nil {
+				err = fmt.Errorf("invalid %v %q: %w", key, value, err)
+			}
+			cfg.PublishService = value
+		case classPublishStatusAddressKey:
+			cfg.PublishStatusAddress = value
+		default:
+			data[key] = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (ports.HTTP == 0) != (ports.HTTPS == 0) {
+		return nil, fmt.Errorf("%v and %v must be set together", classHTTPPortKey, classHTTPSPortKey)
+	}
+	if ports.HTTP != 0 {
+		if ports.HTTP == ports.HTTPS {
+			return nil, fmt.Errorf("%v and %v must be different", classHTTPPortKey, classHTTPSPortKey)
+		}
+		cfg.ListenPorts = ports
+	}
+
+	cfg.Backend = ngx_template.ReadConfig(data).Backend
+	return cfg, nil
+}
+
+func parseClassPort(key, value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid %v %q: expected a port number", key, value)
+	}
+	return port, nil
+}
+
+// classResolver resolves the default backend of the Ingresses of a class
+// with the configuration of the class.
+type classResolver struct {
+	*k8sStore
+	backend defaults.Backend
+}
+
+// GetDefaultBackend returns the default backend of the class
+func (r classResolver) GetDefaultBackend() defaults.Backend {
+	return r.backend
+}
+
+// syncIngressClassConfigs reads the configuration of the IngressClasses
+// whose parameters reference a ConfigMap. The classes with an invalid
+// configuration use the global configuration.
+func (s *k8sStore) syncIngressClassConfigs() {
+	configs := make(map[string]*IngressClassConfig)
+
+	if s.listers.IngressClass.Store != nil {
+		s.backendConfigMu.RLock()
+		global := s.backendConfigData
+		s.backendConfigMu.RUnlock()
+
+		classes := []*networkingv1.IngressClass{}
+		for _, obj := range s.listers.IngressClass.List() {
+			if ic, ok := obj.(*networkingv1.IngressClass); ok {
+				classes = append(classes, ic)
+			}
+		}
+		// the first class using a port keeps it
+		sort.Slice(classes, func(i, j int) bool {
+			return classes[i].Name < classes[j].Name
+		})
+		portClasses := map[int]string{}
+
+		for _, ic := range classes {
+			key, err := classParametersConfigMap(ic)
+			if err != nil {
+				klog.Warningf("ignoring the parameters of IngressClass %v: %v", ic.Name, err)
+				continue
+			}
+			if key == "" {
+				continue
+			}
+
+			cm, err := s.GetConfigMap(key)
+			if err != nil {
+				klog.Warningf("ignoring the parameters of IngressClass %v: error reading ConfigMap %v: %v", ic.Name, key, err)
+				continue
+			}
+
+			cfg, err := parseIngressClassConfig(global, cm)
+			if err != nil {
+				klog.Warningf("ignoring the parameters of IngressClass %v: invalid ConfigMap %v: %v", ic.Name, key, err)
+				continue
+			}
+			if cfg.ListenPorts != nil {
+				if class, ok := usedClassPort(portClasses, cfg.ListenPorts); ok {
+					klog.Warningf("ignoring the parameters of IngressClass %v: the ports of ConfigMap %v are used by IngressClass %v", ic.Name, key, class)
+					continue
+				}
+				portClasses[cfg.ListenPorts.HTTP] = ic.Name
+				portClasses[cfg.ListenPorts.HTTPS] = ic.Name
+			}
+			cfg.extractor = annotations.NewAnnotationExtractor(classResolver{k8sStore: s, backend: cfg.Backend})
+			configs[ic.Name] = cfg
+		}
+	}
+
+	s.classConfigsMu.Lock()
+	defer s.classConfigsMu.Unlock()
+
+	s.classConfigs = configs
+}
+
+// usedClassPort returns the class already using one of the ports
+func usedClassPort(portClasses map[int]string, ports *ingress.ClassListenPorts) (string, bool) {
+	for _, port := range []int{ports.HTTP, ports.HTTPS} {
+		if class, ok := portClasses[port]; ok {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// isIngressClassConfigMap returns true when the ConfigMap is referenced by
+// the parameters of an IngressClass.
+func (s *k8sStore) isIngressClassConfigMap(key string) bool {
+	if s.listers.IngressClass.Store == nil {
+		return false
+	}
+
+	for _, obj := range s.listers.IngressClass.List() {
+		ic, ok := obj.(*networkingv1.IngressClass)
+		if !ok {
+			continue
+		}
+		if cmKey, err := classParametersConfigMap(ic); err == nil && cmKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIngressClassConfig returns the configuration of an IngressClass, or
+// nil when the class uses the global configuration.
+func (s *k8sStore) GetIngressClassConfig(class string) *IngressClassConfig {
+	s.classConfigsMu.RLock()
+	defer s.classConfigsMu.RUnlock()
+
+	return s.classConfigs[class]
+}
+
+// ListIngressClassConfigs returns the configuration of the IngressClasses
+// whose parameters reference a ConfigMap, by class.
+func (s *k8sStore) ListIngressClassConfigs() map[string]*IngressClassConfig {
+	s.classConfigsMu.RLock()
+	defer s.classConfigsMu.RUnlock()
+
+	configs := make(map[string]*IngressClassConfig, len(s.classConfigs))
+	for class, cfg := range s.classConfigs {
+		configs[class] = cfg
+	}
+	return configs
+}
+
+// annotationExtractor returns the extractor of the annotations of the
+// Ingresses of a class.
+func (s *k8sStore) annotationExtractor(class string) annotations.Extractor {
+	if cfg := s.GetIngressClassConfig(class); cfg != nil {
+		return cfg.extractor
+	}
+	return s.annotations
+}
+
+// syncIngressesOfClass parses again the annotations of the Ingresses of a
+// class after its configuration changes.
+func (s *k8sStore) syncIngressesOfClass(class string) {
+	for _, obj := range s.listers.IngressWithAnnotation.List() {
+		ing, ok := obj.(*ingress.Ingress)
+		if !ok || ing.IngressClass != class {
+			continue
+		}
+		s.syncIngress(&ing.Ingress)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func TestClassParametersConfigMap(t *testing.T) {
+	namespace := "ingress-nginx"
+	apiGroup := "example.com"
+
+	testCases := []struct {
+		name        string
+		params      *networkingv1.IngressClassParametersReference
+		expected    string
+		expectedErr bool
+	}{
+		{"no parameters", nil, "", false},
+		{"configmap", &networkingv1.IngressClassParametersReference{Kind: "ConfigMap", Name: "internal", Namespace: &namespace}, "ingress-nginx/internal", false},
+		{"without namespace", &networkingv1.IngressClassParametersReference{Kind: "ConfigMap", Name: "internal"}, "", true},
+		{"other kind", &networkingv1.IngressClassParametersReference{Kind: "Secret", Name: "internal", Namespace: &namespace}, "", true},
+		{"other api group", &networkingv1.IngressClassParametersReference{APIGroup: &apiGroup, Kind: "ConfigMap", Name: "internal", Namespace: &namespace}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ic := &networkingv1.IngressClass{Spec: networkingv1.IngressClassSpec{Parameters: tc.params}}
+			key, err := classParametersConfigMap(ic)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %v but returned %v", tc.expectedErr, err)
+			}
+			if key != tc.expected {
+				t.Errorf("expected %q but returned %q", tc.expected, key)
+			}
+		})
+	}
+}
+
+func TestParseIngressClassConfig(t *testing.T) {
+	global := map[string]string{
+		"proxy-body-size":    "1m",
+		"proxy-read-timeout": "120",
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-nginx", Name: "internal"},
+		Data: map[string]string{
+			"proxy-body-size":        "8m",
+			"http-port":              "8080",
+			"https-port":             "8443",
+			"publish-service":        "ingress-nginx/internal",
+			"publish-status-address": "10.0.0.1",
+		},
+	}
+
+	cfg, err := parseIngressClassConfig(global, cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ConfigMap != "ingress-nginx/internal" {
+		t.Errorf("unexpected ConfigMap %q", cfg.ConfigMap)
+	}
+	if cfg.Backend.ProxyBodySize != "8m" || cfg.Backend.ProxyReadTimeout != 120 {
+		t.Errorf("expected the class to override the global configuration but returned %+v", cfg.Backend)
+	}
+	if !cfg.ListenPorts.Equal(&ingress.ClassListenPorts{HTTP: 8080, HTTPS: 8443}) {
+		t.Errorf("unexpected listen ports %+v", cfg.ListenPorts)
+	}
+	if cfg.PublishService != "ingress-nginx/internal" || cfg.PublishStatusAddress != "10.0.0.1" {
+		t.Errorf("unexpected publish target %q %q", cfg.PublishService, cfg.PublishStatusAddress)
+	}
+
+	cfg, err = parseIngressClassConfig(global, &corev1.ConfigMap{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ListenPorts != nil || cfg.Backend.ProxyBodySize != "1m" {
+		t.Errorf("expected the global configuration but returned %+v", cfg)
+	}
+
+	invalid := []map[string]string{
+		{"http-port": "8080"},
+		{"http-port": "8080", "https-port": "8080"},
+		{"http-port": "http", "https-port": "8443"},
+		{"http-port": "0", "https-port": "8443"},
+		{"publish-service": "internal"},
+	}
+	for _, data := range invalid {
+		if _, err := parseIngressClassConfig(global, &corev1.ConfigMap{Data: data}); err == nil {
+			t.Errorf("expected an error parsing %v", data)
+		}
+	}
+}
+
+func TestSyncIngressClassConfigsDuplicatePorts(t *testing.T) {
+	s := newStore()
+	s.listers.ConfigMap = ConfigMapLister{cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	s.classConfigsMu = &sync.RWMutex{}
+
+	namespace := "ingress-nginx"
+	ports := map[string]string{"internal": "8080", "private": "8080", "public": "9080"}
+	for name, httpPort := range ports {
+		ic := &networkingv1.IngressClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: networkingv1.IngressClassSpec{
+				Parameters: &networkingv1.IngressClassParametersReference{Kind: "ConfigMap", Name: name, Namespace: &namespace},
+			},
+		}
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string]string{classHTTPPortKey: httpPort, classHTTPSPortKey: "8443"},
+		}
+		if name == "public" {
+			cm.Data[classHTTPSPortKey] = "9443"
+		}
+		if err := s.listers.IngressClass.Add(ic); err != nil {
+			t.Fatalf("unexpected error adding the IngressClass: %v", err)
+		}
+		if err := s.listers.ConfigMap.Add(cm); err != nil {
+			t.Fatalf("unexpected error adding the ConfigMap: %v", err)
+		}
+	}
+
+	s.syncIngressClassConfigs()
+
+	configs := s.ListIngressClassConfigs()
+	if configs["internal"] == nil || configs["public"] == nil {
+		t.Errorf("expected the configuration of the classes internal and public but got %v", configs)
+	}
+	if configs["private"] != nil {
+		t.Errorf("expected the configuration of the class private using the ports of internal to be ignored")
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	klog "k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/net/ssl"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// offlineStore is a Storer serving objects read from files rather than
+// watched in the API server, to interpret Ingresses without a cluster
+type offlineStore struct {
+	backendConfig  ngx_config.Configuration
+	icConfig       *ingressclass.Configuration
+	ingresses      []*networkingv1.Ingress
+	ingressClasses map[string]*networkingv1.IngressClass
+	services       map[string]*corev1.Service
+	secrets        map[string]*corev1.Secret
+	configMaps     map[string]*corev1.ConfigMap
+	annotations    annotations.Extractor
+}
+
+// NewOffline returns a Storer serving the Ingresses, IngressClasses,
+// Services, Secrets and ConfigMaps of objects. The configuration of NGINX
+// is read from the ConfigMap configmap, namespace/name, when it is one of
+// the objects. The Services have no endpoints, and the certificates of the
+// Secrets are not written to disk.
+func NewOffline(objects []k8sruntime.Object, configmap string, icConfig *ingressclass.Configuration) Storer {
+	s := &offlineStore{
+		backendConfig:  ngx_config.NewDefault(),
+		icConfig:       icConfig,
+		ingressClasses: make(map[string]*networkingv1.IngressClass),
+		services:       make(map[string]*corev1.Service),
+		secrets:        make(map[string]*corev1.Secret),
+		configMaps:     make(map[string]*corev1.ConfigMap),
+	}
+
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *networkingv1.Ingress:
+			s.ingresses = append(s.ingresses, o)
+		case *networkingv1.IngressClass:
+			s.ingressClasses[o.Name] = o
+		case *corev1.Service:
+			s.services[k8s.MetaNamespaceKey(o)] = o
+		case *corev1.Secret:
+			s.secrets[k8s.MetaNamespaceKey(o)] = o
+		case *corev1.ConfigMap:
+			s.configMaps[k8s.MetaNamespaceKey(o)] = o
+		}
+	}
+
+	if cm, ok := s.configMaps[configmap]; ok {
+		s.backendConfig = ngx_template.ReadConfig(cm.Data)
+	}
+	s.annotations = annotations.NewAnnotationExtractor(s)
+
+	return s
+}
+
+func (s *offlineStore) GetBackendConfiguration() ngx_config.Configuration {
+	return s.backendConfig
+}
+
+func (s *offlineStore) GetSecurityConfiguration() defaults.SecurityConfiguration {
+	return defaults.SecurityConfiguration{
+		AllowCrossNamespaceResources: s.backendConfig.AllowCrossNamespaceResources,
+		AnnotationsRiskLevel:         s.backendConfig.AnnotationsRiskLevel,
+	}
+}
+
+func (s *offlineStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
+	cm, ok := s.configMaps[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return cm, nil
+}
+
+func (s *offlineStore) GetSecret(key string) (*corev1.Secret, error) {
+	secret, ok := s.secrets[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return secret, nil
+}
+
+func (s *offlineStore) GetService(key string) (*corev1.Service, error) {
+	svc, ok := s.services[key]
+	if !ok {
+		return nil, NotExistsError(key)
+	}
+	return svc, nil
+}
+
+func (s *offlineStore) GetServiceEndpointsSlices(_ string) ([]*discoveryv1.EndpointSlice, error) {
+	return []*discoveryv1.EndpointSlice{}, nil
+}
+
+// ListIngresses returns the Ingresses handled by the controller with their
+// parsed annotations, sorted like the ones of the store watching the API
+// server. The Ingresses with annotations the controller rejects are left out.
+func (s *offlineStore) ListIngresses() []*ingress.Ingress {
+	ingresses := make([]*ingress.Ingress, 0, len(s.ingresses))
+	for _, ing := range s.ingresses {
+		class, err := s.GetIngressClass(ing, s.icConfig)
+		if err != nil {
+			continue
+		}
+
+		copyIng := ing.DeepCopy()
+		for ri, rule := range copyIng.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for pi, path := range rule.HTTP.Paths {
+				if path.Path == "" {
+					copyIng.Spec.Rules[ri].HTTP.Paths[pi].Path = "/"
+				}
+			}
+		}
+		k8s.SetDefaultNGINXPathType(copyIng)
+
+		if s.backendConfig.AnnotationValueWordBlocklist != "" {
+			if err := checkBadAnnotationValue(copyIng.Annotations, s.backendConfig.AnnotationValueWordBlocklist); err != nil {
+				klog.Warningf("skipping ingress %s: %s", k8s.MetaNamespaceKey(ing), err)
+				continue
+			}
+		}
+
+		parsed, err := s.annotations.Extract(ing)
+		if err != nil {
+			klog.Error(err)
+			continue
+		}
+
+		ingresses = append(ingresses, &ingress.Ingress{
+			Ingress:           *copyIng,
+			ParsedAnnotations: parsed,
+			IngressClass:      class,
+		})
+	}
+
+	sortIngressSlice(ingresses)
+	return ingresses
+}
+
+func (s *offlineStore) ListTCPRoutes() []*v1alpha1.TCPRoute {
+	return nil
+}
+
+func (s *offlineStore) ListUDPRoutes() []*v1alpha1.UDPRoute {
+	return nil
+}
+
+// GetLocalSSLCert returns the certificate of the Secret like
+// getPemCertificate, without writing the certificate and the CA to disk
+func (s *offlineStore) GetLocalSSLCert(secretName string) (*ingress.SSLCert, error) {
+	secret, err := s.GetSecret(secretName)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, okcert := secret.Data[corev1.TLSCertKey]
+	key, okkey := secret.Data[corev1.TLSPrivateKeyKey]
+	ca := secret.Data["ca.crt"]
+
+	var sslCert *ingress.SSLCert
+	switch {
+	case okcert && okkey:
+		sslCert, err = ssl.CreateSSLCert(cert, key, string(secret.UID))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error creating SSL Cert: %v", err)
+		}
+
+		if len(ca) > 0 {
+			sslCert.CACertificate, err = ssl.CheckCACert(ca)
+			if err != nil {
+				return nil, fmt.Errorf("parsing CA certificate: %v", err)
+			}
+		}
+	case len(ca) > 0:
+		sslCert, err = ssl.CreateCACert(ca)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error creating SSL Cert: %v", err)
+		}
+	default:
+		if secret.Data["auth"] != nil {
+			return nil, ErrSecretForAuth
+		}
+		return nil, fmt.Errorf("secret %q contains no keypair or CA certificate", secretName)
+	}
+
+	sslCert.Name = secret.Name
+	sslCert.Namespace = secret.Namespace
+	return sslCert, nil
+}
+
+func (s *offlineStore) ListLocalSSLCerts() []*ingress.SSLCert {
+	keys := make([]string, 0, len(s.secrets))
+	for key := range s.secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var certs []*ingress.SSLCert
+	for _, key := range keys {
+		if cert, err := s.GetLocalSSLCert(key); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+func (s *offlineStore) ListDomainSSLCerts() map[string][]*ingress.SSLCert {
+	return map[string][]*ingress.SSLCert{}
+}
+
+// WithNamespaceDefaults returns the Ingress, the default annotations are
+// not read from files
+func (s *offlineStore) WithNamespaceDefaults(ing *networkingv1.Ingress) (*networkingv1.Ingress, []string) {
+	return ing, nil
+}
+
+func (s *offlineStore) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
+	cert, err := s.GetLocalSSLCert(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolver.AuthSSLCert{
+		Secret: name,
+		CASHA:  cert.CASHA,
+	}, nil
+}
+
+func (s *offlineStore) GetDefaultBackend() defaults.Backend {
+	return s.backendConfig.Backend
+}
+
+func (s *offlineStore) Run(_ chan struct{}) {}
+
+// GetIngressClass returns the class of the Ingress like the store watching
+// the API server, the classes of the controller being the IngressClasses of
+// the objects whose controller is the one of icConfig, and the class of the
+// annotation of icConfig
+func (s *offlineStore) GetIngressClass(ing *networkingv1.Ingress, icConfig *ingressclass.Configuration) (string, error) {
+	if !icConfig.IgnoreIngressClass && ing.Spec.IngressClassName != nil {
+		className := *ing.Spec.IngressClassName
+		if iclass, ok := s.ingressClasses[className]; ok && iclass.Spec.Controller == icConfig.Controller {
+			return className, nil
+		}
+		if className == icConfig.AnnotationValue {
+			return className, nil
+		}
+		return "", fmt.Errorf("IngressClass %q is not one of the controller %v", className, icConfig.Controller)
+	}
+
+	if class, ok := ing.GetAnnotations()[ingressclass.IngressKey]; ok {
+		if class != icConfig.AnnotationValue {
+			return "", fmt.Errorf("ingress class annotation is not equal to the expected by Ingress Controller")
+		}
+		return class, nil
+	}
+
+	if icConfig.WatchWithoutClass {
+		return "_", nil
+	}
+	return "", fmt.Errorf("ingress does not contain a valid IngressClass")
+}
+
+// GetStreamRouteIngressClass returns the class of a TCPRoute or UDPRoute
+// like GetIngressClass, the routes not having a class annotation
+func (s *offlineStore) GetStreamRouteIngressClass(spec *v1alpha1.RouteSpec, icConfig *ingressclass.Configuration) (string, error) {
+	if spec.IngressClassName != nil {
+		className := *spec.IngressClassName
+		if iclass, ok := s.ingressClasses[className]; ok && !icConfig.IgnoreIngressClass && iclass.Spec.Controller == icConfig.Controller {
+			return className, nil
+		}
+		if className == icConfig.AnnotationValue {
+			return className, nil
+		}
+		return "", fmt.Errorf("IngressClass %q is not one of the controller %v", className, icConfig.Controller)
+	}
+
+	if icConfig.WatchWithoutClass {
+		return "_", nil
+	}
+	return "", fmt.Errorf("route does not contain a valid IngressClass")
+}
+
+func (s *offlineStore) GetIngressClassConfig(_ string) *IngressClassConfig {
+	return nil
+}
+
+func (s *offlineStore) ListIngressClassConfigs() map[string]*IngressClassConfig {
+	return nil
+}
@@ -36,6 +36,8 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -58,6 +60,7 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 )
 
 // IngressFilterFunc decides if an Ingress should be omitted or not
@@ -87,12 +90,26 @@ type Storer interface {
 	// ListIngresses returns a list of all Ingresses in the store.
 	ListIngresses() []*ingress.Ingress
 
+	// ListTCPRoutes returns a list of all TCPRoutes in the store.
+	ListTCPRoutes() []*v1alpha1.TCPRoute
+
+	// ListUDPRoutes returns a list of all UDPRoutes in the store.
+	ListUDPRoutes() []*v1alpha1.UDPRoute
+
 	// GetLocalSSLCert returns the local copy of a SSLCert
 	GetLocalSSLCert(name string) (*ingress.SSLCert, error)
 
 	// ListLocalSSLCerts returns the list of local SSLCerts
 	ListLocalSSLCerts() []*ingress.SSLCert
 
+	// WithNamespaceDefaults returns a copy of the Ingress that also contains
+	// the default annotations of its namespace, and the defaults it overrides.
+	WithNamespaceDefaults(ing *networkingv1.Ingress) (*networkingv1.Ingress, []string)
+
+	// ListDomainSSLCerts returns the certificates of the Secrets labeled
+	// with DomainCertificateLabel, by domain
+	ListDomainSSLCerts() map[string][]*ingress.SSLCert
+
 	// GetAuthCertificate resolves a given secret name into an SSL certificate.
 	// The secret must contain 3 keys named:
 	//   ca.crt: contains the certificate chain used for authentication
@@ -106,8 +123,25 @@ type Storer interface {
 
 	// GetIngressClass validates given ingress against ingress class configuration and returns the ingress class.
 	GetIngressClass(ing *networkingv1.Ingress, icConfig *ingressclass.Configuration) (string, error)
+
+	// GetStreamRouteIngressClass validates the TCPRoute or UDPRoute specification against
+	// ingress class configuration and returns the ingress class.
+	GetStreamRouteIngressClass(spec *v1alpha1.RouteSpec, icConfig *ingressclass.Configuration) (string, error)
+
+	// GetIngressClassConfig returns the configuration of an IngressClass from
+	// the ConfigMap referenced by its parameters.
+	GetIngressClassConfig(class string) *IngressClassConfig
+
+	// ListIngressClassConfigs returns the configuration of the IngressClasses
+	// whose parameters reference a ConfigMap, by class.
+	ListIngressClassConfigs() map[string]*IngressClassConfig
 }
 
+// DomainCertificateLabel is the label of the Secrets containing the
+// certificate of a domain, used for the hosts of the domain whose Ingresses
+// do not contain a TLS section when the certificate discovery is enabled
+const DomainCertificateLabel = "cert.ingress.kubernetes.io/domain"
+
 // EventType type of event associated with an informer
 type EventType string
 
@@ -137,6 +171,8 @@ type Informer struct {
 	Secret        cache.SharedIndexInformer
 	ConfigMap     cache.SharedIndexInformer
 	Namespace     cache.SharedIndexInformer
+	TCPRoute      cache.SharedIndexInformer
+	UDPRoute      cache.SharedIndexInformer
 }
 
 // Lister contains object listers (stores).
@@ -149,6 +185,8 @@ type Lister struct {
 	ConfigMap             ConfigMapLister
 	Namespace             NamespaceLister
 	IngressWithAnnotation IngressWithAnnotationsLister
+	TCPRoute              TCPRouteLister
+	UDPRoute              UDPRouteLister
 }
 
 // NotExistsError is returned when an object does not exist in a local store.
@@ -168,6 +206,10 @@ func (i *Informer) Run(stopCh chan struct{}) {
 	}
 	go i.Service.Run(stopCh)
 	go i.ConfigMap.Run(stopCh)
+	if i.TCPRoute != nil {
+		go i.TCPRoute.Run(stopCh)
+		go i.UDPRoute.Run(stopCh)
+	}
 
 	// wait for all involved caches to be synced before processing items
 	// from the queue
@@ -181,6 +223,9 @@ func (i *Informer) Run(stopCh chan struct{}) {
 	if i.IngressClass != nil && !cache.WaitForCacheSync(stopCh, i.IngressClass.HasSynced) {
 		runtime.HandleError(fmt.Errorf("timed out waiting for ingress classcaches to sync"))
 	}
+	if i.TCPRoute != nil && !cache.WaitForCacheSync(stopCh, i.TCPRoute.HasSynced, i.UDPRoute.HasSynced) {
+		runtime.HandleError(fmt.Errorf("timed out waiting for stream route caches to sync"))
+	}
 
 	// when limit controller scope to one namespace, skip sync namespaces at cluster scope
 	if i.Namespace != nil {
@@ -213,6 +258,9 @@ type k8sStore struct {
 	// operation to execute in each OnUpdate invocation
 	backendConfig ngx_config.Configuration
 
+	// backendConfigData contains the data of the configmap
+	backendConfigData map[string]string
+
 	// informer contains the cache Informers
 	informers *Informer
 
@@ -240,6 +288,35 @@ type k8sStore struct {
 	backendConfigMu *sync.RWMutex
 
 	defaultSSLCertificate string
+
+	// namespaceDefaults contains the default annotations of each namespace
+	namespaceDefaults map[string]*NamespaceDefaults
+
+	// namespaceDefaultsMu protects against simultaneous read/write of namespaceDefaults
+	namespaceDefaultsMu *sync.RWMutex
+
+	// watchedNamespace returns true when the namespace matches the namespace selector
+	watchedNamespace func(namespace string) bool
+
+	// icConfig defines how the class of the Ingresses is read
+	icConfig *ingressclass.Configuration
+
+	// classConfigs contains the configuration of the IngressClasses with parameters
+	classConfigs map[string]*IngressClassConfig
+
+	// classConfigsMu protects against simultaneous read/write of classConfigs
+	classConfigsMu *sync.RWMutex
+
+	// certificateDiscovery enables the discovery of the Secrets labeled
+	// with DomainCertificateLabel
+	certificateDiscovery bool
+
+	// domainCertificates contains the domain of the Secrets labeled with
+	// DomainCertificateLabel, by Secret
+	domainCertificates map[string]string
+
+	// domainCertificatesMu protects against simultaneous read/write of domainCertificates
+	domainCertificatesMu *sync.RWMutex
 }
 
 // New creates a new object store to be used in the ingress controller.
@@ -248,14 +325,16 @@ type k8sStore struct {
 func New(
 	namespace string,
 	namespaceSelector labels.Selector,
-	configmap, tcp, udp, defaultSSLCertificate string,
+	configmap, tcp, udp, defaultAnnotations, drainedEndpoints, metricsLabels, reloadFreeze, changeApproval, luaPlugins, defaultSSLCertificate string,
 	resyncPeriod time.Duration,
 	client clientset.Interface,
+	dynamicClient dynamic.Interface,
 	updateCh *channels.RingChannel,
 	disableCatchAll bool,
 	deepInspector bool,
 	icConfig *ingressclass.Configuration,
 	disableSyncEvents bool,
+	certificateDiscovery bool,
 ) Storer {
 	store := &k8sStore{
 		informers:             &Informer{},
@@ -267,6 +346,14 @@ func New(
 		backendConfigMu:       &sync.RWMutex{},
 		secretIngressMap:      NewObjectRefMap(),
 		defaultSSLCertificate: defaultSSLCertificate,
+		namespaceDefaults:     make(map[string]*NamespaceDefaults),
+		namespaceDefaultsMu:   &sync.RWMutex{},
+		icConfig:              icConfig,
+		classConfigs:          make(map[string]*IngressClassConfig),
+		classConfigsMu:        &sync.RWMutex{},
+		certificateDiscovery:  certificateDiscovery,
+		domainCertificates:    make(map[string]string),
+		domainCertificatesMu:  &sync.RWMutex{},
 	}
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -377,6 +464,7 @@ func New(
 
 		return namespaceSelector.Matches(labels.Set(ns.Labels))
 	}
+	store.watchedNamespace = watchedNamespace
 
 	ingDeleteHandler := func(obj interface{}) {
 		ing, ok := toIngress(obj)
@@ -540,6 +628,10 @@ func New(
 				klog.InfoS("error adding ingressclass to store", "ingressclass", klog.KObj(ingressclass), "error", err)
 				return
 			}
+			if ingressclass.Spec.Parameters != nil {
+				store.syncIngressClassConfigs()
+				store.syncIngressesOfClass(ingressclass.Name)
+			}
 
 			updateCh.In() <- Event{
 				Type: CreateEvent,
@@ -560,6 +652,9 @@ func New(
 				klog.InfoS("error removing ingressclass from store", "ingressclass", klog.KObj(ingressclass), "error", err)
 				return
 			}
+			if ingressclass.Spec.Parameters != nil {
+				store.syncIngressClassConfigs()
+			}
 			updateCh.In() <- Event{
 				Type: DeleteEvent,
 				Obj:  obj,
@@ -578,14 +673,15 @@ func New(
 				klog.InfoS("ignoring ingressclass as the spec.controller is not the same of this ingress", "ingressclass", klog.KObj(cic))
 				return
 			}
-			// TODO: In a future we might be interested in parse parameters and use as
-			// current IngressClass for this case, crossing with configmap
+			// the parameters reference the ConfigMap with the configuration of the class
 			if !reflect.DeepEqual(cic.Spec.Parameters, oic.Spec.Parameters) {
 				err := store.listers.IngressClass.Update(cic)
 				if err != nil {
 					klog.InfoS("error updating ingressclass in store", "ingressclass", klog.KObj(cic), "error", err)
 					return
 				}
+				store.syncIngressClassConfigs()
+				store.syncIngressesOfClass(cic.Name)
 				updateCh.In() <- Event{
 					Type: UpdateEvent,
 					Obj:  cur,
@@ -606,6 +702,13 @@ func New(
 				store.syncSecret(store.defaultSSLCertificate)
 			}
 
+			if watchedNamespace(sec.Namespace) && store.syncDomainCertificate(sec) {
+				updateCh.In() <- Event{
+					Type: CreateEvent,
+					Obj:  obj,
+				}
+			}
+
 			// find references in ingresses and update local ssl certs
 			if ings := store.secretIngressMap.Reference(key); len(ings) > 0 {
 				klog.InfoS("Secret was added and it is used in ingress annotations. Parsing", "secret", key)
@@ -640,6 +743,13 @@ func New(
 					store.syncSecret(store.defaultSSLCertificate)
 				}
 
+				if store.syncDomainCertificate(sec) {
+					updateCh.In() <- Event{
+						Type: UpdateEvent,
+						Obj:  cur,
+					}
+				}
+
 				// find references in ingresses and update local ssl certs
 				if ings := store.secretIngressMap.Reference(key); len(ings) > 0 {
 					klog.InfoS("secret was updated and it is used in ingress annotations. Parsing", "secret", key)
@@ -682,6 +792,13 @@ func New(
 
 			key := k8s.MetaNamespaceKey(sec)
 
+			if store.removeDomainCertificate(key) {
+				updateCh.In() <- Event{
+					Type: DeleteEvent,
+					Obj:  obj,
+				}
+			}
+
 			// find references in ingresses
 			if ings := store.secretIngressMap.Reference(key); len(ings) > 0 {
 				klog.InfoS("secret was deleted and it is used in ingress annotations. Parsing", "secret", key)
@@ -734,7 +851,8 @@ func New(
 	}
 
 	changeTriggerUpdate := func(name string) bool {
-		return name == configmap || name == tcp || name == udp
+		return name == configmap || name == tcp || name == udp || name == defaultAnnotations || name == drainedEndpoints ||
+			name == metricsLabels || name == reloadFreeze || name == changeApproval || name == luaPlugins
 	}
 
 	handleCfgMapEvent := func(key string, cfgMap *corev1.ConfigMap, eventName string) {
@@ -745,8 +863,17 @@ func New(
 			recorder.Eventf(cfgMap, corev1.EventTypeNormal, eventName, fmt.Sprintf("ConfigMap %v", key))
 			if key == configmap {
 				store.setConfig(cfgMap)
+				store.syncIngressClassConfigs()
+			}
+			if key == defaultAnnotations {
+				store.setNamespaceDefaults(cfgMap)
 			}
 		}
+		if store.isIngressClassConfigMap(key) {
+			triggerUpdate = true
+			recorder.Eventf(cfgMap, corev1.EventTypeNormal, eventName, fmt.Sprintf("ConfigMap %v", key))
+			store.syncIngressClassConfigs()
+		}
 
 		ings := store.listers.IngressWithAnnotation.List()
 		for _, ingKey := range ings {
@@ -865,6 +992,67 @@ func New(
 		klog.Errorf("Error adding service event handler: %v", err)
 	}
 
+	// TCPRoute and UDPRoute resources are only watched when a dynamic client is provided
+	if dynamicClient != nil {
+		infFactoryRoutes := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resyncPeriod, namespace, nil)
+
+		store.informers.TCPRoute = infFactoryRoutes.ForResource(v1alpha1.TCPRouteResource).Informer()
+		if err := store.informers.TCPRoute.SetTransform(routeTransform(func() k8sruntime.Object { return &v1alpha1.TCPRoute{} })); err != nil {
+			klog.Errorf("Error adding TCPRoute transform: %v", err)
+		}
+		store.listers.TCPRoute.Store = store.informers.TCPRoute.GetStore()
+
+		store.informers.UDPRoute = infFactoryRoutes.ForResource(v1alpha1.UDPRouteResource).Informer()
+		if err := store.informers.UDPRoute.SetTransform(routeTransform(func() k8sruntime.Object { return &v1alpha1.UDPRoute{} })); err != nil {
+			klog.Errorf("Error adding UDPRoute transform: %v", err)
+		}
+		store.listers.UDPRoute.Store = store.informers.UDPRoute.GetStore()
+
+		routeEventHandler := cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				updateCh.In() <- Event{
+					Type: CreateEvent,
+					Obj:  obj,
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				updateCh.In() <- Event{
+					Type: DeleteEvent,
+					Obj:  obj,
+				}
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				oldRoute, ok := old.(metav1.Object)
+				if !ok {
+					klog.Errorf("unexpected type: %T", old)
+					return
+				}
+				curRoute, ok := cur.(metav1.Object)
+				if !ok {
+					klog.Errorf("unexpected type: %T", cur)
+					return
+				}
+
+				// changes of the status do not modify the generation
+				if oldRoute.GetGeneration() == curRoute.GetGeneration() {
+					return
+				}
+
+				updateCh.In() <- Event{
+					Type: UpdateEvent,
+					Obj:  cur,
+				}
+			},
+		}
+
+		if _, err := store.informers.TCPRoute.AddEventHandler(routeEventHandler); err != nil {
+			klog.Errorf("Error adding TCPRoute event handler: %v", err)
+		}
+		if _, err := store.informers.UDPRoute.AddEventHandler(routeEventHandler); err != nil {
+			klog.Errorf("Error adding UDPRoute event handler: %v", err)
+		}
+	}
+
 	// do not wait for informers to read the configmap configuration
 	ns, name, err := k8s.ParseNameNS(configmap)
 	if err != nil {
@@ -876,6 +1064,20 @@ func New(
 	}
 
 	store.setConfig(cm)
+
+	if defaultAnnotations != "" {
+		ns, name, err := k8s.ParseNameNS(defaultAnnotations)
+		if err != nil {
+			klog.Errorf("unexpected error parsing name and ns: %v", err)
+		}
+		cm, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Unexpected error reading default annotations configmap: %v", err)
+		}
+
+		store.setNamespaceDefaults(cm)
+	}
+
 	return store
 }
 
@@ -933,14 +1135,20 @@ func (s *k8sStore) syncIngress(ing *networkingv1.Ingress) {
 
 	k8s.SetDefaultNGINXPathType(copyIng)
 
-	parsed, err := s.annotations.Extract(ing)
+	// the class is empty when the Ingress is not handled by the controller
+	class, _ := s.GetIngressClass(ing, s.icConfig)
+
+	withDefaults, overridden := s.WithNamespaceDefaults(ing)
+	parsed, err := s.annotationExtractor(class).Extract(withDefaults)
 	if err != nil {
 		klog.Error(err)
 		return
 	}
 	err = s.listers.IngressWithAnnotation.Update(&ingress.Ingress{
-		Ingress:           *copyIng,
-		ParsedAnnotations: parsed,
+		Ingress:                      *copyIng,
+		ParsedAnnotations:            parsed,
+		OverriddenDefaultAnnotations: overridden,
+		IngressClass:                 class,
 	})
 	if err != nil {
 		klog.Error(err)
@@ -971,10 +1179,14 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1.Ingress) {
 	// store. As a result, adding a secret *after* the ingress(es) which
 	// references it would not trigger a resync of that secret.
 	secretAnnotations := []string{
+		"attribution-signing-secret",
 		"auth-secret",
 		"auth-tls-secret",
+		"failover-external-origin-ssl-secret",
 		"proxy-ssl-secret",
 		"secure-verify-ca-secret",
+		"ssl-certificate-secret",
+		"upstream-signing-secret",
 	}
 
 	secConfig := s.GetSecurityConfiguration().AllowCrossNamespaceResources
@@ -1042,6 +1254,59 @@ func (s *k8sStore) ListLocalSSLCerts() []*ingress.SSLCert {
 	return certs
 }
 
+// ListDomainSSLCerts returns the certificates of the Secrets labeled with
+// DomainCertificateLabel, by domain
+func (s *k8sStore) ListDomainSSLCerts() map[string][]*ingress.SSLCert {
+	s.domainCertificatesMu.RLock()
+	defer s.domainCertificatesMu.RUnlock()
+
+	certs := make(map[string][]*ingress.SSLCert)
+	for key, domain := range s.domainCertificates {
+		cert, err := s.GetLocalSSLCert(key)
+		if err != nil {
+			continue
+		}
+		certs[domain] = append(certs[domain], cert)
+	}
+
+	return certs
+}
+
+// syncDomainCertificate keeps track of the domain of a Secret labeled with
+// DomainCertificateLabel and syncs its certificate. Returns true when the
+// domain of the Secret changes.
+func (s *k8sStore) syncDomainCertificate(sec *corev1.Secret) bool {
+	if !s.certificateDiscovery {
+		return false
+	}
+
+	key := k8s.MetaNamespaceKey(sec)
+	domain := strings.ToLower(sec.Labels[DomainCertificateLabel])
+	if domain == "" {
+		return s.removeDomainCertificate(key)
+	}
+
+	s.domainCertificatesMu.Lock()
+	previous, ok := s.domainCertificates[key]
+	s.domainCertificates[key] = domain
+	s.domainCertificatesMu.Unlock()
+
+	s.syncSecret(key)
+
+	return !ok || previous != domain
+}
+
+// removeDomainCertificate stops keeping track of the domain of a Secret.
+// Returns true when the Secret was labeled with DomainCertificateLabel.
+func (s *k8sStore) removeDomainCertificate(key string) bool {
+	s.domainCertificatesMu.Lock()
+	defer s.domainCertificatesMu.Unlock()
+
+	_, ok := s.domainCertificates[key]
+	delete(s.domainCertificates, key)
+	return ok
+}
+
 // GetService returns the Service matching key.
 func (s *k8sStore) GetService(key string) (*corev1.Service, error) {
 	return s.listers.Service.ByKey(key)
@@ -1073,6 +1338,31 @@ func (s *k8sStore) GetIngressClass(ing *networkingv1.Ingress, icConfig *ingressc
 	return "", fmt.Errorf("ingress does not contain a valid IngressClass")
 }
 
+// GetStreamRouteIngressClass returns the class of a TCPRoute or UDPRoute
+// like GetIngressClass, the routes not having a class annotation
+func (s *k8sStore) GetStreamRouteIngressClass(spec *v1alpha1.RouteSpec, icConfig *ingressclass.Configuration) (string, error) {
+	if spec.IngressClassName != nil {
+		className := *spec.IngressClassName
+		if icConfig.IgnoreIngressClass {
+			if className != icConfig.AnnotationValue {
+				return "", fmt.Errorf("route class is not equal to the expected by Ingress Controller")
+			}
+			return className, nil
+		}
+
+		iclass, err := s.listers.IngressClass.ByKey(className)
+		if err != nil {
+			return "", err
+		}
+		return iclass.Name, nil
+	}
+
+	if icConfig.WatchWithoutClass {
+		return "_", nil
+	}
+	return "", fmt.Errorf("route does not contain a valid IngressClass")
+}
+
 // getIngress returns the Ingress matching key.
 func (s *k8sStore) getIngress(key string) (*networkingv1.Ingress, error) {
 	ing, err := s.listers.IngressWithAnnotation.ByKey(key)
@@ -1115,6 +1405,44 @@ func (s *k8sStore) ListIngresses() []*ingress.Ingress {
 	return ingresses
 }
 
+// ListTCPRoutes returns the list of TCPRoutes of the ingress classes of
+// the controller in the watched namespaces
+func (s *k8sStore) ListTCPRoutes() []*v1alpha1.TCPRoute {
+	var routes []*v1alpha1.TCPRoute
+	for _, route := range s.listers.TCPRoute.List() {
+		if s.watchedStreamRoute(&route.ObjectMeta, &route.Spec) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// ListUDPRoutes returns the list of UDPRoutes of the ingress classes of
+// the controller in the watched namespaces
+func (s *k8sStore) ListUDPRoutes() []*v1alpha1.UDPRoute {
+	var routes []*v1alpha1.UDPRoute
+	for _, route := range s.listers.UDPRoute.List() {
+		if s.watchedStreamRoute(&route.ObjectMeta, &route.Spec) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// watchedStreamRoute returns true when a TCPRoute or UDPRoute is in a
+// watched namespace and has an ingress class of the controller
+func (s *k8sStore) watchedStreamRoute(meta *metav1.ObjectMeta, spec *v1alpha1.RouteSpec) bool {
+	if !s.watchedNamespace(meta.Namespace) {
+		return false
+	}
+
+	if _, err := s.GetStreamRouteIngressClass(spec, s.icConfig); err != nil {
+		klog.V(3).InfoS("Ignoring route", "route", klog.KObj(meta), "error", err)
+		return false
+	}
+	return true
+}
+
 // GetLocalSSLCert returns the local copy of a SSLCert
 func (s *k8sStore) GetLocalSSLCert(key string) (*ingress.SSLCert, error) {
 	return s.sslStore.ByKey(key)
@@ -1209,6 +1537,7 @@ func (s *k8sStore) setConfig(cmap *corev1.ConfigMap) {
 		return
 	}
 
+	s.backendConfigData = cmap.Data
 	s.backendConfig = ngx_template.ReadConfig(cmap.Data)
 	if s.backendConfig.UseGeoIP2 && !nginx.GeoLite2DBExists() {
 		klog.Warning("The GeoIP2 feature is enabled but the databases are missing. Disabling")
@@ -39,6 +39,7 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 	"k8s.io/ingress-nginx/test/e2e/framework"
 )
 
@@ -124,12 +125,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -210,12 +219,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -318,12 +335,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -438,12 +463,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			ingressClassconfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -572,12 +605,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			ingressClassconfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -676,12 +717,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -774,12 +823,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -864,12 +921,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -964,12 +1029,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -1091,12 +1164,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -1219,12 +1300,20 @@ func TestStore(t *testing.T) {
 			fmt.Sprintf("%v/tcp", ns),
 			fmt.Sprintf("%v/udp", ns),
 			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
 			10*time.Minute,
 			clientSet,
+			nil,
 			updateCh,
 			false,
 			true,
 			DefaultClassConfig,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -1613,6 +1702,63 @@ func TestListIngresses(t *testing.T) {
 	}
 }
 
+func TestListStreamRoutes(t *testing.T) {
+	s := newStore()
+	s.icConfig = DefaultClassConfig
+	s.watchedNamespace = func(namespace string) bool {
+		return namespace != "unwatched"
+	}
+	s.listers.TCPRoute = TCPRouteLister{cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	s.listers.UDPRoute = UDPRouteLister{cache.NewStore(cache.MetaNamespaceKeyFunc)}
+
+	if err := s.listers.IngressClass.Add(&networking.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx"},
+		Spec:       networking.IngressClassSpec{Controller: ingressclass.DefaultControllerName},
+	}); err != nil {
+		t.Fatalf("error adding the IngressClass: %v", err)
+	}
+
+	className := func(name string) *string {
+		return &name
+	}
+	routes := map[string]v1alpha1.RouteSpec{
+		"owned":         {IngressClassName: className("nginx")},
+		"other-class":   {IngressClassName: className("other")},
+		"without-class": {},
+	}
+	for name, spec := range routes {
+		meta := metav1.ObjectMeta{Name: name, Namespace: "default"}
+		if err := s.listers.TCPRoute.Add(&v1alpha1.TCPRoute{ObjectMeta: meta, Spec: spec}); err != nil {
+			t.Fatalf("error adding the TCPRoute: %v", err)
+		}
+		if err := s.listers.UDPRoute.Add(&v1alpha1.UDPRoute{ObjectMeta: meta, Spec: spec}); err != nil {
+			t.Fatalf("error adding the UDPRoute: %v", err)
+		}
+	}
+	unwatched := metav1.ObjectMeta{Name: "owned", Namespace: "unwatched"}
+	if err := s.listers.TCPRoute.Add(&v1alpha1.TCPRoute{ObjectMeta: unwatched, Spec: routes["owned"]}); err != nil {
+		t.Fatalf("error adding the TCPRoute: %v", err)
+	}
+
+	tcpRoutes := s.ListTCPRoutes()
+	if len(tcpRoutes) != 1 || tcpRoutes[0].Namespace != "default" || tcpRoutes[0].Name != "owned" {
+		t.Errorf("expected only the TCPRoute of the class of the controller but got %v", tcpRoutes)
+	}
+	udpRoutes := s.ListUDPRoutes()
+	if len(udpRoutes) != 1 || udpRoutes[0].Name != "owned" {
+		t.Errorf("expected only the UDPRoute of the class of the controller but got %v", udpRoutes)
+	}
+
+	s.icConfig = &ingressclass.Configuration{
+		Controller:        ingressclass.DefaultControllerName,
+		AnnotationValue:   ingressclass.DefaultAnnotationValue,
+		WatchWithoutClass: true,
+	}
+	if tcpRoutes := s.ListTCPRoutes(); len(tcpRoutes) != 2 {
+		t.Errorf("expected the TCPRoutes without class to be listed when watching the ingresses without class but got %v", tcpRoutes)
+	}
+}
+
 func TestWriteSSLSessionTicketKey(t *testing.T) {
 	tests := []string{
 		"9DyULjtYWz520d1rnTLbc4BOmN2nLAVfd3MES/P3IxWuwXkz9Fby0lnOZZUdNEMV",
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// TCPRouteLister makes a Store that lists TCPRoutes.
+type TCPRouteLister struct {
+	cache.Store
+}
+
+// List returns the TCPRoutes in the local TCPRoute Store.
+func (l *TCPRouteLister) List() []*v1alpha1.TCPRoute {
+	var routes []*v1alpha1.TCPRoute
+	if l.Store == nil {
+		return routes
+	}
+
+	for _, obj := range l.Store.List() {
+		if route, ok := obj.(*v1alpha1.TCPRoute); ok {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// UDPRouteLister makes a Store that lists UDPRoutes.
+type UDPRouteLister struct {
+	cache.Store
+}
+
+// List returns the UDPRoutes in the local UDPRoute Store.
+func (l *UDPRouteLister) List() []*v1alpha1.UDPRoute {
+	var routes []*v1alpha1.UDPRoute
+	if l.Store == nil {
+		return routes
+	}
+
+	for _, obj := range l.Store.List() {
+		if route, ok := obj.(*v1alpha1.UDPRoute); ok {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// routeTransform returns a cache.TransformFunc converting the unstructured
+// objects returned by the dynamic informers to the type returned by newObj
+func routeTransform(newObj func() k8sruntime.Object) cache.TransformFunc {
+	return func(obj interface{}) (interface{}, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return obj, nil
+		}
+
+		route := newObj()
+		if err := k8sruntime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), route); err != nil {
+			return nil, fmt.Errorf("converting %v %v/%v: %w", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+		}
+		return route, nil
+	}
+}
@@ -0,0 +1,349 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// streamRoute contains the common fields of TCPRoute and UDPRoute resources
+type streamRoute struct {
+	resource schema.GroupVersionResource
+	object   runtime.Object
+	meta     *metav1.ObjectMeta
+	spec     *v1alpha1.RouteSpec
+	status   *v1alpha1.RouteStatus
+}
+
+// streamRouteResult contains the outcome of the processing of a stream route
+type streamRouteResult struct {
+	route   streamRoute
+	port    int32
+	reason  string
+	message string
+}
+
+// listStreamRoutes returns the TCPRoute or UDPRoute resources, oldest first.
+// Older routes take precedence when several routes use the same port.
+func (n *NGINXController) listStreamRoutes(proto apiv1.Protocol) []streamRoute {
+	var routes []streamRoute
+	if proto == apiv1.ProtocolTCP {
+		for _, r := range n.store.ListTCPRoutes() {
+			routes = append(routes, streamRoute{v1alpha1.TCPRouteResource, r, &r.ObjectMeta, &r.Spec, &r.Status})
+		}
+	} else {
+		for _, r := range n.store.ListUDPRoutes() {
+			routes = append(routes, streamRoute{v1alpha1.UDPRouteResource, r, &r.ObjectMeta, &r.Spec, &r.Status})
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		ti := routes[i].meta.CreationTimestamp
+		tj := routes[j].meta.CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return k8s.MetaNamespaceKey(routes[i].meta) < k8s.MetaNamespaceKey(routes[j].meta)
+	})
+
+	return routes
+}
+
+// configMapStreamPorts returns the ports declared in the tcp-services-configmap
+// or udp-services-configmap ConfigMap, regardless of the state of their services
+func (n *NGINXController) configMapStreamPorts(configmapName string) sets.Int {
+	ports := sets.NewInt()
+	if configmapName == "" {
+		return ports
+	}
+
+	configmap, err := n.store.GetConfigMap(configmapName)
+	if err != nil {
+		return ports
+	}
+
+	for port := range configmap.Data {
+		if p, err := strconv.Atoi(port); err == nil {
+			ports.Insert(p)
+		}
+	}
+	return ports
+}
+
+// getStreamRouteServices returns the stream services declared using TCPRoute
+// or UDPRoute resources and the outcome of the processing of each route.
+// Routes using a port in usedPorts are rejected.
+func (n *NGINXController) getStreamRouteServices(proto apiv1.Protocol, usedPorts sets.Int) ([]ingress.L4Service, []streamRouteResult) {
+	if n.cfg.DynamicClient == nil {
+		return nil, nil
+	}
+
+	reservedPorts := n.reservedStreamPorts()
+	usedPorts = sets.NewInt(usedPorts.List()...)
+
+	var svcs []ingress.L4Service
+	var results []streamRouteResult
+	for _, route := range n.listStreamRoutes(proto) {
+		result := streamRouteResult{route: route, reason: v1alpha1.RouteReasonAccepted}
+		spec := route.spec
+		key := k8s.MetaNamespaceKey(route.meta)
+
+		port := int(spec.Port)
+		switch err := validateStreamRoute(proto, spec); {
+		case err != nil:
+			result.reason, result.message = v1alpha1.RouteReasonInvalid, err.Error()
+		case reservedPorts.Has(port):
+			result.reason, result.message = v1alpha1.RouteReasonPortConflict,
+				fmt.Sprintf("port %d is reserved for the Ingress controller", port)
+		case usedPorts.Has(port):
+			result.reason, result.message = v1alpha1.RouteReasonPortConflict,
+				fmt.Sprintf("port %d is already used by another %v service", port, proto)
+		}
+		if result.reason != v1alpha1.RouteReasonAccepted {
+			klog.Warningf("Ignoring %v route %q: %v", proto, key, result.message)
+			results = append(results, result)
+			continue
+		}
+
+		// the port belongs to the route even if its service is not available
+		usedPorts.Insert(port)
+
+		svcKey := fmt.Sprintf("%v/%v", route.meta.Namespace, spec.Backend.ServiceName)
+		svc, err := n.store.GetService(svcKey)
+		if err != nil {
+			result.reason, result.message = v1alpha1.RouteReasonBackendNotFound, fmt.Sprintf("service %q not found", svcKey)
+			klog.Warningf("Error getting Service %q for %v route %q: %v", svcKey, proto, key, err)
+			results = append(results, result)
+			continue
+		}
+
+		svcPort := spec.Backend.ServicePort.String()
+		endps := n.getStreamEndpoints(svc, svcPort, proto)
+		// stream services cannot contain empty upstreams and there is
+		// no default backend equivalent
+		if len(endps) == 0 {
+			result.reason, result.message = v1alpha1.RouteReasonBackendNotFound,
+				fmt.Sprintf("service %q does not have any active endpoint for %v port %v", svcKey, proto, svcPort)
+			klog.Warningf("Service %q does not have any active Endpoint for %v port %v", svcKey, proto, svcPort)
+			results = append(results, result)
+			continue
+		}
+
+		backend := ingress.L4Backend{
+			Name:      spec.Backend.ServiceName,
+			Namespace: route.meta.Namespace,
+			Port:      intstr.FromString(svcPort),
+			Protocol:  proto,
+		}
+		if spec.ProxyProtocol != nil {
+			backend.ProxyProtocol = ingress.ProxyProtocol{
+				Decode: spec.ProxyProtocol.Decode,
+				Encode: spec.ProxyProtocol.Encode,
+			}
+		}
+		if spec.Timeouts != nil {
+			backend.ConnectTimeout = nginxDuration(spec.Timeouts.Connect)
+			backend.ProxyTimeout = nginxDuration(spec.Timeouts.Idle)
+		}
+
+		svcs = append(svcs, ingress.L4Service{
+			Port:      port,
+			Backend:   backend,
+			Endpoints: endps,
+			Service:   svc,
+		})
+
+		result.port = spec.Port
+		results = append(results, result)
+	}
+
+	return svcs, results
+}
+
+// nginxDuration returns the NGINX representation of d or an empty string when d is not set
+func nginxDuration(d *metav1.Duration) string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// validateStreamRoute checks the specification of a TCPRoute or UDPRoute
+func validateStreamRoute(proto apiv1.Protocol, spec *v1alpha1.RouteSpec) error {
+	if spec.Port < 1 || spec.Port > 65535 {
+		return fmt.Errorf("port %d must be between 1 and 65535", spec.Port)
+	}
+
+	if errs := validation.IsDNS1035Label(spec.Backend.ServiceName); len(errs) > 0 {
+		return fmt.Errorf("invalid service name %q: %v", spec.Backend.ServiceName, strings.Join(errs, ", "))
+	}
+
+	servicePort := spec.Backend.ServicePort
+	if servicePort.Type == intstr.Int {
+		if errs := validation.IsValidPortNum(servicePort.IntValue()); len(errs) > 0 {
+			return fmt.Errorf("invalid service port %v: %v", servicePort.IntValue(), strings.Join(errs, ", "))
+		}
+	} else if errs := validation.IsValidPortName(servicePort.StrVal); len(errs) > 0 {
+		return fmt.Errorf("invalid service port %q: %v", servicePort.StrVal, strings.Join(errs, ", "))
+	}
+
+	if spec.ProxyProtocol != nil && proto != apiv1.ProtocolTCP && (spec.ProxyProtocol.Decode || spec.ProxyProtocol.Encode) {
+		return fmt.Errorf("the PROXY protocol is only supported by TCP routes")
+	}
+
+	if spec.Timeouts != nil {
+		if spec.Timeouts.Connect != nil && spec.Timeouts.Connect.Duration < time.Millisecond {
+			return fmt.Errorf("connect timeout must be at least 1ms")
+		}
+		if spec.Timeouts.Idle != nil && spec.Timeouts.Idle.Duration < time.Millisecond {
+			return fmt.Errorf("idle timeout must be at least 1ms")
+		}
+	}
+
+	return nil
+}
+
+// CheckStreamRoute returns an error in case the TCPRoute or UDPRoute
+// namespace/name contains an invalid specification or uses a port
+// already allocated to another stream service
+func (n *NGINXController) CheckStreamRoute(proto apiv1.Protocol, namespace, name string, spec *v1alpha1.RouteSpec) error {
+	if n == nil {
+		return fmt.Errorf("cannot check stream route on a nil ingress controller")
+	}
+
+	if !n.cfg.EnableStreamRoutes {
+		return nil
+	}
+
+	if _, err := n.store.GetStreamRouteIngressClass(spec, n.cfg.IngressClassConfiguration); err != nil {
+		klog.V(3).Infof("Skipping validation of %v route %v/%v: %v", proto, namespace, name, err)
+		return nil
+	}
+
+	if err := validateStreamRoute(proto, spec); err != nil {
+		return err
+	}
+
+	port := int(spec.Port)
+	if n.reservedStreamPorts().Has(port) {
+		return fmt.Errorf("port %d is reserved for the Ingress controller", port)
+	}
+
+	configmapName := n.cfg.TCPConfigMapName
+	if proto == apiv1.ProtocolUDP {
+		configmapName = n.cfg.UDPConfigMapName
+	}
+	if n.configMapStreamPorts(configmapName).Has(port) {
+		return fmt.Errorf("port %d is already used by the ConfigMap %v", port, configmapName)
+	}
+
+	for _, route := range n.listStreamRoutes(proto) {
+		if route.meta.Namespace == namespace && route.meta.Name == name {
+			continue
+		}
+		if route.spec.Port == spec.Port {
+			return fmt.Errorf("port %d is already used by %v route %v", port, proto, k8s.MetaNamespaceKey(route.meta))
+		}
+	}
+
+	return nil
+}
+
+// syncStreamRouteStatus updates the status of the TCPRoute and UDPRoute
+// resources with the listener port allocated to them. Only called by the
+// status sync of the leader.
+func (n *NGINXController) syncStreamRouteStatus() {
+	if n.cfg.DynamicClient == nil || n.cfg.ShadowMode {
+		return
+	}
+
+	configmaps := map[apiv1.Protocol]string{
+		apiv1.ProtocolTCP: n.cfg.TCPConfigMapName,
+		apiv1.ProtocolUDP: n.cfg.UDPConfigMapName,
+	}
+	for proto, configmapName := range configmaps {
+		_, results := n.getStreamRouteServices(proto, n.configMapStreamPorts(configmapName))
+		for i := range results {
+			if err := n.updateStreamRouteStatus(&results[i]); err != nil {
+				klog.Warningf("Error updating status of %v route %q: %v", proto, k8s.MetaNamespaceKey(results[i].route.meta), err)
+			}
+		}
+	}
+}
+
+// updateStreamRouteStatus writes the outcome of the processing of a route
+// to its status when it changed
+func (n *NGINXController) updateStreamRouteStatus(result *streamRouteResult) error {
+	route := result.route
+
+	status := route.status.DeepCopy()
+	status.ListenerPort = result.port
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.RouteConditionAccepted,
+		Status:             metav1.ConditionTrue,
+		Reason:             result.reason,
+		Message:            result.message,
+		ObservedGeneration: route.meta.Generation,
+	}
+	if result.reason != v1alpha1.RouteReasonAccepted {
+		condition.Status = metav1.ConditionFalse
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if apiequality.Semantic.DeepEqual(status, route.status) {
+		return nil
+	}
+
+	obj := route.object.DeepCopyObject()
+	switch r := obj.(type) {
+	case *v1alpha1.TCPRoute:
+		r.Status = *status
+	case *v1alpha1.UDPRoute:
+		r.Status = *status
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.cfg.DynamicClient.Resource(route.resource).Namespace(route.meta.Namespace).
+		UpdateStatus(context.TODO(), &unstructured.Unstructured{Object: content}, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+type fakeStreamRouteStore struct {
+	fakeIngressStore
+	services map[string]*corev1.Service
+}
+
+func (s *fakeStreamRouteStore) GetService(key string) (*corev1.Service, error) {
+	if svc, ok := s.services[key]; ok {
+		return svc, nil
+	}
+	return nil, fmt.Errorf("service %v not found", key)
+}
+
+func newStreamRouteController(t *testing.T, routes ...*v1alpha1.TCPRoute) *NGINXController {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	objects := make([]runtime.Object, 0, len(routes))
+	for _, route := range routes {
+		objects = append(objects, route)
+	}
+
+	return &NGINXController{
+		store: &fakeStreamRouteStore{
+			fakeIngressStore: fakeIngressStore{tcpRoutes: routes},
+			services: map[string]*corev1.Service{
+				"default/echo": {
+					ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+					Spec: corev1.ServiceSpec{
+						Type:         corev1.ServiceTypeExternalName,
+						ExternalName: "10.0.0.1",
+						Ports: []corev1.ServicePort{
+							{Name: "echo", Port: 8080, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromInt(8080)},
+						},
+					},
+				},
+			},
+		},
+		cfg: &Configuration{
+			DynamicClient:      dynamicfake.NewSimpleDynamicClient(scheme, objects...),
+			EnableStreamRoutes: true,
+			ListenPorts:        &ngx_config.ListenPorts{HTTP: 80, HTTPS: 443},
+		},
+	}
+}
+
+func newTCPRoute(name string, created time.Time, spec v1alpha1.RouteSpec) *v1alpha1.TCPRoute {
+	return &v1alpha1.TCPRoute{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: "TCPRoute"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			Generation:        1,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: spec,
+	}
+}
+
+func echoRoute(port int32) v1alpha1.RouteSpec {
+	return v1alpha1.RouteSpec{
+		Port:    port,
+		Backend: v1alpha1.RouteBackend{ServiceName: "echo", ServicePort: intstr.FromInt(8080)},
+	}
+}
+
+func TestGetStreamRouteServices(t *testing.T) {
+	now := time.Now()
+
+	accepted := echoRoute(9000)
+	accepted.ProxyProtocol = &v1alpha1.ProxyProtocol{Decode: true}
+	accepted.Timeouts = &v1alpha1.RouteTimeouts{
+		Connect: &metav1.Duration{Duration: 5 * time.Second},
+		Idle:    &metav1.Duration{Duration: time.Minute},
+	}
+
+	invalid := echoRoute(9004)
+	invalid.Backend.ServiceName = "Invalid_Name"
+
+	missing := echoRoute(9003)
+	missing.Backend.ServiceName = "missing"
+
+	n := newStreamRouteController(t,
+		newTCPRoute("conflict", now, echoRoute(9000)),
+		newTCPRoute("accepted", now.Add(-time.Hour), accepted),
+		newTCPRoute("reserved", now, echoRoute(80)),
+		newTCPRoute("configmap", now, echoRoute(9001)),
+		newTCPRoute("missing", now, missing),
+		newTCPRoute("invalid", now, invalid),
+		newTCPRoute("by-name", now, v1alpha1.RouteSpec{
+			Port:    9005,
+			Backend: v1alpha1.RouteBackend{ServiceName: "echo", ServicePort: intstr.FromString("echo")},
+		}),
+	)
+
+	svcs, results := n.getStreamRouteServices(corev1.ProtocolTCP, sets.NewInt(9001))
+
+	expectedReasons := map[string]string{
+		"accepted":  v1alpha1.RouteReasonAccepted,
+		"conflict":  v1alpha1.RouteReasonPortConflict,
+		"reserved":  v1alpha1.RouteReasonPortConflict,
+		"configmap": v1alpha1.RouteReasonPortConflict,
+		"missing":   v1alpha1.RouteReasonBackendNotFound,
+		"invalid":   v1alpha1.RouteReasonInvalid,
+		"by-name":   v1alpha1.RouteReasonAccepted,
+	}
+	if len(results) != len(expectedReasons) {
+		t.Fatalf("expected %d results but got %d", len(expectedReasons), len(results))
+	}
+	for _, result := range results {
+		name := result.route.meta.Name
+		if result.reason != expectedReasons[name] {
+			t.Errorf("expected reason %q for route %v but got %q (%v)", expectedReasons[name], name, result.reason, result.message)
+		}
+		if result.reason == v1alpha1.RouteReasonAccepted && result.port != result.route.spec.Port {
+			t.Errorf("expected listener port %d for route %v but got %d", result.route.spec.Port, name, result.port)
+		}
+	}
+
+	if len(svcs) != 2 {
+		t.Fatalf("expected 2 stream services but got %d", len(svcs))
+	}
+	svc := svcs[0]
+	if svc.Port != 9000 || svc.Backend.Name != "echo" || svc.Backend.Namespace != "default" {
+		t.Errorf("unexpected stream service %+v", svc)
+	}
+	if !svc.Backend.ProxyProtocol.Decode || svc.Backend.ProxyProtocol.Encode {
+		t.Errorf("expected PROXY protocol decoding only but got %+v", svc.Backend.ProxyProtocol)
+	}
+	if svc.Backend.ConnectTimeout != "5000ms" || svc.Backend.ProxyTimeout != "60000ms" {
+		t.Errorf("unexpected timeouts %q and %q", svc.Backend.ConnectTimeout, svc.Backend.ProxyTimeout)
+	}
+	if len(svc.Endpoints) != 1 || svc.Endpoints[0].Address != "10.0.0.1" {
+		t.Errorf("unexpected endpoints %+v", svc.Endpoints)
+	}
+}
+
+func TestValidateStreamRoute(t *testing.T) {
+	proxyProtocol := echoRoute(9000)
+	proxyProtocol.ProxyProtocol = &v1alpha1.ProxyProtocol{Encode: true}
+
+	zeroTimeout := echoRoute(9000)
+	zeroTimeout.Timeouts = &v1alpha1.RouteTimeouts{Idle: &metav1.Duration{}}
+
+	invalidPortName := echoRoute(9000)
+	invalidPortName.Backend.ServicePort = intstr.FromString("Not A Port")
+
+	testCases := map[string]struct {
+		proto     corev1.Protocol
+		spec      v1alpha1.RouteSpec
+		expectErr bool
+	}{
+		"valid route":                  {corev1.ProtocolTCP, echoRoute(9000), false},
+		"invalid port":                 {corev1.ProtocolTCP, echoRoute(70000), true},
+		"invalid service port":         {corev1.ProtocolTCP, v1alpha1.RouteSpec{Port: 9000, Backend: v1alpha1.RouteBackend{ServiceName: "echo"}}, true},
+		"invalid service port name":    {corev1.ProtocolTCP, invalidPortName, true},
+		"PROXY protocol with TCP":      {corev1.ProtocolTCP, proxyProtocol, false},
+		"PROXY protocol with UDP":      {corev1.ProtocolUDP, proxyProtocol, true},
+		"zero timeout":                 {corev1.ProtocolTCP, zeroTimeout, true},
+		"missing service name":         {corev1.ProtocolUDP, v1alpha1.RouteSpec{Port: 9000}, true},
+		"service name is not DNS-1035": {corev1.ProtocolTCP, v1alpha1.RouteSpec{Port: 9000, Backend: v1alpha1.RouteBackend{ServiceName: "1echo", ServicePort: intstr.FromInt(80)}}, true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateStreamRoute(tc.proto, &tc.spec)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error %t but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckStreamRoute(t *testing.T) {
+	n := newStreamRouteController(t, newTCPRoute("existing", time.Now(), echoRoute(9000)))
+
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "new", &v1alpha1.RouteSpec{Port: 9000}); err == nil {
+		t.Errorf("expected an error with an invalid route")
+	}
+
+	spec := echoRoute(9000)
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "new", &spec); err == nil {
+		t.Errorf("expected an error with a port used by another route")
+	}
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "existing", &spec); err != nil {
+		t.Errorf("unexpected error updating a route: %v", err)
+	}
+	if err := n.CheckStreamRoute(corev1.ProtocolUDP, "default", "new", &spec); err != nil {
+		t.Errorf("unexpected error using the same port with another protocol: %v", err)
+	}
+
+	otherClass := "other"
+	spec.IngressClassName = &otherClass
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "new", &spec); err != nil {
+		t.Errorf("unexpected error with a route of another ingress class: %v", err)
+	}
+
+	spec = echoRoute(443)
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "new", &spec); err == nil {
+		t.Errorf("expected an error with a reserved port")
+	}
+
+	n.cfg.EnableStreamRoutes = false
+	if err := n.CheckStreamRoute(corev1.ProtocolTCP, "default", "new", &spec); err != nil {
+		t.Errorf("unexpected error with stream routes disabled: %v", err)
+	}
+}
+
+func TestSyncStreamRouteStatus(t *testing.T) {
+	route := newTCPRoute("echo", time.Now(), echoRoute(9000))
+	n := newStreamRouteController(t, route)
+
+	n.syncStreamRouteStatus()
+
+	obj, err := n.cfg.DynamicClient.Resource(v1alpha1.TCPRouteResource).Namespace("default").
+		Get(context.TODO(), "echo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := &v1alpha1.TCPRoute{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if updated.Status.ListenerPort != 9000 {
+		t.Errorf("expected listener port 9000 but got %d", updated.Status.ListenerPort)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.RouteConditionAccepted)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.ObservedGeneration != 1 {
+		t.Errorf("unexpected Accepted condition %+v", condition)
+	}
+}
+
+func TestSyncStreamRouteStatusShadowMode(t *testing.T) {
+	route := newTCPRoute("echo", time.Now(), echoRoute(9000))
+	n := newStreamRouteController(t, route)
+	n.cfg.ShadowMode = true
+
+	n.syncStreamRouteStatus()
+
+	obj, err := n.cfg.DynamicClient.Resource(v1alpha1.TCPRouteResource).Namespace("default").
+		Get(context.TODO(), "echo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := &v1alpha1.TCPRoute{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if updated.Status.ListenerPort != 0 || len(updated.Status.Conditions) != 0 {
+		t.Errorf("expected the status to be unchanged in shadow mode but got %+v", updated.Status)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/syntheticprobe"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+const (
+	syntheticProbeUserAgent = "ingress-nginx-synthetic-probe"
+	// syntheticProbeConcurrency is the maximum number of probes sent at once
+	syntheticProbeConcurrency = 10
+)
+
+// syntheticProbeTarget is a host and path of an Ingress probed through NGINX
+type syntheticProbeTarget struct {
+	ingress string
+	host    string
+	path    string
+	tls     bool
+	config  *syntheticprobe.Config
+}
+
+// syntheticProbeTargets returns the hosts and paths of the Ingresses with
+// synthetic probes. The locations with regular expressions or path templates
+// are only probed with the path of the synthetic-probe-path annotation.
+func syntheticProbeTargets(servers []*ingress.Server) []syntheticProbeTarget {
+	targets := []syntheticProbeTarget{}
+	seen := sets.New[string]()
+
+	for _, server := range servers {
+		if server.Hostname == defServerName || server.Hostname == "" || server.Hostname[0] == '*' || server.SSLPassthrough {
+			continue
+		}
+
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil || loc.Ingress.ParsedAnnotations == nil {
+				continue
+			}
+
+			config := &loc.Ingress.ParsedAnnotations.SyntheticProbe
+			if !config.Enabled {
+				continue
+			}
+
+			path := config.Path
+			if path == "" {
+				if loc.IsDefBackend || loc.Rewrite.UseRegex || len(loc.PathParameters) > 0 {
+					continue
+				}
+				path = loc.Path
+			}
+
+			ing := k8s.MetaNamespaceKey(&loc.Ingress.Ingress)
+			key := fmt.Sprintf("%v %v %v", ing, server.Hostname, path)
+			if seen.Has(key) {
+				continue
+			}
+			seen.Insert(key)
+
+			targets = append(targets, syntheticProbeTarget{
+				ingress: ing,
+				host:    server.Hostname,
+				path:    path,
+				tls:     server.SSLCert != nil,
+				config:  config,
+			})
+		}
+	}
+
+	return targets
+}
+
+// syntheticProber sends the synthetic probes to the HTTP and HTTPS ports of NGINX
+type syntheticProber struct {
+	client *http.Client
+}
+
+// newSyntheticProber returns a prober connecting to httpAddr and httpsAddr
+// whatever the host of the probes. The PROXY protocol header is sent when
+// proxyProtocol returns true.
+func newSyntheticProber(httpAddr, httpsAddr string, timeout time.Duration, proxyProtocol func() bool) *syntheticProber {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			target := httpAddr
+			if _, port, err := net.SplitHostPort(addr); err == nil && port == "443" {
+				target = httpsAddr
+			}
+
+			conn, err := dialer.DialContext(ctx, network, target)
+			if err != nil || !proxyProtocol() {
+				return conn, err
+			}
+
+			local, _ := conn.LocalAddr().(*net.TCPAddr)
+			remote, _ := conn.RemoteAddr().(*net.TCPAddr)
+			if local == nil || remote == nil {
+				return conn, nil
+			}
+			protocol := "TCP4"
+			if local.IP.To4() == nil {
+				protocol = "TCP6"
+			}
+			if _, err := fmt.Fprintf(conn, "PROXY %v %v %v %v %v\r\n", protocol, local.IP, remote.IP, local.Port, remote.Port); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+		// the probes check the routes, the certificates are reported by other metrics
+		//nolint:gosec // the probes are sent to the local NGINX
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}
+
+	return &syntheticProber{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// probe sends the synthetic probe of the target
+func (p *syntheticProber) probe(ctx context.Context, target syntheticProbeTarget) ingress.SyntheticProbeResult {
+	result := ingress.SyntheticProbeResult{
+		Ingress: target.ingress,
+		Host:    target.host,
+		Path:    target.path,
+	}
+
+	scheme := "http"
+	if target.tls {
+		scheme = "https"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%v://%v%v", scheme, target.host, target.path), http.NoBody)
+	if err != nil {
+		klog.Warningf("Invalid synthetic probe of %v%v (Ingress %q): %v", target.host, target.path, target.ingress, err)
+		return result
+	}
+	req.Header.Set("User-Agent", syntheticProbeUserAgent)
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		klog.V(2).InfoS("Synthetic probe failed", "ingress", target.ingress, "host", target.host, "path", target.path, "err", err)
+		return result
+	}
+	defer resp.Body.Close()
+	//nolint:errcheck // the body is only read to reuse the connection
+	io.Copy(io.Discard, resp.Body)
+
+	result.Success = target.config.Success(resp.StatusCode)
+	if !result.Success {
+		klog.V(2).InfoS("Synthetic probe returned an unexpected status", "ingress", target.ingress, "host", target.host, "path", target.path, "status", resp.StatusCode)
+	}
+
+	return result
+}
+
+// probeAll sends the synthetic probes of the targets
+func (p *syntheticProber) probeAll(ctx context.Context, targets []syntheticProbeTarget) []ingress.SyntheticProbeResult {
+	results := make([]ingress.SyntheticProbeResult, len(targets))
+	sem := make(chan struct{}, syntheticProbeConcurrency)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			results[i] = p.probe(ctx, target)
+			<-sem
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runSyntheticProbes probes the hosts and paths of the running configuration
+// until the controller stops
+func (n *NGINXController) runSyntheticProbes() {
+	prober := newSyntheticProber(
+		fmt.Sprintf("127.0.0.1:%v", n.cfg.ListenPorts.HTTP),
+		fmt.Sprintf("127.0.0.1:%v", n.cfg.ListenPorts.HTTPS),
+		n.cfg.SyntheticProbeTimeout,
+		func() bool { return n.store.GetBackendConfiguration().UseProxyProtocol },
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-n.stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(n.cfg.SyntheticProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			targets := syntheticProbeTargets(n.RunningConfiguration().Servers)
+			n.metricCollector.SetSyntheticProbeResults(prober.probeAll(ctx, targets))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/syntheticprobe"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+func probedIngress(name string, probe syntheticprobe.Config) *ingress.Ingress {
+	ing := regressionIngress(name, "")
+	ing.ParsedAnnotations = &annotations.Ingress{SyntheticProbe: probe}
+	return ing
+}
+
+func TestSyntheticProbeTargets(t *testing.T) {
+	probed := probedIngress("probed", syntheticprobe.Config{Enabled: true})
+	withPath := probedIngress("with-path", syntheticprobe.Config{Enabled: true, Path: "/healthz"})
+	notProbed := probedIngress("not-probed", syntheticprobe.Config{})
+
+	servers := []*ingress.Server{
+		{
+			Hostname: "example.com",
+			SSLCert:  &ingress.SSLCert{},
+			Locations: []*ingress.Location{
+				{Path: "/", Ingress: probed, IsDefBackend: true},
+				{Path: "/api", Ingress: probed},
+				{Path: "/api/v[0-9]+", Ingress: probed, Rewrite: rewrite.Config{UseRegex: true}},
+				{Path: "/users/{id}", Ingress: probed, PathParameters: []string{"id"}},
+				{Path: "/other", Ingress: notProbed},
+				{Path: "/", Ingress: nil},
+			},
+		},
+		{
+			Hostname: "health.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/a", Ingress: withPath},
+				{Path: "/b", Ingress: withPath},
+			},
+		},
+		{Hostname: "*.example.com", Locations: []*ingress.Location{{Path: "/api", Ingress: probed}}},
+		{Hostname: "passthrough.example.com", SSLPassthrough: true, Locations: []*ingress.Location{{Path: "/", Ingress: probed}}},
+		{Hostname: defServerName, Locations: []*ingress.Location{{Path: "/api", Ingress: probed}}},
+	}
+
+	expected := []syntheticProbeTarget{
+		{ingress: "default/probed", host: "example.com", path: "/api", tls: true, config: &probed.ParsedAnnotations.SyntheticProbe},
+		{ingress: "default/with-path", host: "health.example.com", path: "/healthz", config: &withPath.ParsedAnnotations.SyntheticProbe},
+	}
+
+	if targets := syntheticProbeTargets(servers); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %+v but got %+v", expected, targets)
+	}
+}
+
+func TestSyntheticProbe(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" || r.UserAgent() != syntheticProbeUserAgent {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/redirect":
+			http.Redirect(w, r, "https://example.com/ok", http.StatusPermanentRedirect)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	httpsServer := httptest.NewTLSServer(handler)
+	defer httpsServer.Close()
+
+	prober := newSyntheticProber(httpServer.Listener.Addr().String(), httpsServer.Listener.Addr().String(), time.Second, func() bool { return false })
+
+	config := &syntheticprobe.Config{Enabled: true}
+	notFound := &syntheticprobe.Config{Enabled: true, ExpectedStatus: []int{http.StatusNotFound}}
+	targets := []syntheticProbeTarget{
+		{ingress: "default/demo", host: "example.com", path: "/ok", config: config},
+		{ingress: "default/demo", host: "example.com", path: "/ok", tls: true, config: config},
+		{ingress: "default/demo", host: "example.com", path: "/redirect", config: config},
+		{ingress: "default/demo", host: "example.com", path: "/missing", tls: true, config: config},
+		{ingress: "default/demo", host: "example.com", path: "/missing", config: notFound},
+	}
+	expected := []bool{true, true, true, false, true}
+
+	results := prober.probeAll(context.Background(), targets)
+	if len(results) != len(targets) {
+		t.Fatalf("expected %v results but got %v", len(targets), len(results))
+	}
+	for i, result := range results {
+		if result.Success != expected[i] {
+			t.Errorf("expected success %v for %+v but got %+v", expected[i], targets[i], result)
+		}
+		if result.Ingress != "default/demo" || result.Host != "example.com" || result.Path != targets[i].path {
+			t.Errorf("unexpected result %+v for %+v", result, targets[i])
+		}
+	}
+}
@@ -31,10 +31,13 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/conflictresolution"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customheaders"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslcertpreference"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/pkg/tcpproxy"
 	"k8s.io/ingress-nginx/pkg/util/runtime"
 )
 
@@ -69,6 +72,10 @@ const (
 	luaSharedDictsKey             = "lua-shared-dicts"
 	debugConnections              = "debug-connections"
 	workerSerialReloads           = "enable-serial-reloads"
+	sslCertificatePreference      = "ssl-certificate-preference"
+	upstreamAttemptsHeaderCIDRs   = "upstream-attempts-header-cidrs"
+	ingressConflictResolution     = "ingress-conflict-resolution"
+	sslPassthroughUnmatchedSNI    = "ssl-passthrough-unmatched-sni"
 )
 
 var (
@@ -82,6 +89,12 @@ var (
 		"balancer_ewma_locks":           1024,
 		"certificate_servers":           5120,
 		"ocsp_response_cache":           5120, // keep this same as certificate_servers
+		"auth_cookie_sessions":          10240,
+		"balancer_retry_budget":         1024,
+		"balancer_connect_backoff":      1024,
+		"concurrency_limit":             1024,
+		"bot_mitigation":                1024,
+		"monitor_pending":               10240,
 	}
 	defaultGlobalAuthRedirectParam = "rd"
 )
@@ -180,21 +193,26 @@ func ReadConfig(src map[string]string) config.Configuration {
 
 	if val, ok := conf[denylistSourceRange]; ok {
 		delete(conf, denylistSourceRange)
-		denyList = append(denyList, splitAndTrimSpace(val, ",")...)
+		denyList = append(denyList, normalizeCIDRs(denylistSourceRange, splitAndTrimSpace(val, ","))...)
 	}
 
 	if val, ok := conf[whitelistSourceRange]; ok {
 		delete(conf, whitelistSourceRange)
-		whiteList = append(whiteList, splitAndTrimSpace(val, ",")...)
+		whiteList = append(whiteList, normalizeCIDRs(whitelistSourceRange, splitAndTrimSpace(val, ","))...)
 	}
 
 	if val, ok := conf[proxyRealIPCIDR]; ok {
 		delete(conf, proxyRealIPCIDR)
-		proxyList = append(proxyList, splitAndTrimSpace(val, ",")...)
+		proxyList = append(proxyList, normalizeCIDRs(proxyRealIPCIDR, splitAndTrimSpace(val, ","))...)
 	} else {
 		proxyList = append(proxyList, "0.0.0.0/0")
 	}
 
+	if val, ok := conf[upstreamAttemptsHeaderCIDRs]; ok {
+		delete(conf, upstreamAttemptsHeaderCIDRs)
+		to.UpstreamAttemptsHeaderCIDRs = normalizeCIDRs(upstreamAttemptsHeaderCIDRs, splitAndTrimSpace(val, ","))
+	}
+
 	if val, ok := conf[bindAddress]; ok {
 		delete(conf, bindAddress)
 		for _, i := range splitAndTrimSpace(val, ",") {
@@ -213,7 +231,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 
 	if val, ok := conf[blockCIDRs]; ok {
 		delete(conf, blockCIDRs)
-		blockCIDRList = splitAndTrimSpace(val, ",")
+		blockCIDRList = normalizeCIDRs(blockCIDRs, splitAndTrimSpace(val, ","))
 	}
 
 	if val, ok := conf[blockUserAgents]; ok {
@@ -435,6 +453,36 @@ func ReadConfig(src map[string]string) config.Configuration {
 		to.DebugConnections = debugConnectionsList
 	}
 
+	if val, ok := conf[sslCertificatePreference]; ok {
+		delete(conf, sslCertificatePreference)
+		val = strings.ToLower(strings.TrimSpace(val))
+		if val == "" || sets.NewString(sslcertpreference.Preferences...).Has(val) {
+			to.SSLCertificatePreference = val
+		} else {
+			klog.Warningf("%v is not a valid SSL certificate preference, valid values are %v", val, strings.Join(sslcertpreference.Preferences, " or "))
+		}
+	}
+
+	if val, ok := conf[ingressConflictResolution]; ok {
+		delete(conf, ingressConflictResolution)
+		val = strings.ToLower(strings.TrimSpace(val))
+		if val == "" || sets.NewString(conflictresolution.Strategies...).Has(val) {
+			to.IngressConflictResolution = val
+		} else {
+			klog.Warningf("%v is not a valid ingress conflict resolution, valid values are %v", val, strings.Join(conflictresolution.Strategies, ", "))
+		}
+	}
+
+	if val, ok := conf[sslPassthroughUnmatchedSNI]; ok {
+		delete(conf, sslPassthroughUnmatchedSNI)
+		val = strings.ToLower(strings.TrimSpace(val))
+		if sets.NewString(tcpproxy.UnmatchedSNIActions...).Has(val) {
+			to.SSLPassthroughUnmatchedSNI = val
+		} else {
+			klog.Warningf("%v is not a valid SSL passthrough unmatched SNI action, valid values are %v", val, strings.Join(tcpproxy.UnmatchedSNIActions, ", "))
+		}
+	}
+
 	to.CustomHTTPErrors = filterErrors(errors)
 	to.SkipAccessLogURLs = skipUrls
 	to.DenylistSourceRange = denyList
@@ -506,6 +554,26 @@ func splitAndTrimSpace(s, sep string) []string {
 	return values
 }
 
+// normalizeCIDRs returns the canonical form of the IP addresses and networks
+// of a setting, without duplicates and ignoring the invalid ones
+func normalizeCIDRs(setting string, values []string) []string {
+	cidrs := make([]string, 0, len(values))
+	found := sets.New[string]()
+	for _, value := range values {
+		cidr, err := ing_net.NormalizeCIDR(value)
+		if err != nil {
+			klog.Warningf("%v is not a valid IP or CIDR address in %v", value, setting)
+			continue
+		}
+		if found.Has(cidr) {
+			continue
+		}
+		found.Insert(cidr)
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
 func dictStrToKb(sizeStr string) int {
 	sizeMatch := dictSizeRegex.FindStringSubmatch(sizeStr)
 	if sizeMatch == nil {
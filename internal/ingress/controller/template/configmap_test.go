@@ -92,7 +92,7 @@ func TestMergeConfigMapToStruct(t *testing.T) {
 	def.GzipLevel = 9
 	def.GzipMinLength = 1024
 	def.GzipTypes = "text/html"
-	def.ProxyRealIPCIDR = []string{"1.1.1.1/8", "2.2.2.2/24"}
+	def.ProxyRealIPCIDR = []string{"1.0.0.0/8", "2.2.2.0/24"}
 	def.BindAddressIpv4 = []string{"1.1.1.1", "2.2.2.2"}
 	def.BindAddressIpv6 = []string{"[2001:db8:a0b:12f0::1]", "[3731:54:65fe:2::a7]"}
 	def.WorkerShutdownTimeout = "99s"
@@ -364,6 +364,66 @@ func TestGlobalExternalAuthCacheDurationParsing(t *testing.T) {
 	}
 }
 
+func TestSSLCertificatePreferenceParsing(t *testing.T) {
+	testCases := map[string]struct {
+		preference string
+		expect     string
+	}{
+		"nothing":  {"", ""},
+		"exact":    {"exact", "exact"},
+		"wildcard": {" Wildcard ", "wildcard"},
+		"invalid":  {"first", ""},
+	}
+
+	for n, tc := range testCases {
+		cfg := ReadConfig(map[string]string{"ssl-certificate-preference": tc.preference})
+
+		if cfg.SSLCertificatePreference != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", n, tc.expect, cfg.SSLCertificatePreference)
+		}
+	}
+}
+
+func TestIngressConflictResolutionParsing(t *testing.T) {
+	testCases := map[string]struct {
+		resolution string
+		expect     string
+	}{
+		"nothing":  {"", ""},
+		"newest":   {"newest", "newest"},
+		"priority": {" Priority ", "priority"},
+		"invalid":  {"first", ""},
+	}
+
+	for n, tc := range testCases {
+		cfg := ReadConfig(map[string]string{"ingress-conflict-resolution": tc.resolution})
+
+		if cfg.IngressConflictResolution != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", n, tc.expect, cfg.IngressConflictResolution)
+		}
+	}
+}
+
+func TestSSLPassthroughUnmatchedSNIParsing(t *testing.T) {
+	testCases := map[string]struct {
+		action string
+		expect string
+	}{
+		"nothing":  {"", "terminate"},
+		"reject":   {"reject", "reject"},
+		"fallback": {" Fallback ", "fallback"},
+		"invalid":  {"drop", "terminate"},
+	}
+
+	for n, tc := range testCases {
+		cfg := ReadConfig(map[string]string{"ssl-passthrough-unmatched-sni": tc.action})
+
+		if cfg.SSLPassthroughUnmatchedSNI != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", n, tc.expect, cfg.SSLPassthroughUnmatchedSNI)
+		}
+	}
+}
+
 func TestLuaSharedDictsParsing(t *testing.T) {
 	testsCases := []struct {
 		name   string
@@ -463,6 +523,33 @@ func TestSplitAndTrimSpace(t *testing.T) {
 	}
 }
 
+func TestCIDRListsNormalization(t *testing.T) {
+	cfg := ReadConfig(map[string]string{
+		"whitelist-source-range":         "[2001:DB8::]/32, 2001:db8:0::/32, ::ffff:10.0.0.1, fe80::1%eth0",
+		"denylist-source-range":          "10.1.2.3/8, ::ffff:10.0.0.0/104, invalid",
+		"proxy-real-ip-cidr":             "[::1], ::ffff:192.168.0.0/112",
+		"block-cidrs":                    "2001:0db8::0001",
+		"upstream-attempts-header-cidrs": "10.0.0.0/8, 10.1.0.0/8, invalid",
+	})
+
+	testCases := map[string]struct {
+		expect []string
+		actual []string
+	}{
+		"whitelist-source-range":         {[]string{"2001:db8::/32", "10.0.0.1", "fe80::1"}, cfg.WhitelistSourceRange},
+		"denylist-source-range":          {[]string{"10.0.0.0/8"}, cfg.DenylistSourceRange},
+		"proxy-real-ip-cidr":             {[]string{"::1", "192.168.0.0/16"}, cfg.ProxyRealIPCIDR},
+		"block-cidrs":                    {[]string{"2001:db8::1"}, cfg.BlockCIDRs},
+		"upstream-attempts-header-cidrs": {[]string{"10.0.0.0/8"}, cfg.UpstreamAttemptsHeaderCIDRs},
+	}
+
+	for name, tc := range testCases {
+		if !reflect.DeepEqual(tc.expect, tc.actual) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", name, tc.expect, tc.actual)
+		}
+	}
+}
+
 func TestDictStrToKb(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -27,10 +27,12 @@ import (
 	"io"
 	"math/big"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -40,8 +42,14 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failoverorigin"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestid"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamsigning"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
@@ -206,6 +214,18 @@ The json format should follow what's expected by lua:
 		hsts_max_age = %v,
 		hsts_include_subdomains = %t,
 		hsts_preload = %t,
+
+		auth_cookie_session = { store = "%v", threshold = %v, name = "%v", ttl = %v,
+			redis_host = "%v", redis_port = %v },
+
+		enable_tls_fingerprinting = %t,
+		tls_fingerprint_headers = %t,
+
+		upstream_attempts_header = %t,
+
+		bot_mitigation = { key = "%v", ttl = %v },
+
+		connect_backoff = { base = %v, max = %v },
 */
 
 type LuaConfig struct {
@@ -221,6 +241,52 @@ type LuaConfig struct {
 	HSTSMaxAge              string         `json:"hsts_max_age"`
 	HSTSIncludeSubdomains   bool           `json:"hsts_include_subdomains"`
 	HSTSPreload             bool           `json:"hsts_preload"`
+
+	AuthCookieSession LuaAuthCookieSession `json:"auth_cookie_session"`
+
+	EnableTLSFingerprinting bool `json:"enable_tls_fingerprinting"`
+	TLSFingerprintHeaders   bool `json:"tls_fingerprint_headers"`
+
+	EnableTLSHandshakeMetrics bool `json:"enable_tls_handshake_metrics"`
+
+	UpstreamAttemptsHeader bool `json:"upstream_attempts_header"`
+
+	BotMitigation LuaBotMitigation `json:"bot_mitigation"`
+
+	OTLPAccessLog LuaOTLPAccessLog `json:"otlp_access_log"`
+
+	ConnectBackoff LuaConnectBackoff `json:"connect_backoff"`
+
+	Plugins []string `json:"plugins"`
+}
+
+// LuaConnectBackoff contains the configuration of the connect_backoff Lua module
+type LuaConnectBackoff struct {
+	Base int `json:"base"`
+	Max  int `json:"max"`
+}
+
+// LuaOTLPAccessLog contains the configuration of the otlp_access_log Lua module
+type LuaOTLPAccessLog struct {
+	Endpoint    string `json:"endpoint"`
+	BatchSize   int    `json:"batch_size"`
+	ServiceName string `json:"service_name"`
+}
+
+// LuaBotMitigation contains the configuration of the bot_mitigation Lua module
+type LuaBotMitigation struct {
+	Key string `json:"key"`
+	TTL int    `json:"ttl"`
+}
+
+// LuaAuthCookieSession contains the configuration of the auth_cookie_session Lua module
+type LuaAuthCookieSession struct {
+	Store     string `json:"store"`
+	Threshold int    `json:"threshold"`
+	Name      string `json:"name"`
+	TTL       int    `json:"ttl"`
+	RedisHost string `json:"redis_host"`
+	RedisPort int    `json:"redis_port"`
 }
 
 type LuaListenPorts struct {
@@ -278,6 +344,7 @@ var funcMap = text_template.FuncMap{
 	"buildLuaSharedDictionaries":      buildLuaSharedDictionaries,
 	"luaConfigurationRequestBodySize": luaConfigurationRequestBodySize,
 	"buildLocation":                   buildLocation,
+	"buildRegexLimits":                buildRegexLimits,
 	"buildAuthLocation":               buildAuthLocation,
 	"shouldApplyGlobalAuth":           shouldApplyGlobalAuth,
 	"buildAuthResponseHeaders":        buildAuthResponseHeaders,
@@ -289,6 +356,15 @@ var funcMap = text_template.FuncMap{
 	"changeHostPort":                  changeHostPort,
 	"buildProxyPass":                  buildProxyPass,
 	"filterRateLimits":                filterRateLimits,
+	"filterUpstreamKeepalives":        filterUpstreamKeepalives,
+	"buildRequestHeaderMaps":          buildRequestHeaderMaps,
+	"buildRequestHeaders":             buildRequestHeaders,
+	"buildResponseBodyRewrite":        buildResponseBodyRewrite,
+	"buildAcceptEncoding":             buildAcceptEncoding,
+	"buildLinkRewriteForLocation":     buildLinkRewriteForLocation,
+	"buildPathParameterHeaders":       buildPathParameterHeaders,
+	"buildRedirectURL":                buildRedirectURL,
+	"isRequestHeaderChanged":          isRequestHeaderChanged,
 	"buildRateLimitZones":             buildRateLimitZones,
 	"buildRateLimit":                  buildRateLimit,
 	"locationConfigForLua":            locationConfigForLua,
@@ -324,13 +400,37 @@ var funcMap = text_template.FuncMap{
 	"shouldLoadModSecurityModule":        shouldLoadModSecurityModule,
 	"buildHTTPListener":                  buildHTTPListener,
 	"buildHTTPSListener":                 buildHTTPSListener,
+	"usesClientIPAgent":                  usesClientIPAgent,
 	"buildOpentelemetryForLocation":      buildOpentelemetryForLocation,
 	"shouldLoadOpentelemetryModule":      shouldLoadOpentelemetryModule,
 	"buildModSecurityForLocation":        buildModSecurityForLocation,
 	"buildMirrorLocations":               buildMirrorLocations,
+	"buildFailoverOriginLocations":       buildFailoverOriginLocations,
+	"buildFailoverOriginForLocation":     buildFailoverOriginForLocation,
 	"shouldLoadAuthDigestModule":         shouldLoadAuthDigestModule,
 	"buildServerName":                    buildServerName,
 	"buildCorsOriginRegex":               buildCorsOriginRegex,
+	"buildGraphQLForLocation":            buildGraphQLForLocation,
+	"buildRetryPolicyForLocation":        buildRetryPolicyForLocation,
+	"buildChaosForLocation":              buildChaosForLocation,
+	"buildRequestIDForLocation":          buildRequestIDForLocation,
+	"buildBotMitigationForLocation":      buildBotMitigationForLocation,
+	"buildServerTimingForLocation":       buildServerTimingForLocation,
+	"buildLuaPluginsForLocation":         buildLuaPluginsForLocation,
+	"requestIDHeader":                    requestIDHeader,
+	"buildConcurrencyLimitForLocation":   buildConcurrencyLimitForLocation,
+	"hasConcurrencyLimits":               hasConcurrencyLimits,
+	"buildSkipAccessLogPaths":            buildSkipAccessLogPaths,
+	"buildGeoIPVariables":                buildGeoIPVariables,
+	"buildGeoAccessForLocation":          buildGeoAccessForLocation,
+	"buildAttributionForLocation":        buildAttributionForLocation,
+	"buildProxyCacheZones":               buildProxyCacheZones,
+	"buildProxyCacheForLocation":         buildProxyCacheForLocation,
+	"buildUpstreamSigningForLocation":    buildUpstreamSigningForLocation,
+	"buildAuthTLSForServer":              buildAuthTLSForServer,
+	"buildAuthTLSForLocation":            buildAuthTLSForLocation,
+	"buildNextUpstreamForLocation":       buildNextUpstreamForLocation,
+	"buildNextUpstreamLocation":          buildNextUpstreamLocation,
 }
 
 // escapeLiteralDollar will replace the $ character with ${literal_dollar}
@@ -499,8 +599,8 @@ func needsRewrite(location *ingress.Location) bool {
 	return false
 }
 
-// enforceRegexModifier checks if the "rewrite-target" or "use-regex" annotation
-// is used on any location path within a server
+// enforceRegexModifier checks if the "rewrite-target", "use-regex" or
+// "path-template" annotation is used on any location path within a server
 func enforceRegexModifier(input interface{}) bool {
 	locations, ok := input.([]*ingress.Location)
 	if !ok {
@@ -509,7 +609,7 @@ func enforceRegexModifier(input interface{}) bool {
 	}
 
 	for _, location := range locations {
-		if needsRewrite(location) || location.Rewrite.UseRegex {
+		if needsRewrite(location) || location.Rewrite.UseRegex || location.PathTemplate.Enabled {
 			return true
 		}
 	}
@@ -518,7 +618,8 @@ func enforceRegexModifier(input interface{}) bool {
 
 // buildLocation produces the location string, if the ingress has redirects
 // (specified through the nginx.ingress.kubernetes.io/rewrite-target annotation)
-func buildLocation(input interface{}, enforceRegex bool) string {
+// Regular expression locations are prefixed with regexLimits.
+func buildLocation(input interface{}, enforceRegex bool, regexLimits string) string {
 	location, ok := input.(*ingress.Location)
 	if !ok {
 		klog.Errorf("expected an '*ingress.Location' type but %T was returned", input)
@@ -527,7 +628,7 @@ func buildLocation(input interface{}, enforceRegex bool) string {
 
 	path := location.Path
 	if enforceRegex {
-		return fmt.Sprintf(`~* "^%s"`, path)
+		return fmt.Sprintf(`~* "%s^%s"`, regexLimits, path)
 	}
 
 	if location.PathType != nil && *location.PathType == networkingv1.PathTypeExact {
@@ -537,6 +638,25 @@ func buildLocation(input interface{}, enforceRegex bool) string {
 	return path
 }
 
+// buildRegexLimits returns the PCRE settings limiting the match and
+// recursion calls of the regular expression locations, if configured
+func buildRegexLimits(input interface{}) string {
+	cfg, ok := input.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was returned", input)
+		return ""
+	}
+
+	limits := ""
+	if cfg.PCREMatchLimit > 0 {
+		limits += fmt.Sprintf("(*LIMIT_MATCH=%d)", cfg.PCREMatchLimit)
+	}
+	if cfg.PCRERecursionLimit > 0 {
+		limits += fmt.Sprintf("(*LIMIT_RECURSION=%d)", cfg.PCRERecursionLimit)
+	}
+	return limits
+}
+
 func buildAuthLocation(input interface{}, globalExternalAuthURL string) string {
 	location, ok := input.(*ingress.Location)
 	if !ok {
@@ -733,6 +853,9 @@ func buildProxyPass(_ string, b, loc interface{}) string {
 	}
 
 	upstreamName := "upstream_balancer"
+	if location.UpstreamKeepalive.Enabled {
+		upstreamName = upstreamKeepaliveName(location.UpstreamKeepalive.Connections)
+	}
 
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
@@ -762,18 +885,32 @@ func buildProxyPass(_ string, b, loc interface{}) string {
 		return defProxyPass
 	}
 
-	if location.Rewrite.Target != "" {
-		var xForwardedPrefix string
-
-		if location.XForwardedPrefix != "" {
-			xForwardedPrefix = fmt.Sprintf("%s X-Forwarded-Prefix %q;\n", proxySetHeader(location), location.XForwardedPrefix)
-		}
+	var xForwardedPrefix string
+	if location.XForwardedPrefix != "" {
+		xForwardedPrefix = fmt.Sprintf("%s X-Forwarded-Prefix %q;\n", proxySetHeader(location), location.XForwardedPrefix)
+	}
 
+	if location.Rewrite.Target != "" {
 		return fmt.Sprintf(`
 rewrite "(?i)%s" %s break;
 %v%v %s%s;`, path, location.Rewrite.Target, xForwardedPrefix, proxyPass, proto, upstreamName)
 	}
 
+	if location.Rewrite.ServeSubpath != "" {
+		// the prefix without a trailing slash is redirected like a directory,
+		// so the relative links of the upstream resolve below the prefix
+		prefix := regexp.QuoteMeta(strings.TrimSuffix(path, "/"))
+		var redirect string
+		if prefix != "" {
+			redirect = fmt.Sprintf(`
+rewrite "(?i)^%s$" $scheme://$http_host$uri/ permanent;`, prefix)
+		}
+
+		return fmt.Sprintf(`%s
+rewrite "(?i)^%s/(.*)" %s/$1 break;
+%v%v %s%s;`, redirect, prefix, strings.TrimSuffix(location.Rewrite.ServeSubpath, "/"), xForwardedPrefix, proxyPass, proto, upstreamName)
+	}
+
 	// default proxy_pass
 	return defProxyPass
 }
@@ -798,6 +935,257 @@ func filterRateLimits(input interface{}) []ratelimit.Config {
 	return ratelimits
 }
 
+// upstreamKeepaliveName returns the name of the upstream keeping up to
+// connections idle keepalive connections to the endpoints
+func upstreamKeepaliveName(connections int) string {
+	return fmt.Sprintf("upstream_balancer_keepalive_%d", connections)
+}
+
+// filterUpstreamKeepalives returns the sorted numbers of keepalive connections
+// of the locations with keepalive annotations. Each one requires a dedicated
+// upstream as the size of the connection pool is set per upstream.
+func filterUpstreamKeepalives(input interface{}) []int {
+	found := sets.Set[int]{}
+
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return []int{}
+	}
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			if loc.UpstreamKeepalive.Enabled {
+				found.Insert(loc.UpstreamKeepalive.Connections)
+			}
+		}
+	}
+	return sets.List(found)
+}
+
+// requestHeaderVariable returns the variable containing the value of a
+// header added to the requests when the client did not send it
+func requestHeaderVariable(header requestheaders.Header) string {
+	hash := sha1.Sum([]byte(strings.ToLower(header.Name) + ":" + header.Value)) // #nosec
+	return fmt.Sprintf("request_header_%x", hash[:5])
+}
+
+// clientHeaderVariable returns the variable containing a header sent by the client
+func clientHeaderVariable(name string) string {
+	return "http_" + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// buildRequestHeaderMaps produces the maps defining the variables of the
+// headers added to the requests by the request-headers-add annotation
+func buildRequestHeaderMaps(input interface{}) []string {
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return []string{}
+	}
+
+	maps := sets.Set[string]{}
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			for _, header := range loc.RequestHeaders.Add {
+				clientVariable := clientHeaderVariable(header.Name)
+				maps.Insert(fmt.Sprintf("map $%s $%s {\n        \"\" %q;\n        default $%s;\n    }",
+					clientVariable, requestHeaderVariable(header), header.Value, clientVariable))
+			}
+		}
+	}
+	return sets.List(maps)
+}
+
+// buildRequestHeaders produces the directives changing the headers of the
+// requests sent to the upstream of a location
+func buildRequestHeaders(loc interface{}, directive string) []string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return []string{}
+	}
+
+	lines := []string{}
+	for _, name := range location.RequestHeaders.Remove {
+		lines = append(lines, fmt.Sprintf("%s %s \"\";", directive, name))
+	}
+	for _, header := range location.RequestHeaders.Set {
+		lines = append(lines, fmt.Sprintf("%s %s %q;", directive, header.Name, header.Value))
+	}
+	for _, header := range location.RequestHeaders.Add {
+		lines = append(lines, fmt.Sprintf("%s %s $%s;", directive, header.Name, requestHeaderVariable(header)))
+	}
+	return lines
+}
+
+// buildPathParameterHeaders produces the directives sending the parameters
+// captured by the path template of a location to the upstream
+func buildPathParameterHeaders(loc interface{}, directive string) []string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return []string{}
+	}
+
+	lines := []string{}
+	for _, param := range location.PathParameters {
+		lines = append(lines, fmt.Sprintf("%s %s $%s%s;", directive,
+			pathtemplate.HeaderName(location.PathTemplate.HeaderPrefix, param), pathtemplate.VariablePrefix, param))
+	}
+	return lines
+}
+
+// buildRedirectURL returns the URL of the redirect of a location, followed
+// by the path and the query string of the request when they are kept
+func buildRedirectURL(loc interface{}) string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return ""
+	}
+
+	redirectURL := location.Redirect.URL
+	if location.Redirect.AppendPath {
+		// the path as sent by the client, $uri is decoded
+		redirectURL = strings.TrimSuffix(redirectURL, "/") + "$request_uri_path"
+	}
+
+	if location.Redirect.KeepQuery {
+		if strings.Contains(redirectURL, "?") {
+			redirectURL += "$redirect_args"
+		} else {
+			redirectURL += "$is_args$args"
+		}
+	}
+
+	return redirectURL
+}
+
+// buildResponseBodyRewrite produces the sub_filter directives replacing
+// strings in the bodies of the responses of a location
+func buildResponseBodyRewrite(loc interface{}) []string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return []string{}
+	}
+
+	rewrite := location.ResponseBodyRewrite
+	if len(rewrite.Rules) == 0 {
+		return []string{}
+	}
+
+	lines := []string{}
+	for _, rule := range rewrite.Rules {
+		lines = append(lines, fmt.Sprintf("sub_filter %q %q;", rule.Find, rule.Replace))
+	}
+	if rewrite.Once {
+		lines = append(lines, "sub_filter_once on;")
+	} else {
+		lines = append(lines, "sub_filter_once off;")
+	}
+	if len(rewrite.Types) > 0 {
+		lines = append(lines, fmt.Sprintf("sub_filter_types %s;", strings.Join(rewrite.Types, " ")))
+	}
+	return lines
+}
+
+// rewritesResponseBodies returns true when the bodies of the responses of
+// a location are rewritten, by sub_filter or the link_rewrite Lua module
+func rewritesResponseBodies(location *ingress.Location) bool {
+	return len(location.ResponseBodyRewrite.Rules) > 0 || location.LinkRewrite.Enabled
+}
+
+// buildAcceptEncoding asks the upstream of a location rewriting the bodies
+// of the responses for uncompressed responses, NGINX can not rewrite
+// compressed ones
+func buildAcceptEncoding(loc interface{}, directive string) string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return ""
+	}
+
+	if !rewritesResponseBodies(location) || changedByRequestHeaders(location, "Accept-Encoding") {
+		return ""
+	}
+	return fmt.Sprintf("%s Accept-Encoding \"\";", directive)
+}
+
+// buildLinkRewriteForLocation sets the variables read by the link_rewrite
+// Lua module and enables its body filter. The links of the upstream below
+// the path of the upstream the location is mapped to are rewritten below
+// the path prefix of the location.
+func buildLinkRewriteForLocation(location *ingress.Location) string {
+	if !location.LinkRewrite.Enabled {
+		return ""
+	}
+
+	prefix := location.LinkRewrite.Prefix
+	if prefix == "" {
+		if location.Rewrite.UseRegex || location.Rewrite.Target != "" {
+			klog.Warningf("The links of the location %q are not rewritten, the rewrite-links-prefix annotation is required with a regular expression path", location.Path)
+			return ""
+		}
+		prefix = strings.TrimSuffix(location.Path, "/")
+	}
+
+	upstreamPrefix := prefix
+	switch {
+	case location.Rewrite.ServeSubpath != "":
+		upstreamPrefix = strings.TrimSuffix(location.Rewrite.ServeSubpath, "/")
+	case location.Rewrite.Target != "":
+		// the path of the upstream is the part of the target before
+		// the first capture group, like /app for /app/$2
+		target := location.Rewrite.Target
+		if i := strings.Index(target, "$"); i >= 0 {
+			target = target[:i]
+		}
+		upstreamPrefix = strings.TrimSuffix(target, "/")
+	}
+
+	return fmt.Sprintf(`set $rewrite_links "true";
+set $rewrite_links_prefix %q;
+set $rewrite_links_upstream_prefix %q;
+set $rewrite_links_upstream_urls %q;
+body_filter_by_lua_file /etc/nginx/lua/nginx/ngx_conf_body_filter.lua;
+`, prefix, upstreamPrefix, strings.Join(location.LinkRewrite.UpstreamURLs, " "))
+}
+
+// isRequestHeaderChanged returns true when the annotations of a location
+// change a header, which must not be set by the proxy-set-headers ConfigMap
+// as NGINX would send it twice
+func isRequestHeaderChanged(loc interface{}, name string) bool {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return false
+	}
+
+	if rewritesResponseBodies(location) && strings.EqualFold(name, "Accept-Encoding") {
+		return true
+	}
+	return changedByRequestHeaders(location, name)
+}
+
+// changedByRequestHeaders returns true when the request headers annotations
+// of a location change a header
+func changedByRequestHeaders(location *ingress.Location, name string) bool {
+	for _, headers := range [][]requestheaders.Header{location.RequestHeaders.Set, location.RequestHeaders.Add} {
+		for _, header := range headers {
+			if strings.EqualFold(header.Name, name) {
+				return true
+			}
+		}
+	}
+	for _, header := range location.RequestHeaders.Remove {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // buildRateLimitZones produces an array of limit_conn_zone in order to allow
 // rate limiting of request. Each Ingress rule could have up to three zones, one
 // for connection limit by IP address, one for limiting requests per minute, and
@@ -1373,9 +1761,15 @@ func buildHTTPListener(t, s interface{}) string {
 
 	co := commonListenOptions(&tc, hostname)
 
+	if ports, ok := tc.ClassListenPorts[hostname]; ok {
+		return strings.Join(classListener(&tc, co, ports.HTTP, ""), "\n")
+	}
+
 	out = append(out, httpListener(addrV4, co, &tc)...)
+	out = append(out, clientIPAgentListener(addrV4, co, tc.ListenPorts.ClientIPAgentHTTP, "")...)
 
 	if !tc.IsIPV6Enabled {
+		out = append(out, defaultClassListeners(&tc, hostname, co, false)...)
 		return strings.Join(out, "\n")
 	}
 
@@ -1385,6 +1779,8 @@ func buildHTTPListener(t, s interface{}) string {
 	}
 
 	out = append(out, httpListener(addrV6, co, &tc)...)
+	out = append(out, clientIPAgentListener(addrV6, co, tc.ListenPorts.ClientIPAgentHTTP, "")...)
+	out = append(out, defaultClassListeners(&tc, hostname, co, false)...)
 
 	return strings.Join(out, "\n")
 }
@@ -1406,14 +1802,20 @@ func buildHTTPSListener(t, s interface{}) string {
 
 	co := commonListenOptions(&tc, hostname)
 
+	if ports, ok := tc.ClassListenPorts[hostname]; ok {
+		return strings.Join(classListener(&tc, co, ports.HTTPS, "ssl"), "\n")
+	}
+
 	addrV4 := []string{""}
 	if len(tc.Cfg.BindAddressIpv4) > 0 {
 		addrV4 = tc.Cfg.BindAddressIpv4
 	}
 
 	out = append(out, httpsListener(addrV4, co, &tc)...)
+	out = append(out, clientIPAgentListener(addrV4, co, tc.ListenPorts.ClientIPAgentHTTPS, "ssl")...)
 
 	if !tc.IsIPV6Enabled {
+		out = append(out, defaultClassListeners(&tc, hostname, co, true)...)
 		return strings.Join(out, "\n")
 	}
 
@@ -1423,10 +1825,72 @@ func buildHTTPSListener(t, s interface{}) string {
 	}
 
 	out = append(out, httpsListener(addrV6, co, &tc)...)
+	out = append(out, clientIPAgentListener(addrV6, co, tc.ListenPorts.ClientIPAgentHTTPS, "ssl")...)
+	out = append(out, defaultClassListeners(&tc, hostname, co, true)...)
 
 	return strings.Join(out, "\n")
 }
 
+// classListener returns the listen directives of a server listening on a
+// port of its IngressClass
+func classListener(tc *config.TemplateConfig, co string, port int, ssl string) []string {
+	addresses := []string{""}
+	if len(tc.Cfg.BindAddressIpv4) > 0 {
+		addresses = tc.Cfg.BindAddressIpv4
+	}
+	if tc.IsIPV6Enabled {
+		if len(tc.Cfg.BindAddressIpv6) > 0 {
+			addresses = append(addresses, tc.Cfg.BindAddressIpv6...)
+		} else {
+			addresses = append(addresses, "[::]")
+		}
+	}
+
+	out := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lo := []string{"listen"}
+
+		if address == "" {
+			lo = append(lo, fmt.Sprintf("%v", port))
+		} else {
+			lo = append(lo, fmt.Sprintf("%v:%v", address, port))
+		}
+
+		lo = append(lo, co, ssl+";")
+		out = append(out, strings.Join(lo, " "))
+	}
+
+	return out
+}
+
+// defaultClassListeners returns the listen directives of the catch-all
+// server on the ports of the IngressClasses, to serve the requests for the
+// hosts not defined by the Ingresses of a class
+func defaultClassListeners(tc *config.TemplateConfig, hostname, co string, https bool) []string {
+	out := make([]string, 0)
+	if hostname != "_" {
+		return out
+	}
+
+	ports := sets.New[int]()
+	for _, classPorts := range tc.ClassListenPorts {
+		if https {
+			ports.Insert(classPorts.HTTPS)
+		} else {
+			ports.Insert(classPorts.HTTP)
+		}
+	}
+
+	ssl := ""
+	if https {
+		ssl = "ssl"
+	}
+	for _, port := range sets.List(ports) {
+		out = append(out, classListener(tc, co, port, ssl)...)
+	}
+	return out
+}
+
 func commonListenOptions(template *config.TemplateConfig, hostname string) string {
 	var out []string
 
@@ -1500,6 +1964,46 @@ func httpsListener(addresses []string, co string, tc *config.TemplateConfig) []s
 	return out
 }
 
+// clientIPAgentListener returns the listen directives of the port receiving
+// the connections of the client IP agent, always prefixed with a PROXY
+// protocol header. It returns nothing when the port is disabled.
+func clientIPAgentListener(addresses []string, co string, port int, ssl string) []string {
+	out := make([]string, 0)
+	if port == 0 {
+		return out
+	}
+
+	for _, address := range addresses {
+		lo := []string{"listen"}
+
+		if address == "" {
+			lo = append(lo, fmt.Sprintf("%v", port))
+		} else {
+			lo = append(lo, fmt.Sprintf("%v:%v", address, port))
+		}
+
+		if !strings.Contains(co, "proxy_protocol") {
+			lo = append(lo, "proxy_protocol")
+		}
+
+		lo = append(lo, co, ssl+";")
+		out = append(out, strings.Join(lo, " "))
+	}
+
+	return out
+}
+
+// usesClientIPAgent returns true when the client IP agent ports are enabled
+func usesClientIPAgent(t interface{}) bool {
+	tc, ok := t.(config.TemplateConfig)
+	if !ok {
+		klog.Errorf("expected a 'config.TemplateConfig' type but %T was returned", t)
+		return false
+	}
+
+	return tc.ListenPorts != nil && (tc.ListenPorts.ClientIPAgentHTTP > 0 || tc.ListenPorts.ClientIPAgentHTTPS > 0)
+}
+
 func buildOpentelemetryForLocation(isOTEnabled, isOTTrustSet bool, location *ingress.Location) string {
 	isOTEnabledInLoc := location.Opentelemetry.Enabled
 	isOTSetInLoc := location.Opentelemetry.Set
@@ -1527,6 +2031,13 @@ func buildOpentelemetryForLocation(isOTEnabled, isOTTrustSet bool, location *ing
 	} else {
 		opc += "\nopentelemetry_trust_incoming_spans on;"
 	}
+
+	for _, attribute := range location.Opentelemetry.Attributes {
+		opc += fmt.Sprintf("\nopentelemetry_attribute %q %q;", attribute.Key, attribute.Value)
+	}
+	if location.RequestID.Enabled() {
+		opc += "\nopentelemetry_attribute \"http.request.id\" \"$req_id\";"
+	}
 	return opc
 }
 
@@ -1605,6 +2116,431 @@ func buildModSecurityForLocation(cfg config.Configuration, location *ingress.Loc
 	return buffer.String()
 }
 
+// buildRetryPolicyForLocation sets the variables read by the Lua balancer
+// to apply the retry policy of the location
+func buildRetryPolicyForLocation(location *ingress.Location) string {
+	if !location.RetryPolicy.Enabled {
+		return ""
+	}
+
+	return fmt.Sprintf(`set $retry_policy_max_retries "%v";
+set $retry_policy_per_try_timeout "%v";
+set $retry_policy_budget_percent "%v";
+`,
+		location.RetryPolicy.MaxRetries,
+		location.RetryPolicy.PerTryTimeout.Seconds(),
+		location.RetryPolicy.BudgetPercent,
+	)
+}
+
+// buildChaosForLocation sets the variables read by the chaos Lua module to
+// inject faults in the requests of the location. The faults are only
+// injected when the controller runs with --enable-chaos-injection.
+func buildChaosForLocation(enabled bool, location *ingress.Location) string {
+	if !enabled || !location.Chaos.Enabled() {
+		return ""
+	}
+
+	return fmt.Sprintf(`set $chaos_abort_percent "%v";
+set $chaos_delay_ms "%v";
+set $chaos_delay_percent "%v";
+`,
+		location.Chaos.AbortPercent,
+		location.Chaos.DelayMs,
+		location.Chaos.DelayPercent,
+	)
+}
+
+// buildRequestIDForLocation sets the variables read by the request ID Lua
+// module to replace the $req_id of the location
+func buildRequestIDForLocation(location *ingress.Location) string {
+	if !location.RequestID.Enabled() {
+		return ""
+	}
+
+	return fmt.Sprintf(`set $request_id_policy %q;
+set $request_id_header %q;
+set $request_id_format %q;
+`,
+		location.RequestID.Policy,
+		location.RequestID.Header,
+		location.RequestID.Format,
+	)
+}
+
+// requestIDHeader returns the header containing the request ID sent to
+// the upstream of the location
+func requestIDHeader(location *ingress.Location) string {
+	if location.RequestID.Header == "" {
+		return requestid.DefaultHeader
+	}
+	return location.RequestID.Header
+}
+
+// buildBotMitigationForLocation sets the variables read by the bot
+// mitigation Lua module to challenge or block the suspicious clients
+func buildBotMitigationForLocation(location *ingress.Location) string {
+	bm := location.BotMitigation
+	if !bm.Enabled() {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "set $bot_challenge %q;\n", bm.Challenge)
+	for _, variable := range []struct {
+		name  string
+		value string
+	}{
+		{"bot_challenge_user_agents", bm.ChallengeUserAgents},
+		{"bot_challenge_fingerprints", strings.Join(bm.ChallengeFingerprints, ",")},
+		{"bot_block_user_agents", bm.BlockUserAgents},
+		{"bot_allowlist_source_range", strings.Join(bm.AllowlistSourceRange, ",")},
+		{"bot_allowlist_user_agents", bm.AllowlistUserAgents},
+	} {
+		if variable.value != "" {
+			fmt.Fprintf(&buffer, "set $%v %v;\n", variable.name, escapeLiteralDollar(strconv.Quote(variable.value)))
+		}
+	}
+	if bm.ChallengeRate > 0 {
+		fmt.Fprintf(&buffer, "set $bot_challenge_rate \"%v\";\n", bm.ChallengeRate)
+	}
+
+	return buffer.String()
+}
+
+// buildServerTimingForLocation sets the variables read by the server timing
+// Lua module to add the Server-Timing header to the responses
+func buildServerTimingForLocation(location *ingress.Location) string {
+	if !location.ServerTiming.Enabled {
+		return ""
+	}
+
+	buffer := "set $server_timing \"true\";\n"
+	if len(location.ServerTiming.AllowOrigins) > 0 {
+		buffer += fmt.Sprintf("set $server_timing_allow_origin %q;\n", strings.Join(location.ServerTiming.AllowOrigins, ", "))
+	}
+
+	return buffer
+}
+
+// buildLuaPluginsForLocation sets the variable read by the plugins Lua
+// module to run the Lua plugins enabled in the location
+func buildLuaPluginsForLocation(location *ingress.Location) string {
+	if len(location.LuaPlugins) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("set $lua_plugins %q;\n", strings.Join(location.LuaPlugins, ","))
+}
+
+// buildConcurrencyLimitForLocation sets the variables read by the concurrency
+// limit Lua module to limit the concurrent requests to the backends of the Ingress
+func buildConcurrencyLimitForLocation(cfg config.Configuration, location *ingress.Location) string {
+	if location.ConcurrencyLimit.Limit == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`set $concurrency_limit "%v";
+set $concurrency_limit_queue "%v";
+set $concurrency_limit_queue_timeout "%v";
+set $concurrency_limit_status "%v";
+`,
+		location.ConcurrencyLimit.Limit,
+		location.ConcurrencyLimit.Queue,
+		location.ConcurrencyLimit.QueueTimeout.Seconds(),
+		cfg.LimitConnStatusCode,
+	)
+}
+
+// hasConcurrencyLimits returns true when a location limits the concurrent
+// requests to the backends of its Ingress
+func hasConcurrencyLimits(s interface{}) bool {
+	servers, ok := s.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '[]*ingress.Server' type but %T was returned", s)
+		return false
+	}
+
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if location.ConcurrencyLimit.Limit > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildSkipAccessLogPaths returns the keys of the $loggable_path map, the
+// namespace and name of an Ingress followed by a path that is not logged
+func buildSkipAccessLogPaths(s interface{}) []string {
+	servers, ok := s.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '[]*ingress.Server' type but %T was returned", s)
+		return nil
+	}
+
+	keys := sets.New[string]()
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if location.Ingress == nil {
+				continue
+			}
+			for _, path := range location.Logs.SkipPaths {
+				keys.Insert(fmt.Sprintf("%v/%v:%v", location.Ingress.Namespace, location.Ingress.Name, path))
+			}
+		}
+	}
+	return sets.List(keys)
+}
+
+// buildGeoIPVariables returns the maps of the $geo_country_code and $geo_asn
+// variables from the variables of the loaded GeoIP2 databases. The variables
+// are empty when the databases are not loaded.
+func buildGeoIPVariables(cfg config.Configuration, files *[]string) string {
+	country, asn := `""`, `""`
+	if cfg.UseGeoIP2 && files != nil {
+		for _, file := range *files {
+			switch strings.TrimSuffix(file, ".mmdb") {
+			case "GeoLite2-Country", "GeoIP2-Country":
+				country = "$geoip2_country_code"
+			case "GeoLite2-City", "GeoIP2-City":
+				if country == `""` {
+					country = "$geoip2_city_country_code"
+				}
+			case "GeoLite2-ASN", "GeoIP2-ASN", "GeoIP2-ISP":
+				asn = "$geoip2_asn"
+			}
+		}
+	}
+
+	return fmt.Sprintf(`map $remote_addr $geo_country_code {
+    default %v;
+}
+
+map $remote_addr $geo_asn {
+    default %v;
+}
+`, country, asn)
+}
+
+// buildGeoAccessForLocation rejects the requests from the countries and
+// autonomous systems not allowed to access the location
+func buildGeoAccessForLocation(location *ingress.Location) string {
+	rules := []struct {
+		variable string
+		operator string
+		values   []string
+	}{
+		{"$geo_country_code", "~", location.GeoAccess.DenyCountries},
+		{"$geo_country_code", "!~", location.GeoAccess.AllowCountries},
+		{"$geo_asn", "~", location.GeoAccess.DenyASNs},
+		{"$geo_asn", "!~", location.GeoAccess.AllowASNs},
+	}
+
+	buffer := new(bytes.Buffer)
+	for _, rule := range rules {
+		if len(rule.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(buffer, `if (%v %v "^(%v)$") {
+    return 403;
+}
+`, rule.variable, rule.operator, strings.Join(rule.values, "|"))
+	}
+
+	return buffer.String()
+}
+
+// buildAttributionForLocation sets the variables read by the attribution
+// Lua module to sign the attribution header of the location
+func buildAttributionForLocation(cfg config.Configuration, location *ingress.Location) string {
+	if !location.Attribution.Enabled {
+		return ""
+	}
+
+	// the request only reaches the backend once authenticated
+	auth := "none"
+	switch {
+	case isLocationInLocationList(location, cfg.NoAuthLocations):
+	case location.ExternalAuth.URL != "":
+		auth = "external"
+	case location.EnableGlobalAuth && cfg.GlobalExternalAuth.URL != "":
+		auth = "external"
+	case location.BasicDigestAuth.Secured:
+		auth = location.BasicDigestAuth.Type
+	}
+
+	return fmt.Sprintf(`set $attribution_header "%v";
+set $attribution_key_id "%v";
+set $attribution_key_file "%v";
+set $attribution_key_sha "%v";
+set $attribution_auth "%v";
+`,
+		location.Attribution.Header,
+		location.Attribution.KeyID,
+		location.Attribution.KeyFile,
+		location.Attribution.KeySHA,
+		auth,
+	)
+}
+
+// buildProxyCacheZones returns the cache zones of the Ingresses caching
+// the responses of their backends, one zone per Ingress
+func buildProxyCacheZones(cfg config.Configuration, servers []*ingress.Server) []string {
+	zones := sets.Set[string]{}
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			if loc.ProxyCache.Enabled {
+				zones.Insert(loc.ProxyCache.Zone)
+			}
+		}
+	}
+
+	paths := make([]string, 0, zones.Len())
+	for _, zone := range sets.List(zones) {
+		paths = append(paths, fmt.Sprintf("proxy_cache_path %v/%v levels=1:2 keys_zone=%v:%v max_size=%v inactive=%v use_temp_path=off;",
+			proxycache.Directory, zone, zone, cfg.ProxyCacheZoneSize, cfg.ProxyCacheMaxSize, cfg.ProxyCacheInactive))
+	}
+	return paths
+}
+
+// buildProxyCacheForLocation configures the caching of the responses of a location
+func buildProxyCacheForLocation(location *ingress.Location) string {
+	if !location.ProxyCache.Enabled {
+		return ""
+	}
+
+	variables := func(components []string, sep string) string {
+		vars := make([]string, 0, len(components))
+		for _, component := range components {
+			vars = append(vars, proxycache.Variable(component))
+		}
+		return strings.Join(vars, sep)
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "proxy_cache %v;\n", location.ProxyCache.Zone)
+	fmt.Fprintf(&buffer, "proxy_cache_key \"%v\";\n", variables(location.ProxyCache.Key, proxycache.KeySeparator))
+	for _, valid := range location.ProxyCache.Valid {
+		fmt.Fprintf(&buffer, "proxy_cache_valid %v;\n", valid)
+	}
+	if len(location.ProxyCache.Bypass) > 0 {
+		fmt.Fprintf(&buffer, "proxy_cache_bypass %v;\n", variables(location.ProxyCache.Bypass, " "))
+	}
+	if len(location.ProxyCache.NoCache) > 0 {
+		fmt.Fprintf(&buffer, "proxy_no_cache %v;\n", variables(location.ProxyCache.NoCache, " "))
+	}
+
+	return buffer.String()
+}
+
+// buildUpstreamSigningForLocation sets the variables read by the
+// upstream_signing Lua module and sends the headers it computes
+func buildUpstreamSigningForLocation(location *ingress.Location, proxySetHeader string) string {
+	signing := location.UpstreamSigning
+	if signing.Method == "" {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "set $upstream_signing_method \"%v\";\n", signing.Method)
+	fmt.Fprintf(&buffer, "set $upstream_signing_credentials_file \"%v\";\n", signing.CredentialsFile)
+	fmt.Fprintf(&buffer, "set $upstream_signing_credentials_sha \"%v\";\n", signing.CredentialsSHA)
+	fmt.Fprintf(&buffer, "set $upstream_signing_host \"%v\";\n", location.UpstreamVhost)
+
+	headers := [][]string{{"Date", "date"}, {"Digest", "digest"}, {"Signature", "signature"}}
+	if signing.Method == upstreamsigning.MethodAWSSigV4 {
+		fmt.Fprintf(&buffer, "set $upstream_signing_region \"%v\";\n", signing.Region)
+		fmt.Fprintf(&buffer, "set $upstream_signing_service \"%v\";\n", signing.Service)
+		headers = [][]string{
+			{"Authorization", "authorization"},
+			{"X-Amz-Date", "date"},
+			{"X-Amz-Content-Sha256", "content_sha256"},
+			{"X-Amz-Security-Token", "security_token"},
+		}
+	}
+
+	// the headers are only sent to the upstream, the requests
+	// to the authentication service keep the original ones
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "set $upstream_signing_%v \"\";\n", header[1])
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "%v %v $upstream_signing_%v;\n", proxySetHeader, header[0], header[1])
+	}
+
+	return buffer.String()
+}
+
+// buildAuthTLSForServer sets the variables read by the auth_tls Lua module
+// to authorize the client certificates of the requests of all the locations
+// of the server by their OU and subject alternative names, like the
+// auth-tls-match-cn check of the server
+func buildAuthTLSForServer(server *ingress.Server) string {
+	auth := server.CertificateAuth
+	if auth.CAFileName == "" {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+	if auth.MatchOU != "" {
+		fmt.Fprintf(&buffer, "set $auth_tls_match_ou %v;\n", escapeLiteralDollar(strconv.Quote(auth.MatchOU)))
+	}
+	if auth.MatchSAN != "" {
+		fmt.Fprintf(&buffer, "set $auth_tls_match_san %v;\n", escapeLiteralDollar(strconv.Quote(auth.MatchSAN)))
+	}
+
+	return buffer.String()
+}
+
+// buildAuthTLSForLocation sets the variables read by the auth_tls Lua module
+// to extract the attributes of the client certificates and sends them to
+// the upstream
+func buildAuthTLSForLocation(server *ingress.Server, proxySetHeader string) string {
+	auth := server.CertificateAuth
+	if auth.CAFileName == "" || len(auth.IdentityHeaders) == 0 {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+	attributes := []string{}
+	for _, header := range auth.IdentityHeaders {
+		if !slices.Contains(attributes, header.Attribute) {
+			attributes = append(attributes, header.Attribute)
+		}
+	}
+	fmt.Fprintf(&buffer, "set $auth_tls_identity_attributes \"%v\";\n", strings.Join(attributes, ","))
+	for _, attribute := range attributes {
+		fmt.Fprintf(&buffer, "set $auth_tls_identity_%v \"\";\n", attribute)
+	}
+	// the headers sent by the client are replaced, or removed when the
+	// client certificate does not have the attribute
+	for _, header := range auth.IdentityHeaders {
+		fmt.Fprintf(&buffer, "%v %v $auth_tls_identity_%v;\n", proxySetHeader, header.Header, header.Attribute)
+	}
+
+	return buffer.String()
+}
+
+// buildGraphQLForLocation sets the variables read by the graphql Lua module
+// to inspect the queries sent to a location
+func buildGraphQLForLocation(location *ingress.Location) string {
+	if !location.GraphQL.Enabled {
+		return ""
+	}
+
+	return fmt.Sprintf(`set $graphql_enabled "true";
+set $graphql_max_depth "%v";
+set $graphql_max_complexity "%v";
+set $graphql_introspection_allowlist "%v";
+`,
+		location.GraphQL.MaxDepth,
+		location.GraphQL.MaxComplexity,
+		strings.Join(location.GraphQL.IntrospectionAllowlist, ","),
+	)
+}
+
 func buildMirrorLocations(locs []*ingress.Location) string {
 	var buffer bytes.Buffer
 
@@ -1632,6 +2568,159 @@ proxy_pass "%v";
 	return buffer.String()
 }
 
+// failoverOriginStatusCodes are the status codes of the requests no endpoint
+// of the backend could serve, proxied to the external origin
+var failoverOriginStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// buildFailoverOriginLocations returns the locations proxying the requests
+// to the external origins of the locations of a server
+func buildFailoverOriginLocations(locs []*ingress.Location) string {
+	var buffer bytes.Buffer
+
+	mapped := sets.Set[string]{}
+
+	for _, loc := range locs {
+		if loc.FailoverOrigin.URL == "" || mapped.Has(loc.FailoverOrigin.Location) {
+			continue
+		}
+
+		mapped.Insert(loc.FailoverOrigin.Location)
+		// a named location keeps the method and the body of the request,
+		// error_page redirecting to a URI changes them to a GET. The origin
+		// is resolved when the requests are proxied, as it is not part of
+		// the cluster
+		buffer.WriteString(fmt.Sprintf(`location %v {
+set $failover_origin "%v";
+proxy_set_header Host "%v";
+proxy_ssl_server_name on;
+proxy_ssl_name "%v";
+%vproxy_pass $failover_origin$request_uri;
+}
+
+`, loc.FailoverOrigin.Location, strings.TrimSuffix(loc.FailoverOrigin.URL, "/"), loc.FailoverOrigin.Host, loc.FailoverOrigin.Host,
+			buildFailoverOriginSSL(&loc.FailoverOrigin)))
+	}
+
+	return buffer.String()
+}
+
+// buildFailoverOriginSSL returns the TLS configuration of the requests to the
+// external origin, which must not inherit the proxy_ssl_* directives of the
+// server used for its backends, like the client certificate
+func buildFailoverOriginSSL(origin *failoverorigin.Config) string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(fmt.Sprintf("proxy_ssl_verify %v;\n", origin.SSLVerify))
+	if origin.SSL.CAFileName != "" {
+		buffer.WriteString(fmt.Sprintf("proxy_ssl_trusted_certificate %v;\n", origin.SSL.CAFileName))
+	}
+
+	// an empty value disables the client certificate
+	clientCert := `""`
+	if origin.SSL.PemFileName != "" {
+		clientCert = origin.SSL.PemFileName
+	}
+	buffer.WriteString(fmt.Sprintf("proxy_ssl_certificate %v;\nproxy_ssl_certificate_key %v;\n", clientCert, clientCert))
+
+	return buffer.String()
+}
+
+// buildFailoverOriginForLocation sends the requests of the location no
+// endpoint of the backend could serve to the external origin, leaving the
+// status codes handled by the custom error pages and the next upstream
+func buildFailoverOriginForLocation(location *ingress.Location) string {
+	if location.FailoverOrigin.URL == "" {
+		return ""
+	}
+
+	handled := sets.New[int](location.CustomHTTPErrors...)
+	if location.NextUpstream.Enabled() {
+		handled.Insert(location.NextUpstream.StatusCodes...)
+	}
+
+	var buffer bytes.Buffer
+	for _, code := range failoverOriginStatusCodes {
+		if handled.Has(code) {
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("error_page %v = %v;\n", code, location.FailoverOrigin.Location))
+	}
+
+	return buffer.String()
+}
+
+// nextUpstreamMaxTries limits the tries of the requests proxied again because
+// of the response of the backend, as NGINX allows 10 internal redirects per
+// request and each retry takes two of them
+const nextUpstreamMaxTries = 4
+
+// buildNextUpstreamForLocation intercepts the responses of the backend retried
+// with another endpoint and sets the variables read by the @next_upstream location
+func buildNextUpstreamForLocation(location *ingress.Location, retryNonIdempotent bool) string {
+	if !location.NextUpstream.Enabled() {
+		return ""
+	}
+
+	// the retry policy replaces proxy-next-upstream and proxy-next-upstream-tries
+	tries, nextUpstream := location.Proxy.NextUpstreamTries, location.Proxy.NextUpstream
+	if location.RetryPolicy.Enabled {
+		tries, nextUpstream = location.RetryPolicy.MaxRetries+1, location.RetryPolicy.RetryOn
+	}
+	if tries <= 0 || tries > nextUpstreamMaxTries {
+		tries = nextUpstreamMaxTries
+	}
+
+	for _, v := range strings.Split(nextUpstream, " ") {
+		if v == nonIdempotent {
+			retryNonIdempotent = true
+		}
+	}
+
+	header := strings.ReplaceAll(strings.ToLower(location.NextUpstream.Header), "-", "_")
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf(`set $next_upstream_uri $uri;
+set $next_upstream_tries "%v";
+set $next_upstream_non_idempotent "%v";
+set $next_upstream_header "%v";
+`, tries, retryNonIdempotent, header))
+
+	// the custom error pages already enable the interception of the errors
+	if len(location.CustomHTTPErrors) == 0 || location.DisableProxyInterceptErrors {
+		buffer.WriteString("proxy_intercept_errors on;\n")
+	}
+
+	customErrors := sets.New[int](location.CustomHTTPErrors...)
+	for _, code := range location.NextUpstream.StatusCodes {
+		if customErrors.Has(code) {
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("error_page %v = @next_upstream;\n", code))
+	}
+
+	return buffer.String()
+}
+
+// buildNextUpstreamLocation returns the location proxying again the requests
+// whose response was intercepted by the locations of a server
+func buildNextUpstreamLocation(locs []*ingress.Location) string {
+	for _, loc := range locs {
+		if !loc.NextUpstream.Enabled() {
+			continue
+		}
+
+		return `location @next_upstream {
+internal;
+set $next_upstream_tried_peers $upstream_addr;
+content_by_lua_file /etc/nginx/lua/nginx/ngx_conf_next_upstream.lua;
+log_by_lua_file /etc/nginx/lua/nginx/ngx_conf_log_block.lua;
+}
+`
+	}
+
+	return ""
+}
+
 // shouldLoadAuthDigestModule determines whether or not the ngx_http_auth_digest_module module needs to be loaded.
 func shouldLoadAuthDigestModule(s interface{}) bool {
 	servers, ok := s.([]*ingress.Server)
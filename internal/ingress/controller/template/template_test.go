@@ -25,8 +25,10 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pmezard/go-difflib/difflib"
@@ -34,13 +36,38 @@ import (
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"k8s.io/ingress-nginx/internal/ingress/annotations/attribution"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botmitigation"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/chaos"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/concurrencylimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failoverorigin"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/geoaccess"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/graphql"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/linkrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/nextupstream"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentelemetry"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestid"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/responsebodyrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/servertiming"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamsigning"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/nginx/conf"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
 )
 
@@ -310,7 +337,7 @@ func TestQuote(t *testing.T) {
 func TestBuildLocation(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := "/"
-	actual := buildLocation(invalidType, true)
+	actual := buildLocation(invalidType, true, "")
 
 	if !reflect.DeepEqual(expected, actual) {
 		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
@@ -323,11 +350,51 @@ func TestBuildLocation(t *testing.T) {
 			Rewrite:  rewrite.Config{Target: tc.Target},
 		}
 
-		newLoc := buildLocation(loc, tc.enforceRegex)
+		newLoc := buildLocation(loc, tc.enforceRegex, "")
 		if tc.Location != newLoc {
 			t.Errorf("%s: expected '%v' but returned %v", k, tc.Location, newLoc)
 		}
 	}
+
+	loc := &ingress.Location{
+		Path:     "/foo/(.+)",
+		PathType: &pathPrefix,
+	}
+	expected = `~* "(*LIMIT_MATCH=1000)^/foo/(.+)"`
+	if actual := buildLocation(loc, true, "(*LIMIT_MATCH=1000)"); actual != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+	expected = "/foo/(.+)"
+	if actual := buildLocation(loc, false, "(*LIMIT_MATCH=1000)"); actual != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
+func TestBuildRegexLimits(t *testing.T) {
+	if actual := buildRegexLimits(&ingress.Ingress{}); actual != "" {
+		t.Errorf("Expected no limits for an invalid type but returned '%v'", actual)
+	}
+
+	testCases := []struct {
+		matchLimit     int
+		recursionLimit int
+		expected       string
+	}{
+		{0, 0, ""},
+		{1000, 0, "(*LIMIT_MATCH=1000)"},
+		{0, 500, "(*LIMIT_RECURSION=500)"},
+		{1000, 500, "(*LIMIT_MATCH=1000)(*LIMIT_RECURSION=500)"},
+		{-1, -1, ""},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.NewDefault()
+		cfg.PCREMatchLimit = tc.matchLimit
+		cfg.PCRERecursionLimit = tc.recursionLimit
+		if actual := buildRegexLimits(cfg); actual != tc.expected {
+			t.Errorf("limits %d/%d: expected '%v' but returned '%v'", tc.matchLimit, tc.recursionLimit, tc.expected, actual)
+		}
+	}
 }
 
 func TestBuildProxyPass(t *testing.T) {
@@ -407,6 +474,63 @@ func TestBuildProxyPassAutoHttp(t *testing.T) {
 	}
 }
 
+func TestBuildProxyPassUpstreamKeepalive(t *testing.T) {
+	loc := &ingress.Location{
+		Path:              "/",
+		PathType:          &pathPrefix,
+		Backend:           defaultBackend,
+		UpstreamKeepalive: upstreamkeepalive.Config{Enabled: true, Connections: 32, Timeout: 60, Requests: 100},
+	}
+	backends := []*ingress.Backend{{Name: defaultBackend}}
+
+	expected := "proxy_pass http://upstream_balancer_keepalive_32;"
+	if pp := buildProxyPass(defaultHost, backends, loc); pp != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, pp)
+	}
+}
+
+func TestBuildProxyPassServeSubpath(t *testing.T) {
+	backends := []*ingress.Backend{{Name: defaultBackend}}
+
+	testCases := map[string]struct {
+		path             string
+		subpath          string
+		xForwardedPrefix string
+		expected         string
+	}{
+		"prefix with a trailing slash": {"/app/", "/static/app/", "", `
+rewrite "(?i)^/app$" $scheme://$http_host$uri/ permanent;
+rewrite "(?i)^/app/(.*)" /static/app/$1 break;
+proxy_pass http://upstream_balancer;`},
+		"exact prefix": {"/app.v1", "/static", "", `
+rewrite "(?i)^/app\.v1$" $scheme://$http_host$uri/ permanent;
+rewrite "(?i)^/app\.v1/(.*)" /static/$1 break;
+proxy_pass http://upstream_balancer;`},
+		"root prefix": {"/", "/static/", "/", `
+rewrite "(?i)^/(.*)" /static/$1 break;
+proxy_set_header X-Forwarded-Prefix "/";
+proxy_pass http://upstream_balancer;`},
+		"root subpath": {"/app/", "/", "", `
+rewrite "(?i)^/app$" $scheme://$http_host$uri/ permanent;
+rewrite "(?i)^/app/(.*)" /$1 break;
+proxy_pass http://upstream_balancer;`},
+	}
+
+	for k, tc := range testCases {
+		loc := &ingress.Location{
+			Path:             tc.path,
+			PathType:         &pathPrefix,
+			Rewrite:          rewrite.Config{ServeSubpath: tc.subpath},
+			Backend:          defaultBackend,
+			XForwardedPrefix: tc.xForwardedPrefix,
+		}
+
+		if pp := buildProxyPass(defaultHost, backends, loc); pp != tc.expected {
+			t.Errorf("%s: expected \n'%v'\nbut returned \n'%v'", k, tc.expected, pp)
+		}
+	}
+}
+
 func TestBuildAuthLocation(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := ""
@@ -743,6 +867,67 @@ func TestTemplateWithData(t *testing.T) {
 	}
 }
 
+func TestTemplateStructure(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LuaSharedDicts = defaultLuaSharedDicts
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	rt, err := ngxTpl.Write(&dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	root, err := conf.Parse(string(rt))
+	if err != nil {
+		t.Fatalf("unexpected error parsing the NGINX configuration: %v", err)
+	}
+
+	every := []struct {
+		query    string
+		required string
+	}{
+		{"http > server", "> location"},
+		{"http > server", "> listen"},
+		{"location:has(> proxy_pass)", "> set[$proxy_upstream_name]"},
+		{"location:has(> proxy_pass)", "> proxy_set_header[Host]"},
+		{"location:has(> internal)", "> access_log[off]"},
+		{"server:has(> server_name[external-auth-01.sample.com]) > location[/]", "> auth_request"},
+	}
+	for _, tc := range every {
+		if err := root.Every(tc.query, tc.required); err != nil {
+			t.Errorf("unexpected NGINX configuration: %v", err)
+		}
+	}
+
+	none := []string{
+		"server server",
+		"location:has(> internal) > rewrite_by_lua_file",
+	}
+	for _, query := range none {
+		if err := root.None(query); err != nil {
+			t.Errorf("unexpected NGINX configuration: %v", err)
+		}
+	}
+}
+
 func BenchmarkTemplateWithData(b *testing.B) {
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -1009,6 +1194,278 @@ func TestFilterRateLimits(t *testing.T) {
 	}
 }
 
+func TestFilterUpstreamKeepalives(t *testing.T) {
+	invalidType := &ingress.Ingress{}
+	expected := []int{}
+	actual := filterUpstreamKeepalives(invalidType)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	servers := []*ingress.Server{
+		{
+			Locations: []*ingress.Location{
+				{Path: "/a", UpstreamKeepalive: upstreamkeepalive.Config{Enabled: true, Connections: 64}},
+				{Path: "/b"},
+				{Path: "/c", UpstreamKeepalive: upstreamkeepalive.Config{Enabled: true, Connections: 0}},
+			},
+		},
+		{
+			Locations: []*ingress.Location{
+				{Path: "/", UpstreamKeepalive: upstreamkeepalive.Config{Enabled: true, Connections: 64}},
+			},
+		},
+	}
+
+	expected = []int{0, 64}
+	actual = filterUpstreamKeepalives(servers)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+}
+
+func TestBuildRequestHeaders(t *testing.T) {
+	header := requestheaders.Header{Name: "X-Api-Version", Value: "v1"}
+	variable := requestHeaderVariable(header)
+
+	location := &ingress.Location{
+		RequestHeaders: requestheaders.Config{
+			Set:    []requestheaders.Header{{Name: "X-Client", Value: "$remote_addr:${remote_port}"}},
+			Add:    []requestheaders.Header{header},
+			Remove: []string{"Cookie"},
+		},
+	}
+
+	expected := []string{
+		`proxy_set_header Cookie "";`,
+		`proxy_set_header X-Client "$remote_addr:${remote_port}";`,
+		fmt.Sprintf("proxy_set_header X-Api-Version $%s;", variable),
+	}
+	if actual := buildRequestHeaders(location, "proxy_set_header"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	servers := []*ingress.Server{
+		{Locations: []*ingress.Location{location, {Path: "/b"}}},
+		{Locations: []*ingress.Location{location}},
+	}
+	expected = []string{
+		fmt.Sprintf("map $http_x_api_version $%s {\n        \"\" \"v1\";\n        default $http_x_api_version;\n    }", variable),
+	}
+	if actual := buildRequestHeaderMaps(servers); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	for name, changed := range map[string]bool{"x-client": true, "X-API-VERSION": true, "cookie": true, "X-Tenant": false} {
+		if actual := isRequestHeaderChanged(location, name); actual != changed {
+			t.Errorf("Expected %v for header %v but returned %v", changed, name, actual)
+		}
+	}
+}
+
+func TestBuildResponseBodyRewrite(t *testing.T) {
+	location := &ingress.Location{}
+	if actual := buildResponseBodyRewrite(location); len(actual) != 0 {
+		t.Errorf("Expected no directive but returned '%v'", actual)
+	}
+	if actual := buildAcceptEncoding(location, "proxy_set_header"); actual != "" {
+		t.Errorf("Expected no directive but returned '%v'", actual)
+	}
+
+	location.ResponseBodyRewrite = responsebodyrewrite.Config{
+		Rules: []responsebodyrewrite.Rule{
+			{Find: "http://legacy.internal/", Replace: "https://app.example.com/"},
+			{Find: "<!-- debug -->", Replace: ""},
+		},
+		Types: []string{"text/css", "application/javascript"},
+	}
+	expected := []string{
+		`sub_filter "http://legacy.internal/" "https://app.example.com/";`,
+		`sub_filter "<!-- debug -->" "";`,
+		`sub_filter_once off;`,
+		`sub_filter_types text/css application/javascript;`,
+	}
+	if actual := buildResponseBodyRewrite(location); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+	if actual := buildAcceptEncoding(location, "proxy_set_header"); actual != `proxy_set_header Accept-Encoding "";` {
+		t.Errorf("Expected the Accept-Encoding header to be removed but returned '%v'", actual)
+	}
+	if !isRequestHeaderChanged(location, "accept-encoding") {
+		t.Errorf("Expected the Accept-Encoding header to be changed by the response body rewrite")
+	}
+
+	location.ResponseBodyRewrite.Once = true
+	location.ResponseBodyRewrite.Types = nil
+	location.RequestHeaders.Set = []requestheaders.Header{{Name: "Accept-Encoding", Value: "identity"}}
+	expected = []string{
+		`sub_filter "http://legacy.internal/" "https://app.example.com/";`,
+		`sub_filter "<!-- debug -->" "";`,
+		`sub_filter_once on;`,
+	}
+	if actual := buildResponseBodyRewrite(location); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+	if actual := buildAcceptEncoding(location, "proxy_set_header"); actual != "" {
+		t.Errorf("Expected the Accept-Encoding header of the request headers to be kept but returned '%v'", actual)
+	}
+}
+
+func TestBuildLinkRewriteForLocation(t *testing.T) {
+	location := &ingress.Location{Path: "/grafana/"}
+	if actual := buildLinkRewriteForLocation(location); actual != "" {
+		t.Errorf("Expected no configuration without link rewriting but returned %q", actual)
+	}
+
+	linkRewrite := linkrewrite.Config{Enabled: true, UpstreamURLs: []string{"http://grafana.monitoring:3000"}}
+	testCases := map[string]struct {
+		location               *ingress.Location
+		prefix, upstreamPrefix string
+	}{
+		"path prefix": {
+			&ingress.Location{Path: "/grafana/", LinkRewrite: linkRewrite},
+			"/grafana", "/grafana",
+		},
+		"serve subpath": {
+			&ingress.Location{Path: "/grafana", LinkRewrite: linkRewrite, Rewrite: rewrite.Config{ServeSubpath: "/static/grafana/"}},
+			"/grafana", "/static/grafana",
+		},
+		"rewrite target": {
+			&ingress.Location{
+				Path:        "/grafana(/|$)(.*)",
+				LinkRewrite: linkrewrite.Config{Enabled: true, Prefix: "/grafana", UpstreamURLs: linkRewrite.UpstreamURLs},
+				Rewrite:     rewrite.Config{Target: "/$2", UseRegex: true},
+			},
+			"/grafana", "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expected := fmt.Sprintf(`set $rewrite_links "true";
+set $rewrite_links_prefix %q;
+set $rewrite_links_upstream_prefix %q;
+set $rewrite_links_upstream_urls "http://grafana.monitoring:3000";
+body_filter_by_lua_file /etc/nginx/lua/nginx/ngx_conf_body_filter.lua;
+`, tc.prefix, tc.upstreamPrefix)
+			if actual := buildLinkRewriteForLocation(tc.location); actual != expected {
+				t.Errorf("Expected %q but returned %q", expected, actual)
+			}
+		})
+	}
+
+	location = &ingress.Location{Path: "/grafana(/|$)(.*)", LinkRewrite: linkRewrite, Rewrite: rewrite.Config{Target: "/$2"}}
+	if actual := buildLinkRewriteForLocation(location); actual != "" {
+		t.Errorf("Expected no configuration for a regular expression path without prefix but returned %q", actual)
+	}
+}
+
+func TestBuildPathParameterHeaders(t *testing.T) {
+	location := &ingress.Location{
+		Path:           `/users/(?<path_param_user_id>[0-9]+)/orders(?:/|$)`,
+		PathTemplate:   pathtemplate.Config{Enabled: true, HeaderPrefix: "X-Param-"},
+		PathParameters: []string{"user_id"},
+	}
+
+	expected := []string{"grpc_set_header X-Param-user-id $path_param_user_id;"}
+	if actual := buildPathParameterHeaders(location, "grpc_set_header"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	if !enforceRegexModifier([]*ingress.Location{{Path: "/"}, location}) {
+		t.Errorf("Expected the locations of a server with a path template to use regular expressions")
+	}
+}
+
+func TestBuildRedirectURL(t *testing.T) {
+	cases := map[string]struct {
+		Redirect redirect.Config
+		Output   string
+	}{
+		"literal url":                  {redirect.Config{URL: "https://example.com/new"}, "https://example.com/new"},
+		"keep query":                   {redirect.Config{URL: "https://example.com/new", KeepQuery: true}, "https://example.com/new$is_args$args"},
+		"keep query of url with query": {redirect.Config{URL: "https://example.com/new?from=old", KeepQuery: true}, "https://example.com/new?from=old$redirect_args"},
+		"append path":                  {redirect.Config{URL: "https://example.com/", AppendPath: true}, "https://example.com$request_uri_path"},
+		"append path and keep query":   {redirect.Config{URL: "https://example.com/new", AppendPath: true, KeepQuery: true}, "https://example.com/new$request_uri_path$is_args$args"},
+	}
+	for k, tc := range cases {
+		res := buildRedirectURL(&ingress.Location{Redirect: tc.Redirect})
+		if res != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, res)
+		}
+	}
+}
+
+func TestRedirectURLEncodedCRLF(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LuaSharedDicts = defaultLuaSharedDicts
+	dat.Servers[1].Locations[0].Redirect = redirect.Config{URL: "https://example.com/", Code: 301, AppendPath: true, KeepQuery: true}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	rt, err := ngxTpl.Write(&dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	root, err := conf.Parse(string(rt))
+	if err != nil {
+		t.Fatalf("unexpected error parsing the NGINX configuration: %v", err)
+	}
+
+	returns, err := root.Query(fmt.Sprintf("server:has(> server_name[%v]) > location[%v] > return", dat.Servers[1].Hostname, dat.Servers[1].Locations[0].Path))
+	if err != nil || len(returns) != 1 {
+		t.Fatalf("expected the return directive of the redirect but got %v (%v)", returns, err)
+	}
+	target := returns[0].Args[1]
+	if strings.Contains(target, "$uri") {
+		t.Errorf("expected the redirect not to append the decoded $uri but got %v", target)
+	}
+
+	maps, err := root.Query("http > map[$request_uri $request_uri_path]")
+	if err != nil || len(maps) != 1 {
+		t.Fatalf("expected the map of $request_uri_path but got %v (%v)", maps, err)
+	}
+	requestURI := "/old%0d%0aSet-Cookie:%20session=attacker?page=1"
+	var requestURIPath string
+	for _, entry := range maps[0].Block {
+		if !strings.HasPrefix(entry.Name, "~") {
+			continue
+		}
+		match := regexp.MustCompile(strings.TrimPrefix(entry.Name, "~")).FindStringSubmatch(requestURI)
+		if match != nil {
+			requestURIPath = strings.ReplaceAll(entry.Args[0], "$1", match[1])
+			break
+		}
+	}
+
+	location := strings.NewReplacer("$request_uri_path", requestURIPath, "$is_args", "?", "$args", "page=1").Replace(target)
+	if strings.ContainsAny(location, "\r\n") {
+		t.Errorf("expected the Location header not to contain CR or LF but got %q", location)
+	}
+	if expected := "https://example.com/old%0d%0aSet-Cookie:%20session=attacker?page=1"; location != expected {
+		t.Errorf("expected the Location header %q but got %q", expected, location)
+	}
+}
+
 func TestBuildAuthSignURL(t *testing.T) {
 	cases := map[string]struct {
 		Input, RedirectParam, Output string
@@ -1775,6 +2232,21 @@ opentelemetry_trust_incoming_spans off;`
 			t.Errorf("%v: expected '%v' but returned '%v'", testCase.description, testCase.expected, actual)
 		}
 	}
+
+	il := &ingress.Location{
+		Opentelemetry: opentelemetry.Config{
+			Attributes: []opentelemetry.Attribute{{Key: "team", Value: "payments"}, {Key: "service", Value: "$service_name"}},
+		},
+	}
+	expected := loadOT + `
+opentelemetry_attribute "team" "payments";
+opentelemetry_attribute "service" "$service_name";`
+	if actual := buildOpentelemetryForLocation(true, true, il); actual != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, actual)
+	}
+	if actual := buildOpentelemetryForLocation(false, true, il); actual != "" {
+		t.Errorf("expected no attributes with OpenTelemetry disabled but returned '%v'", actual)
+	}
 }
 
 //nolint:dupl // Ignore dupl errors for similar test case
@@ -1903,53 +2375,936 @@ func TestModSecurityForLocation(t *testing.T) {
 	}
 }
 
-func TestBuildServerName(t *testing.T) {
-	testCases := []struct {
-		title    string
-		hostname string
-		expected string
-	}{
-		{"simple domain", "foo.bar", "foo.bar"},
-		{"simple www domain", "www.foo.bar", "www.foo.bar"},
-		{"wildcard domain", "*.foo.bar", "~^(?<subdomain>[\\w-]+)\\.foo\\.bar$"},
-		{"wildcard two levels domain", "*.sub.foo.bar", "~^(?<subdomain>[\\w-]+)\\.sub\\.foo\\.bar$"},
+func TestBuildGraphQLForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildGraphQLForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without GraphQL but got %q", out)
 	}
 
-	for _, testCase := range testCases {
-		result := buildServerName(testCase.hostname)
-		if result != testCase.expected {
-			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
-		}
+	loc.GraphQL = graphql.Config{
+		Enabled:                true,
+		MaxDepth:               5,
+		MaxComplexity:          100,
+		IntrospectionAllowlist: []string{"10.0.0.0/8", "192.168.0.1"},
+	}
+
+	expected := `set $graphql_enabled "true";
+set $graphql_max_depth "5";
+set $graphql_max_complexity "100";
+set $graphql_introspection_allowlist "10.0.0.0/8,192.168.0.1";
+`
+	if out := buildGraphQLForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
 	}
 }
 
-func TestCleanConf(t *testing.T) {
-	testDataDir, err := getTestDataDir()
-	if err != nil {
-		t.Error("unexpected error reading conf file: ", err)
+func TestBuildRetryPolicyForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildRetryPolicyForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without retry policy but got %q", out)
 	}
-	actual := &bytes.Buffer{}
-	{
-		data, err := os.ReadFile(testDataDir + "/cleanConf.src.conf")
-		if err != nil {
-			t.Error("unexpected error reading conf file: ", err)
-		}
-		in := bytes.NewBuffer(data)
-		err = cleanConf(in, actual)
-		if err != nil {
-			t.Error("cleanConf failed: ", err)
-		}
+
+	loc.RetryPolicy = retrypolicy.Config{
+		Enabled:       true,
+		RetryOn:       "error timeout",
+		MaxRetries:    3,
+		PerTryTimeout: 500 * time.Millisecond,
+		BudgetPercent: 20,
 	}
 
-	expected, err := os.ReadFile(testDataDir + "/cleanConf.expected.conf")
-	if err != nil {
-		t.Error("unexpected error reading conf file: ", err)
+	expected := `set $retry_policy_max_retries "3";
+set $retry_policy_per_try_timeout "0.5";
+set $retry_policy_budget_percent "20";
+`
+	if out := buildRetryPolicyForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
 	}
-	if !bytes.Equal(expected, actual.Bytes()) {
-		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{A: strings.SplitAfter(string(expected), "\n"), B: strings.SplitAfter(actual.String(), "\n"), Context: 3})
-		if err != nil {
-			t.Error("failed to get diff for cleanConf", err)
-		}
-		t.Errorf("cleanConf result don't match with expected: %s", diff)
+}
+
+func TestBuildChaosForLocation(t *testing.T) {
+	loc := &ingress.Location{
+		Chaos: chaos.Config{
+			AbortPercent: 2.5,
+			DelayMs:      300,
+			DelayPercent: 50,
+		},
+	}
+	if out := buildChaosForLocation(false, loc); out != "" {
+		t.Errorf("expected no configuration without chaos injection but got %q", out)
+	}
+
+	expected := `set $chaos_abort_percent "2.5";
+set $chaos_delay_ms "300";
+set $chaos_delay_percent "50";
+`
+	if out := buildChaosForLocation(true, loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	if out := buildChaosForLocation(true, &ingress.Location{}); out != "" {
+		t.Errorf("expected no configuration for a location without chaos annotations but got %q", out)
+	}
+}
+
+func TestBuildRequestIDForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildRequestIDForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without request ID policy but got %q", out)
+	}
+	if header := requestIDHeader(loc); header != "X-Request-ID" {
+		t.Errorf("expected the default request ID header but got %q", header)
+	}
+
+	loc.RequestID = requestid.Config{
+		Policy: requestid.PolicyRegenerate,
+		Header: "X-Correlation-Id",
+		Format: requestid.FormatUUIDv7,
+	}
+	expected := `set $request_id_policy "regenerate";
+set $request_id_header "X-Correlation-Id";
+set $request_id_format "uuidv7";
+`
+	if out := buildRequestIDForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+	if header := requestIDHeader(loc); header != "X-Correlation-Id" {
+		t.Errorf("expected the header of the annotation but got %q", header)
+	}
+
+	if out := buildOpentelemetryForLocation(true, false, loc); !strings.Contains(out, `opentelemetry_attribute "http.request.id" "$req_id";`) {
+		t.Errorf("expected the request ID in the trace attributes but got %q", out)
+	}
+}
+
+func TestBuildBotMitigationForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildBotMitigationForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without bot mitigation but got %q", out)
+	}
+
+	loc.BotMitigation = botmitigation.Config{
+		Challenge:             botmitigation.ChallengeJS,
+		ChallengeUserAgents:   `^(curl|python-requests)/\d`,
+		ChallengeFingerprints: []string{"t13d1516h2_8daaf6152771_02713d6af862"},
+		ChallengeRate:         120,
+		AllowlistSourceRange:  []string{"10.0.0.0/8", "192.168.0.1"},
+	}
+	expected := `set $bot_challenge "js";
+set $bot_challenge_user_agents "^(curl|python-requests)/\\d";
+set $bot_challenge_fingerprints "t13d1516h2_8daaf6152771_02713d6af862";
+set $bot_allowlist_source_range "10.0.0.0/8,192.168.0.1";
+set $bot_challenge_rate "120";
+`
+	if out := buildBotMitigationForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	loc.BotMitigation = botmitigation.Config{BlockUserAgents: "(nikto|sqlmap)$"}
+	expected = `set $bot_challenge "";
+set $bot_block_user_agents "(nikto|sqlmap)${literal_dollar}";
+`
+	if out := buildBotMitigationForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildServerTimingForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildServerTimingForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without Server-Timing but got %q", out)
+	}
+
+	loc.ServerTiming = servertiming.Config{Enabled: true}
+	expected := "set $server_timing \"true\";\n"
+	if out := buildServerTimingForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	loc.ServerTiming.AllowOrigins = []string{"https://www.example.com", "https://app.example.com"}
+	expected = `set $server_timing "true";
+set $server_timing_allow_origin "https://www.example.com, https://app.example.com";
+`
+	if out := buildServerTimingForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildLuaPluginsForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildLuaPluginsForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without Lua plugins but got %q", out)
+	}
+
+	loc.LuaPlugins = []string{"geo_headers", "audit"}
+	expected := "set $lua_plugins \"geo_headers,audit\";\n"
+	if out := buildLuaPluginsForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildConcurrencyLimitForLocation(t *testing.T) {
+	cfg := config.NewDefault()
+	loc := &ingress.Location{}
+	if out := buildConcurrencyLimitForLocation(cfg, loc); out != "" {
+		t.Errorf("expected no configuration for a location without concurrency limit but got %q", out)
+	}
+
+	loc.ConcurrencyLimit = concurrencylimit.Config{
+		Limit:        100,
+		Queue:        20,
+		QueueTimeout: 1500 * time.Millisecond,
+	}
+	cfg.LimitConnStatusCode = 429
+
+	expected := `set $concurrency_limit "100";
+set $concurrency_limit_queue "20";
+set $concurrency_limit_queue_timeout "1.5";
+set $concurrency_limit_status "429";
+`
+	if out := buildConcurrencyLimitForLocation(cfg, loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestHasConcurrencyLimits(t *testing.T) {
+	servers := []*ingress.Server{
+		{Hostname: "_", Locations: []*ingress.Location{{Path: "/"}}},
+		{Hostname: "example.com", Locations: []*ingress.Location{{Path: "/"}, {Path: "/api"}}},
+	}
+	if hasConcurrencyLimits(servers) {
+		t.Errorf("expected no concurrency limits")
+	}
+
+	servers[1].Locations[1].ConcurrencyLimit = concurrencylimit.Config{Limit: 10}
+	if !hasConcurrencyLimits(servers) {
+		t.Errorf("expected concurrency limits")
+	}
+
+	if hasConcurrencyLimits(nil) {
+		t.Errorf("expected no concurrency limits for an invalid type")
+	}
+}
+
+func TestBuildSkipAccessLogPaths(t *testing.T) {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		},
+	}
+	servers := []*ingress.Server{
+		{Hostname: "_", Locations: []*ingress.Location{{Path: "/", Logs: log.Config{SkipPaths: []string{"/healthz"}}}}},
+		{Hostname: "a.example.com", Locations: []*ingress.Location{{Path: "/", Ingress: ing, Logs: log.Config{SkipPaths: []string{"/readyz", "/healthz"}}}}},
+		{Hostname: "b.example.com", Locations: []*ingress.Location{{Path: "/", Ingress: ing, Logs: log.Config{SkipPaths: []string{"/healthz"}}}}},
+	}
+
+	expected := []string{"default/app:/healthz", "default/app:/readyz"}
+	if keys := buildSkipAccessLogPaths(servers); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v but got %v", expected, keys)
+	}
+
+	if keys := buildSkipAccessLogPaths(nil); keys != nil {
+		t.Errorf("expected no keys for an invalid type but got %v", keys)
+	}
+}
+
+func TestBuildNextUpstreamForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildNextUpstreamForLocation(loc, false); out != "" {
+		t.Errorf("expected no configuration for a location without retried responses but got %q", out)
+	}
+
+	loc.Proxy = proxy.Config{NextUpstream: "error timeout non_idempotent", NextUpstreamTries: 2}
+	loc.NextUpstream = nextupstream.Config{StatusCodes: []int{409, 503}, Header: "X-Backend-Overloaded"}
+
+	expected := `set $next_upstream_uri $uri;
+set $next_upstream_tries "2";
+set $next_upstream_non_idempotent "true";
+set $next_upstream_header "x_backend_overloaded";
+proxy_intercept_errors on;
+error_page 409 = @next_upstream;
+error_page 503 = @next_upstream;
+`
+	if out := buildNextUpstreamForLocation(loc, false); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	// the custom error pages take precedence
+	loc.Proxy = proxy.Config{NextUpstream: "error timeout"}
+	loc.NextUpstream = nextupstream.Config{StatusCodes: []int{409, 503}}
+	loc.CustomHTTPErrors = []int{503}
+
+	expected = `set $next_upstream_uri $uri;
+set $next_upstream_tries "4";
+set $next_upstream_non_idempotent "false";
+set $next_upstream_header "";
+error_page 409 = @next_upstream;
+`
+	if out := buildNextUpstreamForLocation(loc, false); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	// the retry policy replaces proxy-next-upstream-tries
+	loc.RetryPolicy = retrypolicy.Config{Enabled: true, RetryOn: "error", MaxRetries: 1}
+	if out := buildNextUpstreamForLocation(loc, false); !strings.Contains(out, `set $next_upstream_tries "2";`) {
+		t.Errorf("expected the tries of the retry policy but got %q", out)
+	}
+}
+
+func TestBuildNextUpstreamLocation(t *testing.T) {
+	locs := []*ingress.Location{{Path: "/"}}
+	if out := buildNextUpstreamLocation(locs); out != "" {
+		t.Errorf("expected no location for locations without retried responses but got %q", out)
+	}
+
+	locs = append(locs, &ingress.Location{Path: "/api", NextUpstream: nextupstream.Config{StatusCodes: []int{409}}})
+	if out := buildNextUpstreamLocation(locs); !strings.Contains(out, "location @next_upstream {") {
+		t.Errorf("expected the @next_upstream location but got %q", out)
+	}
+}
+
+func TestBuildFailoverOriginForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildFailoverOriginForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without external origin but got %q", out)
+	}
+
+	loc.FailoverOrigin = failoverorigin.Config{Location: "@failover-uid", URL: "https://static.example.com", Host: "static.example.com"}
+	expected := `error_page 502 = @failover-uid;
+error_page 503 = @failover-uid;
+error_page 504 = @failover-uid;
+`
+	if out := buildFailoverOriginForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	// the custom error pages and the next upstream take precedence
+	loc.CustomHTTPErrors = []int{503}
+	loc.NextUpstream = nextupstream.Config{StatusCodes: []int{502}}
+	expected = "error_page 504 = @failover-uid;\n"
+	if out := buildFailoverOriginForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildFailoverOriginLocations(t *testing.T) {
+	locs := []*ingress.Location{{Path: "/"}}
+	if out := buildFailoverOriginLocations(locs); out != "" {
+		t.Errorf("expected no location for locations without external origin but got %q", out)
+	}
+
+	origin := failoverorigin.Config{Location: "@failover-uid", URL: "https://cdn.example.net/www/", Host: "www.example.com", SSLVerify: "off"}
+	locs = []*ingress.Location{{Path: "/", FailoverOrigin: origin}, {Path: "/api", FailoverOrigin: origin}}
+	expected := `location @failover-uid {
+set $failover_origin "https://cdn.example.net/www";
+proxy_set_header Host "www.example.com";
+proxy_ssl_server_name on;
+proxy_ssl_name "www.example.com";
+proxy_ssl_verify off;
+proxy_ssl_certificate "";
+proxy_ssl_certificate_key "";
+proxy_pass $failover_origin$request_uri;
+}
+
+`
+	if out := buildFailoverOriginLocations(locs); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	// the origin uses its own CA certificates and client certificate
+	origin.SSLVerify = "on"
+	origin.SSL = resolver.AuthSSLCert{Secret: "default/origin", CAFileName: "/ssl/ca.pem", PemFileName: "/ssl/origin.pem"}
+	locs = []*ingress.Location{{Path: "/", FailoverOrigin: origin}}
+	expected = `location @failover-uid {
+set $failover_origin "https://cdn.example.net/www";
+proxy_set_header Host "www.example.com";
+proxy_ssl_server_name on;
+proxy_ssl_name "www.example.com";
+proxy_ssl_verify on;
+proxy_ssl_trusted_certificate /ssl/ca.pem;
+proxy_ssl_certificate /ssl/origin.pem;
+proxy_ssl_certificate_key /ssl/origin.pem;
+proxy_pass $failover_origin$request_uri;
+}
+
+`
+	if out := buildFailoverOriginLocations(locs); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestFailoverOriginTemplate(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LuaSharedDicts = defaultLuaSharedDicts
+
+	server := dat.Servers[1]
+	for _, loc := range server.Locations {
+		loc.FailoverOrigin = failoverorigin.Config{Location: "@failover-uid", URL: "https://static.example.com", Host: "static.example.com", SSLVerify: "off"}
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	rt, err := ngxTpl.Write(&dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	root, err := conf.Parse(string(rt))
+	if err != nil {
+		t.Fatalf("unexpected error parsing the NGINX configuration: %v", err)
+	}
+
+	selector := fmt.Sprintf("server:has(> server_name[%v])", server.Hostname)
+	if err := root.Every(selector+" > location:has(> set[$proxy_upstream_name])", "> error_page[502 = @failover-uid]"); err != nil {
+		t.Errorf("expected the locations to fail over to the named location: %v", err)
+	}
+	if err := root.Every(selector, "> location[@failover-uid] > proxy_pass[$failover_origin$request_uri]"); err != nil {
+		t.Errorf("expected the named location proxying to the external origin: %v", err)
+	}
+	if err := root.Every(selector, "> location[@failover-uid] > proxy_ssl_verify[off]"); err != nil {
+		t.Errorf("expected the named location to set the TLS verification of the external origin: %v", err)
+	}
+
+	// redirecting to a URI changes the method of the request to GET
+	errorPages, err := root.Query(selector + " error_page[* =]")
+	if err != nil || len(errorPages) == 0 {
+		t.Fatalf("expected the error pages of the failover but got %v (%v)", errorPages, err)
+	}
+	for _, page := range errorPages {
+		if page.Args[0] >= "502" && page.Args[0] <= "504" && !strings.HasPrefix(page.Args[len(page.Args)-1], "@") {
+			t.Errorf("expected the error pages to redirect to a named location but got %v", page)
+		}
+	}
+}
+
+func TestTLSHandshakeMetricsTemplate(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LuaSharedDicts = defaultLuaSharedDicts
+	dat.EnableMetrics = true
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	render := func() *conf.Directive {
+		rt, err := ngxTpl.Write(&dat)
+		if err != nil {
+			t.Fatalf("invalid NGINX template: %v", err)
+		}
+		root, err := conf.Parse(string(rt))
+		if err != nil {
+			t.Fatalf("unexpected error parsing the NGINX configuration: %v", err)
+		}
+		return root
+	}
+
+	const errorLog = "http > error_log[syslog:server=unix:/tmp/nginx/connection-errors.socket,nohostname info]"
+
+	// the Lua code run in every TLS handshake and the error log at the info level are opt-in
+	root := render()
+	if err := root.None("http > ssl_client_hello_by_lua_file"); err != nil {
+		t.Errorf("unexpected NGINX configuration with the metrics enabled: %v", err)
+	}
+	if err := root.None(errorLog); err != nil {
+		t.Errorf("unexpected NGINX configuration with the metrics enabled: %v", err)
+	}
+
+	dat.Cfg.EnableTLSHandshakeMetrics = true
+	root = render()
+	if err := root.Every("http", "> ssl_client_hello_by_lua_file"); err != nil {
+		t.Errorf("unexpected NGINX configuration with the TLS handshake metrics enabled: %v", err)
+	}
+	if nodes, err := root.Query(errorLog); err != nil || len(nodes) != 1 {
+		t.Errorf("expected the error log of the connection errors but got %v (%v)", nodes, err)
+	}
+
+	dat.EnableMetrics = false
+	root = render()
+	if err := root.None("http > ssl_client_hello_by_lua_file"); err != nil {
+		t.Errorf("unexpected NGINX configuration with the metrics disabled: %v", err)
+	}
+	if err := root.None(errorLog); err != nil {
+		t.Errorf("unexpected NGINX configuration with the metrics disabled: %v", err)
+	}
+}
+
+func TestBuildGeoIPVariables(t *testing.T) {
+	files := []string{"GeoLite2-City.mmdb", "GeoLite2-ASN.mmdb"}
+
+	expected := `map $remote_addr $geo_country_code {
+    default "";
+}
+
+map $remote_addr $geo_asn {
+    default "";
+}
+`
+	if out := buildGeoIPVariables(config.Configuration{}, &files); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	expected = `map $remote_addr $geo_country_code {
+    default $geoip2_city_country_code;
+}
+
+map $remote_addr $geo_asn {
+    default $geoip2_asn;
+}
+`
+	if out := buildGeoIPVariables(config.Configuration{UseGeoIP2: true}, &files); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	files = append(files, "GeoIP2-Country.mmdb")
+	if out := buildGeoIPVariables(config.Configuration{UseGeoIP2: true}, &files); !strings.Contains(out, "default $geoip2_country_code;") {
+		t.Errorf("expected the country database to be preferred but got %q", out)
+	}
+}
+
+func TestBuildGeoAccessForLocation(t *testing.T) {
+	loc := &ingress.Location{}
+	if out := buildGeoAccessForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without geo restrictions but got %q", out)
+	}
+
+	loc.GeoAccess = geoaccess.Config{
+		AllowCountries: []string{"CA", "US"},
+		DenyASNs:       []string{"64496"},
+	}
+
+	expected := `if ($geo_country_code !~ "^(CA|US)$") {
+    return 403;
+}
+if ($geo_asn ~ "^(64496)$") {
+    return 403;
+}
+`
+	if out := buildGeoAccessForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildProxyCacheZones(t *testing.T) {
+	cfg := config.NewDefault()
+	servers := []*ingress.Server{
+		{
+			Locations: []*ingress.Location{
+				{Path: "/", ProxyCache: proxycache.Config{Enabled: true, Zone: "default_web"}},
+				{Path: "/api"},
+			},
+		},
+		{
+			Locations: []*ingress.Location{
+				{Path: "/", ProxyCache: proxycache.Config{Enabled: true, Zone: "default_web"}},
+				{Path: "/docs", ProxyCache: proxycache.Config{Enabled: true, Zone: "docs_site"}},
+			},
+		},
+	}
+
+	expected := []string{
+		"proxy_cache_path /tmp/nginx/cache/default_web levels=1:2 keys_zone=default_web:10m max_size=1g inactive=10m use_temp_path=off;",
+		"proxy_cache_path /tmp/nginx/cache/docs_site levels=1:2 keys_zone=docs_site:10m max_size=1g inactive=10m use_temp_path=off;",
+	}
+	if zones := buildProxyCacheZones(cfg, servers); !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected %v but got %v", expected, zones)
+	}
+}
+
+func TestBuildProxyCacheForLocation(t *testing.T) {
+	loc := &ingress.Location{Path: "/"}
+	if out := buildProxyCacheForLocation(loc); out != "" {
+		t.Errorf("expected no configuration for a location without cache but got %q", out)
+	}
+
+	loc.ProxyCache = proxycache.Config{
+		Enabled: true,
+		Zone:    "default_web",
+		Key:     []string{"scheme", "host", "uri", "header:Accept-Language"},
+		Valid:   []string{"200 302 10m", "any 1m"},
+		Bypass:  []string{"header:Cache-Control", "arg:nocache"},
+		NoCache: []string{"cookie:session"},
+	}
+
+	expected := `proxy_cache default_web;
+proxy_cache_key "$scheme|$host|$request_uri|$http_accept_language";
+proxy_cache_valid 200 302 10m;
+proxy_cache_valid any 1m;
+proxy_cache_bypass $http_cache_control $arg_nocache;
+proxy_no_cache $cookie_session;
+`
+	if out := buildProxyCacheForLocation(loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildUpstreamSigningForLocation(t *testing.T) {
+	loc := &ingress.Location{Path: "/"}
+	if out := buildUpstreamSigningForLocation(loc, "proxy_set_header"); out != "" {
+		t.Errorf("expected no configuration for a location without signing but got %q", out)
+	}
+
+	loc.UpstreamVhost = "bucket.s3.us-east-1.amazonaws.com"
+	loc.UpstreamSigning = upstreamsigning.Config{
+		Method:          upstreamsigning.MethodAWSSigV4,
+		Region:          "us-east-1",
+		Service:         "s3",
+		CredentialsFile: "/etc/ingress-controller/auth/default-uid-uid.signing",
+		CredentialsSHA:  "sha",
+	}
+
+	expected := `set $upstream_signing_method "aws-sigv4";
+set $upstream_signing_credentials_file "/etc/ingress-controller/auth/default-uid-uid.signing";
+set $upstream_signing_credentials_sha "sha";
+set $upstream_signing_host "bucket.s3.us-east-1.amazonaws.com";
+set $upstream_signing_region "us-east-1";
+set $upstream_signing_service "s3";
+set $upstream_signing_authorization "";
+set $upstream_signing_date "";
+set $upstream_signing_content_sha256 "";
+set $upstream_signing_security_token "";
+proxy_set_header Authorization $upstream_signing_authorization;
+proxy_set_header X-Amz-Date $upstream_signing_date;
+proxy_set_header X-Amz-Content-Sha256 $upstream_signing_content_sha256;
+proxy_set_header X-Amz-Security-Token $upstream_signing_security_token;
+`
+	if out := buildUpstreamSigningForLocation(loc, "proxy_set_header"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	loc.UpstreamVhost = ""
+	loc.UpstreamSigning = upstreamsigning.Config{
+		Method:          upstreamsigning.MethodHMACSHA256,
+		CredentialsFile: "/etc/ingress-controller/auth/default-uid-uid.signing",
+		CredentialsSHA:  "sha",
+	}
+
+	expected = `set $upstream_signing_method "hmac-sha256";
+set $upstream_signing_credentials_file "/etc/ingress-controller/auth/default-uid-uid.signing";
+set $upstream_signing_credentials_sha "sha";
+set $upstream_signing_host "";
+set $upstream_signing_date "";
+set $upstream_signing_digest "";
+set $upstream_signing_signature "";
+grpc_set_header Date $upstream_signing_date;
+grpc_set_header Digest $upstream_signing_digest;
+grpc_set_header Signature $upstream_signing_signature;
+`
+	if out := buildUpstreamSigningForLocation(loc, "grpc_set_header"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildAttributionForLocation(t *testing.T) {
+	loc := &ingress.Location{Path: "/"}
+	if out := buildAttributionForLocation(config.Configuration{}, loc); out != "" {
+		t.Errorf("expected no configuration for a location without attribution but got %q", out)
+	}
+
+	loc.Attribution = attribution.Config{
+		Enabled: true,
+		Header:  "X-Ingress-Attribution",
+		KeyID:   "2024-01",
+		KeyFile: "/etc/ingress-controller/auth/default-uid-uid.attribution",
+		KeySHA:  "sha",
+	}
+	loc.BasicDigestAuth = auth.Config{Type: "basic", Secured: true}
+
+	expected := `set $attribution_header "X-Ingress-Attribution";
+set $attribution_key_id "2024-01";
+set $attribution_key_file "/etc/ingress-controller/auth/default-uid-uid.attribution";
+set $attribution_key_sha "sha";
+set $attribution_auth "basic";
+`
+	if out := buildAttributionForLocation(config.Configuration{}, loc); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	loc.ExternalAuth = authreq.Config{URL: "http://auth.example.com"}
+	if out := buildAttributionForLocation(config.Configuration{}, loc); !strings.Contains(out, `set $attribution_auth "external";`) {
+		t.Errorf("expected the external authentication but got %q", out)
+	}
+
+	if out := buildAttributionForLocation(config.Configuration{NoAuthLocations: "/"}, loc); !strings.Contains(out, `set $attribution_auth "none";`) {
+		t.Errorf("expected no authentication but got %q", out)
+	}
+}
+
+func TestBuildServerName(t *testing.T) {
+	testCases := []struct {
+		title    string
+		hostname string
+		expected string
+	}{
+		{"simple domain", "foo.bar", "foo.bar"},
+		{"simple www domain", "www.foo.bar", "www.foo.bar"},
+		{"wildcard domain", "*.foo.bar", "~^(?<subdomain>[\\w-]+)\\.foo\\.bar$"},
+		{"wildcard two levels domain", "*.sub.foo.bar", "~^(?<subdomain>[\\w-]+)\\.sub\\.foo\\.bar$"},
+	}
+
+	for _, testCase := range testCases {
+		result := buildServerName(testCase.hostname)
+		if result != testCase.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
+		}
+	}
+}
+
+func TestCleanConf(t *testing.T) {
+	testDataDir, err := getTestDataDir()
+	if err != nil {
+		t.Error("unexpected error reading conf file: ", err)
+	}
+	actual := &bytes.Buffer{}
+	{
+		data, err := os.ReadFile(testDataDir + "/cleanConf.src.conf")
+		if err != nil {
+			t.Error("unexpected error reading conf file: ", err)
+		}
+		in := bytes.NewBuffer(data)
+		err = cleanConf(in, actual)
+		if err != nil {
+			t.Error("cleanConf failed: ", err)
+		}
+	}
+
+	expected, err := os.ReadFile(testDataDir + "/cleanConf.expected.conf")
+	if err != nil {
+		t.Error("unexpected error reading conf file: ", err)
+	}
+	if !bytes.Equal(expected, actual.Bytes()) {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{A: strings.SplitAfter(string(expected), "\n"), B: strings.SplitAfter(actual.String(), "\n"), Context: 3})
+		if err != nil {
+			t.Error("failed to get diff for cleanConf", err)
+		}
+		t.Errorf("cleanConf result don't match with expected: %s", diff)
+	}
+}
+
+func TestBuildAuthTLSForServer(t *testing.T) {
+	server := &ingress.Server{Hostname: "example.com"}
+	server.CertificateAuth.MatchOU = "^payments$"
+	if out := buildAuthTLSForServer(server); out != "" {
+		t.Errorf("expected no configuration for a server without client certificates but got %q", out)
+	}
+
+	server.CertificateAuth.CAFileName = "/etc/ingress-controller/ssl/ca-default-demo.pem"
+	server.CertificateAuth.MatchSAN = `^URI:spiffe://cluster\.local/ns/"payments"/`
+	server.CertificateAuth.IdentityHeaders = []authtls.IdentityHeader{{Attribute: "cn", Header: "X-Client-CN"}}
+
+	expected := `set $auth_tls_match_ou "^payments${literal_dollar}";
+set $auth_tls_match_san "^URI:spiffe://cluster\\.local/ns/\"payments\"/";
+`
+	if out := buildAuthTLSForServer(server); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildAuthTLSForLocation(t *testing.T) {
+	server := &ingress.Server{Hostname: "example.com"}
+	server.CertificateAuth.IdentityHeaders = []authtls.IdentityHeader{{Attribute: "cn", Header: "X-Client-CN"}}
+	if out := buildAuthTLSForLocation(server, "proxy_set_header"); out != "" {
+		t.Errorf("expected no configuration for a server without client certificates but got %q", out)
+	}
+
+	server.CertificateAuth.CAFileName = "/etc/ingress-controller/ssl/ca-default-demo.pem"
+	server.CertificateAuth.MatchOU = "^payments$"
+	server.CertificateAuth.IdentityHeaders = nil
+	if out := buildAuthTLSForLocation(server, "proxy_set_header"); out != "" {
+		t.Errorf("expected no configuration for a location without identity headers but got %q", out)
+	}
+
+	server.CertificateAuth.IdentityHeaders = []authtls.IdentityHeader{
+		{Attribute: "cn", Header: "X-Client-CN"},
+		{Attribute: "san", Header: "X-Client-SAN"},
+		{Attribute: "cn", Header: "X-Client-Name"},
+	}
+
+	expected := `set $auth_tls_identity_attributes "cn,san";
+set $auth_tls_identity_cn "";
+set $auth_tls_identity_san "";
+proxy_set_header X-Client-CN $auth_tls_identity_cn;
+proxy_set_header X-Client-SAN $auth_tls_identity_san;
+proxy_set_header X-Client-Name $auth_tls_identity_cn;
+`
+	if out := buildAuthTLSForLocation(server, "proxy_set_header"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestAuthTLSTemplate(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LuaSharedDicts = defaultLuaSharedDicts
+
+	server := dat.Servers[1]
+	if len(server.Locations) < 2 {
+		t.Fatalf("expected a server with several locations but got %v", len(server.Locations))
+	}
+	server.CertificateAuth.CAFileName = "/etc/ingress-controller/ssl/ca-default-demo.pem"
+	server.CertificateAuth.VerifyClient = "on"
+	server.CertificateAuth.ValidationDepth = 1
+	server.CertificateAuth.MatchOU = "^payments$"
+	server.CertificateAuth.MatchSAN = "^DNS:client\\.example\\.com$"
+	server.CertificateAuth.IdentityHeaders = []authtls.IdentityHeader{{Attribute: "cn", Header: "X-Client-CN"}}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	rt, err := ngxTpl.Write(&dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	root, err := conf.Parse(string(rt))
+	if err != nil {
+		t.Fatalf("unexpected error parsing the NGINX configuration: %v", err)
+	}
+
+	selector := fmt.Sprintf("server:has(> server_name[%v])", server.Hostname)
+	for _, variable := range []string{"$auth_tls_match_ou", "$auth_tls_match_san"} {
+		all, err := root.Query(fmt.Sprintf("%v set[%v]", selector, variable))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		inServer, err := root.Query(fmt.Sprintf("%v > set[%v]", selector, variable))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(all) != 1 || len(inServer) != 1 {
+			t.Errorf("expected %v to be set once in the server block but got %v, %v in the server block", variable, len(all), len(inServer))
+		}
+	}
+
+	locations, err := root.Query(selector + " > location:has(> set[$proxy_upstream_name])")
+	if err != nil || len(locations) != len(server.Locations) {
+		t.Fatalf("expected %v locations but got %v (%v)", len(server.Locations), len(locations), err)
+	}
+	if err := root.Every(selector+" > location:has(> set[$proxy_upstream_name])", "> proxy_set_header[X-Client-CN]"); err != nil {
+		t.Errorf("expected every location to send the identity headers: %v", err)
+	}
+}
+
+func TestBuildListenerWithClientIPAgent(t *testing.T) {
+	tc := config.TemplateConfig{
+		Cfg:           config.NewDefault(),
+		BacklogSize:   511,
+		IsIPV6Enabled: true,
+		ListenPorts:   &config.ListenPorts{HTTP: 80, HTTPS: 443},
+	}
+	if usesClientIPAgent(tc) {
+		t.Errorf("expected the client IP agent to be disabled without port")
+	}
+
+	tc.ListenPorts.ClientIPAgentHTTP = 8080
+	tc.ListenPorts.ClientIPAgentHTTPS = 8443
+	if !usesClientIPAgent(tc) {
+		t.Errorf("expected the client IP agent to be enabled")
+	}
+
+	expected := `listen 80  ;
+listen 8080 proxy_protocol  ;
+listen [::]:80  ;
+listen [::]:8080 proxy_protocol  ;`
+	if out := buildHTTPListener(tc, "example.com"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	expected = `listen 443 default_server reuseport backlog=511 ssl;
+listen 8443 proxy_protocol default_server reuseport backlog=511 ssl;
+listen [::]:443 default_server reuseport backlog=511 ssl;
+listen [::]:8443 proxy_protocol default_server reuseport backlog=511 ssl;`
+	if out := buildHTTPSListener(tc, "_"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	tc.Cfg.UseProxyProtocol = true
+	expected = `listen 443 proxy_protocol ssl;
+listen 8443 proxy_protocol ssl;
+listen [::]:443 proxy_protocol ssl;
+listen [::]:8443 proxy_protocol ssl;`
+	if out := buildHTTPSListener(tc, "example.com"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
+
+func TestBuildListenerWithClassListenPorts(t *testing.T) {
+	tc := config.TemplateConfig{
+		Cfg:           config.NewDefault(),
+		BacklogSize:   511,
+		IsIPV6Enabled: true,
+		ListenPorts:   &config.ListenPorts{HTTP: 80, HTTPS: 443},
+		ClassListenPorts: map[string]*ingress.ClassListenPorts{
+			"internal.example.com": {HTTP: 8080, HTTPS: 8443},
+		},
+	}
+
+	expected := `listen 8080  ;
+listen [::]:8080  ;`
+	if out := buildHTTPListener(tc, "internal.example.com"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	expected = `listen 8443  ssl;
+listen [::]:8443  ssl;`
+	if out := buildHTTPSListener(tc, "internal.example.com"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	expected = `listen 80  ;
+listen [::]:80  ;`
+	if out := buildHTTPListener(tc, "example.com"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+
+	expected = `listen 443 default_server reuseport backlog=511 ssl;
+listen [::]:443 default_server reuseport backlog=511 ssl;
+listen 8443 default_server reuseport backlog=511 ssl;
+listen [::]:8443 default_server reuseport backlog=511 ssl;`
+	if out := buildHTTPSListener(tc, "_"); out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
 	}
 }
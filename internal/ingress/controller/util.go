@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -27,7 +28,11 @@ import (
 
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
 	klog "k8s.io/klog/v2"
 )
@@ -73,6 +78,43 @@ func upstreamServiceNameAndPort(service *networking.IngressServiceBackend) (stri
 	return "", intstr.IntOrString{}
 }
 
+// updateConfigMap applies mutate to the ConfigMap name, in the form
+// "namespace/name", creating it when it does not exist. The ConfigMap is
+// read and mutated again when the update conflicts with another replica.
+func (n *NGINXController) updateConfigMap(name string, mutate func(*api.ConfigMap) error) error {
+	ns, name, err := k8s.ParseNameNS(name)
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := n.cfg.Client.CoreV1().ConfigMaps(ns)
+
+		cm, err := configMaps.Get(context.TODO(), name, metav1.GetOptions{})
+		create := k8s_errors.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		if create {
+			cm = &api.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if err := mutate(cm); err != nil {
+			return err
+		}
+
+		if create {
+			_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		} else {
+			_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		}
+		return err
+	})
+}
+
 // sysctlSomaxconn returns the maximum number of connections that can be queued
 // for acceptance (value of net.core.somaxconn)
 // http://nginx.org/en/docs/http/ngx_http_core_module.html#listen
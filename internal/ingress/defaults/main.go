@@ -174,6 +174,11 @@ type Backend struct {
 	// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_http_version
 	ProxyHTTPVersion string `json:"proxy-http-version"`
 
+	// Enables or disables chunked transfer encoding in the requests sent to
+	// the upstreams. When "off" the request bodies are buffered to send them
+	// with a Content-Length header, like proxy-request-buffering "on".
+	ChunkedTransferEncoding string `json:"chunked-transfer-encoding"`
+
 	// Sets the maximum temp file size when proxy-buffers capacity is exceeded.
 	// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_max_temp_file_size
 	ProxyMaxTempFileSize string `json:"proxy-max-temp-file-size"`
@@ -184,6 +189,22 @@ type Backend struct {
 
 	// AllowedResponseHeaders allows to define allow response headers for custom header annotation
 	AllowedResponseHeaders []string `json:"global-allowed-response-headers"`
+
+	// Activates the cache for connections to upstream servers.
+	// The connections parameter sets the maximum number of idle keepalive connections to
+	// upstream servers that are preserved in the cache of each worker process. When this
+	// number is exceeded, the least recently used connections are closed.
+	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive
+	UpstreamKeepaliveConnections int `json:"upstream-keepalive-connections"`
+
+	// Sets a timeout during which an idle keepalive connection to an upstream server will stay open.
+	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive_timeout
+	UpstreamKeepaliveTimeout int `json:"upstream-keepalive-timeout"`
+
+	// Sets the maximum number of requests that can be served through one keepalive connection.
+	// After the maximum number of requests is made, the connection is closed.
+	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#keepalive_requests
+	UpstreamKeepaliveRequests int `json:"upstream-keepalive-requests"`
 }
 
 type SecurityConfiguration struct {
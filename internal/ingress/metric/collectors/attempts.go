@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// maxAttemptEndpoints bounds the number of endpoints reported for the
+// attempts of the requests of an Ingress. The attempts to the other
+// endpoints are reported with the endpoint otherEndpoint.
+const maxAttemptEndpoints = 50
+
+const otherEndpoint = "other"
+
+// upstreamAttempt is a try of a request to an endpoint of the upstream
+type upstreamAttempt struct {
+	Endpoint string
+	// Status is the status code returned by the endpoint, 502 for the
+	// connection errors and 504 for the timeouts
+	Status string
+}
+
+// upstreamAttempts returns the tries of a request to the endpoints of the
+// upstream in the order of the $upstream_addr and $upstream_status variables
+// of NGINX, like "10.0.0.1:8080, 10.0.0.2:8080" and "502, 200". The tries of
+// the upstreams of internal redirects are separated by a colon.
+func upstreamAttempts(addrs, statuses string) []upstreamAttempt {
+	if addrs == "" || addrs == "-" {
+		return nil
+	}
+
+	endpoints := splitUpstreamVariable(addrs)
+	codes := splitUpstreamVariable(statuses)
+
+	attempts := make([]upstreamAttempt, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		status := "-"
+		if len(codes) == len(endpoints) {
+			status = codes[i]
+		}
+		attempts = append(attempts, upstreamAttempt{Endpoint: endpoint, Status: status})
+	}
+	return attempts
+}
+
+func splitUpstreamVariable(value string) []string {
+	return strings.Split(strings.ReplaceAll(value, " : ", ", "), ", ")
+}
+
+// attemptEndpoints bounds the endpoints reported for the attempts of the
+// requests of every Ingress
+type attemptEndpoints struct {
+	mu        sync.Mutex
+	endpoints map[string]sets.Set[string]
+}
+
+// label returns the endpoint reported for an attempt of a request of an
+// Ingress, otherEndpoint once maxAttemptEndpoints endpoints are reported
+func (a *attemptEndpoints) label(ingress, endpoint string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.endpoints == nil {
+		a.endpoints = map[string]sets.Set[string]{}
+	}
+
+	reported, ok := a.endpoints[ingress]
+	if !ok {
+		reported = sets.New[string]()
+		a.endpoints[ingress] = reported
+	}
+
+	if reported.Has(endpoint) {
+		return endpoint
+	}
+	if reported.Len() >= maxAttemptEndpoints {
+		return otherEndpoint
+	}
+
+	reported.Insert(endpoint)
+	return endpoint
+}
+
+// forget forgets the endpoints reported for the removed Ingresses
+func (a *attemptEndpoints) forget(ingresses []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, ingress := range ingresses {
+		delete(a.endpoints, ingress)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package collectors
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUpstreamAttempts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		addrs    string
+		statuses string
+		expected []upstreamAttempt
+	}{
+		{"not proxied", "-", "-", nil},
+		{"single try", "10.0.0.1:80", "200", []upstreamAttempt{{"10.0.0.1:80", "200"}}},
+		{
+			"next upstream", "10.0.0.1:80, 10.0.0.2:80", "504, 200",
+			[]upstreamAttempt{{"10.0.0.1:80", "504"}, {"10.0.0.2:80", "200"}},
+		},
+		{
+			"internal redirect", "10.0.0.1:80 : 10.0.0.3:80", "404 : 200",
+			[]upstreamAttempt{{"10.0.0.1:80", "404"}, {"10.0.0.3:80", "200"}},
+		},
+		{
+			"missing statuses", "10.0.0.1:80, 10.0.0.2:80", "-",
+			[]upstreamAttempt{{"10.0.0.1:80", "-"}, {"10.0.0.2:80", "-"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := upstreamAttempts(tc.addrs, tc.statuses)
+			if !reflect.DeepEqual(attempts, tc.expected) {
+				t.Errorf("expected %v but returned %v", tc.expected, attempts)
+			}
+		})
+	}
+}
+
+func TestAttemptEndpointsLabel(t *testing.T) {
+	var endpoints attemptEndpoints
+
+	for i := 0; i < maxAttemptEndpoints; i++ {
+		endpoint := fmt.Sprintf("10.0.0.%d:80", i)
+		if label := endpoints.label("default/web", endpoint); label != endpoint {
+			t.Fatalf("expected %v but returned %v", endpoint, label)
+		}
+	}
+
+	if label := endpoints.label("default/web", "10.0.1.1:80"); label != otherEndpoint {
+		t.Errorf("expected %v once the endpoints are bounded but returned %v", otherEndpoint, label)
+	}
+	if label := endpoints.label("default/web", "10.0.0.1:80"); label != "10.0.0.1:80" {
+		t.Errorf("expected a reported endpoint to keep its label but returned %v", label)
+	}
+	if label := endpoints.label("default/api", "10.0.1.1:80"); label != "10.0.1.1:80" {
+		t.Errorf("expected the endpoints to be bounded per Ingress but returned %v", label)
+	}
+
+	endpoints.forget([]string{"default/web"})
+	if label := endpoints.label("default/web", "10.0.1.1:80"); label != "10.0.1.1:80" {
+		t.Errorf("expected the endpoints of a removed Ingress to be forgotten but returned %v", label)
+	}
+}
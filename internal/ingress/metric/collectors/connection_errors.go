@@ -0,0 +1,141 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"k8s.io/klog/v2"
+)
+
+// connectionErrorsSocket receives the error log of NGINX at the info level,
+// which reports the TLS handshakes that failed and the connections reset by
+// the clients, when enable-tls-handshake-metrics is set
+const connectionErrorsSocket = "/tmp/nginx/connection-errors.socket"
+
+// maxErrorLogMessage is the maximum size of a message of the error log
+const maxErrorLogMessage = 4096
+
+// the reasons of the TLS handshake failures, by error of the log
+var handshakeFailureReasons = []struct {
+	reason string
+	errors []string
+}{
+	{"no_shared_cipher", []string{"no shared cipher", "no suitable signature algorithm"}},
+	{"unsupported_protocol", []string{"unsupported protocol", "wrong version number", "version too low", "unknown protocol", "http request"}},
+	{"certificate_rejected", []string{"alert bad certificate", "alert certificate unknown", "alert unknown ca", "alert certificate expired"}},
+	{"alert", []string{"alert"}},
+	{"timeout", []string{"timed out"}},
+	{"reset", []string{"Connection reset by peer"}},
+	{"closed", []string{"closed connection"}},
+}
+
+func listenConnectionErrors() (net.PacketConn, error) {
+	// unix sockets must be unlink()ed before being used
+	//nolint:errcheck // Ignore unlink error
+	_ = syscall.Unlink(connectionErrorsSocket)
+
+	conn, err := net.ListenPacket("unixgram", connectionErrorsSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(connectionErrorsSocket, 0o777); err != nil { // #nosec
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// readConnectionErrors reads the messages of the error log until the
+// socket is closed
+func (sc *SocketCollector) readConnectionErrors() {
+	buf := make([]byte, maxErrorLogMessage)
+	for {
+		n, _, err := sc.errorLog.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			klog.V(3).ErrorS(err, "Error reading the NGINX error log")
+			continue
+		}
+
+		sc.handleConnectionError(string(buf[:n]))
+	}
+}
+
+// handleConnectionError counts the TLS handshake failures and the
+// connections reset by the clients reported by a message of the error log
+func (sc *SocketCollector) handleConnectionError(msg string) {
+	if reason, ok := handshakeFailure(msg); ok {
+		if sc.tlsHandshakeFailures != nil {
+			sc.tlsHandshakeFailures.WithLabelValues(reason).Inc()
+		}
+		return
+	}
+
+	if serverName, ok := connectionReset(msg); ok && sc.connectionResets != nil {
+		sc.connectionResets.WithLabelValues(sc.serverNameLabel(serverName)).Inc()
+	}
+}
+
+// handshakeFailure returns the reason of the TLS handshake failure reported
+// by a message of the error log, like
+// "SSL_do_handshake() failed (SSL: error:0A0000C1:SSL routines::no shared cipher) while SSL handshaking, client: ..."
+func handshakeFailure(msg string) (string, bool) {
+	if !strings.Contains(msg, "while SSL handshaking") {
+		return "", false
+	}
+
+	for _, r := range handshakeFailureReasons {
+		for _, e := range r.errors {
+			if strings.Contains(msg, e) {
+				return r.reason, true
+			}
+		}
+	}
+
+	return "error", true
+}
+
+// connectionReset returns the server name of the connection reset by the
+// client reported by a message of the error log, like
+// "recv() failed (104: Connection reset by peer) while reading client request line, client: ..., server: example.com"
+func connectionReset(msg string) (string, bool) {
+	cause, context, found := strings.Cut(msg, " while ")
+	if !found || !strings.Contains(cause, "Connection reset by peer") || strings.Contains(context, "upstream") {
+		return "", false
+	}
+
+	_, server, found := strings.Cut(context, ", server: ")
+	if !found {
+		return "", true
+	}
+	server, _, _ = strings.Cut(server, ",")
+	server = strings.TrimSpace(server)
+	if server == "_" {
+		return "", true
+	}
+
+	return server, true
+}
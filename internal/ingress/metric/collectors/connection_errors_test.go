@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestHandshakeFailure(t *testing.T) {
+	testCases := map[string]struct {
+		msg            string
+		expectedReason string
+		expectedOK     bool
+	}{
+		"no shared cipher": {
+			msg:            `*1 SSL_do_handshake() failed (SSL: error:0A0000C1:SSL routines::no shared cipher) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "no_shared_cipher",
+			expectedOK:     true,
+		},
+		"unsupported protocol": {
+			msg:            `*1 SSL_do_handshake() failed (SSL: error:0A000102:SSL routines::unsupported protocol) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "unsupported_protocol",
+			expectedOK:     true,
+		},
+		"certificate rejected by the client": {
+			msg:            `*1 SSL_do_handshake() failed (SSL: error:0A000416:SSL routines::sslv3 alert certificate unknown:SSL alert number 46) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "certificate_rejected",
+			expectedOK:     true,
+		},
+		"timeout": {
+			msg:            `*1 client timed out (110: Connection timed out) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "timeout",
+			expectedOK:     true,
+		},
+		"closed": {
+			msg:            `*1 peer closed connection in SSL handshake while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "closed",
+			expectedOK:     true,
+		},
+		"unknown error": {
+			msg:            `*1 SSL_do_handshake() failed (SSL: error:0A00010B:SSL routines::wrong ssl version) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+			expectedReason: "error",
+			expectedOK:     true,
+		},
+		"other message": {
+			msg: `*1 client 10.0.0.1 closed keepalive connection`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			reason, ok := handshakeFailure(tc.msg)
+			if reason != tc.expectedReason || ok != tc.expectedOK {
+				t.Errorf("expected %q, %t but got %q, %t", tc.expectedReason, tc.expectedOK, reason, ok)
+			}
+		})
+	}
+}
+
+func TestConnectionReset(t *testing.T) {
+	testCases := map[string]struct {
+		msg                string
+		expectedServerName string
+		expectedOK         bool
+	}{
+		"reset while reading the request": {
+			msg:                `*1 recv() failed (104: Connection reset by peer) while reading client request headers, client: 10.0.0.1, server: testshop.com, request: "GET / HTTP/1.1", host: "testshop.com"`,
+			expectedServerName: "testshop.com",
+			expectedOK:         true,
+		},
+		"reset while sending the response": {
+			msg:                `*1 writev() failed (104: Connection reset by peer) while sending to client, client: 10.0.0.1, server: testshop.com, request: "GET / HTTP/1.1"`,
+			expectedServerName: "testshop.com",
+			expectedOK:         true,
+		},
+		"reset of the default server": {
+			msg:        `*1 recv() failed (104: Connection reset by peer) while reading client request line, client: 10.0.0.1, server: _`,
+			expectedOK: true,
+		},
+		"reset by the upstream": {
+			msg: `*1 recv() failed (104: Connection reset by peer) while reading response header from upstream, client: 10.0.0.1, server: testshop.com, upstream: "http://10.1.0.1:80/"`,
+		},
+		"other message": {
+			msg: `*1 client 10.0.0.1 closed keepalive connection`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			serverName, ok := connectionReset(tc.msg)
+			if serverName != tc.expectedServerName || ok != tc.expectedOK {
+				t.Errorf("expected %q, %t but got %q, %t", tc.expectedServerName, tc.expectedOK, serverName, ok)
+			}
+		})
+	}
+}
+
+func TestHandleConnectionError(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+
+	sc, err := NewSocketCollector("pod", "default", "ingress", true, false, false, HistogramBuckets{}, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating new SocketCollector: %v", err)
+	}
+	defer sc.Stop()
+
+	if err := registry.Register(sc); err != nil {
+		t.Fatalf("registering collector failed: %s", err)
+	}
+	sc.SetHosts(sets.New[string]("testshop.com"))
+
+	for _, msg := range []string{
+		`<14>Oct 15 18:22:21 nginx: 2024/10/15 18:22:21 [info] 31#31: *1 SSL_do_handshake() failed (SSL: error:0A0000C1:SSL routines::no shared cipher) while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+		`<14>Oct 15 18:22:21 nginx: 2024/10/15 18:22:21 [info] 31#31: *2 peer closed connection in SSL handshake while SSL handshaking, client: 10.0.0.1, server: 0.0.0.0:443`,
+		`<14>Oct 15 18:22:21 nginx: 2024/10/15 18:22:21 [info] 31#31: *3 recv() failed (104: Connection reset by peer) while reading client request headers, client: 10.0.0.1, server: testshop.com`,
+		`<14>Oct 15 18:22:21 nginx: 2024/10/15 18:22:21 [info] 31#31: *4 recv() failed (104: Connection reset by peer) while reading client request headers, client: 10.0.0.1, server: unknown.com`,
+		`<14>Oct 15 18:22:21 nginx: 2024/10/15 18:22:21 [info] 31#31: *5 client 10.0.0.1 closed keepalive connection`,
+	} {
+		sc.handleConnectionError(msg)
+	}
+
+	want := `
+		# HELP nginx_ingress_controller_connection_resets_total The total number of connections reset by the clients, by server name
+		# TYPE nginx_ingress_controller_connection_resets_total counter
+		nginx_ingress_controller_connection_resets_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",server_name="-"} 1
+		nginx_ingress_controller_connection_resets_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",server_name="testshop.com"} 1
+		# HELP nginx_ingress_controller_tls_handshake_failures_total The total number of TLS handshakes that failed, by reason
+		# TYPE nginx_ingress_controller_tls_handshake_failures_total counter
+		nginx_ingress_controller_tls_handshake_failures_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",reason="closed"} 1
+		nginx_ingress_controller_tls_handshake_failures_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",reason="no_shared_cipher"} 1
+	`
+	metrics := []string{"nginx_ingress_controller_connection_resets_total", "nginx_ingress_controller_tls_handshake_failures_total"}
+	if err := GatherAndCompare(sc, want, metrics, registry); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
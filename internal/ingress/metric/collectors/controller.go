@@ -18,6 +18,9 @@ package collectors
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,11 +31,21 @@ import (
 )
 
 var (
-	operation        = []string{"controller_namespace", "controller_class", "controller_pod"}
-	ingressOperation = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress"}
-	sslLabelHost     = []string{"namespace", "class", "host", "secret_name", "identifier"}
-	sslInfoLabels    = []string{"namespace", "class", "host", "secret_name", "identifier", "issuer_organization", "issuer_common_name", "serial_number", "public_key_algorithm"}
-	orphanityLabels  = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress", "type"}
+	operation         = []string{"controller_namespace", "controller_class", "controller_pod"}
+	ingressOperation  = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress"}
+	sslLabelHost      = []string{"namespace", "class", "host", "secret_name", "identifier"}
+	sslInfoLabels     = []string{"namespace", "class", "host", "secret_name", "identifier", "issuer_organization", "issuer_common_name", "serial_number", "public_key_algorithm"}
+	orphanityLabels   = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress", "type"}
+	overrideLabels    = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress", "annotation"}
+	crlLabels         = []string{"controller_namespace", "controller_class", "controller_pod", "url"}
+	crlResultLabels   = []string{"controller_namespace", "controller_class", "controller_pod", "url", "result"}
+	ocspLabels        = []string{"controller_namespace", "controller_class", "controller_pod", "responder"}
+	ocspResultLabels  = []string{"controller_namespace", "controller_class", "controller_pod", "responder", "result"}
+	fallbackLabels    = []string{"controller_namespace", "controller_class", "controller_pod", "host", "namespace", "ingress", "secret_name", "reason", "fake_certificate"}
+	slowStartLabels   = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "service"}
+	conflictLabels    = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress", "host", "path", "winner"}
+	passthroughLabels = []string{"controller_namespace", "controller_class", "controller_pod", "host", "action"}
+	probeLabels       = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress", "host", "path"}
 )
 
 // Controller defines base metrics about the ingress controller
@@ -50,6 +63,21 @@ type Controller struct {
 	sslExpireTime               *prometheus.GaugeVec
 	sslInfo                     *prometheus.GaugeVec
 	OrphanIngress               *prometheus.GaugeVec
+	defaultAnnotationOverrides  *prometheus.GaugeVec
+	crlRefreshDuration          *prometheus.HistogramVec
+	crlRefresh                  *prometheus.CounterVec
+	ocspCheckDuration           *prometheus.HistogramVec
+	ocspCheck                   *prometheus.CounterVec
+	sslCertificateFallback      *prometheus.GaugeVec
+	slowStartWarmingEndpoints   *prometheus.GaugeVec
+	ingressConflict             *prometheus.GaugeVec
+	sslPassthroughConnections   *prometheus.CounterVec
+	syntheticProbeSuccess       *prometheus.GaugeVec
+	syntheticProbeDuration      *prometheus.GaugeVec
+
+	// slowStartMu protects the endpoints of the backends with slow start
+	slowStartMu       sync.Mutex
+	slowStartBackends map[string]*slowStartBackend
 
 	constLabels prometheus.Labels
 	labels      prometheus.Labels
@@ -59,6 +87,15 @@ type Controller struct {
 	buildInfo prometheus.Collector
 }
 
+// slowStartBackend tracks when the endpoints of a backend with slow start
+// were first seen
+type slowStartBackend struct {
+	namespace string
+	service   string
+	duration  time.Duration
+	firstSeen map[string]time.Time
+}
+
 // NewController creates a new prometheus collector for the
 // Ingress controller operations
 func NewController(pod, namespace, class string) *Controller {
@@ -182,6 +219,101 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			orphanityLabels,
 		),
+		defaultAnnotationOverrides: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "default_annotation_overridden",
+				Help: `Gauge reporting namespace default annotations overridden by an Ingress, 1 indicates the Ingress sets a different value.
+			'namespace' and 'ingress' identify the Ingress and 'annotation' the overridden default`,
+			},
+			overrideLabels,
+		),
+		crlRefreshDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "crl_refresh_duration_seconds",
+				Help:      `Time spent downloading and validating the certificate revocation lists used for client certificate authentication`,
+			},
+			crlLabels,
+		),
+		crlRefresh: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "crl_refresh_total",
+				Help: `Cumulative number of certificate revocation list downloads.
+			'url' is the location of the CRL and 'result' is 'success' or 'error'`,
+			},
+			crlResultLabels,
+		),
+		ocspCheckDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ocsp_check_duration_seconds",
+				Help:      `Time spent obtaining and validating a response of the OCSP responders used for client certificate authentication`,
+			},
+			ocspLabels,
+		),
+		ocspCheck: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ocsp_check_total",
+				Help: `Cumulative number of OCSP responder checks.
+			'responder' is the URL of the OCSP responder and 'result' is 'success' or 'error'`,
+			},
+			ocspResultLabels,
+		),
+		sslCertificateFallback: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ssl_certificate_fallback",
+				Help: `Gauge reporting hosts served with the default certificate instead of the certificate of their TLS section, 1 indicates the host uses the default certificate.
+			'reason' is 'no-secret-name', 'secret-missing', 'secret-not-synced', 'secret-invalid' or 'host-mismatch' and 'fake_certificate' indicates the default certificate is the one generated by the controller`,
+			},
+			fallbackLabels,
+		),
+		slowStartWarmingEndpoints: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "slow_start_warming_endpoints",
+				Help:      `Number of endpoints of the backends of a Service whose weight is still being ramped up by the slow start`,
+			},
+			slowStartLabels,
+		),
+		ingressConflict: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ingress_conflict",
+				Help: `Gauge reporting the hosts and paths of an Ingress not served because other Ingresses define them, 1 indicates the Ingress does not serve them.
+			'winner' is the Ingress serving them, empty when none of the Ingresses serves them`,
+			},
+			conflictLabels,
+		),
+		sslPassthroughConnections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ssl_passthrough_connections_total",
+				Help: `Cumulative number of TLS connections handled by the SSL passthrough proxy not terminated by NGINX as one of its hosts.
+			'action' is 'passthrough', 'fallback', 'terminate' or 'reject' and 'host' is the passthrough host, empty for the connections with an unmatched SNI not passed through`,
+			},
+			passthroughLabels,
+		),
+		syntheticProbeSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "synthetic_probe_success",
+				Help:      `Gauge reporting the result of the last synthetic probe of the host and path of an Ingress sent through NGINX, 1 indicates the probe returned an expected status`,
+			},
+			probeLabels,
+		),
+		syntheticProbeDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "synthetic_probe_duration_seconds",
+				Help:      `Duration of the last synthetic probe of the host and path of an Ingress sent through NGINX`,
+			},
+			probeLabels,
+		),
+		slowStartBackends: map[string]*slowStartBackend{},
 	}
 
 	return cm
@@ -245,6 +377,183 @@ func (cm *Controller) DecOrphanIngress(namespace, name, orphanityType string) {
 	cm.OrphanIngress.MustCurryWith(cm.constLabels).With(labels).Set(0.0)
 }
 
+// SetDefaultAnnotationOverrides sets the namespace default annotations
+// overridden by each Ingress, removing the entries of previous syncs
+func (cm *Controller) SetDefaultAnnotationOverrides(ingresses []*ingress.Ingress) {
+	cm.defaultAnnotationOverrides.Reset()
+
+	for _, ing := range ingresses {
+		for _, annotation := range ing.OverriddenDefaultAnnotations {
+			labels := prometheus.Labels{
+				"namespace":  ing.Namespace,
+				"ingress":    ing.Name,
+				"annotation": annotation,
+			}
+			cm.defaultAnnotationOverrides.MustCurryWith(cm.constLabels).With(labels).Set(1.0)
+		}
+	}
+}
+
+// SetSSLCertificateFallbacks sets the hosts served with the default
+// certificate, removing the entries of previous syncs
+func (cm *Controller) SetSSLCertificateFallbacks(servers []*ingress.Server) {
+	cm.sslCertificateFallback.Reset()
+
+	for _, s := range servers {
+		if s.SSLCertFallback == nil {
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(s.SSLCertFallback.Ingress, "/")
+		_, secretName, _ := strings.Cut(s.SSLCertFallback.Secret, "/")
+
+		labels := prometheus.Labels{
+			"host":             s.Hostname,
+			"namespace":        namespace,
+			"ingress":          name,
+			"secret_name":      secretName,
+			"reason":           s.SSLCertFallback.Reason,
+			"fake_certificate": strconv.FormatBool(s.SSLCertFallback.FakeCertificate),
+		}
+		cm.sslCertificateFallback.MustCurryWith(cm.constLabels).With(labels).Set(1.0)
+	}
+}
+
+// SetIngressConflicts sets the hosts and paths not served by Ingresses,
+// removing the entries of previous syncs
+func (cm *Controller) SetIngressConflicts(conflicts []ingress.IngressConflict) {
+	cm.ingressConflict.Reset()
+
+	for _, c := range conflicts {
+		namespace, name, _ := strings.Cut(c.Ingress, "/")
+
+		labels := prometheus.Labels{
+			"namespace": namespace,
+			"ingress":   name,
+			"host":      c.Host,
+			"path":      c.Path,
+			"winner":    c.Winner,
+		}
+		cm.ingressConflict.MustCurryWith(cm.constLabels).With(labels).Set(1.0)
+	}
+}
+
+// SetSyntheticProbeResults sets the results of the last synthetic probes,
+// removing the entries of previous probes
+func (cm *Controller) SetSyntheticProbeResults(results []ingress.SyntheticProbeResult) {
+	cm.syntheticProbeSuccess.Reset()
+	cm.syntheticProbeDuration.Reset()
+
+	for _, r := range results {
+		namespace, name, _ := strings.Cut(r.Ingress, "/")
+
+		labels := prometheus.Labels{
+			"namespace": namespace,
+			"ingress":   name,
+			"host":      r.Host,
+			"path":      r.Path,
+		}
+
+		success := 0.0
+		if r.Success {
+			success = 1.0
+		}
+		cm.syntheticProbeSuccess.MustCurryWith(cm.constLabels).With(labels).Set(success)
+		cm.syntheticProbeDuration.MustCurryWith(cm.constLabels).With(labels).Set(r.Duration.Seconds())
+	}
+}
+
+// SetSlowStartEndpoints tracks the endpoints of the backends with slow start.
+// The endpoints present the first time a backend is seen are considered warm,
+// like the balancer of the NGINX workers does.
+func (cm *Controller) SetSlowStartEndpoints(backends []*ingress.Backend) {
+	now := time.Now()
+	slowStartBackends := map[string]*slowStartBackend{}
+
+	cm.slowStartMu.Lock()
+	defer cm.slowStartMu.Unlock()
+
+	for _, b := range backends {
+		if b.SlowStart.Duration <= 0 || b.Service == nil {
+			continue
+		}
+
+		previous := cm.slowStartBackends[b.Name]
+		backend := &slowStartBackend{
+			namespace: b.Service.Namespace,
+			service:   b.Service.Name,
+			duration:  time.Duration(b.SlowStart.Duration * float64(time.Second)),
+			firstSeen: make(map[string]time.Time, len(b.Endpoints)),
+		}
+		for _, ep := range b.Endpoints {
+			key := ep.Address + ":" + ep.Port
+			since := now
+			if previous == nil {
+				since = time.Time{}
+			} else if seen, ok := previous.firstSeen[key]; ok {
+				since = seen
+			}
+			backend.firstSeen[key] = since
+		}
+		slowStartBackends[b.Name] = backend
+	}
+
+	cm.slowStartBackends = slowStartBackends
+}
+
+// collectSlowStartEndpoints sets the number of warming endpoints per Service
+func (cm *Controller) collectSlowStartEndpoints(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	cm.slowStartMu.Lock()
+	cm.slowStartWarmingEndpoints.Reset()
+	for _, b := range cm.slowStartBackends {
+		labels := prometheus.Labels{
+			"namespace": b.namespace,
+			"service":   b.service,
+		}
+		gauge := cm.slowStartWarmingEndpoints.MustCurryWith(cm.constLabels).With(labels)
+		for _, since := range b.firstSeen {
+			if now.Sub(since) < b.duration {
+				gauge.Inc()
+			}
+		}
+	}
+	cm.slowStartMu.Unlock()
+
+	cm.slowStartWarmingEndpoints.Collect(ch)
+}
+
+// ObserveCRLRefresh records the duration and the result of the download
+// of the certificate revocation list located at url
+func (cm *Controller) ObserveCRLRefresh(url string, duration time.Duration, success bool) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+
+	cm.crlRefreshDuration.MustCurryWith(cm.constLabels).With(prometheus.Labels{"url": url}).Observe(duration.Seconds())
+	cm.crlRefresh.MustCurryWith(cm.constLabels).With(prometheus.Labels{"url": url, "result": result}).Inc()
+}
+
+// ObserveOCSPCheck records the duration and the result of the check
+// of the OCSP responder located at responder
+func (cm *Controller) ObserveOCSPCheck(responder string, duration time.Duration, success bool) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+
+	cm.ocspCheckDuration.MustCurryWith(cm.constLabels).With(prometheus.Labels{"responder": responder}).Observe(duration.Seconds())
+	cm.ocspCheck.MustCurryWith(cm.constLabels).With(prometheus.Labels{"responder": responder, "result": result}).Inc()
+}
+
+// IncSSLPassthroughConnection increments the counter of the connections
+// handled by the SSL passthrough proxy for host and action
+func (cm *Controller) IncSSLPassthroughConnection(host, action string) {
+	cm.sslPassthroughConnections.MustCurryWith(cm.constLabels).With(prometheus.Labels{"host": host, "action": action}).Inc()
+}
+
 // ConfigSuccess set a boolean flag according to the output of the controller configuration reload
 func (cm *Controller) ConfigSuccess(hash uint64, success bool) {
 	if success {
@@ -274,6 +583,17 @@ func (cm *Controller) Describe(ch chan<- *prometheus.Desc) {
 	cm.leaderElection.Describe(ch)
 	cm.buildInfo.Describe(ch)
 	cm.OrphanIngress.Describe(ch)
+	cm.defaultAnnotationOverrides.Describe(ch)
+	cm.crlRefreshDuration.Describe(ch)
+	cm.crlRefresh.Describe(ch)
+	cm.ocspCheckDuration.Describe(ch)
+	cm.ocspCheck.Describe(ch)
+	cm.sslCertificateFallback.Describe(ch)
+	cm.ingressConflict.Describe(ch)
+	cm.slowStartWarmingEndpoints.Describe(ch)
+	cm.sslPassthroughConnections.Describe(ch)
+	cm.syntheticProbeSuccess.Describe(ch)
+	cm.syntheticProbeDuration.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -290,6 +610,17 @@ func (cm *Controller) Collect(ch chan<- prometheus.Metric) {
 	cm.leaderElection.Collect(ch)
 	cm.buildInfo.Collect(ch)
 	cm.OrphanIngress.Collect(ch)
+	cm.defaultAnnotationOverrides.Collect(ch)
+	cm.crlRefreshDuration.Collect(ch)
+	cm.crlRefresh.Collect(ch)
+	cm.ocspCheckDuration.Collect(ch)
+	cm.ocspCheck.Collect(ch)
+	cm.sslCertificateFallback.Collect(ch)
+	cm.ingressConflict.Collect(ch)
+	cm.collectSlowStartEndpoints(ch)
+	cm.sslPassthroughConnections.Collect(ch)
+	cm.syntheticProbeSuccess.Collect(ch)
+	cm.syntheticProbeDuration.Collect(ch)
 }
 
 // SetSSLExpireTime sets the expiration time of SSL Certificates
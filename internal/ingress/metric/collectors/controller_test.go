@@ -24,6 +24,10 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/slowstart"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
 )
 
@@ -201,6 +205,130 @@ func TestControllerCounters(t *testing.T) {
 			want:    ``,
 			metrics: []string{"nginx_ingress_controller_ssl_certificate_info"},
 		},
+		{
+			name: "should set hosts served with the default certificate",
+			test: func(cm *Controller) {
+				servers := []*ingress.Server{
+					{
+						Hostname: "demo",
+						SSLCertFallback: &ingress.SSLCertFallback{
+							Reason:          ingress.SSLCertFallbackSecretMissing,
+							Ingress:         "ingress-namespace/ingress-name",
+							Secret:          "ingress-namespace/secret-name",
+							FakeCertificate: true,
+						},
+					},
+					{
+						Hostname: "empty",
+						SSLCertFallback: &ingress.SSLCertFallback{
+							Reason:  ingress.SSLCertFallbackNoSecretName,
+							Ingress: "ingress-namespace/ingress-name",
+						},
+					},
+					{
+						Hostname: "valid",
+						SSLCert:  &ingress.SSLCert{},
+					},
+				}
+				// the hosts of a previous sync are removed
+				cm.SetSSLCertificateFallbacks([]*ingress.Server{{Hostname: "previous", SSLCertFallback: servers[0].SSLCertFallback}})
+				cm.SetSSLCertificateFallbacks(servers)
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_certificate_fallback Gauge reporting hosts served with the default certificate instead of the certificate of their TLS section, 1 indicates the host uses the default certificate.\n			'reason' is 'no-secret-name', 'secret-missing', 'secret-not-synced', 'secret-invalid' or 'host-mismatch' and 'fake_certificate' indicates the default certificate is the one generated by the controller
+				# TYPE nginx_ingress_controller_ssl_certificate_fallback gauge
+				nginx_ingress_controller_ssl_certificate_fallback{controller_class="nginx",controller_namespace="default",controller_pod="pod",fake_certificate="false",host="empty",ingress="ingress-name",namespace="ingress-namespace",reason="no-secret-name",secret_name=""} 1
+				nginx_ingress_controller_ssl_certificate_fallback{controller_class="nginx",controller_namespace="default",controller_pod="pod",fake_certificate="true",host="demo",ingress="ingress-name",namespace="ingress-namespace",reason="secret-missing",secret_name="secret-name"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_certificate_fallback"},
+		},
+		{
+			name: "should set the ingress conflicts",
+			test: func(cm *Controller) {
+				cm.SetIngressConflicts([]ingress.IngressConflict{{Ingress: "ingress-namespace/previous", Host: "demo", Path: "/"}})
+				cm.SetIngressConflicts([]ingress.IngressConflict{
+					{Ingress: "ingress-namespace/newest", Host: "demo", Path: "/", Winner: "ingress-namespace/oldest"},
+					{Ingress: "ingress-namespace/other", Host: "demo", Path: "/api"},
+				})
+			},
+			want: `
+				# HELP nginx_ingress_controller_ingress_conflict Gauge reporting the hosts and paths of an Ingress not served because other Ingresses define them, 1 indicates the Ingress does not serve them.\n			'winner' is the Ingress serving them, empty when none of the Ingresses serves them
+				# TYPE nginx_ingress_controller_ingress_conflict gauge
+				nginx_ingress_controller_ingress_conflict{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="newest",namespace="ingress-namespace",path="/",winner="ingress-namespace/oldest"} 1
+				nginx_ingress_controller_ingress_conflict{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="other",namespace="ingress-namespace",path="/api",winner=""} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ingress_conflict"},
+		},
+		{
+			name: "should count the SSL passthrough connections",
+			test: func(cm *Controller) {
+				cm.IncSSLPassthroughConnection("demo", "passthrough")
+				cm.IncSSLPassthroughConnection("demo", "passthrough")
+				cm.IncSSLPassthroughConnection("", "reject")
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_passthrough_connections_total Cumulative number of TLS connections handled by the SSL passthrough proxy not terminated by NGINX as one of its hosts.\n			'action' is 'passthrough', 'fallback', 'terminate' or 'reject' and 'host' is the passthrough host, empty for the connections with an unmatched SNI not passed through
+				# TYPE nginx_ingress_controller_ssl_passthrough_connections_total counter
+				nginx_ingress_controller_ssl_passthrough_connections_total{action="passthrough",controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo"} 2
+				nginx_ingress_controller_ssl_passthrough_connections_total{action="reject",controller_class="nginx",controller_namespace="default",controller_pod="pod",host=""} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_passthrough_connections_total"},
+		},
+		{
+			name: "should set the results of the last synthetic probes",
+			test: func(cm *Controller) {
+				cm.SetSyntheticProbeResults([]ingress.SyntheticProbeResult{
+					{Ingress: "ingress-namespace/stale", Host: "demo", Path: "/stale", Success: true, Duration: time.Second},
+				})
+				cm.SetSyntheticProbeResults([]ingress.SyntheticProbeResult{
+					{Ingress: "ingress-namespace/demo", Host: "demo", Path: "/", Success: true, Duration: 250 * time.Millisecond},
+					{Ingress: "ingress-namespace/demo", Host: "demo", Path: "/api", Duration: 2 * time.Second},
+				})
+			},
+			want: `
+				# HELP nginx_ingress_controller_synthetic_probe_duration_seconds Duration of the last synthetic probe of the host and path of an Ingress sent through NGINX
+				# TYPE nginx_ingress_controller_synthetic_probe_duration_seconds gauge
+				nginx_ingress_controller_synthetic_probe_duration_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="demo",namespace="ingress-namespace",path="/"} 0.25
+				nginx_ingress_controller_synthetic_probe_duration_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="demo",namespace="ingress-namespace",path="/api"} 2
+				# HELP nginx_ingress_controller_synthetic_probe_success Gauge reporting the result of the last synthetic probe of the host and path of an Ingress sent through NGINX, 1 indicates the probe returned an expected status
+				# TYPE nginx_ingress_controller_synthetic_probe_success gauge
+				nginx_ingress_controller_synthetic_probe_success{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="demo",namespace="ingress-namespace",path="/"} 1
+				nginx_ingress_controller_synthetic_probe_success{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",ingress="demo",namespace="ingress-namespace",path="/api"} 0
+			`,
+			metrics: []string{"nginx_ingress_controller_synthetic_probe_success", "nginx_ingress_controller_synthetic_probe_duration_seconds"},
+		},
+		{
+			name: "should set the endpoints warming up with slow start",
+			test: func(cm *Controller) {
+				service := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-namespace", Name: "demo"}}
+				backends := []*ingress.Backend{
+					{
+						Name:      "ingress-namespace-demo-80",
+						Service:   service,
+						SlowStart: slowstart.Config{Duration: 60, Aggression: 1},
+						Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+					},
+					{
+						Name:      "ingress-namespace-other-80",
+						Service:   &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-namespace", Name: "other"}},
+						Endpoints: []ingress.Endpoint{{Address: "10.0.1.1", Port: "8080"}},
+					},
+				}
+				// the endpoints of the first sync are warm
+				cm.SetSlowStartEndpoints(backends)
+				backends[0].Endpoints = append(backends[0].Endpoints,
+					ingress.Endpoint{Address: "10.0.0.2", Port: "8080"},
+					ingress.Endpoint{Address: "10.0.0.3", Port: "8080"})
+				backends[1].Endpoints = append(backends[1].Endpoints, ingress.Endpoint{Address: "10.0.1.2", Port: "8080"})
+				cm.SetSlowStartEndpoints(backends)
+			},
+			want: `
+				# HELP nginx_ingress_controller_slow_start_warming_endpoints Number of endpoints of the backends of a Service whose weight is still being ramped up by the slow start
+				# TYPE nginx_ingress_controller_slow_start_warming_endpoints gauge
+				nginx_ingress_controller_slow_start_warming_endpoints{controller_class="nginx",controller_namespace="default",controller_pod="pod",namespace="ingress-namespace",service="demo"} 2
+			`,
+			metrics: []string{"nginx_ingress_controller_slow_start_warming_endpoints"},
+		},
 		{
 			name: "should ignore servers without certificates",
 			test: func(cm *Controller) {
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status aggregations of the request metrics
+const (
+	// StatusCode reports the status code of the response, like 404
+	StatusCode = "code"
+	// StatusClass reports the class of the status code of the response, like 4xx
+	StatusClass = "class"
+)
+
+// MetricLabels selects the labels of the request metrics reported for the
+// requests of an Ingress. The labels that are not selected are reported empty,
+// aggregating the requests that only differ by them in the same series.
+type MetricLabels struct {
+	Host   bool
+	Path   bool
+	Method bool
+	// Status is StatusCode, StatusClass or empty to aggregate all the statuses
+	Status string
+}
+
+// ParseMetricLabels parses a comma separated list of the labels of the
+// request metrics: host, path, method, status or status-class.
+func ParseMetricLabels(value string) (MetricLabels, error) {
+	labels := MetricLabels{}
+	for _, label := range strings.Split(value, ",") {
+		label = strings.TrimSpace(label)
+
+		status := ""
+		switch label {
+		case "":
+		case "host":
+			labels.Host = true
+		case "path":
+			labels.Path = true
+		case "method":
+			labels.Method = true
+		case "status":
+			status = StatusCode
+		case "status-class":
+			status = StatusClass
+		default:
+			return MetricLabels{}, fmt.Errorf("unknown label %q, expected host, path, method, status or status-class", label)
+		}
+
+		if status != "" {
+			if labels.Status != "" && labels.Status != status {
+				return MetricLabels{}, fmt.Errorf("status and status-class can not be used together")
+			}
+			labels.Status = status
+		}
+	}
+
+	return labels, nil
+}
+
+// aggregate empties the labels of the request that are not selected
+func (l MetricLabels) aggregate(stats *socketData) {
+	if !l.Host {
+		stats.Host = ""
+	}
+	if !l.Path {
+		stats.Path = ""
+	}
+	if !l.Method {
+		stats.Method = ""
+	}
+
+	switch {
+	case l.Status == "":
+		stats.Status = ""
+	case l.Status == StatusClass && stats.Status != "":
+		stats.Status = fmt.Sprintf("%cxx", stats.Status[0])
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+)
+
+func TestParseMetricLabels(t *testing.T) {
+	testCases := []struct {
+		value     string
+		expected  MetricLabels
+		expectErr bool
+	}{
+		{"", MetricLabels{}, false},
+		{"host,path,method,status", MetricLabels{Host: true, Path: true, Method: true, Status: StatusCode}, false},
+		{" status-class , method ", MetricLabels{Method: true, Status: StatusClass}, false},
+		{"status,status", MetricLabels{Status: StatusCode}, false},
+		{"status,status-class", MetricLabels{}, true},
+		{"uri", MetricLabels{}, true},
+	}
+
+	for _, tc := range testCases {
+		labels, err := ParseMetricLabels(tc.value)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%q: expected error %t but got %v", tc.value, tc.expectErr, err)
+			continue
+		}
+		if labels != tc.expected {
+			t.Errorf("%q: expected %+v but got %+v", tc.value, tc.expected, labels)
+		}
+	}
+}
+
+func TestAggregateMetricLabels(t *testing.T) {
+	request := socketData{Host: "example.com", Status: "503", Method: "GET", Path: "/api"}
+
+	testCases := []struct {
+		labels   MetricLabels
+		expected socketData
+	}{
+		{MetricLabels{}, socketData{}},
+		{MetricLabels{Host: true, Path: true, Method: true, Status: StatusCode}, request},
+		{MetricLabels{Path: true, Status: StatusClass}, socketData{Status: "5xx", Path: "/api"}},
+	}
+
+	for _, tc := range testCases {
+		stats := request
+		tc.labels.aggregate(&stats)
+		if stats != tc.expected {
+			t.Errorf("%+v: expected %+v but got %+v", tc.labels, tc.expected, stats)
+		}
+	}
+}
@@ -38,7 +38,23 @@ type (
 	nginxStatusCollector struct {
 		scrapeChan chan scrapeRequest
 
-		data *nginxStatusData
+		data     *nginxStatusData
+		counters *nginxStatusCounters
+	}
+
+	// nginxStatusCounters keeps the counters of the NGINX status increasing
+	// when NGINX is restarted and its counters start again from zero
+	nginxStatusCounters struct {
+		accepted monotonicCounter
+		handled  monotonicCounter
+		requests monotonicCounter
+	}
+
+	// monotonicCounter adds the last value of a counter that went back
+	// to zero to the following values
+	monotonicCounter struct {
+		last   float64
+		offset float64
 	}
 
 	nginxStatusData struct {
@@ -77,6 +93,7 @@ type NGINXStatusCollector interface {
 func NewNGINXStatus(podName, namespace, ingressClass string) (NGINXStatusCollector, error) {
 	p := nginxStatusCollector{
 		scrapeChan: make(chan scrapeRequest),
+		counters:   &nginxStatusCounters{},
 	}
 
 	constLabels := prometheus.Labels{
@@ -131,6 +148,15 @@ func (p nginxStatusCollector) Stop() {
 	close(p.scrapeChan)
 }
 
+// value returns the value of the counter from the value reported by NGINX
+func (c *monotonicCounter) value(v float64) float64 {
+	if v < c.last {
+		c.offset += c.last
+	}
+	c.last = v
+	return c.offset + v
+}
+
 func toInt(data []string, pos int) int {
 	if len(data) == 0 {
 		return 0
@@ -179,12 +205,13 @@ func (p nginxStatusCollector) scrape(ch chan<- prometheus.Metric) {
 
 	s := parse(string(data))
 
+	// the scrapes are serialized by the scrape channel
 	ch <- prometheus.MustNewConstMetric(p.data.connectionsTotal,
-		prometheus.CounterValue, float64(s.Accepted), "accepted")
+		prometheus.CounterValue, p.counters.accepted.value(float64(s.Accepted)), "accepted")
 	ch <- prometheus.MustNewConstMetric(p.data.connectionsTotal,
-		prometheus.CounterValue, float64(s.Handled), "handled")
+		prometheus.CounterValue, p.counters.handled.value(float64(s.Handled)), "handled")
 	ch <- prometheus.MustNewConstMetric(p.data.requestsTotal,
-		prometheus.CounterValue, float64(s.Requests))
+		prometheus.CounterValue, p.counters.requests.value(float64(s.Requests)))
 	ch <- prometheus.MustNewConstMetric(p.data.connections,
 		prometheus.GaugeValue, float64(s.Active), "active")
 	ch <- prometheus.MustNewConstMetric(p.data.connections,
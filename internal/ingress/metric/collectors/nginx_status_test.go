@@ -151,6 +151,28 @@ func TestStatusCollector(t *testing.T) {
 	}
 }
 
+func TestMonotonicCounter(t *testing.T) {
+	c := &monotonicCounter{}
+
+	// NGINX restarted after 15 and 4 requests
+	for _, step := range []struct {
+		reported float64
+		expected float64
+	}{
+		{10, 10},
+		{15, 15},
+		{15, 15},
+		{3, 18},
+		{4, 19},
+		{0, 19},
+		{2, 21},
+	} {
+		if v := c.value(step.reported); v != step.expected {
+			t.Errorf("expected %v for the reported value %v but returned %v", step.expected, step.reported, v)
+		}
+	}
+}
+
 func tryListen(network, address string) (l net.Listener, err error) {
 	condFunc := func() (bool, error) {
 		l, err = net.Listen(network, address)
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"sync"
+	"time"
+)
+
+// requestSampleTTL is the time after which a sample is not returned
+// anymore, the route it used is likely to be gone when it is not used
+const requestSampleTTL = time.Hour
+
+// RequestSample is a request served by an Ingress
+type RequestSample struct {
+	Method string
+	Host   string
+	Path   string
+
+	seen time.Time
+}
+
+type requestSampleKey struct {
+	method string
+	host   string
+	path   string
+}
+
+// RequestSampler keeps the most recent distinct requests served by Ingresses
+type RequestSampler struct {
+	lock sync.Mutex
+
+	samples []RequestSample
+	keys    map[requestSampleKey]int
+	next    int
+
+	now func() time.Time
+}
+
+// NewRequestSampler returns a RequestSampler keeping up to size requests
+func NewRequestSampler(size int) *RequestSampler {
+	return &RequestSampler{
+		samples: make([]RequestSample, 0, size),
+		keys:    make(map[requestSampleKey]int, size),
+		now:     time.Now,
+	}
+}
+
+// Add records a request, replacing the first recorded one when the sampler is full
+func (rs *RequestSampler) Add(sample RequestSample) {
+	if cap(rs.samples) == 0 {
+		return
+	}
+
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	sample.seen = rs.now()
+	key := requestSampleKey{method: sample.Method, host: sample.Host, path: sample.Path}
+	if i, ok := rs.keys[key]; ok {
+		rs.samples[i] = sample
+		return
+	}
+
+	if len(rs.samples) < cap(rs.samples) {
+		rs.keys[key] = len(rs.samples)
+		rs.samples = append(rs.samples, sample)
+		return
+	}
+
+	old := rs.samples[rs.next]
+	delete(rs.keys, requestSampleKey{method: old.Method, host: old.Host, path: old.Path})
+	rs.samples[rs.next] = sample
+	rs.keys[key] = rs.next
+	rs.next = (rs.next + 1) % len(rs.samples)
+}
+
+// Samples returns the requests recorded during the last hour
+func (rs *RequestSampler) Samples() []RequestSample {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	samples := make([]RequestSample, 0, len(rs.samples))
+	for i := range rs.samples {
+		if rs.now().Sub(rs.samples[i].seen) < requestSampleTTL {
+			samples = append(samples, rs.samples[i])
+		}
+	}
+	return samples
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func samplePaths(samples []RequestSample) []string {
+	paths := []string{}
+	for i := range samples {
+		paths = append(paths, samples[i].Path)
+	}
+	return paths
+}
+
+func TestRequestSampler(t *testing.T) {
+	now := time.Now()
+	rs := NewRequestSampler(2)
+	rs.now = func() time.Time { return now }
+
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/a"})
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/a"})
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/b"})
+	if paths := samplePaths(rs.Samples()); !reflect.DeepEqual(paths, []string{"/a", "/b"}) {
+		t.Errorf("expected distinct samples /a and /b but got %v", paths)
+	}
+
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/c"})
+	if paths := samplePaths(rs.Samples()); !reflect.DeepEqual(paths, []string{"/c", "/b"}) {
+		t.Errorf("expected /a to be replaced by /c but got %v", paths)
+	}
+
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/a"})
+	if paths := samplePaths(rs.Samples()); !reflect.DeepEqual(paths, []string{"/c", "/a"}) {
+		t.Errorf("expected /b to be replaced by /a but got %v", paths)
+	}
+
+	now = now.Add(requestSampleTTL)
+	if samples := rs.Samples(); len(samples) != 0 {
+		t.Errorf("expected expired samples to be ignored but got %v", samples)
+	}
+}
+
+func TestRequestSamplerWithoutSize(t *testing.T) {
+	rs := NewRequestSampler(0)
+	rs.Add(RequestSample{Method: "GET", Host: "foo.bar", Path: "/a"})
+	if samples := rs.Samples(); len(samples) != 0 {
+		t.Errorf("expected no samples but got %v", samples)
+	}
+}
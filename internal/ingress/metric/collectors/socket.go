@@ -19,9 +19,12 @@ package collectors
 import (
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	jsoniter "github.com/json-iterator/go"
@@ -49,6 +52,37 @@ type socketData struct {
 	Service      string  `json:"service"`
 	Canary       string  `json:"canary"`
 	Path         string  `json:"path"`
+	URI          string  `json:"uri"`
+
+	// Retries is the number of tries that retried the request
+	Retries float64 `json:"upstreamRetries"`
+	// RetryBudgetExhausted is true when the retry budget of the upstream
+	// did not allow to retry the request
+	RetryBudgetExhausted bool `json:"retryBudgetExhausted"`
+	// UpstreamAddr and UpstreamStatus are the endpoints tried for the
+	// request and the status codes they returned, separated by commas
+	UpstreamAddr   string `json:"upstreamAddr"`
+	UpstreamStatus string `json:"upstreamStatus"`
+	// CacheStatus is the status of the response in the cache zone of the
+	// Ingress, like HIT or MISS, "-" when the location does not cache
+	CacheStatus string `json:"upstreamCacheStatus"`
+	// BotMitigation is challenged or blocked when the request was stopped
+	// by the bot mitigation of the Ingress, "-" otherwise
+	BotMitigation string `json:"botMitigation"`
+
+	// SSLServerName, SSLProtocol, SSLCipher and SSLClientVerify describe
+	// the TLS connection, they are only set for its first request
+	SSLServerName   string `json:"sslServerName"`
+	SSLProtocol     string `json:"sslProtocol"`
+	SSLCipher       string `json:"sslCipher"`
+	SSLClientVerify string `json:"sslClientVerify"`
+
+	// RejectedProtocol is set instead of the request details when
+	// the client sent a protocol other than HTTP
+	RejectedProtocol string `json:"rejectedProtocol"`
+	// TLSHandshake is set instead of the request details when a client
+	// started a TLS handshake, to the server name it requested or "-"
+	TLSHandshake string `json:"tlsHandshake"`
 }
 
 // HistogramBuckets allow customizing prometheus histogram buckets values
@@ -75,17 +109,59 @@ type SocketCollector struct {
 
 	requests *prometheus.CounterVec
 
+	upstreamRetries              *prometheus.CounterVec
+	upstreamRetryBudgetExhausted *prometheus.CounterVec
+
+	upstreamAttempts         *prometheus.HistogramVec
+	upstreamEndpointAttempts *prometheus.CounterVec
+	upstreamNextUpstream     *prometheus.CounterVec
+	attemptEndpoints         attemptEndpoints
+
+	cacheRequests *prometheus.CounterVec
+
+	botMitigationRequests *prometheus.CounterVec
+
+	upstreamConnections *prometheus.CounterVec
+
+	rejectedProtocols *prometheus.CounterVec
+
+	tlsHandshakes           *prometheus.CounterVec
+	tlsHandshakeFailures    *prometheus.CounterVec
+	tlsConnections          *prometheus.CounterVec
+	tlsClientVerifyFailures *prometheus.CounterVec
+	connectionResets        *prometheus.CounterVec
+
+	ingressRequestBytes  *prometheus.CounterVec
+	ingressResponseBytes *prometheus.CounterVec
+
+	samples *RequestSampler
+	usage   *UsageAggregator
+
 	listener net.Listener
+	// errorLog receives the error log of NGINX
+	errorLog net.PacketConn
 
 	metricMapping metricMapping
 
 	hosts sets.Set[string]
 
+	// metricLabels contains the labels selected for the requests of the
+	// Ingresses, by namespace/name
+	metricLabels   map[string]MetricLabels
+	metricLabelsMu sync.RWMutex
+
 	metricsPerHost          bool
 	metricsPerUndefinedHost bool
 	reportStatusClasses     bool
 }
 
+var upstreamTags = []string{
+	"namespace",
+	"ingress",
+	"service",
+	"canary",
+}
+
 var requestTags = []string{
 	"status",
 
@@ -116,6 +192,12 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost, metricsPer
 		return nil, err
 	}
 
+	errorLog, err := listenConnectionErrors()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
 	constLabels := prometheus.Labels{
 		"controller_namespace": namespace,
 		"controller_class":     class,
@@ -139,11 +221,14 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost, metricsPer
 
 	sc := &SocketCollector{
 		listener: listener,
+		errorLog: errorLog,
 
 		metricsPerHost:          metricsPerHost,
 		metricsPerUndefinedHost: metricsPerUndefinedHost,
 		reportStatusClasses:     reportStatusClasses,
 
+		usage: NewUsageAggregator(),
+
 		connectTime: histogramMetric(
 			&prometheus.HistogramOpts{
 				Name:                           "connect_duration_seconds",
@@ -257,6 +342,199 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost, metricsPer
 			em,
 			mm,
 		),
+
+		upstreamRetries: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "upstream_retries_total",
+				Help:        "The total number of tries that retried a request to the upstream",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			upstreamTags,
+			em,
+			mm,
+		),
+
+		upstreamRetryBudgetExhausted: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "upstream_retry_budget_exhausted_total",
+				Help:        "The total number of requests that could not be retried because the retry budget of the upstream was exhausted",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			upstreamTags,
+			em,
+			mm,
+		),
+
+		upstreamAttempts: histogramMetric(
+			&prometheus.HistogramOpts{
+				Name:        "upstream_attempts",
+				Help:        "The number of tries of the requests to the endpoints of the upstream",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+				Buckets:     prometheus.LinearBuckets(1, 1, 5),
+			},
+			upstreamTags,
+			em,
+			mm,
+		),
+
+		upstreamEndpointAttempts: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "upstream_endpoint_attempts_total",
+				Help:        "The total number of tries of the requests to the upstream, by endpoint and status code returned",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append([]string{"endpoint", "status"}, upstreamTags...),
+			em,
+			mm,
+		),
+
+		upstreamNextUpstream: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "upstream_next_upstream_total",
+				Help:        "The total number of tries of the requests passed to the next endpoint of the upstream, by status code returned",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append([]string{"reason"}, upstreamTags...),
+			em,
+			mm,
+		),
+
+		cacheRequests: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "cache_requests_total",
+				Help:        "The total number of requests to locations caching the responses of the upstream, by cache status",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append([]string{"cache_status"}, upstreamTags...),
+			em,
+			mm,
+		),
+
+		botMitigationRequests: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "bot_mitigation_requests_total",
+				Help:        "The total number of requests challenged or blocked by the bot mitigation of the Ingress, by action",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append([]string{"action"}, upstreamTags...),
+			em,
+			mm,
+		),
+
+		upstreamConnections: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "upstream_connections_total",
+				Help:        "The total number of requests sent to the upstream, by reuse of a keepalive connection",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append([]string{"reused"}, upstreamTags...),
+			em,
+			mm,
+		),
+
+		rejectedProtocols: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "rejected_protocols_total",
+				Help:        "The total number of connections closed because the client sent a protocol other than HTTP",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"protocol"},
+			em,
+			mm,
+		),
+
+		tlsHandshakes: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "tls_handshakes_total",
+				Help:        "The total number of TLS handshakes started by the clients, by server name",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"server_name"},
+			em,
+			mm,
+		),
+
+		tlsHandshakeFailures: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "tls_handshake_failures_total",
+				Help:        "The total number of TLS handshakes that failed, by reason",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"reason"},
+			em,
+			mm,
+		),
+
+		tlsConnections: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "tls_connections_total",
+				Help:        "The total number of TLS connections that completed the handshake and sent a request, by server name, protocol and cipher",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"server_name", "protocol", "cipher"},
+			em,
+			mm,
+		),
+
+		tlsClientVerifyFailures: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "tls_client_verify_failures_total",
+				Help:        "The total number of TLS connections whose client certificate failed the verification, by server name and reason",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"server_name", "reason"},
+			em,
+			mm,
+		),
+
+		connectionResets: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "connection_resets_total",
+				Help:        "The total number of connections reset by the clients, by server name",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"server_name"},
+			em,
+			mm,
+		),
+
+		ingressRequestBytes: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "ingress_request_bytes_total",
+				Help:        "The total size of the requests of the Ingress, including their line and headers",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "ingress"},
+			em,
+			mm,
+		),
+
+		ingressResponseBytes: counterMetric(
+			&prometheus.CounterOpts{
+				Name:        "ingress_response_bytes_total",
+				Help:        "The total number of bytes sent to the clients of the Ingress",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "ingress"},
+			em,
+			mm,
+		),
 	}
 
 	sc.metricMapping = mm
@@ -308,12 +586,40 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 
 	for i := range statsBatch {
 		stats := &statsBatch[i]
+		if stats.RejectedProtocol != "" {
+			if sc.rejectedProtocols != nil {
+				sc.rejectedProtocols.WithLabelValues(stats.RejectedProtocol).Inc()
+			}
+			continue
+		}
+
+		if stats.TLSHandshake != "" {
+			if sc.tlsHandshakes != nil {
+				sc.tlsHandshakes.WithLabelValues(sc.serverNameLabel(stats.TLSHandshake)).Inc()
+			}
+			continue
+		}
+
+		sc.observeConnection(stats)
+
+		if sc.samples != nil && stats.URI != "" && stats.Ingress != "" && stats.Ingress != "-" {
+			sc.samples.Add(RequestSample{
+				Method: stats.Method,
+				Host:   stats.Host,
+				Path:   stats.URI,
+			})
+		}
+
+		sc.observeUsage(stats)
+
 		if sc.metricsPerHost && !sc.hosts.Has(stats.Host) && !sc.metricsPerUndefinedHost {
 			klog.V(3).InfoS("Skipping metric for host not explicitly defined in an ingress", "host", stats.Host)
 			continue
 		}
 
-		if sc.reportStatusClasses && stats.Status != "" {
+		if labels, ok := sc.ingressMetricLabels(stats.Namespace, stats.Ingress); ok {
+			labels.aggregate(stats)
+		} else if sc.reportStatusClasses && stats.Status != "" {
 			stats.Status = fmt.Sprintf("%cxx", stats.Status[0])
 		}
 
@@ -351,6 +657,74 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			}
 		}
 
+		upstreamLabels := prometheus.Labels{
+			"namespace": stats.Namespace,
+			"ingress":   stats.Ingress,
+			"service":   stats.Service,
+			"canary":    stats.Canary,
+		}
+
+		if stats.Retries > 0 && sc.upstreamRetries != nil {
+			retriesMetric, err := sc.upstreamRetries.GetMetricWith(upstreamLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching upstream retries metric")
+			} else {
+				retriesMetric.Add(stats.Retries)
+			}
+		}
+
+		if stats.RetryBudgetExhausted && sc.upstreamRetryBudgetExhausted != nil {
+			budgetMetric, err := sc.upstreamRetryBudgetExhausted.GetMetricWith(upstreamLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching upstream retry budget metric")
+			} else {
+				budgetMetric.Inc()
+			}
+		}
+
+		sc.observeUpstreamAttempts(stats, upstreamLabels)
+
+		if stats.CacheStatus != "" && stats.CacheStatus != "-" && sc.cacheRequests != nil {
+			cacheLabels := prometheus.Labels{"cache_status": strings.ToLower(stats.CacheStatus)}
+			for k, v := range upstreamLabels {
+				cacheLabels[k] = v
+			}
+			cacheMetric, err := sc.cacheRequests.GetMetricWith(cacheLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching cache requests metric")
+			} else {
+				cacheMetric.Inc()
+			}
+		}
+
+		if stats.BotMitigation != "" && stats.BotMitigation != "-" && sc.botMitigationRequests != nil {
+			botLabels := prometheus.Labels{"action": stats.BotMitigation}
+			for k, v := range upstreamLabels {
+				botLabels[k] = v
+			}
+			botMetric, err := sc.botMitigationRequests.GetMetricWith(botLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching bot mitigation requests metric")
+			} else {
+				botMetric.Inc()
+			}
+		}
+
+		if stats.Latency != -1 && sc.upstreamConnections != nil {
+			// NGINX reports a connect time of zero for the
+			// connections taken from the keepalive cache
+			connectionLabels := prometheus.Labels{"reused": strconv.FormatBool(stats.Latency == 0)}
+			for k, v := range upstreamLabels {
+				connectionLabels[k] = v
+			}
+			connectionsMetric, err := sc.upstreamConnections.GetMetricWith(connectionLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching upstream connections metric")
+			} else {
+				connectionsMetric.Inc()
+			}
+		}
+
 		if stats.Latency != -1 {
 			if sc.connectTime != nil {
 				connectTimeMetric, err := sc.connectTime.GetMetricWith(requestLabels)
@@ -420,8 +794,110 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 	}
 }
 
+// observeUpstreamAttempts reports the tries of a request to the endpoints of the upstream
+func (sc *SocketCollector) observeUpstreamAttempts(stats *socketData, upstreamLabels prometheus.Labels) {
+	attempts := upstreamAttempts(stats.UpstreamAddr, stats.UpstreamStatus)
+	if len(attempts) == 0 {
+		return
+	}
+
+	if sc.upstreamAttempts != nil {
+		attemptsMetric, err := sc.upstreamAttempts.GetMetricWith(upstreamLabels)
+		if err != nil {
+			klog.ErrorS(err, "Error fetching upstream attempts metric")
+		} else {
+			attemptsMetric.Observe(float64(len(attempts)))
+		}
+	}
+
+	for i, attempt := range attempts {
+		if sc.upstreamEndpointAttempts != nil {
+			endpointLabels := prometheus.Labels{
+				"endpoint": sc.attemptEndpoints.label(stats.Namespace+"/"+stats.Ingress, attempt.Endpoint),
+				"status":   attempt.Status,
+			}
+			for k, v := range upstreamLabels {
+				endpointLabels[k] = v
+			}
+			endpointMetric, err := sc.upstreamEndpointAttempts.GetMetricWith(endpointLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching upstream endpoint attempts metric")
+			} else {
+				endpointMetric.Inc()
+			}
+		}
+
+		// every try but the last one was passed to the next endpoint
+		if i < len(attempts)-1 && sc.upstreamNextUpstream != nil {
+			nextLabels := prometheus.Labels{"reason": attempt.Status}
+			for k, v := range upstreamLabels {
+				nextLabels[k] = v
+			}
+			nextMetric, err := sc.upstreamNextUpstream.GetMetricWith(nextLabels)
+			if err != nil {
+				klog.ErrorS(err, "Error fetching upstream next upstream metric")
+			} else {
+				nextMetric.Inc()
+			}
+		}
+	}
+}
+
+// observeConnection reports the TLS details of the first request of a
+// connection. They are reported for every server name, the hosts not served
+// by an Ingress using the "-" label.
+func (sc *SocketCollector) observeConnection(stats *socketData) {
+	if stats.SSLProtocol != "" && stats.SSLProtocol != "-" {
+		serverName := sc.serverNameLabel(stats.SSLServerName)
+
+		if sc.tlsConnections != nil {
+			sc.tlsConnections.WithLabelValues(serverName, stats.SSLProtocol, stats.SSLCipher).Inc()
+		}
+
+		// NGINX reports the failed verifications as "FAILED:reason"
+		if reason, failed := strings.CutPrefix(stats.SSLClientVerify, "FAILED:"); failed && sc.tlsClientVerifyFailures != nil {
+			sc.tlsClientVerifyFailures.WithLabelValues(serverName, reason).Inc()
+		}
+	}
+}
+
+// serverNameLabel returns the label of a server name sent by a client,
+// "-" when it is not a host of an Ingress and the metrics of the undefined
+// hosts are not exported, to bound the cardinality of the metrics
+// observeUsage counts the bytes received and sent by the Ingress of the request
+func (sc *SocketCollector) observeUsage(stats *socketData) {
+	if stats.Ingress == "" || stats.Ingress == "-" {
+		return
+	}
+
+	// the lengths are -1 when NGINX did not set them
+	requestBytes := math.Max(stats.RequestLength, 0)
+	responseBytes := math.Max(stats.ResponseLength, 0)
+
+	sc.usage.Add(stats.Namespace, stats.Ingress, requestBytes, responseBytes)
+
+	if sc.ingressRequestBytes != nil {
+		sc.ingressRequestBytes.WithLabelValues(stats.Namespace, stats.Ingress).Add(requestBytes)
+	}
+	if sc.ingressResponseBytes != nil {
+		sc.ingressResponseBytes.WithLabelValues(stats.Namespace, stats.Ingress).Add(responseBytes)
+	}
+}
+
+func (sc *SocketCollector) serverNameLabel(serverName string) string {
+	if serverName == "" || !sc.metricsPerHost {
+		return "-"
+	}
+	if !sc.hosts.Has(serverName) && !sc.metricsPerUndefinedHost {
+		return "-"
+	}
+	return serverName
+}
+
 // Start listen for connections in the unix socket and spawns a goroutine to process the content
 func (sc *SocketCollector) Start() {
+	go sc.readConnectionErrors()
+
 	for {
 		conn, err := sc.listener.Accept()
 		if err != nil {
@@ -435,6 +911,7 @@ func (sc *SocketCollector) Start() {
 // Stop stops unix listener
 func (sc *SocketCollector) Stop() {
 	sc.listener.Close()
+	sc.errorLog.Close()
 }
 
 // RemoveMetrics deletes prometheus metrics from prometheus for ingresses and
@@ -447,6 +924,8 @@ func (sc *SocketCollector) RemoveMetrics(ingresses []string, registry prometheus
 		return
 	}
 
+	sc.attemptEndpoints.forget(ingresses)
+
 	// 1. remove metrics of removed ingresses
 	klog.V(2).InfoS("removing metrics", "ingresses", ingresses)
 	for _, mf := range mfs {
@@ -527,6 +1006,43 @@ func (sc *SocketCollector) SetHosts(hosts sets.Set[string]) {
 	sc.hosts = hosts
 }
 
+// SetMetricLabels sets the labels of the request metrics reported for the
+// Ingresses, by namespace/name. The requests of the other Ingresses are
+// reported with all the labels.
+func (sc *SocketCollector) SetMetricLabels(labels map[string]MetricLabels) {
+	sc.metricLabelsMu.Lock()
+	defer sc.metricLabelsMu.Unlock()
+
+	sc.metricLabels = labels
+}
+
+func (sc *SocketCollector) ingressMetricLabels(namespace, name string) (MetricLabels, bool) {
+	sc.metricLabelsMu.RLock()
+	defer sc.metricLabelsMu.RUnlock()
+
+	labels, ok := sc.metricLabels[namespace+"/"+name]
+	return labels, ok
+}
+
+// EnableRequestSampling records the last size distinct requests served
+// by Ingresses. It must be called before Start.
+func (sc *SocketCollector) EnableRequestSampling(size int) {
+	sc.samples = NewRequestSampler(size)
+}
+
+// RequestSamples returns the requests recorded by the collector
+func (sc *SocketCollector) RequestSamples() []RequestSample {
+	if sc.samples == nil {
+		return nil
+	}
+	return sc.samples.Samples()
+}
+
+// IngressUsage returns the bytes received and sent by every Ingress by hour
+func (sc *SocketCollector) IngressUsage() []IngressUsage {
+	return sc.usage.Usage()
+}
+
 // handleMessages process the content received in a network connection
 func handleMessages(conn io.ReadCloser, fn func([]byte)) {
 	defer conn.Close()
@@ -93,6 +93,7 @@ func TestCollector(t *testing.T) {
 		metricsPerUndefinedHost bool
 		useStatusClasses        bool
 		excludeMetrics          []string
+		metricLabels            map[string]MetricLabels
 		wantBefore              string
 		removeIngresses         []string
 		wantAfter               string
@@ -623,6 +624,330 @@ func TestCollector(t *testing.T) {
 				nginx_ingress_controller_requests{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",host="wildcard.testshop.com",ingress="web-yml",method="GET",namespace="test-app-production",path="/admin",service="test-app",status="2xx"} 1
 			`,
 		},
+		{
+			name: "retried requests should update the upstream retry metrics",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamRetries":2,
+				"retryBudgetExhausted":true
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamRetries":0
+			}]`},
+			metrics:                 []string{"nginx_ingress_controller_upstream_retries_total", "nginx_ingress_controller_upstream_retry_budget_exhausted_total"},
+			metricsPerUndefinedHost: true,
+			wantBefore: `
+				# HELP nginx_ingress_controller_upstream_retries_total The total number of tries that retried a request to the upstream
+				# TYPE nginx_ingress_controller_upstream_retries_total counter
+				nginx_ingress_controller_upstream_retries_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 2
+				# HELP nginx_ingress_controller_upstream_retry_budget_exhausted_total The total number of requests that could not be retried because the retry budget of the upstream was exhausted
+				# TYPE nginx_ingress_controller_upstream_retry_budget_exhausted_total counter
+				nginx_ingress_controller_upstream_retry_budget_exhausted_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 1
+			`,
+		},
+		{
+			name: "upstream tries should update the upstream attempts metrics",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamAddr":"10.0.0.1:8080, 10.0.0.2:8080",
+				"upstreamStatus":"502, 200"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamAddr":"-",
+				"upstreamStatus":"-"
+			}]`},
+			metrics:                 []string{"nginx_ingress_controller_upstream_endpoint_attempts_total", "nginx_ingress_controller_upstream_next_upstream_total"},
+			metricsPerUndefinedHost: true,
+			wantBefore: `
+				# HELP nginx_ingress_controller_upstream_endpoint_attempts_total The total number of tries of the requests to the upstream, by endpoint and status code returned
+				# TYPE nginx_ingress_controller_upstream_endpoint_attempts_total counter
+				nginx_ingress_controller_upstream_endpoint_attempts_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.1:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="502"} 1
+				nginx_ingress_controller_upstream_endpoint_attempts_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",endpoint="10.0.0.2:8080",ingress="web-yml",namespace="test-app-production",service="test-app",status="200"} 1
+				# HELP nginx_ingress_controller_upstream_next_upstream_total The total number of tries of the requests passed to the next endpoint of the upstream, by status code returned
+				# TYPE nginx_ingress_controller_upstream_next_upstream_total counter
+				nginx_ingress_controller_upstream_next_upstream_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",reason="502",service="test-app"} 1
+			`,
+		},
+		{
+			name: "cached requests should update the cache requests metric",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamCacheStatus":"HIT"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamCacheStatus":"MISS"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamCacheStatus":"HIT"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamCacheStatus":"-"
+			}]`},
+			metrics:                 []string{"nginx_ingress_controller_cache_requests_total"},
+			metricsPerUndefinedHost: true,
+			wantBefore: `
+				# HELP nginx_ingress_controller_cache_requests_total The total number of requests to locations caching the responses of the upstream, by cache status
+				# TYPE nginx_ingress_controller_cache_requests_total counter
+				nginx_ingress_controller_cache_requests_total{cache_status="hit",canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 2
+				nginx_ingress_controller_cache_requests_total{cache_status="miss",canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 1
+			`,
+		},
+		{
+			name: "challenged and blocked requests should update the bot mitigation metric",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"307",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"botMitigation":"challenged"
+			},{
+				"host":"testshop.com",
+				"status":"403",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"botMitigation":"blocked"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"botMitigation":"-"
+			}]`},
+			metrics:                 []string{"nginx_ingress_controller_bot_mitigation_requests_total"},
+			metricsPerUndefinedHost: true,
+			wantBefore: `
+				# HELP nginx_ingress_controller_bot_mitigation_requests_total The total number of requests challenged or blocked by the bot mitigation of the Ingress, by action
+				# TYPE nginx_ingress_controller_bot_mitigation_requests_total counter
+				nginx_ingress_controller_bot_mitigation_requests_total{action="blocked",canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 1
+				nginx_ingress_controller_bot_mitigation_requests_total{action="challenged",canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",service="test-app"} 1
+			`,
+		},
+		{
+			name: "connect time should update the upstream connections metric",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamLatency":0
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamLatency":0.002
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamLatency":0
+			},{
+				"host":"testshop.com",
+				"status":"502",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"upstreamLatency":-1
+			}]`},
+			metrics:                 []string{"nginx_ingress_controller_upstream_connections_total"},
+			metricsPerUndefinedHost: true,
+			wantBefore: `
+				# HELP nginx_ingress_controller_upstream_connections_total The total number of requests sent to the upstream, by reuse of a keepalive connection
+				# TYPE nginx_ingress_controller_upstream_connections_total counter
+				nginx_ingress_controller_upstream_connections_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",reused="false",service="test-app"} 1
+				nginx_ingress_controller_upstream_connections_total{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production",reused="true",service="test-app"} 2
+			`,
+		},
+		{
+			name: "rejected protocols should only update the rejected protocols metric",
+			data: []string{`[{
+				"rejectedProtocol":"tls"
+			},{
+				"rejectedProtocol":"ssh"
+			},{
+				"rejectedProtocol":"tls"
+			}]`},
+			metrics: []string{"nginx_ingress_controller_rejected_protocols_total", "nginx_ingress_controller_requests"},
+			wantBefore: `
+				# HELP nginx_ingress_controller_rejected_protocols_total The total number of connections closed because the client sent a protocol other than HTTP
+				# TYPE nginx_ingress_controller_rejected_protocols_total counter
+				nginx_ingress_controller_rejected_protocols_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",protocol="ssh"} 1
+				nginx_ingress_controller_rejected_protocols_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",protocol="tls"} 2
+			`,
+		},
+		{
+			name: "tls handshakes and connections should be reported by server name",
+			data: []string{`[{
+				"tlsHandshake":"testshop.com"
+			},{
+				"tlsHandshake":"unknown.com"
+			},{
+				"tlsHandshake":"testshop.com"
+			},{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/",
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":"",
+				"sslServerName":"testshop.com",
+				"sslProtocol":"TLSv1.3",
+				"sslCipher":"TLS_AES_128_GCM_SHA256",
+				"sslClientVerify":"FAILED:certificate has expired"
+			}]`},
+			metrics: []string{
+				"nginx_ingress_controller_tls_handshakes_total",
+				"nginx_ingress_controller_tls_connections_total",
+				"nginx_ingress_controller_tls_client_verify_failures_total",
+			},
+			wantBefore: `
+				# HELP nginx_ingress_controller_tls_client_verify_failures_total The total number of TLS connections whose client certificate failed the verification, by server name and reason
+				# TYPE nginx_ingress_controller_tls_client_verify_failures_total counter
+				nginx_ingress_controller_tls_client_verify_failures_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",reason="certificate has expired",server_name="testshop.com"} 1
+				# HELP nginx_ingress_controller_tls_connections_total The total number of TLS connections that completed the handshake and sent a request, by server name, protocol and cipher
+				# TYPE nginx_ingress_controller_tls_connections_total counter
+				nginx_ingress_controller_tls_connections_total{cipher="TLS_AES_128_GCM_SHA256",controller_class="ingress",controller_namespace="default",controller_pod="pod",protocol="TLSv1.3",server_name="testshop.com"} 1
+				# HELP nginx_ingress_controller_tls_handshakes_total The total number of TLS handshakes started by the clients, by server name
+				# TYPE nginx_ingress_controller_tls_handshakes_total counter
+				nginx_ingress_controller_tls_handshakes_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",server_name="-"} 1
+				nginx_ingress_controller_tls_handshakes_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",server_name="testshop.com"} 2
+			`,
+		},
+		{
+			name: "bytes should be counted by Ingress only",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/",
+				"requestLength":300.0,
+				"responseLength":1500.0,
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":""
+			},{
+				"host":"testshop.com",
+				"status":"404",
+				"method":"POST",
+				"path":"/admin",
+				"requestLength":700.0,
+				"responseLength":-1,
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":""
+			},{
+				"host":"unknown.com",
+				"status":"404",
+				"method":"GET",
+				"path":"/",
+				"requestLength":100.0,
+				"responseLength":200.0,
+				"namespace":"",
+				"ingress":"-",
+				"service":"",
+				"canary":""
+			}]`},
+			metrics: []string{
+				"nginx_ingress_controller_ingress_request_bytes_total",
+				"nginx_ingress_controller_ingress_response_bytes_total",
+			},
+			wantBefore: `
+				# HELP nginx_ingress_controller_ingress_request_bytes_total The total size of the requests of the Ingress, including their line and headers
+				# TYPE nginx_ingress_controller_ingress_request_bytes_total counter
+				nginx_ingress_controller_ingress_request_bytes_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production"} 1000
+				# HELP nginx_ingress_controller_ingress_response_bytes_total The total number of bytes sent to the clients of the Ingress
+				# TYPE nginx_ingress_controller_ingress_response_bytes_total counter
+				nginx_ingress_controller_ingress_response_bytes_total{controller_class="ingress",controller_namespace="default",controller_pod="pod",ingress="web-yml",namespace="test-app-production"} 1500
+			`,
+		},
 		{
 			name: "metrics with a host should be dropped when the host is not in the hosts slice",
 			data: []string{`[{
@@ -648,6 +973,65 @@ func TestCollector(t *testing.T) {
 			metrics:          []string{"nginx_ingress_controller_requests"},
 			useStatusClasses: true,
 		},
+		{
+			name: "aggregate the labels not selected for an ingress",
+			data: []string{`[{
+				"host":"testshop.com",
+				"status":"200",
+				"method":"GET",
+				"path":"/admin",
+				"requestLength":-1,
+				"requestTime":-1,
+				"upstreamLatency":-1,
+				"upstreamHeaderTime":-1,
+				"upstreamResponseTime":-1,
+				"responseLength":-1,
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":""
+			},{
+				"host":"testshop.com",
+				"status":"201",
+				"method":"POST",
+				"path":"/login",
+				"requestLength":-1,
+				"requestTime":-1,
+				"upstreamLatency":-1,
+				"upstreamHeaderTime":-1,
+				"upstreamResponseTime":-1,
+				"responseLength":-1,
+				"namespace":"test-app-production",
+				"ingress":"web-yml",
+				"service":"test-app",
+				"canary":""
+			},{
+				"host":"testshop.com",
+				"status":"404",
+				"method":"GET",
+				"path":"/",
+				"requestLength":-1,
+				"requestTime":-1,
+				"upstreamLatency":-1,
+				"upstreamHeaderTime":-1,
+				"upstreamResponseTime":-1,
+				"responseLength":-1,
+				"namespace":"test-app-production",
+				"ingress":"web-other",
+				"service":"test-app",
+				"canary":""
+			}]`},
+			metrics: []string{"nginx_ingress_controller_requests"},
+			metricLabels: map[string]MetricLabels{
+				"test-app-production/web-yml": {Status: StatusClass},
+			},
+			wantBefore: `
+				# HELP nginx_ingress_controller_requests The total number of client requests
+				# TYPE nginx_ingress_controller_requests counter
+				nginx_ingress_controller_requests{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",host="",ingress="web-yml",method="",namespace="test-app-production",path="",service="test-app",status="2xx"} 2
+				nginx_ingress_controller_requests{canary="",controller_class="ingress",controller_namespace="default",controller_pod="pod",host="testshop.com",ingress="web-other",method="GET",namespace="test-app-production",path="/",service="test-app",status="404"} 1
+			`,
+		},
 	}
 
 	for _, c := range cases {
@@ -664,6 +1048,7 @@ func TestCollector(t *testing.T) {
 			}
 
 			sc.SetHosts(sets.New[string]("testshop.com"))
+			sc.SetMetricLabels(c.metricLabels)
 
 			for _, d := range c.data {
 				sc.handleMessage([]byte(d))
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageRetention is the number of hours the usage of the Ingresses is kept
+const usageRetention = 24
+
+// IngressUsage is the number of bytes received and sent by an Ingress
+// during an hour
+type IngressUsage struct {
+	Namespace string    `json:"namespace"`
+	Ingress   string    `json:"ingress"`
+	Hour      time.Time `json:"hour"`
+	Requests  uint64    `json:"requests"`
+	// RequestBytes is the size of the requests, including their line and headers
+	RequestBytes uint64 `json:"requestBytes"`
+	// ResponseBytes is the number of bytes sent to the clients
+	ResponseBytes uint64 `json:"responseBytes"`
+}
+
+type usageKey struct {
+	namespace string
+	ingress   string
+	hour      time.Time
+}
+
+// UsageAggregator sums the bytes received and sent by every Ingress by hour
+type UsageAggregator struct {
+	lock sync.Mutex
+
+	usage map[usageKey]*IngressUsage
+
+	now func() time.Time
+}
+
+// NewUsageAggregator returns a UsageAggregator keeping the usage of the last
+// usageRetention hours
+func NewUsageAggregator() *UsageAggregator {
+	return &UsageAggregator{
+		usage: map[usageKey]*IngressUsage{},
+		now:   time.Now,
+	}
+}
+
+// Add records a request of an Ingress
+func (ua *UsageAggregator) Add(namespace, ingress string, requestBytes, responseBytes float64) {
+	ua.lock.Lock()
+	defer ua.lock.Unlock()
+
+	hour := ua.now().UTC().Truncate(time.Hour)
+	key := usageKey{namespace: namespace, ingress: ingress, hour: hour}
+	usage, ok := ua.usage[key]
+	if !ok {
+		usage = &IngressUsage{Namespace: namespace, Ingress: ingress, Hour: hour}
+		ua.usage[key] = usage
+		ua.expire(hour)
+	}
+
+	usage.Requests++
+	usage.RequestBytes += uint64(requestBytes)
+	usage.ResponseBytes += uint64(responseBytes)
+}
+
+// expire removes the usage older than usageRetention hours
+func (ua *UsageAggregator) expire(hour time.Time) {
+	oldest := hour.Add(-(usageRetention - 1) * time.Hour)
+	for key := range ua.usage {
+		if key.hour.Before(oldest) {
+			delete(ua.usage, key)
+		}
+	}
+}
+
+// Usage returns the usage of the Ingresses during the last usageRetention
+// hours, the current one included, by Ingress and hour. The usage of the
+// removed Ingresses is kept until it expires.
+func (ua *UsageAggregator) Usage() []IngressUsage {
+	ua.lock.Lock()
+	defer ua.lock.Unlock()
+
+	ua.expire(ua.now().UTC().Truncate(time.Hour))
+
+	usage := make([]IngressUsage, 0, len(ua.usage))
+	for _, u := range ua.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Namespace != usage[j].Namespace {
+			return usage[i].Namespace < usage[j].Namespace
+		}
+		if usage[i].Ingress != usage[j].Ingress {
+			return usage[i].Ingress < usage[j].Ingress
+		}
+		return usage[i].Hour.Before(usage[j].Hour)
+	})
+	return usage
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageAggregator(t *testing.T) {
+	now := time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)
+	ua := NewUsageAggregator()
+	ua.now = func() time.Time { return now }
+
+	ua.Add("default", "web", 300, 1500)
+	ua.Add("default", "web", 700, 0)
+	ua.Add("default", "api", 100, 200)
+	now = now.Add(time.Hour)
+	ua.Add("default", "web", 50, 60)
+
+	hour := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	expected := []IngressUsage{
+		{Namespace: "default", Ingress: "api", Hour: hour, Requests: 1, RequestBytes: 100, ResponseBytes: 200},
+		{Namespace: "default", Ingress: "web", Hour: hour, Requests: 2, RequestBytes: 1000, ResponseBytes: 1500},
+		{Namespace: "default", Ingress: "web", Hour: hour.Add(time.Hour), Requests: 1, RequestBytes: 50, ResponseBytes: 60},
+	}
+	if usage := ua.Usage(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("expected usage %+v but got %+v", expected, usage)
+	}
+
+	now = now.Add((usageRetention - 1) * time.Hour)
+	expected = expected[2:]
+	if usage := ua.Usage(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("expected the usage older than %v hours to expire but got %+v", usageRetention, usage)
+	}
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package metric
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
 	"k8s.io/ingress-nginx/pkg/apis/ingress"
 )
 
@@ -47,6 +50,30 @@ func (dc DummyCollector) IncOrphanIngress(string, string, string) {}
 // DecOrphanIngress dummy implementation
 func (dc DummyCollector) DecOrphanIngress(string, string, string) {}
 
+// SetSSLCertificateFallbacks dummy implementation
+func (dc DummyCollector) SetSSLCertificateFallbacks([]*ingress.Server) {}
+
+// SetSlowStartEndpoints dummy implementation
+func (dc DummyCollector) SetSlowStartEndpoints([]*ingress.Backend) {}
+
+// SetIngressConflicts dummy implementation
+func (dc DummyCollector) SetIngressConflicts([]ingress.IngressConflict) {}
+
+// SetSyntheticProbeResults dummy implementation
+func (dc DummyCollector) SetSyntheticProbeResults([]ingress.SyntheticProbeResult) {}
+
+// SetDefaultAnnotationOverrides dummy implementation
+func (dc DummyCollector) SetDefaultAnnotationOverrides([]*ingress.Ingress) {}
+
+// ObserveCRLRefresh dummy implementation
+func (dc DummyCollector) ObserveCRLRefresh(string, time.Duration, bool) {}
+
+// ObserveOCSPCheck dummy implementation
+func (dc DummyCollector) ObserveOCSPCheck(string, time.Duration, bool) {}
+
+// IncSSLPassthroughConnection dummy implementation
+func (dc DummyCollector) IncSSLPassthroughConnection(string, string) {}
+
 // IncCheckCount dummy implementation
 func (dc DummyCollector) IncCheckCount(string, string) {}
 
@@ -71,6 +98,18 @@ func (dc DummyCollector) SetSSLExpireTime([]*ingress.Server) {}
 // SetHosts dummy implementation
 func (dc DummyCollector) SetHosts(_ sets.Set[string]) {}
 
+// SetMetricLabels dummy implementation
+func (dc DummyCollector) SetMetricLabels(_ map[string]collectors.MetricLabels) {}
+
+// EnableRequestSampling dummy implementation
+func (dc DummyCollector) EnableRequestSampling(_ int) {}
+
+// RequestSamples dummy implementation
+func (dc DummyCollector) RequestSamples() []collectors.RequestSample { return nil }
+
+// IngressUsage dummy implementation
+func (dc DummyCollector) IngressUsage() []collectors.IngressUsage { return nil }
+
 // OnStartedLeading indicates the pod is not the current leader
 func (dc DummyCollector) OnStartedLeading(_ string) {}
 
@@ -45,14 +45,32 @@ type Collector interface {
 	IncCheckErrorCount(string, string)
 	IncOrphanIngress(string, string, string)
 	DecOrphanIngress(string, string, string)
+	SetDefaultAnnotationOverrides([]*ingress.Ingress)
+	ObserveCRLRefresh(url string, duration time.Duration, success bool)
+	ObserveOCSPCheck(responder string, duration time.Duration, success bool)
+	IncSSLPassthroughConnection(host, action string)
 
 	RemoveMetrics(ingresses, certificates []string)
 
 	SetSSLExpireTime([]*ingress.Server)
 	SetSSLInfo(servers []*ingress.Server)
+	SetSSLCertificateFallbacks(servers []*ingress.Server)
+	SetSlowStartEndpoints(backends []*ingress.Backend)
+	SetIngressConflicts(conflicts []ingress.IngressConflict)
+	SetSyntheticProbeResults(results []ingress.SyntheticProbeResult)
 
 	// SetHosts sets the hostnames that are being served by the ingress controller
 	SetHosts(set sets.Set[string])
+	// SetMetricLabels sets the labels of the request metrics of the Ingresses
+	SetMetricLabels(labels map[string]collectors.MetricLabels)
+
+	// EnableRequestSampling records the last size distinct requests served by Ingresses
+	EnableRequestSampling(size int)
+	// RequestSamples returns the requests recorded since EnableRequestSampling
+	RequestSamples() []collectors.RequestSample
+
+	// IngressUsage returns the bytes received and sent by every Ingress by hour
+	IngressUsage() []collectors.IngressUsage
 
 	Start(string)
 	Stop(string)
@@ -183,6 +201,22 @@ func (c *collector) SetSSLInfo(servers []*ingress.Server) {
 	c.ingressController.SetSSLInfo(servers)
 }
 
+func (c *collector) SetSSLCertificateFallbacks(servers []*ingress.Server) {
+	c.ingressController.SetSSLCertificateFallbacks(servers)
+}
+
+func (c *collector) SetSlowStartEndpoints(backends []*ingress.Backend) {
+	c.ingressController.SetSlowStartEndpoints(backends)
+}
+
+func (c *collector) SetIngressConflicts(conflicts []ingress.IngressConflict) {
+	c.ingressController.SetIngressConflicts(conflicts)
+}
+
+func (c *collector) SetSyntheticProbeResults(results []ingress.SyntheticProbeResult) {
+	c.ingressController.SetSyntheticProbeResults(results)
+}
+
 func (c *collector) IncOrphanIngress(namespace, name, orphanityType string) {
 	c.ingressController.IncOrphanIngress(namespace, name, orphanityType)
 }
@@ -191,10 +225,42 @@ func (c *collector) DecOrphanIngress(namespace, name, orphanityType string) {
 	c.ingressController.DecOrphanIngress(namespace, name, orphanityType)
 }
 
+func (c *collector) SetDefaultAnnotationOverrides(ingresses []*ingress.Ingress) {
+	c.ingressController.SetDefaultAnnotationOverrides(ingresses)
+}
+
+func (c *collector) ObserveCRLRefresh(url string, duration time.Duration, success bool) {
+	c.ingressController.ObserveCRLRefresh(url, duration, success)
+}
+
+func (c *collector) ObserveOCSPCheck(responder string, duration time.Duration, success bool) {
+	c.ingressController.ObserveOCSPCheck(responder, duration, success)
+}
+
+func (c *collector) IncSSLPassthroughConnection(host, action string) {
+	c.ingressController.IncSSLPassthroughConnection(host, action)
+}
+
 func (c *collector) SetHosts(hosts sets.Set[string]) {
 	c.socket.SetHosts(hosts)
 }
 
+func (c *collector) SetMetricLabels(labels map[string]collectors.MetricLabels) {
+	c.socket.SetMetricLabels(labels)
+}
+
+func (c *collector) EnableRequestSampling(size int) {
+	c.socket.EnableRequestSampling(size)
+}
+
+func (c *collector) RequestSamples() []collectors.RequestSample {
+	return c.socket.RequestSamples()
+}
+
+func (c *collector) IngressUsage() []collectors.IngressUsage {
+	return c.socket.IngressUsage()
+}
+
 func (c *collector) SetAdmissionMetrics(testedIngressLength, testedIngressTime, renderingIngressLength, renderingIngressTime, testedConfigurationSize, admissionTime float64) {
 	c.admissionController.SetAdmissionMetrics(
 		testedIngressLength,
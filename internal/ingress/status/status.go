@@ -69,6 +69,15 @@ type Config struct {
 	UseNodeInternalIP bool
 
 	IngressLister ingressLister
+
+	// IngressClassPublish returns the publish Service and status address of
+	// the Ingresses of an IngressClass, both empty when the Ingresses use the
+	// addresses of the controller
+	IngressClassPublish func(class string) (publishService, publishStatusAddress string)
+
+	// UpdateRouteStatus updates the status of the TCPRoute and UDPRoute
+	// resources, along with the status of the Ingresses
+	UpdateRouteStatus func()
 }
 
 // statusSync keeps the status IP in each Ingress rule updated executing a periodic check
@@ -133,7 +142,7 @@ func (s *statusSync) Shutdown() {
 	}
 
 	klog.InfoS("removing value from ingress status", "address", addrs)
-	s.updateStatus([]v1.IngressLoadBalancerIngress{})
+	s.updateStatus([]v1.IngressLoadBalancerIngress{}, nil)
 }
 
 func (s *statusSync) sync(_ interface{}) error {
@@ -142,15 +151,51 @@ func (s *statusSync) sync(_ interface{}) error {
 		return nil
 	}
 
+	// the routes do not use the addresses of the controller
+	if s.UpdateRouteStatus != nil {
+		s.UpdateRouteStatus()
+	}
+
 	addrs, err := s.runningAddresses()
 	if err != nil {
 		return err
 	}
-	s.updateStatus(standardizeLoadBalancerIngresses(addrs))
+	s.updateStatus(standardizeLoadBalancerIngresses(addrs), s.classAddresses())
 
 	return nil
 }
 
+// classAddresses returns the addresses of the IngressClasses with a publish
+// Service or status address. The addresses of a class are nil when they can
+// not be obtained.
+func (s *statusSync) classAddresses() map[string][]v1.IngressLoadBalancerIngress {
+	if s.IngressClassPublish == nil {
+		return nil
+	}
+
+	addrs := make(map[string][]v1.IngressLoadBalancerIngress)
+	for _, ing := range s.IngressLister.ListIngresses() {
+		if _, ok := addrs[ing.IngressClass]; ok || ing.IngressClass == "" {
+			continue
+		}
+
+		publishService, publishStatusAddress := s.IngressClassPublish(ing.IngressClass)
+		classAddrs, ok, err := publishedAddresses(publishService, publishStatusAddress, s.Client)
+		if err != nil {
+			klog.ErrorS(err, "error obtaining the addresses of the IngressClass", "ingressclass", ing.IngressClass)
+			addrs[ing.IngressClass] = nil
+			continue
+		}
+		if ok {
+			if classAddrs == nil {
+				classAddrs = []v1.IngressLoadBalancerIngress{}
+			}
+			addrs[ing.IngressClass] = standardizeLoadBalancerIngresses(classAddrs)
+		}
+	}
+	return addrs
+}
+
 func (s *statusSync) keyfunc(input interface{}) (interface{}, error) {
 	return input, nil
 }
@@ -176,18 +221,8 @@ func nameOrIPToLoadBalancerIngress(nameOrIP string) v1.IngressLoadBalancerIngres
 // runningAddresses returns a list of IP addresses and/or FQDN where the
 // ingress controller is currently running
 func (s *statusSync) runningAddresses() ([]v1.IngressLoadBalancerIngress, error) {
-	if s.PublishStatusAddress != "" {
-		re := regexp.MustCompile(`,\s*`)
-		multipleAddrs := re.Split(s.PublishStatusAddress, -1)
-		addrs := make([]v1.IngressLoadBalancerIngress, len(multipleAddrs))
-		for i, addr := range multipleAddrs {
-			addrs[i] = nameOrIPToLoadBalancerIngress(addr)
-		}
-		return addrs, nil
-	}
-
-	if s.PublishService != "" {
-		return statusAddressFromService(s.PublishService, s.Client)
+	if addrs, ok, err := publishedAddresses(s.PublishService, s.PublishStatusAddress, s.Client); ok || err != nil {
+		return addrs, err
 	}
 
 	// get information about all the pods running the ingress controller
@@ -229,6 +264,27 @@ func (s *statusSync) runningAddresses() ([]v1.IngressLoadBalancerIngress, error)
 	return addrs, nil
 }
 
+// publishedAddresses returns the addresses of a publish status address or,
+// when it is empty, of a publish Service. It returns false when both are empty.
+func publishedAddresses(publishService, publishStatusAddress string, client clientset.Interface) ([]v1.IngressLoadBalancerIngress, bool, error) {
+	if publishStatusAddress != "" {
+		re := regexp.MustCompile(`,\s*`)
+		multipleAddrs := re.Split(publishStatusAddress, -1)
+		addrs := make([]v1.IngressLoadBalancerIngress, len(multipleAddrs))
+		for i, addr := range multipleAddrs {
+			addrs[i] = nameOrIPToLoadBalancerIngress(addr)
+		}
+		return addrs, true, nil
+	}
+
+	if publishService != "" {
+		addrs, err := statusAddressFromService(publishService, client)
+		return addrs, true, err
+	}
+
+	return nil, false, nil
+}
+
 func (s *statusSync) isRunningMultiplePods() bool {
 	// As a standard, app.kubernetes.io are "reserved well-known" labels.
 	// In our case, we add those labels as identifiers of the Ingress
@@ -262,8 +318,10 @@ func standardizeLoadBalancerIngresses(lbi []v1.IngressLoadBalancerIngress) []v1.
 	return lbi
 }
 
-// updateStatus changes the status information of Ingress rules
-func (s *statusSync) updateStatus(newIngressPoint []v1.IngressLoadBalancerIngress) {
+// updateStatus changes the status information of Ingress rules. The
+// Ingresses of the classes listed in classIngressPoints get the addresses of
+// their class instead, and are skipped when they are nil.
+func (s *statusSync) updateStatus(newIngressPoint []v1.IngressLoadBalancerIngress, classIngressPoints map[string][]v1.IngressLoadBalancerIngress) {
 	ings := s.IngressLister.ListIngresses()
 
 	p := pool.NewLimited(10)
@@ -271,16 +329,27 @@ func (s *statusSync) updateStatus(newIngressPoint []v1.IngressLoadBalancerIngres
 
 	batch := p.Batch()
 	sort.SliceStable(newIngressPoint, lessLoadBalancerIngress(newIngressPoint))
+	for _, classIngressPoint := range classIngressPoints {
+		sort.SliceStable(classIngressPoint, lessLoadBalancerIngress(classIngressPoint))
+	}
 
 	for _, ing := range ings {
+		ingressPoint := newIngressPoint
+		if classIngressPoint, ok := classIngressPoints[ing.IngressClass]; ok {
+			if classIngressPoint == nil {
+				continue
+			}
+			ingressPoint = classIngressPoint
+		}
+
 		curIPs := ing.Status.LoadBalancer.Ingress
 		sort.SliceStable(curIPs, lessLoadBalancerIngress(curIPs))
-		if ingressSliceEqual(curIPs, newIngressPoint) {
+		if ingressSliceEqual(curIPs, ingressPoint) {
 			klog.V(3).InfoS("skipping update of Ingress (no change)", "namespace", ing.Namespace, "ingress", ing.Name)
 			continue
 		}
 
-		batch.Queue(runUpdate(ing, newIngressPoint, s.Client))
+		batch.Queue(runUpdate(ing, ingressPoint, s.Client))
 	}
 
 	batch.QueueComplete()
@@ -367,6 +367,22 @@ func TestCallback(_ *testing.T) {
 	buildStatusSync()
 }
 
+func TestUpdateRouteStatus(t *testing.T) {
+	st := buildStatusSync()
+	st.PublishService = ""
+	st.PublishStatusAddress = "10.0.0.1"
+
+	updates := 0
+	st.UpdateRouteStatus = func() { updates++ }
+
+	if err := st.sync("just-test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updates != 1 {
+		t.Errorf("expected the status of the routes to be updated once but got %v", updates)
+	}
+}
+
 func TestKeyfunc(t *testing.T) {
 	fk := buildStatusSync()
 
@@ -740,3 +756,37 @@ func TestIngressSliceEqual(t *testing.T) {
 		}
 	}
 }
+
+type classIngressLister []*ingress.Ingress
+
+func (l classIngressLister) ListIngresses() []*ingress.Ingress {
+	return l
+}
+
+func TestClassAddresses(t *testing.T) {
+	fk := buildStatusSync()
+	fk.IngressLister = classIngressLister{
+		{IngressClass: "nginx"},
+		{IngressClass: "internal"},
+		{IngressClass: "internal"},
+		{IngressClass: "missing"},
+	}
+	fk.IngressClassPublish = func(class string) (publishService, publishStatusAddress string) {
+		switch class {
+		case "internal":
+			return "", "10.0.0.2, 10.0.0.1"
+		case "missing":
+			return apiv1.NamespaceDefault + "/missing", ""
+		}
+		return "", ""
+	}
+
+	addrs := fk.classAddresses()
+	expected := map[string][]networking.IngressLoadBalancerIngress{
+		"internal": {{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+		"missing":  nil,
+	}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expected %v but returned %v", expected, addrs)
+	}
+}
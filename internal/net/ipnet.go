@@ -28,6 +28,56 @@ type IPNet map[string]*net.IPNet
 // IP maps string to net.IP.
 type IP map[string]net.IP
 
+// parseIPNet parses an IP address or network. IPv6 literals can be
+// enclosed in brackets and contain a zone, which is removed as NGINX
+// does not support them. IPv4-mapped IPv6 addresses and networks are
+// converted to IPv4, as NGINX matches them with the IPv4 rules.
+func parseIPNet(spec string) (*net.IPNet, net.IP, error) {
+	value, bits := spec, ""
+	if i := strings.LastIndexByte(value, '/'); i >= 0 {
+		value, bits = value[:i], value[i:]
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		value = value[1 : len(value)-1]
+	}
+	if i := strings.IndexByte(value, '%'); i >= 0 && strings.Contains(value, ":") {
+		value = value[:i]
+	}
+
+	if bits == "" {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, &net.ParseError{Type: "IP address", Text: spec}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		return nil, ip, nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(value + bits)
+	if err != nil {
+		return nil, nil, &net.ParseError{Type: "CIDR address", Text: spec}
+	}
+	if ip4 := ipnet.IP.To4(); ip4 != nil && len(ipnet.Mask) == net.IPv6len {
+		ones, _ := ipnet.Mask.Size()
+		ipnet = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 32)}
+	}
+	return ipnet, nil, nil
+}
+
+// NormalizeCIDR returns the canonical form of an IP address or network
+func NormalizeCIDR(spec string) (string, error) {
+	ipnet, ip, err := parseIPNet(strings.TrimSpace(spec))
+	if err != nil {
+		return "", err
+	}
+	if ipnet != nil {
+		return ipnet.String(), nil
+	}
+	return ip.String(), nil
+}
+
 // ParseIPNets parses string slice to IPNet.
 func ParseIPNets(specs ...string) (IPNet, IP, error) {
 	ipnetset := make(IPNet)
@@ -35,19 +85,16 @@ func ParseIPNets(specs ...string) (IPNet, IP, error) {
 
 	for _, spec := range specs {
 		spec = strings.TrimSpace(spec)
-		_, ipnet, err := net.ParseCIDR(spec)
+		ipnet, ip, err := parseIPNet(spec)
 		if err != nil {
-			ip := net.ParseIP(spec)
-			if ip == nil {
-				return nil, nil, err
-			}
-			i := ip.String()
-			ipset[i] = ip
-			continue
+			return nil, nil, err
 		}
 
-		k := ipnet.String()
-		ipnetset[k] = ipnet
+		if ipnet != nil {
+			ipnetset[ipnet.String()] = ipnet
+			continue
+		}
+		ipset[ip.String()] = ip
 	}
 
 	return ipnetset, ipset, nil
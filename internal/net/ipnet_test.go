@@ -17,9 +17,13 @@ limitations under the License.
 package net
 
 import (
+	"fmt"
+	"net"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"testing/quick"
 )
 
 func TestNewIPSet(t *testing.T) {
@@ -55,3 +59,155 @@ func TestParseCIDRs(t *testing.T) {
 		t.Errorf("expected %v but got %v", expected, cidr)
 	}
 }
+
+func TestNormalizeCIDR(t *testing.T) {
+	testCases := map[string]string{
+		"10.0.0.1":                "10.0.0.1",
+		" 10.0.0.0/8 ":            "10.0.0.0/8",
+		"10.1.2.3/8":              "10.0.0.0/8",
+		"2001:DB8::1":             "2001:db8::1",
+		"2001:0db8:0000::0001":    "2001:db8::1",
+		"[2001:db8::1]":           "2001:db8::1",
+		"[2001:db8::]/32":         "2001:db8::/32",
+		"2001:db8::1/32":          "2001:db8::/32",
+		"fe80::1%eth0":            "fe80::1",
+		"[fe80::1%25eth0]":        "fe80::1",
+		"fe80::%eth0/64":          "fe80::/64",
+		"::ffff:10.0.0.1":         "10.0.0.1",
+		"::ffff:10.0.0.0/104":     "10.0.0.0/8",
+		"::ffff:a00:1/128":        "10.0.0.1/32",
+		"::/0":                    "::/0",
+		"0.0.0.0/0":               "0.0.0.0/0",
+		"::ffff:0:0/96":           "0.0.0.0/0",
+		"64:ff9b::192.0.2.33/120": "64:ff9b::c000:200/120",
+	}
+
+	for spec, expected := range testCases {
+		cidr, err := NormalizeCIDR(spec)
+		if err != nil {
+			t.Errorf("unexpected error normalizing %q: %v", spec, err)
+			continue
+		}
+		if cidr != expected {
+			t.Errorf("expected %q for %q but got %q", expected, spec, cidr)
+		}
+	}
+
+	for _, spec := range []string{"", "invalid.com", "10.0.0.1%eth0", "10.0.0.0/33", "2001:db8::/129", "[10.0.0.1", "2001:db8::1]", "2001:db8:::1", "10.0.0.0/-1"} {
+		if cidr, err := NormalizeCIDR(spec); err == nil {
+			t.Errorf("expected an error normalizing %q but got %q", spec, cidr)
+		}
+	}
+}
+
+func TestParseIPNetsMixedFamilies(t *testing.T) {
+	ipnets, ips, err := ParseIPNets("10.0.0.0/8", "::ffff:10.0.0.0/104", "2001:db8::/32", "[2001:DB8::]/32", "192.168.0.1", "::ffff:192.168.0.1", "fe80::1%eth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	networks := []string{}
+	for k := range ipnets {
+		networks = append(networks, k)
+	}
+	sort.Strings(networks)
+	if expected := []string{"10.0.0.0/8", "2001:db8::/32"}; !reflect.DeepEqual(expected, networks) {
+		t.Errorf("expected networks %v but got %v", expected, networks)
+	}
+
+	addresses := []string{}
+	for k := range ips {
+		addresses = append(addresses, k)
+	}
+	sort.Strings(addresses)
+	if expected := []string{"192.168.0.1", "fe80::1"}; !reflect.DeepEqual(expected, addresses) {
+		t.Errorf("expected addresses %v but got %v", expected, addresses)
+	}
+}
+
+// ipv6Spellings returns equivalent textual representations of an IPv6 address
+func ipv6Spellings(ip net.IP) []string {
+	canonical := ip.String()
+	groups := make([]string, 0, 8)
+	for i := 0; i < net.IPv6len; i += 2 {
+		groups = append(groups, fmt.Sprintf("%04X", int(ip[i])<<8|int(ip[i+1])))
+	}
+	expanded := strings.Join(groups, ":")
+
+	return []string{
+		canonical,
+		strings.ToUpper(canonical),
+		expanded,
+		"[" + expanded + "]",
+		canonical + "%eth0",
+		"[" + canonical + "%25eth0]",
+	}
+}
+
+func TestNormalizeCIDRProperties(t *testing.T) {
+	// every spelling of an IPv6 address and network has the canonical form
+	ipv6 := func(addr [16]byte, bits uint8) bool {
+		ip := net.IP(addr[:])
+		if ip.To4() != nil {
+			return true
+		}
+		ones := int(bits % 129)
+		expectedNet := (&net.IPNet{IP: ip.Mask(net.CIDRMask(ones, 128)), Mask: net.CIDRMask(ones, 128)}).String()
+
+		for _, spelling := range ipv6Spellings(ip) {
+			if cidr, err := NormalizeCIDR(spelling); err != nil || cidr != ip.String() {
+				t.Logf("address %q normalized to %q (%v)", spelling, cidr, err)
+				return false
+			}
+			if strings.HasPrefix(spelling, "[") {
+				continue
+			}
+			spelling = strings.TrimSuffix(spelling, "%eth0")
+			if cidr, err := NormalizeCIDR(fmt.Sprintf("%s/%d", spelling, ones)); err != nil || cidr != expectedNet {
+				t.Logf("network %s/%d normalized to %q (%v)", spelling, ones, cidr, err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(ipv6, nil); err != nil {
+		t.Error(err)
+	}
+
+	// IPv4 addresses and networks are the same as their IPv4-mapped IPv6 form
+	ipv4 := func(addr [4]byte, bits uint8) bool {
+		ip := net.IP(addr[:])
+		ones := int(bits % 33)
+		mapped := fmt.Sprintf("::ffff:%02x%02x:%02x%02x", addr[0], addr[1], addr[2], addr[3])
+
+		plain, err := NormalizeCIDR(fmt.Sprintf("%s/%d", ip, ones))
+		if err != nil {
+			return false
+		}
+		fromMapped, err := NormalizeCIDR(fmt.Sprintf("%s/%d", mapped, ones+96))
+		if err != nil || plain != fromMapped {
+			t.Logf("network %s/%d normalized to %q but %s/%d to %q (%v)", ip, ones, plain, mapped, ones+96, fromMapped, err)
+			return false
+		}
+
+		address, err := NormalizeCIDR("::ffff:" + ip.String())
+		return err == nil && address == ip.String()
+	}
+	if err := quick.Check(ipv4, nil); err != nil {
+		t.Error(err)
+	}
+
+	// normalizing is idempotent
+	idempotent := func(addr [16]byte, bits uint8) bool {
+		cidr, err := NormalizeCIDR(fmt.Sprintf("%s/%d", net.IP(addr[:]), bits%129))
+		if err != nil {
+			// IPv4-mapped addresses with a prefix shorter than 96 bits are IPv6 networks
+			return true
+		}
+		again, err := NormalizeCIDR(cidr)
+		return err == nil && again == cidr
+	}
+	if err := quick.Check(idempotent, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package spiffe implements a client of the X.509 SVID profile of the SPIFFE
+// Workload API, used to obtain the identity of the controller in a service mesh.
+// Only the FetchX509SVID stream is needed, its messages are decoded with
+// protowire instead of vendoring go-spiffe and its generated protobuf code.
+package spiffe
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+	// securityHeader must be sent with every request to the Workload API
+	securityHeader = "workload.spiffe.io"
+)
+
+// X509SVID is an X.509 SPIFFE Verifiable Identity Document
+type X509SVID struct {
+	// ID is the SPIFFE ID of the SVID, like spiffe://example.org/ns/default/sa/web
+	ID string
+	// Certificates contains the certificate of the SVID followed by its intermediates
+	Certificates []*x509.Certificate
+	// PrivateKey contains the PKCS#8 DER encoded private key of the SVID
+	PrivateKey []byte
+	// Bundle contains the CA certificates of the trust domain of the SVID
+	Bundle []*x509.Certificate
+}
+
+// CertificatePEM returns the PEM encoded certificate chain and private key
+// of the SVID, in the format expected by proxy_ssl_certificate
+func (s *X509SVID) CertificatePEM() []byte {
+	var out []byte
+	for _, cert := range s.Certificates {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return append(out, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: s.PrivateKey})...)
+}
+
+// BundlePEM returns the PEM encoded CA certificates of the trust domain of the SVID
+func (s *X509SVID) BundlePEM() []byte {
+	var out []byte
+	for _, cert := range s.Bundle {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return out
+}
+
+// Client is a client of the Workload API exposed on a unix socket by the
+// SPIFFE agent of the node
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient returns a client of the Workload API listening on socket,
+// either a path or an URL like unix:///run/spire/sockets/agent.sock
+func NewClient(socket string) (*Client, error) {
+	if !strings.HasPrefix(socket, "unix://") {
+		socket = "unix://" + socket
+	}
+
+	conn, err := grpc.NewClient(socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection to the Workload API
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// WatchX509SVIDs calls onUpdate with the SVIDs of the workload each time the
+// Workload API sends them, until ctx is done or the stream fails
+func (c *Client) WatchX509SVIDs(ctx context.Context, onUpdate func([]*X509SVID)) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, securityHeader, "true")
+
+	stream, err := c.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod,
+		grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	// X509SVIDRequest has no field
+	if err := stream.SendMsg([]byte{}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var resp []byte
+		if err := stream.RecvMsg(&resp); err != nil {
+			return err
+		}
+
+		svids, err := parseX509SVIDResponse(resp)
+		if err != nil {
+			return err
+		}
+
+		onUpdate(svids)
+	}
+}
+
+// rawCodec sends and receives the protobuf messages without decoding them,
+// the messages of the Workload API are decoded by parseX509SVIDResponse
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// parseX509SVIDResponse decodes an X509SVIDResponse message:
+//
+//	message X509SVIDResponse {
+//	  repeated X509SVID svids = 1;
+//	  ...
+//	}
+func parseX509SVIDResponse(b []byte) ([]*X509SVID, error) {
+	var svids []*X509SVID
+	err := parseMessage(b, func(num protowire.Number, value []byte) error {
+		if num != 1 {
+			return nil
+		}
+
+		svid, err := parseX509SVID(value)
+		if err != nil {
+			return err
+		}
+		svids = append(svids, svid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(svids) == 0 {
+		return nil, errors.New("the Workload API returned no SVID")
+	}
+
+	return svids, nil
+}
+
+// parseX509SVID decodes an X509SVID message:
+//
+//	message X509SVID {
+//	  string spiffe_id = 1;
+//	  bytes x509_svid = 2;
+//	  bytes x509_svid_key = 3;
+//	  bytes bundle = 4;
+//	  string hint = 5;
+//	}
+func parseX509SVID(b []byte) (*X509SVID, error) {
+	svid := &X509SVID{}
+	var certs, bundle []byte
+	err := parseMessage(b, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			svid.ID = string(value)
+		case 2:
+			certs = value
+		case 3:
+			svid.PrivateKey = value
+		case 4:
+			bundle = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svid.Certificates, err = x509.ParseCertificates(certs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificates of SVID %q: %w", svid.ID, err)
+	}
+	if len(svid.Certificates) == 0 {
+		return nil, fmt.Errorf("SVID %q has no certificate", svid.ID)
+	}
+
+	if _, err := x509.ParsePKCS8PrivateKey(svid.PrivateKey); err != nil {
+		return nil, fmt.Errorf("invalid private key of SVID %q: %w", svid.ID, err)
+	}
+
+	svid.Bundle, err = x509.ParseCertificates(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle of SVID %q: %w", svid.ID, err)
+	}
+
+	return svid, nil
+}
+
+// parseMessage calls field with the length-delimited fields of a protobuf
+// message, the other fields are skipped
+func parseMessage(b []byte, field func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := field(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spiffe
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type testSVID struct {
+	id     string
+	certs  []byte
+	key    []byte
+	bundle []byte
+}
+
+func newTestSVID(t *testing.T, id string) testSVID {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"example.org"}},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testSVID{id: id, certs: cert, key: keyDER, bundle: ca}
+}
+
+func (s testSVID) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, s.id)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, s.certs)
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, s.key)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, s.bundle)
+	return b
+}
+
+func marshalResponse(svids ...testSVID) []byte {
+	var b []byte
+	for _, svid := range svids {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, svid.marshal())
+	}
+	// federated bundles are ignored
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{})
+	return b
+}
+
+// startWorkloadAPI starts a fake Workload API sending responses to the
+// stream of FetchX509SVID
+func startWorkloadAPI(t *testing.T, responses ...[]byte) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		if method != fetchX509SVIDMethod {
+			return status.Errorf(codes.Unimplemented, "unexpected method %v", method)
+		}
+
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		if v := md.Get(securityHeader); len(v) != 1 || v[0] != "true" {
+			return status.Error(codes.InvalidArgument, "security header missing from request")
+		}
+
+		var req []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+
+		for _, resp := range responses {
+			if err := stream.SendMsg(resp); err != nil {
+				return err
+			}
+		}
+		return status.Error(codes.Unavailable, "done")
+	}))
+	go func() {
+		//nolint:errcheck // the server stops with the test
+		server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return socket
+}
+
+func TestWatchX509SVIDs(t *testing.T) {
+	web := newTestSVID(t, "spiffe://example.org/ns/default/sa/web")
+	api := newTestSVID(t, "spiffe://example.org/ns/default/sa/api")
+	socket := startWorkloadAPI(t, marshalResponse(web), marshalResponse(web, api))
+
+	client, err := NewClient(socket)
+	if err != nil {
+		t.Fatalf("unexpected error creating the client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updates [][]*X509SVID
+	err = client.WatchX509SVIDs(ctx, func(svids []*X509SVID) {
+		updates = append(updates, svids)
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the stream to end with the error of the server but got %v", err)
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates but got %v", len(updates))
+	}
+	if len(updates[1]) != 2 || updates[1][1].ID != api.id {
+		t.Errorf("expected the SVIDs of the second update to be web and api but got %v", updates[1])
+	}
+
+	svid := updates[0][0]
+	if svid.ID != web.id {
+		t.Errorf("expected SPIFFE ID %v but got %v", web.id, svid.ID)
+	}
+	if !bytes.Equal(svid.Certificates[0].Raw, web.certs) || !bytes.Equal(svid.Bundle[0].Raw, web.bundle) {
+		t.Errorf("unexpected certificates of the SVID")
+	}
+
+	pem := svid.CertificatePEM()
+	if !bytes.Contains(pem, []byte("BEGIN CERTIFICATE")) || !bytes.Contains(pem, []byte("BEGIN PRIVATE KEY")) {
+		t.Errorf("expected the certificate and the key in PEM format but got %s", pem)
+	}
+}
+
+func TestParseX509SVIDResponse(t *testing.T) {
+	if _, err := parseX509SVIDResponse(nil); err == nil {
+		t.Errorf("expected an error for a response without SVID")
+	}
+
+	svid := newTestSVID(t, "spiffe://example.org/web")
+	svid.key = []byte("invalid")
+	if _, err := parseX509SVIDResponse(marshalResponse(svid)); err == nil {
+		t.Errorf("expected an error for an SVID with an invalid key")
+	}
+
+	if _, err := parseX509SVIDResponse([]byte{0x0a, 0xff}); err == nil {
+		t.Errorf("expected an error for a truncated response")
+	}
+}
+
+// workloadAPIFile describes the messages of FetchX509SVID of the workload.proto
+// of the SPIFFE Workload API specification, to encode the responses with the
+// protobuf runtime instead of the hand-written encoding of marshalResponse
+func workloadAPIFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	bytesType := descriptorpb.FieldDescriptorProto_TYPE_BYTES
+	stringType := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	messageType := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+
+	svids := field("svids", 1, messageType, repeated)
+	svids.TypeName = proto.String(".X509SVID")
+	federatedBundles := field("federated_bundles", 3, messageType, repeated)
+	federatedBundles.TypeName = proto.String(".X509SVIDResponse.FederatedBundlesEntry")
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:   proto.String("workload.proto"),
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("X509SVIDResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					svids,
+					field("crl", 2, bytesType, repeated),
+					federatedBundles,
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("FederatedBundlesEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, stringType, optional),
+						field("value", 2, bytesType, optional),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+			{
+				Name: proto.String("X509SVID"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("spiffe_id", 1, stringType, optional),
+					field("x509_svid", 2, bytesType, optional),
+					field("x509_svid_key", 3, bytesType, optional),
+					field("bundle", 4, bytesType, optional),
+					field("hint", 5, stringType, optional),
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestParseX509SVIDResponseOfWorkloadAPISchema(t *testing.T) {
+	fd := workloadAPIFile(t)
+	responseDesc := fd.Messages().ByName("X509SVIDResponse")
+	svidDesc := fd.Messages().ByName("X509SVID")
+
+	web := newTestSVID(t, "spiffe://example.org/ns/default/sa/web")
+	federated := newTestSVID(t, "spiffe://federated.example.com/web")
+	// the SVID is sent with its intermediate
+	chain := append(append([]byte{}, web.certs...), federated.bundle...)
+
+	svid := dynamicpb.NewMessage(svidDesc)
+	svid.Set(svidDesc.Fields().ByName("spiffe_id"), protoreflect.ValueOfString(web.id))
+	svid.Set(svidDesc.Fields().ByName("x509_svid"), protoreflect.ValueOfBytes(chain))
+	svid.Set(svidDesc.Fields().ByName("x509_svid_key"), protoreflect.ValueOfBytes(web.key))
+	svid.Set(svidDesc.Fields().ByName("bundle"), protoreflect.ValueOfBytes(web.bundle))
+	svid.Set(svidDesc.Fields().ByName("hint"), protoreflect.ValueOfString("internal"))
+
+	response := dynamicpb.NewMessage(responseDesc)
+	response.Mutable(responseDesc.Fields().ByName("svids")).List().Append(protoreflect.ValueOfMessage(svid))
+	response.Mutable(responseDesc.Fields().ByName("crl")).List().Append(protoreflect.ValueOfBytes([]byte("crl")))
+	response.Mutable(responseDesc.Fields().ByName("federated_bundles")).Map().Set(
+		protoreflect.ValueOfString("federated.example.com").MapKey(), protoreflect.ValueOfBytes(federated.bundle))
+
+	b, err := proto.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svids, err := parseX509SVIDResponse(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(svids) != 1 {
+		t.Fatalf("expected 1 SVID but got %v", len(svids))
+	}
+	if svids[0].ID != web.id {
+		t.Errorf("expected SPIFFE ID %v but got %v", web.id, svids[0].ID)
+	}
+	if len(svids[0].Certificates) != 2 || !bytes.Equal(svids[0].Certificates[1].Raw, federated.bundle) {
+		t.Errorf("expected the certificate of the SVID followed by its intermediate")
+	}
+	if !bytes.Equal(svids[0].PrivateKey, web.key) {
+		t.Errorf("unexpected private key of the SVID")
+	}
+	if len(svids[0].Bundle) != 1 || !bytes.Equal(svids[0].Bundle[0].Raw, web.bundle) {
+		t.Errorf("expected the bundle of the trust domain of the SVID only")
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conf parses NGINX configuration files into a tree of directives
+// and queries it, to assert properties of the configuration rendered by
+// the template instead of comparing it with golden files.
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// luaBlockSuffix is the suffix of the directives whose block is Lua code
+const luaBlockSuffix = "_by_lua_block"
+
+// Directive is a simple or block directive of an NGINX configuration
+type Directive struct {
+	Name string
+	Args []string
+	// Line is the line of the name of the directive
+	Line int
+	// Block contains the directives of a block directive
+	Block []*Directive
+	// Lua contains the code of the *_by_lua_block directives
+	Lua string
+}
+
+// String returns the name and arguments of the directive and its line
+func (d *Directive) String() string {
+	if len(d.Args) == 0 {
+		return fmt.Sprintf("%v (line %v)", d.Name, d.Line)
+	}
+	return fmt.Sprintf("%v %v (line %v)", d.Name, strings.Join(d.Args, " "), d.Line)
+}
+
+type token struct {
+	value string
+	line  int
+	// quoted is true for the strings quoted in the configuration
+	quoted bool
+}
+
+func (t token) isSpecial(s string) bool {
+	return !t.quoted && t.value == s
+}
+
+type lexer struct {
+	content string
+	pos     int
+	line    int
+}
+
+// next returns the next token, false at the end of the content
+func (l *lexer) next() (token, bool, error) {
+	l.skipSpaceAndComments()
+	if l.pos >= len(l.content) {
+		return token{}, false, nil
+	}
+
+	line := l.line
+	c := l.content[l.pos]
+	switch c {
+	case '{', '}', ';':
+		l.pos++
+		return token{value: string(c), line: line}, true, nil
+	case '"', '\'':
+		value, err := l.quoted(c)
+		return token{value: value, line: line, quoted: true}, true, err
+	}
+
+	start := l.pos
+	for l.pos < len(l.content) {
+		c := l.content[l.pos]
+		if isSpace(c) || c == ';' || c == '{' || c == '}' {
+			break
+		}
+		// the braces of the ${name} variables are part of the word
+		if c == '$' && l.pos+1 < len(l.content) && l.content[l.pos+1] == '{' {
+			end := strings.IndexByte(l.content[l.pos:], '}')
+			if end < 0 {
+				return token{}, false, fmt.Errorf("line %v: unterminated variable", line)
+			}
+			l.pos += end + 1
+			continue
+		}
+		l.pos++
+	}
+	return token{value: l.content[start:l.pos], line: line}, true, nil
+}
+
+func (l *lexer) quoted(quote byte) (string, error) {
+	line := l.line
+	l.pos++
+
+	var value strings.Builder
+	for l.pos < len(l.content) {
+		c := l.content[l.pos]
+		switch {
+		case c == quote:
+			l.pos++
+			return value.String(), nil
+		case c == '\\' && l.pos+1 < len(l.content):
+			// NGINX removes the backslash of the escaped quotes and backslashes only
+			next := l.content[l.pos+1]
+			if next != quote && next != '\\' {
+				value.WriteByte(c)
+			}
+			value.WriteByte(next)
+			l.pos += 2
+			continue
+		case c == '\n':
+			l.line++
+		}
+		value.WriteByte(c)
+		l.pos++
+	}
+	return "", fmt.Errorf("line %v: unterminated string", line)
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.content) {
+		c := l.content[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case isSpace(c):
+			l.pos++
+		case c == '#':
+			end := strings.IndexByte(l.content[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.content)
+				return
+			}
+			l.pos += end
+		default:
+			return
+		}
+	}
+}
+
+// lua returns the code of a *_by_lua_block directive, up to its closing
+// brace. The braces of the Lua strings and comments are ignored.
+func (l *lexer) lua() (string, error) {
+	line := l.line
+	start := l.pos
+	depth := 0
+	for l.pos < len(l.content) {
+		c := l.content[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				code := l.content[start:l.pos]
+				l.pos++
+				return code, nil
+			}
+			depth--
+		case c == '"' || c == '\'':
+			l.skipLuaString(c)
+		case strings.HasPrefix(l.content[l.pos:], "[["):
+			l.skipUntil("]]")
+		case strings.HasPrefix(l.content[l.pos:], "--[["):
+			l.skipUntil("]]")
+		case strings.HasPrefix(l.content[l.pos:], "--"):
+			l.skipUntil("\n")
+			continue
+		}
+		l.pos++
+	}
+	return "", fmt.Errorf("line %v: unterminated Lua block", line)
+}
+
+func (l *lexer) skipLuaString(quote byte) {
+	for l.pos++; l.pos < len(l.content); l.pos++ {
+		switch l.content[l.pos] {
+		case '\\':
+			l.pos++
+		case quote, '\n':
+			return
+		}
+	}
+}
+
+// skipUntil moves to the last byte of the next occurrence of s, or to the
+// end of the content
+func (l *lexer) skipUntil(s string) {
+	end := strings.Index(l.content[l.pos+1:], s)
+	if end < 0 {
+		l.line += strings.Count(l.content[l.pos:], "\n")
+		l.pos = len(l.content)
+		return
+	}
+	end += l.pos + 1 + len(s) - 1
+	l.line += strings.Count(l.content[l.pos:end], "\n")
+	l.pos = end
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Parse parses an NGINX configuration, returning the directive containing
+// its top level directives
+func Parse(content string) (*Directive, error) {
+	l := &lexer{content: content, line: 1}
+	block, err := parseBlock(l, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Directive{Block: block}, nil
+}
+
+func parseBlock(l *lexer, nested bool) ([]*Directive, error) {
+	block := []*Directive{}
+	var current *Directive
+	for {
+		t, ok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if current != nil {
+				return nil, fmt.Errorf("line %v: directive %q is not terminated by \";\"", current.Line, current.Name)
+			}
+			if nested {
+				return nil, fmt.Errorf("line %v: unexpected end of file, expecting \"}\"", l.line)
+			}
+			return block, nil
+		}
+
+		switch {
+		case t.isSpecial(";"):
+			if current == nil {
+				return nil, fmt.Errorf("line %v: unexpected \";\"", t.line)
+			}
+			block = append(block, current)
+			current = nil
+		case t.isSpecial("{"):
+			if current == nil {
+				return nil, fmt.Errorf("line %v: unexpected \"{\"", t.line)
+			}
+			if strings.HasSuffix(current.Name, luaBlockSuffix) {
+				current.Lua, err = l.lua()
+			} else {
+				current.Block, err = parseBlock(l, true)
+			}
+			if err != nil {
+				return nil, err
+			}
+			block = append(block, current)
+			current = nil
+		case t.isSpecial("}"):
+			if current != nil {
+				return nil, fmt.Errorf("line %v: directive %q is not terminated by \";\"", current.Line, current.Name)
+			}
+			if !nested {
+				return nil, fmt.Errorf("line %v: unexpected \"}\"", t.line)
+			}
+			return block, nil
+		case current == nil:
+			current = &Directive{Name: t.value, Line: t.line}
+		default:
+			current.Args = append(current.Args, t.value)
+		}
+	}
+}
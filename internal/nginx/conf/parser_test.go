@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testConfig = `# comment
+worker_processes 2;
+
+http {
+    map $http_upgrade $connection_upgrade {
+        default upgrade;
+        ''      close;
+    }
+
+    server {
+        server_name example.com;
+        listen 443 ssl;
+
+        location /api/ {
+            set $path "${uri}?a={b}"; # comment
+            add_header X-Frame-Options 'SAMEORIGIN' always;
+            rewrite_by_lua_block {
+                -- a comment with a brace }
+                local s = "}" .. '{' .. [[ } ]]
+                if s then balancer.rewrite() end
+            }
+            proxy_pass http://upstream_balancer;
+        }
+    }
+}
+`
+
+func TestParse(t *testing.T) {
+	root, err := Parse(testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(root.Block) != 2 {
+		t.Fatalf("expected 2 top level directives but got %v", root.Block)
+	}
+	if d := root.Block[0]; d.Name != "worker_processes" || !reflect.DeepEqual(d.Args, []string{"2"}) || d.Line != 2 {
+		t.Errorf("unexpected directive %v", d)
+	}
+
+	http := root.Block[1]
+	mapBlock := http.Block[0]
+	if !reflect.DeepEqual(mapBlock.Args, []string{"$http_upgrade", "$connection_upgrade"}) || len(mapBlock.Block) != 2 {
+		t.Errorf("unexpected map %v %v", mapBlock, mapBlock.Block)
+	}
+	if d := mapBlock.Block[1]; d.Name != "" || !reflect.DeepEqual(d.Args, []string{"close"}) {
+		t.Errorf("expected the quoted empty name of the map entry but got %q %v", d.Name, d.Args)
+	}
+
+	location := http.Block[1].Block[2]
+	if location.Name != "location" || location.Line != 14 || len(location.Block) != 4 {
+		t.Fatalf("unexpected location %v %v", location, location.Block)
+	}
+	if d := location.Block[0]; !reflect.DeepEqual(d.Args, []string{"$path", "${uri}?a={b}"}) {
+		t.Errorf("unexpected set %v", d)
+	}
+	if d := location.Block[1]; !reflect.DeepEqual(d.Args, []string{"X-Frame-Options", "SAMEORIGIN", "always"}) {
+		t.Errorf("unexpected add_header %v", d)
+	}
+	if d := location.Block[2]; d.Name != "rewrite_by_lua_block" || d.Lua == "" || d.Block != nil {
+		t.Errorf("unexpected Lua block %v %q", d, d.Lua)
+	}
+	if d := location.Block[3]; d.Name != "proxy_pass" || d.Line != 22 {
+		t.Errorf("unexpected directive after the Lua block %v", d)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := map[string]string{
+		"unterminated directive": "worker_processes 2",
+		"unterminated block":     "http { server {}",
+		"unexpected brace":       "http {} }",
+		"unterminated string":    `set $a "b;`,
+		"unterminated Lua block": "init_by_lua_block { local a = 1",
+		"unexpected semicolon":   "http { ; }",
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(content); err == nil {
+				t.Errorf("expected an error parsing %q", content)
+			}
+		})
+	}
+}
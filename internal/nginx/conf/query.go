@@ -0,0 +1,326 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A selector selects directives like a CSS selector selects elements:
+//
+//	server location                 the locations of the servers, at any depth
+//	server > location               the locations directly in a server
+//	location[/api/]                 the locations whose first argument is /api/
+//	proxy_set_header[Host *]        the directives with two or more arguments, the first one being Host
+//	location[~^/api]                an argument matching a regular expression
+//	add_header["X-Frame-Options"]   quoted arguments, to match spaces or brackets
+//	*                               any directive
+//	server:has(ssl_certificate)     the servers containing an ssl_certificate directive
+//	server:has(> listen[443])       the servers with a listen 443 directive in their block
+//
+// The arguments of a step match the first arguments of a directive, *
+// matching any argument.
+
+type combinator int
+
+const (
+	descendant combinator = iota
+	child
+)
+
+type argMatcher struct {
+	any   bool
+	value string
+	regex *regexp.Regexp
+}
+
+func (m argMatcher) match(arg string) bool {
+	switch {
+	case m.any:
+		return true
+	case m.regex != nil:
+		return m.regex.MatchString(arg)
+	default:
+		return m.value == arg
+	}
+}
+
+type step struct {
+	// combinator is the relation of the directive with the one of the
+	// previous step, or with the queried directive for the first step
+	combinator combinator
+	name       string
+	args       []argMatcher
+	has        []*selector
+}
+
+func (s *step) match(d *Directive) bool {
+	if s.name != "*" && s.name != d.Name {
+		return false
+	}
+	if len(s.args) > len(d.Args) {
+		return false
+	}
+	for i, arg := range s.args {
+		if !arg.match(d.Args[i]) {
+			return false
+		}
+	}
+	for _, has := range s.has {
+		if len(has.match(d)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type selector struct {
+	steps []*step
+}
+
+// match returns the directives of the block of root matching the selector
+func (s *selector) match(root *Directive) []*Directive {
+	matches := []*Directive{}
+	var walk func(ancestors []*Directive, block []*Directive)
+	walk = func(ancestors []*Directive, block []*Directive) {
+		for _, d := range block {
+			if s.matchAt(ancestors, d, len(s.steps)-1) {
+				matches = append(matches, d)
+			}
+			walk(append(ancestors, d), d.Block)
+		}
+	}
+	walk([]*Directive{root}, root.Block)
+	return matches
+}
+
+// matchAt returns true when the directive d, whose ancestors up to the
+// queried directive are ancestors, matches the steps up to i
+func (s *selector) matchAt(ancestors []*Directive, d *Directive, i int) bool {
+	st := s.steps[i]
+	if !st.match(d) {
+		return false
+	}
+
+	parent := len(ancestors) - 1
+	if i == 0 {
+		return st.combinator == descendant || parent == 0
+	}
+
+	if st.combinator == child {
+		return parent > 0 && s.matchAt(ancestors[:parent], ancestors[parent], i-1)
+	}
+	for a := parent; a > 0; a-- {
+		if s.matchAt(ancestors[:a], ancestors[a], i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func parseSelector(input string) (*selector, error) {
+	p := &selectorParser{input: input}
+	s, err := p.selector()
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", input, err)
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("invalid selector %q: unexpected %q at %v", input, p.input[p.pos], p.pos)
+	}
+	return s, nil
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) selector() (*selector, error) {
+	s := &selector{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) || p.input[p.pos] == ')' {
+			break
+		}
+
+		st := &step{combinator: descendant}
+		if p.input[p.pos] == '>' {
+			st.combinator = child
+			p.pos++
+			p.skipSpace()
+		}
+
+		start := p.pos
+		for p.pos < len(p.input) && isNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+		st.name = p.input[start:p.pos]
+		if st.name == "" {
+			return nil, fmt.Errorf("expected a directive name at %v", start)
+		}
+
+		if err := p.filters(st); err != nil {
+			return nil, err
+		}
+		s.steps = append(s.steps, st)
+	}
+
+	if len(s.steps) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return s, nil
+}
+
+// filters parses the arguments and :has() filters following the name of a step
+func (p *selectorParser) filters(st *step) error {
+	for p.pos < len(p.input) {
+		switch {
+		case p.input[p.pos] == '[':
+			args, err := p.args()
+			if err != nil {
+				return err
+			}
+			st.args = args
+		case strings.HasPrefix(p.input[p.pos:], ":has("):
+			p.pos += len(":has(")
+			has, err := p.selector()
+			if err != nil {
+				return err
+			}
+			if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+				return fmt.Errorf("unterminated :has(")
+			}
+			p.pos++
+			st.has = append(st.has, has)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// args parses the arguments of a step, between brackets
+func (p *selectorParser) args() ([]argMatcher, error) {
+	p.pos++
+
+	args := []argMatcher{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("unterminated arguments")
+		}
+
+		c := p.input[p.pos]
+		if c == ']' {
+			p.pos++
+			return args, nil
+		}
+
+		var arg string
+		quoted := c == '"' || c == '\''
+		if quoted {
+			end := strings.IndexByte(p.input[p.pos+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %v", p.pos)
+			}
+			arg = p.input[p.pos+1 : p.pos+1+end]
+			p.pos += end + 2
+		} else {
+			start := p.pos
+			for p.pos < len(p.input) && !isSpace(p.input[p.pos]) && p.input[p.pos] != ']' {
+				p.pos++
+			}
+			arg = p.input[start:p.pos]
+		}
+
+		switch {
+		case !quoted && arg == "*":
+			args = append(args, argMatcher{any: true})
+		case !quoted && strings.HasPrefix(arg, "~"):
+			re, err := regexp.Compile(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, argMatcher{regex: re})
+		default:
+			args = append(args, argMatcher{value: arg})
+		}
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '*' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Query returns the directives of the block of the directive matching the
+// selector, in the order of the configuration
+func (d *Directive) Query(query string) ([]*Directive, error) {
+	s, err := parseSelector(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.match(d), nil
+}
+
+// Every returns an error listing the directives matching the selector
+// whose block does not contain a directive matching required
+func (d *Directive) Every(query, required string) error {
+	matches, err := d.Query(query)
+	if err != nil {
+		return err
+	}
+	s, err := parseSelector(required)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, m := range matches {
+		if len(s.match(m)) == 0 {
+			missing = append(missing, m.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%v directives matching %q do not contain %q: %v", len(missing), query, required, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// None returns an error listing the directives matching the selector
+func (d *Directive) None(query string) error {
+	matches, err := d.Query(query)
+	if err != nil {
+		return err
+	}
+
+	if len(matches) > 0 {
+		found := make([]string, 0, len(matches))
+		for _, m := range matches {
+			found = append(found, m.String())
+		}
+		return fmt.Errorf("%v directives match %q: %v", len(matches), query, strings.Join(found, ", "))
+	}
+	return nil
+}
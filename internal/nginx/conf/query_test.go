@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"testing"
+)
+
+func directiveLines(directives []*Directive) []int {
+	lines := []int{}
+	for _, d := range directives {
+		lines = append(lines, d.Line)
+	}
+	return lines
+}
+
+func TestQuery(t *testing.T) {
+	root, err := Parse(`
+http {
+    server {
+        server_name example.com;
+        listen 443 ssl;
+        location / {
+            proxy_set_header Host $host;
+            location /nested {
+                proxy_set_header Host $best_http_host;
+            }
+        }
+        location ~* ^/api/v[0-9] {
+            proxy_set_header X-Request-ID $req_id;
+        }
+    }
+    server {
+        server_name "other.com";
+        listen 80;
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		query    string
+		expected []int
+	}{
+		{"server", []int{3, 16}},
+		{"http > server", []int{3, 16}},
+		{"> server", []int{}},
+		{"> http > server", []int{3, 16}},
+		{"server location", []int{6, 8, 12}},
+		{"server > location", []int{6, 12}},
+		{"location location", []int{8}},
+		{"location[/]", []int{6}},
+		{"location[~\\* ~^/api/]", []int{}},
+		{"location[~\\* ~/api/v]", []int{12}},
+		{"listen[* ssl]", []int{5}},
+		{"listen[443 ssl http2]", []int{}},
+		{"proxy_set_header[Host]", []int{7, 9}},
+		{"proxy_set_header['Host' '$host']", []int{7}},
+		{"server_name[other.com]", []int{17}},
+		{"server:has(listen[443])", []int{3}},
+		{"server:has(listen[443]):has(server_name[other.com])", []int{}},
+		{"location:has(> proxy_set_header[Host])", []int{6, 8}},
+		{"location:has(> location)", []int{6}},
+		{"server:has(location:has(proxy_set_header[X-Request-ID])) > server_name", []int{4}},
+		{"* > listen", []int{5, 18}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			matches, err := root.Query(tc.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if lines := directiveLines(matches); !equalLines(lines, tc.expected) {
+				t.Errorf("expected the directives of the lines %v but got %v", tc.expected, lines)
+			}
+		})
+	}
+}
+
+func equalLines(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueryErrors(t *testing.T) {
+	root := &Directive{}
+	for _, query := range []string{"", "server[", "server:has(", "server:has()", "location[~(]", "server)", "[a]", "server['a]"} {
+		if _, err := root.Query(query); err == nil {
+			t.Errorf("expected an error with the query %q", query)
+		}
+	}
+}
+
+func TestEveryAndNone(t *testing.T) {
+	root, err := Parse(`
+server {
+    server_name a.com;
+    location / { proxy_pass http://upstream_balancer; }
+    location /static { root /var/www; }
+}
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := root.Every("server", "server_name"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := root.Every("location", "proxy_pass"); err == nil {
+		t.Errorf("expected an error for the location without proxy_pass")
+	} else if err.Error() != `1 directives matching "location" do not contain "proxy_pass": location /static (line 5)` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := root.None("location[/admin]"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := root.None("root"); err == nil {
+		t.Errorf("expected an error for the root directive")
+	}
+}
@@ -52,11 +52,16 @@ var MaxmindRetriesCount = 1
 // MaxmindRetriesTimeout maxmind download retries timeout in seconds, 0 - do not retry to download if something went wrong
 var MaxmindRetriesTimeout = time.Second * 0
 
+// MaxmindRefreshInterval interval between the downloads of the GeoIP DB, 0 - download only at startup
+var MaxmindRefreshInterval = time.Second * 0
+
 // minimumRetriesCount minimum value of the MaxmindRetriesCount parameter. If MaxmindRetriesCount less than minimumRetriesCount, it will be set to minimumRetriesCount
 const minimumRetriesCount = 1
 
+// geoIPPath directory of the databases loaded by the GeoIP2 NGINX module
+var geoIPPath = "/etc/ingress-controller/geoip"
+
 const (
-	geoIPPath   = "/etc/ingress-controller/geoip"
 	dbExtension = ".mmdb"
 
 	maxmindURL = "https://download.maxmind.com/app/geoip_download?license_key=%v&edition_id=%v&suffix=tar.gz"
@@ -179,19 +184,7 @@ func downloadDatabase(dbName string) error {
 			if !strings.HasSuffix(header.Name, mmdbFile) {
 				continue
 			}
-			return func() error {
-				outFile, err := os.Create(path.Join(geoIPPath, mmdbFile))
-				if err != nil {
-					return err
-				}
-
-				defer outFile.Close()
-
-				if _, err := io.CopyN(outFile, tarReader, header.Size); err != nil {
-					return err
-				}
-				return nil
-			}()
+			return writeDatabase(mmdbFile, tarReader, header.Size)
 		}
 	}
 
@@ -199,6 +192,51 @@ func downloadDatabase(dbName string) error {
 		fmt.Sprintf(maxmindURL, "XXXXXXX", dbName), mmdbFile)
 }
 
+// writeDatabase replaces the database atomically, as NGINX
+// could load it while it is being written
+func writeDatabase(mmdbFile string, r io.Reader, size int64) error {
+	outFile, err := os.CreateTemp(geoIPPath, mmdbFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(outFile.Name())
+
+	if _, err := io.CopyN(outFile, r, size); err != nil {
+		outFile.Close()
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+
+	//nolint:gosec // the databases are readable by the NGINX workers
+	if err := os.Chmod(outFile.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(outFile.Name(), path.Join(geoIPPath, mmdbFile))
+}
+
+// RefreshGeoLite2DB downloads the databases every MaxmindRefreshInterval
+// until stopCh is closed. The GeoIP2 NGINX module loads the new databases
+// using its auto_reload option, without reloading NGINX.
+func RefreshGeoLite2DB(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(MaxmindRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			klog.InfoS("refreshing maxmind GeoIP2 databases")
+			if err := DownloadGeoLite2DB(MaxmindRetriesCount, MaxmindRetriesTimeout); err != nil {
+				klog.ErrorS(err, "unexpected error refreshing GeoIP2 database")
+			}
+		}
+	}
+}
+
 // ValidateGeoLite2DBEditions check provided Maxmind database editions names
 func ValidateGeoLite2DBEditions() error {
 	allowedEditions := map[string]bool{
@@ -17,6 +17,13 @@ limitations under the License.
 package nginx
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 )
@@ -73,3 +80,61 @@ func TestGeoLite2DBExists(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadDatabase(t *testing.T) {
+	resetForTesting()
+	defer func(p string) { geoIPPath = p }(geoIPPath)
+	geoIPPath = t.TempDir()
+
+	content := []byte("mmdb content")
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "GeoLite2-Country_20240101/GeoLite2-Country.mmdb",
+		Mode:     0o644,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tw.Close()
+	gz.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/GeoLite2-Country.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		//nolint:errcheck // test server
+		w.Write(archive.Bytes())
+	}))
+	defer srv.Close()
+	MaxmindMirror = srv.URL
+
+	if err := downloadDatabase("GeoLite2-Country"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path.Join(geoIPPath, "GeoLite2-Country.mmdb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("expected %q but got %q", content, data)
+	}
+
+	if err := downloadDatabase("GeoLite2-ASN"); err == nil {
+		t.Errorf("expected an error downloading a missing database")
+	}
+
+	files, err := os.ReadDir(geoIPPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the database in the directory but got %v", files)
+	}
+}
@@ -17,29 +17,53 @@ limitations under the License.
 package ingress
 
 import (
+	"time"
+
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/attribution"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botmitigation"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/chaos"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/concurrencylimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/externalname"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failoverorigin"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/geoaccess"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/graphql"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipallowlist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipdenylist"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/linkrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancetuning"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/mirror"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/nextupstream"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentelemetry"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/pathtemplate"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycache"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestid"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/responsebodyrewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/retrypolicy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/servertiming"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/slowstart"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthroughrouting"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamsigning"
 )
 
 // TODO: The API shouldn't be importing structs from annotation code. Instead we probably want a conversion from internal
@@ -73,6 +97,21 @@ type Configuration struct {
 	DefaultSSLCertificate *SSLCert `json:"-"`
 
 	StreamSnippets []string `json:"StreamSnippets"`
+
+	// LuaPlugins are the Lua plugins loaded by NGINX, whose checksum
+	// matches the one configured
+	// +optional
+	LuaPlugins []LuaPlugin `json:"luaPlugins,omitempty"`
+}
+
+// LuaPlugin is a Lua plugin loaded from the Lua plugins ConfigMap
+type LuaPlugin struct {
+	// Name is the name of the module of the plugin
+	Name string `json:"name"`
+	// Checksum is the SHA-256 checksum of the code, in hexadecimal
+	Checksum string `json:"checksum"`
+	// Code is the Lua code of the plugin
+	Code string `json:"code,omitempty"`
 }
 
 // Backend describes one or more remote server/s (endpoints) associated with a service
@@ -92,6 +131,14 @@ type Backend struct {
 	UpstreamHashBy UpstreamHashByConfig `json:"upstreamHashByConfig,omitempty"`
 	// LB algorithm configuration per ingress
 	LoadBalancing string `json:"load-balance,omitempty"`
+	// Parameters of the LB algorithms per ingress
+	LoadBalanceTuning loadbalancetuning.Config `json:"loadBalanceTuning,omitempty"`
+	// Keepalive connections to the endpoints per ingress
+	UpstreamKeepalive upstreamkeepalive.Config `json:"upstreamKeepalive,omitempty"`
+	// Resolution of the external name of a Service of type ExternalName per ingress
+	ExternalName externalname.Config `json:"externalName,omitempty"`
+	// Ramp up of the weight of the new endpoints per ingress
+	SlowStart slowstart.Config `json:"slowStart,omitempty"`
 	// Denotes if a backend has no server. The backend instead shares a server with another backend and acts as an
 	// alternative backend.
 	// This can be used to share multiple upstreams in the sam nginx server block.
@@ -125,6 +172,8 @@ type TrafficShapingPolicy struct {
 	HeaderPattern string `json:"headerPattern"`
 	// Cookie on which to redirect requests to this backend
 	Cookie string `json:"cookie"`
+	// Geo contains the country codes of the clients whose requests are redirected to this backend
+	Geo []string `json:"geo,omitempty"`
 }
 
 // HashInclude defines if a field should be used or not to calculate the hash
@@ -190,6 +239,8 @@ type Server struct {
 	// SSLPassthrough indicates if the TLS termination is realized in
 	// the server or in the remote endpoint
 	SSLPassthrough bool `json:"sslPassthrough"`
+	// SSLPassthroughRouting defines the timeout and fallback of the SSL passthrough connections
+	SSLPassthroughRouting sslpassthroughrouting.Config `json:"sslPassthroughRouting"`
 	// SSLCert describes the certificate that will be used on the server
 	SSLCert *SSLCert `json:"sslCert"`
 	// Locations list of URIs configured in the server.
@@ -214,6 +265,82 @@ type Server struct {
 	SSLPreferServerCiphers string `json:"sslPreferServerCiphers,omitempty"`
 	// AuthTLSError contains the reason why the access to a server should be denied
 	AuthTLSError string `json:"authTLSError,omitempty"`
+	// Dynamic indicates the server is not rendered in the configuration of
+	// NGINX, its requests are routed by the catch-all server with Lua
+	Dynamic bool `json:"dynamic,omitempty"`
+	// SSLCertFallback describes why the server uses the default certificate
+	// instead of the certificate of the Secret listed in its TLS section.
+	// It does not change the configuration of NGINX and is not compared by Equal.
+	// +optional
+	SSLCertFallback *SSLCertFallback `json:"sslCertFallback,omitempty"`
+	// IngressClass is the class of the Ingresses of the server
+	// +optional
+	IngressClass string `json:"ingressClass,omitempty"`
+	// ClassListenPorts contains the ports of the IngressClass the server
+	// listens on instead of the ports of the controller
+	// +optional
+	ClassListenPorts *ClassListenPorts `json:"classListenPorts,omitempty"`
+}
+
+// ClassListenPorts describes the ports the servers of an IngressClass
+// listen on
+type ClassListenPorts struct {
+	HTTP  int `json:"http"`
+	HTTPS int `json:"https"`
+}
+
+// Reasons for a server to use the default certificate
+const (
+	// SSLCertFallbackNoSecretName indicates the TLS section does not name a Secret
+	SSLCertFallbackNoSecretName = "no-secret-name"
+	// SSLCertFallbackSecretMissing indicates the Secret does not exist
+	SSLCertFallbackSecretMissing = "secret-missing"
+	// SSLCertFallbackSecretNotSynced indicates the Secret exists but its
+	// certificate is not in the local store yet
+	SSLCertFallbackSecretNotSynced = "secret-not-synced"
+	// SSLCertFallbackSecretInvalid indicates the Secret does not contain a
+	// valid certificate and key
+	SSLCertFallbackSecretInvalid = "secret-invalid"
+	// SSLCertFallbackHostMismatch indicates the certificate is not valid for the host
+	SSLCertFallbackHostMismatch = "host-mismatch"
+)
+
+// SSLCertFallback describes a server using the default certificate
+type SSLCertFallback struct {
+	// Reason is one of the SSLCertFallback constants
+	Reason string `json:"reason"`
+	// Ingress is the namespace/name of the Ingress listing the host
+	Ingress string `json:"ingress"`
+	// Secret is the namespace/name of the Secret that cannot be used
+	Secret string `json:"secret,omitempty"`
+	// FakeCertificate indicates the default certificate is the one
+	// generated by the controller, not --default-ssl-certificate
+	FakeCertificate bool `json:"fakeCertificate"`
+}
+
+// IngressConflict describes a host and path of an Ingress that is not served
+// because other Ingresses define them too
+type IngressConflict struct {
+	// Ingress is the namespace/name of the Ingress not serving the host and path
+	Ingress string `json:"ingress"`
+	Host    string `json:"host"`
+	Path    string `json:"path"`
+	// Winner is the namespace/name of the Ingress serving the host and path,
+	// empty when none of the Ingresses serves them
+	Winner string `json:"winner,omitempty"`
+}
+
+// SyntheticProbeResult describes the result of the synthetic probe of a host
+// and path of an Ingress
+type SyntheticProbeResult struct {
+	// Ingress is the namespace/name of the Ingress defining the host and path
+	Ingress string `json:"ingress"`
+	Host    string `json:"host"`
+	Path    string `json:"path"`
+	// Success indicates the probe returned an expected status
+	Success bool `json:"success"`
+	// Duration of the request sent through NGINX
+	Duration time.Duration `json:"duration"`
 }
 
 // Location describes an URI inside a server.
@@ -358,6 +485,88 @@ type Location struct {
 	// Opentelemetry allows the global opentelemetry setting to be overridden for a location
 	// +optional
 	Opentelemetry opentelemetry.Config `json:"opentelemetry"`
+	// GraphQL enables query depth, complexity and introspection checks for
+	// locations serving a GraphQL endpoint
+	// +optional
+	GraphQL graphql.Config `json:"graphql,omitempty"`
+	// AuthCookieSession indicates if the cookies set by the authentication
+	// flow must be stored server-side when they are too big
+	// +optional
+	AuthCookieSession bool `json:"authCookieSession,omitempty"`
+	// RetryPolicy limits the retries of the requests to the backend
+	// +optional
+	RetryPolicy retrypolicy.Config `json:"retryPolicy,omitempty"`
+	// GeoAccess restricts the access to the location by country or
+	// autonomous system of the client
+	// +optional
+	GeoAccess geoaccess.Config `json:"geoAccess,omitempty"`
+	// Attribution contains the key used to sign the header asserting the
+	// client address, TLS connection and authentication of the request
+	// +optional
+	Attribution attribution.Config `json:"attribution,omitempty"`
+	// ProxyCache configures the caching of the responses of the backend
+	// in the cache zone of the Ingress
+	// +optional
+	ProxyCache proxycache.Config `json:"proxyCache,omitempty"`
+	// UpstreamSigning signs the requests sent to the upstream with
+	// AWS Signature Version 4 or HMAC
+	// +optional
+	UpstreamSigning upstreamsigning.Config `json:"upstreamSigning,omitempty"`
+	// NextUpstream retries the responses of the backend with a status code
+	// or a header asking for another endpoint
+	// +optional
+	NextUpstream nextupstream.Config `json:"nextUpstream,omitempty"`
+	// UpstreamKeepalive keeps the connections to the endpoints of the
+	// backend open in a dedicated pool
+	// +optional
+	UpstreamKeepalive upstreamkeepalive.Config `json:"upstreamKeepalive,omitempty"`
+	// RequestHeaders sets, adds or removes headers of the requests sent
+	// to the upstream
+	// +optional
+	RequestHeaders requestheaders.Config `json:"requestHeaders,omitempty"`
+	// RequestID sets how the request ID of the location is generated and
+	// propagated, instead of the generate-request-id ConfigMap option
+	// +optional
+	RequestID requestid.Config `json:"requestID,omitempty"`
+	// ResponseBodyRewrite replaces strings in the bodies of the responses
+	// of the upstream
+	// +optional
+	ResponseBodyRewrite responsebodyrewrite.Config `json:"responseBodyRewrite,omitempty"`
+	// LinkRewrite rewrites the links of an application served under a
+	// path prefix
+	// +optional
+	LinkRewrite linkrewrite.Config `json:"linkRewrite,omitempty"`
+	// ConcurrencyLimit limits the requests to the backends of the Ingress
+	// processed concurrently, queueing the requests above the limit
+	// +optional
+	ConcurrencyLimit concurrencylimit.Config `json:"concurrencyLimit,omitempty"`
+	// PathTemplate indicates if the path of the location was compiled
+	// from a path template
+	// +optional
+	PathTemplate pathtemplate.Config `json:"pathTemplate,omitempty"`
+	// PathParameters are the names of the parameters captured by the
+	// path template of the location
+	// +optional
+	PathParameters []string `json:"pathParameters,omitempty"`
+	// Chaos contains the faults injected in the requests of the location
+	// for resilience testing
+	// +optional
+	Chaos chaos.Config `json:"chaos,omitempty"`
+	// BotMitigation challenges or blocks the suspicious clients before
+	// their requests reach the backend
+	// +optional
+	BotMitigation botmitigation.Config `json:"botMitigation,omitempty"`
+	// ServerTiming adds the Server-Timing header with the upstream and
+	// NGINX timings to the responses of the location
+	// +optional
+	ServerTiming servertiming.Config `json:"serverTiming,omitempty"`
+	// FailoverOrigin is the external origin the requests are proxied to
+	// when no endpoint of the backend could serve them
+	// +optional
+	FailoverOrigin failoverorigin.Config `json:"failoverOrigin,omitempty"`
+	// LuaPlugins are the names of the Lua plugins run in the location
+	// +optional
+	LuaPlugins []string `json:"luaPlugins,omitempty"`
 }
 
 // SSLPassthroughBackend describes a SSL upstream server configured
@@ -371,6 +580,8 @@ type SSLPassthroughBackend struct {
 	Backend string `json:"namespace,omitempty"`
 	// Hostname returns the FQDN of the server
 	Hostname string `json:"hostname"`
+	// Routing defines the timeout and fallback of the connections
+	Routing sslpassthroughrouting.Config `json:"routing"`
 }
 
 // L4Service describes a L4 Ingress service.
@@ -393,6 +604,12 @@ type L4Backend struct {
 	Protocol  apiv1.Protocol     `json:"protocol"`
 	// +optional
 	ProxyProtocol ProxyProtocol `json:"proxyProtocol"`
+	// ConnectTimeout overrides the timeout to establish a connection with the endpoints
+	// +optional
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+	// ProxyTimeout overrides the proxy-stream-timeout setting
+	// +optional
+	ProxyTimeout string `json:"proxyTimeout,omitempty"`
 }
 
 // ProxyProtocol describes the proxy protocol configuration
@@ -405,7 +622,94 @@ type ProxyProtocol struct {
 type Ingress struct {
 	networking.Ingress `json:"-"`
 	ParsedAnnotations  *annotations.Ingress `json:"parsedAnnotations"`
+	// OverriddenDefaultAnnotations contains the names of the namespace
+	// default annotations the Ingress sets to a different value
+	OverriddenDefaultAnnotations []string `json:"overriddenDefaultAnnotations,omitempty"`
+	// IngressClass is the class of the Ingress handled by the controller
+	IngressClass string `json:"ingressClass,omitempty"`
 }
 
 // GeneralConfig holds the definition of lua general configuration data
 type GeneralConfig struct{}
+
+// Expansion describes the server and location blocks generated for the
+// paths of an Ingress
+type Expansion struct {
+	// Ingress is the namespace and name of the Ingress
+	Ingress string            `json:"ingress"`
+	Servers []ExpansionServer `json:"servers"`
+}
+
+// ExpansionServer is a server block containing locations of an Ingress
+type ExpansionServer struct {
+	Hostname  string              `json:"hostname"`
+	Aliases   []string            `json:"aliases,omitempty"`
+	Locations []ExpansionLocation `json:"locations"`
+}
+
+// ExpansionLocation is a location block generated for a path of an Ingress
+type ExpansionLocation struct {
+	// Path and PathType are the ones of the path in the Ingress
+	Path     string `json:"path"`
+	PathType string `json:"pathType"`
+	// Location is the modifier and the path of the location block
+	Location string `json:"location"`
+	// Match is how the path of the requests is matched: exact, prefix or regex
+	Match string `json:"match"`
+	// CaseInsensitive is true when the regular expression ignores the case
+	CaseInsensitive bool   `json:"caseInsensitive,omitempty"`
+	Backend         string `json:"backend"`
+}
+
+// Interpretation describes how the controller interprets an Ingress
+type Interpretation struct {
+	// Ingress is the namespace and name of the Ingress
+	Ingress string `json:"ingress"`
+	// Class is the class of the Ingress, empty when the controller ignores it
+	Class string `json:"class,omitempty"`
+	// Annotations are the values of the parsers of the annotations of the
+	// Ingress, by name of parser
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	// Errors are the reasons the controller rejects the Ingress or does not
+	// serve some of its paths
+	Errors []string `json:"errors,omitempty"`
+	// Warnings are the class the controller does not handle, the annotations
+	// it ignores or replaces with their default and the backends it can not use
+	Warnings []string          `json:"warnings,omitempty"`
+	Servers  []ExpansionServer `json:"servers,omitempty"`
+}
+
+// ConfigurationDiff describes the changes between the running configuration
+// and the configuration applied by a sync
+type ConfigurationDiff struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Reload is true when the changes required a reload of NGINX
+	Reload bool `json:"reload"`
+	// Checksum is the checksum of the configuration when NGINX is reloaded
+	Checksum        string                     `json:"checksum,omitempty"`
+	HostsAdded      []string                   `json:"hostsAdded,omitempty"`
+	HostsRemoved    []string                   `json:"hostsRemoved,omitempty"`
+	HostsChanged    []string                   `json:"hostsChanged,omitempty"`
+	BackendsAdded   []string                   `json:"backendsAdded,omitempty"`
+	BackendsRemoved []string                   `json:"backendsRemoved,omitempty"`
+	BackendsChanged []string                   `json:"backendsChanged,omitempty"`
+	Ingresses       []IngressConfigurationDiff `json:"ingresses,omitempty"`
+}
+
+// IngressConfigurationDiff describes an Ingress added, removed or updated
+// in the configuration
+type IngressConfigurationDiff struct {
+	// Ingress is the namespace and name of the Ingress
+	Ingress string `json:"ingress"`
+	// Change is added, removed or updated
+	Change      string           `json:"change"`
+	Annotations []AnnotationDiff `json:"annotations,omitempty"`
+}
+
+// AnnotationDiff describes an annotation added, removed or changed
+type AnnotationDiff struct {
+	Name string `json:"name"`
+	// Old is empty for an added annotation and New for a removed one
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package ingress
 
 import (
+	"slices"
+
 	"k8s.io/ingress-nginx/pkg/util/sets"
 )
 
@@ -76,6 +78,15 @@ func (c1 *Configuration) Equal(c2 *Configuration) bool {
 		}
 	}
 
+	if len(c1.LuaPlugins) != len(c2.LuaPlugins) {
+		return false
+	}
+	for i := range c1.LuaPlugins {
+		if c1.LuaPlugins[i].Name != c2.LuaPlugins[i].Name || c1.LuaPlugins[i].Checksum != c2.LuaPlugins[i].Checksum {
+			return false
+		}
+	}
+
 	return c1.BackendConfigChecksum == c2.BackendConfigChecksum
 }
 
@@ -121,6 +132,18 @@ func (b *Backend) Equal(newB *Backend) bool {
 	if b.LoadBalancing != newB.LoadBalancing {
 		return false
 	}
+	if !(&b.LoadBalanceTuning).Equal(&newB.LoadBalanceTuning) {
+		return false
+	}
+	if !(&b.UpstreamKeepalive).Equal(&newB.UpstreamKeepalive) {
+		return false
+	}
+	if !(&b.ExternalName).Equal(&newB.ExternalName) {
+		return false
+	}
+	if !(&b.SlowStart).Equal(&newB.SlowStart) {
+		return false
+	}
 
 	match := compareEndpoints(b.Endpoints, newB.Endpoints)
 	if !match {
@@ -259,6 +282,9 @@ func (tsp1 *TrafficShapingPolicy) Equal(tsp2 *TrafficShapingPolicy) bool {
 	if tsp1.Cookie != tsp2.Cookie {
 		return false
 	}
+	if !sets.StringElementsMatch(tsp1.Geo, tsp2.Geo) {
+		return false
+	}
 
 	return true
 }
@@ -277,9 +303,21 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.SSLPassthrough != s2.SSLPassthrough {
 		return false
 	}
+	if !s1.SSLPassthroughRouting.Equal(&s2.SSLPassthroughRouting) {
+		return false
+	}
 	if !s1.SSLCert.Equal(s2.SSLCert) {
 		return false
 	}
+	if s1.Dynamic != s2.Dynamic {
+		return false
+	}
+	if s1.IngressClass != s2.IngressClass {
+		return false
+	}
+	if !s1.ClassListenPorts.Equal(s2.ClassListenPorts) {
+		return false
+	}
 
 	if len(s1.Aliases) != len(s2.Aliases) {
 		return false
@@ -334,6 +372,18 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	return true
 }
 
+// Equal tests for equality between two ClassListenPorts types
+func (p1 *ClassListenPorts) Equal(p2 *ClassListenPorts) bool {
+	if p1 == p2 {
+		return true
+	}
+	if p1 == nil || p2 == nil {
+		return false
+	}
+
+	return *p1 == *p2
+}
+
 // Equal tests for equality between two Location types
 //
 //nolint:gocyclo // Ignore function complexity error
@@ -467,6 +517,74 @@ func (l1 *Location) Equal(l2 *Location) bool {
 		return false
 	}
 
+	if !(&l1.GraphQL).Equal(&l2.GraphQL) {
+		return false
+	}
+
+	if l1.AuthCookieSession != l2.AuthCookieSession {
+		return false
+	}
+
+	if !(&l1.RetryPolicy).Equal(&l2.RetryPolicy) {
+		return false
+	}
+
+	if !(&l1.GeoAccess).Equal(&l2.GeoAccess) {
+		return false
+	}
+
+	if !(&l1.Attribution).Equal(&l2.Attribution) {
+		return false
+	}
+	if !(&l1.ProxyCache).Equal(&l2.ProxyCache) {
+		return false
+	}
+	if !(&l1.UpstreamSigning).Equal(&l2.UpstreamSigning) {
+		return false
+	}
+	if !(&l1.NextUpstream).Equal(&l2.NextUpstream) {
+		return false
+	}
+	if !(&l1.UpstreamKeepalive).Equal(&l2.UpstreamKeepalive) {
+		return false
+	}
+	if !(&l1.RequestHeaders).Equal(&l2.RequestHeaders) {
+		return false
+	}
+	if !(&l1.RequestID).Equal(&l2.RequestID) {
+		return false
+	}
+	if !(&l1.ResponseBodyRewrite).Equal(&l2.ResponseBodyRewrite) {
+		return false
+	}
+	if !(&l1.LinkRewrite).Equal(&l2.LinkRewrite) {
+		return false
+	}
+	if !(&l1.ConcurrencyLimit).Equal(&l2.ConcurrencyLimit) {
+		return false
+	}
+	if !(&l1.PathTemplate).Equal(&l2.PathTemplate) {
+		return false
+	}
+	if !slices.Equal(l1.PathParameters, l2.PathParameters) {
+		return false
+	}
+	if !(&l1.Chaos).Equal(&l2.Chaos) {
+		return false
+	}
+	if !(&l1.BotMitigation).Equal(&l2.BotMitigation) {
+		return false
+	}
+	if !(&l1.ServerTiming).Equal(&l2.ServerTiming) {
+		return false
+	}
+	if !(&l1.FailoverOrigin).Equal(&l2.FailoverOrigin) {
+		return false
+	}
+	if !slices.Equal(l1.LuaPlugins, l2.LuaPlugins) {
+		return false
+	}
+
 	return true
 }
 
@@ -487,6 +605,9 @@ func (ptb1 *SSLPassthroughBackend) Equal(ptb2 *SSLPassthroughBackend) bool {
 	if ptb1.Port != ptb2.Port {
 		return false
 	}
+	if !ptb1.Routing.Equal(&ptb2.Routing) {
+		return false
+	}
 
 	if ptb1.Service != ptb2.Service {
 		if ptb1.Service == nil || ptb2.Service == nil {
@@ -544,6 +665,12 @@ func (l4b1 *L4Backend) Equal(l4b2 *L4Backend) bool {
 	if l4b1.ProxyProtocol != l4b2.ProxyProtocol {
 		return false
 	}
+	if l4b1.ConnectTimeout != l4b2.ConnectTimeout {
+		return false
+	}
+	if l4b1.ProxyTimeout != l4b2.ProxyTimeout {
+		return false
+	}
 
 	return true
 }
@@ -43,7 +43,11 @@ func (in *Backend) DeepCopyInto(out *Backend) {
 	}
 	in.SessionAffinity.DeepCopyInto(&out.SessionAffinity)
 	out.UpstreamHashBy = in.UpstreamHashBy
-	out.TrafficShapingPolicy = in.TrafficShapingPolicy
+	out.LoadBalanceTuning = in.LoadBalanceTuning
+	out.UpstreamKeepalive = in.UpstreamKeepalive
+	out.ExternalName = in.ExternalName
+	out.SlowStart = in.SlowStart
+	in.TrafficShapingPolicy.DeepCopyInto(&out.TrafficShapingPolicy)
 	if in.AlternativeBackends != nil {
 		in, out := &in.AlternativeBackends, &out.AlternativeBackends
 		*out = make([]string, len(*in))
@@ -134,6 +138,11 @@ func (in *SessionAffinityConfig) DeepCopy() *SessionAffinityConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TrafficShapingPolicy) DeepCopyInto(out *TrafficShapingPolicy) {
 	*out = *in
+	if in.Geo != nil {
+		in, out := &in.Geo, &out.Geo
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
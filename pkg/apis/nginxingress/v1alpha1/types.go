@@ -0,0 +1,140 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// RouteConditionAccepted indicates whether the controller exposes the route
+	RouteConditionAccepted = "Accepted"
+
+	// RouteReasonAccepted is used when the route is exposed
+	RouteReasonAccepted = "Accepted"
+	// RouteReasonPortConflict is used when the port is reserved or used by another route
+	RouteReasonPortConflict = "PortConflict"
+	// RouteReasonInvalid is used when the route contains an invalid configuration
+	RouteReasonInvalid = "Invalid"
+	// RouteReasonBackendNotFound is used when the service or its endpoints do not exist
+	RouteReasonBackendNotFound = "BackendNotFound"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TCPRoute exposes a TCP port of a service on a port of the ingress controller
+type TCPRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RouteSpec `json:"spec"`
+	// +optional
+	Status RouteStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TCPRouteList is a list of TCPRoute
+type TCPRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []TCPRoute `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UDPRoute exposes a UDP port of a service on a port of the ingress controller
+type UDPRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RouteSpec `json:"spec"`
+	// +optional
+	Status RouteStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UDPRouteList is a list of UDPRoute
+type UDPRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []UDPRoute `json:"items"`
+}
+
+// RouteSpec describes the port exposed by the ingress controller and the
+// service receiving the traffic
+type RouteSpec struct {
+	// IngressClassName is the name of the IngressClass of the ingress
+	// controller exposing the route. Without it, the route is only exposed
+	// by the ingress controllers watching the Ingresses without class.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+	// Port is the port the ingress controller listens on
+	Port int32 `json:"port"`
+	// Backend is the service receiving the traffic of the port
+	Backend RouteBackend `json:"backend"`
+	// ProxyProtocol configures the PROXY protocol. Only valid for TCP routes.
+	// +optional
+	ProxyProtocol *ProxyProtocol `json:"proxyProtocol,omitempty"`
+	// Timeouts overrides the proxy-stream-* timeouts of the ConfigMap
+	// +optional
+	Timeouts *RouteTimeouts `json:"timeouts,omitempty"`
+}
+
+// RouteBackend references a port of a service in the namespace of the route
+type RouteBackend struct {
+	// ServiceName is the name of the service
+	ServiceName string `json:"serviceName"`
+	// ServicePort is the number or the name of the port of the service
+	ServicePort intstr.IntOrString `json:"servicePort"`
+}
+
+// ProxyProtocol configures the PROXY protocol of a TCP route
+type ProxyProtocol struct {
+	// Decode expects the PROXY protocol header in the connections of the clients
+	// +optional
+	Decode bool `json:"decode,omitempty"`
+	// Encode sends the PROXY protocol header to the service
+	// +optional
+	Encode bool `json:"encode,omitempty"`
+}
+
+// RouteTimeouts configures the timeouts of a route
+type RouteTimeouts struct {
+	// Connect is the timeout to establish a connection with the service
+	// +optional
+	Connect *metav1.Duration `json:"connect,omitempty"`
+	// Idle is the timeout between two successive read or write operations
+	// +optional
+	Idle *metav1.Duration `json:"idle,omitempty"`
+}
+
+// RouteStatus describes the state of the route
+type RouteStatus struct {
+	// ListenerPort is the port allocated to the route by the ingress controller
+	// +optional
+	ListenerPort int32 `json:"listenerPort,omitempty"`
+	// Conditions describe the state of the route
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
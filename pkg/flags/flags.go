@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"time"
 
@@ -101,6 +102,23 @@ The key in the map indicates the external port to be used. The value is a
 reference to a Service in the form "namespace/name:port", where "port" can
 either be a port name or number.`)
 
+		defaultAnnotationsConfigMapName = flags.String("default-annotations-configmap", "",
+			`Name of the ConfigMap containing the default annotations of each namespace.
+The key in the map has the form "<namespace>.<annotation>", where the annotation
+name does not include the annotations prefix. The defaults are merged into every
+Ingress of the namespace and can be overridden by the Ingress itself, unless they
+are listed in the key "<namespace>._enforced" as a comma separated list.`)
+
+		drainedEndpointsConfigMapName = flags.String("drained-endpoints-configmap", "",
+			`Name of the ConfigMap containing the endpoints drained by the endpoint drain API, in the form "namespace/name".
+The drained endpoints are removed from the upstreams of all the replicas of the controller until they expire.`)
+
+		metricsLabelsConfigMapName = flags.String("metrics-labels-configmap", "",
+			`Name of the ConfigMap selecting the labels of the request metrics, in the form "namespace/name".
+The key in the map is a namespace or "ingressclass.<name>" and the value a comma separated list of the labels
+host, path, method, status and status-class reported for the requests of its Ingresses. The labels that are not
+listed are reported empty. The labels of the namespace of an Ingress take precedence over those of its IngressClass.`)
+
 		resyncPeriod = flags.Duration("sync-period", 0,
 			`Period at which the controller forces the repopulation of its local object stores. Disabled by default.`)
 
@@ -167,6 +185,13 @@ Requires the update-status parameter.`)
 Certificates uploaded to Kubernetes must have the "Authority Information Access" X.509 v3
 extension for this to succeed.`)
 
+		enableCertificateDiscovery = flags.Bool("enable-certificate-discovery", false,
+			`Uses the certificates of the Secrets labeled with cert.ingress.kubernetes.io/domain=<domain>, like example.com,
+for the hosts of the domain and its subdomains whose Ingresses do not contain a TLS section.`)
+		certificateDiscoveryCAFile = flags.String("certificate-discovery-ca-file", "",
+			`Path of a file containing the CA certificates the chain of the discovered certificates is verified against,
+instead of the system CA certificates.`)
+
 		syncRateLimit = flags.Float32("sync-rate-limit", 0.3,
 			`Define the sync frequency upper limit`)
 
@@ -182,6 +207,20 @@ Requires the update-status parameter.`)
 			`Export metrics per-host even if the host is not defined in an ingress. Requires --metrics-per-host to be set to true.`)
 		reportStatusClasses = flags.Bool("report-status-classes", false,
 			`Use status classes (2xx, 3xx, 4xx and 5xx) instead of status codes in metrics.`)
+		metricsTLSSecret = flags.String("metrics-tls-secret", "",
+			`Secret containing the certificate (tls.crt and tls.key) of the metrics endpoint, in the form "namespace/name".
+When set, the metrics are served over TLS on the metrics-port instead of the healthz port.`)
+		metricsPort = flags.Int("metrics-port", 10264,
+			`Port to use for the metrics endpoint served over TLS. Requires the metrics-tls-secret parameter.`)
+		metricsClientCert = flags.Bool("metrics-client-cert", false,
+			`Requires the scrapers of the metrics endpoint to present a certificate signed by the ca.crt of the metrics-tls-secret.`)
+		metricsTokenFile = flags.String("metrics-token-file", "",
+			`Path of the file containing the bearer token required to scrape the metrics endpoint.`)
+		enableTenantMetrics = flags.Bool("enable-tenant-metrics", false,
+			`Exposes under /metrics/tenant the metrics of the namespace of the ServiceAccount scraping them, authenticated
+with its token by a TokenReview. Requires the enable-metrics parameter.`)
+		tenantMetricsAudience = flags.String("tenant-metrics-audience", "ingress-nginx-tenant-metrics",
+			`Audience the ServiceAccount tokens scraping /metrics/tenant must be issued for.`)
 
 		timeBuckets          = flags.Float64Slice("time-buckets", prometheus.DefBuckets, "Set of buckets which will be used for prometheus histogram metrics such as RequestTime, ResponseTime.")
 		lengthBuckets        = flags.Float64Slice("length-buckets", prometheus.LinearBuckets(10, 10, 10), "Set of buckets which will be used for prometheus histogram metrics such as RequestLength, ResponseLength.")
@@ -199,6 +238,11 @@ Requires the update-status parameter.`)
 		healthzPort   = flags.Int("healthz-port", 10254, "Port to use for the healthz endpoint.")
 		healthzHost   = flags.String("healthz-host", "", "Address to bind the healthz endpoint.")
 
+		clientIPAgentHTTPPort = flags.Int("client-ip-agent-http-port", 0,
+			`Port receiving the HTTP connections of a node-local agent prefixed with a PROXY protocol header. Disabled when 0.`)
+		clientIPAgentHTTPSPort = flags.Int("client-ip-agent-https-port", 0,
+			`Port receiving the HTTPS connections of a node-local agent prefixed with a PROXY protocol header. Disabled when 0.`)
+
 		disableCatchAll = flags.Bool("disable-catch-all", false,
 			`Disable support for catch-all Ingresses.`)
 
@@ -211,6 +255,10 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 			`The path of the validating webhook key PEM.`)
 		disableFullValidationTest = flags.Bool("disable-full-test", false,
 			`Disable full test of all merged ingresses at the admission stage and tests the template of the ingress being created or updated  (full test of all ingresses is enabled by default).`)
+		validationWebhookExpansion = flags.Bool("validating-webhook-expansion-report", false,
+			`Add the server and location blocks an ingress expands to, as JSON, to the ingress-expansion audit annotation of the validating webhook response.`)
+		validationWebhookConflicts = flags.String("validating-webhook-conflicts", controller.ConflictsReject,
+			`Action of the validating webhook, reject or warn, on an ingress conflicting with other ingresses: a host, path and path type or a server alias already defined, or a canary without a primary ingress.`)
 
 		statusPort = flags.Int("status-port", 10246, `Port to use for the lua HTTP endpoint configuration.`)
 		streamPort = flags.Int("stream-port", 10247, "Port to use for the lua TCP/UDP endpoint configuration.")
@@ -233,6 +281,125 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 		disableSyncEvents = flags.Bool("disable-sync-events", false, "Disables the creation of 'Sync' event resources")
 
 		enableTopologyAwareRouting = flags.Bool("enable-topology-aware-routing", false, "Enable topology aware routing feature, needs service object annotation service.kubernetes.io/topology-mode sets to auto.")
+
+		enableConfigurationAPI = flags.Bool("enable-configuration-api", false,
+			`Exposes the running configuration (servers, locations and backends) as JSON under /api/v1/configuration in the healthz port,
+and the bytes received and sent by every Ingress by hour under /api/v1/usage/ingresses.
+Requires the configuration-api-token-file parameter.`)
+		configurationAPITokenFile = flags.String("configuration-api-token-file", "",
+			`Path of the file containing the bearer token required to access the configuration API.`)
+
+		configurationHandoffSocket = flags.String("configuration-handoff-socket", "",
+			`Path of the unix socket the running configuration is handed off on. The directory of the socket must be a volume
+shared by the controller being replaced and its replacement, like a hostPath volume mounted at the same path by the
+controllers of the node, and only writable by their user. At start NGINX is configured with the configuration
+fetched from the controller listening on it, instead of an empty configuration, avoiding the unavailable backends of
+the initial sync. Once synced the controller hands its configuration off on the socket, readable and writable by its
+user only (0600) as it contains the private keys of the SSL certificates. The state of the Lua balancer, like the
+ewma scores, the slow start and the connect backoff of the endpoints, is not handed off.`)
+		configurationHandoffTimeout = flags.Duration("configuration-handoff-timeout", 10*time.Second,
+			`Time to wait for the configuration handoff before starting with an empty configuration.`)
+
+		enableCachePurgeAPI = flags.Bool("enable-cache-purge-api", false,
+			`Exposes an API removing the responses cached by an Ingress under /api/v1/cache/purge in the healthz port.
+Requires the cache-purge-api-token-file parameter.`)
+		cachePurgeAPITokenFile = flags.String("cache-purge-api-token-file", "",
+			`Path of the file containing the bearer token required to access the cache purge API.`)
+
+		enableEndpointDrainAPI = flags.Bool("enable-endpoint-drain-api", false,
+			`Exposes an API draining endpoints from the upstreams of all the replicas under /api/v1/endpoints/drain in the healthz port.
+Requires the endpoint-drain-api-token-file and drained-endpoints-configmap parameters.`)
+		endpointDrainAPITokenFile = flags.String("endpoint-drain-api-token-file", "",
+			`Path of the file containing the bearer token required to access the endpoint drain API.`)
+
+		reloadFreezeWindows = flags.String("reload-freeze-windows", "",
+			`Comma separated list of time ranges in the form start/end, with times in RFC3339 format, during which the
+configuration changes are not applied, except the endpoints of the upstreams and the certificate renewals.
+The changes are applied when the freeze ends.`)
+		reloadFreezeConfigMapName = flags.String("reload-freeze-configmap", "",
+			`Name of the ConfigMap containing the reload freeze windows declared by the reload freeze API, in the form "namespace/name".
+The freeze windows apply to all the replicas of the controller.`)
+		enableReloadFreezeAPI = flags.Bool("enable-reload-freeze-api", false,
+			`Exposes an API declaring reload freeze windows for all the replicas under /api/v1/reload/freeze in the healthz port.
+Requires the reload-freeze-api-token-file and reload-freeze-configmap parameters.`)
+		reloadFreezeAPITokenFile = flags.String("reload-freeze-api-token-file", "",
+			`Path of the file containing the bearer token required to access the reload freeze API.`)
+
+		enableChangeApproval = flags.Bool("enable-change-approval", false,
+			`Stages the high-impact changes of the Ingresses, removing a host, an authentication or deleting the Ingress,
+and the changes of snippet annotations, serving the previous version of the Ingress until the change is approved with
+the approve-change annotation or the change approval API. Requires the change-approval-configmap parameter.`)
+		changeApprovalTimeout = flags.Duration("change-approval-timeout", 0,
+			`Time after which a staged change is applied without approval. 0 waits for an approval.`)
+		changeApprovalConfigMapName = flags.String("change-approval-configmap", "",
+			`Name of the ConfigMap containing the changes staged and approved by all the replicas of the controller,
+in the form "namespace/name".`)
+		enableChangeApprovalAPI = flags.Bool("enable-change-approval-api", false,
+			`Exposes an API approving the changes staged by the replicas under /api/v1/changes in the healthz port.
+Requires the enable-change-approval and change-approval-api-token-file parameters.`)
+		changeApprovalAPITokenFile = flags.String("change-approval-api-token-file", "",
+			`Path of the file containing the bearer token required to access the change approval API.`)
+
+		luaPluginsConfigMapName = flags.String("lua-plugins-configmap", "",
+			`Name of the ConfigMap containing the code of the Lua plugins, in the form "namespace/name". The key in the
+map is "<name>.lua". Only the plugins listed with their checksum in the lua-plugins option of the configuration
+ConfigMap are loaded.`)
+
+		enableErrorPages = flags.Bool("enable-error-pages", false,
+			`Serves templated error pages from the controller, in JSON or HTML depending on the Accept header of the client,
+for the requests sent to the default backend. Can not be used with --default-backend-service.`)
+		errorPagesTemplates = flags.String("error-pages-templates", "",
+			`Directory containing the Go templates of the error pages, named <status code>.html, <status code>.json,
+default.html and default.json. Built-in templates are used for the missing default templates.`)
+		errorPagesPort = flags.Int("error-pages-port", 10253, "Port to use internally for the error pages served by the controller.")
+
+		spiffeWorkloadAPISocket = flags.String("spiffe-workload-api-socket", "",
+			`Path of the socket of the SPIFFE Workload API, like /run/spire/sockets/agent.sock. The X.509 SVIDs it provides
+to the controller are kept up to date and can be presented to the upstreams with the annotation proxy-ssl-spiffe-id.`)
+
+		enableRouteRegressionCheck = flags.Bool("enable-route-regression-check", false,
+			`Replays a sample of the requests recently served by Ingresses against a new configuration before reloading NGINX,
+and holds the reload when some of them would be sent to the default backend. The endpoints and certificates are still
+updated dynamically. The routes of the Ingresses updated since the last reload are not checked. Requires the
+enable-metrics parameter.`)
+		routeRegressionSamples = flags.Int("route-regression-samples", 1000,
+			`Number of distinct requests (method, host and path) sampled for the route regression check.`)
+		routeRegressionTimeout = flags.Duration("route-regression-timeout", 15*time.Minute,
+			`Maximum time the route regression check holds the reload of a new configuration, the configuration is applied
+once it expires. 0 holds the reload until the route regressions are solved.`)
+
+		syntheticProbeInterval = flags.Duration("synthetic-probe-interval", 0,
+			`Interval of the synthetic probes sent through NGINX to the hosts and paths of the Ingresses with the synthetic-probe
+annotation, reported by the synthetic_probe_success and synthetic_probe_duration_seconds metrics. 0 disables the probes.
+Requires the enable-metrics parameter.`)
+		syntheticProbeTimeout = flags.Duration("synthetic-probe-timeout", 5*time.Second,
+			`Timeout of the synthetic probes.`)
+
+		configSnapshots = flags.Int("config-snapshots", 0,
+			`Number of the last configurations applied successfully kept to roll back the Ingresses NGINX rejects. When a new
+configuration fails the NGINX test, the Ingresses breaking it are found by bisection and replaced by their version
+in the last snapshot containing them, or ignored, until they are updated. 0 disables the rollback.`)
+
+		shadowMode = flags.Bool("shadow-mode", false,
+			`Builds and validates the configuration of all the Ingresses without updating their status, taking part in the
+leader election or creating events, to test a new version of the controller before sending traffic to it.
+Requires the http-port and https-port parameters to serve the configuration on alternate ports.`)
+
+		configurationDiffFile = flags.String("configuration-diff-file", "",
+			`Path of a file the changes of the configuration applied by every sync are appended to, as a line of JSON containing
+the hosts and backends added, removed or changed and the Ingresses added, removed or updated with their annotation changes.`)
+		configurationDiffWebhook = flags.String("configuration-diff-webhook", "",
+			`URL the changes of the configuration applied by every sync are sent to, as JSON in a POST request.`)
+		configurationDiffEvents = flags.Bool("configuration-diff-events", false,
+			`Creates a ConfigurationDiff event of the controller pod summarizing the changes of the configuration applied by every sync.`)
+
+		enableStreamRoutes = flags.Bool("enable-stream-routes", false,
+			`Exposes TCP and UDP services declared using TCPRoute and UDPRoute resources of the nginx.ingress.kubernetes.io API group.
+The custom resource definitions must be installed in the cluster.`)
+
+		enableChaosInjection = flags.Bool("enable-chaos-injection", false,
+			`Allows the annotations chaos-abort-percent, chaos-delay-ms and chaos-delay-percent to inject faults in the requests
+to the backends, for resilience testing. The Ingresses using them are rejected when disabled.`)
 	)
 
 	flags.StringVar(&nginx.MaxmindMirror, "maxmind-mirror", "", `Maxmind mirror url (example: http://geoip.local/databases.`)
@@ -241,6 +408,8 @@ https://blog.maxmind.com/2019/12/significant-changes-to-accessing-and-using-geol
 	flags.StringVar(&nginx.MaxmindEditionIDs, "maxmind-edition-ids", "GeoLite2-City,GeoLite2-ASN", `Maxmind edition ids to download GeoLite2 Databases.`)
 	flags.IntVar(&nginx.MaxmindRetriesCount, "maxmind-retries-count", 1, "Number of attempts to download the GeoIP DB.")
 	flags.DurationVar(&nginx.MaxmindRetriesTimeout, "maxmind-retries-timeout", time.Second*0, "Maxmind downloading delay between 1st and 2nd attempt, 0s - do not retry to download if something went wrong.")
+	flags.DurationVar(&nginx.MaxmindRefreshInterval, "maxmind-refresh-interval", time.Second*0, `Interval between the downloads of the Maxmind databases, 0s - download them only at startup.
+The updated databases are loaded by NGINX without a reload.`)
 
 	flags.AddGoFlagSet(flag.CommandLine)
 	if err := flags.Parse(os.Args); err != nil {
@@ -299,6 +468,14 @@ https://blog.maxmind.com/2019/12/significant-changes-to-accessing-and-using-geol
 		return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --ssl-passthrough-proxy-port", *sslProxyPort)
 	}
 
+	if *clientIPAgentHTTPPort > 0 && !ing_net.IsPortAvailable(*clientIPAgentHTTPPort) {
+		return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --client-ip-agent-http-port", *clientIPAgentHTTPPort)
+	}
+
+	if *clientIPAgentHTTPSPort > 0 && !ing_net.IsPortAvailable(*clientIPAgentHTTPSPort) {
+		return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --client-ip-agent-https-port", *clientIPAgentHTTPSPort)
+	}
+
 	if *publishSvc != "" && *publishStatusAddress != "" {
 		return false, nil, fmt.Errorf("flags --publish-service and --publish-status-address are mutually exclusive")
 	}
@@ -326,6 +503,116 @@ https://blog.maxmind.com/2019/12/significant-changes-to-accessing-and-using-geol
 		return false, nil, errors.New("--metrics-per-undefined-host=true must be passed with --metrics-per-host=true")
 	}
 
+	if *metricsClientCert && *metricsTLSSecret == "" {
+		return false, nil, errors.New("--metrics-client-cert=true must be passed with --metrics-tls-secret")
+	}
+
+	if *enableTenantMetrics && !*enableMetrics {
+		return false, nil, errors.New("--enable-tenant-metrics=true must be passed with --enable-metrics=true")
+	}
+
+	if *metricsTLSSecret != "" {
+		if *metricsPort == *healthzPort {
+			return false, nil, errors.New("--metrics-port must be different from --healthz-port")
+		}
+		if !ing_net.IsPortAvailable(*metricsPort) {
+			return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --metrics-port", *metricsPort)
+		}
+	}
+
+	if *enableConfigurationAPI && *configurationAPITokenFile == "" {
+		return false, nil, errors.New("--enable-configuration-api=true must be passed with --configuration-api-token-file")
+	}
+
+	if *enableCachePurgeAPI && *cachePurgeAPITokenFile == "" {
+		return false, nil, errors.New("--enable-cache-purge-api=true must be passed with --cache-purge-api-token-file")
+	}
+
+	if *enableEndpointDrainAPI && (*endpointDrainAPITokenFile == "" || *drainedEndpointsConfigMapName == "") {
+		return false, nil, errors.New("--enable-endpoint-drain-api=true must be passed with --endpoint-drain-api-token-file and --drained-endpoints-configmap")
+	}
+
+	var freezeWindows []controller.FreezeWindow
+	if *reloadFreezeWindows != "" {
+		var err error
+		freezeWindows, err = controller.ParseFreezeWindows(*reloadFreezeWindows)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid --reload-freeze-windows: %w", err)
+		}
+	}
+
+	if *enableReloadFreezeAPI && (*reloadFreezeAPITokenFile == "" || *reloadFreezeConfigMapName == "") {
+		return false, nil, errors.New("--enable-reload-freeze-api=true must be passed with --reload-freeze-api-token-file and --reload-freeze-configmap")
+	}
+
+	if *enableChangeApproval && *changeApprovalConfigMapName == "" {
+		return false, nil, errors.New("--enable-change-approval=true must be passed with --change-approval-configmap")
+	}
+
+	if *changeApprovalTimeout < 0 {
+		return false, nil, fmt.Errorf("invalid value %v for --change-approval-timeout, it must be 0 or greater", *changeApprovalTimeout)
+	}
+
+	if *enableChangeApprovalAPI && (!*enableChangeApproval || *changeApprovalAPITokenFile == "") {
+		return false, nil, errors.New("--enable-change-approval-api=true must be passed with --enable-change-approval and --change-approval-api-token-file")
+	}
+
+	if *enableErrorPages && *defaultSvc != "" {
+		return false, nil, errors.New("flags --enable-error-pages and --default-backend-service are mutually exclusive")
+	}
+
+	if *enableErrorPages && !ing_net.IsPortAvailable(*errorPagesPort) {
+		return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --error-pages-port", *errorPagesPort)
+	}
+
+	if *enableRouteRegressionCheck && !*enableMetrics {
+		return false, nil, errors.New("--enable-route-regression-check=true must be passed with --enable-metrics=true")
+	}
+
+	if *routeRegressionTimeout < 0 {
+		return false, nil, errors.New("--route-regression-timeout must not be negative")
+	}
+
+	if *enableRouteRegressionCheck && *routeRegressionSamples <= 0 {
+		return false, nil, fmt.Errorf("invalid value %d for --route-regression-samples, it must be greater than 0", *routeRegressionSamples)
+	}
+
+	if *syntheticProbeInterval < 0 || *syntheticProbeTimeout <= 0 {
+		return false, nil, errors.New("--synthetic-probe-interval must not be negative and --synthetic-probe-timeout must be greater than 0")
+	}
+
+	if *syntheticProbeInterval > 0 && !*enableMetrics {
+		return false, nil, errors.New("--synthetic-probe-interval must be passed with --enable-metrics=true")
+	}
+
+	if *configSnapshots < 0 {
+		return false, nil, fmt.Errorf("invalid value %d for --config-snapshots, it must be 0 or greater", *configSnapshots)
+	}
+
+	if *validationWebhookConflicts != controller.ConflictsReject && *validationWebhookConflicts != controller.ConflictsWarn {
+		return false, nil, fmt.Errorf("invalid value %q for --validating-webhook-conflicts, it must be %q or %q",
+			*validationWebhookConflicts, controller.ConflictsReject, controller.ConflictsWarn)
+	}
+
+	if *configurationDiffWebhook != "" {
+		if u, err := url.Parse(*configurationDiffWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return false, nil, fmt.Errorf("invalid value %q for --configuration-diff-webhook, it must be an http or https URL", *configurationDiffWebhook)
+		}
+	}
+
+	if *shadowMode && (!flags.Changed("http-port") || !flags.Changed("https-port")) {
+		return false, nil, errors.New("--shadow-mode=true must be passed with --http-port and --https-port")
+	}
+
+	if *shadowMode {
+		klog.InfoS("Running in shadow mode, the status of the Ingresses will not be updated and the events will not be created")
+		*updateStatus = false
+		*updateStatusOnShutdown = false
+		*disableLeaderElection = true
+		*disableSyncEvents = true
+		*configurationDiffEvents = false
+	}
+
 	if *electionTTL <= 0 {
 		*electionTTL = 30 * time.Second
 	}
@@ -339,50 +626,100 @@ https://blog.maxmind.com/2019/12/significant-changes-to-accessing-and-using-geol
 	ngx_config.EnableSSLChainCompletion = *enableSSLChainCompletion
 
 	config := &controller.Configuration{
-		APIServerHost:               *apiserverHost,
-		KubeConfigFile:              *kubeConfigFile,
-		UpdateStatus:                *updateStatus,
-		ElectionID:                  *electionID,
-		ElectionTTL:                 *electionTTL,
-		EnableProfiling:             *profiling,
-		EnableMetrics:               *enableMetrics,
-		MetricsPerHost:              *metricsPerHost,
-		MetricsPerUndefinedHost:     *metricsPerUndefinedHost,
-		MetricsBuckets:              histogramBuckets,
-		MetricsBucketFactor:         *bucketFactor,
-		MetricsMaxBuckets:           *maxBuckets,
-		ReportStatusClasses:         *reportStatusClasses,
-		ExcludeSocketMetrics:        *excludeSocketMetrics,
-		MonitorMaxBatchSize:         *monitorMaxBatchSize,
-		DisableServiceExternalName:  *disableServiceExternalName,
-		EnableSSLPassthrough:        *enableSSLPassthrough,
-		DisableLeaderElection:       *disableLeaderElection,
-		ResyncPeriod:                *resyncPeriod,
-		DefaultService:              *defaultSvc,
-		Namespace:                   *watchNamespace,
-		WatchNamespaceSelector:      namespaceSelector,
-		ConfigMapName:               *configMap,
-		TCPConfigMapName:            *tcpConfigMapName,
-		UDPConfigMapName:            *udpConfigMapName,
-		DisableFullValidationTest:   *disableFullValidationTest,
-		DefaultSSLCertificate:       *defSSLCertificate,
-		DeepInspector:               *deepInspector,
-		PublishService:              *publishSvc,
-		PublishStatusAddress:        *publishStatusAddress,
-		UpdateStatusOnShutdown:      *updateStatusOnShutdown,
-		ShutdownGracePeriod:         *shutdownGracePeriod,
-		PostShutdownGracePeriod:     *postShutdownGracePeriod,
-		UseNodeInternalIP:           *useNodeInternalIP,
-		SyncRateLimit:               *syncRateLimit,
-		HealthCheckHost:             *healthzHost,
-		DynamicConfigurationRetries: *dynamicConfigurationRetries,
-		EnableTopologyAwareRouting:  *enableTopologyAwareRouting,
+		APIServerHost:                   *apiserverHost,
+		KubeConfigFile:                  *kubeConfigFile,
+		UpdateStatus:                    *updateStatus,
+		ElectionID:                      *electionID,
+		ElectionTTL:                     *electionTTL,
+		EnableProfiling:                 *profiling,
+		EnableMetrics:                   *enableMetrics,
+		MetricsPerHost:                  *metricsPerHost,
+		MetricsPerUndefinedHost:         *metricsPerUndefinedHost,
+		MetricsBuckets:                  histogramBuckets,
+		MetricsBucketFactor:             *bucketFactor,
+		MetricsMaxBuckets:               *maxBuckets,
+		ReportStatusClasses:             *reportStatusClasses,
+		ExcludeSocketMetrics:            *excludeSocketMetrics,
+		MonitorMaxBatchSize:             *monitorMaxBatchSize,
+		DisableServiceExternalName:      *disableServiceExternalName,
+		EnableSSLPassthrough:            *enableSSLPassthrough,
+		DisableLeaderElection:           *disableLeaderElection,
+		ResyncPeriod:                    *resyncPeriod,
+		DefaultService:                  *defaultSvc,
+		Namespace:                       *watchNamespace,
+		WatchNamespaceSelector:          namespaceSelector,
+		ConfigMapName:                   *configMap,
+		TCPConfigMapName:                *tcpConfigMapName,
+		UDPConfigMapName:                *udpConfigMapName,
+		DefaultAnnotationsConfigMapName: *defaultAnnotationsConfigMapName,
+		DrainedEndpointsConfigMapName:   *drainedEndpointsConfigMapName,
+		MetricsLabelsConfigMapName:      *metricsLabelsConfigMapName,
+		ReloadFreezeConfigMapName:       *reloadFreezeConfigMapName,
+		DisableFullValidationTest:       *disableFullValidationTest,
+		ValidationWebhookConflicts:      *validationWebhookConflicts,
+		ValidationWebhookExpansion:      *validationWebhookExpansion,
+		DefaultSSLCertificate:           *defSSLCertificate,
+		DeepInspector:                   *deepInspector,
+		PublishService:                  *publishSvc,
+		PublishStatusAddress:            *publishStatusAddress,
+		UpdateStatusOnShutdown:          *updateStatusOnShutdown,
+		ShutdownGracePeriod:             *shutdownGracePeriod,
+		PostShutdownGracePeriod:         *postShutdownGracePeriod,
+		UseNodeInternalIP:               *useNodeInternalIP,
+		SyncRateLimit:                   *syncRateLimit,
+		HealthCheckHost:                 *healthzHost,
+		DynamicConfigurationRetries:     *dynamicConfigurationRetries,
+		EnableTopologyAwareRouting:      *enableTopologyAwareRouting,
+		EnableConfigurationAPI:          *enableConfigurationAPI,
+		ConfigurationAPITokenFile:       *configurationAPITokenFile,
+		ConfigurationHandoffSocket:      *configurationHandoffSocket,
+		ConfigurationHandoffTimeout:     *configurationHandoffTimeout,
+		EnableCachePurgeAPI:             *enableCachePurgeAPI,
+		CachePurgeAPITokenFile:          *cachePurgeAPITokenFile,
+		EnableStreamRoutes:              *enableStreamRoutes,
+		EnableChaosInjection:            *enableChaosInjection,
+		EnableErrorPages:                *enableErrorPages,
+		ErrorPagesTemplates:             *errorPagesTemplates,
+		SpiffeWorkloadAPISocket:         *spiffeWorkloadAPISocket,
+		EnableEndpointDrainAPI:          *enableEndpointDrainAPI,
+		EndpointDrainAPITokenFile:       *endpointDrainAPITokenFile,
+		ReloadFreezeWindows:             freezeWindows,
+		EnableReloadFreezeAPI:           *enableReloadFreezeAPI,
+		ReloadFreezeAPITokenFile:        *reloadFreezeAPITokenFile,
+		EnableCertificateDiscovery:      *enableCertificateDiscovery,
+		CertificateDiscoveryCAFile:      *certificateDiscoveryCAFile,
+		EnableChangeApproval:            *enableChangeApproval,
+		ChangeApprovalTimeout:           *changeApprovalTimeout,
+		EnableChangeApprovalAPI:         *enableChangeApprovalAPI,
+		ChangeApprovalAPITokenFile:      *changeApprovalAPITokenFile,
+		ChangeApprovalConfigMapName:     *changeApprovalConfigMapName,
+		LuaPluginsConfigMapName:         *luaPluginsConfigMapName,
+		EnableRouteRegressionCheck:      *enableRouteRegressionCheck,
+		RouteRegressionSamples:          *routeRegressionSamples,
+		RouteRegressionTimeout:          *routeRegressionTimeout,
+		SyntheticProbeInterval:          *syntheticProbeInterval,
+		SyntheticProbeTimeout:           *syntheticProbeTimeout,
+		ConfigSnapshots:                 *configSnapshots,
+		ShadowMode:                      *shadowMode,
+		ConfigurationDiffFile:           *configurationDiffFile,
+		ConfigurationDiffWebhook:        *configurationDiffWebhook,
+		ConfigurationDiffEvents:         *configurationDiffEvents,
+		MetricsTLSSecret:                *metricsTLSSecret,
+		MetricsClientCert:               *metricsClientCert,
+		MetricsTokenFile:                *metricsTokenFile,
+		EnableTenantMetrics:             *enableTenantMetrics,
+		TenantMetricsAudience:           *tenantMetricsAudience,
 		ListenPorts: &ngx_config.ListenPorts{
-			Default:  *defServerPort,
-			Health:   *healthzPort,
-			HTTP:     *httpPort,
-			HTTPS:    *httpsPort,
-			SSLProxy: *sslProxyPort,
+			Default:    *defServerPort,
+			Health:     *healthzPort,
+			HTTP:       *httpPort,
+			HTTPS:      *httpsPort,
+			SSLProxy:   *sslProxyPort,
+			ErrorPages: *errorPagesPort,
+			Metrics:    *metricsPort,
+
+			ClientIPAgentHTTP:  *clientIPAgentHTTPPort,
+			ClientIPAgentHTTPS: *clientIPAgentHTTPSPort,
 		},
 		IngressClassConfiguration: &ingressclass.Configuration{
 			Controller:         *ingressClassController,
@@ -402,6 +739,10 @@ https://blog.maxmind.com/2019/12/significant-changes-to-accessing-and-using-geol
 		config.RootCAFile = *rootCAFile
 	}
 
+	if nginx.MaxmindRefreshInterval > 0 && nginx.MaxmindLicenseKey == "" && nginx.MaxmindMirror == "" {
+		return false, nil, fmt.Errorf("flag --maxmind-refresh-interval requires --maxmind-license-key or --maxmind-mirror")
+	}
+
 	var err error
 	if nginx.MaxmindEditionIDs != "" {
 		if err := nginx.ValidateGeoLite2DBEditions(); err != nil {
@@ -111,6 +111,19 @@ func TestMaxmindRetryDownload(t *testing.T) {
 	}
 }
 
+func TestMaxmindRefreshIntervalWithoutDownload(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--publish-service", "namespace/test", "--http-port", "0", "--https-port", "0", "--maxmind-edition-ids", "GeoLite2-City", "--maxmind-refresh-interval", "24h"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
 func TestDisableLeaderElectionFlag(t *testing.T) {
 	ResetForTesting(func() { t.Fatal("Parsing failed") })
 
@@ -238,3 +251,195 @@ func TestMetricsPerUndefinedHostWithMetricsPerHostFalse(t *testing.T) {
 		t.Fatalf("Expected an error parsing flags but none returned")
 	}
 }
+
+func TestErrorPagesWithDefaultBackendService(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--enable-error-pages", "--default-backend-service", "namespace/test", "--http-port", "0", "--https-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestEndpointDrainAPIWithoutConfigMap(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--enable-endpoint-drain-api", "--endpoint-drain-api-token-file", "/etc/token", "--http-port", "0", "--https-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestReloadFreezeWindows(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--reload-freeze-windows", "2024-12-20T00:00:00Z/2025-01-02T00:00:00Z", "--http-port", "0", "--https-port", "0"}
+
+	_, conf, err := ParseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if len(conf.ReloadFreezeWindows) != 1 {
+		t.Errorf("Expected one reload freeze window but got %v", conf.ReloadFreezeWindows)
+	}
+
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--reload-freeze-windows", "2025-01-02T00:00:00Z/2024-12-20T00:00:00Z", "--http-port", "0", "--https-port", "0"}
+
+	if _, _, err := ParseFlags(); err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestReloadFreezeAPIWithoutConfigMap(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--enable-reload-freeze-api", "--reload-freeze-api-token-file", "/etc/token", "--http-port", "0", "--https-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestChangeApprovalAPIWithoutChangeApproval(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--enable-change-approval-api", "--change-approval-api-token-file", "/etc/token", "--http-port", "0", "--https-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestShadowModeWithoutPorts(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--shadow-mode", "--http-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestShadowMode(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--shadow-mode", "--http-port", "0", "--https-port", "0"}
+
+	_, conf, err := ParseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if !conf.ShadowMode {
+		t.Errorf("Expected shadow mode to be enabled")
+	}
+	if conf.UpdateStatus || conf.UpdateStatusOnShutdown {
+		t.Errorf("Expected the update of the Ingress status to be disabled")
+	}
+	if !conf.DisableLeaderElection || !conf.DisableSyncEvents {
+		t.Errorf("Expected leader election and sync events to be disabled")
+	}
+}
+
+func TestRouteRegressionCheckWithoutMetrics(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--enable-route-regression-check", "--enable-metrics=false", "--http-port", "0", "--https-port", "0"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestInvalidValidatingWebhookConflicts(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--publish-service", "namespace/test", "--http-port", "0", "--https-port", "0", "--validating-webhook-conflicts", "ignore"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestInvalidConfigurationDiffWebhook(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--configuration-diff-webhook", "ftp://audit.local/diffs"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestMetricsClientCertWithoutTLSSecret(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--metrics-client-cert"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestTenantMetricsWithoutMetrics(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--enable-tenant-metrics"}
+
+	_, _, err := ParseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestMetricsTLS(t *testing.T) {
+	ResetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--metrics-tls-secret", "ingress-nginx/metrics", "--metrics-port", "0", "--metrics-client-cert"}
+
+	_, conf, err := ParseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if conf.MetricsTLSSecret != "ingress-nginx/metrics" || !conf.MetricsClientCert || conf.ListenPorts.Metrics != 0 {
+		t.Errorf("Unexpected metrics configuration %v %v %v", conf.MetricsTLSSecret, conf.MetricsClientCert, conf.ListenPorts.Metrics)
+	}
+}
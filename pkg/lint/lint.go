@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lint interprets Ingresses read from files like the ingress-nginx
+// controller does, without a cluster, to validate them before applying them.
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller"
+	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	"k8s.io/ingress-nginx/pkg/apis/ingress"
+)
+
+// Options are the flags of the controller changing how it interprets the
+// Ingresses
+type Options struct {
+	// ConfigMap is the namespace/name of the ConfigMap of the controller,
+	// --configmap, read from the files
+	ConfigMap string
+	// Controller is the controller of the IngressClasses handled by the
+	// controller, --controller-class
+	Controller string
+	// IngressClass is the class of the Ingresses handled by the controller,
+	// --ingress-class
+	IngressClass string
+	// WatchWithoutClass is --watch-ingress-without-class
+	WatchWithoutClass bool
+}
+
+// Files returns how the controller interprets the Ingresses of the YAML and
+// JSON files of paths, and of the files of the directories of paths. The
+// IngressClasses, Services, Secrets and ConfigMaps of the files are the
+// ones the Ingresses reference, the objects of other kinds are ignored.
+func Files(paths []string, opts Options) ([]*ingress.Interpretation, error) {
+	var objects []k8sruntime.Object
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			// the files of the directories are filtered by extension, not
+			// the files named explicitly
+			if file != path && !isManifest(file) {
+				return nil
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			decoded, err := Decode(content)
+			if err != nil {
+				return fmt.Errorf("%v: %w", file, err)
+			}
+			objects = append(objects, decoded...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return Objects(objects, opts), nil
+}
+
+func isManifest(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// Decode returns the Kubernetes objects of the YAML or JSON documents of
+// content, the items of the Lists included. The documents of kinds unknown
+// to the Kubernetes client, e.g. custom resources, are ignored.
+func Decode(content []byte) ([]k8sruntime.Object, error) {
+	var objects []k8sruntime.Object
+	reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		decoded, err := decode(doc)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+func decode(doc []byte) ([]k8sruntime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+	if k8sruntime.IsNotRegisteredError(err) || k8sruntime.IsMissingKind(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.(*corev1.List)
+	if !ok {
+		return []k8sruntime.Object{obj}, nil
+	}
+
+	var objects []k8sruntime.Object
+	for _, item := range list.Items {
+		decoded, err := decode(item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// Objects returns how the controller interprets the Ingresses of objects,
+// in the namespace default when they have none
+func Objects(objects []k8sruntime.Object, opts Options) []*ingress.Interpretation {
+	if opts.Controller == "" {
+		opts.Controller = ingressclass.DefaultControllerName
+	}
+	if opts.IngressClass == "" {
+		opts.IngressClass = ingressclass.DefaultAnnotationValue
+	}
+
+	var ings []*networking.Ingress
+	for _, obj := range objects {
+		if meta, ok := obj.(metav1.Object); ok && meta.GetNamespace() == "" {
+			if _, cluster := obj.(*networking.IngressClass); !cluster {
+				meta.SetNamespace(corev1.NamespaceDefault)
+			}
+		}
+		if ing, ok := obj.(*networking.Ingress); ok {
+			ings = append(ings, ing)
+		}
+	}
+
+	icConfig := &ingressclass.Configuration{
+		Controller:        opts.Controller,
+		AnnotationValue:   opts.IngressClass,
+		WatchWithoutClass: opts.WatchWithoutClass,
+	}
+	return controller.InterpretIngresses(store.NewOffline(objects, opts.ConfigMap, icConfig), ings, icConfig)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const manifests = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: ignored
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  annotations:
+    nginx.ingress.kubernetes.io/rewrite-target: /$1
+spec:
+  ingressClassName: nginx
+  rules:
+  - host: example.com
+    http:
+      paths:
+      - path: /app/(.*)
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+`
+
+const list = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [{
+    "apiVersion": "networking.k8s.io/v1",
+    "kind": "Ingress",
+    "metadata": {"name": "other", "namespace": "apps"},
+    "spec": {"ingressClassName": "other", "defaultBackend": {"service": {"name": "web", "port": {"number": 80}}}}
+  }]
+}`
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"web.yaml":         manifests,
+		"nested/list.json": list,
+		"README.md":        "not a manifest",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	interpretations, err := Files([]string{dir}, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(interpretations) != 2 {
+		t.Fatalf("expected 2 interpretations but returned %v", len(interpretations))
+	}
+
+	other, web := interpretations[0], interpretations[1]
+	if other.Ingress != "apps/other" || other.Class != "" || len(other.Errors) != 0 || len(other.Warnings) != 1 {
+		t.Errorf("expected apps/other ignored but returned %+v", other)
+	}
+
+	if web.Ingress != "default/web" || web.Class != "nginx" || len(web.Errors) != 0 || len(web.Warnings) != 0 {
+		t.Errorf("expected default/web handled but returned %+v", web)
+	}
+	if len(web.Servers) != 1 || len(web.Servers[0].Locations) == 0 {
+		t.Fatalf("expected the locations of default/web but returned %+v", web.Servers)
+	}
+	location := web.Servers[0].Locations[0]
+	if location.Location != `~* "^/app/(.*)"` || location.Backend != "default-web-80" {
+		t.Errorf("unexpected location of default/web: %+v", location)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode([]byte("kind: Ingress\napiVersion: networking.k8s.io/v1\nspec: [")); err == nil {
+		t.Errorf("expected an error decoding an invalid document")
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package metrics
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
@@ -40,13 +41,23 @@ func RegisterHealthz(healthPath string, mux *http.ServeMux, checks ...healthz.He
 	)
 }
 
-func RegisterMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
-	mux.Handle(
-		"/metrics",
-		promhttp.InstrumentMetricHandler(
-			reg,
-			promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
-		),
+// RegisterMetrics exposes the metrics of the registry under /metrics. When
+// token is not empty, the requests must authenticate with it as bearer token.
+func RegisterMetrics(reg *prometheus.Registry, mux *http.ServeMux, token string) {
+	handler := NewMetricsHandler(reg)
+	if token != "" {
+		handler = RequireBearerToken(token, handler)
+	}
+
+	mux.Handle("/metrics", handler)
+}
+
+// NewMetricsHandler returns the handler of the metrics of the registry. The
+// handler does not authenticate the requests.
+func NewMetricsHandler(reg *prometheus.Registry) http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		reg,
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
 	)
 }
 
@@ -84,3 +95,17 @@ func StartHTTPServer(host string, port int, mux *http.ServeMux) {
 	}
 	klog.Fatal(server.ListenAndServe())
 }
+
+// StartHTTPSServer serves the mux over TLS with the given configuration
+func StartHTTPSServer(host string, port int, mux *http.ServeMux, tlsConfig *tls.Config) {
+	server := &http.Server{
+		Addr:              fmt.Sprintf("%s:%v", host, port),
+		Handler:           mux,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      300 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	klog.Fatal(server.ListenAndServeTLS("", ""))
+}
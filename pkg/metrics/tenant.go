@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	clientset "k8s.io/client-go/kubernetes"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// TenantMetricsPath is the path of the metrics of the namespace of the
+	// ServiceAccount scraping them
+	TenantMetricsPath = "/metrics/tenant"
+
+	// tenantReviewTTL is the time the result of the review of a token is kept
+	tenantReviewTTL = time.Minute
+	// tenantReviewCacheSize is the number of token reviews kept
+	tenantReviewCacheSize = 1024
+	// tenantNamespaceLabel is the label of the series filtered by namespace
+	tenantNamespaceLabel = "namespace"
+)
+
+// tenantAuthenticator returns the namespace of the ServiceAccount of a
+// bearer token, reviewed with the TokenReview API
+type tenantAuthenticator struct {
+	client clientset.Interface
+	// audience is the audience the tokens must be issued for, so the tokens
+	// of the ServiceAccounts given to other services can not be replayed
+	audience string
+	reviews  *cache.LRUExpireCache
+}
+
+// namespace returns the namespace of the ServiceAccount authenticated by
+// the token for the audience, or an empty string when the token does not
+// authenticate a ServiceAccount. The results are kept for tenantReviewTTL.
+func (a *tenantAuthenticator) namespace(ctx context.Context, token string) (string, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if ns, ok := a.reviews.Get(key); ok {
+		return ns.(string), nil
+	}
+
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{a.audience},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	ns := ""
+	if review.Status.Authenticated && slices.Contains(review.Status.Audiences, a.audience) {
+		// the users that are not ServiceAccounts have no namespace
+		ns, _, _ = serviceaccount.SplitUsername(review.Status.User.Username)
+	}
+	a.reviews.Add(key, ns, tenantReviewTTL)
+	return ns, nil
+}
+
+// namespaceGatherer returns the series of the gatherer with the namespace
+// label of ns
+func namespaceGatherer(gatherer prometheus.Gatherer, ns string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := gatherer.Gather()
+
+		filtered := make([]*dto.MetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			var metrics []*dto.Metric
+			for _, m := range mf.GetMetric() {
+				for _, label := range m.GetLabel() {
+					if label.GetName() == tenantNamespaceLabel && label.GetValue() == ns {
+						metrics = append(metrics, m)
+						break
+					}
+				}
+			}
+			if len(metrics) == 0 {
+				continue
+			}
+			mf.Metric = metrics
+			filtered = append(filtered, mf)
+		}
+
+		return filtered, err
+	})
+}
+
+// RegisterTenantMetrics exposes under TenantMetricsPath the metrics of the
+// gatherer with the namespace label of the ServiceAccount authenticated by
+// the bearer token of the request, issued for the audience, so the tenants
+// of a shared controller can scrape the metrics of their own Ingresses.
+func RegisterTenantMetrics(gatherer prometheus.Gatherer, mux *http.ServeMux, client clientset.Interface, audience string) {
+	authenticator := &tenantAuthenticator{
+		client:   client,
+		audience: audience,
+		reviews:  cache.NewLRUExpireCache(tenantReviewCacheSize),
+	}
+
+	mux.HandleFunc(TenantMetricsPath, func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		ns, err := authenticator.namespace(r.Context(), token)
+		if err != nil {
+			klog.ErrorS(err, "Error reviewing the token of a tenant metrics request")
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		if ns == "" {
+			http.Error(w, "the token does not authenticate a ServiceAccount for the audience "+authenticator.audience, http.StatusForbidden)
+			return
+		}
+
+		promhttp.HandlerFor(namespaceGatherer(gatherer, ns), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTenantMetrics(t *testing.T) {
+	users := map[string]string{
+		"team-a-token":     "system:serviceaccount:team-a:prometheus",
+		"team-b-api-token": "system:serviceaccount:team-b:default",
+		"team-c-token":     "system:serviceaccount:team-c:default",
+		"admin-token":      "admin",
+	}
+	// the audiences the tokens were issued for, the authenticator of the
+	// tokens without audiences does not report them
+	audiences := map[string][]string{
+		"team-a-token":     {"ingress-nginx-tenant-metrics"},
+		"team-b-api-token": {"https://kubernetes.default.svc"},
+		"admin-token":      {"ingress-nginx-tenant-metrics"},
+	}
+
+	reviews := 0
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if user, ok := users[review.Spec.Token]; ok {
+			for _, audience := range review.Spec.Audiences {
+				if slices.Contains(audiences[review.Spec.Token], audience) {
+					review.Status.Audiences = append(review.Status.Audiences, audience)
+				}
+			}
+			review.Status.Authenticated = len(review.Status.Audiences) > 0 || audiences[review.Spec.Token] == nil
+			if review.Status.Authenticated {
+				review.Status.User.Username = user
+			}
+		}
+		return true, review, nil
+	})
+
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests"}, []string{"namespace", "ingress"})
+	reg.MustRegister(requests)
+	requests.WithLabelValues("team-a", "web").Inc()
+	requests.WithLabelValues("team-b", "web").Inc()
+
+	mux := http.NewServeMux()
+	RegisterTenantMetrics(reg, mux, client, "ingress-nginx-tenant-metrics")
+
+	get := func(token string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, TenantMetricsPath, http.NoBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		body, err := io.ReadAll(rec.Result().Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rec.Code, string(body)
+	}
+
+	if status, _ := get(""); status != http.StatusUnauthorized {
+		t.Errorf("expected status %v without token but returned %v", http.StatusUnauthorized, status)
+	}
+
+	for _, token := range []string{"admin-token", "team-b-api-token", "team-c-token", "invalid-token"} {
+		if status, _ := get(token); status != http.StatusForbidden {
+			t.Errorf("expected status %v for token %v but returned %v", http.StatusForbidden, token, status)
+		}
+	}
+
+	status, body := get("team-a-token")
+	if status != http.StatusOK {
+		t.Fatalf("expected status %v but returned %v", http.StatusOK, status)
+	}
+	if !strings.Contains(body, `requests{ingress="web",namespace="team-a"} 1`) {
+		t.Errorf("expected the series of the namespace team-a but returned:\n%v", body)
+	}
+	if strings.Contains(body, "team-b") {
+		t.Errorf("expected no series of the namespace team-b but returned:\n%v", body)
+	}
+
+	get("team-a-token")
+	if reviews != 5 {
+		t.Errorf("expected the reviews of the tokens to be kept but reviewed %v tokens", reviews)
+	}
+}
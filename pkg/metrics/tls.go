@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	klog "k8s.io/klog/v2"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// caKey is the key of the Secret containing the certificates of the
+// authorities signing the client certificates
+const caKey = "ca.crt"
+
+// secretCertificate keeps the certificate of the metrics endpoint in sync
+// with the content of a Secret
+type secretCertificate struct {
+	client    clientset.Interface
+	namespace string
+	name      string
+
+	requireClientCert bool
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// load reads the certificate, key and client certificate authorities from
+// the Secret. The previous certificate is kept when the Secret is invalid.
+func (s *secretCertificate) load() error {
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(context.TODO(), s.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[apiv1.TLSCertKey], secret.Data[apiv1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("invalid certificate in Secret %v/%v: %w", s.namespace, s.name, err)
+	}
+
+	var clientCAs *x509.CertPool
+	if s.requireClientCert {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(secret.Data[caKey]) {
+			return fmt.Errorf("the Secret %v/%v does not contain a valid %v", s.namespace, s.name, caKey)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cert = &cert
+	s.clientCAs = clientCAs
+	return nil
+}
+
+func (s *secretCertificate) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*s.cert},
+	}
+	if s.requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = s.clientCAs
+	}
+	return cfg, nil
+}
+
+// NewSecretTLSConfig returns the TLS configuration of the metrics endpoint
+// using the certificate and key of a Secret, in the form "namespace/name".
+// When requireClientCert is true, the clients must present a certificate
+// signed by the authorities of the ca.crt key of the Secret. The Secret is
+// read again every resync period to follow the renewals of the certificate.
+func NewSecretTLSConfig(client clientset.Interface, secret string, requireClientCert bool, resync time.Duration, stopCh <-chan struct{}) (*tls.Config, error) {
+	ns, name, err := k8s.ParseNameNS(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &secretCertificate{
+		client:            client,
+		namespace:         ns,
+		name:              name,
+		requireClientCert: requireClientCert,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	go wait.Until(func() {
+		if err := s.load(); err != nil {
+			klog.ErrorS(err, "Error reading the certificate of the metrics endpoint", "secret", secret)
+		}
+	}, resync, stopCh)
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: s.getConfigForClient,
+	}, nil
+}
+
+// RequireBearerToken returns a handler rejecting the requests that do not
+// authenticate with token as bearer token. Every request is rejected when
+// token is empty.
+func RequireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || token == "" || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// selfSignedCertificate returns a certificate valid for 127.0.0.1 usable by
+// servers and clients, and its private key, encoded in PEM
+func selfSignedCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "metrics"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestSecretTLSConfig(t *testing.T) {
+	certPEM, keyPEM := selfSignedCertificate(t)
+	client := fake.NewSimpleClientset(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress-nginx", Name: "metrics"},
+		Data: map[string][]byte{
+			apiv1.TLSCertKey:       certPEM,
+			apiv1.TLSPrivateKeyKey: keyPEM,
+			caKey:                  certPEM,
+		},
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	if _, err := NewSecretTLSConfig(client, "ingress-nginx/missing", false, time.Minute, stopCh); err == nil {
+		t.Errorf("expected an error reading a missing Secret")
+	}
+
+	tlsConfig, err := NewSecretTLSConfig(client, "ingress-nginx/metrics", true, time.Minute, stopCh)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterMetrics(prometheus.NewRegistry(), mux, "secret")
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+
+	get := func(certificates []tls.Certificate, token string) (int, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				RootCAs:      roots,
+				Certificates: certificates,
+			},
+		}}
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/metrics", http.NoBody)
+		if err != nil {
+			return 0, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if _, err := get(nil, "secret"); err == nil {
+		t.Errorf("expected an error scraping the metrics without client certificate")
+	}
+
+	status, err := get([]tls.Certificate{cert}, "")
+	if err != nil || status != http.StatusUnauthorized {
+		t.Errorf("expected status %v without token but returned %v (%v)", http.StatusUnauthorized, status, err)
+	}
+
+	status, err = get([]tls.Certificate{cert}, "secret")
+	if err != nil || status != http.StatusOK {
+		t.Errorf("expected status %v but returned %v (%v)", http.StatusOK, status, err)
+	}
+}
+
+func TestRequireBearerToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+		{"invalid token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"empty token", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			RequireBearerToken(tc.token, next).ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("expected status %v but returned %v", tc.status, w.Code)
+			}
+		})
+	}
+}
@@ -20,24 +20,59 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"pault.ag/go/sniff/parser"
 )
 
+// Actions applied to the connections whose SNI matches neither a passthrough
+// server nor a host terminated by NGINX
+const (
+	// UnmatchedSNITerminate terminates the TLS connections in NGINX
+	UnmatchedSNITerminate = "terminate"
+	// UnmatchedSNIReject closes the connections
+	UnmatchedSNIReject = "reject"
+	// UnmatchedSNIFallback passes the connections through to the fallback server
+	UnmatchedSNIFallback = "fallback"
+)
+
+// UnmatchedSNIActions are the supported actions for the unmatched SNIs
+var UnmatchedSNIActions = []string{UnmatchedSNITerminate, UnmatchedSNIReject, UnmatchedSNIFallback}
+
+// Actions applied to the connections not terminated by NGINX as one of its Hosts
+const (
+	ActionPassthrough = "passthrough"
+	ActionFallback    = "fallback"
+	ActionTerminate   = "terminate"
+	ActionReject      = "reject"
+)
+
 // TCPServer describes a server that works in passthrough mode.
 type TCPServer struct {
 	Hostname      string
 	IP            string
 	Port          int
 	ProxyProtocol bool
+	// Timeout closes the connections idle for longer, 0 means no timeout
+	Timeout time.Duration
+	// Fallback indicates the server receives the connections with an unmatched SNI
+	Fallback bool
 }
 
 // TCPProxy describes the passthrough servers and a default as catch all.
 type TCPProxy struct {
 	ServerList []*TCPServer
 	Default    *TCPServer
+	// Hosts are the hosts terminated by the Default server, wildcards included
+	Hosts []string
+	// UnmatchedSNI is the action applied to the connections whose SNI
+	// matches neither a passthrough server nor one of the Hosts
+	UnmatchedSNI string
+	// OnConnection is called with the host and the action of every connection
+	OnConnection func(host, action string)
 }
 
 // Get returns the TCPServer to use for a given host.
@@ -55,6 +90,55 @@ func (p *TCPProxy) Get(host string) *TCPServer {
 	return p.Default
 }
 
+// Route returns the TCPServer to use for a given host and the action applied
+// to the connection. The action is empty for the hosts terminated by the
+// Default server and the TCPServer nil for the rejected connections.
+func (p *TCPProxy) Route(host string) (*TCPServer, string) {
+	for _, s := range p.ServerList {
+		if s.Hostname == host {
+			return s, ActionPassthrough
+		}
+	}
+
+	for _, h := range p.Hosts {
+		if matchesHost(host, h) {
+			return p.Default, ""
+		}
+	}
+
+	switch p.UnmatchedSNI {
+	case UnmatchedSNIReject:
+		return nil, ActionReject
+	case UnmatchedSNIFallback:
+		for _, s := range p.ServerList {
+			if s.Fallback {
+				return s, ActionFallback
+			}
+		}
+	}
+
+	return p.Default, ActionTerminate
+}
+
+// matchesHost returns true when host is the hostname pattern or matches its
+// leading wildcard label
+func matchesHost(host, pattern string) bool {
+	if host == "" {
+		return false
+	}
+	if host == pattern {
+		return true
+	}
+
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	if !ok {
+		return false
+	}
+
+	label, found := strings.CutSuffix(host, suffix)
+	return found && label != "" && !strings.Contains(label, ".")
+}
+
 // Handle reads enough information from the connection to extract the hostname
 // and open a connection to the passthrough server.
 func (p *TCPProxy) Handle(conn net.Conn) {
@@ -68,21 +152,30 @@ func (p *TCPProxy) Handle(conn net.Conn) {
 		return
 	}
 
-	proxy := p.Default
 	hostname, err := parser.GetHostname(data)
 	if err == nil {
 		klog.V(4).InfoS("TLS Client Hello", "host", hostname)
-		proxy = p.Get(hostname)
+	}
+
+	proxy, action := p.Route(hostname)
+	if p.OnConnection != nil && action != "" {
+		// the SNI of the unmatched connections is not reported to bound the hosts
+		host := ""
+		if action == ActionPassthrough || action == ActionFallback {
+			host = proxy.Hostname
+		}
+		p.OnConnection(host, action)
 	}
 
 	if proxy == nil {
-		klog.V(4).InfoS("There is no configured proxy for SSL connections.")
+		klog.V(4).InfoS("Rejecting SSL connection with unmatched SNI", "host", hostname)
 		return
 	}
 
 	hostPort := net.JoinHostPort(proxy.IP, fmt.Sprintf("%v", proxy.Port))
 	klog.V(4).InfoS("passing to", "hostport", hostPort)
-	clientConn, err := net.Dial("tcp", hostPort)
+	dialer := net.Dialer{Timeout: proxy.Timeout}
+	clientConn, err := dialer.Dial("tcp", hostPort)
 	if err != nil {
 		klog.V(4).ErrorS(err, "error dialing proxy", "ip", proxy.IP, "port", proxy.Port, "hostname", proxy.Hostname)
 		return
@@ -120,13 +213,27 @@ func (p *TCPProxy) Handle(conn net.Conn) {
 		}
 	}
 
-	pipe(clientConn, conn)
+	pipe(clientConn, conn, proxy.Timeout)
 }
 
-func pipe(client, server net.Conn) {
+// pipe copies the data between the connections until one of them is closed,
+// or no data was read from them for longer than timeout when it is not 0
+func pipe(client, server net.Conn, timeout time.Duration) {
+	extendDeadline := func() {}
+	if timeout > 0 {
+		extendDeadline = func() {
+			deadline := time.Now().Add(timeout)
+			//nolint:errcheck // the deadline is not set on closed connections
+			client.SetDeadline(deadline)
+			//nolint:errcheck // the deadline is not set on closed connections
+			server.SetDeadline(deadline)
+		}
+		extendDeadline()
+	}
+
 	doCopy := func(s, c net.Conn, cancel chan<- bool) {
 		//nolint:errcheck // No need to catch these errors
-		io.Copy(s, c)
+		io.Copy(s, activityReader{Reader: c, onRead: extendDeadline})
 		cancel <- true
 	}
 
@@ -137,3 +244,17 @@ func pipe(client, server net.Conn) {
 
 	<-cancel
 }
+
+// activityReader calls onRead after every read
+type activityReader struct {
+	io.Reader
+	onRead func()
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.onRead()
+	}
+	return n, err
+}
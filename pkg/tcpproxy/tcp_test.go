@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tcpproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRoute(t *testing.T) {
+	nginx := &TCPServer{Hostname: "localhost"}
+	passthrough := &TCPServer{Hostname: "pass.example.com"}
+	fallback := &TCPServer{Hostname: "fallback.example.com", Fallback: true}
+
+	testCases := []struct {
+		name           string
+		unmatchedSNI   string
+		host           string
+		expectedServer *TCPServer
+		expectedAction string
+	}{
+		{"passthrough host", UnmatchedSNIReject, "pass.example.com", passthrough, ActionPassthrough},
+		{"terminated host", UnmatchedSNIReject, "example.com", nginx, ""},
+		{"terminated wildcard host", UnmatchedSNIReject, "foo.wildcard.com", nginx, ""},
+		{"wildcard matches a single label", UnmatchedSNIReject, "foo.bar.wildcard.com", nil, ActionReject},
+		{"terminated by default", "", "unknown.com", nginx, ActionTerminate},
+		{"terminated", UnmatchedSNITerminate, "unknown.com", nginx, ActionTerminate},
+		{"rejected", UnmatchedSNIReject, "unknown.com", nil, ActionReject},
+		{"rejected without SNI", UnmatchedSNIReject, "", nil, ActionReject},
+		{"fallback", UnmatchedSNIFallback, "unknown.com", fallback, ActionFallback},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &TCPProxy{
+				ServerList:   []*TCPServer{passthrough, fallback},
+				Default:      nginx,
+				Hosts:        []string{"example.com", "*.wildcard.com"},
+				UnmatchedSNI: tc.unmatchedSNI,
+			}
+
+			server, action := p.Route(tc.host)
+			if server != tc.expectedServer {
+				t.Errorf("expected server %v but got %v", tc.expectedServer, server)
+			}
+			if action != tc.expectedAction {
+				t.Errorf("expected action %q but got %q", tc.expectedAction, action)
+			}
+		})
+	}
+
+	p := &TCPProxy{Default: nginx, UnmatchedSNI: UnmatchedSNIFallback}
+	if server, action := p.Route("unknown.com"); server != nginx || action != ActionTerminate {
+		t.Errorf("expected the connection to be terminated without fallback server but got %v %q", server, action)
+	}
+}
+
+func TestPipeTimeout(t *testing.T) {
+	client, clientPeer := net.Pipe()
+	server, serverPeer := net.Pipe()
+	defer clientPeer.Close()
+	defer serverPeer.Close()
+
+	done := make(chan struct{})
+	go func() {
+		pipe(client, server, 50*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the idle connections to be closed")
+	}
+}
@@ -127,9 +127,24 @@ func IsDynamicConfigurationEnough(newcfg, oldcfg *ingress.Configuration) bool {
 	clearCertificates(&copyOfRunningConfig)
 	clearCertificates(&copyOfPcfg)
 
+	clearDynamicServers(&copyOfRunningConfig)
+	clearDynamicServers(&copyOfPcfg)
+
 	return copyOfRunningConfig.Equal(&copyOfPcfg)
 }
 
+// clearDynamicServers removes the servers routed by the catch-all server
+// with Lua, they are not part of the configuration of NGINX.
+func clearDynamicServers(config *ingress.Configuration) {
+	servers := make([]*ingress.Server, 0, len(config.Servers))
+	for _, server := range config.Servers {
+		if !server.Dynamic {
+			servers = append(servers, server)
+		}
+	}
+	config.Servers = servers
+}
+
 // clearL4serviceEndpoints is a helper function to clear endpoints from the ingress configuration since they should be ignored when
 // checking if the new configuration changes can be applied dynamically.
 func clearL4serviceEndpoints(config *ingress.Configuration) {
@@ -173,6 +188,8 @@ type Redirect struct {
 	From    string
 	To      string
 	SSLCert *ingress.SSLCert
+	// ClassListenPorts contains the ports of the IngressClass of the server
+	ClassListenPorts *ingress.ClassListenPorts
 }
 
 // BuildRedirects build the redirects of servers based on configurations and certificates
@@ -213,8 +230,9 @@ func BuildRedirects(servers []*ingress.Server) []*Redirect {
 		}
 
 		r := &Redirect{
-			From: from,
-			To:   to,
+			From:             from,
+			To:               to,
+			ClassListenPorts: srv.ClassListenPorts,
 		}
 
 		if srv.SSLCert != nil {
@@ -130,3 +130,37 @@ func TestIsDynamicConfigurationEnough(t *testing.T) {
 		t.Errorf("Expected new config to not change")
 	}
 }
+
+func TestIsDynamicConfigurationEnoughWithDynamicServers(t *testing.T) {
+	catchAll := &ingress.Server{Hostname: "_", Locations: []*ingress.Location{{Path: "/", Backend: "upstream-default-backend"}}}
+	dynamicServer := func(hostname string) *ingress.Server {
+		return &ingress.Server{
+			Hostname:  hostname,
+			Dynamic:   true,
+			Locations: []*ingress.Location{{Path: "/", Backend: "fakenamespace-myapp-80"}},
+		}
+	}
+
+	runningConfig := &ingress.Configuration{Servers: []*ingress.Server{catchAll, dynamicServer("myapp.fake")}}
+
+	newConfig := &ingress.Configuration{Servers: []*ingress.Server{catchAll, dynamicServer("myapp.fake"), dynamicServer("other.fake")}}
+	if !IsDynamicConfigurationEnough(newConfig, runningConfig) {
+		t.Errorf("Expected to be dynamically configurable when a dynamic server is added")
+	}
+
+	newConfig = &ingress.Configuration{Servers: []*ingress.Server{catchAll}}
+	if !IsDynamicConfigurationEnough(newConfig, runningConfig) {
+		t.Errorf("Expected to be dynamically configurable when a dynamic server is removed")
+	}
+
+	rendered := dynamicServer("myapp.fake")
+	rendered.Dynamic = false
+	newConfig = &ingress.Configuration{Servers: []*ingress.Server{catchAll, rendered}}
+	if IsDynamicConfigurationEnough(newConfig, runningConfig) {
+		t.Errorf("Expected to not be dynamically configurable when a dynamic server is rendered")
+	}
+
+	if len(runningConfig.Servers) != 2 {
+		t.Errorf("Expected running config to not change")
+	}
+}
@@ -0,0 +1,85 @@
+local lrucache = require("resty.lrucache")
+local resty_string = require("resty.string")
+local hmac = require("util.hmac")
+
+local ngx = ngx
+local io = io
+local table_concat = table.concat
+
+local _M = {}
+
+local VERSION = "1"
+
+-- the keys are cached by the SHA of their file, a rotated key
+-- is read again as its file has a new SHA
+local keys, cache_err = lrucache.new(100)
+if not keys then
+  error("failed to create the attribution keys cache: " .. tostring(cache_err))
+end
+
+local function load_key(path, sha)
+  local key = keys:get(sha)
+  if key then
+    return key
+  end
+
+  local f, open_err = io.open(path, "rb")
+  if not f then
+    ngx.log(ngx.ERR, "failed to open attribution key file: ", open_err)
+    return nil
+  end
+  key = f:read("*a")
+  f:close()
+
+  if not key or key == "" then
+    ngx.log(ngx.ERR, "attribution key file ", path, " is empty")
+    return nil
+  end
+
+  keys:set(sha, key)
+  return key
+end
+
+local function escape(value)
+  if not value or value == "" then
+    return "-"
+  end
+  return ngx.escape_uri(value)
+end
+
+-- value returns the fields of the attribution header, without signature
+function _M.value(var, now)
+  return table_concat({
+    "v=" .. VERSION,
+    "kid=" .. escape(var.attribution_key_id),
+    "ts=" .. now,
+    "ip=" .. escape(var.remote_addr),
+    "tls=" .. escape(var.ssl_protocol),
+    "cipher=" .. escape(var.ssl_cipher),
+    "cert=" .. escape(var.ssl_client_verify),
+    "auth=" .. escape(var.attribution_auth),
+    "user=" .. escape(var.remote_user),
+  }, ";")
+end
+
+-- rewrite replaces the attribution header sent by the client with a header
+-- signed with the key of the location
+function _M.rewrite()
+  local var = ngx.var
+  local header = var.attribution_header
+  if not header or header == "" then
+    return
+  end
+
+  local key = load_key(var.attribution_key_file, var.attribution_key_sha)
+  if not key then
+    -- an unsigned header must never reach the backend
+    ngx.req.clear_header(header)
+    return
+  end
+
+  local value = _M.value(var, ngx.time())
+  ngx.req.set_header(header, value .. ";sig=" .. resty_string.to_hex(hmac.sha256(key, value)))
+end
+
+return _M
@@ -0,0 +1,348 @@
+local cjson = require("cjson.safe")
+local resty_random = require("resty.random")
+local resty_string = require("resty.string")
+
+local ngx = ngx
+local type = type
+local next = next
+local pairs = pairs
+local ipairs = ipairs
+local tonumber = tonumber
+local string_find = string.find
+local string_lower = string.lower
+local string_sub = string.sub
+local table_concat = table.concat
+
+local _M = {}
+
+local SESSION_ID_LENGTH = 32
+
+local config = {
+  store = "shared-dict",
+  threshold = 4096,
+  name = "ingress_auth_session",
+  ttl = 86400,
+  redis_host = "",
+  redis_port = 6379,
+}
+
+function _M.set_config(new_config)
+  if type(new_config) ~= "table" then
+    return
+  end
+
+  for key, value in pairs(new_config) do
+    if value ~= nil and value ~= "" and value ~= 0 then
+      config[key] = value
+    end
+  end
+end
+
+local function redis_enabled()
+  return config.store == "redis" and config.redis_host ~= ""
+end
+
+local function redis_connect()
+  local redis = require("resty.redis")
+  local red = redis:new()
+  red:set_timeouts(1000, 1000, 1000)
+
+  local ok, err = red:connect(config.redis_host, tonumber(config.redis_port))
+  if not ok then
+    return nil, err
+  end
+  return red
+end
+
+-- the cookies are always cached in the shared dictionary as the Redis server
+-- can only be reached asynchronously from the header filter
+local function load(id)
+  local dict = ngx.shared.auth_cookie_sessions
+  local value = dict:get(id)
+
+  if not value and redis_enabled() then
+    local red, err = redis_connect()
+    if not red then
+      ngx.log(ngx.ERR, "failed to connect to redis: ", err)
+      return nil
+    end
+
+    value, err = red:get(config.name .. ":" .. id)
+    red:set_keepalive(10000, 100)
+    if not value or value == ngx.null then
+      if err then
+        ngx.log(ngx.ERR, "failed to read auth cookie session: ", err)
+      end
+      return nil
+    end
+
+    dict:set(id, value, config.ttl)
+  end
+
+  if not value then
+    return nil
+  end
+
+  local jar = cjson.decode(value)
+  if type(jar) ~= "table" or type(jar.cookies) ~= "table" then
+    return nil
+  end
+  return jar
+end
+
+local function redis_write(premature, id, value)
+  if premature then
+    return
+  end
+
+  local red, err = redis_connect()
+  if not red then
+    ngx.log(ngx.ERR, "failed to connect to redis: ", err)
+    return
+  end
+
+  local key = config.name .. ":" .. id
+  local ok
+  if value then
+    ok, err = red:set(key, value, "EX", config.ttl)
+  else
+    ok, err = red:del(key)
+  end
+  if not ok then
+    ngx.log(ngx.ERR, "failed to write auth cookie session: ", err)
+  end
+  red:set_keepalive(10000, 100)
+end
+
+local function save(id, jar)
+  local value
+  if jar then
+    value = cjson.encode(jar)
+    local ok, err = ngx.shared.auth_cookie_sessions:set(id, value, config.ttl)
+    if not ok then
+      ngx.log(ngx.ERR, "failed to store auth cookie session: ", err)
+    end
+  else
+    ngx.shared.auth_cookie_sessions:delete(id)
+  end
+
+  if redis_enabled() then
+    local ok, err = ngx.timer.at(0, redis_write, id, value)
+    if not ok then
+      ngx.log(ngx.ERR, "failed to create timer to write auth cookie session: ", err)
+    end
+  end
+end
+
+-- parse_cookie_header returns the name/value pairs of a Cookie header in order
+function _M.parse_cookie_header(header)
+  local cookies = {}
+  if not header then
+    return cookies
+  end
+
+  for pair in header:gmatch("[^;]+") do
+    local name, value = pair:match("^%s*([^=]-)%s*=%s*(.-)%s*$")
+    if name and name ~= "" then
+      cookies[#cookies + 1] = { name = name, value = value }
+    end
+  end
+  return cookies
+end
+
+-- parse_set_cookie returns the name, value and attributes of a Set-Cookie
+-- header. The cookie is marked as deleted when it is expired.
+function _M.parse_set_cookie(header)
+  local semicolon = string_find(header, ";", 1, true)
+  local pair = semicolon and string_sub(header, 1, semicolon - 1) or header
+  local name, value = pair:match("^%s*([^=]-)%s*=%s*(.-)%s*$")
+  if not name or name == "" then
+    return nil
+  end
+
+  local cookie = { name = name, value = value, deleted = value == "" }
+  if not semicolon then
+    return cookie
+  end
+
+  for attribute in string_sub(header, semicolon + 1):gmatch("[^;]+") do
+    local key, attr_value = attribute:match("^%s*([^=]-)%s*=%s*(.-)%s*$")
+    key = string_lower(key or attribute:match("^%s*(.-)%s*$"))
+
+    if key == "domain" then
+      cookie.domain = attr_value
+    elseif key == "max-age" then
+      local max_age = tonumber(attr_value)
+      if max_age and max_age <= 0 then
+        cookie.deleted = true
+      end
+    elseif key == "expires" and attr_value then
+      local expires = ngx.parse_http_time(attr_value)
+      if expires and expires <= ngx.time() then
+        cookie.deleted = true
+      end
+    end
+  end
+
+  return cookie
+end
+
+-- split_set_cookie splits the Set-Cookie headers joined by NGINX in a
+-- variable like $upstream_http_set_cookie, taking care of the commas
+-- used by the Expires attribute.
+function _M.split_set_cookie(value)
+  if not value or value == "" then
+    return {}
+  end
+
+  local headers, err = ngx.re.split(value, [[,\s*(?=[^;,=\s]+=)]], "jo")
+  if not headers then
+    ngx.log(ngx.ERR, "failed to split Set-Cookie headers: ", err)
+    return { value }
+  end
+  return headers
+end
+
+-- merge applies the cookies to the jar, removing the deleted ones
+function _M.merge(jar, cookies)
+  for _, cookie in ipairs(cookies) do
+    if cookie.deleted then
+      jar.cookies[cookie.name] = nil
+    else
+      jar.cookies[cookie.name] = cookie.value
+      if cookie.domain then
+        jar.domain = cookie.domain
+      end
+    end
+  end
+  return jar
+end
+
+-- session_cookie returns the Set-Cookie header of the session cookie.
+-- An empty id expires the cookie.
+function _M.session_cookie(id, domain, secure)
+  local attributes = { config.name .. "=" .. id, "Path=/", "HttpOnly", "SameSite=Lax" }
+  attributes[#attributes + 1] = "Max-Age=" .. (id == "" and 0 or config.ttl)
+  if domain then
+    attributes[#attributes + 1] = "Domain=" .. domain
+  end
+  if secure then
+    attributes[#attributes + 1] = "Secure"
+  end
+  return table_concat(attributes, "; ")
+end
+
+local function new_id()
+  local bytes = resty_random.bytes(SESSION_ID_LENGTH / 2, true)
+  if not bytes then
+    return nil
+  end
+  return resty_string.to_hex(bytes)
+end
+
+-- rewrite replaces the session cookie sent by the client with the cookies
+-- stored in the session, so the authentication request and the upstream
+-- receive the original cookies.
+function _M.rewrite()
+  if ngx.var.auth_cookie_session ~= "true" then
+    return
+  end
+
+  local id = ngx.var["cookie_" .. config.name]
+  if not id or #id ~= SESSION_ID_LENGTH then
+    return
+  end
+
+  local jar = load(id)
+  if not jar then
+    return
+  end
+
+  ngx.ctx.auth_cookie_session = { id = id, jar = jar }
+
+  local cookies = {}
+  for _, cookie in ipairs(_M.parse_cookie_header(ngx.var.http_cookie)) do
+    if cookie.name ~= config.name and not jar.cookies[cookie.name] then
+      cookies[#cookies + 1] = cookie.name .. "=" .. cookie.value
+    end
+  end
+  for name, value in pairs(jar.cookies) do
+    cookies[#cookies + 1] = name .. "=" .. value
+  end
+
+  ngx.req.set_header("Cookie", table_concat(cookies, "; "))
+end
+
+-- header_filter stores the cookies of the response in the session when
+-- they are bigger than the threshold, or when they update the cookies
+-- of an existing session, and replaces them with the session cookie.
+function _M.header_filter()
+  if ngx.var.auth_cookie_session ~= "true" then
+    return
+  end
+
+  local headers = ngx.header["Set-Cookie"]
+  if type(headers) == "string" then
+    headers = { headers }
+  end
+  headers = headers or {}
+
+  local auth_cookie = ngx.var.auth_cookie
+  local auth_headers = _M.split_set_cookie(auth_cookie)
+
+  local size = 0
+  local all = {}
+  for _, list in ipairs({ headers, auth_headers }) do
+    for _, header in ipairs(list) do
+      size = size + #header
+      all[#all + 1] = header
+    end
+  end
+  if #all == 0 then
+    return
+  end
+
+  local session = ngx.ctx.auth_cookie_session
+  local store_all = size > config.threshold
+
+  local stored = {}
+  local passthrough = {}
+  for _, header in ipairs(all) do
+    local cookie = _M.parse_set_cookie(header)
+    if cookie and cookie.name ~= config.name and
+        (store_all or (session and session.jar.cookies[cookie.name])) then
+      stored[#stored + 1] = cookie
+    else
+      passthrough[#passthrough + 1] = header
+    end
+  end
+  if #stored == 0 then
+    return
+  end
+
+  local id = session and session.id or new_id()
+  if not id then
+    ngx.log(ngx.ERR, "failed to generate auth cookie session id")
+    return
+  end
+
+  local jar = _M.merge(session and session.jar or { cookies = {} }, stored)
+  local secure = ngx.var.pass_access_scheme == "https" or ngx.var.scheme == "https"
+
+  if next(jar.cookies) == nil then
+    save(id, nil)
+    passthrough[#passthrough + 1] = _M.session_cookie("", jar.domain, secure)
+  else
+    save(id, jar)
+    passthrough[#passthrough + 1] = _M.session_cookie(id, jar.domain, secure)
+  end
+
+  ngx.header["Set-Cookie"] = passthrough
+  if auth_cookie and auth_cookie ~= "" then
+    -- the headers of the authentication response are now part of
+    -- the response headers, add_header must not send them again
+    ngx.var.auth_cookie = ""
+  end
+end
+
+return _M
@@ -0,0 +1,230 @@
+local ffi = require("ffi")
+local lrucache = require("resty.lrucache")
+
+local ngx = ngx
+local ipairs = ipairs
+local tostring = tostring
+local string_format = string.format
+local string_gmatch = string.gmatch
+local string_sub = string.sub
+local string_upper = string.upper
+local table_concat = table.concat
+local ngx_re_find = ngx.re.find
+
+local _M = {}
+
+ffi.cdef[[
+void *BIO_new_mem_buf(const void *buf, int len);
+int BIO_free(void *a);
+void *PEM_read_bio_X509(void *bp, void **x, void *cb, void *u);
+void X509_free(void *a);
+void *X509_get_ext_d2i(const void *x, int nid, int *crit, int *idx);
+int OPENSSL_sk_num(const void *st);
+void *OPENSSL_sk_value(const void *st, int i);
+void GENERAL_NAMES_free(void *a);
+int ASN1_STRING_length(const void *x);
+const unsigned char *ASN1_STRING_get0_data(const void *x);
+
+typedef struct {
+  int type;
+  void *d;
+} auth_tls_general_name_t;
+]]
+
+local C = ffi.C
+
+local NID_SUBJECT_ALT_NAME = 85
+
+-- types of the general names of the subject alternative name extension
+local SAN_TYPES = {
+  [1] = "email",
+  [2] = "DNS",
+  [6] = "URI",
+  [7] = "IP",
+}
+
+-- the subject alternative names are cached by the fingerprint of the
+-- client certificate, a connection sends the same certificate with all
+-- its requests
+local alt_names_cache, cache_err = lrucache.new(10000)
+if not alt_names_cache then
+  error("failed to create the client certificate cache: " .. tostring(cache_err))
+end
+
+local function format_ip(data, length)
+  if length == 4 then
+    return string_format("%d.%d.%d.%d", data[0], data[1], data[2], data[3])
+  end
+
+  local groups = {}
+  for i = 0, length - 2, 2 do
+    groups[#groups + 1] = string_format("%x", data[i] * 256 + data[i + 1])
+  end
+  return table_concat(groups, ":")
+end
+
+-- subject_alt_names returns the subject alternative names of a PEM encoded
+-- certificate prefixed with their type, like "DNS:example.com"
+function _M.subject_alt_names(pem)
+  local names = {}
+
+  local bio = C.BIO_new_mem_buf(pem, #pem)
+  if bio == nil then
+    return names
+  end
+  local cert = C.PEM_read_bio_X509(bio, nil, nil, nil)
+  C.BIO_free(bio)
+  if cert == nil then
+    ngx.log(ngx.ERR, "failed to read the client certificate")
+    return names
+  end
+
+  local general_names = C.X509_get_ext_d2i(cert, NID_SUBJECT_ALT_NAME, nil, nil)
+  if general_names ~= nil then
+    for i = 0, C.OPENSSL_sk_num(general_names) - 1 do
+      local name = ffi.cast("auth_tls_general_name_t *", C.OPENSSL_sk_value(general_names, i))
+      local name_type = SAN_TYPES[name.type]
+      if name_type then
+        local data = C.ASN1_STRING_get0_data(name.d)
+        local length = C.ASN1_STRING_length(name.d)
+        local value
+        if name_type == "IP" then
+          value = format_ip(data, length)
+        else
+          value = ffi.string(data, length)
+        end
+        names[#names + 1] = name_type .. ":" .. value
+      end
+    end
+    C.GENERAL_NAMES_free(general_names)
+  end
+
+  C.X509_free(cert)
+  return names
+end
+
+-- parse_dn returns the values of the attributes of a distinguished name in
+-- the RFC 2253 format of $ssl_client_s_dn, like "CN=client,OU=a\,b,O=acme"
+function _M.parse_dn(dn)
+  local attributes = {}
+  if not dn or dn == "" then
+    return attributes
+  end
+
+  local current = {}
+  local escaped = false
+  local function add()
+    local rdn = table_concat(current)
+    current = {}
+    local from = rdn:find("=", 1, true)
+    if not from then
+      return
+    end
+    local key = string_upper(string_sub(rdn, 1, from - 1))
+    attributes[key] = attributes[key] or {}
+    local values = attributes[key]
+    values[#values + 1] = string_sub(rdn, from + 1)
+  end
+
+  for char in string_gmatch(dn, ".") do
+    if escaped then
+      current[#current + 1] = char
+      escaped = false
+    elseif char == "\\" then
+      escaped = true
+    elseif char == "," then
+      add()
+    else
+      current[#current + 1] = char
+    end
+  end
+  add()
+
+  return attributes
+end
+
+local function matches_any(values, regex)
+  for _, value in ipairs(values or {}) do
+    local from, _, err = ngx_re_find(value, regex, "jo")
+    if err then
+      ngx.log(ngx.ERR, "failed to match the client certificate against ", regex, ": ", err)
+      return false
+    end
+    if from then
+      return true
+    end
+  end
+  return false
+end
+
+local function alt_names(var)
+  local fingerprint = var.ssl_client_fingerprint
+  local names = alt_names_cache:get(fingerprint)
+  if not names then
+    names = _M.subject_alt_names(var.ssl_client_raw_cert or "")
+    alt_names_cache:set(fingerprint, names)
+  end
+  return names
+end
+
+local function identity(var, attribute, subject, names)
+  if attribute == "cn" or attribute == "ou" or attribute == "o" then
+    local values = subject[string_upper(attribute)]
+    return values and table_concat(values, ",") or ""
+  elseif attribute == "san" then
+    return table_concat(names(), ",")
+  elseif attribute == "subject" then
+    return var.ssl_client_s_dn
+  elseif attribute == "serial" then
+    return var.ssl_client_serial
+  elseif attribute == "fingerprint" then
+    return var.ssl_client_fingerprint
+  end
+  return ""
+end
+
+-- rewrite denies the requests whose client certificate does not match
+-- auth-tls-match-ou or auth-tls-match-san and exposes the attributes of
+-- the certificate in the variables sent to the upstream
+function _M.rewrite()
+  local var = ngx.var
+  local match_ou = var.auth_tls_match_ou
+  local match_san = var.auth_tls_match_san
+  local identity_attributes = var.auth_tls_identity_attributes
+  if not match_ou and not match_san and not identity_attributes then
+    return
+  end
+
+  -- with auth-tls-verify-client set to optional the request may not
+  -- have a verified certificate, it has no attribute to match
+  if var.ssl_client_verify ~= "SUCCESS" then
+    if match_ou or match_san then
+      return ngx.exit(ngx.HTTP_FORBIDDEN)
+    end
+    return
+  end
+
+  local subject = _M.parse_dn(var.ssl_client_s_dn)
+  local names
+  local function get_names()
+    names = names or alt_names(var)
+    return names
+  end
+
+  if match_ou and not matches_any(subject.OU, match_ou) then
+    ngx.log(ngx.INFO, "client certificate ", var.ssl_client_s_dn, " does not match the OU ", match_ou)
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+  if match_san and not matches_any(get_names(), match_san) then
+    ngx.log(ngx.INFO, "client certificate ", var.ssl_client_s_dn, " does not match the SAN ", match_san)
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  if identity_attributes then
+    for attribute in string_gmatch(identity_attributes, "[^,]+") do
+      var["auth_tls_identity_" .. attribute] = identity(var, attribute, subject, get_names)
+    end
+  end
+end
+
+return _M
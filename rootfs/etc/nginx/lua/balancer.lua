@@ -1,7 +1,9 @@
 local ngx_balancer = require("ngx.balancer")
 local cjson = require("cjson.safe")
+local lrucache = require("resty.lrucache")
 local util = require("util")
 local dns_lookup = require("util.dns").lookup
+local dns_lookup_srv = require("util.dns").lookup_srv
 local configuration = require("configuration")
 local round_robin = require("balancer.round_robin")
 local chash = require("balancer.chash")
@@ -9,12 +11,19 @@ local chashsubset = require("balancer.chashsubset")
 local sticky_balanced = require("balancer.sticky_balanced")
 local sticky_persistent = require("balancer.sticky_persistent")
 local ewma = require("balancer.ewma")
+local peak_ewma = require("balancer.peak_ewma")
+local least_request = require("balancer.least_request")
+local slow_start = require("balancer.slow_start")
+local retry_policy = require("retry_policy")
+local connect_backoff = require("connect_backoff")
+local next_upstream = require("next_upstream")
 local string = string
 local ipairs = ipairs
 local table = table
 local getmetatable = getmetatable
 local tostring = tostring
 local pairs = pairs
+local next = next
 local math = math
 local ngx = ngx
 
@@ -32,14 +41,36 @@ local IMPLEMENTATIONS = {
   sticky_balanced = sticky_balanced,
   sticky_persistent = sticky_persistent,
   ewma = ewma,
+  peak_ewma = peak_ewma,
+  least_request = least_request,
 }
 
+-- balancers asked again for an endpoint that was not tried yet on retries.
+-- ewma, peak_ewma, least_request and sticky sessions with change-on-failure
+-- avoid the tried endpoints themselves, consistent hashing always returns
+-- the same endpoint.
+local PICK_UNTRIED_PEER_BALANCERS = {
+  round_robin = true,
+}
+
+-- maximum number of client connections pinned to a peer by each worker
+local PINNED_PEERS_SIZE = 10000
+
 local PROHIBITED_LOCALHOST_PORT = configuration.prohibited_localhost_port or '10246'
 local PROHIBITED_PEER_PATTERN = "^127.*:" .. PROHIBITED_LOCALHOST_PORT .. "$"
 
 local _M = {}
 local balancers = {}
 local backends_with_external_name = {}
+local backends_warming = {}
+local upstream_keepalives = {}
+
+-- peers of the client connections to the backends with ntlm, by backend and
+-- client connection
+local pinned_peers, pinned_peers_err = lrucache.new(PINNED_PEERS_SIZE)
+if not pinned_peers then
+  error("failed to create the cache for pinned peers: " .. (pinned_peers_err or "unknown"))
+end
 local backends_last_synced_at = 0
 
 local function get_implementation(backend)
@@ -74,11 +105,33 @@ end
 
 local function resolve_external_names(original_backend)
   local backend = util.deepcopy(original_backend)
+  local external_name = backend.externalName or {}
+  local ttl
+  if external_name.ttl and external_name.ttl > 0 then
+    ttl = external_name.ttl
+  end
+
   local endpoints = {}
   for _, endpoint in ipairs(backend.endpoints) do
-    local ips = dns_lookup(endpoint.address)
-    for _, ip in ipairs(ips) do
-      table.insert(endpoints, { address = ip, port = endpoint.port })
+    local srv_endpoints
+    if external_name.srv and external_name.srv ~= "" then
+      -- the A and AAAA records are used when the SRV records can not be resolved
+      srv_endpoints = dns_lookup_srv(external_name.srv .. "." .. endpoint.address, ttl)
+    end
+
+    if srv_endpoints then
+      for _, srv_endpoint in ipairs(srv_endpoints) do
+        table.insert(endpoints, {
+          address = srv_endpoint.address,
+          port = srv_endpoint.port,
+          weight = srv_endpoint.weight,
+        })
+      end
+    else
+      local ips = dns_lookup(endpoint.address, ttl)
+      for _, ip in ipairs(ips) do
+        table.insert(endpoints, { address = ip, port = endpoint.port })
+      end
     end
   end
   backend.endpoints = endpoints
@@ -106,13 +159,25 @@ end
 local function sync_backend(backend)
   if not backend.endpoints or #backend.endpoints == 0 then
     balancers[backend.name] = nil
+    backends_warming[backend.name] = nil
     return
   end
 
+  local original_backend = backend
   if is_backend_with_external_name(backend) then
     backend = resolve_external_names(backend)
   end
 
+  -- the weights of the endpoints change until they are all warm, the backend
+  -- is synced again on every interval until then
+  local warming
+  backend, warming = slow_start.apply(backend)
+  if warming and not is_backend_with_external_name(original_backend) then
+    backends_warming[backend.name] = original_backend
+  else
+    backends_warming[backend.name] = nil
+  end
+
   backend.endpoints = format_ipv6_endpoints(backend.endpoints)
 
   local implementation = get_implementation(backend)
@@ -143,6 +208,12 @@ local function sync_backends_with_external_name()
   end
 end
 
+local function sync_backends_warming()
+  for _, backend_warming in pairs(backends_warming) do
+    sync_backend(backend_warming)
+  end
+end
+
 local function sync_backends()
   local raw_backends_last_synced_at = configuration.get_raw_backends_last_synced_at()
   if raw_backends_last_synced_at <= backends_last_synced_at then
@@ -152,6 +223,7 @@ local function sync_backends()
   local backends_data = configuration.get_backends_data()
   if not backends_data then
     balancers = {}
+    upstream_keepalives = {}
     return
   end
 
@@ -162,7 +234,13 @@ local function sync_backends()
   end
 
   local balancers_to_keep = {}
+  local new_upstream_keepalives = {}
   for _, new_backend in ipairs(new_backends) do
+    local keepalive = new_backend.upstreamKeepalive
+    if keepalive and keepalive.enabled then
+      new_upstream_keepalives[new_backend.name] = keepalive
+    end
+
     if is_backend_with_external_name(new_backend) then
       local backend_with_external_name = util.deepcopy(new_backend)
       backends_with_external_name[backend_with_external_name.name] = backend_with_external_name
@@ -176,8 +254,11 @@ local function sync_backends()
     if not balancers_to_keep[backend_name] then
       balancers[backend_name] = nil
       backends_with_external_name[backend_name] = nil
+      backends_warming[backend_name] = nil
+      slow_start.forget(backend_name)
     end
   end
+  upstream_keepalives = new_upstream_keepalives
   backends_last_synced_at = raw_backends_last_synced_at
 end
 
@@ -255,6 +336,18 @@ local function route_to_alternative_balancer(balancer)
     end
   end
 
+  local target_countries = traffic_shaping_policy.geo
+  if target_countries and #target_countries > 0 then
+    local country = ngx.var.geo_country_code
+    if country and country ~= "" then
+      for _, target_country in ipairs(target_countries) do
+        if target_country == country then
+          return true
+        end
+      end
+    end
+  end
+
   local weightTotal = 100
   if traffic_shaping_policy.weightTotal ~= nil and traffic_shaping_policy.weightTotal > 100 then
     weightTotal = traffic_shaping_policy.weightTotal
@@ -314,6 +407,10 @@ function _M.init_worker()
     ngx.log(ngx.ERR, "error when setting up timer.every for sync_backends_with_external_name: ",
             err)
   end
+  ok, err = ngx.timer.every(BACKENDS_SYNC_INTERVAL, sync_backends_warming)
+  if not ok then
+    ngx.log(ngx.ERR, "error when setting up timer.every for sync_backends_warming: ", err)
+  end
 end
 
 function _M.rewrite()
@@ -324,13 +421,129 @@ function _M.rewrite()
   end
 end
 
+-- get_peer returns the endpoint of the current try. Retries governed by a
+-- retry policy, and requests proxied again because of the response of the
+-- backend, go to an endpoint that was not tried yet when possible. The
+-- endpoints backing off after a connect failure are avoided as well.
+local function get_peer(balancer, policy, is_retry)
+  if not PICK_UNTRIED_PEER_BALANCERS[balancer.name] then
+    return balancer:balance()
+  end
+
+  local tried_peers
+  if policy and is_retry then
+    tried_peers = ngx.ctx.balancer_tried_peers
+  elseif not is_retry then
+    tried_peers = next_upstream.tried_peers(ngx.var.next_upstream_tried_peers)
+    if not next(tried_peers) then
+      tried_peers = nil
+    end
+  end
+
+  if connect_backoff.enabled() then
+    return retry_policy.pick_untried_peer(balancer, connect_backoff.avoided_peers(tried_peers))
+  end
+
+  if tried_peers then
+    return retry_policy.pick_untried_peer(balancer, tried_peers)
+  end
+
+  return balancer:balance()
+end
+
+-- set_more_tries allows NGINX to retry the current try when it fails,
+-- according to the max retries and the retry budget of the retry policy
+local function set_more_tries(policy, upstream_name)
+  if not policy then
+    ngx_balancer.set_more_tries(1)
+    return
+  end
+
+  if ngx.ctx.balancer_retries >= policy.max_retries then
+    ngx_balancer.set_more_tries(0)
+    return
+  end
+
+  if not retry_policy.within_budget(upstream_name, policy.budget_percent) then
+    ngx.ctx.balancer_retry_budget_exhausted = true
+    ngx_balancer.set_more_tries(0)
+    return
+  end
+
+  ngx_balancer.set_more_tries(1)
+end
+
+-- get_keepalive returns the keepalive settings of the backend, falling back
+-- to the ones of the backend of the location for alternative backends
+-- without them. Only the locations proxying to an
+-- upstream_balancer_keepalive_* block can keep connections.
+local function get_keepalive(upstream_name)
+  if ngx.var.upstream_keepalive ~= "true" then
+    return nil
+  end
+
+  local keepalive = upstream_keepalives[upstream_name] or
+    upstream_keepalives[ngx.var.proxy_upstream_name]
+  if not keepalive or keepalive.connections == 0 then
+    return nil
+  end
+
+  return keepalive
+end
+
+-- get_pin_key returns the key pinning the client connection to a peer and
+-- to its own upstream connections for the backends with ntlm, as the NTLM
+-- authentication is bound to the connection, and the time in seconds the
+-- client connection stays pinned. nil for the other backends.
+local function get_pin_key(upstream_name)
+  local keepalive = get_keepalive(upstream_name)
+  if not keepalive or not keepalive.ntlm then
+    return nil
+  end
+
+  return "ntlm-" .. ngx.var.connection, keepalive.timeout
+end
+
+-- enable_keepalive keeps the connection to the peer open with the keepalive
+-- settings of the backend
+local function enable_keepalive(upstream_name)
+  local keepalive = get_keepalive(upstream_name)
+  if not keepalive then
+    return
+  end
+
+  local ok, err = ngx_balancer.enable_keepalive(keepalive.timeout, keepalive.requests)
+  if not ok then
+    ngx.log(ngx.ERR, "error while enabling keepalive for upstream ", upstream_name, ": ", err)
+  end
+end
+
 function _M.balance()
   local balancer = get_balancer()
   if not balancer then
     return
   end
 
-  local peer = balancer:balance()
+  local is_retry = ngx.ctx.balancer_retries ~= nil
+  ngx.ctx.balancer_retries = is_retry and ngx.ctx.balancer_retries + 1 or 0
+
+  local policy = retry_policy.get()
+  if policy and not is_retry then
+    ngx.ctx.balancer_tried_peers = next_upstream.tried_peers(ngx.var.next_upstream_tried_peers)
+  end
+
+  local upstream_name = ngx.var.proxy_alternative_upstream_name
+  if not upstream_name or upstream_name == "" then
+    upstream_name = ngx.var.proxy_upstream_name
+  end
+
+  local pin_key, pin_timeout = get_pin_key(upstream_name)
+
+  -- a retry does not use the pinned peer, which failed
+  local peer = pin_key and not is_retry and pinned_peers:get(upstream_name .. ":" .. pin_key)
+  if not peer then
+    peer = get_peer(balancer, policy, is_retry)
+  end
   if not peer then
     ngx.log(ngx.WARN, "no peer was returned, balancer: " .. balancer.name)
     return
@@ -341,16 +554,42 @@ function _M.balance()
     return
   end
 
-  ngx_balancer.set_more_tries(1)
+  if policy then
+    ngx.ctx.balancer_tried_peers[peer] = true
+    retry_policy.record(upstream_name, is_retry)
+
+    if policy.per_try_timeout > 0 then
+      local ok, err = ngx_balancer.set_timeouts(policy.per_try_timeout, policy.per_try_timeout,
+                                                policy.per_try_timeout)
+      if not ok then
+        ngx.log(ngx.ERR, "error while setting the per try timeout: ", err)
+      end
+    end
+  end
 
-  local ok, err = ngx_balancer.set_current_peer(peer)
+  set_more_tries(policy, upstream_name)
+
+  local ok, err
+  if pin_key then
+    pinned_peers:set(upstream_name .. ":" .. pin_key, peer, pin_timeout)
+    -- the keepalive pool of a peer is identified by its address and the
+    -- host, only used for SNI with HTTPS backends
+    ok, err = ngx_balancer.set_current_peer(peer, nil, pin_key)
+  else
+    ok, err = ngx_balancer.set_current_peer(peer)
+  end
   if not ok then
     ngx.log(ngx.ERR, "error while setting current upstream peer ", peer,
             ": ", err)
+    return
   end
+
+  enable_keepalive(upstream_name)
 end
 
 function _M.log()
+  connect_backoff.record(ngx.var.upstream_addr, ngx.var.upstream_connect_time)
+
   local balancer = get_balancer()
   if not balancer then
     return
@@ -366,9 +605,12 @@ end
 setmetatable(_M, {__index = {
   get_implementation = get_implementation,
   sync_backend = sync_backend,
+  sync_backends_warming = sync_backends_warming,
   route_to_alternative_balancer = route_to_alternative_balancer,
   get_balancer = get_balancer,
   get_balancer_by_upstream_name = get_balancer_by_upstream_name,
+  enable_keepalive = enable_keepalive,
+  get_pin_key = get_pin_key,
 }})
 
 return _M
@@ -0,0 +1,93 @@
+local ngx = ngx
+local ipairs = ipairs
+local math_random = math.random
+
+-- in_flight counts the requests sent to the endpoints of the least_request
+-- and peak_ewma balancers. The counts are local to the worker, the balancers
+-- do not lock shared dictionaries on every request.
+local _M = {}
+
+function _M.key(endpoint)
+  return endpoint.address .. ":" .. endpoint.port
+end
+
+local function picks()
+  local ctx = ngx.ctx
+  if not ctx.balancer_in_flight then
+    ctx.balancer_in_flight = {}
+  end
+  return ctx.balancer_in_flight
+end
+
+local function tried(key)
+  for _, pick in ipairs(ngx.ctx.balancer_in_flight or {}) do
+    if pick.key == key then
+      return true
+    end
+  end
+  return false
+end
+
+-- pick returns the endpoint with the lowest score among choices endpoints
+-- taken at random from the ones not tried yet by the request, all of them
+-- when they have all been tried
+function _M.pick(endpoints, choices, score)
+  local candidates = {}
+  for _, endpoint in ipairs(endpoints) do
+    if not tried(_M.key(endpoint)) then
+      candidates[#candidates + 1] = endpoint
+    end
+  end
+  if #candidates == 0 then
+    for i, endpoint in ipairs(endpoints) do
+      candidates[i] = endpoint
+    end
+  end
+
+  local k = #candidates < choices and #candidates or choices
+  local best, best_score
+  for i = 1, k do
+    local j = math_random(i, #candidates)
+    candidates[i], candidates[j] = candidates[j], candidates[i]
+
+    local candidate_score = score(candidates[i])
+    if not best or candidate_score < best_score then
+      best, best_score = candidates[i], candidate_score
+    end
+  end
+
+  return best, best_score
+end
+
+-- start counts the request sent to the endpoint key
+function _M.start(counts, key)
+  counts[key] = (counts[key] or 0) + 1
+  local p = picks()
+  p[#p + 1] = { counts = counts, key = key }
+end
+
+-- finish uncounts the requests sent to the endpoints picked by the request,
+-- it is called once the response is received
+function _M.finish()
+  local p = ngx.ctx.balancer_in_flight
+  if not p then
+    return
+  end
+
+  for _, pick in ipairs(p) do
+    local count = pick.counts[pick.key]
+    if count and count > 0 then
+      pick.counts[pick.key] = count - 1
+    end
+  end
+  ngx.ctx.balancer_in_flight = nil
+end
+
+-- forget drops the counts of the removed endpoints
+function _M.forget(counts, removed)
+  for _, key in ipairs(removed) do
+    counts[key] = nil
+  end
+end
+
+return _M
@@ -0,0 +1,66 @@
+local in_flight = require("balancer.in_flight")
+local util = require("util")
+
+local setmetatable = setmetatable
+
+-- least_request sends the request to the endpoint with the fewest requests
+-- in flight among a few endpoints picked at random, the "power of two
+-- choices". Comparing a few endpoints, rather than all of them, keeps the
+-- workers and the replicas of the controller from all choosing the same one.
+local _M = { name = "least_request" }
+
+local DEFAULT_CHOICES = 2
+
+local function choices(backend)
+  local tuning = backend.loadBalanceTuning
+  return tuning and tuning.leastRequestChoices or DEFAULT_CHOICES
+end
+
+function _M.is_affinitized()
+  return false
+end
+
+function _M.balance(self)
+  local counts = self.in_flight
+  local endpoint = in_flight.pick(self.peers, self.choices, function(candidate)
+    return ((counts[in_flight.key(candidate)] or 0) + 1) / (candidate.weight or 1)
+  end)
+  if not endpoint then
+    return nil
+  end
+
+  local key = in_flight.key(endpoint)
+  in_flight.start(counts, key)
+  return key
+end
+
+function _M.after_balance(_)
+  in_flight.finish()
+end
+
+function _M.sync(self, backend)
+  self.traffic_shaping_policy = backend.trafficShapingPolicy
+  self.alternative_backends = backend.alternativeBackends
+  self.choices = choices(backend)
+
+  local _, removed = util.diff_endpoints(self.peers, backend.endpoints)
+  in_flight.forget(self.in_flight, removed)
+
+  -- the weights change during the slow start of the endpoints
+  self.peers = backend.endpoints
+end
+
+function _M.new(self, backend)
+  local o = {
+    peers = backend.endpoints,
+    choices = choices(backend),
+    in_flight = {},
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+return _M
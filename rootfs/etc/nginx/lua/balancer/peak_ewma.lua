@@ -0,0 +1,159 @@
+-- Inspired by the Peak EWMA balancer of Finagle:
+-- https://github.com/twitter/finagle/blob/1bc837c4feafc0096e43c0e98516a8e1c50c4421
+--   /finagle-core/src/main/scala/com/twitter/finagle/loadbalancer/PeakEwma.scala
+
+local in_flight = require("balancer.in_flight")
+local util = require("util")
+local split = require("util.split")
+
+local ngx = ngx
+local pairs = pairs
+local ipairs = ipairs
+local tonumber = tonumber
+local setmetatable = setmetatable
+local math_exp = math.exp
+
+-- peak_ewma scores the endpoints with the moving average of their latency
+-- multiplied by their requests in flight. Unlike ewma the average jumps to
+-- a latency above it, an endpoint that slows down is avoided at once, and
+-- decays back when the endpoint is fast again. The latencies are measured
+-- by each worker.
+local _M = { name = "peak_ewma" }
+
+local DEFAULT_DECAY = 10 -- seconds
+local PICK_SET_SIZE = 2
+
+-- score of an endpoint with requests in flight whose latency is not known
+-- yet, it is sent a single request until the first response
+local PENALTY = 1e6
+
+local function decay(backend)
+  local tuning = backend.loadBalanceTuning
+  return tuning and tuning.peakEwmaDecay or DEFAULT_DECAY
+end
+
+-- cost returns the latency of the endpoint decayed since its last response
+local function cost(self, key, now)
+  local stat = self.stats[key]
+  if not stat then
+    return 0
+  end
+
+  local elapsed = now - stat.at
+  if elapsed <= 0 then
+    return stat.cost
+  end
+  return stat.cost * math_exp(-elapsed / self.decay)
+end
+
+function _M.score(self, endpoint, now)
+  local key = in_flight.key(endpoint)
+  local pending = self.in_flight[key] or 0
+  local c = cost(self, key, now or ngx.now())
+  if c == 0 and pending > 0 then
+    return PENALTY + pending
+  end
+  return c * (pending + 1) / (endpoint.weight or 1)
+end
+
+-- observe updates the latency of the endpoint with the one of a response
+function _M.observe(self, key, rtt, now)
+  local stat = self.stats[key]
+  if not stat then
+    self.stats[key] = { cost = rtt, at = now }
+    return
+  end
+
+  if rtt > stat.cost then
+    stat.cost = rtt
+  else
+    local elapsed = now - stat.at
+    local weight = math_exp(-(elapsed > 0 and elapsed or 0) / self.decay)
+    stat.cost = stat.cost * weight + rtt * (1 - weight)
+  end
+  stat.at = now
+end
+
+function _M.is_affinitized()
+  return false
+end
+
+function _M.balance(self)
+  local now = ngx.now()
+  local endpoint, score = in_flight.pick(self.peers, PICK_SET_SIZE, function(candidate)
+    return self:score(candidate, now)
+  end)
+  if not endpoint then
+    return nil
+  end
+
+  ngx.var.balancer_ewma_score = score
+
+  local key = in_flight.key(endpoint)
+  in_flight.start(self.in_flight, key)
+  return key
+end
+
+function _M.after_balance(self)
+  local upstream = split.get_last_value(ngx.var.upstream_addr)
+  if not util.is_blank(upstream) then
+    local response_time = tonumber(split.get_last_value(ngx.var.upstream_response_time)) or 0
+    local connect_time = tonumber(split.get_last_value(ngx.var.upstream_connect_time)) or 0
+    self:observe(upstream, connect_time + response_time, ngx.now())
+  end
+
+  in_flight.finish()
+end
+
+-- the new endpoints start with the average latency of the others, so they
+-- are not sent all the requests until their first responses
+local function average_cost(self, now)
+  local total, count = 0, 0
+  for key in pairs(self.stats) do
+    total = total + cost(self, key, now)
+    count = count + 1
+  end
+  if count == 0 then
+    return nil
+  end
+  return total / count
+end
+
+function _M.sync(self, backend)
+  self.traffic_shaping_policy = backend.trafficShapingPolicy
+  self.alternative_backends = backend.alternativeBackends
+  self.decay = decay(backend)
+
+  local added, removed = util.diff_endpoints(self.peers, backend.endpoints)
+  in_flight.forget(self.in_flight, removed)
+  for _, key in ipairs(removed) do
+    self.stats[key] = nil
+  end
+
+  local now = ngx.now()
+  local average = average_cost(self, now)
+  if average then
+    for _, key in ipairs(added) do
+      self.stats[key] = { cost = average, at = now }
+    end
+  end
+
+  -- the weights change during the slow start of the endpoints
+  self.peers = backend.endpoints
+end
+
+function _M.new(self, backend)
+  local o = {
+    peers = backend.endpoints,
+    decay = decay(backend),
+    in_flight = {},
+    stats = {},
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+return _M
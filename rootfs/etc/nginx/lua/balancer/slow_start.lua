@@ -0,0 +1,79 @@
+local ipairs = ipairs
+local pairs = pairs
+local math = math
+local table = table
+local ngx = ngx
+
+-- the weight of an endpoint that completed its warm-up, the weight of a
+-- warming endpoint is a share of it
+local FULL_WEIGHT = 100
+
+local _M = {}
+
+-- the time the worker first saw each endpoint of the backends with slow start
+local first_seen = {}
+
+local function shallow_copy(t)
+  local copy = {}
+  for k, v in pairs(t) do
+    copy[k] = v
+  end
+  return copy
+end
+
+-- apply returns a copy of the backend with the weights of its endpoints
+-- scaled by their warm-up progress, and whether some endpoints are still
+-- warming up. The backend is returned as is when slow start is not configured.
+function _M.apply(backend, now)
+  local slow_start = backend.slowStart
+  if not slow_start or not slow_start.duration or slow_start.duration <= 0 then
+    first_seen[backend.name] = nil
+    return backend, false
+  end
+
+  now = now or ngx.now()
+  local aggression = slow_start.aggression
+  if not aggression or aggression <= 0 then
+    aggression = 1
+  end
+
+  -- the endpoints present the first time the backend is seen are considered
+  -- warm, so a new worker does not ramp up the whole backend
+  local known = first_seen[backend.name]
+  local seen = {}
+  local warming = false
+
+  local endpoints = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    local key = endpoint.address .. ":" .. endpoint.port
+    local since = 0
+    if known then
+      since = known[key] or now
+    end
+    seen[key] = since
+
+    local weight = (endpoint.weight or 1) * FULL_WEIGHT
+    local elapsed = now - since
+    if elapsed < slow_start.duration then
+      warming = true
+      local progress = (elapsed / slow_start.duration) ^ (1 / aggression)
+      weight = math.max(1, math.floor(weight * progress))
+    end
+
+    local weighted_endpoint = shallow_copy(endpoint)
+    weighted_endpoint.weight = weight
+    table.insert(endpoints, weighted_endpoint)
+  end
+  first_seen[backend.name] = seen
+
+  local weighted_backend = shallow_copy(backend)
+  weighted_backend.endpoints = endpoints
+  return weighted_backend, warming
+end
+
+-- forget drops the endpoints tracked for a backend that no longer exists
+function _M.forget(backend_name)
+  first_seen[backend_name] = nil
+end
+
+return _M
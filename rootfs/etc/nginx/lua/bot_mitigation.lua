@@ -0,0 +1,211 @@
+local ipmatcher = require("resty.ipmatcher")
+local resty_string = require("resty.string")
+local hmac = require("util.hmac")
+
+local ngx = ngx
+local type = type
+local tonumber = tonumber
+local tostring = tostring
+local math_floor = math.floor
+local string_find = string.find
+local string_format = string.format
+local string_reverse = string.reverse
+local string_sub = string.sub
+local ngx_re_find = ngx.re.find
+
+local _M = {}
+
+local COOKIE_NAME = "ingress_bot_challenge"
+
+local config = {
+  key = nil,
+  ttl = 3600,
+}
+
+-- matchers of the allowlists, indexed by the list of CIDRs
+local matchers = {}
+
+local JS_CHALLENGE = [[<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body>
+<noscript>JavaScript is required to access this page.</noscript>
+<script>
+document.cookie = "%s=" + "%s".split("").reverse().join("") + "; path=/; max-age=%d; samesite=lax%s";
+window.location.reload();
+</script>
+</body>
+</html>
+]]
+
+-- set_config sets the key signing the cookies. The clients are not
+-- challenged when the ConfigMap does not set one, as a key generated by
+-- each replica would not accept the cookies of the others
+function _M.set_config(new_config)
+  config.key = nil
+  if type(new_config) == "table" then
+    if new_config.key and new_config.key ~= "" then
+      config.key = new_config.key
+    end
+    if tonumber(new_config.ttl) and tonumber(new_config.ttl) > 0 then
+      config.ttl = tonumber(new_config.ttl)
+    end
+  end
+
+  if not config.key then
+    ngx.log(ngx.WARN, "bot-challenge-key is not set, the clients of the bot-challenge annotation are not challenged")
+  end
+end
+
+-- sign returns the value of the cookie of a client that passed the
+-- challenge, bound to its address and user agent
+function _M.sign(expires, remote_addr, user_agent)
+  local signature = hmac.sha256(config.key, expires .. "|" .. remote_addr .. "|" .. (user_agent or ""))
+  return expires .. "." .. resty_string.to_hex(signature)
+end
+
+-- valid_cookie returns true when the cookie was signed for the client and
+-- has not expired
+function _M.valid_cookie(cookie, remote_addr, user_agent, now)
+  if not cookie then
+    return false
+  end
+
+  local dot = string_find(cookie, ".", 1, true)
+  local expires = dot and tonumber(string_sub(cookie, 1, dot - 1))
+  if not expires or expires < now then
+    return false
+  end
+
+  return cookie == _M.sign(string_sub(cookie, 1, dot - 1), remote_addr, user_agent)
+end
+
+local function matches(value, regex)
+  if not regex or not value then
+    return false
+  end
+
+  local from, _, err = ngx_re_find(value, regex, "joi")
+  if err then
+    ngx.log(ngx.ERR, "failed to match the user agent against ", regex, ": ", err)
+    return false
+  end
+  return from ~= nil
+end
+
+local function allowlisted(allowlist, remote_addr)
+  if not allowlist then
+    return false
+  end
+
+  local matcher = matchers[allowlist]
+  if not matcher then
+    local cidrs = {}
+    for cidr in allowlist:gmatch("[^,]+") do
+      cidrs[#cidrs + 1] = cidr
+    end
+
+    local err
+    matcher, err = ipmatcher.new(cidrs)
+    if not matcher then
+      ngx.log(ngx.ERR, "failed to parse the bot mitigation allowlist: ", err)
+      return false
+    end
+    matchers[allowlist] = matcher
+  end
+
+  return matcher:match(remote_addr)
+end
+
+local function listed(list, value)
+  if not list or not value or value == "" then
+    return false
+  end
+  return string_find("," .. list .. ",", "," .. value .. ",", 1, true) ~= nil
+end
+
+-- rate_exceeded counts the requests of the client to the Ingress in the
+-- current minute
+local function rate_exceeded(var, remote_addr)
+  local rate = tonumber(var.bot_challenge_rate)
+  if not rate then
+    return false
+  end
+
+  local key = string_format("%s/%s:%s:%d", var.namespace, var.ingress_name, remote_addr, math_floor(ngx.now() / 60))
+  local count, err = ngx.shared.bot_mitigation:incr(key, 1, 0, 60)
+  if not count then
+    ngx.log(ngx.ERR, "failed to count the requests of ", remote_addr, ": ", err)
+    return false
+  end
+  return count > rate
+end
+
+-- suspicious returns true when the client matches a rule of the location,
+-- every client is suspicious when the location has no rule
+local function suspicious(var, remote_addr, user_agent)
+  local user_agents = var.bot_challenge_user_agents
+  local fingerprints = var.bot_challenge_fingerprints
+  if not user_agents and not fingerprints and not var.bot_challenge_rate then
+    return true
+  end
+
+  return matches(user_agent, user_agents)
+    or listed(fingerprints, var.tls_ja4)
+    or rate_exceeded(var, remote_addr)
+end
+
+local function challenge(var, remote_addr, user_agent)
+  ngx.ctx.bot_mitigation = "challenged"
+
+  local cookie = _M.sign(tostring(math_floor(ngx.now()) + config.ttl), remote_addr, user_agent)
+  local secure = var.https == "on"
+  ngx.header["Cache-Control"] = "no-store"
+
+  if var.bot_challenge == "js" then
+    ngx.status = ngx.HTTP_FORBIDDEN
+    ngx.header["Content-Type"] = "text/html"
+    ngx.print(string_format(JS_CHALLENGE, COOKIE_NAME, string_reverse(cookie), config.ttl, secure and "; secure" or ""))
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  ngx.header["Set-Cookie"] = string_format("%s=%s; Path=/; Max-Age=%d; HttpOnly; SameSite=Lax%s",
+    COOKIE_NAME, cookie, config.ttl, secure and "; Secure" or "")
+  return ngx.redirect(var.request_uri, ngx.HTTP_TEMPORARY_REDIRECT)
+end
+
+-- rewrite challenges or blocks the suspicious clients of the locations
+-- with the bot-challenge or bot-block-user-agents annotations
+function _M.rewrite()
+  local var = ngx.var
+  local challenge_type = var.bot_challenge
+  if not challenge_type then
+    return
+  end
+
+  local remote_addr = var.remote_addr
+  local user_agent = var.http_user_agent
+  if allowlisted(var.bot_allowlist_source_range, remote_addr)
+    or matches(user_agent, var.bot_allowlist_user_agents) then
+    return
+  end
+
+  if matches(user_agent, var.bot_block_user_agents) then
+    ngx.ctx.bot_mitigation = "blocked"
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  if challenge_type == "" or not config.key then
+    return
+  end
+
+  if _M.valid_cookie(var["cookie_" .. COOKIE_NAME], remote_addr, user_agent, ngx.now()) then
+    return
+  end
+
+  if suspicious(var, remote_addr, user_agent) then
+    return challenge(var, remote_addr, user_agent)
+  end
+end
+
+return _M
@@ -0,0 +1,32 @@
+local ngx = ngx
+local tonumber = tonumber
+local math_random = math.random
+
+local _M = {}
+
+-- hit returns true for percent percent of the calls
+local function hit(percent)
+  return percent ~= nil and percent > 0 and math_random() * 100 < percent
+end
+
+-- rewrite delays and aborts a percentage of the requests of the locations
+-- using the chaos annotations. The delay is applied before the abort, an
+-- aborted request can also be delayed.
+function _M.rewrite()
+  local var = ngx.var
+  local abort_percent = tonumber(var.chaos_abort_percent)
+  local delay_ms = tonumber(var.chaos_delay_ms)
+  if not abort_percent and not delay_ms then
+    return
+  end
+
+  if delay_ms and delay_ms > 0 and hit(tonumber(var.chaos_delay_percent)) then
+    ngx.sleep(delay_ms / 1000)
+  end
+
+  if hit(abort_percent) then
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+end
+
+return _M
@@ -0,0 +1,155 @@
+local ngx = ngx
+local tonumber = tonumber
+local math_min = math.min
+
+local _M = {}
+
+-- interval between the attempts of a queued request to take a slot,
+-- doubled after each attempt
+local MIN_QUEUE_WAIT = 0.005 -- seconds
+local MAX_QUEUE_WAIT = 0.1 -- seconds
+
+-- the slot of a request redirected to another location is released using
+-- its request id, which is forgotten after this time
+local HOLDER_TTL = 3600 -- seconds
+
+local function counter_keys(key)
+  return "active:" .. key, "queued:" .. key
+end
+
+local function holder_key(request_id)
+  return "holder:" .. request_id
+end
+
+-- try_acquire takes a slot when less than limit are taken. The second
+-- value is false when the slot was not counted and must not be released.
+local function try_acquire(dict, active_key, limit)
+  local active, err = dict:incr(active_key, 1, 0)
+  if not active then
+    ngx.log(ngx.ERR, "failed to take a concurrency limit slot: ", err)
+    return true, false
+  end
+
+  if active > limit then
+    dict:incr(active_key, -1, 0)
+    return false, false
+  end
+  return true, true
+end
+
+-- get returns the concurrency limit of the current location, nil when
+-- the location does not have one
+function _M.get()
+  local limit = tonumber(ngx.var.concurrency_limit)
+  if not limit then
+    return nil
+  end
+
+  return {
+    limit = limit,
+    queue = tonumber(ngx.var.concurrency_limit_queue) or 0,
+    queue_timeout = tonumber(ngx.var.concurrency_limit_queue_timeout) or 0,
+    status = tonumber(ngx.var.concurrency_limit_status) or ngx.HTTP_SERVICE_UNAVAILABLE,
+  }
+end
+
+-- acquire takes a slot of key, waiting in the queue for another request to
+-- release one when all are taken. It returns false when the request must be
+-- rejected, and whether the slot was counted.
+function _M.acquire(key, policy)
+  local dict = ngx.shared.concurrency_limit
+  local active_key, queued_key = counter_keys(key)
+
+  local acquired, counted = try_acquire(dict, active_key, policy.limit)
+  if acquired or policy.queue <= 0 then
+    return acquired, counted
+  end
+
+  local queued, err = dict:incr(queued_key, 1, 0)
+  if not queued then
+    ngx.log(ngx.ERR, "failed to queue the request: ", err)
+    return false, false
+  end
+  if queued > policy.queue then
+    dict:incr(queued_key, -1, 0)
+    return false, false
+  end
+
+  local deadline = ngx.now() + policy.queue_timeout
+  local wait = MIN_QUEUE_WAIT
+  while not acquired do
+    local remaining = deadline - ngx.now()
+    if remaining <= 0 then
+      break
+    end
+
+    ngx.sleep(math_min(wait, remaining))
+    wait = math_min(wait * 2, MAX_QUEUE_WAIT)
+    acquired, counted = try_acquire(dict, active_key, policy.limit)
+  end
+
+  dict:incr(queued_key, -1, 0)
+  return acquired, counted
+end
+
+-- release frees the slot of key taken by acquire
+function _M.release(key)
+  local active_key = counter_keys(key)
+  local active, err = ngx.shared.concurrency_limit:incr(active_key, -1, 0)
+  if not active then
+    ngx.log(ngx.ERR, "failed to release a concurrency limit slot: ", err)
+  end
+end
+
+-- rewrite takes a slot of the Ingress of the location, rejecting the
+-- request when none is available before the end of the queue timeout
+function _M.rewrite()
+  local policy = _M.get()
+  if not policy then
+    return
+  end
+
+  local dict = ngx.shared.concurrency_limit
+  local holder = holder_key(ngx.var.request_id)
+  -- a request redirected to another location keeps its slot
+  if ngx.ctx.concurrency_limit_key or dict:get(holder) then
+    return
+  end
+
+  local key = ngx.var.namespace .. "/" .. ngx.var.ingress_name
+  local acquired, counted = _M.acquire(key, policy)
+  if not acquired then
+    ngx.log(ngx.INFO, "concurrency limit of Ingress ", key, " exceeded, rejecting request")
+    return ngx.exit(policy.status)
+  end
+
+  if counted then
+    ngx.ctx.concurrency_limit_key = key
+    local ok, err = dict:set(holder, key, HOLDER_TTL)
+    if not ok then
+      ngx.log(ngx.ERR, "failed to store the concurrency limit slot of the request: ", err)
+    end
+  end
+end
+
+-- log releases the slot taken by the request, also when it was
+-- redirected to a location without concurrency limit
+function _M.log()
+  -- the variables of the location taking the slot are kept by redirects
+  if not ngx.var.concurrency_limit then
+    return
+  end
+
+  local dict = ngx.shared.concurrency_limit
+  local holder = holder_key(ngx.var.request_id)
+  local key = ngx.ctx.concurrency_limit_key or dict:get(holder)
+  if not key then
+    return
+  end
+
+  ngx.ctx.concurrency_limit_key = nil
+  dict:delete(holder)
+  _M.release(key)
+end
+
+return _M
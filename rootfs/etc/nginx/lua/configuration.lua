@@ -21,6 +21,14 @@ function _M.get_backends_data()
   return configuration_data:get("backends")
 end
 
+function _M.get_dynamic_servers_data()
+  return configuration_data:get("dynamic_servers")
+end
+
+function _M.get_dynamic_servers_version()
+  return configuration_data:get("dynamic_servers_version") or 0
+end
+
 function _M.get_general_data()
   return configuration_data:get("general")
 end
@@ -220,6 +228,38 @@ local function handle_backends()
   ngx.status = ngx.HTTP_CREATED
 end
 
+local function handle_dynamic_servers()
+  if ngx.var.request_method == "GET" then
+    ngx.status = ngx.HTTP_OK
+    ngx.print(_M.get_dynamic_servers_data())
+    return
+  end
+
+  local dynamic_servers = fetch_request_body()
+  if not dynamic_servers then
+    ngx.log(ngx.ERR, "dynamic-configuration: unable to read valid request body")
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local success, err = configuration_data:set("dynamic_servers", dynamic_servers)
+  if not success then
+    ngx.log(ngx.ERR, "dynamic-configuration: error updating dynamic servers: " .. tostring(err))
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  -- the workers decode the dynamic servers again when the version changes
+  success, err = configuration_data:incr("dynamic_servers_version", 1, 0)
+  if not success then
+    ngx.log(ngx.ERR, "dynamic-configuration: error updating the dynamic servers version: " .. tostring(err))
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
 function _M.call()
   if ngx.var.request_method ~= "POST" and ngx.var.request_method ~= "GET" then
     ngx.status = ngx.HTTP_BAD_REQUEST
@@ -247,6 +287,11 @@ function _M.call()
     return
   end
 
+  if ngx.var.request_uri == "/configuration/dynamic-servers" then
+    handle_dynamic_servers()
+    return
+  end
+
   ngx.status = ngx.HTTP_NOT_FOUND
   ngx.print("Not found!")
 end
@@ -0,0 +1,124 @@
+local ngx = ngx
+local type = type
+local tonumber = tonumber
+local setmetatable = setmetatable
+local math_min = math.min
+local math_random = math.random
+
+local _M = {}
+
+local config = {
+  -- milliseconds, 0 disables the backoff
+  base = 0,
+  max = 10000,
+}
+
+local function failures_key(peer)
+  return "failures:" .. peer
+end
+
+local function backoff_key(peer)
+  return "backoff:" .. peer
+end
+
+-- set_config sets the backoff of the first connect failure of an endpoint
+-- and the maximum backoff, in milliseconds
+function _M.set_config(new_config)
+  if type(new_config) ~= "table" then
+    return
+  end
+
+  local base = tonumber(new_config.base)
+  if base and base >= 0 then
+    config.base = base
+  end
+  local max = tonumber(new_config.max)
+  if max and max > 0 then
+    config.max = max
+  end
+end
+
+function _M.enabled()
+  return config.base > 0
+end
+
+-- backoff returns the time in milliseconds an endpoint is not tried after
+-- its nth consecutive connect failure. It doubles with each failure up to
+-- the maximum, half of it being random so that the NGINX workers and
+-- replicas do not all try the endpoint again at the same time.
+function _M.backoff(failures)
+  local delay = math_min(config.max, config.base * 2 ^ (failures - 1))
+  return delay / 2 + math_random() * delay / 2
+end
+
+function _M.is_backing_off(peer)
+  return ngx.shared.balancer_connect_backoff:get(backoff_key(peer)) ~= nil
+end
+
+-- record_failure backs off the endpoint after a failure to connect to it,
+-- the failures of the requests connecting to it while it was backing off
+-- are not counted
+function _M.record_failure(peer)
+  local dict = ngx.shared.balancer_connect_backoff
+  if _M.is_backing_off(peer) then
+    return
+  end
+
+  local failures, err = dict:incr(failures_key(peer), 1, 0)
+  if not failures then
+    ngx.log(ngx.ERR, "failed to count the connect failures of ", peer, ": ", err)
+    return
+  end
+  -- the failures are forgotten when the endpoint did not fail for a while
+  dict:expire(failures_key(peer), config.max * 2 / 1000)
+
+  local ok
+  ok, err = dict:set(backoff_key(peer), failures, _M.backoff(failures) / 1000)
+  if not ok then
+    ngx.log(ngx.ERR, "failed to back off ", peer, ": ", err)
+  end
+end
+
+-- record_success resets the backoff of the endpoint after a connection
+function _M.record_success(peer)
+  local dict = ngx.shared.balancer_connect_backoff
+  dict:delete(failures_key(peer))
+  dict:delete(backoff_key(peer))
+end
+
+-- record records the connect failures and successes of the tries of the
+-- request from the values of $upstream_addr and $upstream_connect_time,
+-- the connect time of a try that did not connect is "-"
+function _M.record(upstream_addr, upstream_connect_time)
+  if not _M.enabled() or not upstream_addr or not upstream_connect_time then
+    return
+  end
+
+  local connect_times = {}
+  for connect_time in upstream_connect_time:gmatch("[^%s,:]+") do
+    connect_times[#connect_times + 1] = connect_time
+  end
+
+  local i = 0
+  for peer in upstream_addr:gmatch("[^%s,:][^%s,]*") do
+    i = i + 1
+    -- the name of the upstream when no endpoint was tried
+    if peer:find(":%d+$") then
+      if connect_times[i] == "-" then
+        _M.record_failure(peer)
+      elseif connect_times[i] then
+        _M.record_success(peer)
+      end
+    end
+  end
+end
+
+-- avoided_peers returns the endpoints the balancer does not pick, the
+-- ones already tried by the request and the ones backing off
+function _M.avoided_peers(tried)
+  return setmetatable({}, { __index = function(_, peer)
+    return (tried and tried[peer]) or _M.is_backing_off(peer)
+  end })
+end
+
+return _M
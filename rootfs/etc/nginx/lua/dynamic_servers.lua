@@ -0,0 +1,109 @@
+local cjson = require("cjson.safe")
+local configuration = require("configuration")
+
+local ngx = ngx
+local ipairs = ipairs
+local tostring = tostring
+local string_sub = string.sub
+
+local _M = {}
+
+-- servers decoded by this worker and the version of the configuration
+-- they were decoded from
+local servers = {}
+local servers_version = -1
+
+local function sync_servers()
+  local version = configuration.get_dynamic_servers_version()
+  if version == servers_version then
+    return
+  end
+
+  local raw = configuration.get_dynamic_servers_data()
+  local decoded, err = cjson.decode(raw or "{}")
+  if not decoded then
+    ngx.log(ngx.ERR, "could not parse dynamic servers: ", err)
+    return
+  end
+
+  servers = decoded
+  servers_version = version
+end
+
+local function starts_with(value, prefix)
+  return string_sub(value, 1, #prefix) == prefix
+end
+
+-- matches returns true when a location of a dynamic server matches the uri,
+-- following the semantics of the path types of the Ingress API
+function _M.matches(location, uri)
+  local path = location.path
+  if location.pathType == "Exact" then
+    return uri == path
+  end
+
+  if location.pathType == "Prefix" then
+    if path == "/" then
+      return true
+    end
+    if string_sub(path, -1) == "/" then
+      path = string_sub(path, 1, -2)
+    end
+    return uri == path or starts_with(uri, path .. "/")
+  end
+
+  return starts_with(uri, path)
+end
+
+-- find_location returns the location of a dynamic server serving the uri.
+-- The locations are sorted by the length of their path, an exact location
+-- takes precedence over the others.
+function _M.find_location(locations, uri)
+  local found
+  for _, location in ipairs(locations) do
+    if _M.matches(location, uri) then
+      if location.pathType == "Exact" then
+        return location
+      end
+      found = found or location
+    end
+  end
+  return found
+end
+
+-- rewrite routes the requests received by the catch-all server for a
+-- dynamic server to the backend of its location
+function _M.rewrite()
+  local var = ngx.var
+  if var.dynamic_servers ~= "true" then
+    return
+  end
+
+  sync_servers()
+
+  local locations = servers[var.host]
+  if not locations then
+    return
+  end
+
+  local location = _M.find_location(locations, var.uri)
+  if not location then
+    return
+  end
+
+  var.proxy_upstream_name = location.backend
+  var.proxy_host = location.backend
+  var.namespace = location.namespace
+  var.ingress_name = location.ingress
+  var.service_name = location.service
+  var.service_port = location.servicePort
+  var.location_path = location.path
+
+  var.ssl_redirect = tostring(location.sslRedirect == true)
+  var.force_ssl_redirect = tostring(location.forceSSLRedirect == true)
+  var.force_no_ssl_redirect = tostring(location.forceNoSSLRedirect == true)
+  var.preserve_trailing_slash = tostring(location.preserveTrailingSlash == true)
+  var.use_port_in_redirects = tostring(location.usePortInRedirects == true)
+end
+
+return _M
@@ -0,0 +1,345 @@
+local cjson = require("cjson.safe")
+local ipmatcher = require("resty.ipmatcher")
+
+local ngx = ngx
+local io = io
+local type = type
+local ipairs = ipairs
+local tonumber = tonumber
+local string_find = string.find
+local string_sub = string.sub
+local string_byte = string.byte
+local math_max = math.max
+
+local _M = {}
+
+-- matchers of the introspection allowlists, indexed by the list of CIDRs
+local matchers = {}
+
+local BYTE_HASH = string_byte("#")
+local BYTE_QUOTE = string_byte('"')
+local BYTE_BACKSLASH = string_byte("\\")
+local BYTE_NEWLINE = string_byte("\n")
+
+local INTROSPECTION_FIELDS = {
+  __schema = true,
+  __type = true,
+}
+
+-- tokenize returns the names and punctuators of a GraphQL document, skipping
+-- comments, strings and numbers as they do not affect the selection sets.
+local function tokenize(query)
+  local tokens = {}
+  local i = 1
+  local len = #query
+
+  while i <= len do
+    local c = string_byte(query, i)
+
+    if c == BYTE_HASH then
+      local nl = string_find(query, "\n", i, true)
+      i = nl and nl + 1 or len + 1
+    elseif c == BYTE_QUOTE then
+      if string_sub(query, i, i + 2) == '"""' then
+        local close = string_find(query, '"""', i + 3, true)
+        i = close and close + 3 or len + 1
+      else
+        i = i + 1
+        while i <= len do
+          local s = string_byte(query, i)
+          if s == BYTE_BACKSLASH then
+            i = i + 2
+          elseif s == BYTE_QUOTE or s == BYTE_NEWLINE then
+            i = i + 1
+            break
+          else
+            i = i + 1
+          end
+        end
+      end
+    elseif string_sub(query, i, i + 2) == "..." then
+      tokens[#tokens + 1] = "..."
+      i = i + 3
+    else
+      local s, e = string_find(query, "^[_A-Za-z][_0-9A-Za-z]*", i)
+      if s then
+        tokens[#tokens + 1] = string_sub(query, s, e)
+        i = e + 1
+      else
+        s, e = string_find(query, "^[-0-9.eE+]+", i)
+        if s then
+          i = e + 1
+        else
+          local p = string_sub(query, i, i)
+          if string_find("{}():@$!=[]|&", p, 1, true) then
+            tokens[#tokens + 1] = p
+          end
+          i = i + 1
+        end
+      end
+    end
+  end
+
+  return tokens
+end
+
+-- parse splits the document in its definitions, recording for each one the
+-- depth of its selection set, the number of fields it selects and the
+-- fragments it spreads.
+local function parse(query)
+  local tokens = tokenize(query)
+  local operations = {}
+  local fragments = {}
+  local introspection = false
+
+  local current
+  local fragment_name
+  local depth = 0
+  local parens = 0
+  local i = 1
+
+  while i <= #tokens do
+    local t = tokens[i]
+
+    if t == "(" then
+      parens = parens + 1
+    elseif t == ")" then
+      parens = parens - 1
+    elseif parens > 0 then -- luacheck: ignore 542
+      -- arguments and variable definitions can contain input objects
+    elseif depth == 0 then
+      if t == "fragment" then
+        fragment_name = tokens[i + 1]
+        i = i + 1
+      elseif t == "{" then
+        current = { depth = 1, complexity = 0, spreads = {} }
+        if fragment_name then
+          fragments[fragment_name] = current
+        else
+          operations[#operations + 1] = current
+        end
+        fragment_name = nil
+        depth = 1
+      end
+    elseif t == "{" then
+      depth = depth + 1
+      current.depth = math_max(current.depth, depth)
+    elseif t == "}" then
+      depth = depth - 1
+    elseif t == "@" then
+      -- directives are not fields
+      i = i + 1
+    elseif t == "..." then
+      local name = tokens[i + 1]
+      if name == "on" then
+        -- inline fragment, skip the type condition
+        i = i + 2
+      elseif name and name ~= "{" and name ~= "@" then
+        current.spreads[#current.spreads + 1] = { name = name, depth = depth }
+        i = i + 1
+      end
+    elseif string_find(t, "^[_A-Za-z]") then
+      if tokens[i + 1] ~= ":" then
+        current.complexity = current.complexity + 1
+        if INTROSPECTION_FIELDS[t] then
+          introspection = true
+        end
+      end
+    end
+
+    i = i + 1
+  end
+
+  return operations, fragments, introspection
+end
+
+-- resolve adds to a definition the depth and complexity of the fragments it
+-- spreads. Returns nil when a fragment spreads itself.
+local function resolve(definition, fragments, visiting)
+  local depth = definition.depth
+  local complexity = definition.complexity
+
+  for _, spread in ipairs(definition.spreads) do
+    local fragment = fragments[spread.name]
+    if fragment then
+      if visiting[spread.name] then
+        return nil
+      end
+
+      visiting[spread.name] = true
+      local fdepth, fcomplexity = resolve(fragment, fragments, visiting)
+      visiting[spread.name] = nil
+      if not fdepth then
+        return nil
+      end
+
+      depth = math_max(depth, spread.depth + fdepth - 1)
+      complexity = complexity + fcomplexity
+    end
+  end
+
+  return depth, complexity
+end
+
+-- analyze returns the maximum depth and the complexity of the operations of
+-- a GraphQL document, and whether it contains an introspection query.
+function _M.analyze(query)
+  local operations, fragments, introspection = parse(query)
+
+  local depth = 0
+  local complexity = 0
+  for _, operation in ipairs(operations) do
+    local odepth, ocomplexity = resolve(operation, fragments, {})
+    if not odepth then
+      return nil, nil, introspection, "fragment cycle detected"
+    end
+
+    depth = math_max(depth, odepth)
+    complexity = complexity + ocomplexity
+  end
+
+  return depth, complexity, introspection
+end
+
+local function read_body()
+  ngx.req.read_body()
+
+  local body = ngx.req.get_body_data()
+  if body then
+    return body
+  end
+
+  local file_name = ngx.req.get_body_file()
+  if not file_name then
+    return nil
+  end
+
+  local file, err = io.open(file_name, "rb")
+  if not file then
+    ngx.log(ngx.ERR, "failed to open request body file: ", err)
+    return nil
+  end
+
+  body = file:read("*a")
+  file:close()
+  return body
+end
+
+-- get_queries returns the GraphQL documents sent in the request, or an error
+-- when the request can not be analyzed, as forwarding it would bypass the
+-- limits.
+local function get_queries()
+  if ngx.req.get_method() == "GET" then
+    local query = ngx.req.get_uri_args()["query"]
+    if query == nil then
+      return {}
+    end
+    if type(query) ~= "string" then
+      return nil, "the query parameter must be sent once"
+    end
+    return { query }
+  end
+
+  local body = read_body()
+  if not body then
+    return {}
+  end
+
+  local content_type = ngx.var.content_type or ""
+  if string_find(content_type, "application/graphql", 1, true) then
+    return { body }
+  end
+
+  local payload = cjson.decode(body)
+  if type(payload) ~= "table" then
+    return nil, "the request body is not a valid GraphQL request"
+  end
+
+  -- batched requests send a list of operations
+  if payload[1] == nil then
+    payload = { payload }
+  end
+
+  local queries = {}
+  for _, operation in ipairs(payload) do
+    if type(operation) ~= "table" then
+      return nil, "the request body is not a valid GraphQL request"
+    end
+
+    -- persisted queries only send the hash of the query
+    if operation.query ~= nil then
+      if type(operation.query) ~= "string" then
+        return nil, "the query must be a string"
+      end
+      queries[#queries + 1] = operation.query
+    end
+  end
+  return queries
+end
+
+local function introspection_allowed(allowlist)
+  if not allowlist or allowlist == "" then
+    return false
+  end
+
+  local matcher = matchers[allowlist]
+  if not matcher then
+    local cidrs = {}
+    for cidr in allowlist:gmatch("[^,]+") do
+      cidrs[#cidrs + 1] = cidr
+    end
+
+    local err
+    matcher, err = ipmatcher.new(cidrs)
+    if not matcher then
+      ngx.log(ngx.ERR, "failed to parse GraphQL introspection allowlist: ", err)
+      return false
+    end
+    matchers[allowlist] = matcher
+  end
+
+  return matcher:match(ngx.var.remote_addr)
+end
+
+local function reject(status, message)
+  ngx.status = status
+  ngx.header["Content-Type"] = "application/json"
+  ngx.say(cjson.encode({ errors = { { message = message } } }))
+  return ngx.exit(status)
+end
+
+function _M.rewrite()
+  if ngx.var.graphql_enabled ~= "true" then
+    return
+  end
+
+  local max_depth = tonumber(ngx.var.graphql_max_depth) or 0
+  local max_complexity = tonumber(ngx.var.graphql_max_complexity) or 0
+
+  local queries, err = get_queries()
+  if not queries then
+    return reject(ngx.HTTP_BAD_REQUEST, err)
+  end
+
+  for _, query in ipairs(queries) do
+    local depth, complexity, introspection, err = _M.analyze(query)
+    if err then
+      return reject(ngx.HTTP_BAD_REQUEST, err)
+    end
+
+    if introspection and not introspection_allowed(ngx.var.graphql_introspection_allowlist) then
+      return reject(ngx.HTTP_FORBIDDEN, "introspection is not allowed")
+    end
+
+    if max_depth > 0 and depth > max_depth then
+      return reject(ngx.HTTP_BAD_REQUEST, "query depth " .. depth .. " exceeds the maximum of " .. max_depth)
+    end
+
+    if max_complexity > 0 and complexity > max_complexity then
+      return reject(ngx.HTTP_BAD_REQUEST,
+        "query complexity " .. complexity .. " exceeds the maximum of " .. max_complexity)
+    end
+  end
+end
+
+return _M
@@ -0,0 +1,231 @@
+local ngx = ngx
+local type = type
+local ipairs = ipairs
+local string_gmatch = string.gmatch
+local string_lower = string.lower
+local string_match = string.match
+local string_sub = string.sub
+local table_concat = table.concat
+local ngx_re_gsub = ngx.re.gsub
+
+local _M = {}
+
+-- the bodies are buffered to rewrite the links split between chunks, the
+-- links of the larger ones are not rewritten
+local MAX_BODY_SIZE = 2 * 1024 * 1024
+
+local REWRITTEN_TYPES = {
+  ["text/html"] = "html",
+  ["application/xhtml+xml"] = "html",
+  ["text/css"] = "css",
+}
+
+-- the attributes of the HTML elements containing a URL
+local HTML_LINK_REGEX = [[(\s(?:href|src|action|formaction|poster|cite|background)\s*=\s*)(["'])([^"']*)\2]]
+-- the URLs of the stylesheets, the style elements and the style attributes
+local CSS_URL_REGEX = [[(url\(\s*)(["']?)([^"')\s]*)\2(\s*\))]]
+
+local function starts_with(value, prefix)
+  return string_sub(value, 1, #prefix) == prefix
+end
+
+-- under returns true when the path or URL is below the base
+local function under(value, base)
+  if not starts_with(value, base) then
+    return false
+  end
+  local next_char = string_sub(value, #base + 1, #base + 1)
+  return next_char == "" or next_char == "/" or next_char == "?" or next_char == "#"
+end
+
+-- get_config returns the link rewriting of the location, nil when the
+-- links of the location are not rewritten
+function _M.get_config()
+  local var = ngx.var
+  if var.rewrite_links ~= "true" then
+    return nil
+  end
+
+  local config = {
+    prefix = var.rewrite_links_prefix or "",
+    upstream_prefix = var.rewrite_links_upstream_prefix or "",
+    upstream_urls = {},
+    upstream_hosts = {},
+  }
+  for url in string_gmatch(var.rewrite_links_upstream_urls or "", "%S+") do
+    config.upstream_urls[#config.upstream_urls + 1] = url
+    local host = string_match(url, "^https?://([^/:]+)")
+    if host then
+      config.upstream_hosts[string_lower(host)] = true
+    end
+  end
+  return config
+end
+
+-- rewrite_path maps a path of the upstream below the path prefix of the
+-- location. The paths outside of the application are kept.
+function _M.rewrite_path(config, path)
+  local prefix, upstream_prefix = config.prefix, config.upstream_prefix
+  if prefix == upstream_prefix then
+    return path
+  end
+
+  local rest
+  if upstream_prefix == "" then
+    -- an application knowing its prefix already writes it in its links
+    if prefix ~= "" and under(path, prefix) then
+      return path
+    end
+    rest = path
+  else
+    if not under(path, upstream_prefix) then
+      return path
+    end
+    rest = string_sub(path, #upstream_prefix + 1)
+  end
+
+  local rewritten = prefix .. rest
+  if rewritten == "" then
+    return "/"
+  end
+  return rewritten
+end
+
+-- rewrite_url rewrites the absolute URLs of the upstream and the paths
+-- starting with a slash, the relative URLs already resolve below the prefix
+function _M.rewrite_url(config, url)
+  for _, upstream_url in ipairs(config.upstream_urls) do
+    if under(url, upstream_url) then
+      local rest = string_sub(url, #upstream_url + 1)
+      if string_sub(rest, 1, 1) ~= "/" then
+        rest = "/" .. rest
+      end
+      return _M.rewrite_path(config, rest)
+    end
+  end
+
+  if string_sub(url, 1, 1) == "/" and string_sub(url, 2, 2) ~= "/" then
+    return _M.rewrite_path(config, url)
+  end
+  return url
+end
+
+-- rewrite_cookie rewrites the Path attribute of a Set-Cookie header and
+-- removes its Domain attribute when it is the host of an upstream URL, the
+-- cookie is then sent back to the host of the request
+function _M.rewrite_cookie(config, cookie)
+  local parts = {}
+  for part in string_gmatch(cookie, "[^;]+") do
+    if #parts == 0 then
+      parts[1] = part
+    else
+      local name, value = string_match(part, "^%s*([^=]*)=?(.-)%s*$")
+      name = string_lower(name)
+      if name == "path" then
+        parts[#parts + 1] = " Path=" .. _M.rewrite_path(config, value)
+      elseif name ~= "domain" or not config.upstream_hosts[string_lower((value:gsub("^%.", "")))] then
+        parts[#parts + 1] = part
+      end
+    end
+  end
+  return table_concat(parts, ";")
+end
+
+-- rewrite_body rewrites the links of an HTML page or a stylesheet
+function _M.rewrite_body(config, body, html)
+  local rewritten, err
+  if html then
+    rewritten, _, err = ngx_re_gsub(body, HTML_LINK_REGEX, function(m)
+      return m[1] .. m[2] .. _M.rewrite_url(config, m[3]) .. m[2]
+    end, "ijo")
+    if not rewritten then
+      ngx.log(ngx.ERR, "failed to rewrite the links of the response: ", err)
+      return body
+    end
+    body = rewritten
+  end
+
+  rewritten, _, err = ngx_re_gsub(body, CSS_URL_REGEX, function(m)
+    return m[1] .. m[2] .. _M.rewrite_url(config, m[3]) .. m[2] .. m[4]
+  end, "ijo")
+  if not rewritten then
+    ngx.log(ngx.ERR, "failed to rewrite the links of the response: ", err)
+    return body
+  end
+  return rewritten
+end
+
+-- header_filter rewrites the headers of the responses of the locations
+-- with rewrite-links and prepares the rewriting of their body
+function _M.header_filter()
+  local config = _M.get_config()
+  if not config then
+    return
+  end
+
+  local header = ngx.header
+  for _, name in ipairs({ "Location", "Content-Location" }) do
+    local value = header[name]
+    if type(value) == "string" then
+      header[name] = _M.rewrite_url(config, value)
+    end
+  end
+
+  local cookies = header["Set-Cookie"]
+  if cookies then
+    if type(cookies) == "string" then
+      cookies = { cookies }
+    end
+    local rewritten = {}
+    for i, cookie in ipairs(cookies) do
+      rewritten[i] = _M.rewrite_cookie(config, cookie)
+    end
+    header["Set-Cookie"] = rewritten
+  end
+
+  -- the upstream was asked for an uncompressed response
+  if header["Content-Encoding"] then
+    return
+  end
+
+  local content_type = header["Content-Type"]
+  local kind = content_type and REWRITTEN_TYPES[string_lower(string_match(content_type, "^[^;%s]+") or "")]
+  if not kind then
+    return
+  end
+
+  header["Content-Length"] = nil
+  ngx.ctx.link_rewrite = { config = config, html = kind == "html", chunks = {}, size = 0 }
+end
+
+-- body_filter buffers the body of the response and rewrites its links
+function _M.body_filter()
+  local state = ngx.ctx.link_rewrite
+  if not state or state.passthrough then
+    return
+  end
+
+  local chunk, eof = ngx.arg[1], ngx.arg[2]
+  if chunk and chunk ~= "" then
+    state.chunks[#state.chunks + 1] = chunk
+    state.size = state.size + #chunk
+  end
+
+  if state.size > MAX_BODY_SIZE then
+    ngx.log(ngx.WARN, "the response is larger than ", MAX_BODY_SIZE, " bytes, its links are not rewritten")
+    ngx.arg[1] = table_concat(state.chunks)
+    state.chunks = nil
+    state.passthrough = true
+    return
+  end
+
+  if not eof then
+    ngx.arg[1] = nil
+    return
+  end
+
+  ngx.arg[1] = _M.rewrite_body(state.config, table_concat(state.chunks), state.html)
+  ngx.ctx.link_rewrite = nil
+end
+
+return _M
@@ -1,5 +1,6 @@
 local ngx_re_split = require("ngx.re").split
 local string_to_bool = require("util").string_to_bool
+local next_upstream = require("next_upstream")
 
 local certificate_configured_for_current_request =
   require("certificate").configured_for_current_request
@@ -186,6 +187,13 @@ function _M.header()
     end
     ngx.header["Strict-Transport-Security"] = value
   end
+
+  if config.upstream_attempts_header and ngx.var.upstream_attempts_trusted == "1" then
+    local attempts = next_upstream.attempts(ngx.var.upstream_addr)
+    if attempts > 0 then
+      ngx.header["X-Upstream-Attempts"] = attempts
+    end
+  end
 end
 
 return _M
@@ -1,10 +1,10 @@
 local ngx = ngx
 local tonumber = tonumber
-local assert = assert
 local string = string
 local tostring = tostring
 local socket = ngx.socket.tcp
 local cjson = require("cjson.safe")
+local ssl_clienthello = require("ngx.ssl.clienthello")
 local new_tab = require "table.new"
 local clear_tab = require "table.clear"
 local table = table
@@ -24,21 +24,77 @@ local metrics_raw_batch = new_tab(MAX_BATCH_SIZE, 0)
 
 local _M = {}
 
+-- the batches that could not be sent, by the workers shutting down on
+-- reload or while the controller was not listening, are kept in a shared
+-- dictionary and sent by the next flush of any worker, so the requests of
+-- the old workers are not missing from the metrics after a reload
+local PENDING_KEY = "batches"
+
 local function send(payload)
-  local s = assert(socket())
-  assert(s:connect("unix:/tmp/nginx/prometheus-nginx.socket"))
-  assert(s:send(payload))
-  assert(s:close())
+  local s, err = socket()
+  if not s then
+    return nil, err
+  end
+
+  local ok
+  ok, err = s:connect("unix:/tmp/nginx/prometheus-nginx.socket")
+  if not ok then
+    return nil, err
+  end
+
+  ok, err = s:send(payload)
+  s:close()
+  if not ok then
+    return nil, err
+  end
+  return true
+end
+
+local function keep_pending(payload)
+  local pending = ngx.shared.monitor_pending
+  if not pending then
+    return
+  end
+
+  local _, err = pending:rpush(PENDING_KEY, payload)
+  if err then
+    ngx.log(ngx.ERR, string.format("dropping metrics batch, error when keeping it: %s", tostring(err)))
+  end
+end
+
+-- send_pending sends the batches kept by the previous flushes, it stops at
+-- the first batch that can not be sent
+local function send_pending()
+  local pending = ngx.shared.monitor_pending
+  if not pending then
+    return true
+  end
+
+  while true do
+    local payload = pending:lpop(PENDING_KEY)
+    if not payload then
+      return true
+    end
+
+    local ok, err = send(payload)
+    if not ok then
+      ngx.log(ngx.WARN, string.format("error when sending pending metrics: %s", tostring(err)))
+      -- the order of the batches does not matter to the counters
+      keep_pending(payload)
+      return false
+    end
+  end
 end
 
 local function metrics()
-  return {
+  local m = {
     host = ngx.var.host or "-",
     namespace = ngx.var.namespace or "-",
     ingress = ngx.var.ingress_name or "-",
     service = ngx.var.service_name or "-",
     canary = ngx.var.proxy_alternative_upstream_name or "-",
     path = ngx.var.location_path or "-",
+    uri = ngx.var.uri,
 
     method = ngx.var.request_method or "-",
     status = ngx.var.status or "-",
@@ -50,13 +106,30 @@ local function metrics()
     upstreamHeaderTime = tonumber(ngx.var.upstream_header_time) or -1,
     upstreamResponseTime = tonumber(ngx.var.upstream_response_time) or -1,
     upstreamResponseLength = tonumber(ngx.var.upstream_response_length) or -1,
-    --upstreamStatus = ngx.var.upstream_status or "-",
+    upstreamAddr = ngx.var.upstream_addr or "-",
+    upstreamStatus = ngx.var.upstream_status or "-",
+    upstreamRetries = ngx.ctx.balancer_retries or 0,
+    retryBudgetExhausted = ngx.ctx.balancer_retry_budget_exhausted or false,
+    upstreamCacheStatus = ngx.var.upstream_cache_status or "-",
+    botMitigation = ngx.ctx.bot_mitigation or "-",
   }
+
+  -- the details of a TLS connection are reported by its first request
+  if ngx.var.https == "on" and ngx.var.connection_requests == "1" then
+    m.sslServerName = ngx.var.ssl_server_name or "-"
+    m.sslProtocol = ngx.var.ssl_protocol or "-"
+    m.sslCipher = ngx.var.ssl_cipher or "-"
+    m.sslClientVerify = ngx.var.ssl_client_verify or "-"
+  end
+
+  return m
 end
 
 local function flush(premature)
-  if premature then
-    return
+  -- the pending batches are left to the workers replacing the one
+  -- shutting down
+  if not premature and not send_pending() then
+    premature = true
   end
 
   if metrics_count == 0 then
@@ -79,7 +152,17 @@ local function flush(premature)
   local payload = table.concat(request_metrics)
 
   clear_tab(metrics_raw_batch)
-  send(payload)
+
+  if premature then
+    keep_pending(payload)
+    return
+  end
+
+  local ok, err = send(payload)
+  if not ok then
+    ngx.log(ngx.WARN, string.format("error when sending metrics, keeping them: %s", tostring(err)))
+    keep_pending(payload)
+  end
 end
 
 local function set_metrics_max_batch_size(max_batch_size)
@@ -96,13 +179,12 @@ function _M.init_worker(max_batch_size)
   end
 end
 
-function _M.call()
+local function add(metrics_obj)
   if metrics_count >= MAX_BATCH_SIZE then
     ngx.log(ngx.WARN, "omitting metrics for the request, current batch is full")
     return
   end
 
-  local metrics_obj = metrics()
   local payload, err = cjson.encode(metrics_obj)
   if err then
     ngx.log(ngx.ERR, string.format("error when encoding metrics: %s", tostring(err)))
@@ -114,6 +196,28 @@ function _M.call()
   metrics_raw_batch[metrics_count] = payload
 end
 
+function _M.call()
+  -- the paths excluded from the access log of the Ingress are not reported
+  if ngx.var.loggable_path == "0" then
+    return
+  end
+
+  add(metrics())
+end
+
+-- rejected_protocol records a connection closed because the client
+-- sent a protocol other than HTTP
+function _M.rejected_protocol(protocol)
+  add({ rejectedProtocol = protocol })
+end
+
+-- tls_handshake records a TLS handshake started by a client, the controller
+-- reads the handshakes that failed from the error log
+function _M.tls_handshake()
+  local server_name = ssl_clienthello.get_client_hello_server_name()
+  add({ tlsHandshake = server_name or "-" })
+end
+
 setmetatable(_M, {__index = {
   flush = flush,
   set_metrics_max_batch_size = set_metrics_max_batch_size,
@@ -0,0 +1,95 @@
+local ngx = ngx
+local tonumber = tonumber
+local string_gsub = string.gsub
+
+local _M = {}
+
+-- methods retried only when proxy-next-upstream contains non_idempotent
+local NON_IDEMPOTENT_METHODS = {
+  POST = true,
+  LOCK = true,
+  PATCH = true,
+}
+
+-- tried_peers returns the endpoints of a value of $upstream_addr. The
+-- addresses of a proxied request are separated by commas, the ones of
+-- the requests proxied after an internal redirect by colons.
+function _M.tried_peers(upstream_addr)
+  local peers = {}
+  if not upstream_addr then
+    return peers
+  end
+
+  for peer in upstream_addr:gmatch("[^%s,]+") do
+    if peer ~= ":" then
+      peers[peer] = true
+    end
+  end
+  return peers
+end
+
+-- tries returns the number of times a request was proxied
+-- from a value of $upstream_addr
+function _M.tries(upstream_addr)
+  if not upstream_addr or upstream_addr == "" then
+    return 0
+  end
+
+  local _, redirects = string_gsub(upstream_addr, " : ", "")
+  return redirects + 1
+end
+
+-- attempts returns the number of upstream servers contacted
+-- for a request from a value of $upstream_addr
+function _M.attempts(upstream_addr)
+  if not upstream_addr or upstream_addr == "" or upstream_addr == "-" then
+    return 0
+  end
+
+  local _, separators = string_gsub(upstream_addr, "[,:] ", "")
+  return separators + 1
+end
+
+-- last_status returns the status code of the last response
+-- from a value of $upstream_status
+function _M.last_status(upstream_status)
+  if not upstream_status then
+    return nil
+  end
+  return tonumber(upstream_status:match("(%d+)%s*$"))
+end
+
+-- should_retry returns true when the intercepted response of the backend
+-- is retried with another endpoint
+function _M.should_retry()
+  local tries = tonumber(ngx.var.next_upstream_tries) or 0
+  if _M.tries(ngx.var.upstream_addr) >= tries then
+    return false
+  end
+
+  if NON_IDEMPOTENT_METHODS[ngx.req.get_method()] and ngx.var.next_upstream_non_idempotent ~= "true" then
+    return false
+  end
+
+  local header = ngx.var.next_upstream_header
+  if header and header ~= "" then
+    local value = ngx.var["upstream_http_" .. header]
+    return value ~= nil and value ~= ""
+  end
+
+  return true
+end
+
+-- content proxies the request intercepted by the error_page of the
+-- location again, the balancer excluding the endpoints already tried.
+-- The responses that are not retried are replaced by the default error
+-- page of their status code, as their body was discarded.
+function _M.content()
+  if not _M.should_retry() then
+    return ngx.exit(_M.last_status(ngx.var.upstream_status) or ngx.HTTP_BAD_GATEWAY)
+  end
+
+  return ngx.exec(ngx.var.next_upstream_uri, ngx.var.args)
+end
+
+return _M
@@ -0,0 +1,3 @@
+local link_rewrite = require("link_rewrite")
+
+link_rewrite.body_filter()
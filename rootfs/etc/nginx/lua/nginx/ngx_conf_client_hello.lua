@@ -0,0 +1,8 @@
+local tls_fingerprint = require("tls_fingerprint")
+tls_fingerprint.client_hello()
+
+local luaconfig = ngx.shared.luaconfig
+if luaconfig:get("enabletlshandshakemetrics") then
+  local monitor = require("monitor")
+  monitor.tls_handshake()
+end
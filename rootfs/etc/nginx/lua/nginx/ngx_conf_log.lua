@@ -1,2 +1,5 @@
 local monitor = require("monitor")
+local concurrency_limit = require("concurrency_limit")
+
+concurrency_limit.log()
 monitor.call()
\ No newline at end of file
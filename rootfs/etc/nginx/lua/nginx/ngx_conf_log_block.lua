@@ -1,10 +1,16 @@
 local balancer = require("balancer")
 local monitor = require("monitor")
+local concurrency_limit = require("concurrency_limit")
+local otlp_access_log = require("otlp_access_log")
+local plugins = require("plugins")
 
 local luaconfig = ngx.shared.luaconfig
 local enablemetrics = luaconfig:get("enablemetrics")
 
 balancer.log()
+concurrency_limit.log()
+otlp_access_log.log()
+plugins.run()
 
 if enablemetrics then
     monitor.call()
@@ -0,0 +1,3 @@
+local concurrency_limit = require("concurrency_limit")
+
+concurrency_limit.log()
@@ -0,0 +1,11 @@
+local protocol_sniffing = require("protocol_sniffing")
+local concurrency_limit = require("concurrency_limit")
+
+local luaconfig = ngx.shared.luaconfig
+local enablemetrics = luaconfig:get("enablemetrics")
+
+concurrency_limit.log()
+
+if enablemetrics then
+    protocol_sniffing.log()
+end
@@ -0,0 +1,2 @@
+local next_upstream = require("next_upstream")
+next_upstream.content()
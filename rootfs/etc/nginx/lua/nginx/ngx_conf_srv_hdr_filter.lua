@@ -1,2 +1,12 @@
 local lua_ingress = require("lua_ingress")
-lua_ingress.header()
\ No newline at end of file
+local auth_cookie_session = require("auth_cookie_session")
+local link_rewrite = require("link_rewrite")
+local server_timing = require("server_timing")
+local plugins = require("plugins")
+
+lua_ingress.header()
+-- the paths of the cookies are rewritten before they are stored in the session
+link_rewrite.header_filter()
+auth_cookie_session.header_filter()
+server_timing.header_filter()
+plugins.run()
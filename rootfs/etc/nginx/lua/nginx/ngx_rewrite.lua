@@ -1,5 +1,37 @@
 local lua_ingress = require("lua_ingress")
+local dynamic_servers = require("dynamic_servers")
 local balancer = require("balancer")
+local graphql = require("graphql")
+local auth_cookie_session = require("auth_cookie_session")
+local tls_fingerprint = require("tls_fingerprint")
+local auth_tls = require("auth_tls")
+local attribution = require("attribution")
+local upstream_signing = require("upstream_signing")
+local concurrency_limit = require("concurrency_limit")
+local chaos = require("chaos")
+local bot_mitigation = require("bot_mitigation")
+local request_id = require("request_id")
+local plugins = require("plugins")
 
+-- the location of a dynamic server defines the redirects of lua_ingress
+dynamic_servers.rewrite()
+-- the denied requests are logged with the request ID of the location
+request_id.rewrite()
 lua_ingress.rewrite()
-balancer.rewrite()
\ No newline at end of file
+-- the client certificate is authorized before the request is routed
+auth_tls.rewrite()
+-- the fingerprint headers must be set before canary-by-header is evaluated
+tls_fingerprint.rewrite()
+-- the suspicious clients are challenged with the fingerprints of their connection
+bot_mitigation.rewrite()
+balancer.rewrite()
+graphql.rewrite()
+auth_cookie_session.rewrite()
+attribution.rewrite()
+upstream_signing.rewrite()
+-- the aborted requests do not take a slot of the concurrency limit
+chaos.rewrite()
+-- the plugins run after the modules of the controller
+plugins.run()
+-- the request may wait in the queue, it is the last step of the rewrite phase
+concurrency_limit.rewrite()
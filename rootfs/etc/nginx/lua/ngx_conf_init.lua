@@ -8,6 +8,7 @@ local configfile = cjson.decode(content)
 
 local luaconfig = ngx.shared.luaconfig
 luaconfig:set("enablemetrics", configfile.enable_metrics)
+luaconfig:set("enabletlshandshakemetrics", configfile.enable_tls_handshake_metrics)
 luaconfig:set("use_forwarded_headers", configfile.use_forwarded_headers)
 -- init modules
 local ok, res
@@ -18,6 +19,48 @@ else
   lua_ingress = res
   lua_ingress.set_config(configfile)
 end
+ok, res = pcall(require, "auth_cookie_session")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  auth_cookie_session = res
+  auth_cookie_session.set_config(configfile.auth_cookie_session)
+end
+ok, res = pcall(require, "tls_fingerprint")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  tls_fingerprint = res
+  tls_fingerprint.set_config(configfile.enable_tls_fingerprinting, configfile.tls_fingerprint_headers)
+end
+ok, res = pcall(require, "bot_mitigation")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  bot_mitigation = res
+  bot_mitigation.set_config(configfile.bot_mitigation)
+end
+ok, res = pcall(require, "otlp_access_log")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  otlp_access_log = res
+  otlp_access_log.set_config(configfile.otlp_access_log)
+end
+ok, res = pcall(require, "connect_backoff")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  connect_backoff = res
+  connect_backoff.set_config(configfile.connect_backoff)
+end
+ok, res = pcall(require, "plugins")
+if not ok then
+  error("require failed: " .. tostring(res))
+else
+  plugins = res
+  plugins.init(configfile.plugins)
+end
 ok, res = pcall(require, "configuration")
 if not ok then
   error("require failed: " .. tostring(res))
@@ -8,8 +8,12 @@ local configfile = cjson.decode(content)
 local lua_ingress = require("lua_ingress")
 local balancer = require("balancer")
 local monitor = require("monitor")
+local otlp_access_log = require("otlp_access_log")
+local plugins = require("plugins")
 lua_ingress.init_worker()
 balancer.init_worker()
+otlp_access_log.init_worker()
+plugins.init_worker()
 if configfile.enable_metrics and configfile.monitor_batch_max_size then
   monitor.init_worker(configfile.monitor_batch_max_size)
 end
\ No newline at end of file
@@ -0,0 +1,239 @@
+local http = require("resty.http")
+local cjson = require("cjson.safe")
+local new_tab = require "table.new"
+
+local ngx = ngx
+local type = type
+local tonumber = tonumber
+local tostring = tostring
+local math_min = math.min
+local string_format = string.format
+local table_concat = table.concat
+local ngx_re_match = ngx.re.match
+
+local _M = {}
+
+-- if an NGINX worker logs more than MAX_RECORDS requests per FLUSH_INTERVAL
+-- then it starts dropping their records
+local MAX_RECORDS = 10000
+local FLUSH_INTERVAL = 1 -- second
+local SEND_TIMEOUT = 5000 -- milliseconds
+
+local SCOPE_NAME = "ingress-nginx"
+
+local config = {
+  endpoint = nil,
+  batch_size = 512,
+  service_name = "nginx",
+}
+
+-- the encoded records logged since the last flush
+local records = new_tab(MAX_RECORDS, 0)
+local records_count = 0
+
+-- set_config sets the OTLP/HTTP logs endpoint of the collector, the records
+-- are only collected when it is set
+function _M.set_config(new_config)
+  if type(new_config) ~= "table" then
+    return
+  end
+
+  if new_config.endpoint and new_config.endpoint ~= "" then
+    config.endpoint = new_config.endpoint
+  end
+  if tonumber(new_config.batch_size) and tonumber(new_config.batch_size) > 0 then
+    config.batch_size = tonumber(new_config.batch_size)
+  end
+  if new_config.service_name and new_config.service_name ~= "" then
+    config.service_name = new_config.service_name
+  end
+end
+
+local function add_string(attributes, key, value)
+  if not value or value == "" or value == "-" then
+    return
+  end
+  attributes[#attributes + 1] = { key = key, value = { stringValue = tostring(value) } }
+end
+
+-- the 64 bits integers are encoded as strings in OTLP/JSON
+local function add_int(attributes, key, value)
+  value = tonumber(value)
+  if not value then
+    return
+  end
+  attributes[#attributes + 1] = { key = key, value = { intValue = string_format("%d", value) } }
+end
+
+local function add_double(attributes, key, value)
+  value = tonumber(value)
+  if not value then
+    return
+  end
+  attributes[#attributes + 1] = { key = key, value = { doubleValue = value } }
+end
+
+local function severity(status)
+  if status >= 500 then
+    return 17, "ERROR"
+  end
+  if status >= 400 then
+    return 13, "WARN"
+  end
+  return 9, "INFO"
+end
+
+-- trace_context returns the trace and span IDs of the request, the ones of
+-- the OpenTelemetry module or else the ones of the traceparent header
+local function trace_context()
+  local trace_id = ngx.var.opentelemetry_trace_id
+  if trace_id and trace_id ~= "" then
+    return trace_id, ngx.var.opentelemetry_span_id
+  end
+
+  local traceparent = ngx.var.http_traceparent
+  if not traceparent then
+    return nil
+  end
+
+  local m = ngx_re_match(traceparent, [[^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$]], "jo")
+  if not m then
+    return nil
+  end
+  return m[1], m[2]
+end
+
+local function record()
+  local status = tonumber(ngx.var.status) or 0
+  local severity_number, severity_text = severity(status)
+
+  local attributes = {}
+  add_string(attributes, "http.request.method", ngx.var.request_method)
+  add_string(attributes, "url.scheme", ngx.var.scheme)
+  add_string(attributes, "url.path", ngx.var.uri)
+  add_string(attributes, "url.query", ngx.var.args)
+  add_string(attributes, "server.address", ngx.var.host)
+  add_string(attributes, "client.address", ngx.var.remote_addr)
+  add_string(attributes, "user_agent.original", ngx.var.http_user_agent)
+  add_int(attributes, "http.response.status_code", status)
+  add_int(attributes, "http.request.size", ngx.var.request_length)
+  add_int(attributes, "http.response.size", ngx.var.bytes_sent)
+  add_double(attributes, "http.server.request.duration", ngx.var.request_time)
+  add_string(attributes, "http.request.id", ngx.var.req_id)
+  add_string(attributes, "k8s.namespace.name", ngx.var.namespace)
+  add_string(attributes, "ingress.name", ngx.var.ingress_name)
+  add_string(attributes, "ingress.service.name", ngx.var.service_name)
+  add_string(attributes, "ingress.upstream.name", ngx.var.proxy_upstream_name)
+  add_string(attributes, "ingress.upstream.address", ngx.var.upstream_addr)
+  add_string(attributes, "ingress.upstream.status", ngx.var.upstream_status)
+  add_string(attributes, "ingress.upstream.response_time", ngx.var.upstream_response_time)
+
+  local log_record = {
+    timeUnixNano = string_format("%.0f", ngx.req.start_time() * 1e9),
+    observedTimeUnixNano = string_format("%.0f", ngx.now() * 1e9),
+    severityNumber = severity_number,
+    severityText = severity_text,
+    body = { stringValue = string_format("%s %d", ngx.var.request or "-", status) },
+    attributes = attributes,
+  }
+
+  local trace_id, span_id = trace_context()
+  if trace_id then
+    log_record.traceId = trace_id
+    log_record.spanId = span_id
+  end
+
+  return log_record
+end
+
+local function payload(batch, first, last)
+  return string_format('{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":%s}}]},' ..
+    '"scopeLogs":[{"scope":{"name":"%s"},"logRecords":[%s]}]}]}',
+    cjson.encode(config.service_name), SCOPE_NAME, table_concat(batch, ",", first, last))
+end
+
+local function send(body)
+  local httpc = http.new()
+  httpc:set_timeout(SEND_TIMEOUT)
+
+  local res, err = httpc:request_uri(config.endpoint, {
+    method = "POST",
+    headers = {
+      ["Content-Type"] = "application/json",
+    },
+    body = body,
+    ssl_verify = false,
+  })
+  if not res then
+    return nil, err
+  end
+  if res.status < 200 or res.status > 299 then
+    return nil, "unexpected status code " .. tostring(res.status)
+  end
+  return true
+end
+
+-- flush sends the records logged since the last flush in batches, the
+-- records logged while they are sent are kept for the next flush
+local function flush()
+  if records_count == 0 then
+    return
+  end
+
+  local batch, count = records, records_count
+  records = new_tab(MAX_RECORDS, 0)
+  records_count = 0
+
+  for first = 1, count, config.batch_size do
+    local last = math_min(first + config.batch_size - 1, count)
+    local ok, err = send(payload(batch, first, last))
+    if not ok then
+      ngx.log(ngx.WARN, string_format("dropping %d access log records, error when sending them: %s",
+        last - first + 1, tostring(err)))
+    end
+  end
+end
+
+function _M.init_worker()
+  if not config.endpoint then
+    return
+  end
+
+  local _, err = ngx.timer.every(FLUSH_INTERVAL, flush)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every: %s", tostring(err)))
+  end
+end
+
+function _M.log()
+  if not config.endpoint then
+    return
+  end
+
+  -- the requests excluded from the access log are not sent
+  if ngx.var.loggable == "0" or ngx.var.otlp_access_log == "false" then
+    return
+  end
+
+  if records_count >= MAX_RECORDS then
+    ngx.log(ngx.WARN, "omitting the access log record of the request, current batch is full")
+    return
+  end
+
+  local encoded, err = cjson.encode(record())
+  if not encoded then
+    ngx.log(ngx.ERR, string_format("error when encoding the access log record: %s", tostring(err)))
+    return
+  end
+
+  records_count = records_count + 1
+  records[records_count] = encoded
+end
+
+setmetatable(_M, {__index = {
+  flush = flush,
+  payload = payload,
+  get_records = function() return records end,
+}})
+
+return _M
@@ -0,0 +1,83 @@
+local ngx = ngx
+local ipairs = ipairs
+local pairs = pairs
+local pcall = pcall
+local require = require
+local string_format = string.format
+local string_gmatch = string.gmatch
+local type = type
+
+-- the phases of the requests running the function of the same name of
+-- the plugins enabled in the location
+local PHASES = {
+  rewrite = true,
+  header_filter = true,
+  log = true,
+}
+
+local _M = {}
+
+-- plugins are the modules of the loaded plugins by name, a plugin failing
+-- to load is not run
+local plugins = {}
+
+-- init loads the modules of the plugins written by the controller in
+-- /etc/nginx/lua/plugins, with the checksums of their code verified
+function _M.init(names)
+  plugins = {}
+  if type(names) ~= "table" then
+    return
+  end
+
+  for _, name in ipairs(names) do
+    local ok, res = pcall(require, "plugins." .. name)
+    if not ok then
+      ngx.log(ngx.ERR, string_format("error loading the Lua plugin %s: %s", name, res))
+    elseif type(res) ~= "table" then
+      ngx.log(ngx.ERR, string_format("error loading the Lua plugin %s: the module is not a table", name))
+    else
+      plugins[name] = res
+    end
+  end
+end
+
+local function call(name, plugin, phase)
+  local fn = plugin[phase]
+  if type(fn) ~= "function" then
+    return
+  end
+
+  local ok, err = pcall(fn)
+  if not ok then
+    ngx.log(ngx.ERR, string_format("error running the %s phase of the Lua plugin %s: %s", phase, name, err))
+  end
+end
+
+function _M.init_worker()
+  for name, plugin in pairs(plugins) do
+    call(name, plugin, "init_worker")
+  end
+end
+
+-- run runs the current phase of the plugins enabled in the location by the
+-- lua-plugins annotation, in their order in the annotation
+function _M.run()
+  local enabled = ngx.var.lua_plugins
+  if not enabled or enabled == "" then
+    return
+  end
+
+  local phase = ngx.get_phase()
+  if not PHASES[phase] then
+    return
+  end
+
+  for name in string_gmatch(enabled, "[^,]+") do
+    local plugin = plugins[name]
+    if plugin then
+      call(name, plugin, phase)
+    end
+  end
+end
+
+return _M
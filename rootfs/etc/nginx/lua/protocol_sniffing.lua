@@ -0,0 +1,65 @@
+local monitor = require("monitor")
+
+local ngx = ngx
+local string_byte = string.byte
+local string_sub = string.sub
+
+local _M = {}
+
+local BYTE_TLS_HANDSHAKE = 0x16
+local BYTE_TLS_MAJOR_VERSION = 0x03
+local BYTE_A = string_byte("A")
+local BYTE_Z = string_byte("Z")
+
+local PROXY_PROTOCOL_V2_SIGNATURE = "\r\n\r\n\0\r\nQUIT\n"
+
+-- detect returns the protocol spoken by a client given the line it sent
+-- instead of an HTTP request line, or nil when it looks like HTTP
+function _M.detect(request_line)
+  if not request_line or request_line == "" then
+    return nil
+  end
+
+  local first, second = string_byte(request_line, 1, 2)
+  if first == BYTE_TLS_HANDSHAKE and second == BYTE_TLS_MAJOR_VERSION then
+    return "tls"
+  end
+
+  if string_sub(request_line, 1, 4) == "SSH-" then
+    return "ssh"
+  end
+
+  if string_sub(request_line, 1, 6) == "PROXY " or
+      string_sub(request_line, 1, #PROXY_PROTOCOL_V2_SIGNATURE) == PROXY_PROTOCOL_V2_SIGNATURE then
+    return "proxy-protocol"
+  end
+
+  if string_sub(request_line, 1, 14) == "PRI * HTTP/2.0" then
+    return "http2"
+  end
+
+  -- HTTP methods are tokens, in practice always upper case
+  if first < BYTE_A or first > BYTE_Z then
+    return "unknown"
+  end
+
+  return nil
+end
+
+-- log counts the requests NGINX rejected because the client
+-- does not speak HTTP
+function _M.log()
+  local status = ngx.status
+  if status ~= ngx.HTTP_BAD_REQUEST and status ~= ngx.HTTP_VERSION_NOT_SUPPORTED then
+    return
+  end
+
+  local protocol = _M.detect(ngx.var.request)
+  if not protocol then
+    return
+  end
+
+  monitor.rejected_protocol(protocol)
+end
+
+return _M
@@ -0,0 +1,108 @@
+local ngx = ngx
+local math_floor = math.floor
+local string_byte = string.byte
+local string_format = string.format
+local string_gsub = string.gsub
+local string_lower = string.lower
+local string_sub = string.sub
+local table_concat = table.concat
+local tonumber = tonumber
+local ngx_re_find = ngx.re.find
+
+local _M = {}
+
+-- the requests IDs sent by the clients are trusted when they are printable
+-- ASCII characters, up to 128 of them, as they are written in the logs
+local MAX_TRUSTED_LENGTH = 128
+
+local FORMAT_REGEX = {
+  hex = [[^[0-9a-f]{32}$]],
+  uuidv7 = [[^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$]],
+  ulid = [[^[0-7][0-9abcdefghjkmnpqrstvwxyz]{25}$]],
+}
+
+local CROCKFORD_BASE32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+local function base32(value, length)
+  local chars = {}
+  for i = length, 1, -1 do
+    local digit = value % 32
+    chars[i] = string_sub(CROCKFORD_BASE32, digit + 1, digit + 1)
+    value = math_floor(value / 32)
+  end
+  return table_concat(chars)
+end
+
+-- generate returns a request ID of the format, the random part comes from
+-- the 32 hexadecimal characters of the NGINX $request_id
+function _M.generate(format, random, now_ms)
+  if format == "uuidv7" then
+    local variant = string_format("%x", tonumber(string_sub(random, 4, 4), 16) % 4 + 8)
+    return string_format("%08x-%04x-7%s-%s%s-%s",
+      math_floor(now_ms / 65536), now_ms % 65536,
+      string_sub(random, 1, 3),
+      variant, string_sub(random, 5, 7),
+      string_sub(random, 8, 19))
+  end
+
+  if format == "ulid" then
+    -- 80 random bits, 20 bits per group of 4 characters
+    local chars = { base32(now_ms, 10) }
+    for i = 1, 20, 5 do
+      chars[#chars + 1] = base32(tonumber(string_sub(random, i, i + 4), 16), 4)
+    end
+    return table_concat(chars)
+  end
+
+  return random
+end
+
+-- valid returns true when the request ID has the format
+function _M.valid(format, id)
+  if not id or id == "" then
+    return false
+  end
+  local from = ngx_re_find(id, FORMAT_REGEX[format] or FORMAT_REGEX.hex, "joi")
+  return from ~= nil
+end
+
+local function trusted(id)
+  if not id or id == "" or #id > MAX_TRUSTED_LENGTH then
+    return false
+  end
+  for i = 1, #id do
+    local byte = string_byte(id, i)
+    if byte < 0x20 or byte > 0x7e or byte == 0x22 then
+      return false
+    end
+  end
+  return true
+end
+
+-- rewrite replaces the $req_id of the locations with a request-id-policy,
+-- the variable is used in the logs, the error pages and the traces
+function _M.rewrite()
+  local var = ngx.var
+  local policy = var.request_id_policy
+  if not policy then
+    return
+  end
+
+  local format = var.request_id_format
+  local incoming = var["http_" .. string_gsub(string_lower(var.request_id_header or "x-request-id"), "-", "_")]
+
+  local id
+  if policy == "trust" and trusted(incoming) then
+    id = incoming
+  elseif policy == "regenerate" and _M.valid(format, incoming) then
+    id = incoming
+  else
+    id = _M.generate(format, var.request_id, math_floor(ngx.now() * 1000))
+  end
+
+  -- $req_id is a map variable, the value set here replaces the value of
+  -- the map for the rest of the request
+  var.req_id = id
+end
+
+return _M
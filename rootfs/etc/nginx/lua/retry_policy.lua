@@ -0,0 +1,80 @@
+local ngx = ngx
+local tonumber = tonumber
+local math_floor = math.floor
+
+local _M = {}
+
+-- the budget is computed over the current and the previous window
+local BUDGET_WINDOW = 10 -- seconds
+
+-- number of times the balancer is asked for an endpoint that was not
+-- tried yet before giving up and retrying with the same endpoint
+local MAX_PEER_PICKS = 10
+
+local function budget_keys(upstream_name, window)
+  return "requests:" .. upstream_name .. ":" .. window, "retries:" .. upstream_name .. ":" .. window
+end
+
+local function incr(key)
+  local newval, err = ngx.shared.balancer_retry_budget:incr(key, 1, 0, BUDGET_WINDOW * 2)
+  if not newval then
+    ngx.log(ngx.ERR, "failed to update retry budget: ", err)
+  end
+end
+
+-- get returns the retry policy of the current location, nil when
+-- the location does not have one
+function _M.get()
+  local max_retries = tonumber(ngx.var.retry_policy_max_retries)
+  if not max_retries then
+    return nil
+  end
+
+  return {
+    max_retries = max_retries,
+    per_try_timeout = tonumber(ngx.var.retry_policy_per_try_timeout) or 0,
+    budget_percent = tonumber(ngx.var.retry_policy_budget_percent) or 0,
+  }
+end
+
+-- record counts a request, or a retry, sent to the upstream
+function _M.record(upstream_name, is_retry)
+  local window = math_floor(ngx.now() / BUDGET_WINDOW)
+  local requests_key, retries_key = budget_keys(upstream_name, window)
+  incr(is_retry and retries_key or requests_key)
+end
+
+-- within_budget returns true when the retries sent to the upstream are
+-- less than budget_percent of its requests. A budget of 0 is unlimited.
+function _M.within_budget(upstream_name, budget_percent)
+  if budget_percent <= 0 then
+    return true
+  end
+
+  local dict = ngx.shared.balancer_retry_budget
+  local window = math_floor(ngx.now() / BUDGET_WINDOW)
+
+  local requests, retries = 0, 0
+  for w = window - 1, window do
+    local requests_key, retries_key = budget_keys(upstream_name, w)
+    requests = requests + (dict:get(requests_key) or 0)
+    retries = retries + (dict:get(retries_key) or 0)
+  end
+
+  return retries < requests * budget_percent / 100
+end
+
+-- pick_untried_peer asks the balancer for an endpoint that was not tried
+-- yet by the request, falling back to the last one returned.
+function _M.pick_untried_peer(balancer, tried)
+  local peer
+  for _ = 1, MAX_PEER_PICKS do
+    peer = balancer:balance()
+    if not peer or not tried[peer] then
+      return peer
+    end
+  end
+  return peer
+end
+
+return _M
@@ -0,0 +1,118 @@
+local ngx = ngx
+local string_format = string.format
+local string_gmatch = string.gmatch
+local table_concat = table.concat
+local tonumber = tonumber
+local type = type
+
+local _M = {}
+
+-- sum returns the sum in seconds of the times of the tries of an upstream
+-- variable like "0.010, 0.002 : 0.004", nil when none of them is known
+function _M.sum(value)
+  if not value then
+    return nil
+  end
+
+  local total
+  for time in string_gmatch(value, "[^,:%s]+") do
+    local seconds = tonumber(time)
+    if seconds then
+      total = (total or 0) + seconds
+    end
+  end
+  return total
+end
+
+-- retries returns the number of upstream servers tried after the first
+-- one in $upstream_addr, nil when the request was not proxied
+function _M.retries(upstream_addr)
+  if not upstream_addr or upstream_addr == "" then
+    return nil
+  end
+
+  local tries = 0
+  for _ in string_gmatch(upstream_addr, "[^,%s]+") do
+    tries = tries + 1
+  end
+  -- the " : " separating the upstreams of internal redirects is counted too
+  for _ in string_gmatch(upstream_addr, " : ") do
+    tries = tries - 1
+  end
+  return tries - 1
+end
+
+local function duration(name, seconds)
+  return string_format("%s;dur=%.0f", name, seconds * 1000)
+end
+
+-- build returns the Server-Timing header of a response from the NGINX
+-- variables and the seconds elapsed since the request was received
+function _M.build(var, elapsed)
+  local metrics = {}
+
+  local connect = _M.sum(var.upstream_connect_time)
+  if connect then
+    metrics[#metrics + 1] = duration("upstream-connect", connect)
+  end
+
+  local header = _M.sum(var.upstream_header_time)
+  if header then
+    metrics[#metrics + 1] = duration("upstream-header", header)
+  end
+
+  -- the response time is only known when the response of the upstream
+  -- was read before the header is sent, like a buffered response
+  local response = _M.sum(var.upstream_response_time)
+  if response and response > 0 then
+    metrics[#metrics + 1] = duration("upstream-response", response)
+  end
+
+  -- the time spent before the upstream sent the header, like the time of
+  -- the authentication subrequests, or the whole request without upstream
+  local edge = elapsed - (header or 0)
+  if edge < 0 then
+    edge = 0
+  end
+  metrics[#metrics + 1] = duration("edge", edge)
+
+  local cache_status = var.upstream_cache_status
+  if cache_status and cache_status ~= "" then
+    metrics[#metrics + 1] = "cache;desc=" .. cache_status
+  end
+
+  local retries = _M.retries(var.upstream_addr)
+  if retries then
+    metrics[#metrics + 1] = "upstream-retries;desc=" .. retries
+  end
+
+  return table_concat(metrics, ", ")
+end
+
+function _M.header_filter()
+  local var = ngx.var
+  if var.server_timing ~= "true" then
+    return
+  end
+
+  ngx.update_time()
+  local value = _M.build(var, ngx.now() - ngx.req.start_time())
+
+  -- the timings of the upstream are kept
+  local upstream_timing = ngx.header["Server-Timing"]
+  if type(upstream_timing) == "table" then
+    upstream_timing[#upstream_timing + 1] = value
+    ngx.header["Server-Timing"] = upstream_timing
+  elseif upstream_timing then
+    ngx.header["Server-Timing"] = { upstream_timing, value }
+  else
+    ngx.header["Server-Timing"] = value
+  end
+
+  local allow_origin = var.server_timing_allow_origin
+  if allow_origin and allow_origin ~= "" then
+    ngx.header["Timing-Allow-Origin"] = allow_origin
+  end
+end
+
+return _M
@@ -0,0 +1,61 @@
+local attribution = require("attribution")
+
+describe("attribution", function()
+  describe("value()", function()
+    it("returns the escaped fields of the request", function()
+      local var = {
+        attribution_key_id = "2024-01",
+        attribution_auth = "basic",
+        remote_addr = "192.0.2.10",
+        ssl_protocol = "TLSv1.3",
+        ssl_cipher = "TLS_AES_128_GCM_SHA256",
+        ssl_client_verify = "NONE",
+        remote_user = "jane;admin=true",
+      }
+
+      assert.are.equal("v=1;kid=2024-01;ts=1700000000;ip=192.0.2.10;tls=TLSv1.3;cipher=TLS_AES_128_GCM_SHA256;" ..
+        "cert=NONE;auth=basic;user=jane%3Badmin%3Dtrue", attribution.value(var, 1700000000))
+    end)
+
+    it("uses - for the facts that are not available", function()
+      local var = { attribution_key_id = "abc", attribution_auth = "none", remote_addr = "192.0.2.10" }
+
+      assert.are.equal("v=1;kid=abc;ts=1;ip=192.0.2.10;tls=-;cipher=-;cert=-;auth=none;user=-",
+        attribution.value(var, 1))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+
+    local function mock_var(var)
+      _G.ngx = setmetatable({ var = var }, { __index = original_ngx })
+      package.loaded["attribution"] = nil
+      attribution = require("attribution")
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["attribution"] = nil
+      attribution = require("attribution")
+    end)
+
+    it("does nothing when the location does not sign the header", function()
+      mock_var({})
+      local s = spy.on(ngx.req, "set_header")
+      attribution.rewrite()
+      assert.spy(s).was_not_called()
+    end)
+
+    it("removes the header sent by the client when the key can not be read", function()
+      mock_var({
+        attribution_header = "X-Ingress-Attribution",
+        attribution_key_file = "/nonexistent/key",
+        attribution_key_sha = "nonexistent",
+      })
+      local s = spy.on(ngx.req, "clear_header")
+      attribution.rewrite()
+      assert.spy(s).was_called_with("X-Ingress-Attribution")
+    end)
+  end)
+end)
@@ -0,0 +1,64 @@
+local auth_cookie_session = require("auth_cookie_session")
+
+describe("auth_cookie_session", function()
+  describe("parse_set_cookie()", function()
+    it("returns the name, value and domain of the cookie", function()
+      local cookie = auth_cookie_session.parse_set_cookie(
+        "_oauth2_proxy_0=abc=; Path=/; Domain=.example.com; HttpOnly; Secure")
+
+      assert.are.equal("_oauth2_proxy_0", cookie.name)
+      assert.are.equal("abc=", cookie.value)
+      assert.are.equal(".example.com", cookie.domain)
+      assert.is_false(cookie.deleted)
+    end)
+
+    it("detects deleted cookies", function()
+      local cookie = auth_cookie_session.parse_set_cookie("_oauth2_proxy=; Path=/; Max-Age=0")
+      assert.is_true(cookie.deleted)
+
+      cookie = auth_cookie_session.parse_set_cookie(
+        "_oauth2_proxy=abc; Path=/; Expires=Thu, 01 Jan 1970 00:00:01 GMT")
+      assert.is_true(cookie.deleted)
+    end)
+  end)
+
+  describe("split_set_cookie()", function()
+    it("splits headers without breaking the Expires attribute", function()
+      local headers = auth_cookie_session.split_set_cookie(
+        "a=1; Expires=Wed, 21 Oct 2037 07:28:00 GMT; Path=/, b=2; Path=/")
+
+      assert.are.same({ "a=1; Expires=Wed, 21 Oct 2037 07:28:00 GMT; Path=/", "b=2; Path=/" }, headers)
+    end)
+  end)
+
+  describe("merge()", function()
+    it("adds, updates and removes cookies", function()
+      local jar = { cookies = { a = "1", b = "2" } }
+      auth_cookie_session.merge(jar, {
+        { name = "a", value = "3", domain = "example.com", deleted = false },
+        { name = "b", value = "", deleted = true },
+        { name = "c", value = "4", deleted = false },
+      })
+
+      assert.are.same({ a = "3", c = "4" }, jar.cookies)
+      assert.are.equal("example.com", jar.domain)
+    end)
+  end)
+
+  describe("session_cookie()", function()
+    it("builds the session cookie", function()
+      assert.are.equal("ingress_auth_session=abc; Path=/; HttpOnly; SameSite=Lax; Max-Age=86400; Secure",
+        auth_cookie_session.session_cookie("abc", nil, true))
+      assert.are.equal("ingress_auth_session=; Path=/; HttpOnly; SameSite=Lax; Max-Age=0; Domain=.example.com",
+        auth_cookie_session.session_cookie("", ".example.com", false))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    it("does nothing when the annotation is not enabled", function()
+      local s = spy.on(ngx.req, "set_header")
+      auth_cookie_session.rewrite()
+      assert.spy(s).was_not_called()
+    end)
+  end)
+end)
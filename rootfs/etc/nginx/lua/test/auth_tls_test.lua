@@ -0,0 +1,132 @@
+local auth_tls = require("auth_tls")
+
+local function read_file(path)
+  local file = assert(io.open(path, "rb"))
+  local content = file:read("*a")
+  file:close()
+  return content
+end
+
+local CLIENT_CERT = read_file("rootfs/etc/nginx/lua/test/fixtures/client-cert.pem")
+local CLIENT_DN = "O=example,OU=billing,OU=payments,CN=client.example.com"
+
+describe("auth_tls", function()
+  describe("parse_dn()", function()
+    it("returns the values of every attribute", function()
+      local attributes = auth_tls.parse_dn(CLIENT_DN)
+
+      assert.are.same({ "client.example.com" }, attributes.CN)
+      assert.are.same({ "billing", "payments" }, attributes.OU)
+      assert.are.same({ "example" }, attributes.O)
+    end)
+
+    it("unescapes the special characters", function()
+      assert.are.same({ "Example, Inc" }, auth_tls.parse_dn("CN=client,O=Example\\, Inc").O)
+    end)
+
+    it("returns no attribute without subject", function()
+      assert.are.same({}, auth_tls.parse_dn(nil))
+    end)
+  end)
+
+  describe("subject_alt_names()", function()
+    it("returns the names prefixed with their type", function()
+      assert.are.same({
+        "DNS:client.example.com",
+        "URI:spiffe://cluster.local/ns/payments/sa/api",
+        "email:client@example.com",
+        "IP:10.0.0.1",
+      }, auth_tls.subject_alt_names(CLIENT_CERT))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+    local exit_status
+
+    local function mock_ngx(var)
+      exit_status = nil
+      _G.ngx = setmetatable({
+        var = var,
+        exit = function(status) exit_status = status end,
+      }, { __index = original_ngx })
+      package.loaded["auth_tls"] = nil
+      auth_tls = require("auth_tls")
+    end
+
+    local function client_var(var)
+      var.ssl_client_verify = "SUCCESS"
+      var.ssl_client_s_dn = CLIENT_DN
+      var.ssl_client_raw_cert = CLIENT_CERT
+      var.ssl_client_fingerprint = "3f2a"
+      var.ssl_client_serial = "01"
+      return var
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["auth_tls"] = nil
+      auth_tls = require("auth_tls")
+    end)
+
+    it("does nothing when the attributes are not used", function()
+      mock_ngx({ ssl_client_verify = "NONE" })
+      auth_tls.rewrite()
+      assert.is_nil(exit_status)
+    end)
+
+    it("accepts the certificates matching the OU and SAN", function()
+      mock_ngx(client_var({
+        auth_tls_match_ou = "^payments$",
+        auth_tls_match_san = "^URI:spiffe://cluster\\.local/ns/payments/",
+      }))
+      auth_tls.rewrite()
+      assert.is_nil(exit_status)
+    end)
+
+    it("denies the certificates not matching the OU", function()
+      mock_ngx(client_var({ auth_tls_match_ou = "^shipping$" }))
+      auth_tls.rewrite()
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+    end)
+
+    it("denies the certificates not matching the SAN", function()
+      mock_ngx(client_var({ auth_tls_match_san = "^DNS:.*\\.internal$" }))
+      auth_tls.rewrite()
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+    end)
+
+    it("denies the requests without verified certificate", function()
+      mock_ngx({ ssl_client_verify = "NONE", auth_tls_match_ou = "^payments$" })
+      auth_tls.rewrite()
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+    end)
+
+    it("exposes the attributes of the certificate", function()
+      local var = client_var({
+        auth_tls_identity_attributes = "cn,ou,san,serial",
+        auth_tls_identity_cn = "",
+        auth_tls_identity_ou = "",
+        auth_tls_identity_san = "",
+        auth_tls_identity_serial = "",
+      })
+      mock_ngx(var)
+      auth_tls.rewrite()
+
+      assert.are.equal("client.example.com", var.auth_tls_identity_cn)
+      assert.are.equal("billing,payments", var.auth_tls_identity_ou)
+      assert.are.equal("DNS:client.example.com,URI:spiffe://cluster.local/ns/payments/sa/api," ..
+        "email:client@example.com,IP:10.0.0.1", var.auth_tls_identity_san)
+      assert.are.equal("01", var.auth_tls_identity_serial)
+    end)
+
+    it("does not expose attributes without verified certificate", function()
+      local var = { ssl_client_verify = "FAILED:unknown", auth_tls_identity_attributes = "cn", auth_tls_identity_cn = "" }
+      mock_ngx(var)
+      auth_tls.rewrite()
+
+      assert.is_nil(exit_status)
+      assert.are.equal("", var.auth_tls_identity_cn)
+    end)
+  end)
+end)
@@ -0,0 +1,115 @@
+local util = require("util")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("Balancer least_request", function()
+  local balancer_least_request
+  local backend, instance
+
+  before_each(function()
+    mock_ngx({ ctx = {}, var = {} })
+    package.loaded["balancer.in_flight"] = nil
+    package.loaded["balancer.least_request"] = nil
+    balancer_least_request = require("balancer.least_request")
+
+    backend = {
+      name = "namespace-service-port", ["load-balance"] = "least_request",
+      endpoints = {
+        { address = "10.10.10.1", port = "8080", maxFails = 0, failTimeout = 0 },
+        { address = "10.10.10.2", port = "8080", maxFails = 0, failTimeout = 0 },
+        { address = "10.10.10.3", port = "8080", maxFails = 0, failTimeout = 0 },
+      },
+      loadBalanceTuning = { leastRequestChoices = 3 },
+    }
+    instance = balancer_least_request:new(backend)
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("balance()", function()
+    it("picks the endpoint with the fewest requests in flight", function()
+      instance.in_flight = { ["10.10.10.1:8080"] = 4, ["10.10.10.2:8080"] = 1, ["10.10.10.3:8080"] = 2 }
+
+      assert.equal("10.10.10.2:8080", instance:balance())
+      assert.equal(2, instance.in_flight["10.10.10.2:8080"])
+    end)
+
+    it("takes the weight of the endpoints into account", function()
+      backend.endpoints[1].weight = 10
+      instance = balancer_least_request:new(backend)
+      instance.in_flight = { ["10.10.10.1:8080"] = 4, ["10.10.10.2:8080"] = 1, ["10.10.10.3:8080"] = 2 }
+
+      assert.equal("10.10.10.1:8080", instance:balance())
+    end)
+
+    it("doesn't pick the tried endpoint while retry", function()
+      instance.in_flight = { ["10.10.10.1:8080"] = 4, ["10.10.10.3:8080"] = 2 }
+
+      assert.equal("10.10.10.2:8080", instance:balance())
+      assert.equal("10.10.10.3:8080", instance:balance())
+    end)
+
+    it("picks among all the endpoints once they have all been tried", function()
+      instance:balance()
+      instance:balance()
+      instance:balance()
+
+      assert.is_not_nil(instance:balance())
+    end)
+  end)
+
+  describe("after_balance()", function()
+    it("uncounts the requests of the tries", function()
+      instance:balance()
+      instance:balance()
+      instance:after_balance()
+
+      for _, endpoint in ipairs(backend.endpoints) do
+        assert.equal(0, instance.in_flight[endpoint.address .. ":" .. endpoint.port] or 0)
+      end
+      assert.is_nil(ngx.ctx.balancer_in_flight)
+    end)
+  end)
+
+  describe("sync()", function()
+    it("updates the peers and the tuning and forgets the removed endpoints", function()
+      instance.in_flight = { ["10.10.10.1:8080"] = 4, ["10.10.10.2:8080"] = 1 }
+
+      local new_backend = util.deepcopy(backend)
+      table.remove(new_backend.endpoints, 1)
+      new_backend.loadBalanceTuning.leastRequestChoices = 2
+      instance:sync(new_backend)
+
+      assert.are.same(new_backend.endpoints, instance.peers)
+      assert.equal(2, instance.choices)
+      assert.is_nil(instance.in_flight["10.10.10.1:8080"])
+      assert.equal(1, instance.in_flight["10.10.10.2:8080"])
+    end)
+  end)
+
+  describe("simulation", function()
+    local latencies = { ["10.10.10.1:8080"] = 0.01, ["10.10.10.2:8080"] = 0.2, ["10.10.10.3:8080"] = 0.2 }
+
+    it("sends most of the requests to the fastest endpoint", function()
+      local received = helpers.simulate_balancer(instance, latencies, 10000)
+
+      assert.is_true(received["10.10.10.1:8080"] > 6000)
+    end)
+
+    it("measures the time spent balancing #benchmark", function()
+      local _, spent = helpers.simulate_balancer(instance, latencies, 100000)
+      print(string.format("least_request: %.2f us per request", spent * 10))
+    end)
+  end)
+end)
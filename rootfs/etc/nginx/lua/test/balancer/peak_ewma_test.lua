@@ -0,0 +1,146 @@
+local util = require("util")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("Balancer peak_ewma", function()
+  local balancer_peak_ewma
+  local ngx_now = 1543238266
+  local backend, instance
+
+  before_each(function()
+    mock_ngx({ now = function() return ngx_now end, ctx = {}, var = { balancer_ewma_score = -1 } })
+    package.loaded["balancer.in_flight"] = nil
+    package.loaded["balancer.peak_ewma"] = nil
+    balancer_peak_ewma = require("balancer.peak_ewma")
+
+    backend = {
+      name = "namespace-service-port", ["load-balance"] = "peak_ewma",
+      endpoints = {
+        { address = "10.10.10.1", port = "8080", maxFails = 0, failTimeout = 0 },
+        { address = "10.10.10.2", port = "8080", maxFails = 0, failTimeout = 0 },
+      },
+      loadBalanceTuning = { peakEwmaDecay = 10 },
+    }
+    instance = balancer_peak_ewma:new(backend)
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("observe()", function()
+    it("jumps to a latency above the average", function()
+      instance:observe("10.10.10.1:8080", 0.1, ngx_now - 5)
+      instance:observe("10.10.10.1:8080", 0.5, ngx_now)
+
+      assert.equal(0.5, instance.stats["10.10.10.1:8080"].cost)
+    end)
+
+    it("decays towards a latency below the average", function()
+      instance:observe("10.10.10.1:8080", 0.5, ngx_now - 5)
+      instance:observe("10.10.10.1:8080", 0.1, ngx_now)
+
+      local weight = math.exp(-5 / 10)
+      assert.equal(0.5 * weight + 0.1 * (1 - weight), instance.stats["10.10.10.1:8080"].cost)
+      assert.equal(ngx_now, instance.stats["10.10.10.1:8080"].at)
+    end)
+  end)
+
+  describe("score()", function()
+    it("multiplies the decayed latency by the requests in flight", function()
+      instance.stats["10.10.10.1:8080"] = { cost = 0.2, at = ngx_now - 10 }
+      instance.in_flight["10.10.10.1:8080"] = 2
+
+      assert.equal(0.2 * math.exp(-1) * 3, instance:score(backend.endpoints[1]))
+    end)
+
+    it("penalizes the endpoints without latency with requests in flight", function()
+      instance.in_flight["10.10.10.1:8080"] = 1
+
+      assert.is_true(instance:score(backend.endpoints[1]) > 1000)
+      assert.equal(0, instance:score(backend.endpoints[2]))
+    end)
+  end)
+
+  describe("balance()", function()
+    it("picks the endpoint with the lowest score", function()
+      instance.stats["10.10.10.1:8080"] = { cost = 0.5, at = ngx_now }
+      instance.stats["10.10.10.2:8080"] = { cost = 0.1, at = ngx_now }
+
+      assert.equal("10.10.10.2:8080", instance:balance())
+      assert.equal(0.1, ngx.var.balancer_ewma_score)
+      assert.equal(1, instance.in_flight["10.10.10.2:8080"])
+    end)
+
+    it("doesn't pick the tried endpoint while retry", function()
+      instance.stats["10.10.10.1:8080"] = { cost = 0.5, at = ngx_now }
+      instance.stats["10.10.10.2:8080"] = { cost = 0.1, at = ngx_now }
+
+      assert.equal("10.10.10.2:8080", instance:balance())
+      assert.equal("10.10.10.1:8080", instance:balance())
+    end)
+  end)
+
+  describe("after_balance()", function()
+    it("updates the latency of the last try and uncounts the requests", function()
+      instance:balance()
+      ngx.var = { upstream_addr = "10.10.10.1:8080, 10.10.10.2:8080", upstream_connect_time = "0.05, 0",
+                  upstream_response_time = "0.2, 0.12" }
+
+      instance:after_balance()
+
+      assert.equal(0.12, instance.stats["10.10.10.2:8080"].cost)
+      assert.is_nil(instance.stats["10.10.10.1:8080"])
+      assert.equal(0, instance.in_flight["10.10.10.1:8080"] or 0)
+      assert.equal(0, instance.in_flight["10.10.10.2:8080"] or 0)
+    end)
+  end)
+
+  describe("sync()", function()
+    it("forgets the removed endpoints and starts the new ones with the average latency", function()
+      instance.stats["10.10.10.1:8080"] = { cost = 0.2, at = ngx_now }
+      instance.stats["10.10.10.2:8080"] = { cost = 0.4, at = ngx_now }
+
+      local new_backend = util.deepcopy(backend)
+      new_backend.endpoints[1].address = "10.10.10.3"
+      new_backend.loadBalanceTuning.peakEwmaDecay = 30
+      instance:sync(new_backend)
+
+      assert.are.same(new_backend.endpoints, instance.peers)
+      assert.equal(30, instance.decay)
+      assert.is_nil(instance.stats["10.10.10.1:8080"])
+      assert.are.same({ cost = 0.4, at = ngx_now }, instance.stats["10.10.10.3:8080"])
+    end)
+  end)
+
+  describe("simulation", function()
+    local latencies = { ["10.10.10.1:8080"] = 0.01, ["10.10.10.2:8080"] = 0.2, ["10.10.10.3:8080"] = 0.2 }
+
+    before_each(function()
+      table.insert(backend.endpoints, { address = "10.10.10.3", port = "8080", maxFails = 0, failTimeout = 0 })
+      instance = balancer_peak_ewma:new(backend)
+    end)
+
+    it("sends most of the requests to the fastest endpoint", function()
+      local received = helpers.simulate_balancer(instance, latencies, 10000)
+
+      -- round_robin would send it a third of them, it is not among the two
+      -- endpoints compared for a third of them
+      assert.is_true(received["10.10.10.1:8080"] > 5000)
+    end)
+
+    it("measures the time spent balancing #benchmark", function()
+      local _, spent = helpers.simulate_balancer(instance, latencies, 100000)
+      print(string.format("peak_ewma: %.2f us per request", spent * 10))
+    end)
+  end)
+end)
@@ -0,0 +1,95 @@
+describe("Balancer slow start", function()
+  local slow_start
+
+  before_each(function()
+    slow_start = require_without_cache("balancer.slow_start")
+  end)
+
+  local function backend_with(endpoints, config)
+    local backend = { name = "my-dummy-backend", endpoints = {} }
+    backend.slowStart = config
+    for _, address in ipairs(endpoints) do
+      table.insert(backend.endpoints, { address = address, port = "8080", maxFails = 0, failTimeout = 0 })
+    end
+    return backend
+  end
+
+  local function weights(backend)
+    local result = {}
+    for _, endpoint in ipairs(backend.endpoints) do
+      result[endpoint.address] = endpoint.weight
+    end
+    return result
+  end
+
+  describe("apply()", function()
+    it("returns the backend as is when slow start is not configured", function()
+      local backend = backend_with({ "10.0.0.1" })
+      local weighted_backend, warming = slow_start.apply(backend, 100)
+      assert.equal(backend, weighted_backend)
+      assert.is_false(warming)
+
+      backend = backend_with({ "10.0.0.1" }, { duration = 0 })
+      weighted_backend, warming = slow_start.apply(backend, 100)
+      assert.equal(backend, weighted_backend)
+      assert.is_false(warming)
+    end)
+
+    it("considers the endpoints present when the backend is first seen warm", function()
+      local backend = backend_with({ "10.0.0.1", "10.0.0.2" }, { duration = 30, aggression = 1 })
+      local weighted_backend, warming = slow_start.apply(backend, 100)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 100 }, weights(weighted_backend))
+      assert.is_false(warming)
+      assert.is_nil(backend.endpoints[1].weight)
+    end)
+
+    it("ramps up the weight of new endpoints linearly", function()
+      local config = { duration = 30, aggression = 1 }
+      slow_start.apply(backend_with({ "10.0.0.1" }, config), 100)
+
+      local backend = backend_with({ "10.0.0.1", "10.0.0.2" }, config)
+      local weighted_backend, warming = slow_start.apply(backend, 110)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 1 }, weights(weighted_backend))
+      assert.is_true(warming)
+
+      weighted_backend, warming = slow_start.apply(backend, 125)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 50 }, weights(weighted_backend))
+      assert.is_true(warming)
+
+      weighted_backend, warming = slow_start.apply(backend, 140)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 100 }, weights(weighted_backend))
+      assert.is_false(warming)
+    end)
+
+    it("ramps up the weight of new endpoints along the curve of the aggression", function()
+      local config = { duration = 40, aggression = 2 }
+      slow_start.apply(backend_with({ "10.0.0.1" }, config), 0)
+
+      local backend = backend_with({ "10.0.0.1", "10.0.0.2" }, config)
+      slow_start.apply(backend, 100)
+      local weighted_backend = slow_start.apply(backend, 110)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 50 }, weights(weighted_backend))
+    end)
+
+    it("scales the weights of weighted endpoints", function()
+      local config = { duration = 30, aggression = 1 }
+      slow_start.apply(backend_with({}, config), 0)
+
+      local backend = backend_with({ "10.0.0.1" }, config)
+      backend.endpoints[1].weight = 3
+      slow_start.apply(backend, 100)
+      local weighted_backend = slow_start.apply(backend, 115)
+      assert.same({ ["10.0.0.1"] = 150 }, weights(weighted_backend))
+    end)
+
+    it("ramps up again an endpoint that was removed and added back", function()
+      local config = { duration = 30, aggression = 1 }
+      slow_start.apply(backend_with({ "10.0.0.1", "10.0.0.2" }, config), 0)
+      slow_start.apply(backend_with({ "10.0.0.1" }, config), 10)
+
+      local weighted_backend, warming = slow_start.apply(backend_with({ "10.0.0.1", "10.0.0.2" }, config), 20)
+      assert.same({ ["10.0.0.1"] = 100, ["10.0.0.2"] = 1 }, weights(weighted_backend))
+      assert.is_true(warming)
+    end)
+  end)
+end)
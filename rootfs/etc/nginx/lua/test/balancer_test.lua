@@ -37,7 +37,9 @@ local function reset_expected_implementations()
     ["my-dummy-app-3"] = package.loaded["balancer.sticky_persistent"],
     ["my-dummy-app-4"] = package.loaded["balancer.ewma"],
     ["my-dummy-app-5"] = package.loaded["balancer.sticky_balanced"],
-    ["my-dummy-app-6"] = package.loaded["balancer.chashsubset"]
+    ["my-dummy-app-6"] = package.loaded["balancer.chashsubset"],
+    ["my-dummy-app-7"] = package.loaded["balancer.peak_ewma"],
+    ["my-dummy-app-8"] = package.loaded["balancer.least_request"]
   }
 end
 
@@ -88,6 +90,14 @@ local function reset_backends()
       ["load-balance"] = "ewma",                  -- upstreamHashByConfig will take priority.
       upstreamHashByConfig = { ["upstream-hash-by"] = "$request_uri", ["upstream-hash-by-subset"] = "true", }
     },
+    {
+      name = "my-dummy-app-7",
+      ["load-balance"] = "peak_ewma",
+    },
+    {
+      name = "my-dummy-app-8",
+      ["load-balance"] = "least_request",
+    },
   }
 end
 
@@ -261,6 +271,36 @@ describe("Balancer", function()
         end)
       end)
 
+      describe("canary by geo", function()
+        it("returns correct result for given countries", function()
+          local test_patterns = {
+            {
+              case_title = "country is in the list",
+              country = "CA",
+              expected_result = true,
+            },
+            {
+              case_title = "country is not in the list",
+              country = "FR",
+              expected_result = false,
+            },
+            {
+              case_title = "country is unknown",
+              country = "",
+              expected_result = false,
+            },
+          }
+          for _, test_pattern in pairs(test_patterns) do
+            mock_ngx({ var = { geo_country_code = test_pattern.country, request_uri = "/" } })
+            backend.trafficShapingPolicy.geo = { "CA", "US" }
+            balancer.sync_backend(backend)
+            assert.message("\nTest data pattern: " .. test_pattern.case_title)
+              .equal(test_pattern.expected_result, balancer.route_to_alternative_balancer(_primaryBalancer))
+            reset_ngx()
+          end
+        end)
+      end)
+
       describe("canary by header", function()
         it("returns correct result for given headers", function()
           local test_patterns = {
@@ -439,6 +479,49 @@ describe("Balancer", function()
       assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
     end)
 
+    it("resolves external name to weighted endpoints with SRV records when configured", function()
+      local resolver = require("resty.dns.resolver")
+      backend = {
+        name = "example-com", service = { spec = { ["type"] = "ExternalName" } },
+        externalName = { srv = "_http._tcp", ttl = 10 },
+        endpoints = {
+          { address = "example.com.", port = "80", maxFails = 0, failTimeout = 0 }
+        }
+      }
+
+      local answers = {
+        ["_http._tcp.example.com./" .. resolver.TYPE_SRV] = {
+          { target = "a.example.com", port = 8080, weight = 3, priority = 10, ttl = 60 },
+          { target = "b.example.com", port = 8081, weight = 1, priority = 10, ttl = 60 },
+        },
+        ["a.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.1", ttl = 60 } },
+        ["b.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.2", ttl = 60 } },
+      }
+      helpers.mock_resty_dns_new(function(self, options)
+        return {
+          query = function(self, name, options, tries)
+            return answers[name .. "/" .. options.qtype] or {}
+          end
+        }
+      end)
+
+      expected_backend = {
+        name = "example-com", service = { spec = { ["type"] = "ExternalName" } },
+        externalName = { srv = "_http._tcp", ttl = 10 },
+        endpoints = {
+          { address = "192.168.1.1", port = "8080", weight = 3 },
+          { address = "192.168.1.2", port = "8081", weight = 1 },
+        }
+      }
+
+      local mock_instance = { sync = function(backend) end }
+      setmetatable(mock_instance, implementation)
+      implementation.new = function(self, backend) return mock_instance end
+      local s = spy.on(implementation, "new")
+      assert.has_no.errors(function() balancer.sync_backend(backend) end)
+      assert.spy(s).was_called_with(implementation, expected_backend)
+    end)
+
     it("wraps IPv6 addresses into square brackets", function()
       local backend = {
         name = "example-com",
@@ -466,6 +549,43 @@ describe("Balancer", function()
       assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
     end)
 
+    it("ramps up the weight of new endpoints when slow start is configured", function()
+      local backend = {
+        name = "slow-start", slowStart = { duration = 30, aggression = 1 },
+        endpoints = {
+          { address = "192.168.1.1", port = "8080", maxFails = 0, failTimeout = 0 },
+        }
+      }
+
+      local mock_instance = { sync = function(backend) end }
+      setmetatable(mock_instance, implementation)
+      implementation.new = function(self, backend) return mock_instance end
+      local s = spy.on(implementation, "new")
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      assert.spy(s).was_called_with(implementation, {
+        name = "slow-start", slowStart = { duration = 30, aggression = 1 },
+        endpoints = {
+          { address = "192.168.1.1", port = "8080", maxFails = 0, failTimeout = 0, weight = 100 },
+        }
+      })
+
+      table.insert(backend.endpoints, { address = "192.168.1.2", port = "8080", maxFails = 0, failTimeout = 0 })
+      local expected_backend = {
+        name = "slow-start", slowStart = { duration = 30, aggression = 1 },
+        endpoints = {
+          { address = "192.168.1.1", port = "8080", maxFails = 0, failTimeout = 0, weight = 100 },
+          { address = "192.168.1.2", port = "8080", maxFails = 0, failTimeout = 0, weight = 1 },
+        }
+      }
+      stub(mock_instance, "sync")
+      assert.has_no.errors(function() balancer.sync_backend(util.deepcopy(backend)) end)
+      assert.stub(mock_instance.sync).was_called_with(mock_instance, expected_backend)
+
+      -- the backend is synced again until the new endpoint is warm
+      assert.has_no.errors(function() balancer.sync_backends_warming() end)
+      assert.stub(mock_instance.sync).was_called(2)
+    end)
+
     it("replaces the existing balancer when load balancing config changes for backend", function()
       assert.has_no.errors(function() balancer.sync_backend(backend) end)
 
@@ -532,5 +652,122 @@ describe("Balancer", function()
       assert.not_equal(balancer.get_balancer(), nil)
     end)
 
+    describe("enable_keepalive()", function()
+      local ngx_balancer = require("ngx.balancer")
+
+      before_each(function()
+        backends = {
+          {
+            name = "access-router-production-web-80", port = "80", secure = false,
+            endpoints = {
+              { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+            },
+            upstreamKeepalive = { enabled = true, connections = 32, timeout = 30, requests = 100 },
+          }
+        }
+        stub(ngx_balancer, "enable_keepalive", true)
+      end)
+
+      after_each(function()
+        ngx_balancer.enable_keepalive:revert()
+      end)
+
+      it("enables keepalive with the settings of the backend", function()
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", upstream_keepalive = "true" },
+                   ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        balancer.enable_keepalive("access-router-production-web-80")
+
+        assert.stub(ngx_balancer.enable_keepalive).was_called_with(30, 100)
+      end)
+
+      it("falls back to the settings of the backend of the location", function()
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", upstream_keepalive = "true" },
+                   ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        balancer.enable_keepalive("my-dummy-canary")
+
+        assert.stub(ngx_balancer.enable_keepalive).was_called_with(30, 100)
+      end)
+
+      it("does not enable keepalive when the location does not use the keepalive upstream", function()
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80" }, ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        balancer.enable_keepalive("access-router-production-web-80")
+
+        assert.stub(ngx_balancer.enable_keepalive).was_not_called()
+      end)
+
+      it("does not enable keepalive when the connections are disabled", function()
+        backends[1].upstreamKeepalive.connections = 0
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", upstream_keepalive = "true" },
+                   ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        balancer.enable_keepalive("access-router-production-web-80")
+
+        assert.stub(ngx_balancer.enable_keepalive).was_not_called()
+      end)
+    end)
+
+    describe("get_pin_key()", function()
+      before_each(function()
+        backends = {
+          {
+            name = "access-router-production-web-80", port = "80", secure = false,
+            endpoints = {
+              { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+            },
+            upstreamKeepalive = { enabled = true, connections = 32, timeout = 30, requests = 100, ntlm = true },
+          }
+        }
+      end)
+
+      it("pins the client connection with ntlm", function()
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", upstream_keepalive = "true",
+                           connection = "42" }, ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        local key, timeout = balancer.get_pin_key("access-router-production-web-80")
+
+        assert.are.equal("ntlm-42", key)
+        assert.are.equal(30, timeout)
+      end)
+
+      it("does not pin the client connection without ntlm", function()
+        backends[1].upstreamKeepalive.ntlm = nil
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", upstream_keepalive = "true",
+                           connection = "42" }, ctx = { } }, function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        assert.is_nil(balancer.get_pin_key("access-router-production-web-80"))
+      end)
+
+      it("does not pin the client connection when the location does not use the keepalive upstream", function()
+        mock_ngx({ var = { proxy_upstream_name = "access-router-production-web-80", connection = "42" }, ctx = { } },
+                 function()
+          ngx.shared.configuration_data:set("backends", cjson.encode(backends))
+        end)
+        balancer.init_worker()
+
+        assert.is_nil(balancer.get_pin_key("access-router-production-web-80"))
+      end)
+    end)
+
   end)
 end)
@@ -0,0 +1,179 @@
+local NOW = 1704067200
+local CURL = "curl/8.5.0"
+local BROWSER = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+describe("bot_mitigation", function()
+  local bot_mitigation = require("bot_mitigation")
+
+  before_each(function()
+    bot_mitigation.set_config({ key = "secret", ttl = 600 })
+  end)
+
+  describe("valid_cookie()", function()
+    it("accepts the cookies signed for the client", function()
+      local cookie = bot_mitigation.sign(tostring(NOW + 600), "10.0.0.1", BROWSER)
+      assert.is_true(bot_mitigation.valid_cookie(cookie, "10.0.0.1", BROWSER, NOW))
+    end)
+
+    it("rejects the cookies of other clients", function()
+      local cookie = bot_mitigation.sign(tostring(NOW + 600), "10.0.0.1", BROWSER)
+      assert.is_false(bot_mitigation.valid_cookie(cookie, "10.0.0.2", BROWSER, NOW))
+      assert.is_false(bot_mitigation.valid_cookie(cookie, "10.0.0.1", CURL, NOW))
+    end)
+
+    it("rejects the expired and forged cookies", function()
+      local cookie = bot_mitigation.sign(tostring(NOW - 1), "10.0.0.1", BROWSER)
+      assert.is_false(bot_mitigation.valid_cookie(cookie, "10.0.0.1", BROWSER, NOW))
+      assert.is_false(bot_mitigation.valid_cookie(tostring(NOW + 600) .. ".00", "10.0.0.1", BROWSER, NOW))
+      assert.is_false(bot_mitigation.valid_cookie("garbage", "10.0.0.1", BROWSER, NOW))
+      assert.is_false(bot_mitigation.valid_cookie(nil, "10.0.0.1", BROWSER, NOW))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+    local exit_status, redirect_uri, body, header, ctx
+
+    local function mock_ngx(var)
+      exit_status, redirect_uri, body = nil, nil, nil
+      header, ctx = {}, {}
+      var.remote_addr = var.remote_addr or "10.0.0.1"
+      var.request_uri = "/products?page=2"
+      var.namespace = "default"
+      var.ingress_name = "shop"
+      _G.ngx = setmetatable({
+        var = var,
+        header = header,
+        ctx = ctx,
+        now = function() return NOW end,
+        exit = function(status) exit_status = status end,
+        redirect = function(uri, status)
+          redirect_uri = uri
+          exit_status = status
+        end,
+        print = function(value) body = value end,
+      }, { __index = original_ngx })
+      package.loaded["bot_mitigation"] = nil
+      bot_mitigation = require("bot_mitigation")
+      bot_mitigation.set_config({ key = "secret", ttl = 600 })
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["bot_mitigation"] = nil
+      bot_mitigation = require("bot_mitigation")
+    end)
+
+    it("does nothing without bot mitigation", function()
+      mock_ngx({ http_user_agent = CURL })
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+    end)
+
+    it("does not challenge the clients without key", function()
+      mock_ngx({ bot_challenge = "cookie", http_user_agent = BROWSER })
+      bot_mitigation.set_config({ ttl = 600 })
+      bot_mitigation.rewrite()
+
+      assert.is_nil(exit_status)
+      assert.is_nil(ctx.bot_mitigation)
+    end)
+
+    it("blocks the clients without key", function()
+      mock_ngx({ bot_challenge = "cookie", bot_block_user_agents = "^curl/", http_user_agent = CURL })
+      bot_mitigation.set_config({ ttl = 600 })
+      bot_mitigation.rewrite()
+
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+    end)
+
+    it("challenges every client without rule with a cookie", function()
+      mock_ngx({ bot_challenge = "cookie", http_user_agent = BROWSER })
+      bot_mitigation.rewrite()
+
+      assert.are.equal(ngx.HTTP_TEMPORARY_REDIRECT, exit_status)
+      assert.are.equal("/products?page=2", redirect_uri)
+      assert.matches("^ingress_bot_challenge=" .. (NOW + 600) .. "%.%x+; Path=/; Max%-Age=600; HttpOnly", header["Set-Cookie"])
+      assert.are.equal("challenged", ctx.bot_mitigation)
+    end)
+
+    it("challenges the clients with JavaScript", function()
+      mock_ngx({ bot_challenge = "js", http_user_agent = BROWSER })
+      bot_mitigation.rewrite()
+
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+      assert.matches("document.cookie", body, 1, true)
+      local cookie = bot_mitigation.sign(tostring(NOW + 600), "10.0.0.1", BROWSER)
+      assert.matches(cookie:reverse(), body, 1, true)
+    end)
+
+    it("lets the clients with a valid cookie through", function()
+      local cookie = bot_mitigation.sign(tostring(NOW + 600), "10.0.0.1", BROWSER)
+      mock_ngx({ bot_challenge = "cookie", http_user_agent = BROWSER, cookie_ingress_bot_challenge = cookie })
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+    end)
+
+    it("challenges only the clients matching a rule", function()
+      local var = {
+        bot_challenge = "cookie",
+        bot_challenge_user_agents = "^(curl|python-requests)/",
+        bot_challenge_fingerprints = "t13d1516h2_8daaf6152771_02713d6af862",
+        http_user_agent = BROWSER,
+      }
+      mock_ngx(var)
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+
+      var.tls_ja4 = "t13d1516h2_8daaf6152771_02713d6af862"
+      mock_ngx(var)
+      bot_mitigation.rewrite()
+      assert.are.equal(ngx.HTTP_TEMPORARY_REDIRECT, exit_status)
+
+      var.tls_ja4 = nil
+      var.http_user_agent = CURL
+      mock_ngx(var)
+      bot_mitigation.rewrite()
+      assert.are.equal(ngx.HTTP_TEMPORARY_REDIRECT, exit_status)
+    end)
+
+    it("challenges the clients above the rate", function()
+      local var = { bot_challenge = "cookie", bot_challenge_rate = "2", http_user_agent = BROWSER, remote_addr = "10.0.0.9" }
+      mock_ngx(var)
+      bot_mitigation.rewrite()
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+
+      bot_mitigation.rewrite()
+      assert.are.equal(ngx.HTTP_TEMPORARY_REDIRECT, exit_status)
+    end)
+
+    it("blocks the clients matching the block rule", function()
+      mock_ngx({ bot_challenge = "", bot_block_user_agents = "(sqlmap|nikto)", http_user_agent = "sqlmap/1.7" })
+      bot_mitigation.rewrite()
+
+      assert.are.equal(ngx.HTTP_FORBIDDEN, exit_status)
+      assert.are.equal("blocked", ctx.bot_mitigation)
+    end)
+
+    it("does not block or challenge the allowlisted clients", function()
+      mock_ngx({
+        bot_challenge = "js",
+        bot_block_user_agents = "curl",
+        bot_allowlist_source_range = "10.0.0.0/8",
+        http_user_agent = CURL,
+      })
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+
+      mock_ngx({
+        bot_challenge = "js",
+        bot_allowlist_user_agents = "Googlebot",
+        http_user_agent = "Mozilla/5.0 (compatible; Googlebot/2.1)",
+        remote_addr = "203.0.113.1",
+      })
+      bot_mitigation.rewrite()
+      assert.is_nil(exit_status)
+    end)
+  end)
+end)
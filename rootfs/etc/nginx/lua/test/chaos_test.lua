@@ -0,0 +1,56 @@
+describe("chaos", function()
+  local original_ngx = ngx
+  local original_random = math.random
+  local chaos
+  local exit_status, slept
+
+  local function mock_ngx(var, random)
+    exit_status, slept = nil, nil
+    _G.ngx = setmetatable({
+      var = var,
+      exit = function(status) exit_status = status end,
+      sleep = function(seconds) slept = seconds end,
+    }, { __index = original_ngx })
+    math.random = function() return random end
+    package.loaded["chaos"] = nil
+    chaos = require("chaos")
+  end
+
+  after_each(function()
+    _G.ngx = original_ngx
+    math.random = original_random
+    package.loaded["chaos"] = nil
+  end)
+
+  it("does nothing without chaos annotations", function()
+    mock_ngx({}, 0)
+    chaos.rewrite()
+    assert.is_nil(exit_status)
+    assert.is_nil(slept)
+  end)
+
+  it("aborts the requests within the percentage", function()
+    mock_ngx({ chaos_abort_percent = "10", chaos_delay_ms = "0", chaos_delay_percent = "0" }, 0.05)
+    chaos.rewrite()
+    assert.are.equal(ngx.HTTP_SERVICE_UNAVAILABLE, exit_status)
+  end)
+
+  it("lets the other requests through", function()
+    mock_ngx({ chaos_abort_percent = "10", chaos_delay_ms = "0", chaos_delay_percent = "0" }, 0.5)
+    chaos.rewrite()
+    assert.is_nil(exit_status)
+  end)
+
+  it("delays the requests within the percentage", function()
+    mock_ngx({ chaos_abort_percent = "0", chaos_delay_ms = "250", chaos_delay_percent = "50" }, 0.3)
+    chaos.rewrite()
+    assert.are.equal(0.25, slept)
+    assert.is_nil(exit_status)
+  end)
+
+  it("does not delay the other requests", function()
+    mock_ngx({ chaos_abort_percent = "0", chaos_delay_ms = "250", chaos_delay_percent = "50" }, 0.7)
+    chaos.rewrite()
+    assert.is_nil(slept)
+  end)
+end)
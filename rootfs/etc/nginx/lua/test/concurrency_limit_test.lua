@@ -0,0 +1,152 @@
+local concurrency_limit = require("concurrency_limit")
+
+local original_ngx = ngx
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function active(key)
+  return ngx.shared.concurrency_limit:get("active:" .. key) or 0
+end
+
+describe("concurrency_limit", function()
+  before_each(function()
+    ngx.shared.concurrency_limit:flush_all()
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("get()", function()
+    it("returns nil when the location does not have a concurrency limit", function()
+      mock_ngx({ var = {} })
+      assert.is_nil(concurrency_limit.get())
+    end)
+
+    it("returns the concurrency limit of the location", function()
+      mock_ngx({ var = {
+        concurrency_limit = "10",
+        concurrency_limit_queue = "5",
+        concurrency_limit_queue_timeout = "0.5",
+        concurrency_limit_status = "429",
+      } })
+      assert.are.same({ limit = 10, queue = 5, queue_timeout = 0.5, status = 429 }, concurrency_limit.get())
+    end)
+  end)
+
+  describe("acquire()", function()
+    it("rejects the requests above the limit without a queue", function()
+      local policy = { limit = 2, queue = 0, queue_timeout = 1 }
+
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+      assert.is_false(concurrency_limit.acquire("default/demo", policy))
+      assert.are.equal(2, active("default/demo"))
+
+      -- the slots of each Ingress are independent
+      assert.is_true(concurrency_limit.acquire("default/other", policy))
+
+      concurrency_limit.release("default/demo")
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+    end)
+
+    it("rejects the queued requests after the queue timeout", function()
+      local policy = { limit = 1, queue = 1, queue_timeout = 0.05 }
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+
+      local start = ngx.now()
+      assert.is_false(concurrency_limit.acquire("default/demo", policy))
+      ngx.update_time()
+      assert.is_true(ngx.now() - start >= 0.05)
+      assert.are.equal(0, ngx.shared.concurrency_limit:get("queued:default/demo"))
+    end)
+
+    it("rejects the requests above the queue depth", function()
+      local policy = { limit = 1, queue = 1, queue_timeout = 1 }
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+      ngx.shared.concurrency_limit:set("queued:default/demo", 1)
+
+      local start = ngx.now()
+      assert.is_false(concurrency_limit.acquire("default/demo", policy))
+      assert.are.equal(start, ngx.now())
+    end)
+
+    it("gives a slot to a queued request when another is released", function()
+      local policy = { limit = 1, queue = 1, queue_timeout = 1 }
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+
+      ngx.timer.at(0.02, function()
+        concurrency_limit.release("default/demo")
+      end)
+
+      assert.is_true(concurrency_limit.acquire("default/demo", policy))
+      assert.are.equal(1, active("default/demo"))
+    end)
+  end)
+
+  describe("rewrite() and log()", function()
+    local var
+
+    before_each(function()
+      var = {
+        concurrency_limit = "1",
+        concurrency_limit_queue = "0",
+        concurrency_limit_queue_timeout = "1",
+        concurrency_limit_status = "503",
+        namespace = "default",
+        ingress_name = "demo",
+        request_id = "abc",
+      }
+    end)
+
+    it("does nothing when the location does not have a concurrency limit", function()
+      mock_ngx({ var = { request_id = "abc" }, ctx = {} })
+      concurrency_limit.rewrite()
+      concurrency_limit.log()
+      assert.are.equal(0, active("default/demo"))
+    end)
+
+    it("takes a slot until the request is logged", function()
+      mock_ngx({ var = var, ctx = {} })
+      concurrency_limit.rewrite()
+      assert.are.equal(1, active("default/demo"))
+
+      concurrency_limit.log()
+      assert.are.equal(0, active("default/demo"))
+    end)
+
+    it("releases the slot of a request redirected to another location", function()
+      mock_ngx({ var = var, ctx = {} })
+      concurrency_limit.rewrite()
+
+      -- the context is lost by redirects
+      mock_ngx({ var = var, ctx = {} })
+      concurrency_limit.rewrite()
+      assert.are.equal(1, active("default/demo"))
+
+      concurrency_limit.log()
+      assert.are.equal(0, active("default/demo"))
+      assert.is_nil(ngx.shared.concurrency_limit:get("holder:abc"))
+    end)
+
+    it("rejects the requests above the limit", function()
+      ngx.shared.concurrency_limit:set("active:default/demo", 1)
+
+      local status
+      mock_ngx({ var = var, ctx = {}, exit = function(s) status = s end })
+      concurrency_limit.rewrite()
+      assert.are.equal(503, status)
+
+      concurrency_limit.log()
+      assert.are.equal(1, active("default/demo"))
+    end)
+  end)
+end)
@@ -0,0 +1,73 @@
+local connect_backoff = require("connect_backoff")
+
+describe("connect_backoff", function()
+  before_each(function()
+    ngx.shared.balancer_connect_backoff:flush_all()
+    connect_backoff.set_config({ base = 100, max = 1000 })
+  end)
+
+  after_each(function()
+    connect_backoff.set_config({ base = 0, max = 10000 })
+  end)
+
+  describe("backoff()", function()
+    it("doubles with each failure up to the maximum", function()
+      for _ = 1, 100 do
+        local backoff = connect_backoff.backoff(1)
+        assert.is_true(backoff >= 50 and backoff <= 100)
+        backoff = connect_backoff.backoff(3)
+        assert.is_true(backoff >= 200 and backoff <= 400)
+        backoff = connect_backoff.backoff(10)
+        assert.is_true(backoff >= 500 and backoff <= 1000)
+      end
+    end)
+  end)
+
+  describe("record()", function()
+    it("does nothing when the backoff is disabled", function()
+      connect_backoff.set_config({ base = 0 })
+      connect_backoff.record("10.0.0.1:80", "-")
+      assert.is_false(connect_backoff.is_backing_off("10.0.0.1:80"))
+    end)
+
+    it("backs off the endpoints that could not be connected to", function()
+      connect_backoff.record("10.0.0.1:80, 10.0.0.2:80 : [fd00::1]:80", "-, 0.001 : -")
+
+      assert.is_true(connect_backoff.is_backing_off("10.0.0.1:80"))
+      assert.is_false(connect_backoff.is_backing_off("10.0.0.2:80"))
+      assert.is_true(connect_backoff.is_backing_off("[fd00::1]:80"))
+    end)
+
+    it("ignores the upstream name when no endpoint was tried", function()
+      connect_backoff.record("upstream_balancer", "-")
+      assert.is_false(connect_backoff.is_backing_off("upstream_balancer"))
+    end)
+
+    it("resets the backoff after a connection", function()
+      connect_backoff.record("10.0.0.1:80", "-")
+      connect_backoff.record("10.0.0.1:80", "0.002")
+      assert.is_false(connect_backoff.is_backing_off("10.0.0.1:80"))
+    end)
+  end)
+
+  describe("record_failure()", function()
+    it("does not count the failures while backing off", function()
+      connect_backoff.record_failure("10.0.0.1:80")
+      connect_backoff.record_failure("10.0.0.1:80")
+
+      assert.are.equal(1, ngx.shared.balancer_connect_backoff:get("failures:10.0.0.1:80"))
+    end)
+  end)
+
+  describe("avoided_peers()", function()
+    it("avoids the tried endpoints and the ones backing off", function()
+      connect_backoff.record_failure("10.0.0.1:80")
+      local avoided = connect_backoff.avoided_peers({ ["10.0.0.2:80"] = true })
+
+      assert.is_true(avoided["10.0.0.1:80"])
+      assert.is_true(avoided["10.0.0.2:80"])
+      assert.is_false(avoided["10.0.0.3:80"])
+      assert.is_false(connect_backoff.avoided_peers(nil)["10.0.0.3:80"])
+    end)
+  end)
+end)
@@ -0,0 +1,114 @@
+local cjson = require("cjson.safe")
+
+local function location(path, path_type, backend)
+  return {
+    path = path,
+    pathType = path_type,
+    backend = backend,
+    namespace = "default",
+    ingress = "web",
+    service = "web",
+    servicePort = "80",
+    sslRedirect = true,
+  }
+end
+
+local LOCATIONS = {
+  location("/api/v1", "Exact", "default-api-v1-80"),
+  location("/api/", "Prefix", "default-api-80"),
+  location("/static", "ImplementationSpecific", "default-static-80"),
+  location("/", "Prefix", "default-web-80"),
+}
+
+describe("dynamic_servers", function()
+  local dynamic_servers = require("dynamic_servers")
+
+  describe("matches()", function()
+    it("matches the exact paths", function()
+      assert.is_true(dynamic_servers.matches(LOCATIONS[1], "/api/v1"))
+      assert.is_false(dynamic_servers.matches(LOCATIONS[1], "/api/v1/"))
+    end)
+
+    it("matches the prefix paths element by element", function()
+      assert.is_true(dynamic_servers.matches(LOCATIONS[2], "/api"))
+      assert.is_true(dynamic_servers.matches(LOCATIONS[2], "/api/v2"))
+      assert.is_false(dynamic_servers.matches(LOCATIONS[2], "/apis"))
+    end)
+
+    it("matches the implementation specific paths as NGINX prefixes", function()
+      assert.is_true(dynamic_servers.matches(LOCATIONS[3], "/statics"))
+    end)
+  end)
+
+  describe("find_location()", function()
+    it("prefers the exact location", function()
+      assert.are.equal("default-api-v1-80", dynamic_servers.find_location(LOCATIONS, "/api/v1").backend)
+    end)
+
+    it("returns the longest prefix", function()
+      assert.are.equal("default-api-80", dynamic_servers.find_location(LOCATIONS, "/api/v1/users").backend)
+      assert.are.equal("default-web-80", dynamic_servers.find_location(LOCATIONS, "/about").backend)
+    end)
+
+    it("returns nothing without matching location", function()
+      assert.is_nil(dynamic_servers.find_location({ LOCATIONS[1] }, "/about"))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+
+    local function mock_ngx(var)
+      _G.ngx = setmetatable({ var = var }, { __index = original_ngx })
+      package.loaded["dynamic_servers"] = nil
+      dynamic_servers = require("dynamic_servers")
+    end
+
+    before_each(function()
+      ngx.shared.configuration_data:set("dynamic_servers", cjson.encode({ ["example.com"] = LOCATIONS }))
+      ngx.shared.configuration_data:incr("dynamic_servers_version", 1, 0)
+    end)
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["dynamic_servers"] = nil
+      dynamic_servers = require("dynamic_servers")
+    end)
+
+    it("does nothing outside of the catch-all server", function()
+      local var = { host = "example.com", uri = "/", proxy_upstream_name = "upstream-default-backend" }
+      mock_ngx(var)
+      dynamic_servers.rewrite()
+      assert.are.equal("upstream-default-backend", var.proxy_upstream_name)
+    end)
+
+    it("routes the requests of a dynamic server", function()
+      local var = {
+        dynamic_servers = "true",
+        host = "example.com",
+        uri = "/api/users",
+        proxy_upstream_name = "upstream-default-backend",
+      }
+      mock_ngx(var)
+      dynamic_servers.rewrite()
+
+      assert.are.equal("default-api-80", var.proxy_upstream_name)
+      assert.are.equal("default", var.namespace)
+      assert.are.equal("/api/", var.location_path)
+      assert.are.equal("true", var.ssl_redirect)
+      assert.are.equal("false", var.force_ssl_redirect)
+    end)
+
+    it("keeps the default backend for unknown hosts", function()
+      local var = {
+        dynamic_servers = "true",
+        host = "other.example.com",
+        uri = "/",
+        proxy_upstream_name = "upstream-default-backend",
+      }
+      mock_ngx(var)
+      dynamic_servers.rewrite()
+      assert.are.equal("upstream-default-backend", var.proxy_upstream_name)
+    end)
+  end)
+end)
@@ -0,0 +1,146 @@
+local graphql = require("graphql")
+
+local function mock_request(method, uri_args, body, content_type)
+  local status
+  local _ngx = {
+    var = {
+      graphql_enabled = "true",
+      graphql_max_depth = "2",
+      graphql_max_complexity = "0",
+      content_type = content_type or "application/json",
+    },
+    req = {
+      get_method = function() return method end,
+      get_uri_args = function() return uri_args end,
+      read_body = function() end,
+      get_body_data = function() return body end,
+      get_body_file = function() return nil end,
+    },
+    header = {},
+    say = function() end,
+    exit = function(code) status = code end,
+  }
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+
+  -- the module caches the ngx module
+  package.loaded["graphql"] = nil
+  graphql = require("graphql")
+
+  return function() return status end
+end
+
+describe("graphql", function()
+  describe("analyze()", function()
+    it("computes depth and complexity of a query", function()
+      local depth, complexity, introspection = graphql.analyze([[
+        query Hero($episode: Episode = JEDI) {
+          hero(episode: $episode, filter: { name: "R2" }) {
+            name
+            friends { name }
+          }
+        }
+      ]])
+
+      assert.are.equal(3, depth)
+      assert.are.equal(4, complexity)
+      assert.is_false(introspection)
+    end)
+
+    it("does not count aliases, comments and strings", function()
+      local depth, complexity = graphql.analyze([[
+        {
+          # { ignored { comment } }
+          first: hero(name: "{ not { a } selection }") { name }
+          second: hero { name @include(if: true) }
+        }
+      ]])
+
+      assert.are.equal(2, depth)
+      assert.are.equal(4, complexity)
+    end)
+
+    it("expands fragment spreads", function()
+      local depth, complexity = graphql.analyze([[
+        { hero { ...HeroFields ... on Droid { primaryFunction } } }
+        fragment HeroFields on Character { name friends { name } }
+      ]])
+
+      assert.are.equal(3, depth)
+      assert.are.equal(5, complexity)
+    end)
+
+    it("detects introspection queries", function()
+      local _, _, introspection = graphql.analyze("{ __schema { types { name } } }")
+      assert.is_true(introspection)
+
+      _, _, introspection = graphql.analyze("{ hero { __typename } }")
+      assert.is_false(introspection)
+    end)
+
+    it("rejects fragment cycles", function()
+      local depth, _, _, err = graphql.analyze([[
+        { ...A }
+        fragment A on Query { ...B }
+        fragment B on Query { ...A }
+      ]])
+
+      assert.is_nil(depth)
+      assert.are.equal("fragment cycle detected", err)
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local _ngx
+
+    before_each(function()
+      _ngx = _G.ngx
+    end)
+
+    after_each(function()
+      _G.ngx = _ngx
+      package.loaded["graphql"] = nil
+      graphql = require("graphql")
+    end)
+
+    it("accepts queries within the limits", function()
+      local status = mock_request("GET", { query = "{ hero { name } }" })
+      graphql.rewrite()
+      assert.is_nil(status())
+
+      status = mock_request("POST", {}, '{"query": "{ hero { name } }"}')
+      graphql.rewrite()
+      assert.is_nil(status())
+    end)
+
+    it("rejects queries exceeding the limits", function()
+      local status = mock_request("POST", {}, '{"query": "{ hero { friends { name } } }"}')
+      graphql.rewrite()
+      assert.are.equal(ngx.HTTP_BAD_REQUEST, status())
+    end)
+
+    it("rejects a query parameter sent more than once", function()
+      local status = mock_request("GET", { query = { "{ hero { name } }", "{ hero { friends { name } } }" } })
+      graphql.rewrite()
+      assert.are.equal(ngx.HTTP_BAD_REQUEST, status())
+    end)
+
+    it("rejects bodies that are not valid JSON", function()
+      local status = mock_request("POST", {}, '{"query": "{ hero { friends { name } } }"')
+      graphql.rewrite()
+      assert.are.equal(ngx.HTTP_BAD_REQUEST, status())
+    end)
+
+    it("rejects queries that are not strings", function()
+      local status = mock_request("POST", {}, '[{"query": ["{ hero { friends { name } } }"]}]')
+      graphql.rewrite()
+      assert.are.equal(ngx.HTTP_BAD_REQUEST, status())
+    end)
+
+    it("does nothing when GraphQL is not enabled", function()
+      local s = spy.on(ngx.req, "read_body")
+      graphql.rewrite()
+      assert.spy(s).was_not_called()
+    end)
+  end)
+end)
@@ -47,4 +47,48 @@ function _M.mock_resty_dns_query(mocked_host, response, err)
   end
 end
 
+-- simulate_balancer sends a request per millisecond to the balancer, each
+-- request being answered after the latency in seconds of its endpoint, and
+-- returns the number of requests received by each endpoint and the CPU time
+-- spent in balance(). ngx must be mocked, ngx.now, ngx.ctx and ngx.var are
+-- replaced during the simulation.
+function _M.simulate_balancer(instance, latencies, requests)
+  local now = 0
+  ngx.now = function() return now end
+
+  local received, pending = {}, {}
+  local spent = 0
+  for i = 1, requests do
+    now = i / 1000
+
+    local still_pending = {}
+    for _, request in ipairs(pending) do
+      if request.answered_at <= now then
+        ngx.ctx = request.ctx
+        ngx.var = {
+          upstream_addr = request.peer,
+          upstream_connect_time = "0",
+          upstream_response_time = tostring(latencies[request.peer]),
+        }
+        instance:after_balance()
+      else
+        still_pending[#still_pending + 1] = request
+      end
+    end
+    pending = still_pending
+
+    local ctx = {}
+    ngx.ctx = ctx
+    ngx.var = {}
+    local started = os.clock()
+    local peer = instance:balance()
+    spent = spent + os.clock() - started
+
+    received[peer] = (received[peer] or 0) + 1
+    pending[#pending + 1] = { ctx = ctx, peer = peer, answered_at = now + latencies[peer] }
+  end
+
+  return received, spent
+end
+
 return _M
@@ -0,0 +1,135 @@
+local CONFIG = {
+  prefix = "/grafana",
+  upstream_prefix = "",
+  upstream_urls = { "http://grafana.monitoring:3000" },
+  upstream_hosts = { ["grafana.monitoring"] = true },
+}
+
+describe("link_rewrite", function()
+  local link_rewrite = require("link_rewrite")
+
+  describe("rewrite_path()", function()
+    it("adds the prefix to the paths of the upstream", function()
+      assert.are.equal("/grafana/public/app.js", link_rewrite.rewrite_path(CONFIG, "/public/app.js"))
+      assert.are.equal("/grafana/", link_rewrite.rewrite_path(CONFIG, "/"))
+    end)
+
+    it("does not add the prefix twice", function()
+      assert.are.equal("/grafana/login", link_rewrite.rewrite_path(CONFIG, "/grafana/login"))
+    end)
+
+    it("replaces the path of the upstream by the prefix", function()
+      local config = { prefix = "/grafana", upstream_prefix = "/internal", upstream_urls = {} }
+      assert.are.equal("/grafana/login", link_rewrite.rewrite_path(config, "/internal/login"))
+      assert.are.equal("/grafana?x=1", link_rewrite.rewrite_path(config, "/internal?x=1"))
+      assert.are.equal("/other", link_rewrite.rewrite_path(config, "/other"))
+      assert.are.equal("/internals", link_rewrite.rewrite_path(config, "/internals"))
+    end)
+  end)
+
+  describe("rewrite_url()", function()
+    it("rewrites the absolute URLs of the upstream", function()
+      assert.are.equal("/grafana/login", link_rewrite.rewrite_url(CONFIG, "http://grafana.monitoring:3000/login"))
+      assert.are.equal("/grafana/", link_rewrite.rewrite_url(CONFIG, "http://grafana.monitoring:3000"))
+    end)
+
+    it("keeps the other URLs", function()
+      assert.are.equal("https://example.com/a", link_rewrite.rewrite_url(CONFIG, "https://example.com/a"))
+      assert.are.equal("//cdn.example.com/a.js", link_rewrite.rewrite_url(CONFIG, "//cdn.example.com/a.js"))
+      assert.are.equal("img/logo.png", link_rewrite.rewrite_url(CONFIG, "img/logo.png"))
+    end)
+  end)
+
+  describe("rewrite_cookie()", function()
+    it("rewrites the path and removes the domain of the upstream", function()
+      assert.are.equal("session=abc; Path=/grafana/; HttpOnly",
+        link_rewrite.rewrite_cookie(CONFIG, "session=abc; Path=/; Domain=grafana.monitoring; HttpOnly"))
+    end)
+
+    it("keeps the other domains", function()
+      assert.are.equal("id=1; Domain=example.com", link_rewrite.rewrite_cookie(CONFIG, "id=1; Domain=example.com"))
+    end)
+  end)
+
+  describe("rewrite_body()", function()
+    it("rewrites the links of the HTML pages", function()
+      local body = [[<a href="/dashboards">x</a><img src='http://grafana.monitoring:3000/logo.png'>]] ..
+        [[<form action="/login"></form><a href="https://example.com/">y</a>]]
+      assert.are.equal([[<a href="/grafana/dashboards">x</a><img src='/grafana/logo.png'>]] ..
+        [[<form action="/grafana/login"></form><a href="https://example.com/">y</a>]],
+        link_rewrite.rewrite_body(CONFIG, body, true))
+    end)
+
+    it("rewrites the URLs of the stylesheets", function()
+      assert.are.equal([[body { background: url("/grafana/bg.png") } i { src: url(/grafana/font.woff) }]],
+        link_rewrite.rewrite_body(CONFIG, [[body { background: url("/bg.png") } i { src: url(/font.woff) }]], false))
+    end)
+  end)
+
+  describe("filters", function()
+    local original_ngx = ngx
+    local header, arg, ctx
+
+    local function mock_ngx(var)
+      header, arg, ctx = {}, {}, {}
+      _G.ngx = setmetatable({ var = var, header = header, arg = arg, ctx = ctx }, { __index = original_ngx })
+      package.loaded["link_rewrite"] = nil
+      link_rewrite = require("link_rewrite")
+    end
+
+    local VAR = {
+      rewrite_links = "true",
+      rewrite_links_prefix = "/grafana",
+      rewrite_links_upstream_prefix = "",
+      rewrite_links_upstream_urls = "http://grafana.monitoring:3000",
+    }
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["link_rewrite"] = nil
+      link_rewrite = require("link_rewrite")
+    end)
+
+    it("does nothing without rewrite-links", function()
+      mock_ngx({})
+      header["Location"] = "/login"
+      link_rewrite.header_filter()
+      assert.are.equal("/login", header["Location"])
+      assert.is_nil(ctx.link_rewrite)
+    end)
+
+    it("rewrites the redirects and the cookies", function()
+      mock_ngx(VAR)
+      header["Location"] = "http://grafana.monitoring:3000/login"
+      header["Set-Cookie"] = "grafana_session=abc; Path=/"
+      link_rewrite.header_filter()
+
+      assert.are.equal("/grafana/login", header["Location"])
+      assert.are.same({ "grafana_session=abc; Path=/grafana/" }, header["Set-Cookie"])
+    end)
+
+    it("rewrites the bodies split in chunks", function()
+      mock_ngx(VAR)
+      header["Content-Type"] = "text/html; charset=utf-8"
+      header["Content-Length"] = "42"
+      link_rewrite.header_filter()
+      assert.is_nil(header["Content-Length"])
+
+      arg[1], arg[2] = [[<a hr]], false
+      link_rewrite.body_filter()
+      assert.is_nil(arg[1])
+
+      arg[1], arg[2] = [[ef="/login">]], true
+      link_rewrite.body_filter()
+      assert.are.equal([[<a href="/grafana/login">]], arg[1])
+    end)
+
+    it("does not rewrite the compressed bodies", function()
+      mock_ngx(VAR)
+      header["Content-Type"] = "text/html"
+      header["Content-Encoding"] = "gzip"
+      link_rewrite.header_filter()
+      assert.is_nil(ctx.link_rewrite)
+    end)
+  end)
+end)
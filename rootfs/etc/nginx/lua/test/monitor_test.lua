@@ -53,10 +53,70 @@ describe("Monitor", function()
     assert.equal(10, #monitor.get_metrics_batch())
   end)
 
+  it("does not batch metrics of paths excluded from the access log", function()
+    mock_ngx({ var = { loggable_path = "0" } })
+    local monitor = require("monitor")
+
+    monitor.call()
+
+    assert.equal(0, #monitor.get_metrics_batch())
+  end)
+
+  it("reports the TLS details with the first request of a connection", function()
+    local ngx_var_mock = {
+      https = "on",
+      connection_requests = "1",
+      ssl_server_name = "example.com",
+      ssl_protocol = "TLSv1.3",
+      ssl_cipher = "TLS_AES_128_GCM_SHA256",
+      ssl_client_verify = "FAILED:certificate has expired",
+    }
+    mock_ngx({ var = ngx_var_mock })
+    local monitor = require("monitor")
+
+    monitor.call()
+    ngx_var_mock.connection_requests = "2"
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.equal("example.com", batch[1].sslServerName)
+    assert.equal("TLSv1.3", batch[1].sslProtocol)
+    assert.equal("TLS_AES_128_GCM_SHA256", batch[1].sslCipher)
+    assert.equal("FAILED:certificate has expired", batch[1].sslClientVerify)
+    assert.is_nil(batch[2].sslProtocol)
+  end)
+
+  it("records the TLS handshakes", function()
+    local ssl_clienthello = require("ngx.ssl.clienthello")
+    stub(ssl_clienthello, "get_client_hello_server_name", "example.com")
+    mock_ngx({ var = {} })
+    local monitor = require("monitor")
+
+    monitor.tls_handshake()
+
+    assert.same({ tlsHandshake = "example.com" }, monitor.get_metrics_batch()[1])
+    ssl_clienthello.get_client_hello_server_name:revert()
+  end)
+
   describe("flush", function()
-    it("short circuits when premature is true (when worker is shutting down)", function()
+    local function mock_pending()
+      local batches = {}
+      return {
+        batches = batches,
+        rpush = function(_, _, value)
+          table.insert(batches, value)
+          return #batches
+        end,
+        lpop = function(_, _)
+          return table.remove(batches, 1)
+        end,
+      }
+    end
+
+    it("keeps the batch when the worker is shutting down and sends it on the next flush", function()
       local tcp_mock = mock_ngx_socket_tcp()
-      mock_ngx({ var = {} })
+      local pending = mock_pending()
+      mock_ngx({ var = {}, shared = { monitor_pending = pending } })
       local monitor = require("monitor")
 
       for i = 1,10,1 do
@@ -64,6 +124,34 @@ describe("Monitor", function()
       end
       monitor.flush(true)
       assert.stub(tcp_mock.connect).was_not_called()
+      assert.equal(1, #pending.batches)
+      assert.equal(0, #monitor.get_metrics_batch())
+
+      local payload = pending.batches[1]
+      monitor.flush()
+      assert.stub(tcp_mock.send).was_called_with(tcp_mock, payload)
+      assert.equal(0, #pending.batches)
+    end)
+
+    it("keeps the batch when it can not be sent", function()
+      local tcp_mock = {
+        connect = function() return nil, "connection refused" end,
+      }
+      stub(tcp_mock, "send", true)
+      stub(tcp_mock, "close", true)
+      local pending = mock_pending()
+      mock_ngx({ var = {}, socket = { tcp = function() return tcp_mock end }, shared = { monitor_pending = pending } })
+      local monitor = require("monitor")
+
+      monitor.call()
+      monitor.flush()
+      assert.stub(tcp_mock.send).was_not_called()
+      assert.equal(1, #pending.batches)
+
+      -- the batches are kept in order while the controller is not listening
+      monitor.call()
+      monitor.flush()
+      assert.equal(2, #pending.batches)
     end)
 
     it("short circuits when there's no metrics batched", function()
@@ -84,6 +172,7 @@ describe("Monitor", function()
         service_name = "http-svc",
         proxy_alternative_upstream_name = "default-http-svc-canary-80",
         location_path = "/",
+        uri = "/products",
 
         request_method = "GET",
         status = "200",
@@ -97,6 +186,7 @@ describe("Monitor", function()
         upstream_response_time = "0.03",
         upstream_response_length = "456",
         upstream_status = "200",
+        upstream_cache_status = "MISS",
       }
       mock_ngx({ var = ngx_var_mock })
       local monitor = require("monitor")
@@ -118,6 +208,7 @@ describe("Monitor", function()
           service = "http-svc",
           canary = "default-http-svc-canary-80",
           path = "/",
+          uri = "/products",
 
           method = "GET",
           status = "200",
@@ -129,6 +220,12 @@ describe("Monitor", function()
           upstreamHeaderTime = 0.02,
           upstreamResponseTime = 0.03,
           upstreamResponseLength = 456,
+          upstreamAddr = "10.10.0.1",
+          upstreamStatus = "200",
+          upstreamRetries = 0,
+          retryBudgetExhausted = false,
+          upstreamCacheStatus = "MISS",
+          botMitigation = "-",
         },
         {
           host = "example.com",
@@ -137,6 +234,7 @@ describe("Monitor", function()
           service = "http-svc",
           canary = "default-http-svc-canary-80",
           path = "/",
+          uri = "/products",
 
           method = "POST",
           status = "201",
@@ -148,6 +246,12 @@ describe("Monitor", function()
           upstreamHeaderTime = 0.02,
           upstreamResponseTime = 0.03,
           upstreamResponseLength = 456,
+          upstreamAddr = "10.10.0.1",
+          upstreamStatus = "200",
+          upstreamRetries = 0,
+          retryBudgetExhausted = false,
+          upstreamCacheStatus = "MISS",
+          botMitigation = "-",
         },
       })
 
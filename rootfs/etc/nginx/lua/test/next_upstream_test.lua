@@ -0,0 +1,97 @@
+local next_upstream = require("next_upstream")
+
+describe("next_upstream", function()
+  describe("tried_peers()", function()
+    it("returns the endpoints of all the tries", function()
+      assert.are.same({}, next_upstream.tried_peers(nil))
+      assert.are.same({
+        ["10.0.0.1:80"] = true,
+        ["10.0.0.2:80"] = true,
+        ["[fd00::1]:80"] = true,
+      }, next_upstream.tried_peers("10.0.0.1:80, 10.0.0.2:80 : [fd00::1]:80"))
+    end)
+  end)
+
+  describe("attempts()", function()
+    it("counts the upstream servers contacted", function()
+      assert.are.equal(0, next_upstream.attempts(nil))
+      assert.are.equal(0, next_upstream.attempts("-"))
+      assert.are.equal(1, next_upstream.attempts("10.0.0.1:80"))
+      assert.are.equal(2, next_upstream.attempts("10.0.0.1:80, 10.0.0.2:80"))
+      assert.are.equal(3, next_upstream.attempts("10.0.0.1:80, 10.0.0.2:80 : 10.0.0.3:80"))
+    end)
+  end)
+
+  describe("tries()", function()
+    it("counts the times the request was proxied", function()
+      assert.are.equal(0, next_upstream.tries(nil))
+      assert.are.equal(0, next_upstream.tries(""))
+      assert.are.equal(1, next_upstream.tries("10.0.0.1:80, 10.0.0.2:80"))
+      assert.are.equal(2, next_upstream.tries("10.0.0.1:80 : 10.0.0.2:80"))
+    end)
+  end)
+
+  describe("last_status()", function()
+    it("returns the status code of the last response", function()
+      assert.is_nil(next_upstream.last_status(nil))
+      assert.are.equal(409, next_upstream.last_status("409"))
+      assert.are.equal(503, next_upstream.last_status("502, 409 : 503"))
+    end)
+  end)
+
+  describe("should_retry()", function()
+    local original_ngx = ngx
+
+    local function mock_request(method, var)
+      _G.ngx = setmetatable({
+        var = var,
+        req = { get_method = function() return method end },
+      }, { __index = original_ngx })
+      package.loaded["next_upstream"] = nil
+      next_upstream = require("next_upstream")
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["next_upstream"] = nil
+      next_upstream = require("next_upstream")
+    end)
+
+    it("retries until the tries are exhausted", function()
+      mock_request("GET", { next_upstream_tries = "2", upstream_addr = "10.0.0.1:80" })
+      assert.is_true(next_upstream.should_retry())
+
+      mock_request("GET", { next_upstream_tries = "2", upstream_addr = "10.0.0.1:80 : 10.0.0.2:80" })
+      assert.is_false(next_upstream.should_retry())
+    end)
+
+    it("retries non idempotent requests only when allowed", function()
+      mock_request("POST", { next_upstream_tries = "3", upstream_addr = "10.0.0.1:80" })
+      assert.is_false(next_upstream.should_retry())
+
+      mock_request("POST", {
+        next_upstream_tries = "3",
+        next_upstream_non_idempotent = "true",
+        upstream_addr = "10.0.0.1:80",
+      })
+      assert.is_true(next_upstream.should_retry())
+    end)
+
+    it("retries the responses carrying the header", function()
+      mock_request("GET", {
+        next_upstream_tries = "3",
+        next_upstream_header = "x_backend_overloaded",
+        upstream_addr = "10.0.0.1:80",
+      })
+      assert.is_false(next_upstream.should_retry())
+
+      mock_request("GET", {
+        next_upstream_tries = "3",
+        next_upstream_header = "x_backend_overloaded",
+        upstream_http_x_backend_overloaded = "true",
+        upstream_addr = "10.0.0.1:80",
+      })
+      assert.is_true(next_upstream.should_retry())
+    end)
+  end)
+end)
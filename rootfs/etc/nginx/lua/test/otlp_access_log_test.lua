@@ -0,0 +1,161 @@
+local cjson = require("cjson.safe")
+local http = require("resty.http")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function mock_http(status)
+  local requests = {}
+  local httpc = {
+    set_timeout = function() end,
+    request_uri = function(_, uri, params)
+      table.insert(requests, { uri = uri, params = params })
+      if not status then
+        return nil, "connection refused"
+      end
+      return { status = status }
+    end,
+  }
+  stub(http, "new", httpc)
+  return requests
+end
+
+local ngx_var_mock = {
+  request = "GET /products?page=2 HTTP/1.1",
+  request_method = "GET",
+  scheme = "https",
+  uri = "/products",
+  args = "page=2",
+  host = "example.com",
+  remote_addr = "10.0.0.1",
+  status = "503",
+  request_length = "256",
+  bytes_sent = "512",
+  request_time = "0.04",
+  namespace = "default",
+  ingress_name = "example",
+  service_name = "http-svc",
+  upstream_addr = "10.10.0.1:8080",
+  upstream_status = "503",
+  http_traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+}
+
+describe("otlp_access_log", function()
+  local function load(var, config)
+    mock_ngx({ var = var, req = { start_time = function() return 1704067200.5 end } })
+    local otlp_access_log = require("otlp_access_log")
+    otlp_access_log.set_config(config or
+      { endpoint = "http://collector:4318/v1/logs", batch_size = 2, service_name = "ingress" })
+    return otlp_access_log
+  end
+
+  after_each(function()
+    reset_ngx()
+    package.loaded["otlp_access_log"] = nil
+    -- the stubs are callable tables
+    if type(http.new) == "table" then
+      http.new:revert()
+    end
+  end)
+
+  it("does not collect the records without endpoint", function()
+    local otlp_access_log = load(ngx_var_mock, {})
+
+    otlp_access_log.log()
+
+    assert.equal(0, #otlp_access_log.get_records())
+  end)
+
+  it("does not collect the records of the requests excluded from the access log", function()
+    local var = { loggable = "0" }
+    local otlp_access_log = load(var)
+    otlp_access_log.log()
+
+    var.loggable = "1"
+    var.otlp_access_log = "false"
+    otlp_access_log.log()
+
+    assert.equal(0, #otlp_access_log.get_records())
+  end)
+
+  it("encodes the request as a log record correlated with its trace", function()
+    local otlp_access_log = load(ngx_var_mock)
+    otlp_access_log.log()
+
+    local record = cjson.decode(otlp_access_log.get_records()[1])
+    assert.equal("1704067200500000000", record.timeUnixNano)
+    assert.equal(17, record.severityNumber)
+    assert.equal("ERROR", record.severityText)
+    assert.equal("GET /products?page=2 HTTP/1.1 503", record.body.stringValue)
+    assert.equal("0af7651916cd43dd8448eb211c80319c", record.traceId)
+    assert.equal("b7ad6b7169203331", record.spanId)
+
+    local attributes = {}
+    for _, attribute in ipairs(record.attributes) do
+      attributes[attribute.key] = attribute.value
+    end
+    assert.same({ stringValue = "GET" }, attributes["http.request.method"])
+    assert.same({ stringValue = "/products" }, attributes["url.path"])
+    assert.same({ intValue = "503" }, attributes["http.response.status_code"])
+    assert.same({ doubleValue = 0.04 }, attributes["http.server.request.duration"])
+    assert.same({ stringValue = "default" }, attributes["k8s.namespace.name"])
+    assert.same({ stringValue = "10.10.0.1:8080" }, attributes["ingress.upstream.address"])
+    assert.is_nil(attributes["user_agent.original"])
+  end)
+
+  it("prefers the trace of the OpenTelemetry module", function()
+    local var = setmetatable({
+      opentelemetry_trace_id = "4bf92f3577b34da6a3ce929d0e0e4736",
+      opentelemetry_span_id = "00f067aa0ba902b7",
+    }, { __index = ngx_var_mock })
+    local otlp_access_log = load(var)
+
+    otlp_access_log.log()
+
+    local record = cjson.decode(otlp_access_log.get_records()[1])
+    assert.equal("4bf92f3577b34da6a3ce929d0e0e4736", record.traceId)
+    assert.equal("00f067aa0ba902b7", record.spanId)
+  end)
+
+  it("sends the records in batches", function()
+    local requests = mock_http(200)
+    local otlp_access_log = load(ngx_var_mock)
+    for _ = 1, 3 do
+      otlp_access_log.log()
+    end
+
+    otlp_access_log.flush()
+
+    assert.equal(2, #requests)
+    assert.equal("http://collector:4318/v1/logs", requests[1].uri)
+    assert.equal("POST", requests[1].params.method)
+
+    local payload = cjson.decode(requests[1].params.body)
+    local resource_logs = payload.resourceLogs[1]
+    assert.same({ key = "service.name", value = { stringValue = "ingress" } }, resource_logs.resource.attributes[1])
+    assert.equal("ingress-nginx", resource_logs.scopeLogs[1].scope.name)
+    assert.equal(2, #resource_logs.scopeLogs[1].logRecords)
+    assert.equal(1, #cjson.decode(requests[2].params.body).resourceLogs[1].scopeLogs[1].logRecords)
+    assert.equal(0, #otlp_access_log.get_records())
+  end)
+
+  it("drops the records that can not be sent", function()
+    local requests = mock_http(nil)
+    local otlp_access_log = load(ngx_var_mock)
+    otlp_access_log.log()
+
+    otlp_access_log.flush()
+    otlp_access_log.flush()
+
+    assert.equal(1, #requests)
+    assert.equal(0, #otlp_access_log.get_records())
+  end)
+end)
@@ -0,0 +1,83 @@
+describe("plugins", function()
+  local original_ngx = ngx
+  local plugins
+  local calls
+
+  local function mock_ngx(var, phase)
+    _G.ngx = setmetatable({
+      var = var,
+      get_phase = function() return phase end,
+    }, { __index = original_ngx })
+    package.loaded["plugins"] = nil
+    plugins = require("plugins")
+  end
+
+  before_each(function()
+    calls = {}
+    package.preload["plugins.first"] = function()
+      return {
+        init_worker = function() calls[#calls + 1] = "first.init_worker" end,
+        rewrite = function() calls[#calls + 1] = "first.rewrite" end,
+        log = function() error("failing plugin") end,
+      }
+    end
+    package.preload["plugins.second"] = function()
+      return {
+        rewrite = function() calls[#calls + 1] = "second.rewrite" end,
+        header_filter = function() calls[#calls + 1] = "second.header_filter" end,
+      }
+    end
+    package.preload["plugins.broken"] = function()
+      error("syntax error")
+    end
+  end)
+
+  after_each(function()
+    _G.ngx = original_ngx
+    for _, name in ipairs({ "first", "second", "broken" }) do
+      package.preload["plugins." .. name] = nil
+      package.loaded["plugins." .. name] = nil
+    end
+    package.loaded["plugins"] = nil
+  end)
+
+  it("runs the phase of the plugins enabled in the location in order", function()
+    mock_ngx({ lua_plugins = "second,first" }, "rewrite")
+    plugins.init({ "first", "second" })
+    plugins.run()
+    assert.are.same({ "second.rewrite", "first.rewrite" }, calls)
+  end)
+
+  it("does not run the plugins not enabled in the location", function()
+    mock_ngx({ lua_plugins = "second" }, "rewrite")
+    plugins.init({ "first", "second" })
+    plugins.run()
+    assert.are.same({ "second.rewrite" }, calls)
+
+    calls = {}
+    mock_ngx({}, "rewrite")
+    plugins.init({ "first", "second" })
+    plugins.run()
+    assert.are.same({}, calls)
+  end)
+
+  it("does not run the plugins not loaded", function()
+    mock_ngx({ lua_plugins = "broken,unknown,second" }, "header_filter")
+    plugins.init({ "broken", "second" })
+    plugins.run()
+    assert.are.same({ "second.header_filter" }, calls)
+  end)
+
+  it("keeps running the other plugins when one fails", function()
+    mock_ngx({ lua_plugins = "first,second" }, "log")
+    plugins.init({ "first", "second" })
+    assert.has_no.errors(function() plugins.run() end)
+  end)
+
+  it("runs init_worker of the loaded plugins", function()
+    mock_ngx({}, "init_worker")
+    plugins.init({ "first", "second" })
+    plugins.init_worker()
+    assert.are.same({ "first.init_worker" }, calls)
+  end)
+end)
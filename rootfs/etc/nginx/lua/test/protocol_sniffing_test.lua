@@ -0,0 +1,63 @@
+local protocol_sniffing = require("protocol_sniffing")
+
+describe("protocol_sniffing", function()
+  describe("detect()", function()
+    it("detects TLS handshakes", function()
+      assert.are.equal("tls", protocol_sniffing.detect("\22\3\1\2\0\1\0\1\252\3\3"))
+    end)
+
+    it("detects SSH banners", function()
+      assert.are.equal("ssh", protocol_sniffing.detect("SSH-2.0-OpenSSH_9.6"))
+    end)
+
+    it("detects PROXY protocol headers", function()
+      assert.are.equal("proxy-protocol", protocol_sniffing.detect("PROXY TCP4 10.0.0.1 10.0.0.2 5000 80"))
+      assert.are.equal("proxy-protocol", protocol_sniffing.detect("\r\n\r\n\0\r\nQUIT\n\33\17"))
+    end)
+
+    it("detects HTTP/2 prior knowledge", function()
+      assert.are.equal("http2", protocol_sniffing.detect("PRI * HTTP/2.0"))
+    end)
+
+    it("detects other binary protocols", function()
+      assert.are.equal("unknown", protocol_sniffing.detect("\0\0\0\1"))
+    end)
+
+    it("ignores HTTP requests", function()
+      assert.is_nil(protocol_sniffing.detect("GET / HTTP/1.1"))
+      assert.is_nil(protocol_sniffing.detect("GET /%zz HTTP/1.1"))
+      assert.is_nil(protocol_sniffing.detect(nil))
+      assert.is_nil(protocol_sniffing.detect(""))
+    end)
+  end)
+
+  describe("log()", function()
+    local monitor = require("monitor")
+
+    before_each(function()
+      stub(monitor, "rejected_protocol")
+    end)
+
+    after_each(function()
+      monitor.rejected_protocol:revert()
+    end)
+
+    it("records the rejected protocol", function()
+      ngx.status = ngx.HTTP_BAD_REQUEST
+      ngx.var.request = "SSH-2.0-OpenSSH_9.6"
+
+      protocol_sniffing.log()
+
+      assert.stub(monitor.rejected_protocol).was_called_with("ssh")
+    end)
+
+    it("ignores responses other than bad requests", function()
+      ngx.status = ngx.HTTP_OK
+      ngx.var.request = "SSH-2.0-OpenSSH_9.6"
+
+      protocol_sniffing.log()
+
+      assert.stub(monitor.rejected_protocol).was_not_called()
+    end)
+  end)
+end)
@@ -0,0 +1,115 @@
+local RANDOM = "0123456789abcdef0123456789abcdef"
+-- 2024-01-01T00:00:00Z
+local NOW_MS = 1704067200000
+
+describe("request_id", function()
+  local request_id = require("request_id")
+
+  describe("generate()", function()
+    it("returns the NGINX request ID in the hex format", function()
+      assert.are.equal(RANDOM, request_id.generate("hex", RANDOM, NOW_MS))
+    end)
+
+    it("returns a UUIDv7", function()
+      local id = request_id.generate("uuidv7", RANDOM, NOW_MS)
+      assert.are.equal("018cc251-f400-7012-b456-789abcdef012", id)
+      assert.is_true(request_id.valid("uuidv7", id))
+    end)
+
+    it("returns a ULID", function()
+      local id = request_id.generate("ulid", RANDOM, NOW_MS)
+      assert.are.equal(26, #id)
+      assert.are.equal("01HK153X00", id:sub(1, 10))
+      assert.is_true(request_id.valid("ulid", id))
+    end)
+  end)
+
+  describe("valid()", function()
+    it("checks the format of the request ID", function()
+      assert.is_true(request_id.valid("hex", RANDOM))
+      assert.is_false(request_id.valid("hex", "abc"))
+      assert.is_false(request_id.valid("uuidv7", "018cc251-f400-4012-b456-789abcdef012"))
+      assert.is_false(request_id.valid("ulid", "01HK153X00-not-a-ulid"))
+      assert.is_false(request_id.valid("ulid", nil))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+
+    local function mock_ngx(var)
+      _G.ngx = setmetatable({ var = var, now = function() return NOW_MS / 1000 end }, { __index = original_ngx })
+      package.loaded["request_id"] = nil
+      request_id = require("request_id")
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["request_id"] = nil
+      request_id = require("request_id")
+    end)
+
+    it("does nothing without policy", function()
+      local var = { request_id = RANDOM, req_id = "incoming" }
+      mock_ngx(var)
+      request_id.rewrite()
+      assert.are.equal("incoming", var.req_id)
+    end)
+
+    it("trusts the request ID of the client", function()
+      local var = {
+        request_id_policy = "trust",
+        request_id_header = "X-Correlation-Id",
+        request_id_format = "hex",
+        http_x_correlation_id = "client-id-1",
+        request_id = RANDOM,
+      }
+      mock_ngx(var)
+      request_id.rewrite()
+      assert.are.equal("client-id-1", var.req_id)
+    end)
+
+    it("does not trust the request IDs with unsafe characters", function()
+      local var = {
+        request_id_policy = "trust",
+        request_id_header = "X-Request-ID",
+        request_id_format = "hex",
+        http_x_request_id = "id\" injected",
+        request_id = RANDOM,
+      }
+      mock_ngx(var)
+      request_id.rewrite()
+      assert.are.equal(RANDOM, var.req_id)
+    end)
+
+    it("always generates the request ID", function()
+      local var = {
+        request_id_policy = "generate",
+        request_id_header = "X-Request-ID",
+        request_id_format = "uuidv7",
+        http_x_request_id = "018cc251-f400-7012-b456-789abcdef999",
+        request_id = RANDOM,
+      }
+      mock_ngx(var)
+      request_id.rewrite()
+      assert.are.equal("018cc251-f400-7012-b456-789abcdef012", var.req_id)
+    end)
+
+    it("regenerates the request IDs without the format", function()
+      local var = {
+        request_id_policy = "regenerate",
+        request_id_header = "X-Request-ID",
+        request_id_format = "uuidv7",
+        http_x_request_id = "018cc251-f400-7012-b456-789abcdef999",
+        request_id = RANDOM,
+      }
+      mock_ngx(var)
+      request_id.rewrite()
+      assert.are.equal("018cc251-f400-7012-b456-789abcdef999", var.req_id)
+
+      var.http_x_request_id = "not-a-uuid"
+      request_id.rewrite()
+      assert.are.equal("018cc251-f400-7012-b456-789abcdef012", var.req_id)
+    end)
+  end)
+end)
@@ -0,0 +1,51 @@
+local retry_policy = require("retry_policy")
+
+describe("retry_policy", function()
+  before_each(function()
+    ngx.shared.balancer_retry_budget:flush_all()
+  end)
+
+  describe("get()", function()
+    it("returns nil when the location does not have a retry policy", function()
+      assert.is_nil(retry_policy.get())
+    end)
+  end)
+
+  describe("within_budget()", function()
+    it("is unlimited when the budget is 0", function()
+      retry_policy.record("backend", true)
+      assert.is_true(retry_policy.within_budget("backend", 0))
+    end)
+
+    it("limits the retries to a percentage of the requests", function()
+      for _ = 1, 10 do
+        retry_policy.record("backend", false)
+      end
+
+      assert.is_true(retry_policy.within_budget("backend", 20))
+      retry_policy.record("backend", true)
+      assert.is_true(retry_policy.within_budget("backend", 20))
+      retry_policy.record("backend", true)
+      assert.is_false(retry_policy.within_budget("backend", 20))
+
+      -- the budget of each backend is independent
+      retry_policy.record("other-backend", false)
+      assert.is_true(retry_policy.within_budget("other-backend", 20))
+    end)
+  end)
+
+  describe("pick_untried_peer()", function()
+    it("returns an endpoint that was not tried", function()
+      local peers = { "10.0.0.1:80", "10.0.0.2:80" }
+      local i = 0
+      local balancer = { balance = function() i = i % #peers + 1; return peers[i] end }
+
+      assert.are.equal("10.0.0.2:80", retry_policy.pick_untried_peer(balancer, { ["10.0.0.1:80"] = true }))
+    end)
+
+    it("falls back to a tried endpoint", function()
+      local balancer = { balance = function() return "10.0.0.1:80" end }
+      assert.are.equal("10.0.0.1:80", retry_policy.pick_untried_peer(balancer, { ["10.0.0.1:80"] = true }))
+    end)
+  end)
+end)
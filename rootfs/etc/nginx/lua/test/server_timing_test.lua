@@ -0,0 +1,96 @@
+describe("server_timing", function()
+  local server_timing = require("server_timing")
+
+  describe("sum()", function()
+    it("returns the sum of the times of the tries", function()
+      assert.are.near(0.01, server_timing.sum("0.010"), 1e-9)
+      assert.are.near(0.016, server_timing.sum("0.010, 0.002 : 0.004"), 1e-9)
+      assert.are.near(0.002, server_timing.sum("-, 0.002"), 1e-9)
+    end)
+
+    it("returns nil without known time", function()
+      assert.is_nil(server_timing.sum(nil))
+      assert.is_nil(server_timing.sum("-"))
+    end)
+  end)
+
+  describe("retries()", function()
+    it("counts the upstream servers tried after the first one", function()
+      assert.are.equal(0, server_timing.retries("10.0.0.1:8080"))
+      assert.are.equal(2, server_timing.retries("10.0.0.1:8080, 10.0.0.2:8080, [::1]:8080"))
+      assert.are.equal(1, server_timing.retries("10.0.0.1:8080 : 10.0.0.2:8080"))
+    end)
+
+    it("returns nil when the request was not proxied", function()
+      assert.is_nil(server_timing.retries(nil))
+      assert.is_nil(server_timing.retries(""))
+    end)
+  end)
+
+  describe("build()", function()
+    it("returns the timings of a proxied request", function()
+      local value = server_timing.build({
+        upstream_connect_time = "0.002, 0.001",
+        upstream_header_time = "-, 0.040",
+        upstream_response_time = "0.003, 0.045",
+        upstream_cache_status = "MISS",
+        upstream_addr = "10.0.0.1:8080, 10.0.0.2:8080",
+      }, 0.050)
+      assert.are.equal("upstream-connect;dur=3, upstream-header;dur=40, upstream-response;dur=48, " ..
+        "edge;dur=10, cache;desc=MISS, upstream-retries;desc=1", value)
+    end)
+
+    it("returns the time spent in NGINX without upstream", function()
+      assert.are.equal("edge;dur=12", server_timing.build({}, 0.012))
+    end)
+  end)
+
+  describe("header_filter()", function()
+    local original_ngx = ngx
+
+    local function mock_ngx(var, header)
+      _G.ngx = setmetatable({
+        var = var,
+        header = header,
+        now = function() return 100.025 end,
+        update_time = function() end,
+        req = { start_time = function() return 100 end },
+      }, { __index = original_ngx })
+      package.loaded["server_timing"] = nil
+      server_timing = require("server_timing")
+    end
+
+    after_each(function()
+      _G.ngx = original_ngx
+      package.loaded["server_timing"] = nil
+      server_timing = require("server_timing")
+    end)
+
+    it("does nothing when disabled", function()
+      local header = {}
+      mock_ngx({}, header)
+      server_timing.header_filter()
+      assert.is_nil(header["Server-Timing"])
+    end)
+
+    it("adds the Server-Timing and Timing-Allow-Origin headers", function()
+      local header = {}
+      mock_ngx({
+        server_timing = "true",
+        server_timing_allow_origin = "https://www.example.com",
+        upstream_header_time = "0.020",
+        upstream_addr = "10.0.0.1:8080",
+      }, header)
+      server_timing.header_filter()
+      assert.are.equal("upstream-header;dur=20, edge;dur=5, upstream-retries;desc=0", header["Server-Timing"])
+      assert.are.equal("https://www.example.com", header["Timing-Allow-Origin"])
+    end)
+
+    it("keeps the Server-Timing header of the upstream", function()
+      local header = { ["Server-Timing"] = "db;dur=12" }
+      mock_ngx({ server_timing = "true" }, header)
+      server_timing.header_filter()
+      assert.are.same({ "db;dur=12", "edge;dur=25" }, header["Server-Timing"])
+    end)
+  end)
+end)
@@ -0,0 +1,48 @@
+local tls_fingerprint = require("tls_fingerprint")
+
+local function client_hello()
+  return {
+    version = 0x0303,
+    ciphers = { 0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f },
+    extensions = { 0x1a1a, 0, 23, 65281, 10, 11, 35, 16, 5, 13, 43, 51 },
+    groups = { 0x2a2a, 29, 23, 24 },
+    point_formats = { 0 },
+    signature_algorithms = { 0x0403, 0x0804, 0x0401 },
+    supported_versions = { 0x3a3a, 0x0304, 0x0303 },
+    alpn = "h2",
+  }
+end
+
+describe("tls_fingerprint", function()
+  describe("ja3()", function()
+    it("returns the JA3 hash and string without GREASE values", function()
+      local hash, value = tls_fingerprint.ja3(client_hello())
+
+      assert.are.equal("771,4865-4866-4867-49195-49199,0-23-65281-10-11-35-16-5-13-43-51,29-23-24,0", value)
+      assert.are.equal("86eb1d1ac2cad442410bd3fbd0ba751a", hash)
+    end)
+  end)
+
+  describe("ja4()", function()
+    it("returns the JA4 fingerprint", function()
+      assert.are.equal("t13d0511h2_e133e205ac38_870fd19323a3", tls_fingerprint.ja4(client_hello()))
+    end)
+
+    it("uses the legacy version, no SNI and no ALPN when the extensions are missing", function()
+      local hello = client_hello()
+      hello.extensions = { 23, 65281, 10, 11, 35, 5, 13, 51 }
+      hello.supported_versions = {}
+      hello.alpn = nil
+
+      assert.matches("^t12i050800_e133e205ac38_", tls_fingerprint.ja4(hello))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    it("does nothing when fingerprinting is not enabled", function()
+      local s = spy.on(ngx.req, "set_header")
+      tls_fingerprint.rewrite()
+      assert.spy(s).was_not_called()
+    end)
+  end)
+end)
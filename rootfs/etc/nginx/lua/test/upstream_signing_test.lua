@@ -0,0 +1,134 @@
+local upstream_signing = require("upstream_signing")
+
+local EMPTY_SHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+-- credentials of the AWS Signature Version 4 test suite
+local AWS_CREDENTIALS = {
+  access_key_id = "AKIDEXAMPLE",
+  secret_access_key = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+describe("upstream_signing", function()
+  describe("uri_encode()", function()
+    it("encodes the reserved characters", function()
+      assert.are.equal("a%20b%2Fc-d_e.f~g%2B%3D", upstream_signing.uri_encode("a b/c-d_e.f~g+="))
+      assert.are.equal("/photos/a%20b.jpg", upstream_signing.uri_encode("/photos/a b.jpg", true))
+    end)
+  end)
+
+  describe("canonical_query()", function()
+    it("sorts and encodes the parameters", function()
+      assert.are.equal("", upstream_signing.canonical_query(""))
+      assert.are.equal("a=&a1=2&b=1%202&b=3", upstream_signing.canonical_query("b=3&a1=2&b=1%202&a"))
+    end)
+  end)
+
+  describe("sigv4_authorization()", function()
+    local function request(args)
+      return {
+        method = "GET",
+        uri = "/",
+        args = args,
+        headers = { host = "example.amazonaws.com", ["x-amz-date"] = "20150830T123600Z" },
+        payload_hash = EMPTY_SHA256,
+      }
+    end
+
+    it("signs the get-vanilla request of the test suite", function()
+      assert.are.equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " ..
+        "SignedHeaders=host;x-amz-date, " ..
+        "Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+        upstream_signing.sigv4_authorization(request(""), AWS_CREDENTIALS, "us-east-1", "service"))
+    end)
+
+    it("signs the get-vanilla-query-order-key-case request of the test suite", function()
+      assert.are.equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " ..
+        "SignedHeaders=host;x-amz-date, " ..
+        "Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+        upstream_signing.sigv4_authorization(request("Param2=value2&Param1=value1"), AWS_CREDENTIALS,
+          "us-east-1", "service"))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    local original_ngx = ngx
+    local credentials_file
+
+    local function mock_ngx(var, body)
+      _G.ngx = setmetatable({
+        var = var,
+        time = function() return 1388957500 end,
+        req = setmetatable({
+          get_method = function() return "POST" end,
+          read_body = function() end,
+          get_body_data = function() return body end,
+          get_body_file = function() return nil end,
+        }, { __index = original_ngx.req }),
+      }, { __index = original_ngx })
+      package.loaded["upstream_signing"] = nil
+      upstream_signing = require("upstream_signing")
+    end
+
+    before_each(function()
+      credentials_file = os.tmpname()
+      local f = assert(io.open(credentials_file, "w"))
+      f:write('{"key":"secret","key_id":"partner-1","access_key_id":"AKIDEXAMPLE","secret_access_key":"secret"}')
+      f:close()
+    end)
+
+    after_each(function()
+      os.remove(credentials_file)
+      _G.ngx = original_ngx
+      package.loaded["upstream_signing"] = nil
+      upstream_signing = require("upstream_signing")
+    end)
+
+    it("does nothing when the location does not sign the requests", function()
+      local var = {}
+      mock_ngx(var, nil)
+      upstream_signing.rewrite()
+      assert.is_nil(var.upstream_signing_signature)
+    end)
+
+    it("signs the request with hmac-sha256", function()
+      local var = {
+        upstream_signing_method = "hmac-sha256",
+        upstream_signing_credentials_file = credentials_file,
+        upstream_signing_credentials_sha = "hmac-sha",
+        upstream_signing_host = "",
+        best_http_host = "example.com",
+        request_uri = "/foo?param=value&pet=dog",
+        uri = "/foo",
+        args = "param=value&pet=dog",
+      }
+      mock_ngx(var, '{"hello": "world"}')
+      upstream_signing.rewrite()
+
+      assert.are.equal("Sun, 05 Jan 2014 21:31:40 GMT", var.upstream_signing_date)
+      assert.are.equal("SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", var.upstream_signing_digest)
+      assert.are.equal('keyId="partner-1",algorithm="hmac-sha256",headers="(request-target) host date digest",' ..
+        'signature="wQkvoAcAkM6CrpcA2gY4MET0zC2ixR6sgZF/d5HTvCA="', var.upstream_signing_signature)
+    end)
+
+    it("signs S3 requests without hashing the payload", function()
+      local var = {
+        upstream_signing_method = "aws-sigv4",
+        upstream_signing_credentials_file = credentials_file,
+        upstream_signing_credentials_sha = "s3-sha",
+        upstream_signing_host = "bucket.s3.us-east-1.amazonaws.com",
+        upstream_signing_region = "us-east-1",
+        upstream_signing_service = "s3",
+        request_uri = "/index.html",
+        uri = "/index.html",
+        args = "",
+      }
+      mock_ngx(var, "ignored")
+      upstream_signing.rewrite()
+
+      assert.are.equal("20140105T213140Z", var.upstream_signing_date)
+      assert.are.equal("UNSIGNED-PAYLOAD", var.upstream_signing_content_sha256)
+      assert.is_truthy(string.find(var.upstream_signing_authorization,
+        "SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=", 1, true))
+    end)
+  end)
+end)
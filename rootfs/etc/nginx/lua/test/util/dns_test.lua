@@ -133,4 +133,115 @@ describe("dns.lookup", function()
     assert.are.same({ "192.168.1.1", "1.2.3.4" }, dns_lookup("example.com."))
     assert.spy(spy_cache_set).was_called_with(match.is_table(), "example.com.", { "192.168.1.1", "1.2.3.4" }, 60)
   end)
+
+  it("caches with the given ttl instead of the one of the records", function()
+    helpers.mock_resty_dns_query("example.com.", { { name = "example.com.", address = "192.168.1.1", ttl = 3600 } })
+
+    local spy_cache_set = spy.on(dns._cache, "set")
+
+    assert.are.same({ "192.168.1.1" }, dns_lookup("example.com.", 5))
+    assert.spy(spy_cache_set).was_called_with(match.is_table(), "example.com.#5", { "192.168.1.1" }, 5)
+  end)
+end)
+
+describe("dns.lookup_srv", function()
+  local resolver = require("resty.dns.resolver")
+  local dns, spy_ngx_log
+
+  -- mock_answers mocks the answers of the queries by name and record type
+  local function mock_answers(answers)
+    helpers.mock_resty_dns_new(function(self, options)
+      return {
+        query = function(self, name, options, tries)
+          return answers[name .. "/" .. options.qtype] or {}
+        end
+      }
+    end)
+  end
+
+  before_each(function()
+    spy_ngx_log = spy.on(ngx, "log")
+    dns = require("util.dns")
+  end)
+
+  after_each(function()
+    package.loaded["util.dns"] = nil
+  end)
+
+  it("returns the weighted endpoints of the records with the lowest priority", function()
+    mock_answers({
+      ["_http._tcp.example.com./" .. resolver.TYPE_SRV] = {
+        { target = "backup.example.com", port = 8080, weight = 10, priority = 20, ttl = 60 },
+        { target = "a.example.com", port = 80, weight = 3, priority = 10, ttl = 60 },
+        { target = "b.example.com", port = 8080, weight = 1, priority = 10, ttl = 30 },
+      },
+      ["a.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.1", ttl = 60 } },
+      ["b.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.2", ttl = 60 } },
+      ["backup.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.3", ttl = 60 } },
+    })
+
+    local spy_cache_set = spy.on(dns._cache, "set")
+
+    local expected = {
+      { address = "192.168.1.1", port = "80", weight = 3 },
+      { address = "192.168.1.2", port = "8080", weight = 1 },
+    }
+    assert.are.same(expected, dns.lookup_srv("_http._tcp.example.com."))
+    assert.spy(spy_cache_set).was_called_with(match.is_table(), "srv:_http._tcp.example.com.", expected, 30)
+  end)
+
+  it("falls back to the next priority when the targets do not resolve", function()
+    mock_answers({
+      ["_http._tcp.example.com./" .. resolver.TYPE_SRV] = {
+        { target = "a.example.com", port = 80, weight = 1, priority = 10, ttl = 60 },
+        { target = "backup.example.com", port = 8080, weight = 1, priority = 20, ttl = 60 },
+      },
+      ["backup.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.3", ttl = 60 } },
+    })
+
+    assert.are.same({ { address = "192.168.1.3", port = "8080", weight = 1 } },
+      dns.lookup_srv("_http._tcp.example.com."))
+  end)
+
+  it("ignores the records without weight unless all of them are", function()
+    mock_answers({
+      ["_http._tcp.example.com./" .. resolver.TYPE_SRV] = {
+        { target = "a.example.com", port = 80, weight = 0, priority = 10, ttl = 60 },
+        { target = "b.example.com", port = 80, weight = 5, priority = 10, ttl = 60 },
+      },
+      ["_grpc._tcp.example.com./" .. resolver.TYPE_SRV] = {
+        { target = "a.example.com", port = 80, weight = 0, priority = 10, ttl = 60 },
+      },
+      ["a.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.1", ttl = 60 } },
+      ["b.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.2", ttl = 60 } },
+    })
+
+    assert.are.same({ { address = "192.168.1.2", port = "80", weight = 5 } },
+      dns.lookup_srv("_http._tcp.example.com."))
+    assert.are.same({ { address = "192.168.1.1", port = "80", weight = 1 } },
+      dns.lookup_srv("_grpc._tcp.example.com."))
+  end)
+
+  it("caches the endpoints with the given ttl", function()
+    mock_answers({
+      ["_http._tcp.example.com./" .. resolver.TYPE_SRV] = {
+        { target = "a.example.com", port = 80, weight = 1, priority = 10, ttl = 3600 },
+      },
+      ["a.example.com./" .. resolver.TYPE_A] = { { address = "192.168.1.1", ttl = 3600 } },
+    })
+
+    local spy_cache_set = spy.on(dns._cache, "set")
+
+    local expected = { { address = "192.168.1.1", port = "80", weight = 1 } }
+    assert.are.same(expected, dns.lookup_srv("_http._tcp.example.com.", 5))
+    assert.spy(spy_cache_set).was_called_with(match.is_table(), "srv:_http._tcp.example.com.#5", expected, 5)
+  end)
+
+  it("returns nil when there are no SRV records", function()
+    mock_answers({})
+
+    assert.is_nil(dns.lookup_srv("_http._tcp.example.com."))
+    assert.spy(spy_ngx_log).was_called_with(ngx.ERR, "failed to query the DNS server for ",
+      "_http._tcp.example.com.", ":\n", "no SRV record resolved")
+  end)
 end)
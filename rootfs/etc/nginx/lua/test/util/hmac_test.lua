@@ -0,0 +1,15 @@
+local hmac = require("util.hmac")
+local resty_string = require("resty.string")
+
+describe("hmac", function()
+  describe("sha256()", function()
+    it("returns the HMAC of the RFC 4231 test cases", function()
+      assert.are.equal("5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+        resty_string.to_hex(hmac.sha256("Jefe", "what do ya want for nothing?")))
+
+      assert.are.equal("60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54",
+        resty_string.to_hex(hmac.sha256(string.rep("\170", 131),
+          "Test Using Larger Than Block-Size Key - Hash Key First")))
+    end)
+  end)
+end)
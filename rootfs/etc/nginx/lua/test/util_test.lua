@@ -41,6 +41,16 @@ describe("utility", function()
     end)
   end)
 
+  describe("get_nodes", function()
+    it("returns the endpoints with their weight", function()
+      local endpoints = {
+        { address = "10.10.10.1", port = "8080" },
+        { address = "10.10.10.2", port = "8080", weight = 3 },
+      }
+      assert.are.same({ ["10.10.10.1:8080"] = 1, ["10.10.10.2:8080"] = 3 }, util.get_nodes(endpoints))
+    end)
+  end)
+
   describe("diff_endpoints", function()
     it("returns removed and added endpoints", function()
       local old = {
@@ -0,0 +1,306 @@
+local ffi = require("ffi")
+local bit = require("bit")
+local ssl = require("ngx.ssl")
+local ssl_clienthello = require("ngx.ssl.clienthello")
+local lrucache = require("resty.lrucache")
+local resty_sha256 = require("resty.sha256")
+local resty_string = require("resty.string")
+
+local ngx = ngx
+local ipairs = ipairs
+local tonumber = tonumber
+local tostring = tostring
+local unpack = unpack
+local string_byte = string.byte
+local string_format = string.format
+local string_sub = string.sub
+local string_find = string.find
+local table_concat = table.concat
+local table_sort = table.sort
+local math_min = math.min
+local band = bit.band
+local rshift = bit.rshift
+
+local _M = {}
+
+ffi.cdef[[
+unsigned int SSL_client_hello_get0_legacy_version(void *s);
+size_t SSL_client_hello_get0_ciphers(void *s, const unsigned char **out);
+int SSL_client_hello_get1_extensions_present(void *s, int **out, size_t *outlen);
+void CRYPTO_free(void *ptr, const char *file, int line);
+]]
+
+local C = ffi.C
+
+local JA3_HEADER = "X-JA3-Fingerprint"
+local JA4_HEADER = "X-JA4-Fingerprint"
+
+local EXT_SERVER_NAME = 0
+local EXT_SUPPORTED_GROUPS = 10
+local EXT_EC_POINT_FORMATS = 11
+local EXT_SIGNATURE_ALGORITHMS = 13
+local EXT_ALPN = 16
+local EXT_SUPPORTED_VERSIONS = 43
+
+local JA4_VERSIONS = {
+  [0x0304] = "13",
+  [0x0303] = "12",
+  [0x0302] = "11",
+  [0x0301] = "10",
+  [0x0300] = "s3",
+  [0x0002] = "s2",
+  [0xfeff] = "d1",
+  [0xfefd] = "d2",
+  [0xfefc] = "d3",
+}
+
+-- number of connections whose fingerprints are kept by each worker.
+-- A connection always gets new fingerprints during its handshake, even
+-- when the address of its SSL object is reused.
+local CACHE_SIZE = 20000
+
+local config = {
+  enabled = false,
+  headers = false,
+}
+
+local fingerprints
+
+local cptr = ffi.new("const unsigned char *[1]")
+local iptr = ffi.new("int *[1]")
+local sizeptr = ffi.new("size_t[1]")
+
+-- GREASE values (RFC 8701) are ignored by both fingerprints
+local function is_grease(value)
+  return band(value, 0x0f0f) == 0x0a0a and rshift(value, 8) == band(value, 0xff)
+end
+
+local function uint16_list(data, offset, length)
+  local list = {}
+  for i = offset, offset + length - 2, 2 do
+    local hi, lo = string_byte(data, i, i + 1)
+    if not lo then
+      break
+    end
+    list[#list + 1] = hi * 256 + lo
+  end
+  return list
+end
+
+local function uint16_vector(data)
+  if not data or #data < 2 then
+    return {}
+  end
+  local hi, lo = string_byte(data, 1, 2)
+  return uint16_list(data, 3, hi * 256 + lo)
+end
+
+local function uint8_vector(data)
+  if not data or #data < 1 then
+    return {}
+  end
+  local list = {}
+  for i = 2, math_min(#data, string_byte(data, 1) + 1) do
+    list[#list + 1] = string_byte(data, i)
+  end
+  return list
+end
+
+local function first_alpn(data)
+  if not data or #data < 3 then
+    return nil
+  end
+  local length = string_byte(data, 3)
+  return string_sub(data, 4, 3 + length)
+end
+
+local function without_grease(list)
+  local result = {}
+  for _, value in ipairs(list) do
+    if not is_grease(value) then
+      result[#result + 1] = value
+    end
+  end
+  return result
+end
+
+local function join(list, format, separator)
+  local result = {}
+  for i, value in ipairs(list) do
+    result[i] = string_format(format, value)
+  end
+  return table_concat(result, separator)
+end
+
+local function truncated_sha256(value)
+  if value == "" then
+    return "000000000000"
+  end
+  local sha256 = resty_sha256:new()
+  sha256:update(value)
+  return string_sub(resty_string.to_hex(sha256:final()), 1, 12)
+end
+
+-- ja3 returns the MD5 hash of the JA3 string of a ClientHello, and the string
+function _M.ja3(hello)
+  local value = table_concat({
+    tostring(hello.version),
+    join(without_grease(hello.ciphers), "%d", "-"),
+    join(without_grease(hello.extensions), "%d", "-"),
+    join(without_grease(hello.groups), "%d", "-"),
+    join(hello.point_formats, "%d", "-"),
+  }, ",")
+  return ngx.md5(value), value
+end
+
+-- ja4 returns the JA4 fingerprint of a ClientHello received over TCP
+function _M.ja4(hello)
+  -- the highest version of the supported_versions extension is preferred
+  local version = hello.version
+  local supported_versions = without_grease(hello.supported_versions)
+  if #supported_versions > 0 then
+    version = supported_versions[1]
+    for _, v in ipairs(supported_versions) do
+      if v > version then
+        version = v
+      end
+    end
+  end
+
+  local ciphers = without_grease(hello.ciphers)
+  local extensions = without_grease(hello.extensions)
+
+  local sni = "i"
+  local sorted_extensions = {}
+  for _, ext in ipairs(extensions) do
+    if ext == EXT_SERVER_NAME then
+      sni = "d"
+    end
+    if ext ~= EXT_SERVER_NAME and ext ~= EXT_ALPN then
+      sorted_extensions[#sorted_extensions + 1] = ext
+    end
+  end
+
+  local alpn = "00"
+  if hello.alpn and #hello.alpn > 0 then
+    alpn = string_sub(hello.alpn, 1, 1) .. string_sub(hello.alpn, -1)
+    if not string_find(alpn, "^%w%w$") then
+      alpn = string_format("%02x", string_byte(hello.alpn, 1)):sub(1, 1) ..
+        string_format("%02x", string_byte(hello.alpn, -1)):sub(-1)
+    end
+  end
+
+  local sorted_ciphers = { unpack(ciphers) }
+  table_sort(sorted_ciphers)
+  table_sort(sorted_extensions)
+
+  local ext_value = join(sorted_extensions, "%04x", ",")
+  local signature_algorithms = without_grease(hello.signature_algorithms)
+  if #signature_algorithms > 0 and ext_value ~= "" then
+    ext_value = ext_value .. "_" .. join(signature_algorithms, "%04x", ",")
+  end
+
+  return string_format("t%s%s%02d%02d%s_%s_%s",
+    JA4_VERSIONS[version] or "00",
+    sni,
+    math_min(#ciphers, 99),
+    math_min(#extensions, 99),
+    alpn,
+    truncated_sha256(join(sorted_ciphers, "%04x", ",")),
+    truncated_sha256(ext_value))
+end
+
+-- read_client_hello returns the fields of the ClientHello being processed
+local function read_client_hello(ssl_ptr)
+  local hello = {
+    version = tonumber(C.SSL_client_hello_get0_legacy_version(ssl_ptr)),
+    ciphers = {},
+    extensions = {},
+  }
+
+  local length = tonumber(C.SSL_client_hello_get0_ciphers(ssl_ptr, cptr))
+  if length > 0 then
+    hello.ciphers = uint16_list(ffi.string(cptr[0], length), 1, length)
+  end
+
+  if C.SSL_client_hello_get1_extensions_present(ssl_ptr, iptr, sizeptr) == 1 then
+    for i = 0, tonumber(sizeptr[0]) - 1 do
+      hello.extensions[#hello.extensions + 1] = iptr[0][i]
+    end
+    C.CRYPTO_free(iptr[0], "tls_fingerprint.lua", 0)
+  end
+
+  hello.groups = uint16_vector(ssl_clienthello.get_client_hello_ext(EXT_SUPPORTED_GROUPS))
+  hello.point_formats = uint8_vector(ssl_clienthello.get_client_hello_ext(EXT_EC_POINT_FORMATS))
+  hello.signature_algorithms = uint16_vector(ssl_clienthello.get_client_hello_ext(EXT_SIGNATURE_ALGORITHMS))
+  hello.supported_versions = {}
+  local supported_versions = ssl_clienthello.get_client_hello_ext(EXT_SUPPORTED_VERSIONS)
+  if supported_versions then
+    hello.supported_versions = uint16_list(supported_versions, 2, string_byte(supported_versions, 1))
+  end
+  hello.alpn = first_alpn(ssl_clienthello.get_client_hello_ext(EXT_ALPN))
+
+  return hello
+end
+
+local function connection_key(ssl_ptr)
+  return tostring(tonumber(ffi.cast("uintptr_t", ssl_ptr)))
+end
+
+function _M.set_config(enabled, headers)
+  config.enabled = enabled == true
+  config.headers = headers == true
+
+  if config.enabled and not fingerprints then
+    local err
+    fingerprints, err = lrucache.new(CACHE_SIZE)
+    if not fingerprints then
+      error("failed to create the TLS fingerprints cache: " .. tostring(err))
+    end
+  end
+end
+
+-- client_hello computes the fingerprints of the connection during the handshake
+function _M.client_hello()
+  if not config.enabled then
+    return
+  end
+
+  local ssl_ptr, err = ssl.get_req_ssl_pointer()
+  if not ssl_ptr then
+    ngx.log(ngx.ERR, "failed to get the SSL object: ", err)
+    return
+  end
+
+  local hello = read_client_hello(ssl_ptr)
+  fingerprints:set(connection_key(ssl_ptr), { ja3 = _M.ja3(hello), ja4 = _M.ja4(hello) })
+end
+
+-- rewrite exposes the fingerprints of the connection in the $tls_ja3 and
+-- $tls_ja4 variables and, when enabled, in headers sent to the upstream
+function _M.rewrite()
+  if not config.enabled then
+    return
+  end
+
+  local fingerprint
+  if ngx.var.https == "on" then
+    local ssl_ptr = ssl.get_req_ssl_pointer()
+    if ssl_ptr then
+      fingerprint = fingerprints:get(connection_key(ssl_ptr))
+    end
+  end
+
+  if fingerprint then
+    ngx.var.tls_ja3 = fingerprint.ja3
+    ngx.var.tls_ja4 = fingerprint.ja4
+  end
+
+  if config.headers then
+    -- the headers sent by the client are never trusted
+    ngx.req.set_header(JA3_HEADER, fingerprint and fingerprint.ja3)
+    ngx.req.set_header(JA4_HEADER, fingerprint and fingerprint.ja4)
+  end
+end
+
+return _M
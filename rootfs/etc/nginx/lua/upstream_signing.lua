@@ -0,0 +1,259 @@
+local cjson = require("cjson.safe")
+local lrucache = require("resty.lrucache")
+local resty_sha256 = require("resty.sha256")
+local resty_string = require("resty.string")
+local hmac = require("util.hmac")
+
+local ngx = ngx
+local io = io
+local type = type
+local pairs = pairs
+local ipairs = ipairs
+local os_date = os.date
+local string_byte = string.byte
+local string_format = string.format
+local string_gsub = string.gsub
+local string_gmatch = string.gmatch
+local string_lower = string.lower
+local string_match = string.match
+local string_sub = string.sub
+local table_concat = table.concat
+local table_sort = table.sort
+
+local _M = {}
+
+local UNSIGNED_PAYLOAD = "UNSIGNED-PAYLOAD"
+local BODY_CHUNK_SIZE = 65536
+
+-- the credentials are cached by the SHA of their file, rotated
+-- credentials are read again as their file has a new SHA
+local credentials, cache_err = lrucache.new(100)
+if not credentials then
+  error("failed to create the upstream signing credentials cache: " .. tostring(cache_err))
+end
+
+local function load_credentials(path, sha)
+  local creds = credentials:get(sha)
+  if creds then
+    return creds
+  end
+
+  local f, open_err = io.open(path, "rb")
+  if not f then
+    ngx.log(ngx.ERR, "failed to open upstream signing credentials file: ", open_err)
+    return nil
+  end
+  local content = f:read("*a")
+  f:close()
+
+  creds = cjson.decode(content)
+  if type(creds) ~= "table" then
+    ngx.log(ngx.ERR, "invalid upstream signing credentials file ", path)
+    return nil
+  end
+
+  credentials:set(sha, creds)
+  return creds
+end
+
+local function sha256_hex(value)
+  local sha = resty_sha256:new()
+  sha:update(value)
+  return resty_string.to_hex(sha:final())
+end
+
+-- body_sha256 returns the binary SHA-256 of the request body
+local function body_sha256()
+  local sha = resty_sha256:new()
+
+  ngx.req.read_body()
+  local body = ngx.req.get_body_data()
+  if body then
+    sha:update(body)
+    return sha:final()
+  end
+
+  local path = ngx.req.get_body_file()
+  if path then
+    local f, err = io.open(path, "rb")
+    if not f then
+      ngx.log(ngx.ERR, "failed to open request body file: ", err)
+    else
+      while true do
+        local chunk = f:read(BODY_CHUNK_SIZE)
+        if not chunk then
+          break
+        end
+        sha:update(chunk)
+      end
+      f:close()
+    end
+  end
+
+  return sha:final()
+end
+
+-- uri_encode encodes all the characters except the unreserved ones of
+-- RFC 3986, and the slashes when keep_slash is true
+function _M.uri_encode(value, keep_slash)
+  local pattern = keep_slash and "[^A-Za-z0-9%-%._~/]" or "[^A-Za-z0-9%-%._~]"
+  return (string_gsub(value, pattern, function(c)
+    return string_format("%%%02X", string_byte(c))
+  end))
+end
+
+-- canonical_query returns the query string of a request sorted
+-- and encoded as required by AWS Signature Version 4
+function _M.canonical_query(args)
+  if not args or args == "" then
+    return ""
+  end
+
+  local params = {}
+  for pair in string_gmatch(args, "[^&]+") do
+    local name, value = string_match(pair, "^([^=]*)=?(.*)$")
+    params[#params + 1] = {
+      name = _M.uri_encode(ngx.unescape_uri(name)),
+      value = _M.uri_encode(ngx.unescape_uri(value)),
+    }
+  end
+  table_sort(params, function(a, b)
+    if a.name == b.name then
+      return a.value < b.value
+    end
+    return a.name < b.name
+  end)
+
+  local query = {}
+  for i, param in ipairs(params) do
+    query[i] = param.name .. "=" .. param.value
+  end
+  return table_concat(query, "&")
+end
+
+-- sigv4_authorization returns the Authorization header of a request signed
+-- with AWS Signature Version 4. All the headers of the request are signed,
+-- their names must be lowercase.
+function _M.sigv4_authorization(req, creds, region, service)
+  local names = {}
+  for name in pairs(req.headers) do
+    names[#names + 1] = name
+  end
+  table_sort(names)
+
+  local canonical_headers = {}
+  for i, name in ipairs(names) do
+    canonical_headers[i] = name .. ":" .. string_match(req.headers[name], "^%s*(.-)%s*$") .. "\n"
+  end
+  local signed_headers = table_concat(names, ";")
+
+  -- the path is encoded twice, except for S3
+  local canonical_uri = _M.uri_encode(req.uri, true)
+  if service ~= "s3" then
+    canonical_uri = _M.uri_encode(canonical_uri, true)
+  end
+
+  local canonical_request = table_concat({
+    req.method,
+    canonical_uri,
+    _M.canonical_query(req.args),
+    table_concat(canonical_headers),
+    signed_headers,
+    req.payload_hash,
+  }, "\n")
+
+  local amz_date = req.headers["x-amz-date"]
+  local date = string_sub(amz_date, 1, 8)
+  local scope = date .. "/" .. region .. "/" .. service .. "/aws4_request"
+  local string_to_sign = table_concat({ "AWS4-HMAC-SHA256", amz_date, scope, sha256_hex(canonical_request) }, "\n")
+
+  local key = hmac.sha256("AWS4" .. creds.secret_access_key, date)
+  key = hmac.sha256(key, region)
+  key = hmac.sha256(key, service)
+  key = hmac.sha256(key, "aws4_request")
+
+  return string_format("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+    creds.access_key_id, scope, signed_headers, resty_string.to_hex(hmac.sha256(key, string_to_sign)))
+end
+
+-- http_signature returns the Signature header of a request signed with the
+-- hmac-sha256 algorithm of the HTTP Signatures draft (draft-cavage-http-signatures)
+function _M.http_signature(req, creds)
+  local target = string_lower(req.method) .. " " .. req.uri
+  if req.args and req.args ~= "" then
+    target = target .. "?" .. req.args
+  end
+
+  local signing_string = table_concat({
+    "(request-target): " .. target,
+    "host: " .. req.headers.host,
+    "date: " .. req.headers.date,
+    "digest: " .. req.headers.digest,
+  }, "\n")
+
+  return string_format('keyId="%s",algorithm="hmac-sha256",headers="(request-target) host date digest",signature="%s"',
+    creds.key_id, ngx.encode_base64(hmac.sha256(creds.key, signing_string)))
+end
+
+-- request_path returns the path sent to the upstream, the original one
+-- unless it was rewritten
+local function request_path(var)
+  local path = string_match(var.request_uri, "^[^?]*")
+  if ngx.unescape_uri(path) == var.uri then
+    return path
+  end
+  return _M.uri_encode(var.uri, true)
+end
+
+-- rewrite signs the request and sets the headers sent to the upstream
+function _M.rewrite()
+  local var = ngx.var
+  local method = var.upstream_signing_method
+  if not method or method == "" then
+    return
+  end
+
+  local creds = load_credentials(var.upstream_signing_credentials_file, var.upstream_signing_credentials_sha)
+  if not creds then
+    return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+  end
+
+  local host = var.upstream_signing_host
+  if not host or host == "" then
+    host = var.best_http_host or var.host
+  end
+
+  local req = {
+    method = ngx.req.get_method(),
+    args = var.args or "",
+    headers = { host = host },
+  }
+  local now = ngx.time()
+
+  if method == "aws-sigv4" then
+    local service = var.upstream_signing_service
+    -- the payload of S3 requests is not hashed, they can be streamed
+    req.payload_hash = service == "s3" and UNSIGNED_PAYLOAD or resty_string.to_hex(body_sha256())
+    req.uri = var.uri
+    req.headers["x-amz-date"] = os_date("!%Y%m%dT%H%M%SZ", now)
+    req.headers["x-amz-content-sha256"] = req.payload_hash
+    if creds.session_token then
+      req.headers["x-amz-security-token"] = creds.session_token
+      var.upstream_signing_security_token = creds.session_token
+    end
+
+    var.upstream_signing_authorization = _M.sigv4_authorization(req, creds, var.upstream_signing_region, service)
+    var.upstream_signing_date = req.headers["x-amz-date"]
+    var.upstream_signing_content_sha256 = req.payload_hash
+  elseif method == "hmac-sha256" then
+    req.uri = request_path(var)
+    req.headers.date = ngx.http_time(now)
+    req.headers.digest = "SHA-256=" .. ngx.encode_base64(body_sha256())
+
+    var.upstream_signing_signature = _M.http_signature(req, creds)
+    var.upstream_signing_date = req.headers.date
+    var.upstream_signing_digest = req.headers.digest
+  end
+end
+
+return _M
@@ -15,11 +15,11 @@ local _M = {}
 
 function _M.get_nodes(endpoints)
   local nodes = {}
-  local weight = 1
 
   for _, endpoint in pairs(endpoints) do
     local endpoint_string = endpoint.address .. ":" .. endpoint.port
-    nodes[endpoint_string] = weight
+    -- only the endpoints resolved from SRV records have a weight
+    nodes[endpoint_string] = endpoint.weight or 1
   end
 
   return nodes
@@ -8,6 +8,9 @@ local ngx_ERR = ngx.ERR
 local string_format = string.format
 local table_concat = table.concat
 local table_insert = table.insert
+local table_sort = table.sort
+local math_max = math.max
+local math_min = math.min
 local ipairs = ipairs
 local tostring = tostring
 
@@ -27,10 +30,27 @@ do
   end
 end
 
-local function cache_set(host, addresses, ttl)
+local function cache_set(host, addresses, ttl, format_address)
   cache:set(host, addresses, ttl)
+
+  local formatted = addresses
+  if format_address then
+    formatted = {}
+    for i, address in ipairs(addresses) do
+      formatted[i] = format_address(address)
+    end
+  end
   ngx_log(ngx_INFO, string_format("cache set for '%s' with value of [%s] and ttl of %s.",
-    host, table_concat(addresses, ", "), ttl))
+    host, table_concat(formatted, ", "), ttl))
+end
+
+-- the results resolved with a TTL set by the user are cached separately
+-- from the ones using the TTL of the records
+local function cache_key(host, ttl)
+  if ttl then
+    return host .. "#" .. ttl
+  end
+  return host
 end
 
 local function is_fully_qualified(host)
@@ -88,12 +108,49 @@ local function resolve_host(r, host)
   return nil, nil, dns_errors
 end
 
-function _M.lookup(host)
-  local cached_addresses = cache:get(host)
-  if cached_addresses then
-    return cached_addresses
+local function srv_records_and_min_ttl(answers)
+  local records = {}
+  local ttl = MAXIMUM_TTL_VALUE
+
+  for _, ans in ipairs(answers) do
+    if ans.target then
+      table_insert(records, {
+        target = ans.target,
+        port = ans.port,
+        weight = ans.weight,
+        priority = ans.priority,
+      })
+      if ans.ttl < ttl then
+        ttl = ans.ttl
+      end
+    end
   end
 
+  return records, ttl
+end
+
+local function resolve_srv(r, name)
+  local answers, err = r:query(name, { qtype = resolver.TYPE_SRV }, {})
+  if not answers then
+    return nil, nil, { tostring(err) }
+  end
+
+  if answers.errcode then
+    return nil, nil, { string_format("server returned error code: %s: %s",
+      answers.errcode, answers.errstr) }
+  end
+
+  local records, ttl = srv_records_and_min_ttl(answers)
+  if #records == 0 then
+    return nil, nil, { "no SRV record resolved" }
+  end
+
+  return records, ttl, nil
+end
+
+-- query resolves host with the given function, following resolv.conf
+-- for the hosts that are not fully qualified
+local function query(host, resolve)
   local r, err = resolver:new{
     nameservers = resolv_conf.nameservers,
     retrans = 5,
@@ -102,26 +159,25 @@ function _M.lookup(host)
 
   if not r then
     ngx_log(ngx_ERR, string_format("failed to instantiate the resolver: %s", err))
-    return { host }
+    return nil
   end
 
-  local addresses, ttl, dns_errors
+  local results, ttl, dns_errors
 
   -- when the queried domain is fully qualified
   -- then we don't go through resolv_conf.search
   -- NOTE(elvinefendi): currently FQDN as externalName will be supported starting
   -- with K8s 1.15: https://github.com/kubernetes/kubernetes/pull/78385
   if is_fully_qualified(host) then
-    addresses, ttl, dns_errors = resolve_host(r, host)
-    if addresses then
-      cache_set(host, addresses, ttl)
-      return addresses
+    results, ttl, dns_errors = resolve(r, host)
+    if results then
+      return results, ttl
     end
 
     ngx_log(ngx_ERR, "failed to query the DNS server for ",
       host, ":\n", table_concat(dns_errors, "\n"))
 
-    return { host }
+    return nil
   end
 
   -- for non fully qualified domains if number of dots in
@@ -141,10 +197,9 @@ function _M.lookup(host)
     local new_host = resolv_conf.search[i] and
       string_format("%s.%s", host, resolv_conf.search[i]) or host
 
-    addresses, ttl, dns_errors = resolve_host(r, new_host)
-    if addresses then
-      cache_set(host, addresses, ttl)
-      return addresses
+    results, ttl, dns_errors = resolve(r, new_host)
+    if results then
+      return results, ttl
     end
   end
 
@@ -153,7 +208,108 @@ function _M.lookup(host)
       host, ":\n", table_concat(dns_errors, "\n"))
   end
 
-  return { host }
+  return nil
+end
+
+-- lookup returns the addresses of host, or host itself when it can not be
+-- resolved. The addresses are cached for ttl seconds, or the TTL of the
+-- records when ttl is nil.
+function _M.lookup(host, ttl)
+  local key = cache_key(host, ttl)
+  local cached_addresses = cache:get(key)
+  if cached_addresses then
+    return cached_addresses
+  end
+
+  local addresses, records_ttl = query(host, resolve_host)
+  if not addresses then
+    return { host }
+  end
+
+  cache_set(key, addresses, ttl or records_ttl)
+  return addresses
+end
+
+local function format_endpoint(endpoint)
+  return string_format("%s:%s weight=%s", endpoint.address, endpoint.port, endpoint.weight)
+end
+
+-- resolve_srv_targets returns the endpoints of SRV records sharing the same
+-- priority. The records with a weight of 0 are only used when all of them
+-- have a weight of 0.
+local function resolve_srv_targets(records)
+  local weighted = false
+  for _, record in ipairs(records) do
+    if record.weight > 0 then
+      weighted = true
+    end
+  end
+
+  local endpoints = {}
+  local ttl = MAXIMUM_TTL_VALUE
+  for _, record in ipairs(records) do
+    if record.weight > 0 or not weighted then
+      -- the targets of SRV records are always fully qualified
+      local target = record.target
+      if not is_fully_qualified(target) then
+        target = target .. "."
+      end
+
+      local addresses, addresses_ttl = query(target, resolve_host)
+      if addresses then
+        for _, address in ipairs(addresses) do
+          table_insert(endpoints, {
+            address = address,
+            port = tostring(record.port),
+            weight = math_max(record.weight, 1),
+          })
+        end
+        ttl = math_min(ttl, addresses_ttl)
+      end
+    end
+  end
+
+  return endpoints, ttl
+end
+
+-- lookup_srv returns the endpoints of the SRV records of name, with their
+-- addresses, ports and weights, or nil when they can not be resolved. Only
+-- the records with the lowest priority whose targets resolve are used.
+-- The endpoints are cached for ttl seconds, or the TTL of the records
+-- when ttl is nil.
+function _M.lookup_srv(name, ttl)
+  local key = cache_key("srv:" .. name, ttl)
+  local cached_endpoints = cache:get(key)
+  if cached_endpoints then
+    return cached_endpoints
+  end
+
+  local records, records_ttl = query(name, resolve_srv)
+  if not records then
+    return nil
+  end
+
+  table_sort(records, function(a, b) return a.priority < b.priority end)
+
+  local endpoints, endpoints_ttl = {}, MAXIMUM_TTL_VALUE
+  local i = 1
+  while #endpoints == 0 and i <= #records do
+    local priority = records[i].priority
+    local group = {}
+    while i <= #records and records[i].priority == priority do
+      table_insert(group, records[i])
+      i = i + 1
+    end
+    endpoints, endpoints_ttl = resolve_srv_targets(group)
+  end
+
+  if #endpoints == 0 then
+    ngx_log(ngx_ERR, "failed to resolve the targets of the SRV records of ", name)
+    return nil
+  end
+
+  cache_set(key, endpoints, ttl or math_min(records_ttl, endpoints_ttl), format_endpoint)
+  return endpoints
 end
 
 setmetatable(_M, {__index = { _cache = cache }})
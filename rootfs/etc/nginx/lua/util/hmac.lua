@@ -0,0 +1,35 @@
+local bit = require("bit")
+local resty_sha256 = require("resty.sha256")
+
+local string_byte = string.byte
+local string_char = string.char
+local table_concat = table.concat
+local bxor = bit.bxor
+
+local _M = {}
+
+local BLOCK_SIZE = 64 -- bytes, of SHA-256
+
+local function sha256(value)
+  local sha = resty_sha256:new()
+  sha:update(value)
+  return sha:final()
+end
+
+local function pad(key, value)
+  local bytes = {}
+  for i = 1, BLOCK_SIZE do
+    bytes[i] = string_char(bxor(string_byte(key, i) or 0, value))
+  end
+  return table_concat(bytes)
+end
+
+-- sha256 returns the binary HMAC-SHA256 (RFC 2104) of message
+function _M.sha256(key, message)
+  if #key > BLOCK_SIZE then
+    key = sha256(key)
+  end
+  return sha256(pad(key, 0x5c) .. sha256(pad(key, 0x36) .. message))
+end
+
+return _M
@@ -36,6 +36,12 @@ SHDICT_ARGS=(
     "--shdict" "high_throughput_tracker 1M"
     "--shdict" "balancer_ewma_last_touched_at 1M"
     "--shdict" "balancer_ewma_locks 512k"
+    "--shdict" "auth_cookie_sessions 1M"
+    "--shdict" "balancer_retry_budget 1M"
+    "--shdict" "balancer_connect_backoff 1M"
+    "--shdict" "concurrency_limit 1M"
+    "--shdict" "bot_mitigation 1M"
+    "--shdict" "monitor_pending 1M"
     "./rootfs/etc/nginx/lua/test/run.lua"
 )
 